@@ -0,0 +1,289 @@
+// Package notify provides a work executor that sends templated notifications.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"text/template"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// WorkTypeNotify is the work type handled by the notification executor
+const WorkTypeNotify layer0.WorkType = "notify"
+
+// ConfigParameter is the work parameter holding the executor configuration
+const ConfigParameter = "executor_config"
+
+// Notification is a rendered message ready for delivery
+type Notification struct {
+	Channel    string   `json:"channel"`
+	Recipients []string `json:"recipients"`
+	Subject    string   `json:"subject"`
+	Body       string   `json:"body"`
+}
+
+// Notifier delivers notifications over a channel such as email or chat and
+// returns an ID identifying the delivery
+type Notifier interface {
+	Send(ctx context.Context, notification Notification) (string, error)
+}
+
+// Config describes a notification. Recipients, Subject and Body are text/template
+// templates rendered against TemplateData.
+type Config struct {
+	Channel    string   `json:"channel"`
+	Recipients []string `json:"recipients"`
+	Subject    string   `json:"subject"`
+	Body       string   `json:"body"`
+}
+
+// TemplateData is the data templates are rendered against
+type TemplateData struct {
+	Context map[string]interface{}
+	Input   interface{}
+}
+
+// NotificationExecutor executes notify work by rendering and sending a notification
+type NotificationExecutor struct {
+	notifier Notifier
+}
+
+// NewNotificationExecutor creates a new notification executor over the given notifier
+func NewNotificationExecutor(notifier Notifier) *NotificationExecutor {
+	return &NotificationExecutor{
+		notifier: notifier,
+	}
+}
+
+// Execute renders and sends the configured notification
+func (ne *NotificationExecutor) Execute(work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	return ne.ExecuteWithContext(context.Background(), work, workContext)
+}
+
+// ExecuteWithContext renders and sends the configured notification, returning the delivery ID
+func (ne *NotificationExecutor) ExecuteWithContext(ctx context.Context, work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	config, err := ParseConfig(work)
+	if err != nil {
+		return nil, err
+	}
+
+	notification, err := Render(config, newTemplateData(work, workContext))
+	if err != nil {
+		return nil, fmt.Errorf("notify work %s: %w", work.GetID(), err)
+	}
+
+	deliveryID, err := ne.notifier.Send(ctx, notification)
+	if err != nil {
+		return nil, fmt.Errorf("notify work %s failed to send on %s: %w", work.GetID(), notification.Channel, err)
+	}
+
+	return map[string]interface{}{
+		"delivery_id": deliveryID,
+		"channel":     notification.Channel,
+		"recipients":  notification.Recipients,
+	}, nil
+}
+
+// CanExecute checks if the executor can execute the given work type
+func (ne *NotificationExecutor) CanExecute(workType layer0.WorkType) bool {
+	return workType == WorkTypeNotify
+}
+
+// GetSupportedTypes returns the supported work types
+func (ne *NotificationExecutor) GetSupportedTypes() []layer0.WorkType {
+	return []layer0.WorkType{WorkTypeNotify}
+}
+
+// Validate checks the work's executor configuration without running it
+func (ne *NotificationExecutor) Validate(work layer0.Work) error {
+	_, err := ParseConfig(work)
+	return err
+}
+
+// GetSchema describes the executor configuration as a JSON schema with examples
+func (ne *NotificationExecutor) GetSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"channel", "recipients", "body"},
+		"properties": map[string]interface{}{
+			"channel": map[string]interface{}{
+				"type":        "string",
+				"description": "Delivery channel understood by the notifier, such as email or slack",
+			},
+			"recipients": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Recipient templates",
+			},
+			"subject": map[string]interface{}{
+				"type":        "string",
+				"description": "Subject template",
+			},
+			"body": map[string]interface{}{
+				"type":        "string",
+				"description": "Body template; .Context holds the workflow context and .Input the work input",
+			},
+		},
+		"examples": []interface{}{
+			map[string]interface{}{
+				"channel":    "email",
+				"recipients": []string{"{{.Context.customer_email}}"},
+				"subject":    "Order {{.Context.order_id}} shipped",
+				"body":       "Hi {{.Context.customer_name}}, your order is on its way.",
+			},
+			map[string]interface{}{
+				"channel":    "slack",
+				"recipients": []string{"#fulfilment"},
+				"body":       "Order {{.Context.order_id}} needs review: {{.Input.reason}}",
+			},
+		},
+	}
+}
+
+// ParseConfig reads the executor configuration from the work parameters. The
+// configuration may be a Config or a map decoded from a definition file.
+func ParseConfig(work layer0.Work) (Config, error) {
+	value, exists := work.GetConfiguration().Parameters[ConfigParameter]
+	if !exists {
+		return Config{}, fmt.Errorf("notify work %s requires an %s parameter", work.GetID(), ConfigParameter)
+	}
+
+	var config Config
+	switch v := value.(type) {
+	case Config:
+		config = v
+	case map[string]interface{}:
+		config.Channel, _ = v["channel"].(string)
+		config.Subject, _ = v["subject"].(string)
+		config.Body, _ = v["body"].(string)
+
+		switch recipients := v["recipients"].(type) {
+		case nil:
+		case []string:
+			config.Recipients = recipients
+		case []interface{}:
+			for _, recipient := range recipients {
+				name, ok := recipient.(string)
+				if !ok {
+					return Config{}, fmt.Errorf("notify work %s has non-string recipient %v", work.GetID(), recipient)
+				}
+				config.Recipients = append(config.Recipients, name)
+			}
+		default:
+			return Config{}, fmt.Errorf("unsupported recipients type %T for notify work %s", recipients, work.GetID())
+		}
+	default:
+		return Config{}, fmt.Errorf("unsupported %s type %T for notify work %s", ConfigParameter, value, work.GetID())
+	}
+
+	if config.Channel == "" {
+		return Config{}, fmt.Errorf("notify work %s requires a channel", work.GetID())
+	}
+	if len(config.Recipients) == 0 {
+		return Config{}, fmt.Errorf("notify work %s requires at least one recipient", work.GetID())
+	}
+	if config.Body == "" {
+		return Config{}, fmt.Errorf("notify work %s requires a body", work.GetID())
+	}
+
+	return config, nil
+}
+
+// Render renders the configuration's templates into a notification
+func Render(config Config, data TemplateData) (Notification, error) {
+	notification := Notification{
+		Channel:    config.Channel,
+		Recipients: make([]string, 0, len(config.Recipients)),
+	}
+
+	for _, recipient := range config.Recipients {
+		rendered, err := renderTemplate("recipient", recipient, data)
+		if err != nil {
+			return Notification{}, err
+		}
+		notification.Recipients = append(notification.Recipients, rendered)
+	}
+
+	var err error
+	if notification.Subject, err = renderTemplate("subject", config.Subject, data); err != nil {
+		return Notification{}, err
+	}
+	if notification.Body, err = renderTemplate("body", config.Body, data); err != nil {
+		return Notification{}, err
+	}
+
+	return notification, nil
+}
+
+// renderTemplate renders a single template, failing on references to missing keys
+func renderTemplate(name, text string, data TemplateData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", name, err)
+	}
+	return buffer.String(), nil
+}
+
+// newTemplateData collects the context values and work input templates render against
+func newTemplateData(work layer0.Work, workContext *layer0.Context) TemplateData {
+	data := TemplateData{
+		Context: make(map[string]interface{}),
+		Input:   work.GetInput(),
+	}
+
+	if workContext != nil {
+		for _, key := range workContext.Keys() {
+			if value, exists := workContext.Get(key); exists {
+				data.Context[key] = value
+			}
+		}
+	}
+
+	return data
+}
+
+// MockNotifier records notifications instead of delivering them
+type MockNotifier struct {
+	sent  []Notification
+	err   error
+	mutex sync.Mutex
+}
+
+// NewMockNotifier creates a new mock notifier that fails every send with err when it is set
+func NewMockNotifier(err error) *MockNotifier {
+	return &MockNotifier{
+		err:   err,
+		mutex: sync.Mutex{},
+	}
+}
+
+// Send records the notification and returns a sequential delivery ID
+func (mn *MockNotifier) Send(ctx context.Context, notification Notification) (string, error) {
+	mn.mutex.Lock()
+	defer mn.mutex.Unlock()
+
+	if mn.err != nil {
+		return "", mn.err
+	}
+
+	mn.sent = append(mn.sent, notification)
+	return fmt.Sprintf("mock-%d", len(mn.sent)), nil
+}
+
+// Sent returns the notifications sent so far
+func (mn *MockNotifier) Sent() []Notification {
+	mn.mutex.Lock()
+	defer mn.mutex.Unlock()
+
+	sent := make([]Notification, len(mn.sent))
+	copy(sent, mn.sent)
+	return sent
+}
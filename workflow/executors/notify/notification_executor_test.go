@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+func newNotifyWork(config map[string]interface{}, input interface{}) layer0.Work {
+	work := layer0.NewWork("notify-customer", WorkTypeNotify, "Notify Customer")
+	work.Configuration.Parameters = map[string]interface{}{ConfigParameter: config}
+	return work.SetInput(input)
+}
+
+func TestNotificationExecutorRendersTemplates(t *testing.T) {
+	notifier := NewMockNotifier(nil)
+	executor := NewNotificationExecutor(notifier)
+
+	if !executor.CanExecute(WorkTypeNotify) {
+		t.Error("NotificationExecutor should execute notify work")
+	}
+
+	work := newNotifyWork(map[string]interface{}{
+		"channel":    "email",
+		"recipients": []interface{}{"{{.Context.customer_email}}", "ops@example.com"},
+		"subject":    "Order {{.Context.order_id}} shipped",
+		"body":       "Hi {{.Context.customer_name}}, tracking {{.Input.tracking}}.",
+	}, map[string]interface{}{"tracking": "TRK-9"})
+
+	workContext := layer0.NewContext("order-context", layer0.ContextScopeWorkflow, "Order Context").
+		Set("customer_email", "ada@example.com").
+		Set("customer_name", "Ada").
+		Set("order_id", 42)
+
+	output, err := executor.Execute(work, workContext)
+	if err != nil {
+		t.Fatalf("Execute should not return error: %v", err)
+	}
+
+	if deliveryID := output.(map[string]interface{})["delivery_id"]; deliveryID != "mock-1" {
+		t.Errorf("Expected delivery ID mock-1, got %v", deliveryID)
+	}
+
+	sent := notifier.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("Expected 1 notification sent, got %d", len(sent))
+	}
+
+	expected := Notification{
+		Channel:    "email",
+		Recipients: []string{"ada@example.com", "ops@example.com"},
+		Subject:    "Order 42 shipped",
+		Body:       "Hi Ada, tracking TRK-9.",
+	}
+	if fmt.Sprint(sent[0]) != fmt.Sprint(expected) {
+		t.Errorf("Expected notification %+v, got %+v", expected, sent[0])
+	}
+}
+
+func TestNotificationExecutorErrors(t *testing.T) {
+	workContext := layer0.NewContext("order-context", layer0.ContextScopeWorkflow, "Order Context")
+
+	// Missing template keys fail instead of rendering "<no value>"
+	missingKey := newNotifyWork(map[string]interface{}{
+		"channel":    "email",
+		"recipients": []interface{}{"ops@example.com"},
+		"body":       "Order {{.Context.order_id}}",
+	}, nil)
+	notifier := NewMockNotifier(nil)
+	if _, err := NewNotificationExecutor(notifier).Execute(missingKey, workContext); err == nil {
+		t.Error("Execute should return error for a missing template key")
+	}
+	if len(notifier.Sent()) != 0 {
+		t.Error("Nothing should be sent when rendering fails")
+	}
+
+	noRecipients := newNotifyWork(map[string]interface{}{"channel": "email", "body": "Hello"}, nil)
+	if _, err := NewNotificationExecutor(notifier).Execute(noRecipients, workContext); err == nil {
+		t.Error("Execute should return error without recipients")
+	}
+	if err := NewNotificationExecutor(notifier).Validate(noRecipients); err == nil {
+		t.Error("Validate should return error without recipients")
+	}
+
+	valid := newNotifyWork(map[string]interface{}{
+		"channel":    "slack",
+		"recipients": []interface{}{"#ops"},
+		"body":       "Hello",
+	}, nil)
+	if err := NewNotificationExecutor(notifier).Validate(valid); err != nil {
+		t.Errorf("Validate should accept a valid configuration: %v", err)
+	}
+	failing := NewNotificationExecutor(NewMockNotifier(fmt.Errorf("slack unavailable")))
+	if _, err := failing.Execute(valid, workContext); err == nil {
+		t.Error("Execute should return the notifier's error")
+	}
+}
+
+func TestNotificationExecutorGetSchema(t *testing.T) {
+	schema := NewNotificationExecutor(nil).GetSchema()
+
+	examples, ok := schema["examples"].([]interface{})
+	if !ok || len(examples) == 0 {
+		t.Fatal("Schema should include examples")
+	}
+
+	for _, example := range examples {
+		if _, err := ParseConfig(newNotifyWork(example.(map[string]interface{}), nil)); err != nil {
+			t.Errorf("Schema example should parse: %v", err)
+		}
+	}
+}
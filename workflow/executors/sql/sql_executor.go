@@ -0,0 +1,270 @@
+// Package sql provides a work executor that runs parameterized database queries.
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// WorkTypeSQL is the work type handled by the SQL executor
+const WorkTypeSQL layer0.WorkType = "sql"
+
+// ConfigParameter is the work parameter holding the executor configuration
+const ConfigParameter = "executor_config"
+
+// Mode selects whether a statement returns rows or only a count of affected rows
+type Mode string
+
+const (
+	ModeQuery Mode = "query"
+	ModeExec  Mode = "exec"
+)
+
+// Querier is the subset of *sql.DB the executor needs; *sql.DB and *sql.Tx satisfy it
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Config describes the statement a piece of SQL work runs. Params name the work
+// input fields bound, in order, to the statement's placeholders; values are never
+// interpolated into the query text.
+type Config struct {
+	Query  string   `json:"query"`
+	Params []string `json:"params,omitempty"`
+	Mode   Mode     `json:"mode"`
+}
+
+// SQLExecutor executes SQL work against a database
+type SQLExecutor struct {
+	db      Querier
+	timeout time.Duration
+}
+
+// NewSQLExecutor creates a new SQL executor. Statements are cancelled after the
+// work's TimeoutSeconds, or after timeout when the work sets none; zero waits indefinitely.
+func NewSQLExecutor(db Querier, timeout time.Duration) *SQLExecutor {
+	return &SQLExecutor{
+		db:      db,
+		timeout: timeout,
+	}
+}
+
+// Execute runs the configured statement
+func (se *SQLExecutor) Execute(work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	return se.ExecuteWithContext(context.Background(), work, workContext)
+}
+
+// ExecuteWithContext runs the configured statement, returning rows for queries and
+// the number of affected rows for execs
+func (se *SQLExecutor) ExecuteWithContext(ctx context.Context, work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	config, err := ParseConfig(work)
+	if err != nil {
+		return nil, err
+	}
+
+	args, err := bindParams(work, config.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := se.timeout
+	if seconds := work.GetConfiguration().TimeoutSeconds; seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if config.Mode == ModeExec {
+		result, err := se.db.ExecContext(ctx, config.Query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("sql work %s failed: %w", work.GetID(), err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("sql work %s failed to read rows affected: %w", work.GetID(), err)
+		}
+		return map[string]interface{}{"rows_affected": affected}, nil
+	}
+
+	rows, err := se.db.QueryContext(ctx, config.Query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("sql work %s failed: %w", work.GetID(), err)
+	}
+	defer rows.Close()
+
+	records, err := scanRows(rows)
+	if err != nil {
+		return nil, fmt.Errorf("sql work %s failed to read rows: %w", work.GetID(), err)
+	}
+	return map[string]interface{}{"rows": records, "row_count": len(records)}, nil
+}
+
+// CanExecute checks if the executor can execute the given work type
+func (se *SQLExecutor) CanExecute(workType layer0.WorkType) bool {
+	return workType == WorkTypeSQL
+}
+
+// GetSupportedTypes returns the supported work types
+func (se *SQLExecutor) GetSupportedTypes() []layer0.WorkType {
+	return []layer0.WorkType{WorkTypeSQL}
+}
+
+// Validate checks the work's executor configuration without running it
+func (se *SQLExecutor) Validate(work layer0.Work) error {
+	_, err := ParseConfig(work)
+	return err
+}
+
+// GetSchema describes the executor configuration as a JSON schema with examples
+func (se *SQLExecutor) GetSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"query"},
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "Statement to run, using the driver's placeholders for parameters",
+			},
+			"params": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Work input fields bound to the placeholders in order",
+			},
+			"mode": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{string(ModeQuery), string(ModeExec)},
+				"default":     string(ModeQuery),
+				"description": "query returns rows; exec returns rows_affected",
+			},
+		},
+		"examples": []interface{}{
+			map[string]interface{}{
+				"query":  "SELECT id, email FROM customers WHERE region = ?",
+				"params": []string{"region"},
+				"mode":   string(ModeQuery),
+			},
+			map[string]interface{}{
+				"query":  "UPDATE orders SET status = ? WHERE id = ?",
+				"params": []string{"status", "order_id"},
+				"mode":   string(ModeExec),
+			},
+		},
+	}
+}
+
+// ParseConfig reads the executor configuration from the work parameters. The
+// configuration may be a Config or a map decoded from a definition file.
+func ParseConfig(work layer0.Work) (Config, error) {
+	value, exists := work.GetConfiguration().Parameters[ConfigParameter]
+	if !exists {
+		return Config{}, fmt.Errorf("sql work %s requires an %s parameter", work.GetID(), ConfigParameter)
+	}
+
+	var config Config
+	switch v := value.(type) {
+	case Config:
+		config = v
+	case map[string]interface{}:
+		config.Query, _ = v["query"].(string)
+		if mode, ok := v["mode"].(string); ok {
+			config.Mode = Mode(mode)
+		}
+
+		switch params := v["params"].(type) {
+		case nil:
+		case []string:
+			config.Params = params
+		case []interface{}:
+			for _, param := range params {
+				name, ok := param.(string)
+				if !ok {
+					return Config{}, fmt.Errorf("sql work %s has non-string param %v", work.GetID(), param)
+				}
+				config.Params = append(config.Params, name)
+			}
+		default:
+			return Config{}, fmt.Errorf("unsupported params type %T for sql work %s", params, work.GetID())
+		}
+	default:
+		return Config{}, fmt.Errorf("unsupported %s type %T for sql work %s", ConfigParameter, value, work.GetID())
+	}
+
+	if config.Query == "" {
+		return Config{}, fmt.Errorf("sql work %s requires a query", work.GetID())
+	}
+
+	switch config.Mode {
+	case "":
+		config.Mode = ModeQuery
+	case ModeQuery, ModeExec:
+	default:
+		return Config{}, fmt.Errorf("unsupported mode %q for sql work %s", config.Mode, work.GetID())
+	}
+
+	return config, nil
+}
+
+// bindParams looks up the named parameters in the work input
+func bindParams(work layer0.Work, params []string) ([]interface{}, error) {
+	if len(params) == 0 {
+		return nil, nil
+	}
+
+	input, ok := work.GetInput().(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("sql work %s requires a map input to bind params", work.GetID())
+	}
+
+	args := make([]interface{}, 0, len(params))
+	for _, name := range params {
+		value, exists := input[name]
+		if !exists {
+			return nil, fmt.Errorf("sql work %s is missing input %s", work.GetID(), name)
+		}
+		args = append(args, value)
+	}
+	return args, nil
+}
+
+// scanRows reads every row into a map keyed by column name
+func scanRows(rows *sql.Rows) ([]map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for index := range values {
+			pointers[index] = &values[index]
+		}
+
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]interface{}, len(columns))
+		for index, column := range columns {
+			// Drivers return text as bytes; expose it as a string
+			if bytes, ok := values[index].([]byte); ok {
+				record[column] = string(bytes)
+			} else {
+				record[column] = values[index]
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
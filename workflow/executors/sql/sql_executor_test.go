@@ -0,0 +1,229 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// fakeDriver is a minimal database/sql driver over an in-memory customers table.
+// INSERT statements append their arguments as a row; SELECT statements return the
+// rows whose region equals the first argument. Every statement is recorded so tests
+// can check that values are bound rather than interpolated.
+type fakeDriver struct {
+	mutex      sync.Mutex
+	rows       [][]driver.Value
+	statements []string
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{driver: d}, nil
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepare not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions not supported")
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.driver.mutex.Lock()
+	defer c.driver.mutex.Unlock()
+
+	c.driver.statements = append(c.driver.statements, query)
+	if !strings.HasPrefix(query, "INSERT") {
+		return nil, fmt.Errorf("unsupported statement %q", query)
+	}
+
+	row := make([]driver.Value, len(args))
+	for index, arg := range args {
+		row[index] = arg.Value
+	}
+	c.driver.rows = append(c.driver.rows, row)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.driver.mutex.Lock()
+	defer c.driver.mutex.Unlock()
+
+	c.driver.statements = append(c.driver.statements, query)
+	if !strings.HasPrefix(query, "SELECT") || len(args) != 1 {
+		return nil, fmt.Errorf("unsupported statement %q", query)
+	}
+
+	matched := &fakeRows{}
+	for _, row := range c.driver.rows {
+		if row[1] == args[0].Value {
+			matched.rows = append(matched.rows, row)
+		}
+	}
+	return matched, nil
+}
+
+type fakeRows struct {
+	rows  [][]driver.Value
+	index int
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"email", "region"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.index >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.index])
+	r.index++
+	return nil
+}
+
+var registerOnce sync.Once
+var testDriver = &fakeDriver{}
+
+func openTestDB(t *testing.T) *sql.DB {
+	registerOnce.Do(func() {
+		sql.Register("sqlexecutor-fake", testDriver)
+	})
+
+	testDriver.mutex.Lock()
+	testDriver.rows = nil
+	testDriver.statements = nil
+	testDriver.mutex.Unlock()
+
+	db, err := sql.Open("sqlexecutor-fake", "")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newSQLWork(id layer0.WorkID, config map[string]interface{}, input map[string]interface{}) layer0.Work {
+	work := layer0.NewWork(id, WorkTypeSQL, string(id))
+	work.Configuration.Parameters = map[string]interface{}{ConfigParameter: config}
+	return work.SetInput(input)
+}
+
+func TestSQLExecutorInsertAndSelect(t *testing.T) {
+	db := openTestDB(t)
+	executor := NewSQLExecutor(db, 0)
+
+	if !executor.CanExecute(WorkTypeSQL) {
+		t.Error("SQLExecutor should execute sql work")
+	}
+
+	insert := map[string]interface{}{
+		"query":  "INSERT INTO customers (email, region) VALUES (?, ?)",
+		"params": []interface{}{"email", "region"},
+		"mode":   "exec",
+	}
+	for _, input := range []map[string]interface{}{
+		{"email": "ada@example.com", "region": "west"},
+		{"email": "bob@example.com", "region": "east"},
+	} {
+		output, err := executor.Execute(newSQLWork("insert", insert, input), nil)
+		if err != nil {
+			t.Fatalf("Insert should not return error: %v", err)
+		}
+		if affected := output.(map[string]interface{})["rows_affected"]; affected != int64(1) {
+			t.Errorf("Expected 1 row affected, got %v", affected)
+		}
+	}
+
+	selectQuery := map[string]interface{}{
+		"query":  "SELECT email, region FROM customers WHERE region = ?",
+		"params": []interface{}{"region"},
+	}
+	output, err := executor.Execute(newSQLWork("select", selectQuery, map[string]interface{}{"region": "west"}), nil)
+	if err != nil {
+		t.Fatalf("Select should not return error: %v", err)
+	}
+
+	rows := output.(map[string]interface{})["rows"].([]map[string]interface{})
+	if len(rows) != 1 || rows[0]["email"] != "ada@example.com" {
+		t.Errorf("Expected ada@example.com in west, got %v", rows)
+	}
+
+	// Input values are bound, never spliced into the statement
+	injected := map[string]interface{}{"region": "west' OR '1'='1"}
+	output, err = executor.Execute(newSQLWork("select", selectQuery, injected), nil)
+	if err != nil {
+		t.Fatalf("Select should not return error: %v", err)
+	}
+	if count := output.(map[string]interface{})["row_count"]; count != 0 {
+		t.Errorf("Expected no rows for an injected region, got %v", count)
+	}
+
+	for _, statement := range testDriver.statements {
+		if strings.Contains(statement, "OR '1'='1") {
+			t.Errorf("Statement should not contain input values: %s", statement)
+		}
+	}
+}
+
+func TestSQLExecutorConfigErrors(t *testing.T) {
+	executor := NewSQLExecutor(openTestDB(t), 0)
+
+	missing := layer0.NewWork("missing", WorkTypeSQL, "Missing")
+	if _, err := executor.Execute(missing, nil); err == nil {
+		t.Error("Execute should return error without an executor_config")
+	}
+
+	badMode := newSQLWork("bad-mode", map[string]interface{}{"query": "SELECT 1", "mode": "stream"}, nil)
+	if _, err := executor.Execute(badMode, nil); err == nil {
+		t.Error("Execute should return error for an unknown mode")
+	}
+	if err := executor.Validate(badMode); err == nil {
+		t.Error("Validate should return error for an unknown mode")
+	}
+	if err := executor.Validate(newSQLWork("valid", map[string]interface{}{"query": "SELECT 1"}, nil)); err != nil {
+		t.Errorf("Validate should accept a valid configuration: %v", err)
+	}
+
+	missingInput := newSQLWork("missing-input", map[string]interface{}{
+		"query":  "SELECT email, region FROM customers WHERE region = ?",
+		"params": []interface{}{"region"},
+	}, map[string]interface{}{})
+	if _, err := executor.Execute(missingInput, nil); err == nil {
+		t.Error("Execute should return error when a param is missing from the input")
+	}
+}
+
+func TestSQLExecutorGetSchema(t *testing.T) {
+	schema := NewSQLExecutor(nil, 0).GetSchema()
+
+	examples, ok := schema["examples"].([]interface{})
+	if !ok || len(examples) == 0 {
+		t.Fatal("Schema should include examples")
+	}
+
+	for _, example := range examples {
+		work := newSQLWork("example", example.(map[string]interface{}), nil)
+		if _, err := ParseConfig(work); err != nil {
+			t.Errorf("Schema example should parse: %v", err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package layer0
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// CanonicalJSON encodes a value as JSON with map keys sorted, so semantically equal
+// values encode identically regardless of map insertion order. Maps with non-string
+// keys are keyed by the formatted key, and numbers encode by value, so int 1 and
+// float64 1 are equal.
+func CanonicalJSON(value interface{}) ([]byte, error) {
+	return json.Marshal(canonicalize(reflect.ValueOf(value)))
+}
+
+// CanonicalHash returns the hex SHA-256 of a value's canonical JSON. Values that
+// cannot be encoded as JSON are hashed by their formatted representation.
+func CanonicalHash(value interface{}) string {
+	data, err := CanonicalJSON(value)
+	if err != nil {
+		data = []byte(fmt.Sprintf("%T:%v", value, value))
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Hash returns the canonical hash of the context's data
+func (c *Context) Hash() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return CanonicalHash(c.Data)
+}
+
+// canonicalize converts maps to string-keyed maps, which encoding/json writes in
+// sorted key order, and replaces values JSON cannot encode with their formatted form
+func canonicalize(value reflect.Value) interface{} {
+	if !value.IsValid() {
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if value.IsNil() {
+			return nil
+		}
+		return canonicalize(value.Elem())
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+		canonical := make(map[string]interface{}, value.Len())
+		iterator := value.MapRange()
+		for iterator.Next() {
+			canonical[fmt.Sprint(iterator.Key().Interface())] = canonicalize(iterator.Value())
+		}
+		return canonical
+	case reflect.Slice:
+		if value.IsNil() {
+			return nil
+		}
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return value.Bytes()
+		}
+		fallthrough
+	case reflect.Array:
+		canonical := make([]interface{}, value.Len())
+		for index := range canonical {
+			canonical[index] = canonicalize(value.Index(index))
+		}
+		return canonical
+	case reflect.Float32, reflect.Float64:
+		if float := value.Float(); math.IsNaN(float) || math.IsInf(float, 0) {
+			return fmt.Sprint(float)
+		}
+		return value.Interface()
+	case reflect.Complex64, reflect.Complex128, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return fmt.Sprintf("%T:%v", value.Interface(), value.Interface())
+	default:
+		return value.Interface()
+	}
+}
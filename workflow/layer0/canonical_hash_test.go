@@ -0,0 +1,71 @@
+package layer0
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCanonicalHashIgnoresInsertionOrder(t *testing.T) {
+	first := map[string]interface{}{}
+	first["customer"] = map[string]interface{}{"id": 7, "tags": []interface{}{"vip", "eu"}}
+	first["amount"] = 42.5
+	first["approved"] = true
+
+	second := map[string]interface{}{}
+	second["approved"] = true
+	second["amount"] = 42.5
+	second["customer"] = map[string]interface{}{"tags": []interface{}{"vip", "eu"}, "id": 7}
+
+	for attempt := 0; attempt < 20; attempt++ {
+		if CanonicalHash(first) != CanonicalHash(second) {
+			t.Fatal("Semantically equal maps should hash the same regardless of insertion order")
+		}
+	}
+
+	// Numbers compare by value across Go types
+	if CanonicalHash(map[string]interface{}{"n": 1}) != CanonicalHash(map[string]interface{}{"n": 1.0}) {
+		t.Error("int 1 and float64 1 should hash the same")
+	}
+
+	// Non-string keys are canonicalized too
+	if CanonicalHash(map[int]string{1: "a", 2: "b"}) != CanonicalHash(map[int]string{2: "b", 1: "a"}) {
+		t.Error("Maps with int keys should hash the same regardless of insertion order")
+	}
+}
+
+func TestCanonicalHashDistinguishesValues(t *testing.T) {
+	values := []interface{}{
+		nil,
+		map[string]interface{}{"amount": 42.5},
+		map[string]interface{}{"amount": 42.6},
+		map[string]interface{}{"amount": "42.5"},
+		map[string]interface{}{"tags": []interface{}{"vip", "eu"}},
+		map[string]interface{}{"tags": []interface{}{"eu", "vip"}},
+		map[string]interface{}{"nested": map[string]interface{}{"a": true}},
+		map[string]interface{}{"nested": map[string]interface{}{"a": false}},
+		[]byte("raw"),
+		math.NaN(),
+	}
+
+	seen := map[string]int{}
+	for index, value := range values {
+		hash := CanonicalHash(value)
+		if previous, exists := seen[hash]; exists {
+			t.Errorf("Values %d and %d should hash differently", previous, index)
+		}
+		seen[hash] = index
+	}
+}
+
+func TestContextHash(t *testing.T) {
+	first := NewContext("first", ContextScopeWorkflow, "First").Set("a", 1).Set("b", "two")
+	second := NewContext("second", ContextScopeGlobal, "Second").Set("b", "two").Set("a", 1)
+
+	if first.Hash() != second.Hash() {
+		t.Error("Contexts with the same data should hash the same")
+	}
+
+	if first.Hash() == first.Set("a", 2).Hash() {
+		t.Error("Changing a value should change the hash")
+	}
+}
@@ -2,6 +2,7 @@ package layer0
 
 import (
 	"fmt"
+	"reflect"
 	"sync"
 	"time"
 )
@@ -23,6 +24,18 @@ const (
 	ContextScopeWork ContextScope = "work"
 )
 
+// MergeConflictStrategy defines how a merge resolves keys set to different values in both contexts
+type MergeConflictStrategy string
+
+const (
+	// MergeConflictOverwrite resolves conflicts with the value from the merged context
+	MergeConflictOverwrite MergeConflictStrategy = "overwrite"
+	// MergeConflictKeepExisting resolves conflicts by keeping the existing value
+	MergeConflictKeepExisting MergeConflictStrategy = "keep-existing"
+	// MergeConflictError fails the merge on any conflict
+	MergeConflictError MergeConflictStrategy = "error"
+)
+
 // ContextMetadata contains metadata about a context
 type ContextMetadata struct {
 	Name        string            `json:"name"`
@@ -58,6 +71,7 @@ type ContextInterface interface {
 	Size() int
 	Clear() *Context
 	Merge(other *Context) *Context
+	MergeWithStrategy(other *Context, strategy MergeConflictStrategy) (*Context, error)
 	Clone() *Context
 	Validate() error
 }
@@ -237,6 +251,39 @@ func (c *Context) Merge(other *Context) *Context {
 	return newContext
 }
 
+// MergeWithStrategy creates a new context with data from another context merged in,
+// resolving keys set to different values in both contexts with the given strategy (immutable)
+func (c *Context) MergeWithStrategy(other *Context, strategy MergeConflictStrategy) (*Context, error) {
+	switch strategy {
+	case MergeConflictOverwrite, MergeConflictKeepExisting, MergeConflictError:
+	default:
+		return nil, fmt.Errorf("unsupported merge conflict strategy: %s", strategy)
+	}
+
+	c.mutex.RLock()
+	newContext := c.cloneLocked()
+	c.mutex.RUnlock()
+
+	other.mutex.RLock()
+	defer other.mutex.RUnlock()
+
+	for key, value := range other.Data {
+		existing, exists := newContext.Data[key]
+		if exists && !reflect.DeepEqual(existing, value) {
+			switch strategy {
+			case MergeConflictKeepExisting:
+				continue
+			case MergeConflictError:
+				return nil, fmt.Errorf("merge conflict on key %s", key)
+			}
+		}
+		newContext.Data[key] = value
+	}
+
+	newContext.Metadata.UpdatedAt = time.Now()
+	return newContext, nil
+}
+
 // Clone creates a deep copy of the context
 func (c *Context) Clone() *Context {
 	c.mutex.RLock()
@@ -331,3 +331,45 @@ func TestContextConcurrency(t *testing.T) {
 		}
 	}
 }
+
+func TestContextMergeWithStrategy(t *testing.T) {
+	base := NewContext("base", ContextScopeWorkflow, "Base").Set("shared", "base").Set("same", 1)
+	other := NewContext("other", ContextScopeWorkflow, "Other").Set("shared", "other").Set("same", 1).Set("extra", true)
+
+	overwritten, err := base.MergeWithStrategy(other, MergeConflictOverwrite)
+	if err != nil {
+		t.Fatalf("Overwrite merge should not return error: %v", err)
+	}
+	if value, _ := overwritten.Get("shared"); value != "other" {
+		t.Errorf("Expected overwrite to take other value, got %v", value)
+	}
+
+	kept, err := base.MergeWithStrategy(other, MergeConflictKeepExisting)
+	if err != nil {
+		t.Fatalf("Keep-existing merge should not return error: %v", err)
+	}
+	if value, _ := kept.Get("shared"); value != "base" {
+		t.Errorf("Expected keep-existing to keep base value, got %v", value)
+	}
+	if !kept.Has("extra") {
+		t.Error("Keep-existing merge should add non-conflicting keys")
+	}
+
+	if _, err := base.MergeWithStrategy(other, MergeConflictError); err == nil {
+		t.Error("Error strategy should return error on conflict")
+	}
+
+	// Equal values are not conflicts
+	if _, err := base.MergeWithStrategy(NewContext("equal", ContextScopeWorkflow, "Equal").Set("same", 1), MergeConflictError); err != nil {
+		t.Errorf("Equal values should not conflict: %v", err)
+	}
+
+	if _, err := base.MergeWithStrategy(other, "newest"); err == nil {
+		t.Error("Unsupported strategy should return error")
+	}
+
+	// The original context is unchanged
+	if value, _ := base.Get("shared"); value != "base" {
+		t.Errorf("Merge should not modify the original context, got %v", value)
+	}
+}
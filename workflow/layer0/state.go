@@ -55,6 +55,7 @@ type State struct {
 	Status   StateStatus   `json:"status"`
 	Metadata StateMetadata `json:"metadata"`
 	Data     interface{}   `json:"data"`
+	Timeout  time.Duration `json:"timeout,omitempty"` // Maximum dwell time; zero means no limit
 }
 
 // StateInterface defines the contract for state operations
@@ -64,8 +65,10 @@ type StateInterface interface {
 	GetStatus() StateStatus
 	GetMetadata() StateMetadata
 	GetData() interface{}
+	GetTimeout() time.Duration
 	SetStatus(status StateStatus) State
 	SetData(data interface{}) State
+	SetTimeout(timeout time.Duration) State
 	IsActive() bool
 	IsFinal() bool
 	IsError() bool
@@ -117,6 +120,11 @@ func (s State) GetData() interface{} {
 	return s.Data
 }
 
+// GetTimeout returns the maximum time an instance may stay in the state
+func (s State) GetTimeout() time.Duration {
+	return s.Timeout
+}
+
 // SetStatus creates a new state with updated status (immutable)
 func (s State) SetStatus(status StateStatus) State {
 	newState := s.Clone()
@@ -133,6 +141,14 @@ func (s State) SetData(data interface{}) State {
 	return newState
 }
 
+// SetTimeout creates a new state with an updated maximum dwell time (immutable)
+func (s State) SetTimeout(timeout time.Duration) State {
+	newState := s.Clone()
+	newState.Timeout = timeout
+	newState.Metadata.UpdatedAt = time.Now()
+	return newState
+}
+
 // IsActive checks if the state is active
 func (s State) IsActive() bool {
 	return s.Status == StateStatusActive
@@ -170,6 +186,7 @@ func (s State) Clone() State {
 		Status:   s.Status,
 		Metadata: metadata,
 		Data:     s.Data, // Shallow copy for data - caller responsible for deep copy if needed
+		Timeout:  s.Timeout,
 	}
 }
 
@@ -191,5 +208,9 @@ func (s State) Validate() error {
 		return fmt.Errorf("state name cannot be empty")
 	}
 
+	if s.Timeout < 0 {
+		return fmt.Errorf("state timeout cannot be negative")
+	}
+
 	return nil
 }
@@ -157,3 +157,23 @@ func TestStateValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestStateTimeout(t *testing.T) {
+	state := NewState("review", StateTypeIntermediate, "Review")
+	if state.GetTimeout() != 0 {
+		t.Errorf("Expected no timeout by default, got %v", state.GetTimeout())
+	}
+
+	timed := state.SetTimeout(time.Hour)
+	if timed.GetTimeout() != time.Hour || timed.Clone().GetTimeout() != time.Hour {
+		t.Error("SetTimeout and Clone should carry the timeout")
+	}
+
+	if state.GetTimeout() != 0 {
+		t.Error("SetTimeout should not modify the original state")
+	}
+
+	if err := state.SetTimeout(-time.Second).Validate(); err == nil {
+		t.Error("State with negative timeout should return error")
+	}
+}
@@ -20,6 +20,8 @@ const (
 	TransitionTypeConditional TransitionType = "conditional"
 	// TransitionTypeCompensation represents a transition used for error recovery and rollback
 	TransitionTypeCompensation TransitionType = "compensation"
+	// TransitionTypeTimeout represents a transition taken when its source state's timeout expires
+	TransitionTypeTimeout TransitionType = "timeout"
 )
 
 // TransitionStatus represents the current status of a transition
@@ -42,28 +44,57 @@ const (
 	TransitionStatusSkipped TransitionStatus = "skipped"
 )
 
+// ActionExecutionMode defines how a transition runs its actions
+type ActionExecutionMode string
+
+const (
+	// ActionExecutionSequential runs actions one after another in order
+	ActionExecutionSequential ActionExecutionMode = "sequential"
+	// ActionExecutionParallel runs independent actions concurrently
+	ActionExecutionParallel ActionExecutionMode = "parallel"
+)
+
+// TransitionTagIntentionalLoop marks a transition as part of a deliberate unconditional loop
+const TransitionTagIntentionalLoop = "intentional-loop"
+
+// TransitionRecoveryPolicy defines how a transition recovers when its actions keep failing.
+// The transition is retried, and once the retries are exhausted the compensation
+// actions run before the instance is moved to the OnExhausted state.
+type TransitionRecoveryPolicy struct {
+	Retry        int      `json:"retry"`                  // Attempts after the first failed one
+	Compensation []string `json:"compensation,omitempty"` // Actions run once the retries are exhausted
+	OnExhausted  StateID  `json:"on_exhausted,omitempty"` // State entered after compensating; empty leaves the instance where it is
+}
+
 // TransitionMetadata contains metadata about a transition
 type TransitionMetadata struct {
 	Name        string            `json:"name"`
 	Description string            `json:"description"`
 	Tags        []string          `json:"tags"`
 	Properties  map[string]string `json:"properties"`
-	CreatedAt   time.Time         `json:"created_at"`
-	UpdatedAt   time.Time         `json:"updated_at"`
+	// Annotations carry structured information for tooling, such as UI labels or SLA hints
+	Annotations map[string]interface{} `json:"annotations,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
 }
 
 // Transition represents an atomic transition in the workflow system
 type Transition struct {
-	ID          TransitionID       `json:"id"`
-	Type        TransitionType     `json:"type"`
-	Status      TransitionStatus   `json:"status"`
-	FromStateID StateID            `json:"from_state_id"`
-	ToStateID   StateID            `json:"to_state_id"`
-	Metadata    TransitionMetadata `json:"metadata"`
-	Conditions  []string           `json:"conditions"` // References to condition IDs
-	Actions     []string           `json:"actions"`    // References to work IDs
-	Priority    int                `json:"priority"`
-	Data        interface{}        `json:"data"`
+	ID               TransitionID              `json:"id"`
+	Type             TransitionType            `json:"type"`
+	Status           TransitionStatus          `json:"status"`
+	FromStateID      StateID                   `json:"from_state_id"`
+	ToStateID        StateID                   `json:"to_state_id"`
+	Metadata         TransitionMetadata        `json:"metadata"`
+	Conditions       []string                  `json:"conditions"` // References to condition IDs
+	Operator         ConditionOperator         `json:"operator,omitempty"`
+	Actions          []string                  `json:"actions"` // References to work IDs
+	ExecutionMode    ActionExecutionMode       `json:"execution_mode,omitempty"`
+	ConflictStrategy MergeConflictStrategy     `json:"conflict_strategy,omitempty"` // Resolves parallel output conflicts
+	Priority         int                       `json:"priority"`
+	Weight           *float64                  `json:"weight,omitempty"` // Relative chance of selection among satisfiable weighted transitions
+	RecoveryPolicy   *TransitionRecoveryPolicy `json:"recovery_policy,omitempty"`
+	Data             interface{}               `json:"data"`
 }
 
 // TransitionInterface defines the contract for transition operations
@@ -75,13 +106,30 @@ type TransitionInterface interface {
 	GetToStateID() StateID
 	GetMetadata() TransitionMetadata
 	GetConditions() []string
+	GetOperator() ConditionOperator
 	GetActions() []string
+	GetExecutionMode() ActionExecutionMode
+	GetConflictStrategy() MergeConflictStrategy
 	GetPriority() int
+	GetWeight() (float64, bool)
+	GetRecoveryPolicy() (TransitionRecoveryPolicy, bool)
 	GetData() interface{}
+	GetAnnotation(key string) (interface{}, bool)
+	GetAnnotations() map[string]interface{}
 	SetStatus(status TransitionStatus) Transition
 	SetData(data interface{}) Transition
 	AddCondition(conditionID string) Transition
+	SetOperator(operator ConditionOperator) Transition
 	AddAction(actionID string) Transition
+	SetExecutionMode(mode ActionExecutionMode) Transition
+	SetConflictStrategy(strategy MergeConflictStrategy) Transition
+	SetWeight(weight float64) Transition
+	SetRecoveryPolicy(policy TransitionRecoveryPolicy) Transition
+	AddTag(tag string) Transition
+	SetAnnotation(key string, value interface{}) Transition
+	MarkIntentionalLoop() Transition
+	IsIntentionalLoop() bool
+	IsUnconditional() bool
 	IsReady() bool
 	IsCompleted() bool
 	IsFailed() bool
@@ -103,10 +151,12 @@ func NewTransition(id TransitionID, transitionType TransitionType, fromStateID,
 			Description: "",
 			Tags:        []string{},
 			Properties:  make(map[string]string),
+			Annotations: make(map[string]interface{}),
 			CreatedAt:   now,
 			UpdatedAt:   now,
 		},
 		Conditions: []string{},
+		Operator:   ConditionOperatorAnd,
 		Actions:    []string{},
 		Priority:   0,
 		Data:       nil,
@@ -148,16 +198,59 @@ func (t Transition) GetConditions() []string {
 	return t.Conditions
 }
 
+// GetOperator returns the operator combining the transition's conditions.
+// Transitions without an operator combine their conditions with AND.
+func (t Transition) GetOperator() ConditionOperator {
+	if t.Operator == "" {
+		return ConditionOperatorAnd
+	}
+	return t.Operator
+}
+
 // GetActions returns the action IDs
 func (t Transition) GetActions() []string {
 	return t.Actions
 }
 
+// GetExecutionMode returns how the transition runs its actions.
+// Transitions without a mode run their actions sequentially.
+func (t Transition) GetExecutionMode() ActionExecutionMode {
+	if t.ExecutionMode == "" {
+		return ActionExecutionSequential
+	}
+	return t.ExecutionMode
+}
+
+// GetConflictStrategy returns how conflicting parallel action outputs are merged.
+// Transitions without a strategy let later actions overwrite earlier ones.
+func (t Transition) GetConflictStrategy() MergeConflictStrategy {
+	if t.ConflictStrategy == "" {
+		return MergeConflictOverwrite
+	}
+	return t.ConflictStrategy
+}
+
 // GetPriority returns the transition priority
 func (t Transition) GetPriority() int {
 	return t.Priority
 }
 
+// GetWeight returns the transition weight and whether one is set
+func (t Transition) GetWeight() (float64, bool) {
+	if t.Weight == nil {
+		return 0, false
+	}
+	return *t.Weight, true
+}
+
+// GetRecoveryPolicy returns the transition's recovery policy and whether one is set
+func (t Transition) GetRecoveryPolicy() (TransitionRecoveryPolicy, bool) {
+	if t.RecoveryPolicy == nil {
+		return TransitionRecoveryPolicy{}, false
+	}
+	return t.RecoveryPolicy.clone(), true
+}
+
 // GetData returns the transition data
 func (t Transition) GetData() interface{} {
 	return t.Data
@@ -187,6 +280,14 @@ func (t Transition) AddCondition(conditionID string) Transition {
 	return newTransition
 }
 
+// SetOperator creates a new transition with an updated condition operator (immutable)
+func (t Transition) SetOperator(operator ConditionOperator) Transition {
+	newTransition := t.Clone()
+	newTransition.Operator = operator
+	newTransition.Metadata.UpdatedAt = time.Now()
+	return newTransition
+}
+
 // AddAction creates a new transition with an additional action (immutable)
 func (t Transition) AddAction(actionID string) Transition {
 	newTransition := t.Clone()
@@ -195,6 +296,98 @@ func (t Transition) AddAction(actionID string) Transition {
 	return newTransition
 }
 
+// SetExecutionMode creates a new transition with an updated action execution mode (immutable)
+func (t Transition) SetExecutionMode(mode ActionExecutionMode) Transition {
+	newTransition := t.Clone()
+	newTransition.ExecutionMode = mode
+	newTransition.Metadata.UpdatedAt = time.Now()
+	return newTransition
+}
+
+// SetConflictStrategy creates a new transition with an updated output conflict strategy (immutable)
+func (t Transition) SetConflictStrategy(strategy MergeConflictStrategy) Transition {
+	newTransition := t.Clone()
+	newTransition.ConflictStrategy = strategy
+	newTransition.Metadata.UpdatedAt = time.Now()
+	return newTransition
+}
+
+// SetWeight creates a new transition with an updated selection weight (immutable)
+func (t Transition) SetWeight(weight float64) Transition {
+	newTransition := t.Clone()
+	newTransition.Weight = &weight
+	newTransition.Metadata.UpdatedAt = time.Now()
+	return newTransition
+}
+
+// SetRecoveryPolicy creates a new transition with an updated recovery policy (immutable)
+func (t Transition) SetRecoveryPolicy(policy TransitionRecoveryPolicy) Transition {
+	newTransition := t.Clone()
+	policy = policy.clone()
+	newTransition.RecoveryPolicy = &policy
+	newTransition.Metadata.UpdatedAt = time.Now()
+	return newTransition
+}
+
+// AddTag creates a new transition with an additional tag (immutable)
+func (t Transition) AddTag(tag string) Transition {
+	newTransition := t.Clone()
+	newTransition.Metadata.Tags = append(newTransition.Metadata.Tags, tag)
+	newTransition.Metadata.UpdatedAt = time.Now()
+	return newTransition
+}
+
+// SetAnnotation creates a new transition with an annotation set (immutable)
+func (t Transition) SetAnnotation(key string, value interface{}) Transition {
+	newTransition := t.Clone()
+	newTransition.Metadata.Annotations[key] = value
+	newTransition.Metadata.UpdatedAt = time.Now()
+	return newTransition
+}
+
+// GetAnnotation returns an annotation and whether it is set
+func (t Transition) GetAnnotation(key string) (interface{}, bool) {
+	value, exists := t.Metadata.Annotations[key]
+	return value, exists
+}
+
+// GetAnnotations returns a copy of the transition annotations
+func (t Transition) GetAnnotations() map[string]interface{} {
+	annotations := make(map[string]interface{}, len(t.Metadata.Annotations))
+	for k, v := range t.Metadata.Annotations {
+		annotations[k] = v
+	}
+	return annotations
+}
+
+// MarkIntentionalLoop creates a new transition tagged as part of a deliberate loop (immutable)
+func (t Transition) MarkIntentionalLoop() Transition {
+	if t.IsIntentionalLoop() {
+		return t.Clone()
+	}
+
+	newTransition := t.Clone()
+	newTransition.Metadata.Tags = append(newTransition.Metadata.Tags, TransitionTagIntentionalLoop)
+	newTransition.Metadata.UpdatedAt = time.Now()
+	return newTransition
+}
+
+// IsIntentionalLoop checks if the transition is tagged as part of a deliberate loop
+func (t Transition) IsIntentionalLoop() bool {
+	for _, tag := range t.Metadata.Tags {
+		if tag == TransitionTagIntentionalLoop {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnconditional checks if the transition always fires and performs no work
+// that could change the context
+func (t Transition) IsUnconditional() bool {
+	return len(t.Conditions) == 0 && len(t.Actions) == 0
+}
+
 // IsReady checks if the transition is ready for execution
 func (t Transition) IsReady() bool {
 	return t.Status == TransitionStatusReady
@@ -217,6 +410,7 @@ func (t Transition) Clone() Transition {
 		Description: t.Metadata.Description,
 		Tags:        make([]string, len(t.Metadata.Tags)),
 		Properties:  make(map[string]string),
+		Annotations: make(map[string]interface{}, len(t.Metadata.Annotations)),
 		CreatedAt:   t.Metadata.CreatedAt,
 		UpdatedAt:   t.Metadata.UpdatedAt,
 	}
@@ -225,6 +419,9 @@ func (t Transition) Clone() Transition {
 	for k, v := range t.Metadata.Properties {
 		metadata.Properties[k] = v
 	}
+	for k, v := range t.Metadata.Annotations {
+		metadata.Annotations[k] = v // Shallow copy for annotation values
+	}
 
 	conditions := make([]string, len(t.Conditions))
 	copy(conditions, t.Conditions)
@@ -232,17 +429,34 @@ func (t Transition) Clone() Transition {
 	actions := make([]string, len(t.Actions))
 	copy(actions, t.Actions)
 
+	var weight *float64
+	if t.Weight != nil {
+		value := *t.Weight
+		weight = &value
+	}
+
+	var recoveryPolicy *TransitionRecoveryPolicy
+	if t.RecoveryPolicy != nil {
+		policy := t.RecoveryPolicy.clone()
+		recoveryPolicy = &policy
+	}
+
 	return Transition{
-		ID:          t.ID,
-		Type:        t.Type,
-		Status:      t.Status,
-		FromStateID: t.FromStateID,
-		ToStateID:   t.ToStateID,
-		Metadata:    metadata,
-		Conditions:  conditions,
-		Actions:     actions,
-		Priority:    t.Priority,
-		Data:        t.Data, // Shallow copy for data
+		ID:               t.ID,
+		Type:             t.Type,
+		Status:           t.Status,
+		FromStateID:      t.FromStateID,
+		ToStateID:        t.ToStateID,
+		Metadata:         metadata,
+		Conditions:       conditions,
+		Operator:         t.Operator,
+		Actions:          actions,
+		ExecutionMode:    t.ExecutionMode,
+		ConflictStrategy: t.ConflictStrategy,
+		Priority:         t.Priority,
+		Weight:           weight,
+		RecoveryPolicy:   recoveryPolicy,
+		Data:             t.Data, // Shallow copy for data
 	}
 }
 
@@ -272,5 +486,52 @@ func (t Transition) Validate() error {
 		return fmt.Errorf("transition name cannot be empty")
 	}
 
+	switch t.Operator {
+	case "", ConditionOperatorAnd, ConditionOperatorOr:
+	case ConditionOperatorNot:
+		if len(t.Conditions) != 1 {
+			return fmt.Errorf("NOT operator requires exactly one condition, got %d", len(t.Conditions))
+		}
+	default:
+		return fmt.Errorf("unsupported condition operator: %s", t.Operator)
+	}
+
+	switch t.ExecutionMode {
+	case "", ActionExecutionSequential:
+	case ActionExecutionParallel:
+		// Parallel actions are tracked by ID, so each may only appear once
+		seen := make(map[string]bool)
+		for _, actionID := range t.Actions {
+			if seen[actionID] {
+				return fmt.Errorf("parallel action %s is listed more than once", actionID)
+			}
+			seen[actionID] = true
+		}
+	default:
+		return fmt.Errorf("unsupported action execution mode: %s", t.ExecutionMode)
+	}
+
+	switch t.ConflictStrategy {
+	case "", MergeConflictOverwrite, MergeConflictKeepExisting, MergeConflictError:
+	default:
+		return fmt.Errorf("unsupported merge conflict strategy: %s", t.ConflictStrategy)
+	}
+
+	if t.Weight != nil && *t.Weight < 0 {
+		return fmt.Errorf("transition weight cannot be negative")
+	}
+
+	if t.RecoveryPolicy != nil && t.RecoveryPolicy.Retry < 0 {
+		return fmt.Errorf("transition recovery retry count cannot be negative")
+	}
+
 	return nil
 }
+
+// clone creates a deep copy of the recovery policy
+func (policy TransitionRecoveryPolicy) clone() TransitionRecoveryPolicy {
+	compensation := make([]string, len(policy.Compensation))
+	copy(compensation, policy.Compensation)
+	policy.Compensation = compensation
+	return policy
+}
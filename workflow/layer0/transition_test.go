@@ -1,6 +1,7 @@
 package layer0
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 )
@@ -111,6 +112,57 @@ func TestTransitionAddAction(t *testing.T) {
 	}
 }
 
+func TestTransitionSetWeight(t *testing.T) {
+	transition := NewTransition("test", TransitionTypeAutomatic, "from", "to", "Test")
+
+	weighted := transition.SetWeight(0.25)
+	if weight, ok := weighted.GetWeight(); !ok || weight != 0.25 {
+		t.Errorf("Expected weight 0.25, got %v (set %v)", weight, ok)
+	}
+
+	// Original transition should remain unchanged (immutability)
+	if _, ok := transition.GetWeight(); ok {
+		t.Error("Original transition should remain unweighted")
+	}
+
+	// Clones do not share the weight
+	clone := weighted.Clone()
+	*clone.Weight = 1
+	if weight, _ := weighted.GetWeight(); weight != 0.25 {
+		t.Errorf("Expected the clone's weight to be independent, got %v", weight)
+	}
+
+	if err := transition.SetWeight(-1).Validate(); err == nil {
+		t.Error("Expected a negative weight to be invalid")
+	}
+}
+
+func TestTransitionSetRecoveryPolicy(t *testing.T) {
+	transition := NewTransition("test", TransitionTypeAutomatic, "from", "to", "Test")
+
+	recovering := transition.SetRecoveryPolicy(TransitionRecoveryPolicy{Retry: 2, Compensation: []string{"refund"}, OnExhausted: "failed"})
+	policy, ok := recovering.GetRecoveryPolicy()
+	if !ok || policy.Retry != 2 || policy.OnExhausted != "failed" || len(policy.Compensation) != 1 {
+		t.Errorf("Expected the recovery policy to be set, got %+v (set %v)", policy, ok)
+	}
+
+	// Original transition should remain unchanged (immutability)
+	if _, ok := transition.GetRecoveryPolicy(); ok {
+		t.Error("Original transition should have no recovery policy")
+	}
+
+	// Clones do not share the compensation actions
+	clone := recovering.Clone()
+	clone.RecoveryPolicy.Compensation[0] = "release"
+	if policy, _ := recovering.GetRecoveryPolicy(); policy.Compensation[0] != "refund" {
+		t.Errorf("Expected the clone's compensation to be independent, got %v", policy.Compensation)
+	}
+
+	if err := transition.SetRecoveryPolicy(TransitionRecoveryPolicy{Retry: -1}).Validate(); err == nil {
+		t.Error("Expected a negative retry count to be invalid")
+	}
+}
+
 func TestTransitionIsReady(t *testing.T) {
 	transition := NewTransition("test", TransitionTypeAutomatic, "from", "to", "Test")
 
@@ -217,3 +269,156 @@ func TestTransitionValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestTransitionIntentionalLoop(t *testing.T) {
+	transition := NewTransition("loop", TransitionTypeAutomatic, "state1", "state1", "Loop")
+
+	if !transition.IsUnconditional() {
+		t.Error("Transition without conditions or actions should be unconditional")
+	}
+
+	if transition.AddCondition("guard").IsUnconditional() || transition.AddAction("work").IsUnconditional() {
+		t.Error("Transition with conditions or actions should not be unconditional")
+	}
+
+	if transition.IsIntentionalLoop() {
+		t.Error("New transition should not be an intentional loop")
+	}
+
+	marked := transition.MarkIntentionalLoop().MarkIntentionalLoop()
+	if !marked.IsIntentionalLoop() {
+		t.Error("Marked transition should be an intentional loop")
+	}
+
+	if len(marked.GetMetadata().Tags) != 1 {
+		t.Errorf("Marking twice should add the tag once, got %d tags", len(marked.GetMetadata().Tags))
+	}
+
+	// Original should be unchanged (immutability)
+	if transition.IsIntentionalLoop() {
+		t.Error("Original transition should not be modified")
+	}
+}
+
+func TestTransitionOperator(t *testing.T) {
+	transition := NewTransition("test", TransitionTypeConditional, "state1", "state2", "Test")
+
+	if transition.GetOperator() != ConditionOperatorAnd {
+		t.Errorf("Expected default operator and, got %s", transition.GetOperator())
+	}
+
+	// Transitions without an operator default to AND
+	transition.Operator = ""
+	if transition.GetOperator() != ConditionOperatorAnd {
+		t.Errorf("Expected empty operator to default to and, got %s", transition.GetOperator())
+	}
+
+	orTransition := transition.SetOperator(ConditionOperatorOr)
+	if orTransition.GetOperator() != ConditionOperatorOr {
+		t.Errorf("Expected operator or, got %s", orTransition.GetOperator())
+	}
+
+	if orTransition.Clone().GetOperator() != ConditionOperatorOr {
+		t.Error("Clone should preserve the operator")
+	}
+
+	// NOT requires exactly one condition
+	notTransition := transition.AddCondition("a").AddCondition("b").SetOperator(ConditionOperatorNot)
+	if err := notTransition.Validate(); err == nil {
+		t.Error("NOT transition with two conditions should return error")
+	}
+
+	if err := transition.SetOperator("xor").Validate(); err == nil {
+		t.Error("Transition with unsupported operator should return error")
+	}
+}
+
+func TestTransitionExecutionMode(t *testing.T) {
+	transition := NewTransition("test", TransitionTypeAutomatic, "state1", "state2", "Test").
+		AddAction("a").
+		AddAction("b")
+
+	if transition.GetExecutionMode() != ActionExecutionSequential {
+		t.Errorf("Expected default sequential mode, got %s", transition.GetExecutionMode())
+	}
+
+	if transition.GetConflictStrategy() != MergeConflictOverwrite {
+		t.Errorf("Expected default overwrite strategy, got %s", transition.GetConflictStrategy())
+	}
+
+	parallel := transition.SetExecutionMode(ActionExecutionParallel).SetConflictStrategy(MergeConflictError)
+	if parallel.Clone().GetExecutionMode() != ActionExecutionParallel || parallel.Clone().GetConflictStrategy() != MergeConflictError {
+		t.Error("Clone should preserve execution mode and conflict strategy")
+	}
+
+	if err := parallel.Validate(); err != nil {
+		t.Errorf("Parallel transition should be valid: %v", err)
+	}
+
+	if err := parallel.AddAction("a").Validate(); err == nil {
+		t.Error("Parallel transition with a duplicate action should return error")
+	}
+
+	if err := transition.SetExecutionMode("batched").Validate(); err == nil {
+		t.Error("Transition with unsupported execution mode should return error")
+	}
+
+	if err := transition.SetConflictStrategy("newest").Validate(); err == nil {
+		t.Error("Transition with unsupported conflict strategy should return error")
+	}
+}
+
+func TestTransitionAnnotations(t *testing.T) {
+	original := NewTransition("test", TransitionTypeAutomatic, "from", "to", "Test").
+		AddTag("billing").
+		SetAnnotation("ui_label", "Approve").
+		SetAnnotation("sla_seconds", 30)
+
+	if value, exists := original.GetAnnotation("ui_label"); !exists || value != "Approve" {
+		t.Errorf("Expected ui_label annotation to be Approve, got %v", value)
+	}
+	if _, exists := original.GetAnnotation("missing"); exists {
+		t.Error("A missing annotation should not be reported as set")
+	}
+
+	// Setting an annotation does not modify the source transition
+	updated := original.SetAnnotation("ui_label", "Reject")
+	if value, _ := original.GetAnnotation("ui_label"); value != "Approve" {
+		t.Errorf("Original annotation should be unchanged, got %v", value)
+	}
+	if value, _ := updated.GetAnnotation("ui_label"); value != "Reject" {
+		t.Errorf("Expected updated annotation to be Reject, got %v", value)
+	}
+
+	cloned := original.Clone()
+	cloned.Metadata.Annotations["ui_label"] = "modified"
+	if value, _ := original.GetAnnotation("ui_label"); value != "Approve" {
+		t.Error("Original annotations should not be affected by clone modification")
+	}
+
+	annotations := original.GetAnnotations()
+	annotations["ui_label"] = "modified"
+	if value, _ := original.GetAnnotation("ui_label"); value != "Approve" {
+		t.Error("GetAnnotations should return a copy")
+	}
+
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("Marshal should not return error: %v", err)
+	}
+
+	var decoded Transition
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal should not return error: %v", err)
+	}
+
+	if value, _ := decoded.GetAnnotation("ui_label"); value != "Approve" {
+		t.Errorf("Expected ui_label to round-trip, got %v", value)
+	}
+	if value, _ := decoded.GetAnnotation("sla_seconds"); value != float64(30) {
+		t.Errorf("Expected sla_seconds to round-trip, got %v", value)
+	}
+	if len(decoded.Metadata.Tags) != 1 || decoded.Metadata.Tags[0] != "billing" {
+		t.Errorf("Expected tags to round-trip, got %v", decoded.Metadata.Tags)
+	}
+}
@@ -17,6 +17,13 @@ const (
 	WorkTypeScript       WorkType = "script"
 	WorkTypeHuman        WorkType = "human"
 	WorkTypeCompensation WorkType = "compensation"
+	WorkTypeResource     WorkType = "resource"
+	WorkTypeWait         WorkType = "wait"
+	WorkTypeNoop         WorkType = "noop"
+	WorkTypeAsync        WorkType = "async"
+	WorkTypeDecision     WorkType = "decision"
+	WorkTypeForEach      WorkType = "for_each"
+	WorkTypeHumanTask    WorkType = "human_task"
 )
 
 // WorkStatus represents the current status of work
@@ -37,6 +44,8 @@ const (
 	WorkStatusCancelled WorkStatus = "cancelled"
 	// WorkStatusRetrying indicates the work is being retried after a failure
 	WorkStatusRetrying WorkStatus = "retrying"
+	// WorkStatusBlocked indicates the work is waiting for an external completion or signal
+	WorkStatusBlocked WorkStatus = "blocked"
 )
 
 // WorkPriority defines the priority level of work
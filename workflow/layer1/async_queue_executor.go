@@ -0,0 +1,72 @@
+package layer1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// AsyncJob describes work handed to an external worker for asynchronous completion
+type AsyncJob struct {
+	InstanceID    string                 `json:"instance_id"`
+	CorrelationID string                 `json:"correlation_id,omitempty"`
+	WorkID        layer0.WorkID          `json:"work_id"`
+	WorkType      layer0.WorkType        `json:"work_type"`
+	Parameters    map[string]interface{} `json:"parameters"`
+	Input         interface{}            `json:"input"`
+	EnqueuedAt    time.Time              `json:"enqueued_at"`
+}
+
+// AsyncWorkQueue defines the interface for the queue external workers consume jobs from
+type AsyncWorkQueue interface {
+	Enqueue(job AsyncJob) error
+}
+
+// AsyncQueueExecutor executes async work by posting it to an external queue.
+// It returns ErrWorkPending so the engine suspends until the worker reports back.
+type AsyncQueueExecutor struct {
+	queue AsyncWorkQueue
+}
+
+// NewAsyncQueueExecutor creates a new async queue executor
+func NewAsyncQueueExecutor(queue AsyncWorkQueue) *AsyncQueueExecutor {
+	return &AsyncQueueExecutor{
+		queue: queue,
+	}
+}
+
+// Execute enqueues the work and reports it as pending
+func (aqe *AsyncQueueExecutor) Execute(work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	return aqe.ExecuteWithContext(context.Background(), work, workContext)
+}
+
+// ExecuteWithContext enqueues the work with the correlation ID carried by ctx
+func (aqe *AsyncQueueExecutor) ExecuteWithContext(ctx context.Context, work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	job := AsyncJob{
+		InstanceID:    work.GetMetadata().Properties[WorkPropertyInstanceID],
+		CorrelationID: CorrelationIDFromContext(ctx),
+		WorkID:        work.GetID(),
+		WorkType:      work.GetType(),
+		Parameters:    work.GetConfiguration().Parameters,
+		Input:         work.GetInput(),
+		EnqueuedAt:    time.Now(),
+	}
+
+	if err := aqe.queue.Enqueue(job); err != nil {
+		return nil, fmt.Errorf("failed to enqueue work %s: %w", work.GetID(), err)
+	}
+
+	return nil, ErrWorkPending
+}
+
+// CanExecute checks if the executor can execute the given work type
+func (aqe *AsyncQueueExecutor) CanExecute(workType layer0.WorkType) bool {
+	return workType == layer0.WorkTypeAsync
+}
+
+// GetSupportedTypes returns the supported work types
+func (aqe *AsyncQueueExecutor) GetSupportedTypes() []layer0.WorkType {
+	return []layer0.WorkType{layer0.WorkTypeAsync}
+}
@@ -0,0 +1,77 @@
+package layer1
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+type recordingAsyncWorkQueue struct {
+	jobs []AsyncJob
+	err  error
+}
+
+func (q *recordingAsyncWorkQueue) Enqueue(job AsyncJob) error {
+	if q.err != nil {
+		return q.err
+	}
+	q.jobs = append(q.jobs, job)
+	return nil
+}
+
+func TestAsyncQueueExecutor(t *testing.T) {
+	queue := &recordingAsyncWorkQueue{}
+	executor := NewAsyncQueueExecutor(queue)
+
+	if !executor.CanExecute(layer0.WorkTypeAsync) {
+		t.Error("AsyncQueueExecutor should execute async work")
+	}
+
+	if executor.CanExecute(layer0.WorkTypeTask) {
+		t.Error("AsyncQueueExecutor should not execute task work")
+	}
+
+	wec := NewWorkExecutionCore()
+	if err := wec.RegisterExecutor(layer0.WorkTypeAsync, executor); err != nil {
+		t.Fatalf("RegisterExecutor should not return error: %v", err)
+	}
+
+	work := layer0.NewWork("charge", layer0.WorkTypeAsync, "Charge")
+	work.Configuration.Parameters["amount"] = 10
+	work.Metadata.Properties[WorkPropertyInstanceID] = "instance-1"
+	context := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context")
+
+	result, err := wec.ExecuteWork(work, context)
+	if err != nil {
+		t.Fatalf("ExecuteWork should not return error: %v", err)
+	}
+
+	if result.Status != layer0.WorkStatusPending {
+		t.Errorf("Expected pending status, got %s", result.Status)
+	}
+
+	if result.CompletedAt != nil {
+		t.Error("Pending work should not have a completion time")
+	}
+
+	if len(queue.jobs) != 1 {
+		t.Fatalf("Expected 1 enqueued job, got %d", len(queue.jobs))
+	}
+
+	job := queue.jobs[0]
+	if job.InstanceID != "instance-1" || job.WorkID != "charge" || job.Parameters["amount"] != 10 {
+		t.Errorf("Unexpected job: %+v", job)
+	}
+
+	// Enqueue failures fail the work
+	queue.err = errors.New("queue unavailable")
+	result, err = wec.ExecuteWork(work, context)
+	if err != nil {
+		t.Fatalf("ExecuteWork should not return error: %v", err)
+	}
+
+	if result.Status != layer0.WorkStatusFailed {
+		t.Errorf("Expected failed status when enqueue fails, got %s", result.Status)
+	}
+}
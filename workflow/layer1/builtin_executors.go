@@ -0,0 +1,121 @@
+package layer1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// WaitExecutor executes wait work by sleeping for the duration configured in
+// the work's "duration" parameter. The duration may be a Go duration string
+// ("1m30s"), a time.Duration, or a number of seconds.
+type WaitExecutor struct{}
+
+// NewWaitExecutor creates a new wait executor
+func NewWaitExecutor() *WaitExecutor {
+	return &WaitExecutor{}
+}
+
+// Execute waits for the configured duration
+func (we *WaitExecutor) Execute(work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	return we.ExecuteWithContext(context.Background(), work, workContext)
+}
+
+// ExecuteWithContext waits for the configured duration or until the context is done
+func (we *WaitExecutor) ExecuteWithContext(ctx context.Context, work layer0.Work, context *layer0.Context) (interface{}, error) {
+	duration, err := waitDuration(work)
+	if err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return map[string]interface{}{"waited": duration.String()}, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("wait interrupted: %w", ctx.Err())
+	}
+}
+
+// CanExecute checks if the executor can execute the given work type
+func (we *WaitExecutor) CanExecute(workType layer0.WorkType) bool {
+	return workType == layer0.WorkTypeWait
+}
+
+// GetSupportedTypes returns the supported work types
+func (we *WaitExecutor) GetSupportedTypes() []layer0.WorkType {
+	return []layer0.WorkType{layer0.WorkTypeWait}
+}
+
+// waitDuration reads the wait duration from the work parameters
+func waitDuration(work layer0.Work) (time.Duration, error) {
+	duration, exists, err := durationParameter(work, "duration", "wait")
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, fmt.Errorf("wait work %s requires a duration parameter", work.GetID())
+	}
+	return duration, nil
+}
+
+// durationParameter reads a non-negative duration parameter given as a Go duration
+// string, a time.Duration, or a number of seconds, reporting whether it is set.
+// kind names the work in error messages.
+func durationParameter(work layer0.Work, parameter, kind string) (time.Duration, bool, error) {
+	value, exists := work.GetConfiguration().Parameters[parameter]
+	if !exists {
+		return 0, false, nil
+	}
+
+	var duration time.Duration
+	switch v := value.(type) {
+	case time.Duration:
+		duration = v
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, true, fmt.Errorf("invalid %s %q for %s work %s: %w", parameter, v, kind, work.GetID(), err)
+		}
+		duration = parsed
+	case int:
+		duration = time.Duration(v) * time.Second
+	case float64:
+		duration = time.Duration(v * float64(time.Second))
+	default:
+		return 0, true, fmt.Errorf("unsupported %s type %T for %s work %s", parameter, value, kind, work.GetID())
+	}
+
+	if duration < 0 {
+		return 0, true, fmt.Errorf("%s for %s work %s cannot be negative", parameter, kind, work.GetID())
+	}
+
+	return duration, true, nil
+}
+
+// NoopExecutor executes noop work by succeeding immediately
+type NoopExecutor struct{}
+
+// NewNoopExecutor creates a new noop executor
+func NewNoopExecutor() *NoopExecutor {
+	return &NoopExecutor{}
+}
+
+// Execute returns immediately without output
+func (ne *NoopExecutor) Execute(work layer0.Work, context *layer0.Context) (interface{}, error) {
+	return nil, nil
+}
+
+// CanExecute checks if the executor can execute the given work type
+func (ne *NoopExecutor) CanExecute(workType layer0.WorkType) bool {
+	return workType == layer0.WorkTypeNoop
+}
+
+// GetSupportedTypes returns the supported work types
+func (ne *NoopExecutor) GetSupportedTypes() []layer0.WorkType {
+	return []layer0.WorkType{layer0.WorkTypeNoop}
+}
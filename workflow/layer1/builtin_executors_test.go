@@ -0,0 +1,115 @@
+package layer1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+func TestWaitExecutor(t *testing.T) {
+	wec := NewWorkExecutionCore()
+	wec.RegisterExecutor(layer0.WorkTypeWait, NewWaitExecutor())
+
+	work := layer0.NewWork("wait-work", layer0.WorkTypeWait, "Wait Work")
+	work.Configuration.Parameters["duration"] = "20ms"
+	workContext := layer0.NewContext("test-context", layer0.ContextScopeWork, "Test Context")
+
+	result, err := wec.ExecuteWork(work, workContext)
+	if err != nil {
+		t.Errorf("ExecuteWork should not return error: %v", err)
+	}
+
+	if result.Status != layer0.WorkStatusCompleted {
+		t.Errorf("Expected status %s, got %s", layer0.WorkStatusCompleted, result.Status)
+	}
+
+	if result.Duration < 20*time.Millisecond {
+		t.Errorf("Expected wait of at least 20ms, got %s", result.Duration)
+	}
+
+	// Numeric durations are interpreted as seconds
+	numericWork := layer0.NewWork("numeric-wait", layer0.WorkTypeWait, "Numeric Wait")
+	numericWork.Configuration.Parameters["duration"] = 0.01
+	if duration, err := waitDuration(numericWork); err != nil || duration != 10*time.Millisecond {
+		t.Errorf("Expected 10ms from numeric duration, got %s (%v)", duration, err)
+	}
+
+	// Missing and invalid durations fail
+	missingWork := layer0.NewWork("missing-wait", layer0.WorkTypeWait, "Missing Wait")
+	result, _ = wec.ExecuteWork(missingWork, workContext)
+	if result.Status != layer0.WorkStatusFailed {
+		t.Errorf("Expected status %s for missing duration, got %s", layer0.WorkStatusFailed, result.Status)
+	}
+
+	invalidWork := layer0.NewWork("invalid-wait", layer0.WorkTypeWait, "Invalid Wait")
+	invalidWork.Configuration.Parameters["duration"] = "soon"
+	result, _ = wec.ExecuteWork(invalidWork, workContext)
+	if result.Status != layer0.WorkStatusFailed {
+		t.Errorf("Expected status %s for invalid duration, got %s", layer0.WorkStatusFailed, result.Status)
+	}
+}
+
+func TestWaitExecutorCancellation(t *testing.T) {
+	wec := NewWorkExecutionCore()
+	wec.RegisterExecutor(layer0.WorkTypeWait, NewWaitExecutor())
+
+	work := layer0.NewWork("long-wait", layer0.WorkTypeWait, "Long Wait")
+	work.Configuration.Parameters["duration"] = time.Hour
+	workContext := layer0.NewContext("test-context", layer0.ContextScopeWork, "Test Context")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	result, err := wec.ExecuteWorkWithContext(ctx, work, workContext)
+	if err != nil {
+		t.Errorf("ExecuteWorkWithContext should not return error: %v", err)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Error("Wait should return promptly once the context is cancelled")
+	}
+
+	if result.Status != layer0.WorkStatusFailed {
+		t.Errorf("Expected status %s after cancellation, got %s", layer0.WorkStatusFailed, result.Status)
+	}
+}
+
+func TestNoopExecutor(t *testing.T) {
+	executor := NewNoopExecutor()
+	if !executor.CanExecute(layer0.WorkTypeNoop) {
+		t.Error("Executor should support WorkTypeNoop")
+	}
+
+	wec := NewWorkExecutionCore()
+	wec.RegisterExecutor(layer0.WorkTypeNoop, executor)
+
+	work := layer0.NewWork("noop-work", layer0.WorkTypeNoop, "Noop Work")
+	workContext := layer0.NewContext("test-context", layer0.ContextScopeWork, "Test Context")
+
+	result, err := wec.ExecuteWork(work, workContext)
+	if err != nil {
+		t.Errorf("ExecuteWork should not return error: %v", err)
+	}
+
+	if result.Status != layer0.WorkStatusCompleted {
+		t.Errorf("Expected status %s, got %s", layer0.WorkStatusCompleted, result.Status)
+	}
+
+	if result.Output != nil {
+		t.Errorf("Expected no output, got %v", result.Output)
+	}
+
+	if result.StartedAt.IsZero() || result.CompletedAt == nil {
+		t.Error("Execution timestamps should be populated")
+	}
+
+	if result.Duration > 10*time.Millisecond {
+		t.Errorf("Noop should return instantly, took %s", result.Duration)
+	}
+}
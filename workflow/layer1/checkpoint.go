@@ -0,0 +1,117 @@
+package layer1
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// CheckpointStore persists the progress of long-running work so a re-execution
+// after a crash can resume instead of starting over
+type CheckpointStore interface {
+	SaveCheckpoint(key string, checkpoint []byte) error
+	LoadCheckpoint(key string) ([]byte, bool, error)
+	DeleteCheckpoint(key string) error
+}
+
+// SaveCheckpointFunc records the progress of running work
+type SaveCheckpointFunc func(checkpoint []byte) error
+
+// CheckpointableExecutor is implemented by executors whose work can save progress.
+// The execution core passes the last saved checkpoint, or nil on a first run, and a
+// function that saves a new one. The checkpoint is discarded once the work completes.
+type CheckpointableExecutor interface {
+	WorkExecutor
+	ExecuteWithCheckpoint(ctx context.Context, work layer0.Work, context *layer0.Context, checkpoint []byte, save SaveCheckpointFunc) (interface{}, error)
+}
+
+// CheckpointKey returns the key work's checkpoints are stored under: the work ID,
+// scoped by the workflow instance when the work runs for one
+func CheckpointKey(work layer0.Work) string {
+	if instanceID := work.GetMetadata().Properties[WorkPropertyInstanceID]; instanceID != "" {
+		return fmt.Sprintf("%s/%s", instanceID, work.GetID())
+	}
+	return string(work.GetID())
+}
+
+// InMemoryCheckpointStore keeps checkpoints in memory
+type InMemoryCheckpointStore struct {
+	checkpoints map[string][]byte
+	mutex       sync.RWMutex
+}
+
+// NewInMemoryCheckpointStore creates a new empty checkpoint store
+func NewInMemoryCheckpointStore() *InMemoryCheckpointStore {
+	return &InMemoryCheckpointStore{
+		checkpoints: make(map[string][]byte),
+		mutex:       sync.RWMutex{},
+	}
+}
+
+// SaveCheckpoint replaces the checkpoint stored under key
+func (store *InMemoryCheckpointStore) SaveCheckpoint(key string, checkpoint []byte) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.checkpoints[key] = append([]byte(nil), checkpoint...)
+	return nil
+}
+
+// LoadCheckpoint returns the checkpoint stored under key, if any
+func (store *InMemoryCheckpointStore) LoadCheckpoint(key string) ([]byte, bool, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	checkpoint, exists := store.checkpoints[key]
+	if !exists {
+		return nil, false, nil
+	}
+	return append([]byte(nil), checkpoint...), true, nil
+}
+
+// DeleteCheckpoint removes the checkpoint stored under key
+func (store *InMemoryCheckpointStore) DeleteCheckpoint(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.checkpoints, key)
+	return nil
+}
+
+// SetCheckpointStore sets the store checkpointable executors save progress to.
+// Without a store they run from the beginning every time.
+func (wec *WorkExecutionCore) SetCheckpointStore(store CheckpointStore) {
+	wec.mutex.Lock()
+	defer wec.mutex.Unlock()
+
+	wec.checkpoints = store
+}
+
+// executeWithCheckpoint runs a checkpointable executor from the work's last checkpoint
+// and discards the checkpoint once the work completes
+func executeWithCheckpoint(ctx context.Context, store CheckpointStore, executor CheckpointableExecutor, work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	key := CheckpointKey(work)
+	checkpoint, _, err := store.LoadCheckpoint(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint for work %s: %w", work.GetID(), err)
+	}
+
+	save := func(checkpoint []byte) error {
+		if err := store.SaveCheckpoint(key, checkpoint); err != nil {
+			return fmt.Errorf("failed to save checkpoint for work %s: %w", work.GetID(), err)
+		}
+		return nil
+	}
+
+	output, err := executor.ExecuteWithCheckpoint(ctx, work, workContext, checkpoint, save)
+	if err != nil {
+		return output, err
+	}
+
+	if err := store.DeleteCheckpoint(key); err != nil {
+		return nil, fmt.Errorf("failed to delete checkpoint for work %s: %w", work.GetID(), err)
+	}
+	return output, nil
+}
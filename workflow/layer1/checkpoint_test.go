@@ -0,0 +1,88 @@
+package layer1
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// batchExecutor processes ten batches, saving the next batch as its checkpoint.
+// It crashes once after crashAfter batches when crashAfter is positive.
+type batchExecutor struct {
+	processed  []int
+	resumedAt  []byte
+	crashAfter int
+}
+
+func (be *batchExecutor) Execute(work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	return be.ExecuteWithCheckpoint(context.Background(), work, workContext, nil, func([]byte) error { return nil })
+}
+
+func (be *batchExecutor) ExecuteWithCheckpoint(ctx context.Context, work layer0.Work, workContext *layer0.Context, checkpoint []byte, save SaveCheckpointFunc) (interface{}, error) {
+	be.resumedAt = checkpoint
+	next := 0
+	if checkpoint != nil {
+		next, _ = strconv.Atoi(string(checkpoint))
+	}
+
+	for ; next < 10; next++ {
+		if be.crashAfter > 0 && len(be.processed) == be.crashAfter {
+			be.crashAfter = 0
+			return nil, fmt.Errorf("worker crashed")
+		}
+		be.processed = append(be.processed, next)
+		if err := save([]byte(strconv.Itoa(next + 1))); err != nil {
+			return nil, err
+		}
+	}
+	return len(be.processed), nil
+}
+
+func (be *batchExecutor) CanExecute(workType layer0.WorkType) bool {
+	return workType == layer0.WorkTypeTask
+}
+
+func (be *batchExecutor) GetSupportedTypes() []layer0.WorkType {
+	return []layer0.WorkType{layer0.WorkTypeTask}
+}
+
+func TestWorkExecutionCoreCheckpoints(t *testing.T) {
+	executor := &batchExecutor{crashAfter: 4}
+	store := NewInMemoryCheckpointStore()
+	core := NewWorkExecutionCore()
+	core.RegisterExecutor(layer0.WorkTypeTask, executor)
+	core.SetCheckpointStore(store)
+
+	work := layer0.NewWork("import", layer0.WorkTypeTask, "Import")
+	work.Metadata.Properties[WorkPropertyInstanceID] = "instance-1"
+	context := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Ctx")
+
+	result, _ := core.ExecuteWork(work, context)
+	if result.Status != layer0.WorkStatusFailed {
+		t.Fatalf("Expected the crashed run to fail, got %s", result.Status)
+	}
+	if checkpoint, found, _ := store.LoadCheckpoint("instance-1/import"); !found || string(checkpoint) != "4" {
+		t.Fatalf("Expected checkpoint 4 under the instance-scoped key, got %q (%v)", checkpoint, found)
+	}
+
+	// The re-execution resumes from the checkpoint rather than the first batch
+	result, err := core.ExecuteWork(work, context)
+	if err != nil || result.Status != layer0.WorkStatusCompleted {
+		t.Fatalf("Expected the resumed run to complete, got %s (%v): %s", result.Status, err, result.Error)
+	}
+	if string(executor.resumedAt) != "4" {
+		t.Errorf("Expected the executor to receive checkpoint 4, got %q", executor.resumedAt)
+	}
+	if expected := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}; !reflect.DeepEqual(executor.processed, expected) {
+		t.Errorf("Expected each batch to be processed once, got %v", executor.processed)
+	}
+
+	// A completed run discards its checkpoint
+	if _, found, _ := store.LoadCheckpoint("instance-1/import"); found {
+		t.Error("Expected the checkpoint to be deleted after completion")
+	}
+}
@@ -1,7 +1,9 @@
 package layer1
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -15,6 +17,13 @@ type ConditionEvaluator interface {
 	GetSupportedTypes() []layer0.ConditionType
 }
 
+// ContextAwareConditionEvaluator is implemented by evaluators that stop evaluating
+// when their context is cancelled
+type ContextAwareConditionEvaluator interface {
+	ConditionEvaluator
+	EvaluateWithContext(ctx context.Context, condition layer0.Condition, context *layer0.Context) (interface{}, error)
+}
+
 // ConditionEvaluationResult represents the result of condition evaluation
 type ConditionEvaluationResult struct {
 	ConditionID layer0.ConditionID     `json:"condition_id"`
@@ -25,9 +34,26 @@ type ConditionEvaluationResult struct {
 	Duration    time.Duration          `json:"duration"`
 }
 
+// ConditionResultProcessor maps an evaluator's raw result to whether the condition holds
+type ConditionResultProcessor func(condition layer0.Condition, result interface{}) (bool, error)
+
+// DefaultConditionResultProcessor treats nil and false as false, and any other
+// non-nil result as true
+func DefaultConditionResultProcessor(condition layer0.Condition, result interface{}) (bool, error) {
+	if result == nil {
+		return false, nil
+	}
+	if boolResult, ok := result.(bool); ok {
+		return boolResult, nil
+	}
+	// Non-boolean results are considered true if not nil/empty
+	return true, nil
+}
+
 // ConditionEvaluationCore provides core condition evaluation functionality
 type ConditionEvaluationCore struct {
 	evaluators        map[layer0.ConditionType]ConditionEvaluator
+	resultProcessor   ConditionResultProcessor
 	evaluationResults map[layer0.ConditionID]ConditionEvaluationResult
 	activeEvaluations map[layer0.ConditionID]layer0.Condition
 	mutex             sync.RWMutex
@@ -36,21 +62,27 @@ type ConditionEvaluationCore struct {
 // ConditionEvaluationCoreInterface defines the contract for condition evaluation operations
 type ConditionEvaluationCoreInterface interface {
 	RegisterEvaluator(conditionType layer0.ConditionType, evaluator ConditionEvaluator) error
+	RegisterEvaluatorForTypes(evaluator ConditionEvaluator, conditionTypes ...layer0.ConditionType) error
 	UnregisterEvaluator(conditionType layer0.ConditionType) error
 	GetEvaluator(conditionType layer0.ConditionType) (ConditionEvaluator, error)
 	GetSupportedConditionTypes() []layer0.ConditionType
+	SetResultProcessor(processor ConditionResultProcessor)
 	EvaluateCondition(condition layer0.Condition, context *layer0.Context) (ConditionEvaluationResult, error)
+	EvaluateConditionWithContext(ctx context.Context, condition layer0.Condition, context *layer0.Context) (ConditionEvaluationResult, error)
 	EvaluateConditions(conditions []layer0.Condition, context *layer0.Context, operator layer0.ConditionOperator) (bool, error)
+	EvaluateConditionsWithContext(ctx context.Context, conditions []layer0.Condition, context *layer0.Context, operator layer0.ConditionOperator) (bool, error)
 	GetEvaluationResult(conditionID layer0.ConditionID) (ConditionEvaluationResult, error)
 	GetAllEvaluationResults() []ConditionEvaluationResult
 	IsConditionEvaluating(conditionID layer0.ConditionID) bool
 	GetActiveEvaluations() []layer0.Condition
+	Stats() ConditionEvaluationStats
 }
 
 // NewConditionEvaluationCore creates a new condition evaluation core
 func NewConditionEvaluationCore() *ConditionEvaluationCore {
 	return &ConditionEvaluationCore{
 		evaluators:        make(map[layer0.ConditionType]ConditionEvaluator),
+		resultProcessor:   DefaultConditionResultProcessor,
 		evaluationResults: make(map[layer0.ConditionID]ConditionEvaluationResult),
 		activeEvaluations: make(map[layer0.ConditionID]layer0.Condition),
 		mutex:             sync.RWMutex{},
@@ -74,6 +106,41 @@ func (cec *ConditionEvaluationCore) RegisterEvaluator(conditionType layer0.Condi
 	return nil
 }
 
+// RegisterEvaluatorForTypes registers one evaluator for several condition types, or
+// for every type it supports when none are given. The registration is atomic: if
+// any type is already registered, none are.
+func (cec *ConditionEvaluationCore) RegisterEvaluatorForTypes(evaluator ConditionEvaluator, conditionTypes ...layer0.ConditionType) error {
+	if evaluator == nil {
+		return fmt.Errorf("evaluator cannot be nil")
+	}
+
+	if len(conditionTypes) == 0 {
+		conditionTypes = evaluator.GetSupportedTypes()
+	}
+	if len(conditionTypes) == 0 {
+		return fmt.Errorf("evaluator supports no condition types")
+	}
+
+	cec.mutex.Lock()
+	defer cec.mutex.Unlock()
+
+	seen := make(map[layer0.ConditionType]bool, len(conditionTypes))
+	for _, conditionType := range conditionTypes {
+		if _, exists := cec.evaluators[conditionType]; exists {
+			return fmt.Errorf("evaluator for condition type %s already registered", conditionType)
+		}
+		if seen[conditionType] {
+			return fmt.Errorf("condition type %s is listed more than once", conditionType)
+		}
+		seen[conditionType] = true
+	}
+
+	for _, conditionType := range conditionTypes {
+		cec.evaluators[conditionType] = evaluator
+	}
+	return nil
+}
+
 // UnregisterEvaluator unregisters a condition evaluator for a specific condition type
 func (cec *ConditionEvaluationCore) UnregisterEvaluator(conditionType layer0.ConditionType) error {
 	cec.mutex.Lock()
@@ -100,7 +167,7 @@ func (cec *ConditionEvaluationCore) GetEvaluator(conditionType layer0.ConditionT
 	return evaluator, nil
 }
 
-// GetSupportedConditionTypes returns all supported condition types
+// GetSupportedConditionTypes returns all supported condition types in sorted order
 func (cec *ConditionEvaluationCore) GetSupportedConditionTypes() []layer0.ConditionType {
 	cec.mutex.RLock()
 	defer cec.mutex.RUnlock()
@@ -109,12 +176,35 @@ func (cec *ConditionEvaluationCore) GetSupportedConditionTypes() []layer0.Condit
 	for conditionType := range cec.evaluators {
 		types = append(types, conditionType)
 	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
 
 	return types
 }
 
+// SetResultProcessor sets how evaluator results are mapped to condition statuses.
+// A nil processor restores DefaultConditionResultProcessor.
+func (cec *ConditionEvaluationCore) SetResultProcessor(processor ConditionResultProcessor) {
+	if processor == nil {
+		processor = DefaultConditionResultProcessor
+	}
+
+	cec.mutex.Lock()
+	defer cec.mutex.Unlock()
+
+	cec.resultProcessor = processor
+}
+
 // EvaluateCondition evaluates a single condition using the appropriate evaluator
-func (cec *ConditionEvaluationCore) EvaluateCondition(condition layer0.Condition, context *layer0.Context) (ConditionEvaluationResult, error) {
+func (cec *ConditionEvaluationCore) EvaluateCondition(condition layer0.Condition, workContext *layer0.Context) (ConditionEvaluationResult, error) {
+	return cec.EvaluateConditionWithContext(context.Background(), condition, workContext)
+}
+
+// EvaluateConditionWithContext evaluates a single condition, passing ctx to evaluators
+// that support cancellation. A condition whose context is cancelled is not evaluated,
+// and a cancelled evaluation is recorded as an error result.
+func (cec *ConditionEvaluationCore) EvaluateConditionWithContext(ctx context.Context, condition layer0.Condition, context *layer0.Context) (ConditionEvaluationResult, error) {
 	if err := condition.Validate(); err != nil {
 		return ConditionEvaluationResult{}, fmt.Errorf("invalid condition: %w", err)
 	}
@@ -137,11 +227,16 @@ func (cec *ConditionEvaluationCore) EvaluateCondition(condition layer0.Condition
 	// Mark condition as being evaluated
 	evaluatingCondition := condition.SetStatus(layer0.ConditionStatusEvaluating)
 	cec.activeEvaluations[condition.GetID()] = evaluatingCondition
+	processor := cec.resultProcessor
 	cec.mutex.Unlock()
 
-	// Evaluate condition
+	// Evaluate condition and map the result to whether it holds
 	startTime := time.Now()
-	result, err := evaluator.Evaluate(condition, context)
+	result, err := evaluateWithContext(ctx, evaluator, condition, context)
+	holds := false
+	if err == nil {
+		holds, err = processor(condition, result)
+	}
 	endTime := time.Now()
 	duration := endTime.Sub(startTime)
 
@@ -163,18 +258,10 @@ func (cec *ConditionEvaluationCore) EvaluateCondition(condition layer0.Condition
 		evalResult.Error = err.Error()
 	} else {
 		evalResult.Result = result
-		// Determine status based on result
-		if result == nil {
-			evalResult.Status = layer0.ConditionStatusFalse
-		} else if boolResult, ok := result.(bool); ok {
-			if boolResult {
-				evalResult.Status = layer0.ConditionStatusTrue
-			} else {
-				evalResult.Status = layer0.ConditionStatusFalse
-			}
-		} else {
-			// Non-boolean results are considered true if not nil/empty
+		if holds {
 			evalResult.Status = layer0.ConditionStatusTrue
+		} else {
+			evalResult.Status = layer0.ConditionStatusFalse
 		}
 	}
 
@@ -184,8 +271,25 @@ func (cec *ConditionEvaluationCore) EvaluateCondition(condition layer0.Condition
 	return evalResult, nil
 }
 
+// evaluateWithContext evaluates a condition, honoring ctx when the evaluator supports it
+func evaluateWithContext(ctx context.Context, evaluator ConditionEvaluator, condition layer0.Condition, context *layer0.Context) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("evaluation of condition %s was cancelled: %w", condition.GetID(), err)
+	}
+	if contextAware, ok := evaluator.(ContextAwareConditionEvaluator); ok {
+		return contextAware.EvaluateWithContext(ctx, condition, context)
+	}
+	return evaluator.Evaluate(condition, context)
+}
+
 // EvaluateConditions evaluates multiple conditions with a logical operator
-func (cec *ConditionEvaluationCore) EvaluateConditions(conditions []layer0.Condition, context *layer0.Context, operator layer0.ConditionOperator) (bool, error) {
+func (cec *ConditionEvaluationCore) EvaluateConditions(conditions []layer0.Condition, workContext *layer0.Context, operator layer0.ConditionOperator) (bool, error) {
+	return cec.EvaluateConditionsWithContext(context.Background(), conditions, workContext, operator)
+}
+
+// EvaluateConditionsWithContext evaluates multiple conditions with a logical operator,
+// passing ctx to evaluators that support cancellation
+func (cec *ConditionEvaluationCore) EvaluateConditionsWithContext(ctx context.Context, conditions []layer0.Condition, context *layer0.Context, operator layer0.ConditionOperator) (bool, error) {
 	if len(conditions) == 0 {
 		return true, nil // Empty condition list is considered true
 	}
@@ -194,7 +298,7 @@ func (cec *ConditionEvaluationCore) EvaluateConditions(conditions []layer0.Condi
 
 	// Evaluate all conditions
 	for i, condition := range conditions {
-		evalResult, err := cec.EvaluateCondition(condition, context)
+		evalResult, err := cec.EvaluateConditionWithContext(ctx, condition, context)
 		if err != nil {
 			return false, fmt.Errorf("failed to evaluate condition %s: %w", condition.GetID(), err)
 		}
@@ -248,7 +352,7 @@ func (cec *ConditionEvaluationCore) GetEvaluationResult(conditionID layer0.Condi
 	return result, nil
 }
 
-// GetAllEvaluationResults returns all evaluation results
+// GetAllEvaluationResults returns all evaluation results ordered by evaluation time, then condition ID
 func (cec *ConditionEvaluationCore) GetAllEvaluationResults() []ConditionEvaluationResult {
 	cec.mutex.RLock()
 	defer cec.mutex.RUnlock()
@@ -257,6 +361,12 @@ func (cec *ConditionEvaluationCore) GetAllEvaluationResults() []ConditionEvaluat
 	for _, result := range cec.evaluationResults {
 		results = append(results, result)
 	}
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].EvaluatedAt.Equal(results[j].EvaluatedAt) {
+			return results[i].EvaluatedAt.Before(results[j].EvaluatedAt)
+		}
+		return results[i].ConditionID < results[j].ConditionID
+	})
 
 	return results
 }
@@ -270,7 +380,7 @@ func (cec *ConditionEvaluationCore) IsConditionEvaluating(conditionID layer0.Con
 	return isActive
 }
 
-// GetActiveEvaluations returns all currently active condition evaluations
+// GetActiveEvaluations returns all currently active condition evaluations ordered by ID
 func (cec *ConditionEvaluationCore) GetActiveEvaluations() []layer0.Condition {
 	cec.mutex.RLock()
 	defer cec.mutex.RUnlock()
@@ -279,6 +389,9 @@ func (cec *ConditionEvaluationCore) GetActiveEvaluations() []layer0.Condition {
 	for _, condition := range cec.activeEvaluations {
 		activeConditions = append(activeConditions, condition)
 	}
+	sort.Slice(activeConditions, func(i, j int) bool {
+		return activeConditions[i].GetID() < activeConditions[j].GetID()
+	})
 
 	return activeConditions
 }
@@ -1,6 +1,7 @@
 package layer1
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
@@ -53,6 +54,64 @@ func TestConditionEvaluationCoreRegisterEvaluator(t *testing.T) {
 	}
 }
 
+func TestConditionEvaluationCoreRegisterEvaluatorForTypes(t *testing.T) {
+	cec := NewConditionEvaluationCore()
+	evaluator := NewMockConditionEvaluator([]layer0.ConditionType{layer0.ConditionTypeExpression, layer0.ConditionTypeScript}, nil)
+
+	// Without explicit types the evaluator's supported types are registered
+	if err := cec.RegisterEvaluatorForTypes(evaluator); err != nil {
+		t.Fatalf("RegisterEvaluatorForTypes should not return error: %v", err)
+	}
+	for _, conditionType := range evaluator.GetSupportedTypes() {
+		if registered, err := cec.GetEvaluator(conditionType); err != nil || registered != evaluator {
+			t.Errorf("Expected the evaluator to be registered for %s", conditionType)
+		}
+	}
+
+	// A conflict on any type rolls back the whole registration
+	other := NewMockConditionEvaluator([]layer0.ConditionType{layer0.ConditionTypeTime, layer0.ConditionTypeScript}, nil)
+	if err := cec.RegisterEvaluatorForTypes(other, layer0.ConditionTypeTime, layer0.ConditionTypeScript); err == nil {
+		t.Error("RegisterEvaluatorForTypes should return error when a type is already registered")
+	}
+	if _, err := cec.GetEvaluator(layer0.ConditionTypeTime); err == nil {
+		t.Error("No type should be registered when the registration conflicts")
+	}
+	if len(cec.GetSupportedConditionTypes()) != 2 {
+		t.Errorf("Expected 2 supported types, got %v", cec.GetSupportedConditionTypes())
+	}
+
+	if err := cec.RegisterEvaluatorForTypes(nil, layer0.ConditionTypeTime); err == nil {
+		t.Error("RegisterEvaluatorForTypes should return error for nil evaluator")
+	}
+	if err := cec.RegisterEvaluatorForTypes(other, layer0.ConditionTypeTime, layer0.ConditionTypeTime); err == nil {
+		t.Error("RegisterEvaluatorForTypes should return error for a repeated type")
+	}
+}
+
+func TestConditionEvaluationCoreEvaluateConditionWithContext(t *testing.T) {
+	cec := NewConditionEvaluationCore()
+	evaluated := false
+	cec.RegisterEvaluator(layer0.ConditionTypeTime, NewMockConditionEvaluator([]layer0.ConditionType{layer0.ConditionTypeTime}, func(c layer0.Condition, ctx *layer0.Context) (interface{}, error) {
+		evaluated = true
+		return true, nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	condition := layer0.NewCondition("deadline", layer0.ConditionTypeTime, "Deadline")
+	result, err := cec.EvaluateConditionWithContext(ctx, condition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("EvaluateConditionWithContext should not return error: %v", err)
+	}
+	if evaluated || result.Status != layer0.ConditionStatusError {
+		t.Errorf("Expected a cancelled condition not to be evaluated, got %+v", result)
+	}
+	if cec.IsConditionEvaluating("deadline") {
+		t.Error("Expected the cancelled evaluation to be released")
+	}
+}
+
 func TestConditionEvaluationCoreUnregisterEvaluator(t *testing.T) {
 	cec := NewConditionEvaluationCore()
 	evaluator := NewMockConditionEvaluator([]layer0.ConditionType{layer0.ConditionTypeExpression}, nil)
@@ -557,3 +616,100 @@ func TestMockConditionEvaluator(t *testing.T) {
 		t.Errorf("Expected true, got %v", result)
 	}
 }
+
+func TestConditionEvaluationCoreResultProcessor(t *testing.T) {
+	cec := NewConditionEvaluationCore()
+	cec.RegisterEvaluator(layer0.ConditionTypeExpression, NewMockConditionEvaluator(
+		[]layer0.ConditionType{layer0.ConditionTypeExpression},
+		func(c layer0.Condition, ctx *layer0.Context) (interface{}, error) {
+			value, _ := ctx.Get(string(c.GetID()))
+			return value, nil
+		},
+	))
+
+	context := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Ctx").
+		Set("approved", "yes").
+		Set("rejected", "no").
+		Set("flag", 7)
+	evaluate := func(conditionID string) (ConditionEvaluationResult, error) {
+		condition := layer0.NewCondition(layer0.ConditionID(conditionID), layer0.ConditionTypeExpression, conditionID)
+		condition.Expression.Expression = conditionID
+		return cec.EvaluateCondition(condition, context)
+	}
+
+	// The default treats any non-nil result as true
+	if result, _ := evaluate("rejected"); result.Status != layer0.ConditionStatusTrue {
+		t.Errorf("Expected the default processor to treat \"no\" as true, got %s", result.Status)
+	}
+
+	cec.SetResultProcessor(func(condition layer0.Condition, result interface{}) (bool, error) {
+		switch v := result.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return v == "yes" || v == "1", nil
+		default:
+			return false, errors.New("unsupported condition result")
+		}
+	})
+
+	result, err := evaluate("approved")
+	if err != nil {
+		t.Fatalf("EvaluateCondition should not return error: %v", err)
+	}
+	if result.Status != layer0.ConditionStatusTrue || result.Result != "yes" {
+		t.Errorf("Expected \"yes\" to be true with its raw result kept, got %s (%v)", result.Status, result.Result)
+	}
+
+	if result, _ := evaluate("rejected"); result.Status != layer0.ConditionStatusFalse {
+		t.Errorf("Expected \"no\" to be false, got %s", result.Status)
+	}
+
+	if result, _ := evaluate("flag"); result.Status != layer0.ConditionStatusError || result.Error == "" {
+		t.Errorf("Expected a processor error to mark the condition as errored, got %s", result.Status)
+	}
+
+	// A nil processor restores the default
+	cec.SetResultProcessor(nil)
+	if result, _ := evaluate("flag"); result.Status != layer0.ConditionStatusTrue {
+		t.Errorf("Expected the default processor after reset, got %s", result.Status)
+	}
+}
+
+func TestConditionEvaluationCoreOrderedResults(t *testing.T) {
+	cec := NewConditionEvaluationCore()
+	cec.RegisterEvaluator(layer0.ConditionTypeExpression, NewMockConditionEvaluator(
+		[]layer0.ConditionType{layer0.ConditionTypeExpression},
+		func(c layer0.Condition, ctx *layer0.Context) (interface{}, error) {
+			return true, nil
+		},
+	))
+	cec.RegisterEvaluator(layer0.ConditionTypeScript, NewMockConditionEvaluator([]layer0.ConditionType{layer0.ConditionTypeScript}, nil))
+
+	context := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Ctx")
+	conditionIDs := []layer0.ConditionID{"delta", "alpha", "charlie", "bravo"}
+	for _, conditionID := range conditionIDs {
+		condition := layer0.NewCondition(conditionID, layer0.ConditionTypeExpression, string(conditionID))
+		condition.Expression.Expression = "true"
+		if _, err := cec.EvaluateCondition(condition, context); err != nil {
+			t.Fatalf("EvaluateCondition should not return error: %v", err)
+		}
+	}
+
+	// Results follow evaluation order, with IDs breaking ties between equal times
+	for call := 0; call < 10; call++ {
+		results := cec.GetAllEvaluationResults()
+		for index := 1; index < len(results); index++ {
+			previous, current := results[index-1], results[index]
+			if current.EvaluatedAt.Before(previous.EvaluatedAt) ||
+				(current.EvaluatedAt.Equal(previous.EvaluatedAt) && current.ConditionID < previous.ConditionID) {
+				t.Fatalf("Call %d: results out of order at %d: %v", call, index, results)
+			}
+		}
+	}
+
+	types := cec.GetSupportedConditionTypes()
+	if len(types) != 2 || types[0] > types[1] {
+		t.Errorf("Expected supported types in sorted order, got %v", types)
+	}
+}
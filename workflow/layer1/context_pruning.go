@@ -0,0 +1,66 @@
+package layer1
+
+import "fmt"
+
+// ContextPruningMode selects which work outputs the engine drops from an instance
+// context after each transition
+type ContextPruningMode string
+
+const (
+	// ContextPruningNone keeps every work output
+	ContextPruningNone ContextPruningMode = ""
+	// ContextPruningKeepLast keeps only the most recently written work outputs
+	ContextPruningKeepLast ContextPruningMode = "keep_last"
+	// ContextPruningUnreferenced drops work outputs that no action of a transition
+	// reachable from the instance's new state lists in its input keys
+	ContextPruningUnreferenced ContextPruningMode = "unreferenced"
+)
+
+// ContextPruningPolicy bounds the growth of instance contexts in long workflows.
+// Only work outputs are pruned; keys set any other way are never dropped.
+type ContextPruningPolicy struct {
+	Mode         ContextPruningMode `json:"mode,omitempty"`
+	KeepLast     int                `json:"keep_last,omitempty"`     // Work outputs kept in keep_last mode
+	RetainedKeys []string           `json:"retained_keys,omitempty"` // Keys never pruned, such as outputs read by conditions
+}
+
+// Enabled reports whether the policy prunes anything
+func (policy ContextPruningPolicy) Enabled() bool {
+	return policy.Mode != ContextPruningNone
+}
+
+// Retains reports whether the policy never prunes a key
+func (policy ContextPruningPolicy) Retains(key string) bool {
+	for _, retained := range policy.RetainedKeys {
+		if retained == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the mode is known and keep_last keeps at least one output
+func (policy ContextPruningPolicy) Validate() error {
+	switch policy.Mode {
+	case ContextPruningNone, ContextPruningUnreferenced:
+	case ContextPruningKeepLast:
+		if policy.KeepLast <= 0 {
+			return fmt.Errorf("context pruning keep_last must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown context pruning mode: %s", policy.Mode)
+	}
+
+	if policy.KeepLast < 0 {
+		return fmt.Errorf("context pruning keep_last cannot be negative")
+	}
+	return nil
+}
+
+// Clone returns a copy of the policy
+func (policy ContextPruningPolicy) Clone() ContextPruningPolicy {
+	if policy.RetainedKeys != nil {
+		policy.RetainedKeys = append([]string(nil), policy.RetainedKeys...)
+	}
+	return policy
+}
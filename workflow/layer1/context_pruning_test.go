@@ -0,0 +1,44 @@
+package layer1
+
+import "testing"
+
+func TestContextPruningPolicyValidate(t *testing.T) {
+	valid := []ContextPruningPolicy{
+		{},
+		{Mode: ContextPruningKeepLast, KeepLast: 3},
+		{Mode: ContextPruningUnreferenced, RetainedKeys: []string{"order_id"}},
+	}
+	for _, policy := range valid {
+		if err := policy.Validate(); err != nil {
+			t.Errorf("Policy %+v should be valid: %v", policy, err)
+		}
+	}
+
+	invalid := []ContextPruningPolicy{
+		{Mode: ContextPruningKeepLast},
+		{Mode: "oldest_first"},
+		{Mode: ContextPruningUnreferenced, KeepLast: -1},
+	}
+	for _, policy := range invalid {
+		if err := policy.Validate(); err == nil {
+			t.Errorf("Policy %+v should be invalid", policy)
+		}
+	}
+}
+
+func TestContextPruningPolicyRetains(t *testing.T) {
+	policy := ContextPruningPolicy{Mode: ContextPruningKeepLast, KeepLast: 1, RetainedKeys: []string{"work_audit_output"}}
+	if !policy.Enabled() || (ContextPruningPolicy{}).Enabled() {
+		t.Error("Only policies with a mode should be enabled")
+	}
+	if !policy.Retains("work_audit_output") || policy.Retains("work_charge_output") {
+		t.Error("Only retained keys should be retained")
+	}
+
+	// Clones do not share retained keys
+	clone := policy.Clone()
+	clone.RetainedKeys[0] = "changed"
+	if policy.RetainedKeys[0] != "work_audit_output" {
+		t.Error("Modifying a clone should not change the original")
+	}
+}
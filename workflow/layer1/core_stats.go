@@ -0,0 +1,71 @@
+package layer1
+
+import (
+	"github.com/ubom/workflow/layer0"
+)
+
+// ConditionEvaluationStats summarizes the health of a condition evaluation core
+type ConditionEvaluationStats struct {
+	RegisteredEvaluators int     `json:"registered_evaluators"`
+	ActiveEvaluations    int     `json:"active_evaluations"`
+	TotalResults         int     `json:"total_results"`
+	ErrorResults         int     `json:"error_results"`
+	ErrorRate            float64 `json:"error_rate"`
+}
+
+// WorkExecutionStats summarizes the health of a work execution core
+type WorkExecutionStats struct {
+	RegisteredExecutors int     `json:"registered_executors"`
+	ActiveExecutions    int     `json:"active_executions"`
+	TotalResults        int     `json:"total_results"`
+	FailedResults       int     `json:"failed_results"`
+	ErrorRate           float64 `json:"error_rate"`
+}
+
+// Stats returns a consistent snapshot of the core's evaluators, active evaluations
+// and stored results. The error rate is the share of results with an error status.
+func (cec *ConditionEvaluationCore) Stats() ConditionEvaluationStats {
+	cec.mutex.RLock()
+	defer cec.mutex.RUnlock()
+
+	stats := ConditionEvaluationStats{
+		RegisteredEvaluators: len(cec.evaluators),
+		ActiveEvaluations:    len(cec.activeEvaluations),
+		TotalResults:         len(cec.evaluationResults),
+	}
+	for _, result := range cec.evaluationResults {
+		if result.Status == layer0.ConditionStatusError {
+			stats.ErrorResults++
+		}
+	}
+	stats.ErrorRate = errorRate(stats.ErrorResults, stats.TotalResults)
+	return stats
+}
+
+// Stats returns a consistent snapshot of the core's executors, active work and
+// stored results. The error rate is the share of results that failed.
+func (wec *WorkExecutionCore) Stats() WorkExecutionStats {
+	wec.mutex.RLock()
+	defer wec.mutex.RUnlock()
+
+	stats := WorkExecutionStats{
+		RegisteredExecutors: len(wec.executors),
+		ActiveExecutions:    len(wec.activeWork),
+		TotalResults:        len(wec.executionResults),
+	}
+	for _, result := range wec.executionResults {
+		if result.Status == layer0.WorkStatusFailed {
+			stats.FailedResults++
+		}
+	}
+	stats.ErrorRate = errorRate(stats.FailedResults, stats.TotalResults)
+	return stats
+}
+
+// errorRate returns errors as a fraction of total, or zero when there is nothing to rate
+func errorRate(errors, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(errors) / float64(total)
+}
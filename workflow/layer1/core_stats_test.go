@@ -0,0 +1,91 @@
+package layer1
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+func TestConditionEvaluationCoreStats(t *testing.T) {
+	core := NewConditionEvaluationCore()
+	if stats := core.Stats(); stats != (ConditionEvaluationStats{}) {
+		t.Errorf("Expected empty stats, got %+v", stats)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	core.RegisterEvaluator(layer0.ConditionTypeExpression, NewMockConditionEvaluator([]layer0.ConditionType{layer0.ConditionTypeExpression}, func(c layer0.Condition, ctx *layer0.Context) (interface{}, error) {
+		if c.GetID() == "slow" {
+			close(started)
+			<-release
+		}
+		if c.GetID() == "broken" {
+			return nil, errors.New("broken")
+		}
+		return true, nil
+	}))
+	core.RegisterEvaluator(layer0.ConditionTypeTime, NewMockConditionEvaluator([]layer0.ConditionType{layer0.ConditionTypeTime}, nil))
+
+	condition := func(id layer0.ConditionID) layer0.Condition {
+		condition := layer0.NewCondition(id, layer0.ConditionTypeExpression, string(id))
+		condition.Expression.Expression = "true"
+		return condition
+	}
+
+	context := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context")
+	done := make(chan struct{})
+	go func() {
+		core.EvaluateCondition(condition("slow"), context)
+		close(done)
+	}()
+	<-started
+
+	// The in-flight evaluation is active and has no result yet
+	stats := core.Stats()
+	if stats.RegisteredEvaluators != 2 || stats.ActiveEvaluations != 1 || stats.TotalResults != 0 {
+		t.Errorf("Expected 2 evaluators, 1 active and no results, got %+v", stats)
+	}
+
+	close(release)
+	<-done
+	core.EvaluateCondition(condition("broken"), context)
+
+	stats = core.Stats()
+	if stats.ActiveEvaluations != 0 || stats.TotalResults != 2 || stats.ErrorResults != 1 || stats.ErrorRate != 0.5 {
+		t.Errorf("Expected 2 results with a 0.5 error rate, got %+v", stats)
+	}
+}
+
+func TestWorkExecutionCoreStats(t *testing.T) {
+	core := NewWorkExecutionCore()
+	core.RegisterExecutor(layer0.WorkTypeTask, NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		if w.GetID() == "work-0" {
+			return nil, errors.New("failed")
+		}
+		return "done", nil
+	}))
+
+	// Concurrent executions are all accounted for
+	context := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context")
+	var wg sync.WaitGroup
+	for index := 0; index < 8; index++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			core.ExecuteWork(layer0.NewWork(layer0.WorkID(fmt.Sprintf("work-%d", index)), layer0.WorkTypeTask, "Work"), context)
+			core.Stats()
+		}(index)
+	}
+	wg.Wait()
+
+	stats := core.Stats()
+	if stats.RegisteredExecutors != 1 || stats.ActiveExecutions != 0 || stats.TotalResults != 8 || stats.FailedResults != 1 {
+		t.Errorf("Expected 1 executor and 8 results with 1 failure, got %+v", stats)
+	}
+	if stats.ErrorRate != 0.125 {
+		t.Errorf("Expected a 0.125 error rate, got %v", stats.ErrorRate)
+	}
+}
@@ -0,0 +1,27 @@
+package layer1
+
+import "context"
+
+// CorrelationIDHeader is the header outbound executors use to propagate the correlation ID
+const CorrelationIDHeader = "X-Correlation-ID"
+
+// correlationIDKey is the context key holding the correlation ID
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a copy of ctx carrying the correlation ID
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	if correlationID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+// CorrelationIDFromContext returns the correlation ID carried by ctx, if any
+func CorrelationIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	correlationID, _ := ctx.Value(correlationIDKey{}).(string)
+	return correlationID
+}
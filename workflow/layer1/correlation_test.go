@@ -0,0 +1,22 @@
+package layer1
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCorrelationIDContext(t *testing.T) {
+	if id := CorrelationIDFromContext(context.Background()); id != "" {
+		t.Errorf("Expected no correlation ID, got %s", id)
+	}
+
+	ctx := WithCorrelationID(context.Background(), "corr-1")
+	if id := CorrelationIDFromContext(ctx); id != "corr-1" {
+		t.Errorf("Expected corr-1, got %s", id)
+	}
+
+	// An empty ID leaves the context unchanged
+	if WithCorrelationID(ctx, "") != ctx {
+		t.Error("Empty correlation ID should not wrap the context")
+	}
+}
@@ -0,0 +1,235 @@
+package layer1
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// DecisionTableParameter is the work parameter holding a decision work's table
+const DecisionTableParameter = "decision_table"
+
+// DecisionOperator compares a context value with a rule's value
+type DecisionOperator string
+
+const (
+	DecisionOperatorEqual          DecisionOperator = "eq"
+	DecisionOperatorNotEqual       DecisionOperator = "ne"
+	DecisionOperatorLess           DecisionOperator = "lt"
+	DecisionOperatorLessOrEqual    DecisionOperator = "lte"
+	DecisionOperatorGreater        DecisionOperator = "gt"
+	DecisionOperatorGreaterOrEqual DecisionOperator = "gte"
+)
+
+// DecisionRule is a single comparison against a context value
+type DecisionRule struct {
+	Key      string           `json:"key"`
+	Operator DecisionOperator `json:"operator"`
+	Value    interface{}      `json:"value"`
+}
+
+// DecisionRow matches when all of its rules hold. A row without rules always
+// matches, so a default row goes last.
+type DecisionRow struct {
+	When   []DecisionRule `json:"when,omitempty"`
+	Output interface{}    `json:"output"`
+}
+
+// DecisionTable routes on context values by evaluating rows top to bottom; the
+// first matching row wins. Inputs lists the context keys rules may read.
+type DecisionTable struct {
+	Inputs []string      `json:"inputs"`
+	Rows   []DecisionRow `json:"rows"`
+}
+
+// Validate checks that every rule reads a declared input with a known operator
+func (table DecisionTable) Validate() error {
+	if len(table.Rows) == 0 {
+		return fmt.Errorf("decision table has no rows")
+	}
+
+	inputs := make(map[string]bool, len(table.Inputs))
+	for _, input := range table.Inputs {
+		inputs[input] = true
+	}
+
+	for index, row := range table.Rows {
+		for _, rule := range row.When {
+			if !inputs[rule.Key] {
+				return fmt.Errorf("decision row %d reads undeclared input %s", index, rule.Key)
+			}
+
+			switch rule.Operator {
+			case DecisionOperatorEqual, DecisionOperatorNotEqual, DecisionOperatorLess,
+				DecisionOperatorLessOrEqual, DecisionOperatorGreater, DecisionOperatorGreaterOrEqual:
+			default:
+				return fmt.Errorf("decision row %d has unsupported operator %q", index, rule.Operator)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Evaluate returns the index of the first row whose rules hold in the context,
+// or -1 when no row matches
+func (table DecisionTable) Evaluate(context *layer0.Context) (int, error) {
+	for index, row := range table.Rows {
+		matched := true
+		for _, rule := range row.When {
+			holds, err := rule.holds(context)
+			if err != nil {
+				return -1, fmt.Errorf("decision row %d: %w", index, err)
+			}
+			if !holds {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return index, nil
+		}
+	}
+
+	return -1, nil
+}
+
+// holds checks the rule against the context; a missing key never holds
+func (rule DecisionRule) holds(context *layer0.Context) (bool, error) {
+	if context == nil {
+		return false, nil
+	}
+
+	actual, exists := context.Get(rule.Key)
+	if !exists {
+		return false, nil
+	}
+
+	switch rule.Operator {
+	case DecisionOperatorEqual:
+		return decisionEqual(actual, rule.Value), nil
+	case DecisionOperatorNotEqual:
+		return !decisionEqual(actual, rule.Value), nil
+	}
+
+	comparison, err := decisionCompare(actual, rule.Value)
+	if err != nil {
+		return false, fmt.Errorf("input %s: %w", rule.Key, err)
+	}
+
+	switch rule.Operator {
+	case DecisionOperatorLess:
+		return comparison < 0, nil
+	case DecisionOperatorLessOrEqual:
+		return comparison <= 0, nil
+	case DecisionOperatorGreater:
+		return comparison > 0, nil
+	case DecisionOperatorGreaterOrEqual:
+		return comparison >= 0, nil
+	default:
+		return false, fmt.Errorf("unsupported operator %q", rule.Operator)
+	}
+}
+
+// decisionEqual compares numbers by value regardless of their Go type
+func decisionEqual(actual, expected interface{}) bool {
+	if a, ok := decisionNumber(actual); ok {
+		if e, ok := decisionNumber(expected); ok {
+			return a == e
+		}
+	}
+	return reflect.DeepEqual(actual, expected)
+}
+
+// decisionCompare orders two numbers or two strings
+func decisionCompare(actual, expected interface{}) (int, error) {
+	if a, ok := decisionNumber(actual); ok {
+		if e, ok := decisionNumber(expected); ok {
+			switch {
+			case a < e:
+				return -1, nil
+			case a > e:
+				return 1, nil
+			default:
+				return 0, nil
+			}
+		}
+	}
+
+	if a, ok := actual.(string); ok {
+		if e, ok := expected.(string); ok {
+			switch {
+			case a < e:
+				return -1, nil
+			case a > e:
+				return 1, nil
+			default:
+				return 0, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("cannot order %T against %T", actual, expected)
+}
+
+// decisionNumber converts numeric values to float64
+func decisionNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// DecisionExecutor executes decision work by evaluating the work's decision table
+// against the context and returning the first matching row's output. The engine
+// stores the output in the context, where a following transition can branch on it.
+type DecisionExecutor struct{}
+
+// NewDecisionExecutor creates a new decision executor
+func NewDecisionExecutor() *DecisionExecutor {
+	return &DecisionExecutor{}
+}
+
+// Execute returns the output of the first matching row
+func (de *DecisionExecutor) Execute(work layer0.Work, context *layer0.Context) (interface{}, error) {
+	table, ok := work.GetConfiguration().Parameters[DecisionTableParameter].(DecisionTable)
+	if !ok {
+		return nil, fmt.Errorf("decision work %s requires a %s parameter", work.GetID(), DecisionTableParameter)
+	}
+
+	if err := table.Validate(); err != nil {
+		return nil, fmt.Errorf("decision work %s: %w", work.GetID(), err)
+	}
+
+	index, err := table.Evaluate(context)
+	if err != nil {
+		return nil, fmt.Errorf("decision work %s: %w", work.GetID(), err)
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("decision work %s matched no row", work.GetID())
+	}
+
+	return table.Rows[index].Output, nil
+}
+
+// CanExecute checks if the executor can execute the given work type
+func (de *DecisionExecutor) CanExecute(workType layer0.WorkType) bool {
+	return workType == layer0.WorkTypeDecision
+}
+
+// GetSupportedTypes returns the supported work types
+func (de *DecisionExecutor) GetSupportedTypes() []layer0.WorkType {
+	return []layer0.WorkType{layer0.WorkTypeDecision}
+}
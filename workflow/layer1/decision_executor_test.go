@@ -0,0 +1,96 @@
+package layer1
+
+import (
+	"testing"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+func newDecisionWork(table DecisionTable) layer0.Work {
+	work := layer0.NewWork("route-order", layer0.WorkTypeDecision, "Route Order")
+	work.Configuration.Parameters = map[string]interface{}{DecisionTableParameter: table}
+	return work
+}
+
+func TestDecisionExecutorExecute(t *testing.T) {
+	executor := NewDecisionExecutor()
+
+	if !executor.CanExecute(layer0.WorkTypeDecision) {
+		t.Error("DecisionExecutor should execute decision work")
+	}
+
+	table := DecisionTable{
+		Inputs: []string{"amount", "region"},
+		Rows: []DecisionRow{
+			{When: []DecisionRule{{Key: "amount", Operator: DecisionOperatorGreater, Value: 1000}}, Output: "manual_review"},
+			{When: []DecisionRule{{Key: "region", Operator: DecisionOperatorEqual, Value: "eu"}}, Output: "eu_fulfilment"},
+			{Output: "standard"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		context  *layer0.Context
+		expected interface{}
+	}{
+		{
+			name:     "matching row",
+			context:  layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Ctx").Set("amount", 50).Set("region", "eu"),
+			expected: "eu_fulfilment",
+		},
+		{
+			name:     "no match falls back to default row",
+			context:  layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Ctx").Set("amount", 50.5).Set("region", "us"),
+			expected: "standard",
+		},
+		{
+			name:     "first of several matching rows wins",
+			context:  layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Ctx").Set("amount", int64(5000)).Set("region", "eu"),
+			expected: "manual_review",
+		},
+	}
+
+	for _, test := range tests {
+		output, err := executor.Execute(newDecisionWork(table), test.context)
+		if err != nil {
+			t.Errorf("%s: Execute should not return error: %v", test.name, err)
+			continue
+		}
+		if output != test.expected {
+			t.Errorf("%s: expected output %v, got %v", test.name, test.expected, output)
+		}
+	}
+}
+
+func TestDecisionExecutorErrors(t *testing.T) {
+	executor := NewDecisionExecutor()
+	context := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Ctx").Set("amount", 50)
+
+	// Without a default row a table can match nothing
+	noDefault := DecisionTable{
+		Inputs: []string{"amount"},
+		Rows:   []DecisionRow{{When: []DecisionRule{{Key: "amount", Operator: DecisionOperatorGreater, Value: 100}}, Output: "big"}},
+	}
+	if _, err := executor.Execute(newDecisionWork(noDefault), context); err == nil {
+		t.Error("Execute should return error when no row matches")
+	}
+
+	undeclared := DecisionTable{
+		Rows: []DecisionRow{{When: []DecisionRule{{Key: "amount", Operator: DecisionOperatorEqual, Value: 50}}, Output: "exact"}},
+	}
+	if _, err := executor.Execute(newDecisionWork(undeclared), context); err == nil {
+		t.Error("Execute should return error for a rule reading an undeclared input")
+	}
+
+	unordered := DecisionTable{
+		Inputs: []string{"amount"},
+		Rows:   []DecisionRow{{When: []DecisionRule{{Key: "amount", Operator: DecisionOperatorLess, Value: "ten"}}, Output: "small"}},
+	}
+	if _, err := executor.Execute(newDecisionWork(unordered), context); err == nil {
+		t.Error("Execute should return error when ordering a number against a string")
+	}
+
+	if _, err := executor.Execute(layer0.NewWork("no-table", layer0.WorkTypeDecision, "No Table"), context); err == nil {
+		t.Error("Execute should return error without a decision table")
+	}
+}
@@ -0,0 +1,81 @@
+package layer1
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// DefinitionHashVersion identifies the canonical form hashed by ContentHash. It is
+// part of every hash and is bumped whenever the canonical form changes, so hashes
+// computed by an older form never match newer ones.
+const DefinitionHashVersion = 1
+
+// definitionContent is the behavioral content of a definition hashed by ContentHash
+type definitionContent struct {
+	ID             WorkflowDefinitionID   `json:"id"`
+	InitialStateID layer0.StateID         `json:"initial_state_id"`
+	FinalStateIDs  []layer0.StateID       `json:"final_state_ids"`
+	ErrorStateIDs  []layer0.StateID       `json:"error_state_ids"`
+	States         []layer0.State         `json:"states"`
+	Transitions    []layer0.Transition    `json:"transitions"`
+	GlobalContext  map[string]interface{} `json:"global_context"`
+	Configuration  WorkflowConfiguration  `json:"configuration"`
+	Invariants     []Invariant            `json:"invariants"`
+}
+
+// ContentHash returns a stable hash of the definition's states, transitions, global
+// context, configuration and invariants, prefixed with DefinitionHashVersion.
+// Definitions that behave the same hash equally regardless of the order their parts
+// were added in; the version, status, definition metadata and every creation and
+// update timestamp are excluded.
+func (wd WorkflowDefinition) ContentHash() string {
+	content := definitionContent{
+		ID:             wd.ID,
+		InitialStateID: wd.InitialStateID,
+		FinalStateIDs:  sortedStateIDs(wd.FinalStateIDs),
+		ErrorStateIDs:  sortedStateIDs(wd.ErrorStateIDs),
+		Configuration:  wd.Configuration,
+	}
+
+	if wd.StateMachine != nil {
+		for _, state := range wd.StateMachine.GetAllStates() {
+			state.Metadata.CreatedAt = time.Time{}
+			state.Metadata.UpdatedAt = time.Time{}
+			content.States = append(content.States, state)
+		}
+		for _, transition := range wd.StateMachine.sortedTransitions() {
+			transition.Metadata.CreatedAt = time.Time{}
+			transition.Metadata.UpdatedAt = time.Time{}
+			content.Transitions = append(content.Transitions, transition)
+		}
+	}
+
+	if wd.GlobalContext != nil {
+		content.GlobalContext = make(map[string]interface{})
+		for _, key := range wd.GlobalContext.Keys() {
+			content.GlobalContext[key], _ = wd.GlobalContext.Get(key)
+		}
+	}
+
+	for _, invariant := range wd.Invariants {
+		invariant.Condition.Metadata.CreatedAt = time.Time{}
+		invariant.Condition.Metadata.UpdatedAt = time.Time{}
+		invariant.Condition.Metadata.EvaluatedAt = nil
+		content.Invariants = append(content.Invariants, invariant)
+	}
+
+	return fmt.Sprintf("v%d:%s", DefinitionHashVersion, layer0.CanonicalHash(content))
+}
+
+// sortedStateIDs returns a sorted copy of state IDs
+func sortedStateIDs(stateIDs []layer0.StateID) []layer0.StateID {
+	sorted := make([]layer0.StateID, len(stateIDs))
+	copy(sorted, stateIDs)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	return sorted
+}
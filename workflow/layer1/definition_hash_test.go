@@ -0,0 +1,60 @@
+package layer1
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// newHashDefinition builds a three state definition, adding the states in the given order
+func newHashDefinition(stateIDs []layer0.StateID, reviewTarget layer0.StateID) WorkflowDefinition {
+	types := map[layer0.StateID]layer0.StateType{
+		"draft":    layer0.StateTypeInitial,
+		"review":   layer0.StateTypeIntermediate,
+		"approved": layer0.StateTypeFinal,
+		"rejected": layer0.StateTypeFinal,
+	}
+
+	stateMachine := NewStateMachineCore()
+	for _, stateID := range stateIDs {
+		stateMachine.AddState(layer0.NewState(stateID, types[stateID], string(stateID)))
+	}
+	stateMachine.AddTransition(layer0.NewTransition("submit", layer0.TransitionTypeAutomatic, "draft", "review", "Submit"))
+	stateMachine.AddTransition(layer0.NewTransition("decide", layer0.TransitionTypeAutomatic, "review", reviewTarget, "Decide"))
+
+	return NewWorkflowDefinition("approval", "1.0.0", "Approval").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("draft").
+		AddFinalStateID("approved").
+		AddFinalStateID("rejected").
+		SetStatus(WorkflowDefinitionStatusActive)
+}
+
+func TestWorkflowDefinitionContentHash(t *testing.T) {
+	definition := newHashDefinition([]layer0.StateID{"draft", "review", "approved", "rejected"}, "approved")
+	hash := definition.ContentHash()
+	if !strings.HasPrefix(hash, "v1:") {
+		t.Errorf("Expected the hash to carry its version, got %s", hash)
+	}
+
+	// Created later and with its states added in another order, the definition hashes the same
+	time.Sleep(time.Millisecond)
+	reordered := newHashDefinition([]layer0.StateID{"rejected", "approved", "review", "draft"}, "approved")
+	reordered.Version = "1.0.1"
+	if reordered.ContentHash() != hash {
+		t.Error("Reordering how states were added should not change the hash")
+	}
+
+	retargeted := newHashDefinition([]layer0.StateID{"draft", "review", "approved", "rejected"}, "rejected")
+	if retargeted.ContentHash() == hash {
+		t.Error("Changing a transition target should change the hash")
+	}
+
+	configuration := definition.GetConfiguration()
+	configuration.MaxConcurrentWork = 4
+	if definition.UpdateConfiguration(configuration).ContentHash() == hash {
+		t.Error("Changing the configuration should change the hash")
+	}
+}
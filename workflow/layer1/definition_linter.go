@@ -0,0 +1,202 @@
+package layer1
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// LintSeverity represents how serious a lint issue is
+type LintSeverity string
+
+const (
+	// LintSeverityError marks an issue that prevents the definition from validating
+	LintSeverityError LintSeverity = "error"
+	// LintSeverityWarning marks a likely authoring mistake that does not block execution
+	LintSeverityWarning LintSeverity = "warning"
+	// LintSeverityInfo marks a suggestion for improving the definition
+	LintSeverityInfo LintSeverity = "info"
+)
+
+// Lint rule IDs
+const (
+	LintRuleValidation         = "validation"
+	LintRuleUnusedCondition    = "unused-condition"
+	LintRuleTrivialState       = "trivial-state"
+	LintRuleMissingDescription = "missing-description"
+	LintRulePermissiveTimeout  = "permissive-timeout"
+	LintRuleUnreachableError   = "unreachable-error-state"
+	LintRuleNoErrorPath        = "no-error-path"
+)
+
+// lintMaxTimeout is the longest state or default timeout accepted without a warning
+const lintMaxTimeout = 24 * time.Hour
+
+// LintIssue is a problem found in a workflow definition by Lint
+type LintIssue struct {
+	Severity LintSeverity `json:"severity"`
+	RuleID   string       `json:"rule_id"`
+	Element  string       `json:"element"` // e.g. "state:review" or "condition:approved"
+	Message  string       `json:"message"`
+}
+
+// String formats the issue for display
+func (issue LintIssue) String() string {
+	return fmt.Sprintf("%s [%s] %s: %s", issue.Severity, issue.RuleID, issue.Element, issue.Message)
+}
+
+// Lint reports validation errors along with warnings and suggestions that do not
+// block execution. Conditions passed in are checked for use by the definition's transitions.
+func Lint(definition WorkflowDefinition, conditions ...layer0.Condition) []LintIssue {
+	var issues []LintIssue
+	definitionElement := fmt.Sprintf("definition:%s", definition.ID)
+
+	for _, err := range definition.ValidateAll() {
+		issues = append(issues, LintIssue{Severity: LintSeverityError, RuleID: LintRuleValidation, Element: definitionElement, Message: err.Error()})
+	}
+
+	if definition.Metadata.Description == "" {
+		issues = append(issues, LintIssue{Severity: LintSeverityInfo, RuleID: LintRuleMissingDescription, Element: definitionElement, Message: "definition has no description"})
+	}
+
+	if timeout := time.Duration(definition.Configuration.DefaultTimeoutSeconds) * time.Second; timeout > lintMaxTimeout {
+		issues = append(issues, LintIssue{Severity: LintSeverityWarning, RuleID: LintRulePermissiveTimeout, Element: definitionElement, Message: fmt.Sprintf("default timeout %s exceeds %s", timeout, lintMaxTimeout)})
+	}
+
+	if definition.StateMachine != nil {
+		issues = append(issues, lintStates(definition)...)
+		issues = append(issues, lintConditions(definition, conditions)...)
+		issues = append(issues, lintErrorStates(definition)...)
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Element != issues[j].Element {
+			return issues[i].Element < issues[j].Element
+		}
+		return issues[i].RuleID < issues[j].RuleID
+	})
+
+	return issues
+}
+
+// lintStates checks each state for missing descriptions, trivial transitions and long timeouts
+func lintStates(definition WorkflowDefinition) []LintIssue {
+	var issues []LintIssue
+
+	for _, state := range definition.StateMachine.GetAllStates() {
+		element := fmt.Sprintf("state:%s", state.GetID())
+
+		if state.GetMetadata().Description == "" {
+			issues = append(issues, LintIssue{Severity: LintSeverityInfo, RuleID: LintRuleMissingDescription, Element: element, Message: "state has no description"})
+		}
+
+		if state.GetTimeout() > lintMaxTimeout {
+			issues = append(issues, LintIssue{Severity: LintSeverityWarning, RuleID: LintRulePermissiveTimeout, Element: element, Message: fmt.Sprintf("timeout %s exceeds %s", state.GetTimeout(), lintMaxTimeout)})
+		}
+
+		// A pass-through state only forwards to the next state and could be removed
+		if state.GetID() == definition.InitialStateID || state.GetType() != layer0.StateTypeIntermediate {
+			continue
+		}
+
+		transitions := definition.StateMachine.GetTransitionsFromState(state.GetID())
+		if len(transitions) == 1 && len(transitions[0].GetConditions()) == 0 && len(transitions[0].GetActions()) == 0 && state.GetTimeout() == 0 {
+			issues = append(issues, LintIssue{Severity: LintSeverityWarning, RuleID: LintRuleTrivialState, Element: element, Message: fmt.Sprintf("state only passes through to %s via %s", transitions[0].GetToStateID(), transitions[0].GetID())})
+		}
+	}
+
+	return issues
+}
+
+// lintConditions reports conditions that no transition references
+func lintConditions(definition WorkflowDefinition, conditions []layer0.Condition) []LintIssue {
+	referenced := make(map[string]bool)
+	for _, state := range definition.StateMachine.GetAllStates() {
+		for _, transition := range definition.StateMachine.GetTransitionsFromState(state.GetID()) {
+			for _, conditionID := range transition.GetConditions() {
+				referenced[conditionID] = true
+			}
+		}
+	}
+
+	var issues []LintIssue
+	for _, condition := range conditions {
+		if !referenced[string(condition.GetID())] {
+			issues = append(issues, LintIssue{Severity: LintSeverityWarning, RuleID: LintRuleUnusedCondition, Element: fmt.Sprintf("condition:%s", condition.GetID()), Message: "condition is not referenced by any transition"})
+		}
+	}
+
+	return issues
+}
+
+// lintErrorStates reports declared error states that no transition leads to, and
+// states that run work but cannot reach any error state. Definitions without error
+// states are not checked, since their failures fail the instance directly.
+func lintErrorStates(definition WorkflowDefinition) []LintIssue {
+	if len(definition.ErrorStateIDs) == 0 {
+		return nil
+	}
+
+	errorStates := make(map[layer0.StateID]bool, len(definition.ErrorStateIDs))
+	for _, stateID := range definition.ErrorStateIDs {
+		errorStates[stateID] = true
+	}
+
+	incoming := make(map[layer0.StateID]bool)
+	for _, state := range definition.StateMachine.GetAllStates() {
+		for _, transition := range definition.StateMachine.GetTransitionsFromState(state.GetID()) {
+			incoming[transition.GetToStateID()] = true
+		}
+	}
+
+	var issues []LintIssue
+	for _, stateID := range definition.ErrorStateIDs {
+		if !incoming[stateID] {
+			issues = append(issues, LintIssue{Severity: LintSeverityWarning, RuleID: LintRuleUnreachableError, Element: fmt.Sprintf("state:%s", stateID), Message: "error state has no incoming transitions"})
+		}
+	}
+
+	for _, state := range definition.StateMachine.GetAllStates() {
+		if errorStates[state.GetID()] || !runsWork(definition, state.GetID()) {
+			continue
+		}
+		if !reachesAny(definition, state.GetID(), errorStates) {
+			issues = append(issues, LintIssue{Severity: LintSeverityWarning, RuleID: LintRuleNoErrorPath, Element: fmt.Sprintf("state:%s", state.GetID()), Message: "state runs work but has no path to an error state"})
+		}
+	}
+
+	return issues
+}
+
+// runsWork reports whether any transition out of the state has actions
+func runsWork(definition WorkflowDefinition, stateID layer0.StateID) bool {
+	for _, transition := range definition.StateMachine.GetTransitionsFromState(stateID) {
+		if len(transition.GetActions()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// reachesAny reports whether any of the targets can be reached from the state
+func reachesAny(definition WorkflowDefinition, from layer0.StateID, targets map[layer0.StateID]bool) bool {
+	visited := map[layer0.StateID]bool{from: true}
+	queue := []layer0.StateID{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, transition := range definition.StateMachine.GetTransitionsFromState(current) {
+			next := transition.GetToStateID()
+			if targets[next] {
+				return true
+			}
+			if !visited[next] {
+				visited[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package layer1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// findLintIssue returns the issue for an element and rule, if reported
+func findLintIssue(issues []LintIssue, element, ruleID string) (LintIssue, bool) {
+	for _, issue := range issues {
+		if issue.Element == element && issue.RuleID == ruleID {
+			return issue, true
+		}
+	}
+	return LintIssue{}, false
+}
+
+func TestLint(t *testing.T) {
+	describe := func(state layer0.State) layer0.State {
+		state.Metadata.Description = "Described"
+		return state
+	}
+
+	stateMachine := NewStateMachineCore()
+	stateMachine.AddState(describe(layer0.NewState("initial", layer0.StateTypeInitial, "Initial")))
+	stateMachine.AddState(describe(layer0.NewState("relay", layer0.StateTypeIntermediate, "Relay")))
+	stateMachine.AddState(layer0.NewState("review", layer0.StateTypeIntermediate, "Review").SetTimeout(72 * time.Hour))
+	stateMachine.AddState(describe(layer0.NewState("final", layer0.StateTypeFinal, "Final")))
+	stateMachine.AddTransition(layer0.NewTransition("initial-to-relay", layer0.TransitionTypeAutomatic, "initial", "relay", "Initial to Relay"))
+	stateMachine.AddTransition(layer0.NewTransition("relay-to-review", layer0.TransitionTypeAutomatic, "relay", "review", "Relay to Review"))
+	stateMachine.AddTransition(layer0.NewTransition("review-to-final", layer0.TransitionTypeConditional, "review", "final", "Review to Final").
+		AddCondition("approved"))
+
+	definition := NewWorkflowDefinition("lint", "1.0.0", "Lint").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("initial").
+		AddFinalStateID("final").
+		SetStatus(WorkflowDefinitionStatusActive)
+	definition.Metadata.Description = "Described"
+
+	issues := Lint(definition,
+		layer0.NewCondition("approved", layer0.ConditionTypeExpression, "Approved"),
+		layer0.NewCondition("escalated", layer0.ConditionTypeExpression, "Escalated"),
+	)
+
+	for _, issue := range issues {
+		if issue.Severity == LintSeverityError {
+			t.Errorf("Valid definition should have no errors, got %s", issue)
+		}
+	}
+
+	if issue, found := findLintIssue(issues, "condition:escalated", LintRuleUnusedCondition); !found || issue.Severity != LintSeverityWarning {
+		t.Errorf("Expected unused condition warning, got %v", issues)
+	}
+
+	if _, found := findLintIssue(issues, "condition:approved", LintRuleUnusedCondition); found {
+		t.Error("Referenced condition should not be reported")
+	}
+
+	if issue, found := findLintIssue(issues, "state:review", LintRuleMissingDescription); !found || issue.Severity != LintSeverityInfo {
+		t.Errorf("Expected missing description info, got %v", issues)
+	}
+
+	if _, found := findLintIssue(issues, "state:initial", LintRuleMissingDescription); found {
+		t.Error("Described state should not be reported")
+	}
+
+	if _, found := findLintIssue(issues, "state:review", LintRulePermissiveTimeout); !found {
+		t.Error("Expected permissive timeout warning for review")
+	}
+
+	if _, found := findLintIssue(issues, "state:relay", LintRuleTrivialState); !found {
+		t.Error("Expected trivial state warning for relay")
+	}
+
+	// Validation failures are reported as errors
+	broken := definition.SetInitialStateID("missing")
+	if issue, found := findLintIssue(Lint(broken), "definition:lint", LintRuleValidation); !found || issue.Severity != LintSeverityError {
+		t.Error("Expected validation error issue for invalid definition")
+	}
+}
+
+func TestLintErrorStates(t *testing.T) {
+	stateMachine := NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("initial", layer0.StateTypeInitial, "Initial"))
+	stateMachine.AddState(layer0.NewState("charge", layer0.StateTypeIntermediate, "Charge"))
+	stateMachine.AddState(layer0.NewState("ship", layer0.StateTypeIntermediate, "Ship"))
+	stateMachine.AddState(layer0.NewState("final", layer0.StateTypeFinal, "Final"))
+	stateMachine.AddState(layer0.NewState("payment-failed", layer0.StateTypeError, "Payment Failed"))
+	stateMachine.AddState(layer0.NewState("orphaned", layer0.StateTypeError, "Orphaned"))
+	stateMachine.AddTransition(layer0.NewTransition("initial-to-charge", layer0.TransitionTypeAutomatic, "initial", "charge", "Initial to Charge"))
+	stateMachine.AddTransition(layer0.NewTransition("charge-to-ship", layer0.TransitionTypeConditional, "charge", "ship", "Charge to Ship").
+		AddCondition("paid").
+		AddAction("charge-card"))
+	stateMachine.AddTransition(layer0.NewTransition("charge-to-failed", layer0.TransitionTypeConditional, "charge", "payment-failed", "Charge to Failed").
+		AddCondition("declined"))
+	stateMachine.AddTransition(layer0.NewTransition("ship-to-final", layer0.TransitionTypeAutomatic, "ship", "final", "Ship to Final").
+		AddAction("book-courier"))
+
+	definition := NewWorkflowDefinition("errors", "1.0.0", "Errors").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("initial").
+		AddFinalStateID("final").
+		AddErrorStateID("payment-failed").
+		AddErrorStateID("orphaned")
+
+	issues := Lint(definition)
+
+	if issue, found := findLintIssue(issues, "state:orphaned", LintRuleUnreachableError); !found || issue.Severity != LintSeverityWarning {
+		t.Errorf("Expected unreachable error state warning for orphaned, got %v", issues)
+	}
+	if _, found := findLintIssue(issues, "state:payment-failed", LintRuleUnreachableError); found {
+		t.Error("Error state with an incoming transition should not be reported")
+	}
+
+	if issue, found := findLintIssue(issues, "state:ship", LintRuleNoErrorPath); !found || issue.Severity != LintSeverityWarning {
+		t.Errorf("Expected no error path warning for ship, got %v", issues)
+	}
+	if _, found := findLintIssue(issues, "state:charge", LintRuleNoErrorPath); found {
+		t.Error("State with a transition to an error state should not be reported")
+	}
+	if _, found := findLintIssue(issues, "state:initial", LintRuleNoErrorPath); found {
+		t.Error("State that runs no work should not be reported")
+	}
+
+	// Definitions without error states are not checked
+	withoutErrorStates := NewWorkflowDefinition("errors", "1.0.0", "Errors").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("initial").
+		AddFinalStateID("final")
+	for _, issue := range Lint(withoutErrorStates) {
+		if issue.RuleID == LintRuleNoErrorPath || issue.RuleID == LintRuleUnreachableError {
+			t.Errorf("Expected no error state issues without error states, got %s", issue)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package layer1
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// definitionKey identifies one version of a workflow definition
+type definitionKey struct {
+	id      WorkflowDefinitionID
+	version WorkflowDefinitionVersion
+}
+
+// DefinitionRegistry holds validated workflow definitions by ID and version.
+// Registered versions are immutable; changes are registered as new versions, and
+// each definition has a default version that new instances should start from.
+type DefinitionRegistry struct {
+	definitions map[definitionKey]WorkflowDefinition
+	defaults    map[WorkflowDefinitionID]WorkflowDefinitionVersion
+	mutex       sync.RWMutex
+}
+
+// NewDefinitionRegistry creates a new empty definition registry
+func NewDefinitionRegistry() *DefinitionRegistry {
+	return &DefinitionRegistry{
+		definitions: make(map[definitionKey]WorkflowDefinition),
+		defaults:    make(map[WorkflowDefinitionID]WorkflowDefinitionVersion),
+		mutex:       sync.RWMutex{},
+	}
+}
+
+// Register validates and adds a definition; a version that is already registered is rejected.
+// The first version registered for a definition becomes its default version.
+func (registry *DefinitionRegistry) Register(definition WorkflowDefinition) error {
+	if err := definition.Validate(); err != nil {
+		return fmt.Errorf("invalid workflow definition %s version %s: %w", definition.GetID(), definition.GetVersion(), err)
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	key := definitionKey{id: definition.GetID(), version: definition.GetVersion()}
+	if _, exists := registry.definitions[key]; exists {
+		return fmt.Errorf("workflow definition %s version %s is already registered", key.id, key.version)
+	}
+
+	registry.addUnsafe(definition)
+	return nil
+}
+
+// UpdateDefinition registers definition as a new version of a registered definition,
+// numbered by bumping the last component of its latest version (1.2.0 becomes 1.2.1).
+// Existing versions are left unchanged and the default version is not moved; use
+// SetDefaultVersion to start new instances from the returned version.
+func (registry *DefinitionRegistry) UpdateDefinition(definition WorkflowDefinition) (WorkflowDefinition, error) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	latest, exists := registry.latestVersionUnsafe(definition.GetID())
+	if !exists {
+		return WorkflowDefinition{}, fmt.Errorf("workflow definition %s not found", definition.GetID())
+	}
+
+	version, err := nextDefinitionVersion(latest)
+	if err != nil {
+		return WorkflowDefinition{}, err
+	}
+
+	updated := definition.Clone()
+	updated.Version = version
+	if err := updated.Validate(); err != nil {
+		return WorkflowDefinition{}, fmt.Errorf("invalid workflow definition %s version %s: %w", updated.GetID(), version, err)
+	}
+
+	registry.addUnsafe(updated)
+	return updated, nil
+}
+
+// SetDefaultVersion makes a registered version the one new instances of the definition start from
+func (registry *DefinitionRegistry) SetDefaultVersion(id WorkflowDefinitionID, version WorkflowDefinitionVersion) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if _, exists := registry.definitions[definitionKey{id: id, version: version}]; !exists {
+		return fmt.Errorf("workflow definition %s version %s not found", id, version)
+	}
+
+	registry.defaults[id] = version
+	return nil
+}
+
+// GetDefault retrieves the default version of a registered definition
+func (registry *DefinitionRegistry) GetDefault(id WorkflowDefinitionID) (WorkflowDefinition, error) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	version, exists := registry.defaults[id]
+	if !exists {
+		return WorkflowDefinition{}, fmt.Errorf("workflow definition %s not found", id)
+	}
+
+	return registry.definitions[definitionKey{id: id, version: version}], nil
+}
+
+// addUnsafe stores a definition, making it the default when it is the first version of its ID.
+// This method assumes the caller already holds the mutex lock.
+func (registry *DefinitionRegistry) addUnsafe(definition WorkflowDefinition) {
+	registry.definitions[definitionKey{id: definition.GetID(), version: definition.GetVersion()}] = definition
+	if _, exists := registry.defaults[definition.GetID()]; !exists {
+		registry.defaults[definition.GetID()] = definition.GetVersion()
+	}
+}
+
+// latestVersionUnsafe returns the highest registered version of a definition.
+// This method assumes the caller already holds the mutex lock.
+func (registry *DefinitionRegistry) latestVersionUnsafe(id WorkflowDefinitionID) (WorkflowDefinitionVersion, bool) {
+	var latest WorkflowDefinitionVersion
+	found := false
+	for key := range registry.definitions {
+		if key.id == id && (!found || compareDefinitionVersions(key.version, latest) > 0) {
+			latest = key.version
+			found = true
+		}
+	}
+	return latest, found
+}
+
+// nextDefinitionVersion increments the last numeric component of a dotted version
+func nextDefinitionVersion(version WorkflowDefinitionVersion) (WorkflowDefinitionVersion, error) {
+	parts := strings.Split(string(version), ".")
+	last, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil || last < 0 {
+		return "", fmt.Errorf("cannot bump workflow definition version %s: last component is not a number", version)
+	}
+
+	parts[len(parts)-1] = strconv.Itoa(last + 1)
+	return WorkflowDefinitionVersion(strings.Join(parts, ".")), nil
+}
+
+// compareDefinitionVersions orders dotted versions component by component, comparing
+// numeric components as numbers and the rest as strings
+func compareDefinitionVersions(a, b WorkflowDefinitionVersion) int {
+	aParts := strings.Split(string(a), ".")
+	bParts := strings.Split(string(b), ".")
+	for index := 0; index < len(aParts) && index < len(bParts); index++ {
+		aNumber, aErr := strconv.Atoi(aParts[index])
+		bNumber, bErr := strconv.Atoi(bParts[index])
+		switch {
+		case aErr == nil && bErr == nil && aNumber != bNumber:
+			if aNumber < bNumber {
+				return -1
+			}
+			return 1
+		case (aErr != nil || bErr != nil) && aParts[index] != bParts[index]:
+			return strings.Compare(aParts[index], bParts[index])
+		}
+	}
+	return len(aParts) - len(bParts)
+}
+
+// Get retrieves a registered definition by ID and version
+func (registry *DefinitionRegistry) Get(id WorkflowDefinitionID, version WorkflowDefinitionVersion) (WorkflowDefinition, error) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	definition, exists := registry.definitions[definitionKey{id: id, version: version}]
+	if !exists {
+		return WorkflowDefinition{}, fmt.Errorf("workflow definition %s version %s not found", id, version)
+	}
+
+	return definition, nil
+}
+
+// List returns all registered definitions ordered by ID, then version
+func (registry *DefinitionRegistry) List() []WorkflowDefinition {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	definitions := make([]WorkflowDefinition, 0, len(registry.definitions))
+	for _, definition := range registry.definitions {
+		definitions = append(definitions, definition)
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		if definitions[i].GetID() != definitions[j].GetID() {
+			return definitions[i].GetID() < definitions[j].GetID()
+		}
+		return definitions[i].GetVersion() < definitions[j].GetVersion()
+	})
+
+	return definitions
+}
+
+// ExportAll serializes every registered definition to JSON, ordered by ID and version
+func (registry *DefinitionRegistry) ExportAll() ([]byte, error) {
+	data, err := json.Marshal(registry.List())
+	if err != nil {
+		return nil, fmt.Errorf("failed to export workflow definitions: %w", err)
+	}
+	return data, nil
+}
+
+// ImportAll registers every definition in data produced by ExportAll. The import is
+// atomic: if any definition is invalid or its version is already registered, none
+// are imported and the error lists every problem found.
+func (registry *DefinitionRegistry) ImportAll(data []byte) error {
+	var definitions []WorkflowDefinition
+	if err := json.Unmarshal(data, &definitions); err != nil {
+		return fmt.Errorf("failed to decode workflow definitions: %w", err)
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	var problems []string
+	seen := make(map[definitionKey]bool, len(definitions))
+	for _, definition := range definitions {
+		key := definitionKey{id: definition.GetID(), version: definition.GetVersion()}
+
+		if err := definition.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("workflow definition %s version %s is invalid: %v", key.id, key.version, err))
+		}
+
+		switch _, exists := registry.definitions[key]; {
+		case exists:
+			problems = append(problems, fmt.Sprintf("workflow definition %s version %s conflicts with a registered definition", key.id, key.version))
+		case seen[key]:
+			problems = append(problems, fmt.Sprintf("workflow definition %s version %s appears more than once", key.id, key.version))
+		}
+		seen[key] = true
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("import rejected: %s", strings.Join(problems, "; "))
+	}
+
+	for _, definition := range definitions {
+		registry.addUnsafe(definition)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package layer1
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+func newRegistryDefinition(id WorkflowDefinitionID, version WorkflowDefinitionVersion) WorkflowDefinition {
+	stateMachine := NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	stateMachine.AddTransition(layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("notify"))
+
+	return NewWorkflowDefinition(id, version, string(id)).
+		SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("end").
+		SetStatus(WorkflowDefinitionStatusActive)
+}
+
+func TestDefinitionRegistryExportImport(t *testing.T) {
+	source := NewDefinitionRegistry()
+	if err := source.Register(newRegistryDefinition("orders", "1.0.0")); err != nil {
+		t.Fatalf("Register should not return error: %v", err)
+	}
+	if err := source.Register(newRegistryDefinition("refunds", "2.0.0")); err != nil {
+		t.Fatalf("Register should not return error: %v", err)
+	}
+
+	data, err := source.ExportAll()
+	if err != nil {
+		t.Fatalf("ExportAll should not return error: %v", err)
+	}
+
+	target := NewDefinitionRegistry()
+	if err := target.ImportAll(data); err != nil {
+		t.Fatalf("ImportAll should not return error: %v", err)
+	}
+
+	imported := target.List()
+	if len(imported) != 2 {
+		t.Fatalf("Expected 2 imported definitions, got %d", len(imported))
+	}
+
+	for i, original := range source.List() {
+		if imported[i].GetID() != original.GetID() || imported[i].GetVersion() != original.GetVersion() {
+			t.Errorf("Expected %s %s, got %s %s", original.GetID(), original.GetVersion(), imported[i].GetID(), imported[i].GetVersion())
+		}
+		originalStates := original.GetStateMachine().GetAllStates()
+		importedStates := imported[i].GetStateMachine().GetAllStates()
+		if len(importedStates) != len(originalStates) {
+			t.Fatalf("Expected %d states in %s, got %d", len(originalStates), original.GetID(), len(importedStates))
+		}
+		for j := range originalStates {
+			if importedStates[j].GetID() != originalStates[j].GetID() || importedStates[j].GetType() != originalStates[j].GetType() {
+				t.Errorf("Expected state %s of %s to round-trip, got %s", originalStates[j].GetID(), original.GetID(), importedStates[j].GetID())
+			}
+		}
+
+		transition, err := imported[i].GetStateMachine().GetTransition("start-to-end")
+		if err != nil || !reflect.DeepEqual(transition.GetActions(), []string{"notify"}) {
+			t.Errorf("Expected the transitions of %s to round-trip, got %v (%v)", original.GetID(), transition, err)
+		}
+	}
+
+	reexported, err := target.ExportAll()
+	if err != nil {
+		t.Fatalf("ExportAll should not return error: %v", err)
+	}
+	if !bytes.Equal(data, reexported) {
+		t.Error("Re-exporting the imported definitions should produce the same document")
+	}
+}
+
+func TestDefinitionRegistryImportIsAtomic(t *testing.T) {
+	registry := NewDefinitionRegistry()
+	if err := registry.Register(newRegistryDefinition("orders", "1.0.0")); err != nil {
+		t.Fatalf("Register should not return error: %v", err)
+	}
+	if err := registry.Register(newRegistryDefinition("orders", "1.0.0")); err == nil {
+		t.Error("Registering a version twice should return error")
+	}
+
+	source := NewDefinitionRegistry()
+	source.Register(newRegistryDefinition("orders", "1.0.0"))
+	source.Register(newRegistryDefinition("shipping", "1.0.0"))
+	data, _ := source.ExportAll()
+
+	// The conflicting orders version rejects the whole import
+	err := registry.ImportAll(data)
+	if err == nil || !strings.Contains(err.Error(), "orders version 1.0.0 conflicts") {
+		t.Fatalf("Expected a version conflict error, got %v", err)
+	}
+	if _, err := registry.Get("shipping", "1.0.0"); err == nil {
+		t.Error("No definition should be imported when any is rejected")
+	}
+
+	if err := registry.ImportAll([]byte("not json")); err == nil {
+		t.Error("ImportAll should reject malformed data")
+	}
+}
+
+func TestDefinitionRegistryUpdateDefinition(t *testing.T) {
+	registry := NewDefinitionRegistry()
+	if _, err := registry.UpdateDefinition(newRegistryDefinition("orders", "1.0.0")); err == nil {
+		t.Error("UpdateDefinition should reject a definition that is not registered")
+	}
+
+	registry.Register(newRegistryDefinition("orders", "1.0.9"))
+	registry.Register(newRegistryDefinition("orders", "1.0.10"))
+
+	// The bump starts from the numerically latest version, whatever the version on the update
+	updated, err := registry.UpdateDefinition(newRegistryDefinition("orders", "1.0.9"))
+	if err != nil {
+		t.Fatalf("UpdateDefinition should not return error: %v", err)
+	}
+	if updated.GetVersion() != "1.0.11" {
+		t.Errorf("Expected version 1.0.11, got %s", updated.GetVersion())
+	}
+
+	// The first registered version stays the default until another is chosen
+	if current, _ := registry.GetDefault("orders"); current.GetVersion() != "1.0.9" {
+		t.Errorf("Expected default version 1.0.9, got %s", current.GetVersion())
+	}
+	if err := registry.SetDefaultVersion("orders", "2.0.0"); err == nil {
+		t.Error("SetDefaultVersion should reject an unregistered version")
+	}
+	if err := registry.SetDefaultVersion("orders", "1.0.11"); err != nil {
+		t.Fatalf("SetDefaultVersion should not return error: %v", err)
+	}
+	if current, _ := registry.GetDefault("orders"); current.GetVersion() != "1.0.11" {
+		t.Errorf("Expected default version 1.0.11, got %s", current.GetVersion())
+	}
+
+	registry.Register(newRegistryDefinition("labels", "draft"))
+	if _, err := registry.UpdateDefinition(newRegistryDefinition("labels", "draft")); err == nil {
+		t.Error("UpdateDefinition should reject a version it cannot bump")
+	}
+}
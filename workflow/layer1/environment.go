@@ -0,0 +1,34 @@
+package layer1
+
+import "context"
+
+// environmentKey is the context key holding the workflow environment
+type environmentKey struct{}
+
+// WithEnvironment returns a copy of ctx carrying the environment variables of the
+// workflow instance executors run for
+func WithEnvironment(ctx context.Context, environment map[string]string) context.Context {
+	if len(environment) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, environmentKey{}, environment)
+}
+
+// EnvironmentFromContext returns a copy of the environment variables carried by ctx,
+// or nil when it carries none
+func EnvironmentFromContext(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+
+	environment, _ := ctx.Value(environmentKey{}).(map[string]string)
+	if environment == nil {
+		return nil
+	}
+
+	copied := make(map[string]string, len(environment))
+	for key, value := range environment {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,29 @@
+package layer1
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEnvironmentContext(t *testing.T) {
+	if environment := EnvironmentFromContext(context.Background()); environment != nil {
+		t.Errorf("Expected no environment, got %v", environment)
+	}
+
+	ctx := WithEnvironment(context.Background(), map[string]string{"REGION": "eu-west-1"})
+	environment := EnvironmentFromContext(ctx)
+	if environment["REGION"] != "eu-west-1" {
+		t.Errorf("Expected REGION eu-west-1, got %v", environment)
+	}
+
+	// Callers receive a copy they cannot use to modify the context
+	environment["REGION"] = "us-east-1"
+	if EnvironmentFromContext(ctx)["REGION"] != "eu-west-1" {
+		t.Error("Modifying the returned environment should not change the context")
+	}
+
+	// An empty environment leaves the context unchanged
+	if WithEnvironment(ctx, nil) != ctx {
+		t.Error("Empty environment should not wrap the context")
+	}
+}
@@ -0,0 +1,61 @@
+package layer1
+
+import (
+	"fmt"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// ExecutorCapabilities declares what an executor supports and requires
+type ExecutorCapabilities struct {
+	SupportsStreaming    bool `json:"supports_streaming"`
+	SupportsCancellation bool `json:"supports_cancellation"`
+	Idempotent           bool `json:"idempotent"`
+	RequiresNetwork      bool `json:"requires_network"`
+}
+
+// CapableExecutor is implemented by executors that declare their capabilities
+type CapableExecutor interface {
+	WorkExecutor
+	Capabilities() ExecutorCapabilities
+}
+
+// CapabilitiesOf returns the capabilities an executor declares. Executors that do
+// not declare any are treated as idempotent, and as supporting cancellation when
+// they are context aware.
+func CapabilitiesOf(executor WorkExecutor) ExecutorCapabilities {
+	if capable, ok := executor.(CapableExecutor); ok {
+		return capable.Capabilities()
+	}
+
+	_, contextAware := executor.(ContextAwareWorkExecutor)
+	return ExecutorCapabilities{
+		SupportsCancellation: contextAware,
+		Idempotent:           true,
+	}
+}
+
+// GetExecutorCapabilities returns the capabilities of the executor registered for a work type
+func (wec *WorkExecutionCore) GetExecutorCapabilities(workType layer0.WorkType) (ExecutorCapabilities, error) {
+	executor, err := wec.GetExecutor(workType)
+	if err != nil {
+		return ExecutorCapabilities{}, err
+	}
+	return CapabilitiesOf(executor), nil
+}
+
+// GetWorkCapabilities returns the capabilities of the executor that would run work,
+// following the work router and the fallback executor
+func (wec *WorkExecutionCore) GetWorkCapabilities(work layer0.Work) (ExecutorCapabilities, error) {
+	wec.mutex.RLock()
+	defer wec.mutex.RUnlock()
+
+	executorKey := wec.router.Route(work)
+	if executor, exists := wec.executors[executorKey]; exists {
+		return CapabilitiesOf(executor), nil
+	}
+	if wec.fallback != nil {
+		return CapabilitiesOf(wec.fallback), nil
+	}
+	return ExecutorCapabilities{}, fmt.Errorf("no executor registered for key %s", executorKey)
+}
@@ -0,0 +1,58 @@
+package layer1
+
+import (
+	"testing"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// declaringExecutor is a mock executor that declares its capabilities
+type declaringExecutor struct {
+	*MockWorkExecutor
+	capabilities ExecutorCapabilities
+}
+
+func (de *declaringExecutor) Capabilities() ExecutorCapabilities {
+	return de.capabilities
+}
+
+func TestWorkExecutionCoreExecutorCapabilities(t *testing.T) {
+	declared := ExecutorCapabilities{SupportsStreaming: true, RequiresNetwork: true}
+	core := NewWorkExecutionCore()
+	core.RegisterExecutor(layer0.WorkTypeService, &declaringExecutor{
+		MockWorkExecutor: NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeService}, nil),
+		capabilities:     declared,
+	})
+	core.RegisterExecutor(layer0.WorkTypeScript, NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeScript}, nil))
+	core.RegisterExecutor(layer0.WorkTypeTask, &sleepingExecutor{})
+
+	capabilities, err := core.GetExecutorCapabilities(layer0.WorkTypeService)
+	if err != nil {
+		t.Fatalf("GetExecutorCapabilities should not return error: %v", err)
+	}
+	if capabilities != declared {
+		t.Errorf("Expected the declared capabilities %+v, got %+v", declared, capabilities)
+	}
+
+	// Executors that declare nothing are idempotent, and cancellable when context aware
+	if capabilities, _ := core.GetExecutorCapabilities(layer0.WorkTypeScript); capabilities != (ExecutorCapabilities{Idempotent: true}) {
+		t.Errorf("Expected default capabilities, got %+v", capabilities)
+	}
+	if capabilities, _ := core.GetExecutorCapabilities(layer0.WorkTypeTask); !capabilities.SupportsCancellation || !capabilities.Idempotent {
+		t.Errorf("Expected a context aware executor to support cancellation, got %+v", capabilities)
+	}
+
+	if _, err := core.GetExecutorCapabilities(layer0.WorkTypeHuman); err == nil {
+		t.Error("GetExecutorCapabilities should return error for an unregistered work type")
+	}
+
+	// Work without a registered executor reports the capabilities of the fallback
+	work := layer0.NewWork("review", layer0.WorkTypeHuman, "Review")
+	if _, err := core.GetWorkCapabilities(work); err == nil {
+		t.Error("GetWorkCapabilities should return error without an executor or fallback")
+	}
+	core.SetFallbackExecutor(&declaringExecutor{MockWorkExecutor: NewMockWorkExecutor(nil, nil), capabilities: declared})
+	if capabilities, err := core.GetWorkCapabilities(work); err != nil || capabilities != declared {
+		t.Errorf("Expected the fallback capabilities %+v, got %+v (%v)", declared, capabilities, err)
+	}
+}
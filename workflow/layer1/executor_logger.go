@@ -0,0 +1,135 @@
+package layer1
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+// LogLevel is the least severe level of messages a level-filtering logger emits
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+// ParseLogLevel parses a configured logging level such as "DEBUG" or "info".
+// Unknown and empty levels default to LogLevelInfo.
+func ParseLogLevel(level string) LogLevel {
+	switch strings.ToUpper(strings.TrimSpace(level)) {
+	case "DEBUG":
+		return LogLevelDebug
+	case "WARN", "WARNING":
+		return LogLevelWarn
+	case "ERROR":
+		return LogLevelError
+	default:
+		return LogLevelInfo
+	}
+}
+
+// Logger receives log messages from executors
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// StdLogger writes messages through the standard log package, prefixed with their level
+type StdLogger struct{}
+
+// NewStdLogger creates a new logger writing through the standard log package
+func NewStdLogger() *StdLogger {
+	return &StdLogger{}
+}
+
+// Debugf logs a debug message
+func (logger *StdLogger) Debugf(format string, args ...interface{}) {
+	log.Printf("[DEBUG] "+format, args...)
+}
+
+// Infof logs an informational message
+func (logger *StdLogger) Infof(format string, args ...interface{}) {
+	log.Printf("[INFO] "+format, args...)
+}
+
+// Warnf logs a warning
+func (logger *StdLogger) Warnf(format string, args ...interface{}) {
+	log.Printf("[WARN] "+format, args...)
+}
+
+// Errorf logs an error
+func (logger *StdLogger) Errorf(format string, args ...interface{}) {
+	log.Printf("[ERROR] "+format, args...)
+}
+
+// LevelLogger wraps a logger, dropping messages less severe than its level
+type LevelLogger struct {
+	logger Logger
+	level  LogLevel
+}
+
+// NewLevelLogger creates a new logger passing messages at level or above to logger
+func NewLevelLogger(logger Logger, level LogLevel) *LevelLogger {
+	return &LevelLogger{logger: logger, level: level}
+}
+
+// Debugf logs a debug message if the level allows it
+func (ll *LevelLogger) Debugf(format string, args ...interface{}) {
+	if ll.level <= LogLevelDebug {
+		ll.logger.Debugf(format, args...)
+	}
+}
+
+// Infof logs an informational message if the level allows it
+func (ll *LevelLogger) Infof(format string, args ...interface{}) {
+	if ll.level <= LogLevelInfo {
+		ll.logger.Infof(format, args...)
+	}
+}
+
+// Warnf logs a warning if the level allows it
+func (ll *LevelLogger) Warnf(format string, args ...interface{}) {
+	if ll.level <= LogLevelWarn {
+		ll.logger.Warnf(format, args...)
+	}
+}
+
+// Errorf logs an error
+func (ll *LevelLogger) Errorf(format string, args ...interface{}) {
+	ll.logger.Errorf(format, args...)
+}
+
+// discardLogger drops every message
+type discardLogger struct{}
+
+func (discardLogger) Debugf(format string, args ...interface{}) {}
+func (discardLogger) Infof(format string, args ...interface{})  {}
+func (discardLogger) Warnf(format string, args ...interface{})  {}
+func (discardLogger) Errorf(format string, args ...interface{}) {}
+
+// loggerKey is the context key holding the executor logger
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying the logger executors should log through
+func WithLogger(ctx context.Context, logger Logger) context.Context {
+	if logger == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger carried by ctx, or a logger that discards
+// every message when ctx carries none
+func LoggerFromContext(ctx context.Context) Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(Logger); ok {
+			return logger
+		}
+	}
+	return discardLogger{}
+}
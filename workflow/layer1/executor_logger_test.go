@@ -0,0 +1,77 @@
+package layer1
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// capturingLogger records every message it receives, prefixed with its level
+type capturingLogger struct {
+	messages []string
+}
+
+func (logger *capturingLogger) Debugf(format string, args ...interface{}) {
+	logger.messages = append(logger.messages, "DEBUG "+fmt.Sprintf(format, args...))
+}
+
+func (logger *capturingLogger) Infof(format string, args ...interface{}) {
+	logger.messages = append(logger.messages, "INFO "+fmt.Sprintf(format, args...))
+}
+
+func (logger *capturingLogger) Warnf(format string, args ...interface{}) {
+	logger.messages = append(logger.messages, "WARN "+fmt.Sprintf(format, args...))
+}
+
+func (logger *capturingLogger) Errorf(format string, args ...interface{}) {
+	logger.messages = append(logger.messages, "ERROR "+fmt.Sprintf(format, args...))
+}
+
+func TestParseLogLevel(t *testing.T) {
+	cases := map[string]LogLevel{
+		"DEBUG":   LogLevelDebug,
+		"debug":   LogLevelDebug,
+		"INFO":    LogLevelInfo,
+		"WARN":    LogLevelWarn,
+		"warning": LogLevelWarn,
+		"ERROR":   LogLevelError,
+		"":        LogLevelInfo,
+		"VERBOSE": LogLevelInfo,
+	}
+	for level, expected := range cases {
+		if parsed := ParseLogLevel(level); parsed != expected {
+			t.Errorf("ParseLogLevel(%q) = %d, expected %d", level, parsed, expected)
+		}
+	}
+}
+
+func TestLevelLogger(t *testing.T) {
+	capture := &capturingLogger{}
+	logger := NewLevelLogger(capture, LogLevelWarn)
+
+	logger.Debugf("debug %d", 1)
+	logger.Infof("info %d", 2)
+	logger.Warnf("warn %d", 3)
+	logger.Errorf("error %d", 4)
+
+	if len(capture.messages) != 2 || capture.messages[0] != "WARN warn 3" || capture.messages[1] != "ERROR error 4" {
+		t.Errorf("Expected only warn and error messages, got %v", capture.messages)
+	}
+}
+
+func TestLoggerContext(t *testing.T) {
+	// Without a logger, messages are discarded
+	LoggerFromContext(context.Background()).Infof("dropped")
+
+	capture := &capturingLogger{}
+	ctx := WithLogger(context.Background(), capture)
+	LoggerFromContext(ctx).Infof("kept")
+	if len(capture.messages) != 1 || capture.messages[0] != "INFO kept" {
+		t.Errorf("Expected the context logger to receive the message, got %v", capture.messages)
+	}
+
+	// A nil logger leaves the context unchanged
+	if WithLogger(ctx, nil) != ctx {
+		t.Error("Nil logger should not wrap the context")
+	}
+}
@@ -0,0 +1,66 @@
+package layer1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// SetExecutorTimeout bounds how long the executor registered under workType may run
+// each work. The executor's context is cancelled at the deadline and the work fails;
+// executors that ignore cancellation are abandoned at the deadline. Zero removes the limit.
+func (wec *WorkExecutionCore) SetExecutorTimeout(workType layer0.WorkType, timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("executor timeout cannot be negative")
+	}
+
+	wec.mutex.Lock()
+	defer wec.mutex.Unlock()
+
+	if timeout == 0 {
+		delete(wec.timeouts, workType)
+		return nil
+	}
+	wec.timeouts[workType] = timeout
+	return nil
+}
+
+// executeWithTimeout runs the executor with a context that is cancelled after timeout
+// and fails once the deadline passes, even if the executor has not returned
+func executeWithTimeout(ctx context.Context, timeout time.Duration, checkpoints CheckpointStore, executor WorkExecutor, work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type execution struct {
+		output interface{}
+		err    error
+	}
+	done := make(chan execution, 1)
+	go func() {
+		output, err := runExecutor(ctx, checkpoints, executor, work, workContext)
+		done <- execution{output: output, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		if result.err != nil && ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("work %s timed out after %s: %w", work.GetID(), timeout, ctx.Err())
+		}
+		return result.output, result.err
+	case <-ctx.Done():
+		if ctx.Err() != context.DeadlineExceeded {
+			return nil, fmt.Errorf("work %s was cancelled: %w", work.GetID(), ctx.Err())
+		}
+		return nil, fmt.Errorf("work %s timed out after %s: %w", work.GetID(), timeout, ctx.Err())
+	}
+}
+
+// runExecutor executes work, resuming checkpointable work from its last checkpoint
+func runExecutor(ctx context.Context, checkpoints CheckpointStore, executor WorkExecutor, work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	if checkpointable, ok := executor.(CheckpointableExecutor); ok && checkpoints != nil {
+		return executeWithCheckpoint(ctx, checkpoints, checkpointable, work, workContext)
+	}
+	return executeWithContext(ctx, executor, work, workContext)
+}
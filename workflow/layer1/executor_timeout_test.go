@@ -0,0 +1,89 @@
+package layer1
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// sleepingExecutor sleeps for delay, stopping early when its context is cancelled
+// unless it ignores cancellation
+type sleepingExecutor struct {
+	delay         time.Duration
+	ignoresCancel bool
+	cancelled     chan struct{}
+}
+
+func (se *sleepingExecutor) Execute(work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	time.Sleep(se.delay)
+	return "done", nil
+}
+
+func (se *sleepingExecutor) ExecuteWithContext(ctx context.Context, work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	if se.ignoresCancel {
+		return se.Execute(work, workContext)
+	}
+	select {
+	case <-time.After(se.delay):
+		return "done", nil
+	case <-ctx.Done():
+		close(se.cancelled)
+		return nil, ctx.Err()
+	}
+}
+
+func (se *sleepingExecutor) CanExecute(workType layer0.WorkType) bool {
+	return workType == layer0.WorkTypeTask
+}
+
+func (se *sleepingExecutor) GetSupportedTypes() []layer0.WorkType {
+	return []layer0.WorkType{layer0.WorkTypeTask}
+}
+
+func TestWorkExecutionCoreExecutorTimeout(t *testing.T) {
+	context := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Ctx")
+
+	for _, ignoresCancel := range []bool{false, true} {
+		executor := &sleepingExecutor{delay: time.Second, ignoresCancel: ignoresCancel, cancelled: make(chan struct{})}
+		core := NewWorkExecutionCore()
+		core.RegisterExecutor(layer0.WorkTypeTask, executor)
+		if err := core.SetExecutorTimeout(layer0.WorkTypeTask, 20*time.Millisecond); err != nil {
+			t.Fatalf("SetExecutorTimeout should not return error: %v", err)
+		}
+
+		started := time.Now()
+		result, _ := core.ExecuteWork(layer0.NewWork("slow", layer0.WorkTypeTask, "Slow"), context)
+		if result.Status != layer0.WorkStatusFailed || !strings.Contains(result.Error, "timed out") {
+			t.Errorf("Expected the slow work to fail with a timeout (ignores cancel %v), got %s: %s", ignoresCancel, result.Status, result.Error)
+		}
+		if elapsed := time.Since(started); elapsed >= executor.delay {
+			t.Errorf("Expected the work to fail at the timeout (ignores cancel %v), took %s", ignoresCancel, elapsed)
+		}
+		if !ignoresCancel {
+			select {
+			case <-executor.cancelled:
+			case <-time.After(executor.delay):
+				t.Error("Expected the executor's context to be cancelled")
+			}
+		}
+	}
+
+	// Work that finishes within the timeout, or runs with the timeout removed, completes
+	core := NewWorkExecutionCore()
+	core.RegisterExecutor(layer0.WorkTypeTask, &sleepingExecutor{delay: time.Millisecond, cancelled: make(chan struct{})})
+	core.SetExecutorTimeout(layer0.WorkTypeTask, time.Second)
+	if result, err := core.ExecuteWork(layer0.NewWork("fast", layer0.WorkTypeTask, "Fast"), context); err != nil || result.Status != layer0.WorkStatusCompleted {
+		t.Errorf("Expected work within the timeout to complete, got %s (%v)", result.Status, err)
+	}
+	core.SetExecutorTimeout(layer0.WorkTypeTask, 0)
+	if result, err := core.ExecuteWork(layer0.NewWork("fast", layer0.WorkTypeTask, "Fast"), context); err != nil || result.Status != layer0.WorkStatusCompleted {
+		t.Errorf("Expected work without a timeout to complete, got %s (%v)", result.Status, err)
+	}
+
+	if err := core.SetExecutorTimeout(layer0.WorkTypeTask, -time.Second); err == nil {
+		t.Error("SetExecutorTimeout should reject a negative timeout")
+	}
+}
@@ -0,0 +1,219 @@
+package layer1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// ForEachParameter is the work parameter holding a for-each work's spec
+const ForEachParameter = "for_each"
+
+// defaultForEachConcurrency bounds how many items of a for-each work run at once
+const defaultForEachConcurrency = 4
+
+// ForEachErrorPolicy defines how a for-each work handles failing items
+type ForEachErrorPolicy string
+
+const (
+	// ForEachFailFast fails the work at the first failing item and skips items not yet started
+	ForEachFailFast ForEachErrorPolicy = "fail_fast"
+	// ForEachCollectErrors runs every item and reports failures in the item results
+	ForEachCollectErrors ForEachErrorPolicy = "collect_errors"
+)
+
+// ForEachSpec describes the work a for-each work runs for each element of a context array
+type ForEachSpec struct {
+	ItemsKey    string             `json:"items_key"`              // Context key holding the array
+	ItemKey     string             `json:"item_key,omitempty"`     // Context key each item is exposed as; defaults to "item"
+	Work        layer0.Work        `json:"work"`                   // Work run once per item, dispatched by its type
+	Concurrency int                `json:"concurrency,omitempty"`  // Items run at once; zero uses the default of 4
+	ErrorPolicy ForEachErrorPolicy `json:"error_policy,omitempty"` // Defaults to fail fast
+}
+
+// ForEachItemResult is the outcome of one item, in the order of the array
+type ForEachItemResult struct {
+	Index  int         `json:"index"`
+	Output interface{} `json:"output,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// Validate checks that the spec names an array and the work to run
+func (spec ForEachSpec) Validate() error {
+	if spec.ItemsKey == "" {
+		return fmt.Errorf("for-each spec requires an items key")
+	}
+	if spec.Work.GetType() == "" {
+		return fmt.Errorf("for-each spec requires a work type")
+	}
+	if spec.Concurrency < 0 {
+		return fmt.Errorf("for-each concurrency cannot be negative")
+	}
+
+	switch spec.ErrorPolicy {
+	case "", ForEachFailFast, ForEachCollectErrors:
+	default:
+		return fmt.Errorf("unknown for-each error policy: %s", spec.ErrorPolicy)
+	}
+	return nil
+}
+
+// ForEachExecutor executes for-each work by running the spec's work once per element
+// of a context array, dispatching it through the execution core by its type. Each item
+// sees the context with the element under the item key and its position under
+// "<item key>_index". The output lists the item results in array order.
+type ForEachExecutor struct {
+	core *WorkExecutionCore
+}
+
+// NewForEachExecutor creates a for-each executor that dispatches items through core
+func NewForEachExecutor(core *WorkExecutionCore) *ForEachExecutor {
+	return &ForEachExecutor{core: core}
+}
+
+// Execute runs the work for every item
+func (fe *ForEachExecutor) Execute(work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	return fe.ExecuteWithContext(context.Background(), work, workContext)
+}
+
+// ExecuteWithContext runs the work for every item, stopping early when ctx is done
+func (fe *ForEachExecutor) ExecuteWithContext(ctx context.Context, work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	spec, ok := work.GetConfiguration().Parameters[ForEachParameter].(ForEachSpec)
+	if !ok {
+		return nil, fmt.Errorf("for-each work %s requires a %s parameter", work.GetID(), ForEachParameter)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, fmt.Errorf("for-each work %s: %w", work.GetID(), err)
+	}
+
+	items, err := forEachItems(workContext, spec.ItemsKey)
+	if err != nil {
+		return nil, fmt.Errorf("for-each work %s: %w", work.GetID(), err)
+	}
+
+	itemKey := spec.ItemKey
+	if itemKey == "" {
+		itemKey = "item"
+	}
+	concurrency := spec.Concurrency
+	if concurrency == 0 {
+		concurrency = defaultForEachConcurrency
+	}
+	failFast := spec.ErrorPolicy != ForEachCollectErrors
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]ForEachItemResult, len(items))
+	failures := make([]error, len(items))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for index, item := range items {
+		wg.Add(1)
+		go func(index int, item interface{}) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			results[index].Index = index
+			if err := ctx.Err(); err != nil {
+				results[index].Error = err.Error()
+				return
+			}
+
+			itemWork := forEachItemWork(work, spec.Work, index)
+			itemContext := workContext.Set(itemKey, item).Set(itemKey+"_index", index)
+			output, err := fe.runItem(ctx, itemWork, itemContext)
+			results[index].Output = output
+			if err != nil {
+				results[index].Error = err.Error()
+				failures[index] = fmt.Errorf("item %d: %w", index, err)
+				if failFast {
+					cancel()
+				}
+			}
+		}(index, item)
+	}
+	wg.Wait()
+
+	if failFast {
+		for _, failure := range failures {
+			if failure != nil {
+				return nil, fmt.Errorf("for-each work %s: %w", work.GetID(), failure)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// runItem executes one item's work and returns its output
+func (fe *ForEachExecutor) runItem(ctx context.Context, work layer0.Work, itemContext *layer0.Context) (interface{}, error) {
+	result, err := fe.core.ExecuteWorkWithContext(ctx, work, itemContext)
+	if err != nil {
+		return nil, err
+	}
+
+	switch result.Status {
+	case layer0.WorkStatusCompleted:
+		return result.Output, nil
+	case layer0.WorkStatusFailed:
+		return nil, errors.New(result.Error)
+	default:
+		return nil, fmt.Errorf("work %s is %s; asynchronous items are not supported", work.GetID(), result.Status)
+	}
+}
+
+// forEachItemWork derives the work for one item from the spec's template
+func forEachItemWork(parent, template layer0.Work, index int) layer0.Work {
+	work := template.Clone()
+	work.ID = layer0.WorkID(fmt.Sprintf("%s[%d]", parent.GetID(), index))
+	work.Status = layer0.WorkStatusPending
+	if work.Metadata.Properties == nil {
+		work.Metadata.Properties = make(map[string]string)
+	}
+	if instanceID, exists := parent.GetMetadata().Properties[WorkPropertyInstanceID]; exists {
+		work.Metadata.Properties[WorkPropertyInstanceID] = instanceID
+	}
+	return work
+}
+
+// forEachItems reads the array under key from the context
+func forEachItems(workContext *layer0.Context, key string) ([]interface{}, error) {
+	if workContext == nil {
+		return nil, fmt.Errorf("context key %s not found", key)
+	}
+
+	value, exists := workContext.Get(key)
+	if !exists {
+		return nil, fmt.Errorf("context key %s not found", key)
+	}
+	if items, ok := value.([]interface{}); ok {
+		return items, nil
+	}
+
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() != reflect.Slice && reflected.Kind() != reflect.Array {
+		return nil, fmt.Errorf("context key %s holds %T, not an array", key, value)
+	}
+
+	items := make([]interface{}, reflected.Len())
+	for index := range items {
+		items[index] = reflected.Index(index).Interface()
+	}
+	return items, nil
+}
+
+// CanExecute checks if the executor can execute the given work type
+func (fe *ForEachExecutor) CanExecute(workType layer0.WorkType) bool {
+	return workType == layer0.WorkTypeForEach
+}
+
+// GetSupportedTypes returns the supported work types
+func (fe *ForEachExecutor) GetSupportedTypes() []layer0.WorkType {
+	return []layer0.WorkType{layer0.WorkTypeForEach}
+}
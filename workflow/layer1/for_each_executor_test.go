@@ -0,0 +1,107 @@
+package layer1
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+func newForEachWork(spec ForEachSpec) layer0.Work {
+	work := layer0.NewWork("price-lines", layer0.WorkTypeForEach, "Price Lines")
+	work.Configuration.Parameters = map[string]interface{}{ForEachParameter: spec}
+	return work
+}
+
+func TestForEachExecutorExecute(t *testing.T) {
+	core := NewWorkExecutionCore()
+	core.RegisterExecutor(layer0.WorkTypeTask, NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		item, _ := c.Get("line")
+		index, _ := c.Get("line_index")
+		return fmt.Sprintf("%v-%v", item, index), nil
+	}))
+	executor := NewForEachExecutor(core)
+	core.RegisterExecutor(layer0.WorkTypeForEach, executor)
+
+	if !executor.CanExecute(layer0.WorkTypeForEach) {
+		t.Error("ForEachExecutor should execute for-each work")
+	}
+
+	work := newForEachWork(ForEachSpec{
+		ItemsKey: "lines",
+		ItemKey:  "line",
+		Work:     layer0.NewWork("price", layer0.WorkTypeTask, "Price"),
+	})
+	context := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Ctx").Set("lines", []string{"a", "b", "c"})
+
+	result, err := core.ExecuteWork(work, context)
+	if err != nil || result.Status != layer0.WorkStatusCompleted {
+		t.Fatalf("ExecuteWork should complete, got %s (%v): %s", result.Status, err, result.Error)
+	}
+
+	expected := []ForEachItemResult{
+		{Index: 0, Output: "a-0"},
+		{Index: 1, Output: "b-1"},
+		{Index: 2, Output: "c-2"},
+	}
+	if !reflect.DeepEqual(result.Output, expected) {
+		t.Errorf("Expected results %v in order, got %v", expected, result.Output)
+	}
+
+	if _, err := executor.Execute(newForEachWork(ForEachSpec{ItemsKey: "missing", Work: layer0.NewWork("price", layer0.WorkTypeTask, "Price")}), context); err == nil {
+		t.Error("Execute should fail when the items key is missing")
+	}
+	if _, err := executor.Execute(layer0.NewWork("bare", layer0.WorkTypeForEach, "Bare"), context); err == nil {
+		t.Error("Execute should fail without a spec")
+	}
+}
+
+func TestForEachExecutorErrorPolicy(t *testing.T) {
+	var started int32
+	core := NewWorkExecutionCore()
+	core.RegisterExecutor(layer0.WorkTypeTask, NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		atomic.AddInt32(&started, 1)
+		item, _ := c.Get("item")
+		if item == 1 {
+			return nil, fmt.Errorf("item rejected")
+		}
+		return item, nil
+	}))
+	executor := NewForEachExecutor(core)
+	context := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Ctx").Set("numbers", []interface{}{0, 1, 2, 3, 4})
+
+	// Fail fast fails the work with the failing item's error
+	_, err := executor.Execute(newForEachWork(ForEachSpec{
+		ItemsKey:    "numbers",
+		Work:        layer0.NewWork("check", layer0.WorkTypeTask, "Check"),
+		Concurrency: 1,
+	}), context)
+	if err == nil || !strings.Contains(err.Error(), "item 1: item rejected") {
+		t.Errorf("Expected the failing item to fail the work, got %v", err)
+	}
+
+	// Collecting errors runs every item and reports the failure in its result
+	atomic.StoreInt32(&started, 0)
+	output, err := executor.Execute(newForEachWork(ForEachSpec{
+		ItemsKey:    "numbers",
+		Work:        layer0.NewWork("check", layer0.WorkTypeTask, "Check"),
+		ErrorPolicy: ForEachCollectErrors,
+	}), context)
+	if err != nil {
+		t.Fatalf("Execute should not return error when collecting errors: %v", err)
+	}
+	results := output.([]ForEachItemResult)
+	if len(results) != 5 || atomic.LoadInt32(&started) != 5 {
+		t.Fatalf("Expected all 5 items to run, got %d results and %d runs", len(results), started)
+	}
+	if results[1].Error != "item rejected" || results[2].Output != 2 {
+		t.Errorf("Expected item 1 to fail and item 2 to succeed, got %v", results)
+	}
+
+	if err := (ForEachSpec{ItemsKey: "numbers", Work: layer0.NewWork("check", layer0.WorkTypeTask, "Check"), ErrorPolicy: "retry"}).Validate(); err == nil {
+		t.Error("Validate should reject unknown error policies")
+	}
+}
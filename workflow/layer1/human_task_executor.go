@@ -0,0 +1,74 @@
+package layer1
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// Work parameters read by human task work
+const (
+	// HumanTaskExpiryParameter bounds how long the task's completion token is valid,
+	// as a Go duration string, a time.Duration, or a number of seconds
+	HumanTaskExpiryParameter = "expires_in"
+	// HumanTaskOutcomesParameter lists the outcomes the task may be completed with
+	HumanTaskOutcomesParameter = "outcomes"
+)
+
+// HumanTaskExecutor executes human task work by blocking it until an approver
+// completes it with the token the engine issues
+type HumanTaskExecutor struct{}
+
+// NewHumanTaskExecutor creates a new human task executor
+func NewHumanTaskExecutor() *HumanTaskExecutor {
+	return &HumanTaskExecutor{}
+}
+
+// Execute checks the task's parameters and blocks the work
+func (hte *HumanTaskExecutor) Execute(work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	if _, _, err := HumanTaskExpiry(work); err != nil {
+		return nil, err
+	}
+	if _, err := HumanTaskOutcomes(work); err != nil {
+		return nil, err
+	}
+	return nil, ErrWorkBlocked
+}
+
+// CanExecute checks if the executor can execute the given work type
+func (hte *HumanTaskExecutor) CanExecute(workType layer0.WorkType) bool {
+	return workType == layer0.WorkTypeHumanTask
+}
+
+// GetSupportedTypes returns the supported work types
+func (hte *HumanTaskExecutor) GetSupportedTypes() []layer0.WorkType {
+	return []layer0.WorkType{layer0.WorkTypeHumanTask}
+}
+
+// HumanTaskExpiry reads how long a human task's completion token is valid and whether it expires
+func HumanTaskExpiry(work layer0.Work) (time.Duration, bool, error) {
+	return durationParameter(work, HumanTaskExpiryParameter, "human task")
+}
+
+// HumanTaskOutcomes reads the outcomes a human task may be completed with; nil allows any outcome
+func HumanTaskOutcomes(work layer0.Work) ([]string, error) {
+	switch outcomes := work.GetConfiguration().Parameters[HumanTaskOutcomesParameter].(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return outcomes, nil
+	case []interface{}:
+		names := make([]string, 0, len(outcomes))
+		for _, outcome := range outcomes {
+			name, ok := outcome.(string)
+			if !ok {
+				return nil, fmt.Errorf("human task work %s outcomes must be strings, got %T", work.GetID(), outcome)
+			}
+			names = append(names, name)
+		}
+		return names, nil
+	default:
+		return nil, fmt.Errorf("human task work %s outcomes must be a list of strings, got %T", work.GetID(), outcomes)
+	}
+}
@@ -0,0 +1,37 @@
+package layer1
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+func TestHumanTaskExecutor(t *testing.T) {
+	executor := NewHumanTaskExecutor()
+	if !executor.CanExecute(layer0.WorkTypeHumanTask) || executor.CanExecute(layer0.WorkTypeHuman) {
+		t.Error("HumanTaskExecutor should only execute human task work")
+	}
+
+	work := layer0.NewWork("approve", layer0.WorkTypeHumanTask, "Approve")
+	work.Configuration.Parameters[HumanTaskExpiryParameter] = "1h"
+	work.Configuration.Parameters[HumanTaskOutcomesParameter] = []interface{}{"approved", "rejected"}
+
+	if _, err := executor.Execute(work, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context")); !errors.Is(err, ErrWorkBlocked) {
+		t.Errorf("Expected human task work to block, got %v", err)
+	}
+
+	if expiry, expires, err := HumanTaskExpiry(work); err != nil || !expires || expiry != time.Hour {
+		t.Errorf("Expected a one hour expiry, got %v %v (%v)", expiry, expires, err)
+	}
+	if outcomes, err := HumanTaskOutcomes(work); err != nil || !reflect.DeepEqual(outcomes, []string{"approved", "rejected"}) {
+		t.Errorf("Expected the declared outcomes, got %v (%v)", outcomes, err)
+	}
+
+	work.Configuration.Parameters[HumanTaskOutcomesParameter] = []interface{}{"approved", 2}
+	if _, err := executor.Execute(work, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context")); err == nil || errors.Is(err, ErrWorkBlocked) {
+		t.Errorf("Expected an outcomes error, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package layer1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// ErrLockedElsewhere indicates the work's lock is held by another owner
+var ErrLockedElsewhere = errors.New("work locked elsewhere")
+
+// DistributedLock defines the interface for locks shared between engine replicas.
+// A lock expires after its TTL so a crashed owner cannot hold it forever.
+type DistributedLock interface {
+	Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	Release(ctx context.Context, key, owner string) error
+}
+
+// lockEntry records the owner and expiry of a held lock
+type lockEntry struct {
+	owner     string
+	expiresAt time.Time
+}
+
+// InMemoryDistributedLock provides a DistributedLock for a single process
+type InMemoryDistributedLock struct {
+	locks map[string]lockEntry
+	now   func() time.Time
+	mutex sync.Mutex
+}
+
+// NewInMemoryDistributedLock creates a new in-memory distributed lock
+func NewInMemoryDistributedLock() *InMemoryDistributedLock {
+	return &InMemoryDistributedLock{
+		locks: make(map[string]lockEntry),
+		now:   time.Now,
+		mutex: sync.Mutex{},
+	}
+}
+
+// Acquire takes the lock for owner unless another owner holds an unexpired lock.
+// Acquiring a lock the owner already holds extends it.
+func (lock *InMemoryDistributedLock) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return false, fmt.Errorf("lock TTL must be positive")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+
+	now := lock.now()
+	if entry, held := lock.locks[key]; held && entry.owner != owner && now.Before(entry.expiresAt) {
+		return false, nil
+	}
+
+	lock.locks[key] = lockEntry{owner: owner, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+// Release frees the lock if owner holds it
+func (lock *InMemoryDistributedLock) Release(ctx context.Context, key, owner string) error {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+
+	entry, held := lock.locks[key]
+	if !held {
+		return nil
+	}
+
+	if entry.owner != owner {
+		return fmt.Errorf("lock %s is held by %s, not %s", key, entry.owner, owner)
+	}
+
+	delete(lock.locks, key)
+	return nil
+}
+
+// LockingExecutor runs work of an inner executor while holding a distributed lock
+// keyed by the work, so only one replica executes a given work at a time
+type LockingExecutor struct {
+	lock  DistributedLock
+	inner WorkExecutor
+	owner string
+	ttl   time.Duration
+}
+
+// NewLockingExecutor creates a new locking executor. The owner identifies this
+// replica and ttl bounds how long a lock outlives a crashed owner.
+func NewLockingExecutor(lock DistributedLock, inner WorkExecutor, owner string, ttl time.Duration) *LockingExecutor {
+	return &LockingExecutor{
+		lock:  lock,
+		inner: inner,
+		owner: owner,
+		ttl:   ttl,
+	}
+}
+
+// Execute executes the work without a deadline on lock acquisition
+func (le *LockingExecutor) Execute(work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	return le.ExecuteWithContext(context.Background(), work, workContext)
+}
+
+// ExecuteWithContext acquires the work's lock, runs the inner executor and releases the lock.
+// If another owner holds the lock the work fails with ErrLockedElsewhere.
+func (le *LockingExecutor) ExecuteWithContext(ctx context.Context, work layer0.Work, context *layer0.Context) (interface{}, error) {
+	key := WorkLockKey(work)
+
+	acquired, err := le.lock.Acquire(ctx, key, le.owner, le.ttl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lock %s: %w", key, err)
+	}
+
+	if !acquired {
+		return nil, fmt.Errorf("work %s: %w", work.GetID(), ErrLockedElsewhere)
+	}
+	defer le.lock.Release(ctx, key, le.owner)
+
+	return executeWithContext(ctx, le.inner, work, context)
+}
+
+// CanExecute checks if the inner executor can execute the given work type
+func (le *LockingExecutor) CanExecute(workType layer0.WorkType) bool {
+	return le.inner.CanExecute(workType)
+}
+
+// GetSupportedTypes returns the inner executor's supported work types
+func (le *LockingExecutor) GetSupportedTypes() []layer0.WorkType {
+	return le.inner.GetSupportedTypes()
+}
+
+// WorkLockKey returns the lock key for work, scoped to its workflow instance when known
+func WorkLockKey(work layer0.Work) string {
+	if instanceID := work.GetMetadata().Properties[WorkPropertyInstanceID]; instanceID != "" {
+		return fmt.Sprintf("work:%s:%s", instanceID, work.GetID())
+	}
+	return fmt.Sprintf("work:%s", work.GetID())
+}
@@ -0,0 +1,95 @@
+package layer1
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+func TestInMemoryDistributedLock(t *testing.T) {
+	lock := NewInMemoryDistributedLock()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lock.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if acquired, err := lock.Acquire(ctx, "work:a", "replica-1", time.Minute); err != nil || !acquired {
+		t.Fatalf("First acquire should succeed, got %v (%v)", acquired, err)
+	}
+
+	// A held lock blocks a second acquirer
+	if acquired, _ := lock.Acquire(ctx, "work:a", "replica-2", time.Minute); acquired {
+		t.Error("Second owner should not acquire a held lock")
+	}
+
+	if err := lock.Release(ctx, "work:a", "replica-2"); err == nil {
+		t.Error("Releasing a lock held by another owner should return error")
+	}
+
+	// An expired lock can be taken over
+	now = now.Add(2 * time.Minute)
+	if acquired, _ := lock.Acquire(ctx, "work:a", "replica-2", time.Minute); !acquired {
+		t.Error("Expired lock should be acquirable by another owner")
+	}
+
+	if err := lock.Release(ctx, "work:a", "replica-2"); err != nil {
+		t.Errorf("Release should not return error: %v", err)
+	}
+
+	if acquired, _ := lock.Acquire(ctx, "work:a", "replica-1", time.Minute); !acquired {
+		t.Error("Released lock should be acquirable")
+	}
+
+	if _, err := lock.Acquire(ctx, "work:b", "replica-1", 0); err == nil {
+		t.Error("Acquire with non-positive TTL should return error")
+	}
+}
+
+func TestLockingExecutor(t *testing.T) {
+	lock := NewInMemoryDistributedLock()
+	executions := 0
+	inner := NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		executions++
+		return "done", nil
+	})
+
+	first := NewLockingExecutor(lock, inner, "replica-1", time.Minute)
+	second := NewLockingExecutor(lock, inner, "replica-2", time.Minute)
+
+	if !first.CanExecute(layer0.WorkTypeTask) {
+		t.Error("LockingExecutor should support the inner executor's work types")
+	}
+
+	work := layer0.NewWork("charge", layer0.WorkTypeTask, "Charge")
+	work.Metadata.Properties[WorkPropertyInstanceID] = "instance-1"
+	workContext := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context")
+
+	// Another replica holds the lock for this work
+	if acquired, _ := lock.Acquire(context.Background(), WorkLockKey(work), "replica-1", time.Minute); !acquired {
+		t.Fatal("Setup acquire should succeed")
+	}
+
+	if _, err := second.Execute(work, workContext); !errors.Is(err, ErrLockedElsewhere) {
+		t.Errorf("Expected locked elsewhere error, got %v", err)
+	}
+
+	if executions != 0 {
+		t.Errorf("Work should not run while locked elsewhere, ran %d times", executions)
+	}
+
+	// The holder runs the work and releases the lock afterwards
+	output, err := first.Execute(work, workContext)
+	if err != nil || output != "done" {
+		t.Fatalf("Expected holder to execute work, got %v (%v)", output, err)
+	}
+
+	if _, err := second.Execute(work, workContext); err != nil {
+		t.Errorf("Lock should be released after execution: %v", err)
+	}
+
+	if executions != 2 {
+		t.Errorf("Expected 2 executions, got %d", executions)
+	}
+}
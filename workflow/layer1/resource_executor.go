@@ -0,0 +1,122 @@
+package layer1
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// ResourcePool defines the interface for a bounded pool of shared resources
+type ResourcePool interface {
+	Acquire(ctx context.Context) error
+	Release() error
+	Capacity() int
+	InUse() int
+}
+
+// SemaphoreResourcePool provides an in-process ResourcePool with a fixed capacity
+type SemaphoreResourcePool struct {
+	slots chan struct{}
+	mutex sync.Mutex
+}
+
+// NewSemaphoreResourcePool creates a new resource pool with the given capacity
+func NewSemaphoreResourcePool(capacity int) (*SemaphoreResourcePool, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("resource pool capacity must be positive")
+	}
+
+	return &SemaphoreResourcePool{
+		slots: make(chan struct{}, capacity),
+		mutex: sync.Mutex{},
+	}, nil
+}
+
+// Acquire blocks until a resource is available or the context is done
+func (pool *SemaphoreResourcePool) Acquire(ctx context.Context) error {
+	select {
+	case pool.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to acquire resource: %w", ctx.Err())
+	}
+}
+
+// Release returns a previously acquired resource to the pool
+func (pool *SemaphoreResourcePool) Release() error {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	select {
+	case <-pool.slots:
+		return nil
+	default:
+		return fmt.Errorf("no resource is currently acquired")
+	}
+}
+
+// Capacity returns the maximum number of resources that can be held at once
+func (pool *SemaphoreResourcePool) Capacity() int {
+	return cap(pool.slots)
+}
+
+// InUse returns the number of resources currently held
+func (pool *SemaphoreResourcePool) InUse() int {
+	return len(pool.slots)
+}
+
+// ResourceExecutor executes resource work by holding a pooled resource for the
+// duration of the step. If an inner executor is configured it runs while the
+// resource is held; the resource is released when the step completes.
+type ResourceExecutor struct {
+	pool  ResourcePool
+	inner WorkExecutor
+}
+
+// NewResourceExecutor creates a new resource executor over the given pool
+func NewResourceExecutor(pool ResourcePool, inner WorkExecutor) *ResourceExecutor {
+	return &ResourceExecutor{
+		pool:  pool,
+		inner: inner,
+	}
+}
+
+// Execute executes the work without a deadline on resource acquisition
+func (re *ResourceExecutor) Execute(work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	return re.ExecuteWithContext(context.Background(), work, workContext)
+}
+
+// ExecuteWithContext acquires a resource, runs the inner executor and releases the resource
+func (re *ResourceExecutor) ExecuteWithContext(ctx context.Context, work layer0.Work, context *layer0.Context) (interface{}, error) {
+	if err := re.pool.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer re.pool.Release()
+
+	if re.inner == nil {
+		return map[string]interface{}{"acquired": true}, nil
+	}
+
+	return executeWithContext(ctx, re.inner, work, context)
+}
+
+// CanExecute checks if the executor can execute the given work type
+func (re *ResourceExecutor) CanExecute(workType layer0.WorkType) bool {
+	return workType == layer0.WorkTypeResource
+}
+
+// GetSupportedTypes returns the supported work types
+func (re *ResourceExecutor) GetSupportedTypes() []layer0.WorkType {
+	return []layer0.WorkType{layer0.WorkTypeResource}
+}
+
+// Utilization returns the fraction of the pool currently in use
+func (re *ResourceExecutor) Utilization() float64 {
+	capacity := re.pool.Capacity()
+	if capacity == 0 {
+		return 0
+	}
+	return float64(re.pool.InUse()) / float64(capacity)
+}
@@ -0,0 +1,136 @@
+package layer1
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+func TestSemaphoreResourcePool(t *testing.T) {
+	if _, err := NewSemaphoreResourcePool(0); err == nil {
+		t.Error("NewSemaphoreResourcePool should return error for non-positive capacity")
+	}
+
+	pool, err := NewSemaphoreResourcePool(1)
+	if err != nil {
+		t.Fatalf("NewSemaphoreResourcePool should not return error: %v", err)
+	}
+
+	if err := pool.Release(); err == nil {
+		t.Error("Release should return error when nothing is acquired")
+	}
+
+	if err := pool.Acquire(context.Background()); err != nil {
+		t.Errorf("Acquire should not return error: %v", err)
+	}
+
+	if pool.InUse() != 1 {
+		t.Errorf("Expected 1 resource in use, got %d", pool.InUse())
+	}
+
+	// A second acquire must wait and give up when the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := pool.Acquire(ctx); err == nil {
+		t.Error("Acquire should return error when the pool is exhausted and the context expires")
+	}
+
+	if err := pool.Release(); err != nil {
+		t.Errorf("Release should not return error: %v", err)
+	}
+
+	if pool.InUse() != 0 {
+		t.Errorf("Expected 0 resources in use, got %d", pool.InUse())
+	}
+}
+
+func TestResourceExecutorLimitsConcurrency(t *testing.T) {
+	pool, _ := NewSemaphoreResourcePool(2)
+
+	var mutex sync.Mutex
+	running := 0
+	maxRunning := 0
+	inner := NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeResource}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		time.Sleep(30 * time.Millisecond)
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+		return "used", nil
+	})
+
+	executor := NewResourceExecutor(pool, inner)
+	wec := NewWorkExecutionCore()
+	wec.RegisterExecutor(layer0.WorkTypeResource, executor)
+
+	context := layer0.NewContext("test-context", layer0.ContextScopeWork, "Test Context")
+
+	// Run more works than the pool capacity
+	var wg sync.WaitGroup
+	results := make([]WorkExecutionResult, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			work := layer0.NewWork(layer0.WorkID("resource-work-"+string(rune('a'+i))), layer0.WorkTypeResource, "Resource Work")
+			results[i], _ = wec.ExecuteWork(work, context)
+		}(i)
+	}
+
+	// While the first works hold the pool, the rest must wait
+	time.Sleep(10 * time.Millisecond)
+	if executor.Utilization() != 1.0 {
+		t.Errorf("Expected full utilization while works are waiting, got %f", executor.Utilization())
+	}
+
+	wg.Wait()
+
+	if maxRunning != 2 {
+		t.Errorf("Expected at most 2 concurrent works, got %d", maxRunning)
+	}
+
+	for _, result := range results {
+		if result.Status != layer0.WorkStatusCompleted {
+			t.Errorf("Expected status %s, got %s", layer0.WorkStatusCompleted, result.Status)
+		}
+	}
+
+	if executor.Utilization() != 0 {
+		t.Errorf("Expected no utilization after all works complete, got %f", executor.Utilization())
+	}
+}
+
+func TestResourceExecutorAcquireCancelled(t *testing.T) {
+	pool, _ := NewSemaphoreResourcePool(1)
+	pool.Acquire(context.Background())
+
+	executor := NewResourceExecutor(pool, nil)
+	wec := NewWorkExecutionCore()
+	wec.RegisterExecutor(layer0.WorkTypeResource, executor)
+
+	work := layer0.NewWork("resource-work", layer0.WorkTypeResource, "Resource Work")
+	workContext := layer0.NewContext("test-context", layer0.ContextScopeWork, "Test Context")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	result, err := wec.ExecuteWorkWithContext(ctx, work, workContext)
+	if err != nil {
+		t.Errorf("ExecuteWorkWithContext should not return error: %v", err)
+	}
+
+	if result.Status != layer0.WorkStatusFailed {
+		t.Errorf("Expected status %s when the resource cannot be acquired, got %s", layer0.WorkStatusFailed, result.Status)
+	}
+}
@@ -0,0 +1,231 @@
+package layer1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TransformOperation defines how a transform rule reshapes work output
+type TransformOperation string
+
+const (
+	// TransformRename moves the value at From to the key To
+	TransformRename TransformOperation = "rename"
+	// TransformPick keeps only the listed Keys
+	TransformPick TransformOperation = "pick"
+	// TransformExtract reads the value at a JSONPath such as $.data.items[0].id and
+	// stores it under To, or replaces the whole output when To is empty
+	TransformExtract TransformOperation = "extract"
+)
+
+// TransformRule is a single step of a result transformer
+type TransformRule struct {
+	Operation TransformOperation `json:"operation"`
+	From      string             `json:"from,omitempty"`
+	To        string             `json:"to,omitempty"`
+	Keys      []string           `json:"keys,omitempty"`
+	Path      string             `json:"path,omitempty"`
+}
+
+// ResultTransformer reshapes work output by applying its rules in order
+type ResultTransformer []TransformRule
+
+// Validate checks that every rule has the fields its operation needs
+func (transformer ResultTransformer) Validate() error {
+	for index, rule := range transformer {
+		switch rule.Operation {
+		case TransformRename:
+			if rule.From == "" || rule.To == "" {
+				return fmt.Errorf("rule %d: rename requires from and to", index)
+			}
+		case TransformPick:
+			if len(rule.Keys) == 0 {
+				return fmt.Errorf("rule %d: pick requires keys", index)
+			}
+		case TransformExtract:
+			if _, err := parseJSONPath(rule.Path); err != nil {
+				return fmt.Errorf("rule %d: %w", index, err)
+			}
+		default:
+			return fmt.Errorf("rule %d: unknown transform operation: %s", index, rule.Operation)
+		}
+	}
+	return nil
+}
+
+// Apply runs the rules against output and returns the reshaped output.
+// Output that is not a map is first converted to its JSON representation.
+func (transformer ResultTransformer) Apply(output interface{}) (interface{}, error) {
+	if len(transformer) == 0 {
+		return output, nil
+	}
+
+	if err := transformer.Validate(); err != nil {
+		return nil, err
+	}
+
+	current, err := normalizeOutput(output)
+	if err != nil {
+		return nil, err
+	}
+
+	for index, rule := range transformer {
+		fields, isMap := current.(map[string]interface{})
+
+		switch rule.Operation {
+		case TransformRename:
+			if !isMap {
+				return nil, fmt.Errorf("rule %d: rename requires object output", index)
+			}
+			value, exists := fields[rule.From]
+			if !exists {
+				return nil, fmt.Errorf("rule %d: output has no key %s", index, rule.From)
+			}
+			renamed := copyFields(fields)
+			delete(renamed, rule.From)
+			renamed[rule.To] = value
+			current = renamed
+
+		case TransformPick:
+			if !isMap {
+				return nil, fmt.Errorf("rule %d: pick requires object output", index)
+			}
+			picked := make(map[string]interface{}, len(rule.Keys))
+			for _, key := range rule.Keys {
+				if value, exists := fields[key]; exists {
+					picked[key] = value
+				}
+			}
+			current = picked
+
+		case TransformExtract:
+			value, err := extractJSONPath(current, rule.Path)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %w", index, err)
+			}
+			if rule.To == "" {
+				current = value
+				continue
+			}
+			if !isMap {
+				return nil, fmt.Errorf("rule %d: extract into %s requires object output", index, rule.To)
+			}
+			extracted := copyFields(fields)
+			extracted[rule.To] = value
+			current = extracted
+		}
+	}
+
+	return current, nil
+}
+
+// normalizeOutput converts output into maps, slices and scalars
+func normalizeOutput(output interface{}) (interface{}, error) {
+	switch output.(type) {
+	case nil, map[string]interface{}, []interface{}, string, bool, float64:
+		return output, nil
+	}
+
+	data, err := json.Marshal(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert output for transformation: %w", err)
+	}
+
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, fmt.Errorf("failed to convert output for transformation: %w", err)
+	}
+	return normalized, nil
+}
+
+// copyFields returns a shallow copy of fields
+func copyFields(fields map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		copied[key] = value
+	}
+	return copied
+}
+
+// jsonPathStep is one key or index of a parsed JSONPath
+type jsonPathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseJSONPath parses the dot and bracket index subset of JSONPath, such as $.items[0].id
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath %q must start with $", path)
+	}
+
+	var steps []jsonPathStep
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("JSONPath %q has an empty key", path)
+			}
+			steps = append(steps, jsonPathStep{key: key})
+			rest = rest[end+1:]
+
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an unclosed index", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an invalid index %q", path, rest[1:end])
+			}
+			steps = append(steps, jsonPathStep{index: index, isIndex: true})
+			rest = rest[end+1:]
+
+		default:
+			return nil, fmt.Errorf("JSONPath %q is malformed at %q", path, rest)
+		}
+	}
+
+	return steps, nil
+}
+
+// extractJSONPath returns the value at path within value
+func extractJSONPath(value interface{}, path string) (interface{}, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	current := value
+	for _, step := range steps {
+		if step.isIndex {
+			items, isSlice := current.([]interface{})
+			if !isSlice || step.index >= len(items) {
+				return nil, fmt.Errorf("JSONPath %s: index %d not found", path, step.index)
+			}
+			current = items[step.index]
+			continue
+		}
+
+		fields, isMap := current.(map[string]interface{})
+		if !isMap {
+			return nil, fmt.Errorf("JSONPath %s: key %s not found", path, step.key)
+		}
+		next, exists := fields[step.key]
+		if !exists {
+			return nil, fmt.Errorf("JSONPath %s: key %s not found", path, step.key)
+		}
+		current = next
+	}
+
+	return current, nil
+}
@@ -0,0 +1,79 @@
+package layer1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResultTransformerApply(t *testing.T) {
+	output := map[string]interface{}{
+		"customer_name": "Ada",
+		"internal_id":   7,
+		"data": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"sku": "A-1"},
+			},
+		},
+	}
+
+	transformer := ResultTransformer{
+		{Operation: TransformRename, From: "customer_name", To: "name"},
+		{Operation: TransformExtract, Path: "$.data.items[0].sku", To: "sku"},
+		{Operation: TransformPick, Keys: []string{"name", "sku"}},
+	}
+
+	transformed, err := transformer.Apply(output)
+	if err != nil {
+		t.Fatalf("Apply should not return error: %v", err)
+	}
+
+	expected := map[string]interface{}{"name": "Ada", "sku": "A-1"}
+	if !reflect.DeepEqual(transformed, expected) {
+		t.Errorf("Expected %v, got %v", expected, transformed)
+	}
+
+	// The source output is not modified
+	if _, exists := output["customer_name"]; !exists {
+		t.Error("Apply should not modify the original output")
+	}
+}
+
+func TestResultTransformerExtractReplacesOutput(t *testing.T) {
+	type response struct {
+		Total int `json:"total"`
+	}
+
+	transformed, err := ResultTransformer{{Operation: TransformExtract, Path: "$.total"}}.Apply(response{Total: 3})
+	if err != nil {
+		t.Fatalf("Apply should not return error: %v", err)
+	}
+	if transformed != float64(3) {
+		t.Errorf("Expected the extracted total to replace the output, got %v", transformed)
+	}
+}
+
+func TestResultTransformerErrors(t *testing.T) {
+	invalid := []ResultTransformer{
+		{{Operation: TransformRename, From: "a"}},
+		{{Operation: TransformPick}},
+		{{Operation: TransformExtract, Path: "items[0]"}},
+		{{Operation: TransformExtract, Path: "$.items[x]"}},
+		{{Operation: "flatten"}},
+	}
+	for _, transformer := range invalid {
+		if err := transformer.Validate(); err == nil {
+			t.Errorf("Expected %v to be invalid", transformer)
+		}
+	}
+
+	output := map[string]interface{}{"items": []interface{}{}}
+	if _, err := (ResultTransformer{{Operation: TransformRename, From: "missing", To: "b"}}).Apply(output); err == nil {
+		t.Error("Renaming a missing key should return error")
+	}
+	if _, err := (ResultTransformer{{Operation: TransformExtract, Path: "$.items[0]"}}).Apply(output); err == nil {
+		t.Error("Extracting a missing index should return error")
+	}
+	if _, err := (ResultTransformer{{Operation: TransformPick, Keys: []string{"a"}}}).Apply("text"); err == nil {
+		t.Error("Picking from scalar output should return error")
+	}
+}
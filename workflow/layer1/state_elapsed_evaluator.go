@@ -0,0 +1,76 @@
+package layer1
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// StateEnteredAtKey is the context key holding when the instance entered its current
+// state. The engine sets it on the context it evaluates transition conditions with.
+const StateEnteredAtKey = "state_entered_at"
+
+// StateElapsedThresholdVariable is the condition expression variable holding the
+// threshold, as a duration string such as "10m" or a number of seconds
+const StateElapsedThresholdVariable = "threshold"
+
+// StateElapsedEvaluator evaluates time conditions that hold once the instance has
+// been in its current state for at least the condition's threshold
+type StateElapsedEvaluator struct {
+	now func() time.Time
+}
+
+// NewStateElapsedEvaluator creates an evaluator that measures elapsed time with now
+func NewStateElapsedEvaluator(now func() time.Time) *StateElapsedEvaluator {
+	return &StateElapsedEvaluator{now: now}
+}
+
+// Evaluate reports whether the time since the state was entered has reached the threshold
+func (see *StateElapsedEvaluator) Evaluate(condition layer0.Condition, context *layer0.Context) (interface{}, error) {
+	threshold, err := stateElapsedThreshold(condition)
+	if err != nil {
+		return nil, err
+	}
+
+	value, exists := context.Get(StateEnteredAtKey)
+	if !exists {
+		return nil, fmt.Errorf("condition %s: context has no %s", condition.GetID(), StateEnteredAtKey)
+	}
+	enteredAt, ok := value.(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("condition %s: %s is %T, not a time", condition.GetID(), StateEnteredAtKey, value)
+	}
+
+	return see.now().Sub(enteredAt) >= threshold, nil
+}
+
+// CanEvaluate checks if the evaluator can evaluate the given condition type
+func (see *StateElapsedEvaluator) CanEvaluate(conditionType layer0.ConditionType) bool {
+	return conditionType == layer0.ConditionTypeTime
+}
+
+// GetSupportedTypes returns the supported condition types
+func (see *StateElapsedEvaluator) GetSupportedTypes() []layer0.ConditionType {
+	return []layer0.ConditionType{layer0.ConditionTypeTime}
+}
+
+// stateElapsedThreshold reads the threshold variable of a condition
+func stateElapsedThreshold(condition layer0.Condition) (time.Duration, error) {
+	switch threshold := condition.GetExpression().Variables[StateElapsedThresholdVariable].(type) {
+	case string:
+		duration, err := time.ParseDuration(threshold)
+		if err != nil {
+			return 0, fmt.Errorf("condition %s: invalid threshold: %w", condition.GetID(), err)
+		}
+		return duration, nil
+	case float64:
+		return time.Duration(threshold * float64(time.Second)), nil
+	case int:
+		return time.Duration(threshold) * time.Second, nil
+	case nil:
+		return 0, fmt.Errorf("condition %s: missing %s variable", condition.GetID(), StateElapsedThresholdVariable)
+	default:
+		return 0, fmt.Errorf("condition %s: threshold must be a duration or a number of seconds, got %T", condition.GetID(), threshold)
+	}
+}
@@ -0,0 +1,48 @@
+package layer1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+func TestStateElapsedEvaluator(t *testing.T) {
+	enteredAt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	now := enteredAt.Add(5 * time.Minute)
+	evaluator := NewStateElapsedEvaluator(func() time.Time { return now })
+	context := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Ctx").Set(StateEnteredAtKey, enteredAt)
+
+	condition := layer0.NewCondition("sla-breached", layer0.ConditionTypeTime, "SLA Breached")
+	condition.Expression.Variables[StateElapsedThresholdVariable] = "10m"
+
+	if result, err := evaluator.Evaluate(condition, context); err != nil || result != false {
+		t.Errorf("Expected the condition to be false after 5 minutes, got %v (%v)", result, err)
+	}
+
+	now = enteredAt.Add(10 * time.Minute)
+	if result, err := evaluator.Evaluate(condition, context); err != nil || result != true {
+		t.Errorf("Expected the condition to be true at the threshold, got %v (%v)", result, err)
+	}
+
+	// Thresholds may also be given in seconds
+	condition.Expression.Variables[StateElapsedThresholdVariable] = float64(900)
+	if result, err := evaluator.Evaluate(condition, context); err != nil || result != false {
+		t.Errorf("Expected a 900 second threshold to be false after 10 minutes, got %v (%v)", result, err)
+	}
+
+	condition.Expression.Variables[StateElapsedThresholdVariable] = "soon"
+	if _, err := evaluator.Evaluate(condition, context); err == nil {
+		t.Error("Expected an invalid threshold to return error")
+	}
+
+	delete(condition.Expression.Variables, StateElapsedThresholdVariable)
+	if _, err := evaluator.Evaluate(condition, context); err == nil {
+		t.Error("Expected a missing threshold to return error")
+	}
+
+	condition.Expression.Variables[StateElapsedThresholdVariable] = "10m"
+	if _, err := evaluator.Evaluate(condition, layer0.NewContext("empty", layer0.ContextScopeWorkflow, "Empty")); err == nil {
+		t.Error("Expected a context without the entry time to return error")
+	}
+}
@@ -1,7 +1,10 @@
 package layer1
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/ubom/workflow/layer0"
@@ -12,6 +15,11 @@ type StateMachineCore struct {
 	states       map[layer0.StateID]layer0.State
 	transitions  map[layer0.TransitionID]layer0.Transition
 	currentState *layer0.StateID
+	compiled     bool
+	fromIndex    map[layer0.StateID][]layer0.Transition
+	toIndex      map[layer0.StateID][]layer0.Transition
+	finalIndex   map[layer0.StateID]int
+	strictEdges  bool
 	mutex        sync.RWMutex
 }
 
@@ -22,6 +30,7 @@ type StateMachineCoreInterface interface {
 	GetState(stateID layer0.StateID) (layer0.State, error)
 	GetAllStates() []layer0.State
 	AddTransition(transition layer0.Transition) error
+	SetStrictEdges(strict bool)
 	RemoveTransition(transitionID layer0.TransitionID) error
 	GetTransition(transitionID layer0.TransitionID) (layer0.Transition, error)
 	GetTransitionsFromState(stateID layer0.StateID) []layer0.Transition
@@ -29,8 +38,14 @@ type StateMachineCoreInterface interface {
 	SetCurrentState(stateID layer0.StateID) error
 	GetCurrentState() (*layer0.StateID, error)
 	CanTransition(fromStateID, toStateID layer0.StateID) bool
+	DistanceToFinal(stateID layer0.StateID) (int, bool)
 	GetAvailableTransitions() []layer0.Transition
 	ValidateStateMachine() error
+	ValidateAll() []error
+	Compile()
+	IsCompiled() bool
+	Merge(other *StateMachineCore, prefix string) error
+	MergeWithMapping(other *StateMachineCore, prefix string, mapping map[layer0.StateID]layer0.StateID) error
 }
 
 // NewStateMachineCore creates a new state machine core
@@ -56,6 +71,7 @@ func (smc *StateMachineCore) AddState(state layer0.State) error {
 	}
 
 	smc.states[state.GetID()] = state
+	smc.invalidateUnsafe()
 	return nil
 }
 
@@ -81,6 +97,7 @@ func (smc *StateMachineCore) RemoveState(stateID layer0.StateID) error {
 	}
 
 	delete(smc.states, stateID)
+	smc.invalidateUnsafe()
 	return nil
 }
 
@@ -97,7 +114,7 @@ func (smc *StateMachineCore) GetState(stateID layer0.StateID) (layer0.State, err
 	return state, nil
 }
 
-// GetAllStates returns all states in the state machine
+// GetAllStates returns all states in the state machine ordered by ID
 func (smc *StateMachineCore) GetAllStates() []layer0.State {
 	smc.mutex.RLock()
 	defer smc.mutex.RUnlock()
@@ -106,6 +123,9 @@ func (smc *StateMachineCore) GetAllStates() []layer0.State {
 	for _, state := range smc.states {
 		states = append(states, state)
 	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].GetID() < states[j].GetID()
+	})
 
 	return states
 }
@@ -132,10 +152,60 @@ func (smc *StateMachineCore) AddTransition(transition layer0.Transition) error {
 		return fmt.Errorf("to state %s does not exist", transition.GetToStateID())
 	}
 
+	if policy, exists := transition.GetRecoveryPolicy(); exists && policy.OnExhausted != "" {
+		if _, exists := smc.states[policy.OnExhausted]; !exists {
+			return fmt.Errorf("recovery state %s does not exist", policy.OnExhausted)
+		}
+	}
+
+	if smc.strictEdges {
+		if duplicate, exists := smc.findEquivalentEdgeUnsafe(transition); exists {
+			return fmt.Errorf("transition %s duplicates transition %s from %s to %s with the same conditions",
+				transition.GetID(), duplicate, transition.GetFromStateID(), transition.GetToStateID())
+		}
+	}
+
 	smc.transitions[transition.GetID()] = transition
+	smc.invalidateUnsafe()
 	return nil
 }
 
+// SetStrictEdges makes AddTransition reject a transition when one with the same from
+// and to states, operator and conditions already exists, since the engine could take
+// either. Strict edges are off by default so deliberate parallel edges stay allowed.
+func (smc *StateMachineCore) SetStrictEdges(strict bool) {
+	smc.mutex.Lock()
+	defer smc.mutex.Unlock()
+
+	smc.strictEdges = strict
+}
+
+// findEquivalentEdgeUnsafe returns the ID of a transition equivalent to transition, if any.
+// This method assumes the caller already holds the mutex lock.
+func (smc *StateMachineCore) findEquivalentEdgeUnsafe(transition layer0.Transition) (layer0.TransitionID, bool) {
+	conditions := sortedConditions(transition)
+	for _, existing := range smc.transitions {
+		if existing.GetFromStateID() != transition.GetFromStateID() ||
+			existing.GetToStateID() != transition.GetToStateID() ||
+			existing.GetOperator() != transition.GetOperator() {
+			continue
+		}
+
+		existingConditions := sortedConditions(existing)
+		if strings.Join(existingConditions, "\x00") == strings.Join(conditions, "\x00") {
+			return existing.GetID(), true
+		}
+	}
+	return "", false
+}
+
+// sortedConditions returns the condition IDs of a transition in sorted order
+func sortedConditions(transition layer0.Transition) []string {
+	conditions := append([]string(nil), transition.GetConditions()...)
+	sort.Strings(conditions)
+	return conditions
+}
+
 // RemoveTransition removes a transition from the state machine
 func (smc *StateMachineCore) RemoveTransition(transitionID layer0.TransitionID) error {
 	smc.mutex.Lock()
@@ -146,6 +216,7 @@ func (smc *StateMachineCore) RemoveTransition(transitionID layer0.TransitionID)
 	}
 
 	delete(smc.transitions, transitionID)
+	smc.invalidateUnsafe()
 	return nil
 }
 
@@ -167,14 +238,7 @@ func (smc *StateMachineCore) GetTransitionsFromState(stateID layer0.StateID) []l
 	smc.mutex.RLock()
 	defer smc.mutex.RUnlock()
 
-	var transitions []layer0.Transition
-	for _, transition := range smc.transitions {
-		if transition.GetFromStateID() == stateID {
-			transitions = append(transitions, transition)
-		}
-	}
-
-	return transitions
+	return smc.getTransitionsFromStateUnsafe(stateID)
 }
 
 // GetTransitionsToState returns all transitions to a specific state
@@ -182,6 +246,10 @@ func (smc *StateMachineCore) GetTransitionsToState(stateID layer0.StateID) []lay
 	smc.mutex.RLock()
 	defer smc.mutex.RUnlock()
 
+	if smc.compiled {
+		return copyTransitions(smc.toIndex[stateID])
+	}
+
 	var transitions []layer0.Transition
 	for _, transition := range smc.transitions {
 		if transition.GetToStateID() == stateID {
@@ -222,6 +290,15 @@ func (smc *StateMachineCore) CanTransition(fromStateID, toStateID layer0.StateID
 	smc.mutex.RLock()
 	defer smc.mutex.RUnlock()
 
+	if smc.compiled {
+		for _, transition := range smc.fromIndex[fromStateID] {
+			if transition.GetToStateID() == toStateID {
+				return true
+			}
+		}
+		return false
+	}
+
 	for _, transition := range smc.transitions {
 		if transition.GetFromStateID() == fromStateID && transition.GetToStateID() == toStateID {
 			return true
@@ -245,6 +322,10 @@ func (smc *StateMachineCore) GetAvailableTransitions() []layer0.Transition {
 
 // getTransitionsFromStateUnsafe is an internal method that doesn't acquire locks
 func (smc *StateMachineCore) getTransitionsFromStateUnsafe(stateID layer0.StateID) []layer0.Transition {
+	if smc.compiled {
+		return copyTransitions(smc.fromIndex[stateID])
+	}
+
 	var transitions []layer0.Transition
 	for _, transition := range smc.transitions {
 		if transition.GetFromStateID() == stateID {
@@ -254,15 +335,219 @@ func (smc *StateMachineCore) getTransitionsFromStateUnsafe(stateID layer0.StateI
 	return transitions
 }
 
+// PrefixStateID returns the ID a state receives when merged under the given prefix
+func PrefixStateID(prefix string, stateID layer0.StateID) layer0.StateID {
+	return layer0.StateID(prefix + "." + string(stateID))
+}
+
+// PrefixTransitionID returns the ID a transition receives when merged under the given prefix
+func PrefixTransitionID(prefix string, transitionID layer0.TransitionID) layer0.TransitionID {
+	return layer0.TransitionID(prefix + "." + string(transitionID))
+}
+
+// Merge imports another state machine's states and transitions under an ID prefix
+func (smc *StateMachineCore) Merge(other *StateMachineCore, prefix string) error {
+	return smc.MergeWithMapping(other, prefix, nil)
+}
+
+// MergeWithMapping imports another state machine's states and transitions under
+// an ID prefix. The mapping wires the included graph into this one: each key is
+// a state of the other machine and its value an existing state of this machine
+// that replaces it, so transitions into or out of the mapped state are attached
+// to the parent state instead. The merge is all-or-nothing; any collision after
+// prefixing returns an error and leaves this state machine unchanged.
+func (smc *StateMachineCore) MergeWithMapping(other *StateMachineCore, prefix string, mapping map[layer0.StateID]layer0.StateID) error {
+	if other == nil {
+		return fmt.Errorf("state machine to merge cannot be nil")
+	}
+
+	if other == smc {
+		return fmt.Errorf("cannot merge a state machine into itself")
+	}
+
+	if prefix == "" {
+		return fmt.Errorf("merge prefix cannot be empty")
+	}
+
+	other.mutex.RLock()
+	defer other.mutex.RUnlock()
+
+	smc.mutex.Lock()
+	defer smc.mutex.Unlock()
+
+	// Resolve the ID every state of the other machine receives in this one
+	resolved := make(map[layer0.StateID]layer0.StateID, len(other.states))
+	for stateID := range other.states {
+		if parentStateID, mapped := mapping[stateID]; mapped {
+			if _, exists := smc.states[parentStateID]; !exists {
+				return fmt.Errorf("mapped state %s for %s does not exist", parentStateID, stateID)
+			}
+			resolved[stateID] = parentStateID
+			continue
+		}
+
+		prefixedID := PrefixStateID(prefix, stateID)
+		if _, exists := smc.states[prefixedID]; exists {
+			return fmt.Errorf("state with ID %s already exists", prefixedID)
+		}
+		resolved[stateID] = prefixedID
+	}
+
+	for stateID := range mapping {
+		if _, exists := other.states[stateID]; !exists {
+			return fmt.Errorf("mapped state %s does not exist in the merged state machine", stateID)
+		}
+	}
+
+	for transitionID := range other.transitions {
+		prefixedID := PrefixTransitionID(prefix, transitionID)
+		if _, exists := smc.transitions[prefixedID]; exists {
+			return fmt.Errorf("transition with ID %s already exists", prefixedID)
+		}
+	}
+
+	// All checks passed; import states and transitions
+	for stateID, state := range other.states {
+		if _, mapped := mapping[stateID]; mapped {
+			continue
+		}
+
+		imported := state.Clone()
+		imported.ID = resolved[stateID]
+		smc.states[imported.ID] = imported
+	}
+
+	for transitionID, transition := range other.transitions {
+		imported := transition.Clone()
+		imported.ID = PrefixTransitionID(prefix, transitionID)
+		imported.FromStateID = resolved[transition.GetFromStateID()]
+		imported.ToStateID = resolved[transition.GetToStateID()]
+		if imported.RecoveryPolicy != nil && imported.RecoveryPolicy.OnExhausted != "" {
+			imported.RecoveryPolicy.OnExhausted = resolved[imported.RecoveryPolicy.OnExhausted]
+		}
+		smc.transitions[imported.ID] = imported
+	}
+
+	smc.invalidateUnsafe()
+	return nil
+}
+
+// Compile precomputes from/to transition lookup maps so transition queries no
+// longer scan every transition. The maps are cached until the next mutation;
+// an uncompiled state machine falls back to scanning.
+func (smc *StateMachineCore) Compile() {
+	smc.mutex.Lock()
+	defer smc.mutex.Unlock()
+
+	if smc.compiled {
+		return
+	}
+
+	smc.fromIndex = make(map[layer0.StateID][]layer0.Transition)
+	smc.toIndex = make(map[layer0.StateID][]layer0.Transition)
+	for _, transition := range smc.transitions {
+		smc.fromIndex[transition.GetFromStateID()] = append(smc.fromIndex[transition.GetFromStateID()], transition)
+		smc.toIndex[transition.GetToStateID()] = append(smc.toIndex[transition.GetToStateID()], transition)
+	}
+	smc.finalIndex = smc.finalDistancesUnsafe()
+	smc.compiled = true
+}
+
+// IsCompiled checks if the transition lookup maps are current
+func (smc *StateMachineCore) IsCompiled() bool {
+	smc.mutex.RLock()
+	defer smc.mutex.RUnlock()
+
+	return smc.compiled
+}
+
+// invalidateUnsafe discards the compiled lookup maps; the caller must hold the write lock
+func (smc *StateMachineCore) invalidateUnsafe() {
+	smc.compiled = false
+	smc.fromIndex = nil
+	smc.toIndex = nil
+	smc.finalIndex = nil
+}
+
+// DistanceToFinal returns the fewest transitions from a state to any final state.
+// It reports false when no final state is reachable from the state.
+func (smc *StateMachineCore) DistanceToFinal(stateID layer0.StateID) (int, bool) {
+	smc.mutex.RLock()
+	defer smc.mutex.RUnlock()
+
+	distances := smc.finalIndex
+	if !smc.compiled {
+		distances = smc.finalDistancesUnsafe()
+	}
+
+	distance, reachable := distances[stateID]
+	return distance, reachable
+}
+
+// finalDistancesUnsafe runs a breadth-first search backwards from every final state
+// to find each state's distance to its nearest final state.
+// This method assumes the caller already holds the mutex lock.
+func (smc *StateMachineCore) finalDistancesUnsafe() map[layer0.StateID]int {
+	toIndex := make(map[layer0.StateID][]layer0.Transition)
+	for _, transition := range smc.transitions {
+		toIndex[transition.GetToStateID()] = append(toIndex[transition.GetToStateID()], transition)
+	}
+
+	distances := make(map[layer0.StateID]int)
+	var queue []layer0.StateID
+	for stateID, state := range smc.states {
+		if state.IsFinal() {
+			distances[stateID] = 0
+			queue = append(queue, stateID)
+		}
+	}
+
+	for len(queue) > 0 {
+		stateID := queue[0]
+		queue = queue[1:]
+		for _, transition := range toIndex[stateID] {
+			if _, seen := distances[transition.GetFromStateID()]; !seen {
+				distances[transition.GetFromStateID()] = distances[stateID] + 1
+				queue = append(queue, transition.GetFromStateID())
+			}
+		}
+	}
+
+	return distances
+}
+
+// copyTransitions returns a copy so callers cannot modify the compiled maps
+func copyTransitions(transitions []layer0.Transition) []layer0.Transition {
+	if len(transitions) == 0 {
+		return nil
+	}
+
+	result := make([]layer0.Transition, len(transitions))
+	copy(result, transitions)
+	return result
+}
+
 // ValidateStateMachine validates the entire state machine
 func (smc *StateMachineCore) ValidateStateMachine() error {
+	if errs := smc.ValidateAll(); len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
+// ValidateAll validates the entire state machine and returns every failure found.
+// States and transitions are checked in ID order so the result is stable.
+func (smc *StateMachineCore) ValidateAll() []error {
 	smc.mutex.RLock()
 	defer smc.mutex.RUnlock()
 
 	if len(smc.states) == 0 {
-		return fmt.Errorf("state machine must have at least one state")
+		return []error{fmt.Errorf("state machine must have at least one state")}
 	}
 
+	var errs []error
+
 	// Check for initial states
 	hasInitialState := false
 	for _, state := range smc.states {
@@ -273,31 +558,170 @@ func (smc *StateMachineCore) ValidateStateMachine() error {
 	}
 
 	if !hasInitialState {
-		return fmt.Errorf("state machine must have at least one initial state")
+		errs = append(errs, fmt.Errorf("state machine must have at least one initial state"))
 	}
 
 	// Validate all states
-	for _, state := range smc.states {
+	stateIDs := make([]string, 0, len(smc.states))
+	for stateID := range smc.states {
+		stateIDs = append(stateIDs, string(stateID))
+	}
+	sort.Strings(stateIDs)
+
+	for _, stateID := range stateIDs {
+		state := smc.states[layer0.StateID(stateID)]
 		if err := state.Validate(); err != nil {
-			return fmt.Errorf("invalid state %s: %w", state.GetID(), err)
+			errs = append(errs, fmt.Errorf("invalid state %s: %w", state.GetID(), err))
 		}
 	}
 
 	// Validate all transitions
-	for _, transition := range smc.transitions {
+	transitionIDs := make([]string, 0, len(smc.transitions))
+	for transitionID := range smc.transitions {
+		transitionIDs = append(transitionIDs, string(transitionID))
+	}
+	sort.Strings(transitionIDs)
+
+	for _, transitionID := range transitionIDs {
+		transition := smc.transitions[layer0.TransitionID(transitionID)]
 		if err := transition.Validate(); err != nil {
-			return fmt.Errorf("invalid transition %s: %w", transition.GetID(), err)
+			errs = append(errs, fmt.Errorf("invalid transition %s: %w", transition.GetID(), err))
 		}
 
 		// Verify referenced states exist
 		if _, exists := smc.states[transition.GetFromStateID()]; !exists {
-			return fmt.Errorf("transition %s references non-existent from state %s", transition.GetID(), transition.GetFromStateID())
+			errs = append(errs, fmt.Errorf("transition %s references non-existent from state %s", transition.GetID(), transition.GetFromStateID()))
 		}
 
 		if _, exists := smc.states[transition.GetToStateID()]; !exists {
-			return fmt.Errorf("transition %s references non-existent to state %s", transition.GetID(), transition.GetToStateID())
+			errs = append(errs, fmt.Errorf("transition %s references non-existent to state %s", transition.GetID(), transition.GetToStateID()))
+		}
+	}
+
+	// Reject cycles that would loop forever at runtime
+	for _, cycle := range smc.findUnconditionalCyclesUnsafe() {
+		path := make([]string, len(cycle))
+		for i, stateID := range cycle {
+			path[i] = string(stateID)
+		}
+		errs = append(errs, fmt.Errorf("unconditional transition cycle %s is likely infinite; mark a transition as an intentional loop to allow it", strings.Join(path, " -> ")))
+	}
+
+	return errs
+}
+
+// findUnconditionalCyclesUnsafe finds cycles made only of unconditional
+// transitions that are not marked as intentional loops. Each cycle is returned
+// as the state path from its first state back to that state.
+func (smc *StateMachineCore) findUnconditionalCyclesUnsafe() [][]layer0.StateID {
+	edges := make(map[layer0.StateID][]layer0.StateID)
+	for _, transition := range smc.transitions {
+		if transition.IsUnconditional() && !transition.IsIntentionalLoop() {
+			edges[transition.GetFromStateID()] = append(edges[transition.GetFromStateID()], transition.GetToStateID())
+		}
+	}
+
+	stateIDs := make([]layer0.StateID, 0, len(edges))
+	for stateID, targets := range edges {
+		stateIDs = append(stateIDs, stateID)
+		sort.Slice(targets, func(i, j int) bool { return targets[i] < targets[j] })
+	}
+	sort.Slice(stateIDs, func(i, j int) bool { return stateIDs[i] < stateIDs[j] })
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	var cycles [][]layer0.StateID
+	marks := make(map[layer0.StateID]int)
+	var path []layer0.StateID
+
+	var visit func(stateID layer0.StateID)
+	visit = func(stateID layer0.StateID) {
+		marks[stateID] = visiting
+		path = append(path, stateID)
+
+		for _, target := range edges[stateID] {
+			switch marks[target] {
+			case unvisited:
+				visit(target)
+			case visiting:
+				// Back edge: the cycle is the path suffix starting at target
+				for i := len(path) - 1; i >= 0; i-- {
+					if path[i] == target {
+						cycle := make([]layer0.StateID, 0, len(path)-i+1)
+						cycle = append(cycle, path[i:]...)
+						cycles = append(cycles, append(cycle, target))
+						break
+					}
+				}
+			}
 		}
+
+		path = path[:len(path)-1]
+		marks[stateID] = visited
 	}
 
+	for _, stateID := range stateIDs {
+		if marks[stateID] == unvisited {
+			visit(stateID)
+		}
+	}
+
+	return cycles
+}
+
+// stateMachineJSON is the serialized form of a state machine
+type stateMachineJSON struct {
+	States      []layer0.State      `json:"states"`
+	Transitions []layer0.Transition `json:"transitions"`
+}
+
+// sortedTransitions returns the transitions of the state machine ordered by ID
+func (smc *StateMachineCore) sortedTransitions() []layer0.Transition {
+	smc.mutex.RLock()
+	transitions := make([]layer0.Transition, 0, len(smc.transitions))
+	for _, transition := range smc.transitions {
+		transitions = append(transitions, transition)
+	}
+	smc.mutex.RUnlock()
+
+	sort.Slice(transitions, func(i, j int) bool {
+		return transitions[i].GetID() < transitions[j].GetID()
+	})
+	return transitions
+}
+
+// MarshalJSON serializes the states and transitions ordered by ID
+func (smc *StateMachineCore) MarshalJSON() ([]byte, error) {
+	return json.Marshal(stateMachineJSON{States: smc.GetAllStates(), Transitions: smc.sortedTransitions()})
+}
+
+// UnmarshalJSON rebuilds the state machine, validating each state and transition as it is added
+func (smc *StateMachineCore) UnmarshalJSON(data []byte) error {
+	var decoded stateMachineJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	smc.mutex.Lock()
+	smc.states = make(map[layer0.StateID]layer0.State)
+	smc.transitions = make(map[layer0.TransitionID]layer0.Transition)
+	smc.currentState = nil
+	smc.invalidateUnsafe()
+	smc.mutex.Unlock()
+
+	for _, state := range decoded.States {
+		if err := smc.AddState(state); err != nil {
+			return err
+		}
+	}
+	for _, transition := range decoded.Transitions {
+		if err := smc.AddTransition(transition); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -1,6 +1,8 @@
 package layer1
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/ubom/workflow/layer0"
@@ -113,6 +115,41 @@ func TestStateMachineCoreAddTransitionWithInvalidStates(t *testing.T) {
 	}
 }
 
+func TestStateMachineCoreStrictEdges(t *testing.T) {
+	newMachine := func(strict bool) *StateMachineCore {
+		smc := NewStateMachineCore()
+		smc.SetStrictEdges(strict)
+		smc.AddState(layer0.NewState("draft", layer0.StateTypeInitial, "Draft"))
+		smc.AddState(layer0.NewState("published", layer0.StateTypeFinal, "Published"))
+		smc.AddTransition(layer0.NewTransition("publish", layer0.TransitionTypeAutomatic, "draft", "published", "Publish").
+			AddCondition("approved").
+			AddCondition("reviewed"))
+		return smc
+	}
+	duplicate := layer0.NewTransition("publish-again", layer0.TransitionTypeAutomatic, "draft", "published", "Publish Again").
+		AddCondition("reviewed").
+		AddCondition("approved")
+
+	// Without strict edges parallel edges are allowed
+	if err := newMachine(false).AddTransition(duplicate); err != nil {
+		t.Errorf("AddTransition should allow a duplicate edge by default: %v", err)
+	}
+
+	smc := newMachine(true)
+	err := smc.AddTransition(duplicate)
+	if err == nil || !strings.Contains(err.Error(), "duplicates transition publish from") {
+		t.Errorf("Expected the duplicate edge to be rejected naming the conflicting transition, got %v", err)
+	}
+	if _, err := smc.GetTransition("publish-again"); err == nil {
+		t.Error("The rejected transition should not be added")
+	}
+
+	// Edges with different conditions are distinct
+	if err := smc.AddTransition(layer0.NewTransition("force-publish", layer0.TransitionTypeAutomatic, "draft", "published", "Force Publish").AddCondition("forced")); err != nil {
+		t.Errorf("AddTransition should allow an edge with other conditions: %v", err)
+	}
+}
+
 func TestStateMachineCoreGetTransitionsFromState(t *testing.T) {
 	smc := NewStateMachineCore()
 
@@ -383,3 +420,282 @@ func TestStateMachineCoreRemoveCurrentState(t *testing.T) {
 		t.Error("Should not be able to remove current state")
 	}
 }
+
+func TestStateMachineCoreCompile(t *testing.T) {
+	smc := NewStateMachineCore()
+	smc.AddState(layer0.NewState("state1", layer0.StateTypeInitial, "State 1"))
+	smc.AddState(layer0.NewState("state2", layer0.StateTypeIntermediate, "State 2"))
+	smc.AddState(layer0.NewState("state3", layer0.StateTypeFinal, "State 3"))
+	smc.AddTransition(layer0.NewTransition("t1", layer0.TransitionTypeAutomatic, "state1", "state2", "T1"))
+
+	if smc.IsCompiled() {
+		t.Error("New state machine should not be compiled")
+	}
+
+	smc.Compile()
+	if !smc.IsCompiled() {
+		t.Error("State machine should be compiled after Compile")
+	}
+
+	if len(smc.GetTransitionsFromState("state1")) != 1 || len(smc.GetTransitionsToState("state2")) != 1 {
+		t.Error("Compiled lookups should return the existing transition")
+	}
+
+	// Adding a transition invalidates the cache
+	smc.AddTransition(layer0.NewTransition("t2", layer0.TransitionTypeAutomatic, "state1", "state3", "T2"))
+	if smc.IsCompiled() {
+		t.Error("AddTransition should invalidate the compiled maps")
+	}
+
+	if len(smc.GetTransitionsFromState("state1")) != 2 {
+		t.Error("Uncompiled lookup should include the new transition")
+	}
+
+	// Recompiling picks up the new transition
+	smc.Compile()
+	if len(smc.GetTransitionsFromState("state1")) != 2 || !smc.CanTransition("state1", "state3") {
+		t.Error("Recompiled lookups should include the new transition")
+	}
+
+	// Removing a transition invalidates the cache
+	smc.RemoveTransition("t2")
+	if smc.IsCompiled() {
+		t.Error("RemoveTransition should invalidate the compiled maps")
+	}
+
+	smc.Compile()
+	if len(smc.GetTransitionsToState("state3")) != 0 || smc.CanTransition("state1", "state3") {
+		t.Error("Recompiled lookups should not include the removed transition")
+	}
+
+	// Removing a state invalidates the cache
+	smc.RemoveState("state3")
+	if smc.IsCompiled() {
+		t.Error("RemoveState should invalidate the compiled maps")
+	}
+
+	// Modifying a returned slice must not affect the compiled maps
+	smc.Compile()
+	transitions := smc.GetTransitionsFromState("state1")
+	transitions[0] = layer0.Transition{}
+	if smc.GetTransitionsFromState("state1")[0].GetID() != "t1" {
+		t.Error("Compiled lookups should return a copy")
+	}
+}
+
+// newBenchmarkStateMachine creates a chain of states with one transition between neighbours
+func newBenchmarkStateMachine(size int) *StateMachineCore {
+	smc := NewStateMachineCore()
+	for i := 0; i < size; i++ {
+		smc.AddState(layer0.NewState(layer0.StateID(fmt.Sprintf("state%d", i)), layer0.StateTypeIntermediate, "State"))
+	}
+	for i := 0; i < size-1; i++ {
+		from := layer0.StateID(fmt.Sprintf("state%d", i))
+		to := layer0.StateID(fmt.Sprintf("state%d", i+1))
+		smc.AddTransition(layer0.NewTransition(layer0.TransitionID(fmt.Sprintf("t%d", i)), layer0.TransitionTypeAutomatic, from, to, "T"))
+	}
+	return smc
+}
+
+func BenchmarkStateMachineCoreGetTransitionsFromState(b *testing.B) {
+	smc := newBenchmarkStateMachine(1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		smc.GetTransitionsFromState("state500")
+	}
+}
+
+func BenchmarkStateMachineCoreGetTransitionsFromStateCompiled(b *testing.B) {
+	smc := newBenchmarkStateMachine(1000)
+	smc.Compile()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		smc.GetTransitionsFromState("state500")
+	}
+}
+
+func TestStateMachineCoreMerge(t *testing.T) {
+	// Create a reusable approval sub-machine: review -> approved
+	approval := NewStateMachineCore()
+	approval.AddState(layer0.NewState("review", layer0.StateTypeIntermediate, "Review"))
+	approval.AddState(layer0.NewState("approved", layer0.StateTypeIntermediate, "Approved"))
+	approval.AddTransition(layer0.NewTransition("approve", layer0.TransitionTypeManual, "review", "approved", "Approve"))
+
+	// Create the parent: start -> submitted, with an end state to wire the exit into
+	parent := NewStateMachineCore()
+	parent.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	parent.AddState(layer0.NewState("submitted", layer0.StateTypeIntermediate, "Submitted"))
+	parent.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	parent.AddTransition(layer0.NewTransition("submit", layer0.TransitionTypeAutomatic, "start", "submitted", "Submit"))
+
+	// Map the entry to submitted and the exit to end
+	mapping := map[layer0.StateID]layer0.StateID{
+		"review":   "submitted",
+		"approved": "end",
+	}
+	if err := parent.MergeWithMapping(approval, "approval", mapping); err != nil {
+		t.Fatalf("MergeWithMapping should not return error: %v", err)
+	}
+
+	transition, err := parent.GetTransition(PrefixTransitionID("approval", "approve"))
+	if err != nil {
+		t.Fatalf("Merged transition should exist under its prefixed ID: %v", err)
+	}
+
+	if transition.GetFromStateID() != "submitted" || transition.GetToStateID() != "end" {
+		t.Errorf("Merged transition should be wired submitted -> end, got %s -> %s", transition.GetFromStateID(), transition.GetToStateID())
+	}
+
+	// Mapped states are not imported
+	if _, err := parent.GetState(PrefixStateID("approval", "review")); err == nil {
+		t.Error("Mapped state should not be imported")
+	}
+
+	// Merge without mapping imports every state under the prefix
+	if err := parent.Merge(approval, "audit"); err != nil {
+		t.Fatalf("Merge should not return error: %v", err)
+	}
+
+	for _, stateID := range []layer0.StateID{"audit.review", "audit.approved"} {
+		if _, err := parent.GetState(stateID); err != nil {
+			t.Errorf("Merged state %s should exist: %v", stateID, err)
+		}
+	}
+
+	if !parent.CanTransition("audit.review", "audit.approved") {
+		t.Error("Merged transition should connect the prefixed states")
+	}
+
+	parent.AddTransition(layer0.NewTransition("to-audit", layer0.TransitionTypeAutomatic, "end", "audit.review", "To Audit"))
+	if err := parent.ValidateStateMachine(); err != nil {
+		t.Errorf("Combined state machine should validate: %v", err)
+	}
+
+	// Merging under the same prefix again collides and leaves the machine unchanged
+	statesBefore := len(parent.GetAllStates())
+	if err := parent.Merge(approval, "audit"); err == nil {
+		t.Error("Merge should return error when prefixed IDs collide")
+	}
+
+	if len(parent.GetAllStates()) != statesBefore {
+		t.Error("Failed merge should not modify the state machine")
+	}
+
+	// Mapping to a missing parent state is rejected
+	if err := parent.MergeWithMapping(approval, "other", map[layer0.StateID]layer0.StateID{"review": "missing"}); err == nil {
+		t.Error("MergeWithMapping should return error for a missing parent state")
+	}
+}
+
+func TestStateMachineCoreValidateUnconditionalCycles(t *testing.T) {
+	newMachine := func(loop layer0.Transition) *StateMachineCore {
+		smc := NewStateMachineCore()
+		smc.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+		smc.AddState(layer0.NewState("poll", layer0.StateTypeIntermediate, "Poll"))
+		smc.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+		smc.AddTransition(layer0.NewTransition("start-to-poll", layer0.TransitionTypeAutomatic, "start", "poll", "Start to Poll"))
+		smc.AddTransition(layer0.NewTransition("poll-to-end", layer0.TransitionTypeConditional, "poll", "end", "Poll to End").AddCondition("done"))
+		smc.AddTransition(loop)
+		return smc
+	}
+
+	// An unconditional self-loop is rejected
+	selfLoop := layer0.NewTransition("poll-again", layer0.TransitionTypeAutomatic, "poll", "poll", "Poll Again")
+	err := newMachine(selfLoop).ValidateStateMachine()
+	if err == nil {
+		t.Fatal("Unconditional self-loop should be rejected")
+	}
+
+	if !strings.Contains(err.Error(), "poll -> poll") {
+		t.Errorf("Error should name the cycle, got %v", err)
+	}
+
+	// A guarded loop is allowed
+	guardedLoop := selfLoop.AddCondition("not_done")
+	if err := newMachine(guardedLoop).ValidateStateMachine(); err != nil {
+		t.Errorf("Guarded loop should be allowed: %v", err)
+	}
+
+	// A loop whose work can change the context is allowed
+	workLoop := selfLoop.AddAction("check-status")
+	if err := newMachine(workLoop).ValidateStateMachine(); err != nil {
+		t.Errorf("Loop with work should be allowed: %v", err)
+	}
+
+	// An unconditional loop marked as intentional is allowed
+	if err := newMachine(selfLoop.MarkIntentionalLoop()).ValidateStateMachine(); err != nil {
+		t.Errorf("Intentional loop should be allowed: %v", err)
+	}
+
+	// A multi-state unconditional cycle is rejected
+	smc := newMachine(layer0.NewTransition("end-to-start", layer0.TransitionTypeAutomatic, "end", "start", "End to Start"))
+	smc.AddTransition(layer0.NewTransition("poll-to-start", layer0.TransitionTypeAutomatic, "poll", "start", "Poll to Start"))
+	if err := smc.ValidateStateMachine(); err == nil || !strings.Contains(err.Error(), "start -> poll -> start") {
+		t.Errorf("Unconditional multi-state cycle should be rejected, got %v", err)
+	}
+}
+
+func TestStateMachineCoreDistanceToFinal(t *testing.T) {
+	smc := NewStateMachineCore()
+	smc.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	smc.AddState(layer0.NewState("long", layer0.StateTypeIntermediate, "Long"))
+	smc.AddState(layer0.NewState("longer", layer0.StateTypeIntermediate, "Longer"))
+	smc.AddState(layer0.NewState("stuck", layer0.StateTypeIntermediate, "Stuck"))
+	smc.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	smc.AddTransition(layer0.NewTransition("start-long", layer0.TransitionTypeAutomatic, "start", "long", "Start to Long"))
+	smc.AddTransition(layer0.NewTransition("long-longer", layer0.TransitionTypeAutomatic, "long", "longer", "Long to Longer"))
+	smc.AddTransition(layer0.NewTransition("longer-end", layer0.TransitionTypeAutomatic, "longer", "end", "Longer to End"))
+	smc.AddTransition(layer0.NewTransition("start-stuck", layer0.TransitionTypeAutomatic, "start", "stuck", "Start to Stuck"))
+
+	expected := map[layer0.StateID]int{"start": 3, "long": 2, "longer": 1, "end": 0}
+	for _, compile := range []bool{false, true} {
+		if compile {
+			smc.Compile()
+		}
+
+		for stateID, want := range expected {
+			if distance, reachable := smc.DistanceToFinal(stateID); !reachable || distance != want {
+				t.Errorf("Expected distance %d from %s (compiled %v), got %d (reachable %v)", want, stateID, compile, distance, reachable)
+			}
+		}
+
+		if _, reachable := smc.DistanceToFinal("stuck"); reachable {
+			t.Errorf("stuck should not reach a final state (compiled %v)", compile)
+		}
+	}
+
+	// A shortcut shortens the distance once added
+	smc.AddTransition(layer0.NewTransition("start-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End"))
+	if distance, _ := smc.DistanceToFinal("start"); distance != 1 {
+		t.Errorf("Expected distance 1 from start after adding a shortcut, got %d", distance)
+	}
+
+	// A final state added after compiling is not hidden by the compiled distances
+	smc.Compile()
+	smc.AddState(layer0.NewState("cancelled", layer0.StateTypeFinal, "Cancelled"))
+	if smc.IsCompiled() {
+		t.Error("Adding a state should invalidate the compiled indexes")
+	}
+	if distance, reachable := smc.DistanceToFinal("cancelled"); !reachable || distance != 0 {
+		t.Errorf("Expected the added final state to be at distance 0, got %d (reachable %v)", distance, reachable)
+	}
+}
+
+func TestStateMachineCoreGetAllStatesOrdered(t *testing.T) {
+	smc := NewStateMachineCore()
+	for _, stateID := range []layer0.StateID{"delta", "alpha", "echo", "charlie", "bravo"} {
+		smc.AddState(layer0.NewState(stateID, layer0.StateTypeIntermediate, string(stateID)))
+	}
+
+	expected := []layer0.StateID{"alpha", "bravo", "charlie", "delta", "echo"}
+	for call := 0; call < 10; call++ {
+		states := smc.GetAllStates()
+		for index, state := range states {
+			if state.GetID() != expected[index] {
+				t.Fatalf("Call %d: expected state %s at %d, got %s", call, expected[index], index, state.GetID())
+			}
+		}
+	}
+}
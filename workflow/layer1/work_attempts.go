@@ -0,0 +1,62 @@
+package layer1
+
+import (
+	"context"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// WorkAttempt records a single execution of a work item
+type WorkAttempt struct {
+	Attempt   int               `json:"attempt"`
+	Status    layer0.WorkStatus `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	StartedAt time.Time         `json:"started_at"`
+	Duration  time.Duration     `json:"duration"`
+}
+
+// previousAttemptsKey is the context key holding the attempts a retry follows
+type previousAttemptsKey struct{}
+
+// WithPreviousAttempts returns a copy of ctx marking the execution as a retry of
+// the given earlier attempts of the same work, which its result's attempts extend.
+// Executions without previous attempts start a new list.
+func WithPreviousAttempts(ctx context.Context, attempts []WorkAttempt) context.Context {
+	if len(attempts) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, previousAttemptsKey{}, append([]WorkAttempt(nil), attempts...))
+}
+
+// previousAttemptsFromContext returns the attempts carried by ctx, if any
+func previousAttemptsFromContext(ctx context.Context) []WorkAttempt {
+	if ctx == nil {
+		return nil
+	}
+	attempts, _ := ctx.Value(previousAttemptsKey{}).([]WorkAttempt)
+	return attempts
+}
+
+// GetAttempts returns the attempts behind the latest execution result of a work item,
+// oldest first
+func (wec *WorkExecutionCore) GetAttempts(workID layer0.WorkID) []WorkAttempt {
+	wec.mutex.RLock()
+	defer wec.mutex.RUnlock()
+
+	attempts := wec.executionResults[workID].Attempts
+	return append([]WorkAttempt(nil), attempts...)
+}
+
+// recordAttempt appends result as the next attempt after the previous attempts carried by ctx
+func recordAttempt(ctx context.Context, result *WorkExecutionResult) {
+	attempts := previousAttemptsFromContext(ctx)
+
+	result.Attempts = append(append([]WorkAttempt(nil), attempts...), WorkAttempt{
+		Attempt:   len(attempts) + 1,
+		Status:    result.Status,
+		Error:     result.Error,
+		StartedAt: result.StartedAt,
+		Duration:  result.Duration,
+	})
+}
@@ -0,0 +1,66 @@
+package layer1
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+func TestWorkExecutionCoreAttempts(t *testing.T) {
+	calls := 0
+	core := NewWorkExecutionCore()
+	core.RegisterExecutor(layer0.WorkTypeTask, NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		calls++
+		if calls <= 2 {
+			return nil, errors.New("downstream timeout")
+		}
+		return "ok", nil
+	}))
+
+	work := layer0.NewWork("charge", layer0.WorkTypeTask, "Charge")
+	workContext := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Ctx")
+
+	// Fails twice with a timeout and succeeds on the third attempt, each retry
+	// carrying the attempts before it
+	var result WorkExecutionResult
+	for i := 0; i < 3; i++ {
+		result, _ = core.ExecuteWorkWithContext(WithPreviousAttempts(context.Background(), result.Attempts), work, workContext)
+	}
+	if result.Status != layer0.WorkStatusCompleted {
+		t.Fatalf("Expected the third attempt to complete, got %s", result.Status)
+	}
+
+	attempts := core.GetAttempts("charge")
+	if len(attempts) != 3 || len(result.Attempts) != 3 {
+		t.Fatalf("Expected 3 recorded attempts, got %d (%d on the result)", len(attempts), len(result.Attempts))
+	}
+	expected := []layer0.WorkStatus{layer0.WorkStatusFailed, layer0.WorkStatusFailed, layer0.WorkStatusCompleted}
+	for i, attempt := range attempts {
+		if attempt.Attempt != i+1 || attempt.Status != expected[i] {
+			t.Errorf("Expected attempt %d to be %s, got attempt %d %s", i+1, expected[i], attempt.Attempt, attempt.Status)
+		}
+		if failed := attempt.Status == layer0.WorkStatusFailed; failed != (attempt.Error == "downstream timeout") {
+			t.Errorf("Expected attempt %d to carry the timeout error only when failed, got %q", i+1, attempt.Error)
+		}
+		if attempt.StartedAt.IsZero() {
+			t.Errorf("Expected attempt %d to record when it started", i+1)
+		}
+	}
+
+	// An execution that is not marked as a retry starts a new list, even after a failure
+	calls = 0
+	core.ExecuteWork(work, workContext)
+	if attempts := core.GetAttempts("charge"); len(attempts) != 1 || attempts[0].Status != layer0.WorkStatusFailed {
+		t.Errorf("Expected a single failed attempt, got %v", attempts)
+	}
+	core.ExecuteWork(work, workContext)
+	if attempts := core.GetAttempts("charge"); len(attempts) != 1 || attempts[0].Attempt != 1 {
+		t.Errorf("Expected a new execution not to extend the failed one, got %v", attempts)
+	}
+
+	if attempts := core.GetAttempts("missing"); len(attempts) != 0 {
+		t.Errorf("Expected no attempts for unknown work, got %v", attempts)
+	}
+}
@@ -1,13 +1,40 @@
 package layer1
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/ubom/workflow/layer0"
 )
 
+// ErrWorkPending is returned by executors whose work completes asynchronously.
+// The execution result is reported as pending instead of failed.
+var ErrWorkPending = errors.New("work pending asynchronous completion")
+
+// ErrWorkBlocked is returned by executors whose work cannot finish until an external
+// completion or signal arrives, such as a human approval. The execution result is
+// reported as blocked instead of failed.
+var ErrWorkBlocked = errors.New("work blocked awaiting completion")
+
+const (
+	// WorkPropertyInstanceID is the work metadata property holding the ID of the
+	// workflow instance the work runs for
+	WorkPropertyInstanceID = "instance_id"
+	// WorkPropertyOutputKey is the work metadata property naming the context key
+	// the work's output is stored under
+	WorkPropertyOutputKey = "output_key"
+	// WorkPropertyInputKeys is the work metadata property listing, comma separated,
+	// the context keys the work sees when the engine projects contexts
+	WorkPropertyInputKeys = "input_keys"
+	// WorkPropertyFullContext is the work metadata property that, set to "true",
+	// gives the work the full context even when the engine projects contexts
+	WorkPropertyFullContext = "full_context"
+)
+
 // WorkExecutor defines the interface for executing work
 type WorkExecutor interface {
 	Execute(work layer0.Work, context *layer0.Context) (interface{}, error)
@@ -15,6 +42,19 @@ type WorkExecutor interface {
 	GetSupportedTypes() []layer0.WorkType
 }
 
+// ContextAwareWorkExecutor is implemented by executors that honor cancellation and deadlines
+type ContextAwareWorkExecutor interface {
+	WorkExecutor
+	ExecuteWithContext(ctx context.Context, work layer0.Work, context *layer0.Context) (interface{}, error)
+}
+
+// HealthCheckedExecutor is implemented by executors that can report the health
+// of the systems they depend on
+type HealthCheckedExecutor interface {
+	WorkExecutor
+	HealthCheck() error
+}
+
 // WorkExecutionResult represents the result of work execution
 type WorkExecutionResult struct {
 	WorkID      layer0.WorkID     `json:"work_id"`
@@ -24,36 +64,59 @@ type WorkExecutionResult struct {
 	StartedAt   time.Time         `json:"started_at"`
 	CompletedAt *time.Time        `json:"completed_at,omitempty"`
 	Duration    time.Duration     `json:"duration"`
+	Attempts    []WorkAttempt     `json:"attempts,omitempty"`
+	// HandledByFallback is set when no executor was registered for the work and the
+	// fallback executor ran it instead
+	HandledByFallback bool `json:"handled_by_fallback,omitempty"`
 }
 
 // WorkExecutionCore provides core work execution functionality
 type WorkExecutionCore struct {
 	executors        map[layer0.WorkType]WorkExecutor
+	fallback         WorkExecutor
 	activeWork       map[layer0.WorkID]layer0.Work
 	executionResults map[layer0.WorkID]WorkExecutionResult
+	router           WorkRouter
+	checkpoints      CheckpointStore
+	timeouts         map[layer0.WorkType]time.Duration
+	now              func() time.Time
 	mutex            sync.RWMutex
 }
 
 // WorkExecutionCoreInterface defines the contract for work execution operations
 type WorkExecutionCoreInterface interface {
 	RegisterExecutor(workType layer0.WorkType, executor WorkExecutor) error
+	RegisterExecutorForTypes(executor WorkExecutor, workTypes ...layer0.WorkType) error
 	UnregisterExecutor(workType layer0.WorkType) error
 	GetExecutor(workType layer0.WorkType) (WorkExecutor, error)
 	GetSupportedWorkTypes() []layer0.WorkType
+	GetExecutorCapabilities(workType layer0.WorkType) (ExecutorCapabilities, error)
+	GetWorkCapabilities(work layer0.Work) (ExecutorCapabilities, error)
+	SetWorkRouter(router WorkRouter)
+	SetFallbackExecutor(executor WorkExecutor)
+	SetCheckpointStore(store CheckpointStore)
+	SetExecutorTimeout(workType layer0.WorkType, timeout time.Duration) error
+	SetTimeSource(now func() time.Time)
 	ExecuteWork(work layer0.Work, context *layer0.Context) (WorkExecutionResult, error)
+	ExecuteWorkWithContext(ctx context.Context, work layer0.Work, context *layer0.Context) (WorkExecutionResult, error)
 	GetActiveWork() []layer0.Work
 	GetExecutionResult(workID layer0.WorkID) (WorkExecutionResult, error)
+	GetAttempts(workID layer0.WorkID) []WorkAttempt
 	GetAllExecutionResults() []WorkExecutionResult
 	CancelWork(workID layer0.WorkID) error
 	IsWorkActive(workID layer0.WorkID) bool
+	Stats() WorkExecutionStats
 }
 
 // NewWorkExecutionCore creates a new work execution core
 func NewWorkExecutionCore() *WorkExecutionCore {
 	return &WorkExecutionCore{
 		executors:        make(map[layer0.WorkType]WorkExecutor),
+		timeouts:         make(map[layer0.WorkType]time.Duration),
 		activeWork:       make(map[layer0.WorkID]layer0.Work),
 		executionResults: make(map[layer0.WorkID]WorkExecutionResult),
+		router:           NewTypeWorkRouter(),
+		now:              time.Now,
 		mutex:            sync.RWMutex{},
 	}
 }
@@ -75,6 +138,64 @@ func (wec *WorkExecutionCore) RegisterExecutor(workType layer0.WorkType, executo
 	return nil
 }
 
+// RegisterExecutorForTypes registers one executor for several work types, or for
+// every type it supports when none are given. The registration is atomic: if any
+// type is already registered, none are.
+func (wec *WorkExecutionCore) RegisterExecutorForTypes(executor WorkExecutor, workTypes ...layer0.WorkType) error {
+	if executor == nil {
+		return fmt.Errorf("executor cannot be nil")
+	}
+
+	if len(workTypes) == 0 {
+		workTypes = executor.GetSupportedTypes()
+	}
+	if len(workTypes) == 0 {
+		return fmt.Errorf("executor supports no work types")
+	}
+
+	wec.mutex.Lock()
+	defer wec.mutex.Unlock()
+
+	seen := make(map[layer0.WorkType]bool, len(workTypes))
+	for _, workType := range workTypes {
+		if _, exists := wec.executors[workType]; exists {
+			return fmt.Errorf("executor for work type %s already registered", workType)
+		}
+		if seen[workType] {
+			return fmt.Errorf("work type %s is listed more than once", workType)
+		}
+		seen[workType] = true
+	}
+
+	for _, workType := range workTypes {
+		wec.executors[workType] = executor
+	}
+	return nil
+}
+
+// SetTimeSource sets the function execution results are timed with, which defaults
+// to the system time. A nil function restores the system time.
+func (wec *WorkExecutionCore) SetTimeSource(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+
+	wec.mutex.Lock()
+	defer wec.mutex.Unlock()
+
+	wec.now = now
+}
+
+// SetFallbackExecutor sets the executor that runs work no registered executor handles.
+// Its results are marked HandledByFallback. A nil executor removes the fallback, so
+// such work fails again.
+func (wec *WorkExecutionCore) SetFallbackExecutor(executor WorkExecutor) {
+	wec.mutex.Lock()
+	defer wec.mutex.Unlock()
+
+	wec.fallback = executor
+}
+
 // UnregisterExecutor unregisters a work executor for a specific work type
 func (wec *WorkExecutionCore) UnregisterExecutor(workType layer0.WorkType) error {
 	wec.mutex.Lock()
@@ -101,7 +222,7 @@ func (wec *WorkExecutionCore) GetExecutor(workType layer0.WorkType) (WorkExecuto
 	return executor, nil
 }
 
-// GetSupportedWorkTypes returns all supported work types
+// GetSupportedWorkTypes returns all supported work types in sorted order
 func (wec *WorkExecutionCore) GetSupportedWorkTypes() []layer0.WorkType {
 	wec.mutex.RLock()
 	defer wec.mutex.RUnlock()
@@ -110,12 +231,33 @@ func (wec *WorkExecutionCore) GetSupportedWorkTypes() []layer0.WorkType {
 	for workType := range wec.executors {
 		types = append(types, workType)
 	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
 
 	return types
 }
 
+// SetWorkRouter sets the router that selects executors for work.
+// A nil router restores routing by work type.
+func (wec *WorkExecutionCore) SetWorkRouter(router WorkRouter) {
+	if router == nil {
+		router = NewTypeWorkRouter()
+	}
+
+	wec.mutex.Lock()
+	defer wec.mutex.Unlock()
+
+	wec.router = router
+}
+
 // ExecuteWork executes a work item using the appropriate executor
-func (wec *WorkExecutionCore) ExecuteWork(work layer0.Work, context *layer0.Context) (WorkExecutionResult, error) {
+func (wec *WorkExecutionCore) ExecuteWork(work layer0.Work, workContext *layer0.Context) (WorkExecutionResult, error) {
+	return wec.ExecuteWorkWithContext(context.Background(), work, workContext)
+}
+
+// ExecuteWorkWithContext executes a work item, passing ctx to executors that support cancellation
+func (wec *WorkExecutionCore) ExecuteWorkWithContext(ctx context.Context, work layer0.Work, context *layer0.Context) (WorkExecutionResult, error) {
 	if err := work.Validate(); err != nil {
 		return WorkExecutionResult{}, fmt.Errorf("invalid work: %w", err)
 	}
@@ -133,28 +275,45 @@ func (wec *WorkExecutionCore) ExecuteWork(work layer0.Work, context *layer0.Cont
 	}
 
 	// Get executor
-	executor, exists := wec.executors[work.GetType()]
-	if !exists {
+	executorKey := wec.router.Route(work)
+	executor, exists := wec.executors[executorKey]
+	handledByFallback := !exists && wec.fallback != nil
+	if handledByFallback {
+		executor = wec.fallback
+	} else if !exists {
 		wec.mutex.Unlock()
+		if executorKey != work.GetType() {
+			return WorkExecutionResult{}, fmt.Errorf("no executor registered for key %s routed from work type %s", executorKey, work.GetType())
+		}
 		return WorkExecutionResult{}, fmt.Errorf("no executor registered for work type %s", work.GetType())
 	}
 
 	// Mark work as active
 	startedWork := work.MarkStarted()
 	wec.activeWork[work.GetID()] = startedWork
+	checkpoints := wec.checkpoints
+	timeout := wec.timeouts[executorKey]
+	now := wec.now
 	wec.mutex.Unlock()
 
 	// Create initial result
-	startTime := time.Now()
+	startTime := now()
 	result := WorkExecutionResult{
-		WorkID:    work.GetID(),
-		Status:    layer0.WorkStatusExecuting,
-		StartedAt: startTime,
+		WorkID:            work.GetID(),
+		Status:            layer0.WorkStatusExecuting,
+		StartedAt:         startTime,
+		HandledByFallback: handledByFallback,
 	}
 
-	// Execute work
-	output, err := executor.Execute(work, context)
-	endTime := time.Now()
+	// Execute work, failing it if it outlives the executor's timeout
+	var output interface{}
+	var err error
+	if timeout > 0 {
+		output, err = executeWithTimeout(ctx, timeout, checkpoints, executor, work, context)
+	} else {
+		output, err = runExecutor(ctx, checkpoints, executor, work, context)
+	}
+	endTime := now()
 	duration := endTime.Sub(startTime)
 
 	wec.mutex.Lock()
@@ -167,7 +326,13 @@ func (wec *WorkExecutionCore) ExecuteWork(work layer0.Work, context *layer0.Cont
 	result.Duration = duration
 	result.CompletedAt = &endTime
 
-	if err != nil {
+	if errors.Is(err, ErrWorkPending) {
+		result.Status = layer0.WorkStatusPending
+		result.CompletedAt = nil
+	} else if errors.Is(err, ErrWorkBlocked) {
+		result.Status = layer0.WorkStatusBlocked
+		result.CompletedAt = nil
+	} else if err != nil {
 		result.Status = layer0.WorkStatusFailed
 		result.Error = err.Error()
 	} else {
@@ -175,13 +340,22 @@ func (wec *WorkExecutionCore) ExecuteWork(work layer0.Work, context *layer0.Cont
 		result.Output = output
 	}
 
-	// Store result
+	// Store result along with the attempts that led to it
+	recordAttempt(ctx, &result)
 	wec.executionResults[work.GetID()] = result
 
 	return result, nil
 }
 
-// GetActiveWork returns all currently active work items
+// executeWithContext runs the executor, passing ctx along when the executor supports it
+func executeWithContext(ctx context.Context, executor WorkExecutor, work layer0.Work, context *layer0.Context) (interface{}, error) {
+	if contextAware, ok := executor.(ContextAwareWorkExecutor); ok {
+		return contextAware.ExecuteWithContext(ctx, work, context)
+	}
+	return executor.Execute(work, context)
+}
+
+// GetActiveWork returns all currently active work items ordered by ID
 func (wec *WorkExecutionCore) GetActiveWork() []layer0.Work {
 	wec.mutex.RLock()
 	defer wec.mutex.RUnlock()
@@ -190,6 +364,9 @@ func (wec *WorkExecutionCore) GetActiveWork() []layer0.Work {
 	for _, work := range wec.activeWork {
 		activeWork = append(activeWork, work)
 	}
+	sort.Slice(activeWork, func(i, j int) bool {
+		return activeWork[i].GetID() < activeWork[j].GetID()
+	})
 
 	return activeWork
 }
@@ -207,7 +384,7 @@ func (wec *WorkExecutionCore) GetExecutionResult(workID layer0.WorkID) (WorkExec
 	return result, nil
 }
 
-// GetAllExecutionResults returns all execution results
+// GetAllExecutionResults returns all execution results ordered by start time, then work ID
 func (wec *WorkExecutionCore) GetAllExecutionResults() []WorkExecutionResult {
 	wec.mutex.RLock()
 	defer wec.mutex.RUnlock()
@@ -216,6 +393,12 @@ func (wec *WorkExecutionCore) GetAllExecutionResults() []WorkExecutionResult {
 	for _, result := range wec.executionResults {
 		results = append(results, result)
 	}
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].StartedAt.Equal(results[j].StartedAt) {
+			return results[i].StartedAt.Before(results[j].StartedAt)
+		}
+		return results[i].WorkID < results[j].WorkID
+	})
 
 	return results
 }
@@ -234,7 +417,7 @@ func (wec *WorkExecutionCore) CancelWork(workID layer0.WorkID) error {
 	delete(wec.activeWork, workID)
 
 	// Create cancellation result
-	now := time.Now()
+	now := wec.now()
 	var startedAt time.Time
 	if work.GetMetadata().StartedAt != nil {
 		startedAt = *work.GetMetadata().StartedAt
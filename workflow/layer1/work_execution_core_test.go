@@ -2,6 +2,7 @@ package layer1
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -53,6 +54,27 @@ func TestWorkExecutionCoreRegisterExecutor(t *testing.T) {
 	}
 }
 
+func TestWorkExecutionCoreRegisterExecutorForTypes(t *testing.T) {
+	wec := NewWorkExecutionCore()
+	executor := NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask, layer0.WorkTypeService}, nil)
+
+	if err := wec.RegisterExecutorForTypes(executor, layer0.WorkTypeTask, layer0.WorkTypeService); err != nil {
+		t.Fatalf("RegisterExecutorForTypes should not return error: %v", err)
+	}
+	if types := wec.GetSupportedWorkTypes(); len(types) != 2 {
+		t.Errorf("Expected 2 supported work types, got %v", types)
+	}
+
+	// A conflict on any type rolls back the whole registration
+	other := NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeHuman, layer0.WorkTypeTask}, nil)
+	if err := wec.RegisterExecutorForTypes(other); err == nil {
+		t.Error("RegisterExecutorForTypes should return error when a type is already registered")
+	}
+	if _, err := wec.GetExecutor(layer0.WorkTypeHuman); err == nil {
+		t.Error("No type should be registered when the registration conflicts")
+	}
+}
+
 func TestWorkExecutionCoreUnregisterExecutor(t *testing.T) {
 	wec := NewWorkExecutionCore()
 	executor := NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, nil)
@@ -108,6 +130,50 @@ func TestWorkExecutionCoreGetSupportedWorkTypes(t *testing.T) {
 	}
 }
 
+func TestWorkExecutionCoreFallbackExecutor(t *testing.T) {
+	wec := NewWorkExecutionCore()
+	wec.RegisterExecutor(layer0.WorkTypeTask, NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, nil))
+	wec.SetFallbackExecutor(NewMockWorkExecutor(nil, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		return "pending manual handling", nil
+	}))
+
+	context := layer0.NewContext("test-context", layer0.ContextScopeWork, "Test Context")
+	result, err := wec.ExecuteWork(layer0.NewWork("invoice", "invoice", "Invoice"), context)
+	if err != nil {
+		t.Fatalf("ExecuteWork should not return error: %v", err)
+	}
+	if !result.HandledByFallback || result.Status != layer0.WorkStatusCompleted || result.Output != "pending manual handling" {
+		t.Errorf("Expected the fallback to handle the unregistered type, got %+v", result)
+	}
+
+	// Registered types are not routed to the fallback
+	result, _ = wec.ExecuteWork(layer0.NewWork("task", layer0.WorkTypeTask, "Task"), context)
+	if result.HandledByFallback {
+		t.Error("Expected the registered executor to handle its type")
+	}
+
+	wec.SetFallbackExecutor(nil)
+	if _, err := wec.ExecuteWork(layer0.NewWork("invoice-2", "invoice", "Invoice"), context); err == nil {
+		t.Error("ExecuteWork should return error once the fallback is removed")
+	}
+}
+
+func TestWorkExecutionCoreSetTimeSource(t *testing.T) {
+	wec := NewWorkExecutionCore()
+	wec.RegisterExecutor(layer0.WorkTypeTask, NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, nil))
+
+	fixed := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	wec.SetTimeSource(func() time.Time { return fixed })
+
+	result, err := wec.ExecuteWork(layer0.NewWork("task", layer0.WorkTypeTask, "Task"), layer0.NewContext("ctx", layer0.ContextScopeWork, "Context"))
+	if err != nil {
+		t.Fatalf("ExecuteWork should not return error: %v", err)
+	}
+	if !result.StartedAt.Equal(fixed) || result.CompletedAt == nil || !result.CompletedAt.Equal(fixed) || result.Duration != 0 {
+		t.Errorf("Expected the result to be timed by the time source, got %+v", result)
+	}
+}
+
 func TestWorkExecutionCoreExecuteWork(t *testing.T) {
 	wec := NewWorkExecutionCore()
 
@@ -186,6 +252,32 @@ func TestWorkExecutionCoreExecuteWorkWithError(t *testing.T) {
 	}
 }
 
+func TestWorkExecutionCoreExecuteBlockedWork(t *testing.T) {
+	wec := NewWorkExecutionCore()
+
+	work := layer0.NewWork("approval", layer0.WorkTypeHuman, "Approval")
+	context := layer0.NewContext("test-context", layer0.ContextScopeWork, "Test Context")
+
+	// Register executor that waits for a human decision
+	executor := NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeHuman}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		return nil, fmt.Errorf("approval requested: %w", ErrWorkBlocked)
+	})
+	wec.RegisterExecutor(layer0.WorkTypeHuman, executor)
+
+	result, err := wec.ExecuteWork(work, context)
+	if err != nil {
+		t.Errorf("ExecuteWork should not return error for blocked work: %v", err)
+	}
+
+	if result.Status != layer0.WorkStatusBlocked {
+		t.Errorf("Expected status %s, got %s", layer0.WorkStatusBlocked, result.Status)
+	}
+
+	if result.Error != "" || result.CompletedAt != nil {
+		t.Errorf("Blocked work should be neither failed nor completed, got %+v", result)
+	}
+}
+
 func TestWorkExecutionCoreExecuteNonExecutableWork(t *testing.T) {
 	wec := NewWorkExecutionCore()
 
@@ -0,0 +1,67 @@
+package layer1
+
+import (
+	"reflect"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// WorkRouter selects the executor for work. The returned key is the key an
+// executor was registered under, which need not be a work type itself.
+type WorkRouter interface {
+	Route(work layer0.Work) layer0.WorkType
+}
+
+// TypeWorkRouter routes work to the executor registered for its type
+type TypeWorkRouter struct{}
+
+// NewTypeWorkRouter creates a new type work router
+func NewTypeWorkRouter() *TypeWorkRouter {
+	return &TypeWorkRouter{}
+}
+
+// Route returns the work's type
+func (router *TypeWorkRouter) Route(work layer0.Work) layer0.WorkType {
+	return work.GetType()
+}
+
+// WorkRoute routes work whose configuration parameter has a given value to an executor key.
+// An empty WorkType matches work of any type.
+type WorkRoute struct {
+	WorkType    layer0.WorkType `json:"work_type,omitempty"`
+	Parameter   string          `json:"parameter"`
+	Value       interface{}     `json:"value"`
+	ExecutorKey layer0.WorkType `json:"executor_key"`
+}
+
+// Matches checks if the route applies to the given work
+func (route WorkRoute) Matches(work layer0.Work) bool {
+	if route.WorkType != "" && route.WorkType != work.GetType() {
+		return false
+	}
+
+	value, exists := work.GetConfiguration().Parameters[route.Parameter]
+	return exists && reflect.DeepEqual(value, route.Value)
+}
+
+// RuleBasedWorkRouter routes work by the first matching route, falling back to the work's type
+type RuleBasedWorkRouter struct {
+	routes []WorkRoute
+}
+
+// NewRuleBasedWorkRouter creates a new rule-based work router with routes checked in order
+func NewRuleBasedWorkRouter(routes ...WorkRoute) *RuleBasedWorkRouter {
+	return &RuleBasedWorkRouter{
+		routes: append([]WorkRoute(nil), routes...),
+	}
+}
+
+// Route returns the executor key of the first matching route, or the work's type
+func (router *RuleBasedWorkRouter) Route(work layer0.Work) layer0.WorkType {
+	for _, route := range router.routes {
+		if route.Matches(work) {
+			return route.ExecutorKey
+		}
+	}
+	return work.GetType()
+}
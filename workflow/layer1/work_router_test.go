@@ -0,0 +1,66 @@
+package layer1
+
+import (
+	"testing"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+func TestRuleBasedWorkRouter(t *testing.T) {
+	core := NewWorkExecutionCore()
+
+	regionExecutor := func(region string) WorkExecutor {
+		return NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+			return region, nil
+		})
+	}
+	core.RegisterExecutor(layer0.WorkTypeTask, regionExecutor("default"))
+	core.RegisterExecutor("task-us", regionExecutor("us"))
+	core.RegisterExecutor("task-eu", regionExecutor("eu"))
+
+	core.SetWorkRouter(NewRuleBasedWorkRouter(
+		WorkRoute{WorkType: layer0.WorkTypeTask, Parameter: "region", Value: "us", ExecutorKey: "task-us"},
+		WorkRoute{WorkType: layer0.WorkTypeTask, Parameter: "region", Value: "eu", ExecutorKey: "task-eu"},
+	))
+
+	workContext := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context")
+	tests := []struct {
+		region   interface{}
+		expected string
+	}{
+		{region: "us", expected: "us"},
+		{region: "eu", expected: "eu"},
+		{region: "ap", expected: "default"},
+		{region: nil, expected: "default"},
+	}
+
+	for i, test := range tests {
+		work := layer0.NewWork(layer0.WorkID("work-"+string(rune('a'+i))), layer0.WorkTypeTask, "Work")
+		if test.region != nil {
+			work.Configuration.Parameters["region"] = test.region
+		}
+
+		result, err := core.ExecuteWork(work, workContext)
+		if err != nil {
+			t.Fatalf("ExecuteWork should not return error: %v", err)
+		}
+
+		if result.Output != test.expected {
+			t.Errorf("Expected region %v to route to %s executor, got %v", test.region, test.expected, result.Output)
+		}
+	}
+
+	// Routing to a key without an executor fails
+	core.SetWorkRouter(NewRuleBasedWorkRouter(WorkRoute{Parameter: "region", Value: "us", ExecutorKey: "task-missing"}))
+	work := layer0.NewWork("work-missing", layer0.WorkTypeTask, "Work")
+	work.Configuration.Parameters["region"] = "us"
+	if _, err := core.ExecuteWork(work, workContext); err == nil {
+		t.Error("ExecuteWork should return error when the routed executor is not registered")
+	}
+
+	// A nil router restores routing by type
+	core.SetWorkRouter(nil)
+	if result, _ := core.ExecuteWork(work, workContext); result.Output != "default" {
+		t.Errorf("Expected type routing after reset, got %v", result.Output)
+	}
+}
@@ -0,0 +1,56 @@
+package layer1
+
+import (
+	"fmt"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// ValidatingExecutor is implemented by executors that can check work, such as its
+// executor configuration, without running it
+type ValidatingExecutor interface {
+	WorkExecutor
+	Validate(work layer0.Work) error
+}
+
+// WorkValidator checks work against a schema, such as the configuration schema an
+// executor describes, without running it
+type WorkValidator interface {
+	ValidateWork(work layer0.Work) error
+}
+
+// WorkValidatorFunc adapts a function to a WorkValidator
+type WorkValidatorFunc func(work layer0.Work) error
+
+// ValidateWork calls the function
+func (f WorkValidatorFunc) ValidateWork(work layer0.Work) error {
+	return f(work)
+}
+
+// ValidateWork checks that work is well formed and that the executor that would run
+// it, following the work router and the fallback executor, exists and accepts it.
+// Nothing is executed.
+func (wec *WorkExecutionCore) ValidateWork(work layer0.Work) error {
+	if err := work.Validate(); err != nil {
+		return fmt.Errorf("invalid work: %w", err)
+	}
+
+	wec.mutex.RLock()
+	executorKey := wec.router.Route(work)
+	executor, exists := wec.executors[executorKey]
+	if !exists {
+		executor = wec.fallback
+	}
+	wec.mutex.RUnlock()
+
+	if executor == nil {
+		return fmt.Errorf("no executor registered for key %s", executorKey)
+	}
+
+	if validating, ok := executor.(ValidatingExecutor); ok {
+		if err := validating.Validate(work); err != nil {
+			return fmt.Errorf("executor rejected work %s: %w", work.GetID(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package layer1
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// validatingExecutor is a mock executor that requires an endpoint parameter
+type validatingExecutor struct {
+	*MockWorkExecutor
+}
+
+func (ve *validatingExecutor) Validate(work layer0.Work) error {
+	if _, ok := work.GetConfiguration().Parameters["endpoint"].(string); !ok {
+		return errors.New("endpoint is required")
+	}
+	return nil
+}
+
+func TestWorkExecutionCoreValidateWork(t *testing.T) {
+	core := NewWorkExecutionCore()
+	core.RegisterExecutor(layer0.WorkTypeService, &validatingExecutor{
+		MockWorkExecutor: NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeService}, nil),
+	})
+	core.RegisterExecutor(layer0.WorkTypeScript, NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeScript}, nil))
+
+	valid := layer0.NewWork("call", layer0.WorkTypeService, "Call")
+	valid.Configuration.Parameters["endpoint"] = "orders:443"
+	if err := core.ValidateWork(valid); err != nil {
+		t.Errorf("ValidateWork should accept valid work: %v", err)
+	}
+
+	err := core.ValidateWork(layer0.NewWork("call", layer0.WorkTypeService, "Call"))
+	if err == nil || !strings.Contains(err.Error(), "endpoint is required") {
+		t.Errorf("Expected the executor to reject work without an endpoint, got %v", err)
+	}
+
+	// Executors that cannot validate accept any well formed work
+	if err := core.ValidateWork(layer0.NewWork("run", layer0.WorkTypeScript, "Run")); err != nil {
+		t.Errorf("ValidateWork should accept work for a non-validating executor: %v", err)
+	}
+
+	if err := core.ValidateWork(layer0.NewWork("review", layer0.WorkTypeHuman, "Review")); err == nil {
+		t.Error("ValidateWork should return error without an executor or fallback")
+	}
+}
@@ -2,6 +2,7 @@ package layer1
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/ubom/workflow/layer0"
@@ -51,17 +52,61 @@ type WorkflowDefinition struct {
 	ErrorStateIDs  []layer0.StateID           `json:"error_state_ids"`
 	GlobalContext  *layer0.Context            `json:"global_context"`
 	Configuration  WorkflowConfiguration      `json:"configuration"`
+	Invariants     []Invariant                `json:"invariants,omitempty"`
+}
+
+// Invariant is a named condition that must hold after every transition of an instance.
+// Unlike transition conditions, which guard whether a transition is taken, a violated
+// invariant fails the instance.
+type Invariant struct {
+	Name      string           `json:"name"`
+	Condition layer0.Condition `json:"condition"`
+}
+
+// TransitionSelectionStrategy defines which transition a step takes when several are satisfiable
+type TransitionSelectionStrategy string
+
+const (
+	// TransitionSelectionFirstMatch takes the first satisfiable transition in priority order
+	TransitionSelectionFirstMatch TransitionSelectionStrategy = "first_match"
+	// TransitionSelectionHighestPriority evaluates every transition and takes the highest
+	// priority satisfiable one; a tie at the highest priority is an error
+	TransitionSelectionHighestPriority TransitionSelectionStrategy = "highest_priority"
+	// TransitionSelectionAll runs the actions of every satisfiable transition in parallel;
+	// the transitions must share a target state
+	TransitionSelectionAll TransitionSelectionStrategy = "all"
+	// TransitionSelectionRandom takes a satisfiable transition chosen by the engine's seeded RNG
+	TransitionSelectionRandom TransitionSelectionStrategy = "random"
+)
+
+// Validate checks that the strategy is known; the empty strategy defers to the engine
+func (strategy TransitionSelectionStrategy) Validate() error {
+	switch strategy {
+	case "", TransitionSelectionFirstMatch, TransitionSelectionHighestPriority, TransitionSelectionAll, TransitionSelectionRandom:
+		return nil
+	default:
+		return fmt.Errorf("unknown transition selection strategy: %s", strategy)
+	}
 }
 
 // WorkflowConfiguration contains configuration settings for a workflow
 type WorkflowConfiguration struct {
-	MaxConcurrentInstances int               `json:"max_concurrent_instances"`
-	DefaultTimeoutSeconds  int               `json:"default_timeout_seconds"`
-	RetryPolicy            RetryPolicy       `json:"retry_policy"`
-	CompensationEnabled    bool              `json:"compensation_enabled"`
-	PersistenceEnabled     bool              `json:"persistence_enabled"`
-	LoggingLevel           string            `json:"logging_level"`
-	Environment            map[string]string `json:"environment"`
+	MaxConcurrentInstances int                         `json:"max_concurrent_instances"`
+	DefaultTimeoutSeconds  int                         `json:"default_timeout_seconds"`
+	RetryPolicy            RetryPolicy                 `json:"retry_policy"`
+	CompensationEnabled    bool                        `json:"compensation_enabled"`
+	PersistenceEnabled     bool                        `json:"persistence_enabled"` // Unset keeps instances only in engine memory; they do not survive a restart
+	LoggingLevel           string                      `json:"logging_level"`
+	Environment            map[string]string           `json:"environment"`
+	InstanceTTL            time.Duration               `json:"instance_ttl,omitempty"`            // Retention for finished instances; zero uses the engine default
+	MaxConcurrentWork      int                         `json:"max_concurrent_work,omitempty"`     // Work an instance may run at once; zero uses the engine's parallel action limit
+	TransitionSelection    TransitionSelectionStrategy `json:"transition_selection,omitempty"`    // Empty uses the engine's strategy
+	MinTransitionInterval  time.Duration               `json:"min_transition_interval,omitempty"` // Least time between an instance's transitions; zero uses the engine's limit
+	DisplayNameTemplate    string                      `json:"display_name_template,omitempty"`   // Instance display name with ${key} references to the initial context
+	StrictDisplayName      bool                        `json:"strict_display_name,omitempty"`     // Unresolved display name references fail the start instead of rendering blank
+	ContextDefaults        map[string]interface{}      `json:"context_defaults,omitempty"`        // Values for context keys an instance lacks, filled in when it is loaded or resumed
+	SLA                    time.Duration               `json:"sla,omitempty"`                     // Time an instance should take from start to finish; zero means no SLA
+	ContextPruning         ContextPruningPolicy        `json:"context_pruning,omitempty"`         // Work outputs dropped from instance contexts after each transition; off by default
 }
 
 // RetryPolicy defines retry behavior for workflow operations
@@ -71,6 +116,7 @@ type RetryPolicy struct {
 	MaxDelay          time.Duration `json:"max_delay"`
 	BackoffMultiplier float64       `json:"backoff_multiplier"`
 	RetryableErrors   []string      `json:"retryable_errors"`
+	InstanceBudget    int           `json:"instance_budget,omitempty"` // Total work retries allowed per instance; zero disables retries
 }
 
 // WorkflowDefinitionInterface defines the contract for workflow definition operations
@@ -85,6 +131,7 @@ type WorkflowDefinitionInterface interface {
 	GetErrorStateIDs() []layer0.StateID
 	GetGlobalContext() layer0.Context
 	GetConfiguration() WorkflowConfiguration
+	GetInvariants() []Invariant
 	SetStatus(status WorkflowDefinitionStatus) WorkflowDefinition
 	SetStateMachine(stateMachine *StateMachineCore) WorkflowDefinition
 	SetInitialStateID(stateID layer0.StateID) WorkflowDefinition
@@ -92,8 +139,11 @@ type WorkflowDefinitionInterface interface {
 	AddErrorStateID(stateID layer0.StateID) WorkflowDefinition
 	UpdateGlobalContext(context layer0.Context) WorkflowDefinition
 	UpdateConfiguration(config WorkflowConfiguration) WorkflowDefinition
+	AddInvariant(name string, condition layer0.Condition) WorkflowDefinition
 	Validate() error
+	ValidateAll() []error
 	Clone() WorkflowDefinition
+	ContentHash() string
 	IsActive() bool
 	CanExecute() bool
 }
@@ -188,6 +238,13 @@ func (wd WorkflowDefinition) GetConfiguration() WorkflowConfiguration {
 	return wd.Configuration
 }
 
+// GetInvariants returns the invariants checked after every transition
+func (wd WorkflowDefinition) GetInvariants() []Invariant {
+	invariants := make([]Invariant, len(wd.Invariants))
+	copy(invariants, wd.Invariants)
+	return invariants
+}
+
 // SetStatus creates a new workflow definition with updated status (immutable)
 func (wd WorkflowDefinition) SetStatus(status WorkflowDefinitionStatus) WorkflowDefinition {
 	newWd := wd.Clone()
@@ -244,6 +301,14 @@ func (wd WorkflowDefinition) UpdateConfiguration(config WorkflowConfiguration) W
 	return newWd
 }
 
+// AddInvariant creates a new workflow definition with an additional invariant (immutable)
+func (wd WorkflowDefinition) AddInvariant(name string, condition layer0.Condition) WorkflowDefinition {
+	newWd := wd.Clone()
+	newWd.Invariants = append(newWd.Invariants, Invariant{Name: name, Condition: condition.Clone()})
+	newWd.Metadata.UpdatedAt = time.Now()
+	return newWd
+}
+
 // IsActive checks if the workflow definition is active
 func (wd WorkflowDefinition) IsActive() bool {
 	return wd.Status == WorkflowDefinitionStatusActive
@@ -285,6 +350,7 @@ func (wd WorkflowDefinition) Clone() WorkflowDefinition {
 		MaxDelay:          wd.Configuration.RetryPolicy.MaxDelay,
 		BackoffMultiplier: wd.Configuration.RetryPolicy.BackoffMultiplier,
 		RetryableErrors:   make([]string, len(wd.Configuration.RetryPolicy.RetryableErrors)),
+		InstanceBudget:    wd.Configuration.RetryPolicy.InstanceBudget,
 	}
 	copy(retryPolicy.RetryableErrors, wd.Configuration.RetryPolicy.RetryableErrors)
 
@@ -293,6 +359,19 @@ func (wd WorkflowDefinition) Clone() WorkflowDefinition {
 		environment[k] = v
 	}
 
+	var contextDefaults map[string]interface{}
+	if wd.Configuration.ContextDefaults != nil {
+		contextDefaults = make(map[string]interface{}, len(wd.Configuration.ContextDefaults))
+		for k, v := range wd.Configuration.ContextDefaults {
+			contextDefaults[k] = v
+		}
+	}
+
+	var invariants []Invariant
+	for _, invariant := range wd.Invariants {
+		invariants = append(invariants, Invariant{Name: invariant.Name, Condition: invariant.Condition.Clone()})
+	}
+
 	configuration := WorkflowConfiguration{
 		MaxConcurrentInstances: wd.Configuration.MaxConcurrentInstances,
 		DefaultTimeoutSeconds:  wd.Configuration.DefaultTimeoutSeconds,
@@ -301,6 +380,15 @@ func (wd WorkflowDefinition) Clone() WorkflowDefinition {
 		PersistenceEnabled:     wd.Configuration.PersistenceEnabled,
 		LoggingLevel:           wd.Configuration.LoggingLevel,
 		Environment:            environment,
+		InstanceTTL:            wd.Configuration.InstanceTTL,
+		MaxConcurrentWork:      wd.Configuration.MaxConcurrentWork,
+		TransitionSelection:    wd.Configuration.TransitionSelection,
+		MinTransitionInterval:  wd.Configuration.MinTransitionInterval,
+		DisplayNameTemplate:    wd.Configuration.DisplayNameTemplate,
+		StrictDisplayName:      wd.Configuration.StrictDisplayName,
+		ContextDefaults:        contextDefaults,
+		SLA:                    wd.Configuration.SLA,
+		ContextPruning:         wd.Configuration.ContextPruning.Clone(),
 	}
 
 	return WorkflowDefinition{
@@ -314,88 +402,237 @@ func (wd WorkflowDefinition) Clone() WorkflowDefinition {
 		ErrorStateIDs:  errorStateIDs,
 		GlobalContext:  wd.GlobalContext.Clone(),
 		Configuration:  configuration,
+		Invariants:     invariants,
 	}
 }
 
 // Validate checks if the workflow definition is valid
 func (wd WorkflowDefinition) Validate() error {
+	if errs := wd.validate(false); len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
+// ValidateAll checks the workflow definition and returns every failure found
+// rather than stopping at the first, so authoring tools can report them together.
+// Unlike Validate it also reports states that are unreachable from the initial
+// state; these do not prevent execution but usually indicate an authoring mistake.
+func (wd WorkflowDefinition) ValidateAll() []error {
+	return wd.validate(true)
+}
+
+// validate collects validation failures, optionally including reachability
+func (wd WorkflowDefinition) validate(checkReachability bool) []error {
+	var errs []error
+
 	if wd.ID == "" {
-		return fmt.Errorf("workflow definition ID cannot be empty")
+		errs = append(errs, fmt.Errorf("workflow definition ID cannot be empty"))
 	}
 
 	if wd.Version == "" {
-		return fmt.Errorf("workflow definition version cannot be empty")
+		errs = append(errs, fmt.Errorf("workflow definition version cannot be empty"))
 	}
 
 	if wd.Status == "" {
-		return fmt.Errorf("workflow definition status cannot be empty")
+		errs = append(errs, fmt.Errorf("workflow definition status cannot be empty"))
 	}
 
 	if wd.Metadata.Name == "" {
-		return fmt.Errorf("workflow definition name cannot be empty")
+		errs = append(errs, fmt.Errorf("workflow definition name cannot be empty"))
 	}
 
 	if wd.StateMachine == nil {
-		return fmt.Errorf("workflow definition must have a state machine")
+		errs = append(errs, fmt.Errorf("workflow definition must have a state machine"))
+	} else {
+		errs = append(errs, wd.validateStates(checkReachability)...)
+	}
+
+	// Validate global context
+	if wd.GlobalContext == nil {
+		errs = append(errs, fmt.Errorf("workflow definition must have a global context"))
+	} else if err := wd.GlobalContext.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid global context: %w", err))
+	}
+
+	// Validate configuration
+	if wd.Configuration.MaxConcurrentInstances <= 0 {
+		errs = append(errs, fmt.Errorf("max concurrent instances must be positive"))
 	}
 
+	if wd.Configuration.DefaultTimeoutSeconds <= 0 {
+		errs = append(errs, fmt.Errorf("default timeout seconds must be positive"))
+	}
+
+	if wd.Configuration.MaxConcurrentWork < 0 {
+		errs = append(errs, fmt.Errorf("max concurrent work cannot be negative"))
+	}
+
+	if wd.Configuration.MinTransitionInterval < 0 {
+		errs = append(errs, fmt.Errorf("min transition interval cannot be negative"))
+	}
+
+	if err := wd.Configuration.TransitionSelection.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := wd.Configuration.ContextPruning.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if wd.Configuration.RetryPolicy.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("max retries cannot be negative"))
+	}
+
+	if wd.Configuration.RetryPolicy.InstanceBudget < 0 {
+		errs = append(errs, fmt.Errorf("instance retry budget cannot be negative"))
+	}
+
+	if wd.Configuration.RetryPolicy.InitialDelay < 0 {
+		errs = append(errs, fmt.Errorf("initial delay cannot be negative"))
+	}
+
+	if wd.Configuration.RetryPolicy.MaxDelay < wd.Configuration.RetryPolicy.InitialDelay {
+		errs = append(errs, fmt.Errorf("max delay cannot be less than initial delay"))
+	}
+
+	if wd.Configuration.RetryPolicy.BackoffMultiplier <= 0 {
+		errs = append(errs, fmt.Errorf("backoff multiplier must be positive"))
+	}
+
+	if wd.Configuration.InstanceTTL < 0 {
+		errs = append(errs, fmt.Errorf("instance TTL cannot be negative"))
+	}
+
+	// Validate invariants
+	invariantNames := make(map[string]bool, len(wd.Invariants))
+	for _, invariant := range wd.Invariants {
+		if invariant.Name == "" {
+			errs = append(errs, fmt.Errorf("invariant name cannot be empty"))
+		} else if invariantNames[invariant.Name] {
+			errs = append(errs, fmt.Errorf("duplicate invariant %s", invariant.Name))
+		}
+		invariantNames[invariant.Name] = true
+
+		if err := invariant.Condition.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid invariant %s: %w", invariant.Name, err))
+		}
+	}
+
+	return errs
+}
+
+// validateStates checks the state machine and the states the definition refers to
+func (wd WorkflowDefinition) validateStates(checkReachability bool) []error {
+	var errs []error
+
 	// Validate state machine
-	if err := wd.StateMachine.ValidateStateMachine(); err != nil {
-		return fmt.Errorf("invalid state machine: %w", err)
+	for _, err := range wd.StateMachine.ValidateAll() {
+		errs = append(errs, fmt.Errorf("invalid state machine: %w", err))
 	}
 
 	// Validate initial state
+	initialStateValid := false
 	if wd.InitialStateID == "" {
-		return fmt.Errorf("workflow definition must have an initial state")
-	}
-
-	if _, err := wd.StateMachine.GetState(wd.InitialStateID); err != nil {
-		return fmt.Errorf("initial state %s does not exist in state machine: %w", wd.InitialStateID, err)
+		errs = append(errs, fmt.Errorf("workflow definition must have an initial state"))
+	} else if _, err := wd.StateMachine.GetState(wd.InitialStateID); err != nil {
+		errs = append(errs, fmt.Errorf("initial state %s does not exist in state machine: %w", wd.InitialStateID, err))
+	} else {
+		initialStateValid = true
 	}
 
 	// Validate final states
 	for _, stateID := range wd.FinalStateIDs {
 		if _, err := wd.StateMachine.GetState(stateID); err != nil {
-			return fmt.Errorf("final state %s does not exist in state machine: %w", stateID, err)
+			errs = append(errs, fmt.Errorf("final state %s does not exist in state machine: %w", stateID, err))
 		}
 	}
 
 	// Validate error states
+	errorStates := make(map[layer0.StateID]bool)
 	for _, stateID := range wd.ErrorStateIDs {
+		errorStates[stateID] = true
 		if _, err := wd.StateMachine.GetState(stateID); err != nil {
-			return fmt.Errorf("error state %s does not exist in state machine: %w", stateID, err)
+			errs = append(errs, fmt.Errorf("error state %s does not exist in state machine: %w", stateID, err))
 		}
 	}
 
-	// Validate global context
-	if err := wd.GlobalContext.Validate(); err != nil {
-		return fmt.Errorf("invalid global context: %w", err)
+	errs = append(errs, wd.validateTerminalTransitions(initialStateValid)...)
+
+	if !checkReachability || !initialStateValid {
+		return errs
 	}
 
-	// Validate configuration
-	if wd.Configuration.MaxConcurrentInstances <= 0 {
-		return fmt.Errorf("max concurrent instances must be positive")
+	// Every state must be reachable from the initial state. Error states are
+	// exempt because the engine enters them on failure rather than by transition.
+	reachable := map[layer0.StateID]bool{wd.InitialStateID: true}
+	queue := []layer0.StateID{wd.InitialStateID}
+	for len(queue) > 0 {
+		stateID := queue[0]
+		queue = queue[1:]
+		for _, transition := range wd.StateMachine.GetTransitionsFromState(stateID) {
+			if !reachable[transition.GetToStateID()] {
+				reachable[transition.GetToStateID()] = true
+				queue = append(queue, transition.GetToStateID())
+			}
+		}
 	}
 
-	if wd.Configuration.DefaultTimeoutSeconds <= 0 {
-		return fmt.Errorf("default timeout seconds must be positive")
+	states := wd.StateMachine.GetAllStates()
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].GetID() < states[j].GetID()
+	})
+	for _, state := range states {
+		if !reachable[state.GetID()] && !errorStates[state.GetID()] {
+			errs = append(errs, fmt.Errorf("state %s is unreachable from initial state %s", state.GetID(), wd.InitialStateID))
+		}
 	}
 
-	if wd.Configuration.RetryPolicy.MaxRetries < 0 {
-		return fmt.Errorf("max retries cannot be negative")
+	return errs
+}
+
+// validateTerminalTransitions rejects transitions leaving a final state or entering the initial state
+func (wd WorkflowDefinition) validateTerminalTransitions(initialStateValid bool) []error {
+	var errs []error
+
+	finalStates := make(map[layer0.StateID]bool)
+	for _, stateID := range wd.FinalStateIDs {
+		finalStates[stateID] = true
+	}
+	for _, state := range wd.StateMachine.GetAllStates() {
+		if state.IsFinal() {
+			finalStates[state.GetID()] = true
+		}
 	}
 
-	if wd.Configuration.RetryPolicy.InitialDelay < 0 {
-		return fmt.Errorf("initial delay cannot be negative")
+	finalStateIDs := make([]layer0.StateID, 0, len(finalStates))
+	for stateID := range finalStates {
+		finalStateIDs = append(finalStateIDs, stateID)
 	}
+	sort.Slice(finalStateIDs, func(i, j int) bool {
+		return finalStateIDs[i] < finalStateIDs[j]
+	})
 
-	if wd.Configuration.RetryPolicy.MaxDelay < wd.Configuration.RetryPolicy.InitialDelay {
-		return fmt.Errorf("max delay cannot be less than initial delay")
+	for _, stateID := range finalStateIDs {
+		for _, transition := range sortedTransitions(wd.StateMachine.GetTransitionsFromState(stateID)) {
+			errs = append(errs, fmt.Errorf("final state %s has outgoing transition %s", stateID, transition.GetID()))
+		}
 	}
 
-	if wd.Configuration.RetryPolicy.BackoffMultiplier <= 0 {
-		return fmt.Errorf("backoff multiplier must be positive")
+	if initialStateValid {
+		for _, transition := range sortedTransitions(wd.StateMachine.GetTransitionsToState(wd.InitialStateID)) {
+			errs = append(errs, fmt.Errorf("initial state %s has incoming transition %s", wd.InitialStateID, transition.GetID()))
+		}
 	}
 
-	return nil
+	return errs
+}
+
+// sortedTransitions orders transitions by ID so validation errors are deterministic
+func sortedTransitions(transitions []layer0.Transition) []layer0.Transition {
+	sort.Slice(transitions, func(i, j int) bool {
+		return transitions[i].GetID() < transitions[j].GetID()
+	})
+	return transitions
 }
@@ -1,6 +1,7 @@
 package layer1
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -428,3 +429,181 @@ func TestWorkflowDefinitionValidateWithNonExistentErrorState(t *testing.T) {
 		t.Error("Workflow definition with non-existent error state should return error")
 	}
 }
+
+func TestWorkflowDefinitionValidateAll(t *testing.T) {
+	stateMachine := NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("initial", layer0.StateTypeInitial, "Initial State"))
+	stateMachine.AddState(layer0.NewState("final", layer0.StateTypeFinal, "Final State"))
+	stateMachine.AddState(layer0.NewState("orphan", layer0.StateTypeIntermediate, "Orphan State"))
+	stateMachine.AddTransition(layer0.NewTransition("initial-to-final", layer0.TransitionTypeAutomatic, "initial", "final", "Initial to Final"))
+
+	// Inject a dangling transition directly; AddTransition would reject it
+	stateMachine.transitions["dangling"] = layer0.NewTransition("dangling", layer0.TransitionTypeAutomatic, "initial", "missing", "Dangling")
+
+	wd := NewWorkflowDefinition("test", "1.0.0", "Test")
+	config := wd.GetConfiguration()
+	config.MaxConcurrentInstances = 0
+	config.RetryPolicy.MaxRetries = -1
+
+	wd = wd.SetStateMachine(stateMachine).
+		SetInitialStateID("initial").
+		AddFinalStateID("final").
+		AddFinalStateID("non-existent").
+		UpdateConfiguration(config)
+
+	errs := wd.ValidateAll()
+
+	// Each distinct problem should be reported with the offending ID
+	expected := []string{
+		"dangling",
+		"missing",
+		"final state non-existent",
+		"state orphan is unreachable",
+		"max concurrent instances",
+		"max retries",
+	}
+	for _, fragment := range expected {
+		found := false
+		for _, err := range errs {
+			if strings.Contains(err.Error(), fragment) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("ValidateAll should report an error containing %q, got %v", fragment, errs)
+		}
+	}
+
+	// Validate returns the first failure
+	if err := wd.Validate(); err == nil || err.Error() != errs[0].Error() {
+		t.Errorf("Validate should return the first ValidateAll error, got %v", err)
+	}
+
+	// A valid definition reports nothing
+	validMachine := NewStateMachineCore()
+	validMachine.AddState(layer0.NewState("initial", layer0.StateTypeInitial, "Initial State"))
+	valid := NewWorkflowDefinition("valid", "1.0.0", "Valid").
+		SetStateMachine(validMachine).
+		SetInitialStateID("initial")
+
+	if errs := valid.ValidateAll(); len(errs) != 0 {
+		t.Errorf("Valid definition should have no errors, got %v", errs)
+	}
+}
+
+func TestWorkflowDefinitionValidateTerminalTransitions(t *testing.T) {
+	newDefinition := func(stateMachine *StateMachineCore) WorkflowDefinition {
+		return NewWorkflowDefinition("test", "1.0.0", "Test").
+			SetStateMachine(stateMachine).
+			SetInitialStateID("initial").
+			AddFinalStateID("final")
+	}
+
+	newStateMachine := func() *StateMachineCore {
+		stateMachine := NewStateMachineCore()
+		stateMachine.AddState(layer0.NewState("initial", layer0.StateTypeInitial, "Initial State"))
+		stateMachine.AddState(layer0.NewState("work", layer0.StateTypeIntermediate, "Work State"))
+		stateMachine.AddState(layer0.NewState("final", layer0.StateTypeFinal, "Final State"))
+		stateMachine.AddTransition(layer0.NewTransition("initial-to-work", layer0.TransitionTypeAutomatic, "initial", "work", "Initial to Work"))
+		stateMachine.AddTransition(layer0.NewTransition("work-to-final", layer0.TransitionTypeAutomatic, "work", "final", "Work to Final"))
+		return stateMachine
+	}
+
+	// A clean definition is accepted
+	if err := newDefinition(newStateMachine()).Validate(); err != nil {
+		t.Errorf("Clean definition should be valid: %v", err)
+	}
+
+	// A final state with an outgoing transition is rejected
+	leaky := newStateMachine()
+	leaky.AddTransition(layer0.NewTransition("final-to-work", layer0.TransitionTypeAutomatic, "final", "work", "Final to Work"))
+
+	if err := newDefinition(leaky).Validate(); err == nil {
+		t.Error("Final state with outgoing transition should be rejected")
+	}
+
+	if !containsValidationError(newDefinition(leaky).ValidateAll(), "final state final has outgoing transition final-to-work") {
+		t.Error("Expected outgoing transition from final state to name the state and transition")
+	}
+
+	// An initial state with an incoming transition is rejected
+	looping := newStateMachine()
+	looping.AddTransition(layer0.NewTransition("work-to-initial", layer0.TransitionTypeAutomatic, "work", "initial", "Work to Initial"))
+
+	if !containsValidationError(newDefinition(looping).ValidateAll(), "initial state initial has incoming transition work-to-initial") {
+		t.Error("Expected incoming transition to initial state to be reported")
+	}
+}
+
+func containsValidationError(errs []error, fragment string) bool {
+	for _, err := range errs {
+		if strings.Contains(err.Error(), fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWorkflowDefinitionInvariants(t *testing.T) {
+	stateMachine := NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("initial", layer0.StateTypeInitial, "Initial State"))
+	stateMachine.AddState(layer0.NewState("final", layer0.StateTypeFinal, "Final State"))
+	stateMachine.AddTransition(layer0.NewTransition("t1", layer0.TransitionTypeAutomatic, "initial", "final", "Transition"))
+
+	condition := layer0.NewCondition("positive", layer0.ConditionTypeExpression, "Positive")
+	condition.Expression.Expression = "total >= 0"
+
+	wd := NewWorkflowDefinition("test", "1.0.0", "Test").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("initial").
+		AddFinalStateID("final")
+	withInvariant := wd.AddInvariant("total-never-negative", condition)
+
+	if len(wd.GetInvariants()) != 0 {
+		t.Error("AddInvariant should not modify the original definition")
+	}
+	if invariants := withInvariant.GetInvariants(); len(invariants) != 1 || invariants[0].Name != "total-never-negative" {
+		t.Errorf("Expected the total-never-negative invariant, got %v", invariants)
+	}
+	if err := withInvariant.Validate(); err != nil {
+		t.Errorf("Definition with a valid invariant should not return error: %v", err)
+	}
+	if len(withInvariant.Clone().GetInvariants()) != 1 {
+		t.Error("Clone should keep the invariants")
+	}
+
+	if err := withInvariant.AddInvariant("total-never-negative", condition).Validate(); err == nil {
+		t.Error("Duplicate invariant names should be invalid")
+	}
+	if err := withInvariant.AddInvariant("", condition).Validate(); err == nil {
+		t.Error("An unnamed invariant should be invalid")
+	}
+}
+
+func TestWorkflowDefinitionTransitionSelection(t *testing.T) {
+	stateMachine := NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("initial", layer0.StateTypeInitial, "Initial State"))
+	stateMachine.AddState(layer0.NewState("final", layer0.StateTypeFinal, "Final State"))
+	stateMachine.AddTransition(layer0.NewTransition("t1", layer0.TransitionTypeAutomatic, "initial", "final", "Transition"))
+
+	wd := NewWorkflowDefinition("test", "1.0.0", "Test").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("initial").
+		AddFinalStateID("final")
+
+	config := wd.GetConfiguration()
+	config.TransitionSelection = TransitionSelectionRandom
+	wd = wd.UpdateConfiguration(config)
+	if err := wd.Validate(); err != nil {
+		t.Errorf("Definition with a known strategy should not return error: %v", err)
+	}
+	if wd.Clone().GetConfiguration().TransitionSelection != TransitionSelectionRandom {
+		t.Error("Clone should keep the transition selection strategy")
+	}
+
+	config.TransitionSelection = "bogus"
+	if err := wd.UpdateConfiguration(config).Validate(); err == nil {
+		t.Error("Definition with an unknown strategy should return error")
+	}
+}
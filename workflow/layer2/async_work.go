@@ -0,0 +1,415 @@
+package layer2
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// errWorkSuspended signals that a transition stopped at work pending asynchronous completion
+var errWorkSuspended = errors.New("transition suspended on pending work")
+
+// isAwaitingCompletion checks if work is pending or blocked until it is completed externally
+func isAwaitingCompletion(status layer0.WorkStatus) bool {
+	return status == layer0.WorkStatusPending || status == layer0.WorkStatusBlocked
+}
+
+// Work metadata properties recorded on persisted pending work so a completion
+// can resume the transition, even after an engine restart
+const (
+	pendingWorkTransitionProperty = "transition_id"
+	pendingWorkActionProperty     = "action_index"
+	pendingWorkStagedProperty     = "staged_context_id"
+)
+
+// AsyncWorkResult is reported by an external worker when it finishes async work
+type AsyncWorkResult struct {
+	Output interface{} `json:"output"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// RegisterExecutor registers a work executor with the engine
+func (engine *WorkflowRuntimeEngine) RegisterExecutor(workType layer0.WorkType, executor layer1.WorkExecutor) error {
+	return engine.workExecutionCore.RegisterExecutor(workType, executor)
+}
+
+// SetExecutorTimeout bounds how long the executor registered under workType may run each work
+func (engine *WorkflowRuntimeEngine) SetExecutorTimeout(workType layer0.WorkType, timeout time.Duration) error {
+	return engine.workExecutionCore.SetExecutorTimeout(workType, timeout)
+}
+
+// SetWorkRouter sets the router that selects which registered executor runs each work
+func (engine *WorkflowRuntimeEngine) SetWorkRouter(router layer1.WorkRouter) {
+	engine.workExecutionCore.SetWorkRouter(router)
+}
+
+// SetFallbackExecutor sets the executor that runs work of types no registered executor
+// handles, such as work types still being rolled out. A nil executor removes it.
+func (engine *WorkflowRuntimeEngine) SetFallbackExecutor(executor layer1.WorkExecutor) {
+	engine.workExecutionCore.SetFallbackExecutor(executor)
+}
+
+// SetCheckpointStore sets the store checkpointable executors save work progress to,
+// so work re-executed after a crash resumes from its last checkpoint
+func (engine *WorkflowRuntimeEngine) SetCheckpointStore(store layer1.CheckpointStore) {
+	engine.workExecutionCore.SetCheckpointStore(store)
+}
+
+// RegisterActionWork registers the work executed for a transition action.
+// Actions without registered work run as task work with the action ID.
+func (engine *WorkflowRuntimeEngine) RegisterActionWork(actionID string, work layer0.Work) error {
+	if actionID == "" {
+		return fmt.Errorf("action ID cannot be empty")
+	}
+
+	if err := work.Validate(); err != nil {
+		return fmt.Errorf("invalid work for action %s: %w", actionID, err)
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.actionWork[actionID] = work.Clone()
+	return nil
+}
+
+// buildActionWork creates the work to execute for an action of an instance
+func (engine *WorkflowRuntimeEngine) buildActionWork(instanceID WorkflowInstanceID, actionID string) layer0.Work {
+	work := engine.actionWorkTemplate(actionID)
+
+	// Work inherits the priority of an instance started with one
+	if priority := engine.instancePriority(instanceID); priority != 0 {
+		work.Priority = priority
+	}
+
+	work.Metadata.Properties[layer1.WorkPropertyInstanceID] = string(instanceID)
+	return work
+}
+
+// actionWorkTemplate creates the work registered for an action, or task work with
+// the action ID when none is registered
+func (engine *WorkflowRuntimeEngine) actionWorkTemplate(actionID string) layer0.Work {
+	engine.mutex.RLock()
+	template, registered := engine.actionWork[actionID]
+	engine.mutex.RUnlock()
+
+	if !registered {
+		return layer0.NewWork(layer0.WorkID(actionID), layer0.WorkTypeTask, fmt.Sprintf("Action %s", actionID))
+	}
+
+	work := template.Clone()
+	work.ID = layer0.WorkID(actionID)
+	work.Status = layer0.WorkStatusPending
+	return work
+}
+
+// suspendForAsyncWork persists the pending or blocked work along with the context
+// staged by the transition so far, and moves the instance to waiting. The instance
+// context is left as it was before the transition.
+func (engine *WorkflowRuntimeEngine) suspendForAsyncWork(instance *WorkflowInstance, transition layer0.Transition, actionIndex int, work layer0.Work, status layer0.WorkStatus, staged *layer0.Context) error {
+	if instance.Ephemeral {
+		return fmt.Errorf("workflow instance %s is not persisted and cannot wait for work %s", instance.ID, work.GetID())
+	}
+
+	stagedID, err := engine.saveStagedContext(instance.ID, work.GetID(), staged)
+	if err != nil {
+		return err
+	}
+
+	pending := work.Clone()
+	pending.Status = status
+	pending.Metadata.Properties[pendingWorkTransitionProperty] = string(transition.GetID())
+	pending.Metadata.Properties[pendingWorkActionProperty] = strconv.Itoa(actionIndex)
+	pending.Metadata.Properties[pendingWorkStagedProperty] = string(stagedID)
+
+	// Work from an earlier attempt of the same action is replaced
+	if err := engine.saveOrUpdateWork(instance.ID, pending); err != nil {
+		return fmt.Errorf("failed to persist pending work %s: %w", pending.GetID(), err)
+	}
+
+	if pending.GetType() == layer0.WorkTypeHumanTask {
+		if err := engine.issueHumanTaskToken(instance.ID, pending); err != nil {
+			return err
+		}
+	}
+
+	engine.mutex.Lock()
+	if err := engine.ensureRunningUnsafe(instance); err != nil {
+		engine.mutex.Unlock()
+		return err
+	}
+	instance.Status = WorkflowInstanceStatusWaiting
+	instance.UpdatedAt = engine.clock.Now()
+	engine.mutex.Unlock()
+
+	if err := engine.updateInstance(*instance); err != nil {
+		return fmt.Errorf("failed to update workflow instance: %w", err)
+	}
+
+	return nil
+}
+
+// saveStagedContext persists the context staged by a transition suspended on work,
+// returning the ID it is saved under. A later suspension on the same work replaces it,
+// and it is discarded once the work is completed or failed.
+func (engine *WorkflowRuntimeEngine) saveStagedContext(instanceID WorkflowInstanceID, workID layer0.WorkID, staged *layer0.Context) (layer0.ContextID, error) {
+	saved := staged.Clone()
+	saved.ID = layer0.ContextID("staged_" + string(workID))
+
+	if _, err := engine.persistenceStore.GetContext(instanceID, saved.ID); err == nil {
+		err = engine.persistenceStore.UpdateContext(instanceID, saved)
+		if err != nil {
+			return "", fmt.Errorf("failed to update staged context of work %s: %w", workID, err)
+		}
+		return saved.ID, nil
+	}
+
+	if err := engine.persistenceStore.SaveContext(instanceID, saved); err != nil {
+		return "", fmt.Errorf("failed to save staged context of work %s: %w", workID, err)
+	}
+	return saved.ID, nil
+}
+
+// loadStagedContext returns the context staged by the transition suspended on pending
+// work, under the ID of the instance context. Work suspended before staged contexts
+// were saved resumes from a copy of the instance context.
+func (engine *WorkflowRuntimeEngine) loadStagedContext(instance *WorkflowInstance, pending layer0.Work) (*layer0.Context, error) {
+	stagedID, saved := pending.GetMetadata().Properties[pendingWorkStagedProperty]
+	if !saved {
+		engine.mutex.RLock()
+		defer engine.mutex.RUnlock()
+		return instance.Context.Clone(), nil
+	}
+
+	persisted, err := engine.persistenceStore.GetContext(instance.ID, layer0.ContextID(stagedID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load staged context of work %s: %w", pending.GetID(), err)
+	}
+
+	staged := persisted.Clone()
+	engine.mutex.RLock()
+	staged.ID = instance.Context.GetID()
+	engine.mutex.RUnlock()
+	return staged, nil
+}
+
+// discardStagedContext deletes the context staged by the transition suspended on
+// work that has been completed or failed. A context left behind only takes up
+// space, so failures are reported to the error handler.
+func (engine *WorkflowRuntimeEngine) discardStagedContext(instanceID WorkflowInstanceID, pending layer0.Work) {
+	stagedID, saved := pending.GetMetadata().Properties[pendingWorkStagedProperty]
+	if !saved {
+		return
+	}
+
+	if err := engine.persistenceStore.DeleteContext(instanceID, layer0.ContextID(stagedID)); err != nil {
+		engine.errorHandler.HandleError(instanceID, fmt.Errorf("failed to delete staged context of work %s: %w", pending.GetID(), err))
+	}
+}
+
+// CompleteAsyncWork resolves work that was pending asynchronous completion or blocked.
+// On success the suspended transition finishes its remaining actions, commits
+// and the instance continues executing; on failure the work is marked failed, the
+// outputs staged by the suspended transition are discarded and the instance resumes
+// from its current state so the transition can be retried. Human task work is
+// rejected; it is completed with its token through CompleteHumanTask.
+func (engine *WorkflowRuntimeEngine) CompleteAsyncWork(instanceID WorkflowInstanceID, workID layer0.WorkID, result AsyncWorkResult) error {
+	issuedAt := engine.clock.Now()
+	err := engine.completeAsyncWork(instanceID, workID, result, nil)
+	engine.recordCommand(Command{Type: CommandComplete, InstanceID: instanceID, WorkID: workID, Result: &result, IssuedAt: issuedAt}, err)
+	return err
+}
+
+// humanTaskCompletion carries the values a redeemed human task sets in the context
+type humanTaskCompletion struct {
+	values map[string]interface{}
+}
+
+// completeAsyncWork resolves pending or blocked work without recording the command.
+// Human task work is only completed through its token, with human set; on success
+// its values are set in the staged context before the transition resumes.
+func (engine *WorkflowRuntimeEngine) completeAsyncWork(instanceID WorkflowInstanceID, workID layer0.WorkID, result AsyncWorkResult, human *humanTaskCompletion) error {
+	instance, err := engine.loadWaitingInstance(instanceID)
+	if err != nil {
+		return err
+	}
+
+	release, err := engine.claimPendingWork(instanceID, workID)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// The suspended transition belongs to the instance's definition, which an
+	// engine that restarted since the instance was suspended has yet to load
+	if err := engine.loadInstanceDefinition(*instance); err != nil {
+		return err
+	}
+
+	pending, err := engine.persistenceStore.GetWork(instanceID, workID)
+	if err != nil {
+		return fmt.Errorf("pending work %s not found for instance %s: %w", workID, instanceID, err)
+	}
+
+	if !isAwaitingCompletion(pending.GetStatus()) {
+		return fmt.Errorf("work %s is not pending (status: %s)", workID, pending.GetStatus())
+	}
+
+	if pending.GetType() == layer0.WorkTypeHumanTask && human == nil {
+		return fmt.Errorf("%w: human task %s must be completed with its token", ErrInvalidHumanTaskToken, workID)
+	}
+
+	transition, err := engine.stateMachineCore.GetTransition(layer0.TransitionID(pending.GetMetadata().Properties[pendingWorkTransitionProperty]))
+	if err != nil {
+		return fmt.Errorf("failed to resolve transition for work %s: %w", workID, err)
+	}
+
+	actionIndex, err := strconv.Atoi(pending.GetMetadata().Properties[pendingWorkActionProperty])
+	if err != nil {
+		return fmt.Errorf("invalid action index for work %s: %w", workID, err)
+	}
+
+	output := result.Output
+	if result.Error == "" {
+		if output, err = engine.transformOutput(pending, result.Output); err != nil {
+			return err
+		}
+	}
+
+	// Move the instance back to running, then record the outcome of the work
+	if err := engine.resumeWaitingInstance(instance); err != nil {
+		return err
+	}
+
+	completed := pending.MarkCompleted(result.Output)
+	if result.Error != "" {
+		completed = pending.MarkFailed(result.Error)
+	}
+	if err := engine.persistenceStore.UpdateWork(instanceID, completed); err != nil {
+		engine.mutex.Lock()
+		instance.Status = WorkflowInstanceStatusWaiting
+		engine.mutex.Unlock()
+		if restoreErr := engine.updateInstance(*instance); restoreErr != nil {
+			engine.errorHandler.HandleError(instanceID, fmt.Errorf("failed to restore waiting workflow instance: %w", restoreErr))
+		}
+		return fmt.Errorf("failed to update work %s: %w", workID, err)
+	}
+	engine.dropHumanTask(instanceID, workID)
+
+	if result.Error != "" {
+		engine.discardStagedContext(instanceID, pending)
+		if err := engine.updateInstance(*instance); err != nil {
+			return fmt.Errorf("failed to update workflow instance: %w", err)
+		}
+		return engine.errorHandler.HandleError(instanceID, fmt.Errorf("async work %s failed: %s", workID, result.Error))
+	}
+
+	staged, err := engine.loadStagedContext(instance, pending)
+	if err != nil {
+		return err
+	}
+	engine.discardStagedContext(instanceID, pending)
+
+	if human != nil {
+		keys := make([]string, 0, len(human.values))
+		for key := range human.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			staged = staged.Set(key, human.values[key])
+		}
+	}
+	if output != nil {
+		staged = staged.Set(actionOutputKey(pending), output)
+	}
+
+	// Finish the remaining actions and commit the transition
+	if _, err := engine.runTransition(instanceID, transition, actionIndex+1, staged); err != nil {
+		if errors.Is(err, errWorkSuspended) {
+			return nil
+		}
+		return fmt.Errorf("failed to resume transition %s: %w", transition.GetID(), err)
+	}
+
+	if engine.IsDebugEnabled(instanceID) {
+		return nil
+	}
+
+	return engine.executeWorkflow(instanceID)
+}
+
+// pendingWorkKey identifies work of an instance
+type pendingWorkKey struct {
+	instanceID WorkflowInstanceID
+	workID     layer0.WorkID
+}
+
+// claimPendingWork claims the completion of pending work, so concurrent completions
+// of the same work cannot both resume its transition. The returned function
+// releases the claim.
+func (engine *WorkflowRuntimeEngine) claimPendingWork(instanceID WorkflowInstanceID, workID layer0.WorkID) (func(), error) {
+	key := pendingWorkKey{instanceID: instanceID, workID: workID}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if engine.completingWork[key] {
+		return nil, fmt.Errorf("work %s of instance %s is already being completed", workID, instanceID)
+	}
+	engine.completingWork[key] = true
+
+	return func() {
+		engine.mutex.Lock()
+		defer engine.mutex.Unlock()
+		delete(engine.completingWork, key)
+	}, nil
+}
+
+// resumeWaitingInstance moves a waiting instance back to running, claiming the
+// status change in the store so a concurrent change of the instance wins
+func (engine *WorkflowRuntimeEngine) resumeWaitingInstance(instance *WorkflowInstance) error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if instance.Status != WorkflowInstanceStatusWaiting {
+		return fmt.Errorf("workflow instance %s is not waiting for async work", instance.ID)
+	}
+
+	if err := engine.guardStatus(instance, WorkflowInstanceStatusRunning); err != nil {
+		return err
+	}
+
+	instance.Status = WorkflowInstanceStatusRunning
+	instance.UpdatedAt = engine.clock.Now()
+	return nil
+}
+
+// loadWaitingInstance returns a waiting instance, restoring it from the
+// persistence store if the engine restarted since it was suspended
+func (engine *WorkflowRuntimeEngine) loadWaitingInstance(instanceID WorkflowInstanceID) (*WorkflowInstance, error) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	instance, exists := engine.activeInstances[instanceID]
+	if !exists {
+		persisted, err := engine.persistenceStore.GetWorkflowInstance(instanceID)
+		if err != nil {
+			return nil, fmt.Errorf("workflow instance %s not found", instanceID)
+		}
+		instance = &persisted
+		engine.applyContextDefaultsUnsafe(instance)
+	}
+
+	if instance.Status != WorkflowInstanceStatusWaiting {
+		return nil, fmt.Errorf("workflow instance %s is not waiting for async work", instanceID)
+	}
+
+	engine.activeInstances[instanceID] = instance
+	return instance, nil
+}
@@ -0,0 +1,248 @@
+package layer2
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ubom/workflow/layer1"
+)
+
+// FlushPolicy controls when a BufferedStatePersistenceStore writes buffered instance updates
+type FlushPolicy struct {
+	// BatchSize flushes once this many updates are buffered; zero disables size-based flushing
+	BatchSize int `json:"batch_size"`
+	// Interval flushes updates buffered for longer than this; zero disables interval flushing
+	Interval time.Duration `json:"interval"`
+	// CheckpointStatuses flush immediately when an instance enters one of them.
+	// Terminal statuses always flush immediately.
+	CheckpointStatuses []WorkflowInstanceStatus `json:"checkpoint_statuses,omitempty"`
+}
+
+// BufferedStatePersistenceStore coalesces instance updates in memory and writes them
+// to the underlying store according to a FlushPolicy, reducing write amplification
+// during a run. Reads see buffered updates. Updates of instances entering a terminal
+// or checkpoint status are flushed synchronously, so finished instances are durable,
+// but a crash between flushes loses the updates buffered since the last flush.
+// All operations other than instance updates pass straight through.
+type BufferedStatePersistenceStore struct {
+	StatePersistenceStore
+	policy        FlushPolicy
+	pending       map[WorkflowInstanceID]WorkflowInstance
+	buffered      int
+	oldestPending time.Time
+	mutex         sync.Mutex
+}
+
+// NewBufferedStatePersistenceStore creates a new buffered store writing to the given store
+func NewBufferedStatePersistenceStore(store StatePersistenceStore, policy FlushPolicy) *BufferedStatePersistenceStore {
+	return &BufferedStatePersistenceStore{
+		StatePersistenceStore: store,
+		policy:                policy,
+		pending:               make(map[WorkflowInstanceID]WorkflowInstance),
+		mutex:                 sync.Mutex{},
+	}
+}
+
+// UpdateWorkflowInstance buffers the update, flushing if the policy calls for it.
+// Errors from the underlying store surface on the flush that writes the update.
+func (store *BufferedStatePersistenceStore) UpdateWorkflowInstance(instance WorkflowInstance) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if len(store.pending) == 0 {
+		store.oldestPending = time.Now()
+	}
+	store.pending[instance.ID] = instance
+	store.buffered++
+
+	if store.shouldFlushUnsafe(instance) {
+		return store.flushUnsafe()
+	}
+	return nil
+}
+
+// shouldFlushUnsafe checks the flush policy after buffering an update.
+// This method assumes the caller already holds the mutex lock.
+func (store *BufferedStatePersistenceStore) shouldFlushUnsafe(instance WorkflowInstance) bool {
+	if instance.IsTerminal() {
+		return true
+	}
+
+	for _, status := range store.policy.CheckpointStatuses {
+		if instance.Status == status {
+			return true
+		}
+	}
+
+	if store.policy.BatchSize > 0 && store.buffered >= store.policy.BatchSize {
+		return true
+	}
+
+	return store.policy.Interval > 0 && time.Since(store.oldestPending) >= store.policy.Interval
+}
+
+// Flush writes every buffered update to the underlying store
+func (store *BufferedStatePersistenceStore) Flush() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.flushUnsafe()
+}
+
+// flushUnsafe writes buffered updates in instance ID order. Updates that fail stay buffered.
+// This method assumes the caller already holds the mutex lock.
+func (store *BufferedStatePersistenceStore) flushUnsafe() error {
+	instanceIDs := make([]WorkflowInstanceID, 0, len(store.pending))
+	for instanceID := range store.pending {
+		instanceIDs = append(instanceIDs, instanceID)
+	}
+	sort.Slice(instanceIDs, func(i, j int) bool {
+		return instanceIDs[i] < instanceIDs[j]
+	})
+
+	var firstErr error
+	for _, instanceID := range instanceIDs {
+		if err := store.StatePersistenceStore.UpdateWorkflowInstance(store.pending[instanceID]); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to flush workflow instance %s: %w", instanceID, err)
+			}
+			continue
+		}
+		delete(store.pending, instanceID)
+	}
+
+	store.buffered = len(store.pending)
+	store.oldestPending = time.Now()
+	return firstErr
+}
+
+// PendingUpdates returns the number of instances with buffered updates
+func (store *BufferedStatePersistenceStore) PendingUpdates() int {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return len(store.pending)
+}
+
+// StartIntervalFlush flushes buffered updates on the policy's interval until the
+// returned stop function is called, which flushes one last time. Flush errors are
+// passed to onError when it is set.
+func (store *BufferedStatePersistenceStore) StartIntervalFlush(onError func(error)) (stop func()) {
+	interval := store.policy.Interval
+	if interval <= 0 {
+		return func() {}
+	}
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	var once sync.Once
+
+	report := func(err error) {
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				report(store.Flush())
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+			report(store.Flush())
+		})
+	}
+}
+
+// CompareAndSetStatus flushes buffered updates, so the guard sees the latest
+// status, and then sets the status in the underlying store
+func (store *BufferedStatePersistenceStore) CompareAndSetStatus(instanceID WorkflowInstanceID, expected, status WorkflowInstanceStatus) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if err := store.flushUnsafe(); err != nil {
+		return err
+	}
+	return store.StatePersistenceStore.CompareAndSetStatus(instanceID, expected, status)
+}
+
+// GetWorkflowInstance returns the buffered version of an instance if it has one
+func (store *BufferedStatePersistenceStore) GetWorkflowInstance(instanceID WorkflowInstanceID) (WorkflowInstance, error) {
+	store.mutex.Lock()
+	instance, buffered := store.pending[instanceID]
+	store.mutex.Unlock()
+
+	if buffered {
+		return instance, nil
+	}
+	return store.StatePersistenceStore.GetWorkflowInstance(instanceID)
+}
+
+// DeleteWorkflowInstance discards buffered updates and deletes the instance
+func (store *BufferedStatePersistenceStore) DeleteWorkflowInstance(instanceID WorkflowInstanceID) error {
+	store.mutex.Lock()
+	delete(store.pending, instanceID)
+	store.mutex.Unlock()
+
+	return store.StatePersistenceStore.DeleteWorkflowInstance(instanceID)
+}
+
+// ListWorkflowInstances lists a definition's instances with buffered updates applied
+func (store *BufferedStatePersistenceStore) ListWorkflowInstances(definitionID layer1.WorkflowDefinitionID) ([]WorkflowInstance, error) {
+	instances, err := store.StatePersistenceStore.ListWorkflowInstances(definitionID)
+	if err != nil {
+		return nil, err
+	}
+	return store.overlay(instances), nil
+}
+
+// ListAllWorkflowInstances lists all instances with buffered updates applied
+func (store *BufferedStatePersistenceStore) ListAllWorkflowInstances() ([]WorkflowInstance, error) {
+	instances, err := store.StatePersistenceStore.ListAllWorkflowInstances()
+	if err != nil {
+		return nil, err
+	}
+	return store.overlay(instances), nil
+}
+
+// overlay replaces listed instances with their buffered versions
+func (store *BufferedStatePersistenceStore) overlay(instances []WorkflowInstance) []WorkflowInstance {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for index, instance := range instances {
+		if buffered, exists := store.pending[instance.ID]; exists {
+			instances[index] = buffered
+		}
+	}
+	return instances
+}
+
+// GetStats flushes buffered updates so the underlying store's counts are current
+func (store *BufferedStatePersistenceStore) GetStats() (map[string]interface{}, error) {
+	if err := store.Flush(); err != nil {
+		return nil, err
+	}
+	return store.StatePersistenceStore.GetStats()
+}
+
+// Cleanup discards buffered updates and cleans up the underlying store
+func (store *BufferedStatePersistenceStore) Cleanup() error {
+	store.mutex.Lock()
+	store.pending = make(map[WorkflowInstanceID]WorkflowInstance)
+	store.buffered = 0
+	store.mutex.Unlock()
+
+	return store.StatePersistenceStore.Cleanup()
+}
@@ -0,0 +1,117 @@
+package layer2
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time to the engine so time-based behavior can be tested
+type Clock interface {
+	Now() time.Time
+}
+
+// TimerClock is a Clock that can also signal when a duration has passed on it.
+// The engine waits on the system timer for clocks that do not implement it.
+type TimerClock interface {
+	Clock
+	After(duration time.Duration) <-chan time.Time
+}
+
+// SystemClock provides a Clock backed by the system time
+type SystemClock struct{}
+
+// NewSystemClock creates a new system clock
+func NewSystemClock() *SystemClock {
+	return &SystemClock{}
+}
+
+// Now returns the current system time
+func (clock *SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// After returns a channel that receives the time once duration has passed
+func (clock *SystemClock) After(duration time.Duration) <-chan time.Time {
+	return time.After(duration)
+}
+
+// manualTimer is a pending After on a manual clock
+type manualTimer struct {
+	deadline time.Time
+	fired    chan time.Time
+}
+
+// ManualClock provides a Clock that only moves when told to
+type ManualClock struct {
+	now    time.Time
+	timers []manualTimer
+	mutex  sync.RWMutex
+}
+
+// NewManualClock creates a new manual clock set to the given time
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{
+		now:   now,
+		mutex: sync.RWMutex{},
+	}
+}
+
+// Now returns the clock's current time
+func (clock *ManualClock) Now() time.Time {
+	clock.mutex.RLock()
+	defer clock.mutex.RUnlock()
+
+	return clock.now
+}
+
+// Advance moves the clock forward by the given duration
+func (clock *ManualClock) Advance(duration time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	clock.now = clock.now.Add(duration)
+	clock.fireTimersUnsafe()
+}
+
+// Set moves the clock to the given time
+func (clock *ManualClock) Set(now time.Time) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	clock.now = now
+	clock.fireTimersUnsafe()
+}
+
+// After returns a channel that receives the time once the clock has been moved
+// forward by duration
+func (clock *ManualClock) After(duration time.Duration) <-chan time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	timer := manualTimer{deadline: clock.now.Add(duration), fired: make(chan time.Time, 1)}
+	clock.timers = append(clock.timers, timer)
+	clock.fireTimersUnsafe()
+	return timer.fired
+}
+
+// PendingTimers returns the number of After channels that have not fired yet
+func (clock *ManualClock) PendingTimers() int {
+	clock.mutex.RLock()
+	defer clock.mutex.RUnlock()
+
+	return len(clock.timers)
+}
+
+// fireTimersUnsafe fires the timers whose deadline has been reached.
+// This method assumes the caller already holds the mutex lock.
+func (clock *ManualClock) fireTimersUnsafe() {
+	pending := clock.timers[:0]
+	for _, timer := range clock.timers {
+		if clock.now.Before(timer.deadline) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.fired <- clock.now
+	}
+	clock.timers = pending
+}
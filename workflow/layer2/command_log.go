@@ -0,0 +1,276 @@
+package layer2
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// CommandType identifies a state-changing engine operation
+type CommandType string
+
+const (
+	CommandStart           CommandType = "start"
+	CommandStop            CommandType = "stop"
+	CommandPause           CommandType = "pause"
+	CommandPauseUntil      CommandType = "pause_until"
+	CommandResume          CommandType = "resume"
+	CommandCancel          CommandType = "cancel"
+	CommandStep            CommandType = "step"
+	CommandExecute         CommandType = "execute"
+	CommandComplete        CommandType = "complete"
+	CommandCompleteHuman   CommandType = "complete_human_task"
+	CommandProcessTimeouts CommandType = "process_timeouts"
+	CommandEnableDebug     CommandType = "enable_debug"
+	CommandDebugStep       CommandType = "debug_step"
+	CommandContinue        CommandType = "continue"
+)
+
+// Command is a recorded engine operation with the parameters needed to replay it
+type Command struct {
+	Type              CommandType                      `json:"type"`
+	InstanceID        WorkflowInstanceID               `json:"instance_id,omitempty"`
+	DefinitionID      layer1.WorkflowDefinitionID      `json:"definition_id,omitempty"`
+	DefinitionVersion layer1.WorkflowDefinitionVersion `json:"definition_version,omitempty"`
+	Context           *layer0.Context                  `json:"context,omitempty"`
+	Options           *StartOptions                    `json:"options,omitempty"`
+	WorkID            layer0.WorkID                    `json:"work_id,omitempty"`
+	Result            *AsyncWorkResult                 `json:"result,omitempty"`
+	Payload           map[string]interface{}           `json:"payload,omitempty"`
+	Now               *time.Time                       `json:"now,omitempty"` // The time processed, or the deadline of a pause
+	PauseExpiry       PauseExpiryAction                `json:"pause_expiry,omitempty"`
+	IssuedAt          time.Time                        `json:"issued_at"`
+	Error             string                           `json:"error,omitempty"` // Set when the operation failed
+}
+
+// CommandSink defines the append-only storage behind a CommandLog
+type CommandSink interface {
+	Append(command Command) error
+	Commands() ([]Command, error)
+}
+
+// InMemoryCommandSink provides a CommandSink held in memory
+type InMemoryCommandSink struct {
+	commands []Command
+	mutex    sync.RWMutex
+}
+
+// NewInMemoryCommandSink creates a new in-memory command sink
+func NewInMemoryCommandSink() *InMemoryCommandSink {
+	return &InMemoryCommandSink{
+		commands: []Command{},
+		mutex:    sync.RWMutex{},
+	}
+}
+
+// Append adds a command to the end of the sink
+func (sink *InMemoryCommandSink) Append(command Command) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	sink.commands = append(sink.commands, command)
+	return nil
+}
+
+// Commands returns a copy of the commands in the order they were appended
+func (sink *InMemoryCommandSink) Commands() ([]Command, error) {
+	sink.mutex.RLock()
+	defer sink.mutex.RUnlock()
+
+	return append([]Command(nil), sink.commands...), nil
+}
+
+// CommandLog records engine commands to a sink in the order they finish
+type CommandLog struct {
+	sink  CommandSink
+	mutex sync.Mutex
+}
+
+// NewCommandLog creates a new command log writing to the given sink
+func NewCommandLog(sink CommandSink) *CommandLog {
+	return &CommandLog{
+		sink:  sink,
+		mutex: sync.Mutex{},
+	}
+}
+
+// Append records a command
+func (log *CommandLog) Append(command Command) error {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	return log.sink.Append(command)
+}
+
+// Commands returns the recorded commands in order
+func (log *CommandLog) Commands() ([]Command, error) {
+	return log.sink.Commands()
+}
+
+// DefinitionResolver returns the workflow definition a recorded start command refers to
+type DefinitionResolver func(id layer1.WorkflowDefinitionID, version layer1.WorkflowDefinitionVersion) (layer1.WorkflowDefinition, error)
+
+// SetCommandLog records every state-changing operation of the engine to the log.
+// A nil log stops recording.
+func (engine *WorkflowRuntimeEngine) SetCommandLog(log *CommandLog) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.commandLog = log
+}
+
+// recordCommand appends a finished command to the command log, if one is set
+func (engine *WorkflowRuntimeEngine) recordCommand(command Command, err error) {
+	engine.mutex.RLock()
+	log := engine.commandLog
+	engine.mutex.RUnlock()
+
+	if log == nil {
+		return
+	}
+
+	if err != nil {
+		command.Error = err.Error()
+	}
+
+	if appendErr := log.Append(command); appendErr != nil {
+		engine.errorHandler.HandleError(command.InstanceID, fmt.Errorf("failed to record %s command: %w", command.Type, appendErr))
+	}
+}
+
+// recordStartCommand records a start with the instance ID and correlation ID it resolved to
+func (engine *WorkflowRuntimeEngine) recordStartCommand(instanceID WorkflowInstanceID, definition layer1.WorkflowDefinition, initialContext *layer0.Context, options StartOptions, issuedAt time.Time, err error) {
+	options.InstanceID = instanceID
+	options.Labels = options.copyLabels()
+	options.Environment = options.mergeEnvironment(nil)
+
+	engine.mutex.RLock()
+	if instance, exists := engine.activeInstances[instanceID]; exists {
+		options.CorrelationID = instance.CorrelationID
+	}
+	engine.mutex.RUnlock()
+
+	var context *layer0.Context
+	if initialContext != nil {
+		context = initialContext.Clone()
+	}
+
+	engine.recordCommand(Command{
+		Type:              CommandStart,
+		InstanceID:        instanceID,
+		DefinitionID:      definition.GetID(),
+		DefinitionVersion: definition.GetVersion(),
+		Context:           context,
+		Options:           &options,
+		IssuedAt:          issuedAt,
+	}, err)
+}
+
+// Rebuild reconstructs engine state by replaying a command log against this engine,
+// which should be freshly created and configured with the same executors. The engine
+// clock follows each command's issue time during replay. Replayed commands are not
+// recorded again. A command whose outcome differs from the recorded one stops the rebuild.
+func (engine *WorkflowRuntimeEngine) Rebuild(log *CommandLog, resolve DefinitionResolver) error {
+	commands, err := log.Commands()
+	if err != nil {
+		return fmt.Errorf("failed to read command log: %w", err)
+	}
+
+	previous := engine.clock
+	clock := NewManualClock(time.Time{})
+	engine.SetClock(clock)
+	engine.mutex.Lock()
+	engine.replayClock = clock
+	engine.mutex.Unlock()
+	defer func() {
+		engine.SetClock(previous)
+		engine.mutex.Lock()
+		engine.replayClock = nil
+		engine.mutex.Unlock()
+	}()
+
+	for index, command := range commands {
+		clock.Set(command.IssuedAt)
+
+		err := engine.replayCommand(command, resolve)
+		switch {
+		case err != nil && command.Error == "":
+			return fmt.Errorf("replay of command %d (%s %s) failed: %w", index, command.Type, command.InstanceID, err)
+		case err == nil && command.Error != "":
+			return fmt.Errorf("replay of command %d (%s %s) succeeded but originally failed: %s", index, command.Type, command.InstanceID, command.Error)
+		}
+	}
+
+	return nil
+}
+
+// replayCommand applies a recorded command without recording it
+func (engine *WorkflowRuntimeEngine) replayCommand(command Command, resolve DefinitionResolver) error {
+	switch command.Type {
+	case CommandStart:
+		definition, err := resolve(command.DefinitionID, command.DefinitionVersion)
+		if err != nil {
+			return fmt.Errorf("failed to resolve definition %s version %s: %w", command.DefinitionID, command.DefinitionVersion, err)
+		}
+
+		options := StartOptions{InstanceID: command.InstanceID}
+		if command.Options != nil {
+			options = *command.Options
+		}
+
+		_, err = engine.startWorkflow(definition, command.Context, options)
+		return err
+	case CommandStop:
+		return engine.stopWorkflow(command.InstanceID)
+	case CommandPause:
+		return engine.pauseWorkflow(command.InstanceID)
+	case CommandPauseUntil:
+		if command.Now == nil {
+			return fmt.Errorf("pause until command for %s has no deadline", command.InstanceID)
+		}
+		return engine.pauseWorkflowUntil(command.InstanceID, *command.Now, command.PauseExpiry)
+	case CommandResume:
+		return engine.resumeWorkflow(command.InstanceID)
+	case CommandCancel:
+		engine.mutex.Lock()
+		defer engine.mutex.Unlock()
+		return engine.cancelWorkflowUnsafe(command.InstanceID)
+	case CommandStep:
+		_, err := engine.executeStep(command.InstanceID, false)
+		return err
+	case CommandExecute:
+		return engine.executeWorkflow(command.InstanceID)
+	case CommandComplete:
+		var result AsyncWorkResult
+		if command.Result != nil {
+			result = *command.Result
+		}
+		return engine.completeAsyncWork(command.InstanceID, command.WorkID, result, nil)
+	case CommandCompleteHuman:
+		// Tokens are random, so replay completes the task the token was redeemed for
+		var outcome string
+		if command.Result != nil {
+			outcome, _ = command.Result.Output.(string)
+		}
+		return engine.completeHumanTask(command.InstanceID, command.WorkID, outcome, command.Payload)
+	case CommandProcessTimeouts:
+		now := command.IssuedAt
+		if command.Now != nil {
+			now = *command.Now
+		}
+		_, err := engine.processStateTimeouts(now)
+		return err
+	case CommandEnableDebug:
+		return engine.enableDebug(command.InstanceID)
+	case CommandDebugStep:
+		_, err := engine.debugStep(command.InstanceID)
+		return err
+	case CommandContinue:
+		return engine.continueWorkflow(command.InstanceID)
+	default:
+		return fmt.Errorf("unknown command type %s", command.Type)
+	}
+}
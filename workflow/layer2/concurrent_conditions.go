@@ -0,0 +1,75 @@
+package layer2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// SetConcurrentConditionEvaluation makes first-match steps evaluate the conditions of
+// every outgoing transition at once instead of one transition at a time. Once the
+// first transition in order is known to be satisfiable, the evaluations still running
+// for the others are cancelled; context aware condition evaluators stop promptly and
+// release their active evaluations. Disabled by default and in deterministic mode.
+func (engine *WorkflowRuntimeEngine) SetConcurrentConditionEvaluation(enabled bool) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.concurrentConditions = enabled
+}
+
+// concurrentConditionEvaluation checks if first-match steps evaluate conditions concurrently
+func (engine *WorkflowRuntimeEngine) concurrentConditionEvaluation() bool {
+	engine.mutex.RLock()
+	defer engine.mutex.RUnlock()
+
+	return engine.concurrentConditions && !engine.deterministic
+}
+
+// firstSatisfiableTransition evaluates the transitions concurrently and returns the
+// index of the first satisfiable one in order, or -1 when none is. The remaining
+// evaluations are cancelled as soon as the result is known. Evaluation errors are
+// reported and the transition skipped, except a condition timeout or the instance
+// no longer running, which are returned.
+func (engine *WorkflowRuntimeEngine) firstSatisfiableTransition(instanceID WorkflowInstanceID, transitions []layer0.Transition, workContext *layer0.Context) (int, error) {
+	ctx, cancel := context.WithCancel(engine.instanceContext(instanceID))
+	defer cancel()
+
+	type evaluation struct {
+		index     int
+		satisfied bool
+		err       error
+	}
+	done := make(chan evaluation, len(transitions))
+	for index, transition := range transitions {
+		go func(index int, transition layer0.Transition) {
+			satisfied, err := engine.canTransitionWithContext(ctx, instanceID, transition, workContext)
+			done <- evaluation{index: index, satisfied: satisfied, err: err}
+		}(index, transition)
+	}
+
+	// Results arrive in any order but are decided in transition order
+	results := make([]*evaluation, len(transitions))
+	next := 0
+	for next < len(transitions) {
+		result := <-done
+		results[result.index] = &result
+
+		for next < len(transitions) && results[next] != nil {
+			decided := results[next]
+			switch {
+			case errors.Is(decided.err, ErrConditionTimeout), errors.Is(decided.err, ErrInstanceNotRunning):
+				return -1, decided.err
+			case decided.err != nil:
+				engine.errorHandler.HandleError(instanceID, fmt.Errorf("transition evaluation error: %w", decided.err))
+			case decided.satisfied:
+				return next, nil
+			}
+			next++
+		}
+	}
+
+	return -1, nil
+}
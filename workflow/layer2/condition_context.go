@@ -0,0 +1,19 @@
+package layer2
+
+import (
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// conditionContext returns the context transition conditions are evaluated with: the
+// instance context plus when the instance entered its current state. The entry time
+// is never written to the instance context itself.
+func (engine *WorkflowRuntimeEngine) conditionContext(instance *WorkflowInstance) *layer0.Context {
+	engine.mutex.RLock()
+	defer engine.mutex.RUnlock()
+
+	if instance.StateEnteredAt == nil {
+		return instance.Context
+	}
+	return instance.Context.Set(layer1.StateEnteredAtKey, *instance.StateEnteredAt)
+}
@@ -0,0 +1,134 @@
+package layer2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// ErrConditionTimeout indicates transition conditions did not finish evaluating in time
+var ErrConditionTimeout = errors.New("transition condition evaluation timed out")
+
+// ContextAwareTransitionEvaluator is implemented by evaluators that can stop
+// evaluating when the engine's condition timeout expires
+type ContextAwareTransitionEvaluator interface {
+	TransitionEvaluator
+	CanTransitionWithContext(ctx context.Context, transition layer0.Transition, context *layer0.Context) (bool, error)
+}
+
+// ConditionTimeoutAction defines what the engine does when condition evaluation times out
+type ConditionTimeoutAction string
+
+const (
+	// ConditionTimeoutSkip treats the timed out transition as not satisfied
+	ConditionTimeoutSkip ConditionTimeoutAction = "skip"
+	// ConditionTimeoutFail fails the workflow instance
+	ConditionTimeoutFail ConditionTimeoutAction = "fail"
+)
+
+// SetConditionTimeout bounds how long the engine waits for a transition's conditions.
+// A zero timeout waits indefinitely.
+func (engine *WorkflowRuntimeEngine) SetConditionTimeout(timeout time.Duration, action ConditionTimeoutAction) error {
+	if timeout < 0 {
+		return fmt.Errorf("condition timeout cannot be negative")
+	}
+
+	switch action {
+	case ConditionTimeoutSkip, ConditionTimeoutFail:
+	default:
+		return fmt.Errorf("unsupported condition timeout action: %s", action)
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.conditionTimeout = timeout
+	engine.conditionTimeoutAction = action
+	return nil
+}
+
+// canTransition evaluates a transition's conditions under the configured timeout.
+// Evaluators that are not context aware keep running in the background after a
+// timeout, but the step no longer waits for them.
+func (engine *WorkflowRuntimeEngine) canTransition(instanceID WorkflowInstanceID, transition layer0.Transition, workContext *layer0.Context) (bool, error) {
+	return engine.canTransitionWithContext(engine.instanceContext(instanceID), instanceID, transition, workContext)
+}
+
+// canTransitionWithContext evaluates a transition's conditions under the configured
+// timeout, stopping context aware evaluators when ctx is cancelled
+func (engine *WorkflowRuntimeEngine) canTransitionWithContext(ctx context.Context, instanceID WorkflowInstanceID, transition layer0.Transition, workContext *layer0.Context) (bool, error) {
+	engine.mutex.RLock()
+	timeout := engine.conditionTimeout
+	action := engine.conditionTimeoutAction
+	engine.mutex.RUnlock()
+
+	if timeout <= 0 {
+		satisfied, err := engine.evaluateTransition(ctx, transition, workContext)
+		if err != nil && ctx.Err() != nil {
+			return false, engine.evaluationCancelled(instanceID, transition, ctx.Err())
+		}
+		return satisfied, err
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type evaluation struct {
+		satisfied bool
+		err       error
+	}
+	done := make(chan evaluation, 1)
+	go func() {
+		var result evaluation
+		result.satisfied, result.err = engine.evaluateTransition(ctx, transition, workContext)
+		done <- result
+	}()
+
+	select {
+	case result := <-done:
+		// An evaluator that stopped because ctx ended is handled as if ctx ended first
+		if result.err == nil || ctx.Err() == nil {
+			return result.satisfied, result.err
+		}
+	case <-ctx.Done():
+	}
+
+	// Only the condition timeout expiring is a timeout, not the instance being
+	// cancelled or the caller abandoning the transition
+	if ctx.Err() != context.DeadlineExceeded || parent.Err() != nil {
+		return false, engine.evaluationCancelled(instanceID, transition, ctx.Err())
+	}
+
+	err := fmt.Errorf("transition %s: %w after %v", transition.GetID(), ErrConditionTimeout, timeout)
+	if action == ConditionTimeoutFail {
+		return false, err
+	}
+
+	engine.errorHandler.HandleError(instanceID, err)
+	return false, nil
+}
+
+// evaluationCancelled describes an evaluation stopped by its context ending before
+// the condition timeout, wrapping ErrInstanceNotRunning when the instance stopped
+func (engine *WorkflowRuntimeEngine) evaluationCancelled(instanceID WorkflowInstanceID, transition layer0.Transition, cause error) error {
+	engine.mutex.RLock()
+	err := engine.ensureRunningUnsafe(engine.activeInstances[instanceID])
+	engine.mutex.RUnlock()
+
+	if err == nil {
+		err = cause
+	}
+	return fmt.Errorf("evaluation of transition %s was cancelled: %w", transition.GetID(), err)
+}
+
+// evaluateTransition evaluates a transition's conditions, passing ctx to context aware evaluators
+func (engine *WorkflowRuntimeEngine) evaluateTransition(ctx context.Context, transition layer0.Transition, workContext *layer0.Context) (bool, error) {
+	if contextAware, ok := engine.transitionEvaluator.(ContextAwareTransitionEvaluator); ok {
+		return contextAware.CanTransitionWithContext(ctx, transition, workContext)
+	}
+	return engine.transitionEvaluator.CanTransition(transition, workContext)
+}
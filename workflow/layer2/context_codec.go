@@ -0,0 +1,141 @@
+package layer2
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// ValueCodec controls how context values of a custom type are persisted
+type ValueCodec interface {
+	MarshalContextValue(value interface{}) ([]byte, error)
+	UnmarshalContextValue(data []byte) (interface{}, error)
+}
+
+// encodedContextValue wraps a codec-encoded context value so it can be decoded by the same codec
+type encodedContextValue struct {
+	Codec string          `json:"$codec"`
+	Value json.RawMessage `json:"$value"`
+}
+
+// ContextValueCodecs maps custom context value types to the codecs that persist them.
+// Values of types without a codec are persisted as plain JSON.
+type ContextValueCodecs struct {
+	names  map[reflect.Type]string
+	codecs map[string]ValueCodec
+	mutex  sync.RWMutex
+}
+
+// NewContextValueCodecs creates a new, empty codec registry
+func NewContextValueCodecs() *ContextValueCodecs {
+	return &ContextValueCodecs{
+		names:  make(map[reflect.Type]string),
+		codecs: make(map[string]ValueCodec),
+		mutex:  sync.RWMutex{},
+	}
+}
+
+// Register registers a codec under name for values of the same type as sample.
+// The name is persisted with each encoded value, so it must stay stable.
+func (registry *ContextValueCodecs) Register(name string, sample interface{}, codec ValueCodec) error {
+	if name == "" {
+		return fmt.Errorf("codec name cannot be empty")
+	}
+
+	if sample == nil || codec == nil {
+		return fmt.Errorf("codec %s requires a sample value and a codec", name)
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	valueType := reflect.TypeOf(sample)
+	if existing, exists := registry.names[valueType]; exists {
+		return fmt.Errorf("type %s already has codec %s", valueType, existing)
+	}
+
+	if _, exists := registry.codecs[name]; exists {
+		return fmt.Errorf("codec %s already registered", name)
+	}
+
+	registry.names[valueType] = name
+	registry.codecs[name] = codec
+	return nil
+}
+
+// EncodeContext returns a copy of the context with values of registered types
+// replaced by their codec encoding
+func (registry *ContextValueCodecs) EncodeContext(context *layer0.Context) (*layer0.Context, error) {
+	if registry == nil || context == nil {
+		return context, nil
+	}
+
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	encoded := context.Clone()
+	for key, value := range encoded.Data {
+		if value == nil {
+			continue
+		}
+
+		name, exists := registry.names[reflect.TypeOf(value)]
+		if !exists {
+			continue
+		}
+
+		data, err := registry.codecs[name].MarshalContextValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode context value %s with codec %s: %w", key, name, err)
+		}
+
+		encoded.Data[key] = encodedContextValue{Codec: name, Value: json.RawMessage(data)}
+	}
+
+	return encoded, nil
+}
+
+// DecodeContext returns a copy of a persisted context with codec-encoded values decoded
+func (registry *ContextValueCodecs) DecodeContext(context *layer0.Context) (*layer0.Context, error) {
+	if registry == nil || context == nil {
+		return context, nil
+	}
+
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	decoded := context.Clone()
+	for key, value := range decoded.Data {
+		record, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, ok := record["$codec"].(string)
+		if !ok {
+			continue
+		}
+
+		codec, exists := registry.codecs[name]
+		if !exists {
+			return nil, fmt.Errorf("no codec %s registered for context value %s", name, key)
+		}
+
+		data, err := json.Marshal(record["$value"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read context value %s: %w", key, err)
+		}
+
+		decodedValue, err := codec.UnmarshalContextValue(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode context value %s with codec %s: %w", key, name, err)
+		}
+
+		decoded.Data[key] = decodedValue
+	}
+
+	return decoded, nil
+}
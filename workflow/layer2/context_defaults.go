@@ -0,0 +1,55 @@
+package layer2
+
+import (
+	"sort"
+
+	"github.com/ubom/workflow/layer1"
+)
+
+// SetContextDefaults sets the values filled in for context keys that instances of
+// a definition lack when they are loaded or resumed, so instances started before a
+// key was introduced see its default. Starting an instance of a definition whose
+// configuration declares context defaults replaces them; nil defaults clear them.
+func (engine *WorkflowRuntimeEngine) SetContextDefaults(definitionID layer1.WorkflowDefinitionID, defaults map[string]interface{}) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.setContextDefaultsUnsafe(definitionID, defaults)
+}
+
+// setContextDefaultsUnsafe stores a copy of the context defaults of a definition.
+// This method assumes the caller already holds the mutex lock.
+func (engine *WorkflowRuntimeEngine) setContextDefaultsUnsafe(definitionID layer1.WorkflowDefinitionID, defaults map[string]interface{}) {
+	if defaults == nil {
+		delete(engine.contextDefaults, definitionID)
+		return
+	}
+
+	copied := make(map[string]interface{}, len(defaults))
+	for key, value := range defaults {
+		copied[key] = value
+	}
+	engine.contextDefaults[definitionID] = copied
+}
+
+// applyContextDefaultsUnsafe sets the defaults of the instance's definition for every
+// context key the instance lacks, leaving keys it already has unchanged.
+// This method assumes the caller already holds the mutex lock.
+func (engine *WorkflowRuntimeEngine) applyContextDefaultsUnsafe(instance *WorkflowInstance) {
+	defaults := engine.contextDefaults[instance.DefinitionID]
+	if len(defaults) == 0 || instance.Context == nil {
+		return
+	}
+
+	keys := make([]string, 0, len(defaults))
+	for key := range defaults {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !instance.Context.Has(key) {
+			instance.Context = instance.Context.Set(key, defaults[key])
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package layer2
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// defaultContextPatchLimit is the number of patch operations kept per transition record
+const defaultContextPatchLimit = 100
+
+// PatchOperation is one RFC 6902 JSON patch operation on the instance context data
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// SetContextPatchLimit sets how many context patch operations a transition record
+// keeps; operations past the limit are dropped and counted. Zero stops recording patches.
+func (engine *WorkflowRuntimeEngine) SetContextPatchLimit(limit int) error {
+	if limit < 0 {
+		return fmt.Errorf("context patch limit cannot be negative")
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.contextPatchLimit = limit
+	return nil
+}
+
+// recordContextPatch stores the change from before to after in the record, truncated to the engine limit
+func (engine *WorkflowRuntimeEngine) recordContextPatch(record *TransitionRecord, before, after *layer0.Context) {
+	engine.mutex.RLock()
+	limit := engine.contextPatchLimit
+	engine.mutex.RUnlock()
+
+	if limit == 0 || before == nil || after == nil {
+		return
+	}
+
+	patch, err := diffContexts(before, after)
+	if err != nil {
+		engine.errorHandler.HandleError(record.InstanceID, fmt.Errorf("failed to diff context: %w", err))
+		return
+	}
+
+	if len(patch) > limit {
+		record.ContextPatchOmitted = len(patch) - limit
+		patch = patch[:limit]
+	}
+	record.ContextPatch = patch
+}
+
+// diffContexts returns the patch that turns the data of before into the data of after.
+// Values are compared in their JSON form; nested objects are diffed key by key and
+// any other changed value is replaced whole.
+func diffContexts(before, after *layer0.Context) ([]PatchOperation, error) {
+	beforeData, err := jsonData(before)
+	if err != nil {
+		return nil, err
+	}
+	afterData, err := jsonData(after)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffObjects("", beforeData, afterData), nil
+}
+
+// jsonData converts a context's data to its JSON representation
+func jsonData(context *layer0.Context) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(context)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize context %s: %w", context.GetID(), err)
+	}
+
+	var decoded struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to serialize context %s: %w", context.GetID(), err)
+	}
+	return decoded.Data, nil
+}
+
+// diffObjects appends the operations turning before into after, in key order
+func diffObjects(prefix string, before, after map[string]interface{}) []PatchOperation {
+	keys := make([]string, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, exists := before[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var patch []PatchOperation
+	for _, key := range keys {
+		path := prefix + "/" + escapePointer(key)
+		oldValue, hadValue := before[key]
+		newValue, hasValue := after[key]
+
+		switch {
+		case !hasValue:
+			patch = append(patch, PatchOperation{Op: "remove", Path: path})
+		case !hadValue:
+			patch = append(patch, PatchOperation{Op: "add", Path: path, Value: newValue})
+		case reflect.DeepEqual(oldValue, newValue):
+		default:
+			oldObject, oldIsObject := oldValue.(map[string]interface{})
+			newObject, newIsObject := newValue.(map[string]interface{})
+			if oldIsObject && newIsObject {
+				patch = append(patch, diffObjects(path, oldObject, newObject)...)
+				continue
+			}
+			patch = append(patch, PatchOperation{Op: "replace", Path: path, Value: newValue})
+		}
+	}
+	return patch
+}
+
+// escapePointer escapes a key for use in a JSON pointer
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
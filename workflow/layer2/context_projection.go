@@ -0,0 +1,51 @@
+package layer2
+
+import (
+	"strings"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// SetContextProjection sets whether executors see only part of the instance context.
+// When enabled, each work sees the keys listed in its input_keys property plus the
+// given shared keys; work with the full_context property set to "true" sees everything.
+// Executors receive a copy, so the projection is a read-only view of the context.
+func (engine *WorkflowRuntimeEngine) SetContextProjection(enabled bool, sharedKeys ...string) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.projectContexts = enabled
+	engine.sharedContextKeys = append([]string(nil), sharedKeys...)
+}
+
+// projectContext returns the part of the context the work may see
+func (engine *WorkflowRuntimeEngine) projectContext(work layer0.Work, workContext *layer0.Context) *layer0.Context {
+	engine.mutex.RLock()
+	enabled := engine.projectContexts
+	sharedKeys := engine.sharedContextKeys
+	engine.mutex.RUnlock()
+
+	properties := work.GetMetadata().Properties
+	if !enabled || workContext == nil || properties[layer1.WorkPropertyFullContext] == "true" {
+		return workContext
+	}
+
+	visible := make(map[string]bool, len(sharedKeys))
+	for _, key := range sharedKeys {
+		visible[key] = true
+	}
+	for _, key := range strings.Split(properties[layer1.WorkPropertyInputKeys], ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			visible[key] = true
+		}
+	}
+
+	projected := workContext.Clone()
+	for _, key := range workContext.Keys() {
+		if !visible[key] {
+			projected = projected.Delete(key)
+		}
+	}
+	return projected
+}
@@ -0,0 +1,119 @@
+package layer2
+
+import (
+	"strings"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// pruneContext applies the instance's context pruning policy to the context staged
+// by a transition. The outputs the transition's actions wrote are recorded as the
+// most recent, then the work outputs the policy no longer needs are dropped. Keys
+// the policy retains and keys not written by work are never dropped. It returns the
+// work outputs left in the context, oldest first, and the pruned context.
+func (engine *WorkflowRuntimeEngine) pruneContext(instance *WorkflowInstance, transition layer0.Transition, staged *layer0.Context) ([]string, *layer0.Context) {
+	policy := instance.ContextPruning
+	if !policy.Enabled() || staged == nil {
+		return instance.OutputKeys, staged
+	}
+
+	written := engine.transitionOutputKeys(transition)
+	isWritten := make(map[string]bool, len(written))
+	for _, key := range written {
+		isWritten[key] = true
+	}
+
+	// Outputs are ordered by when they were last written
+	var outputKeys []string
+	for _, key := range instance.OutputKeys {
+		if !isWritten[key] && staged.Has(key) {
+			outputKeys = append(outputKeys, key)
+		}
+	}
+	for _, key := range written {
+		if staged.Has(key) && !policy.Retains(key) {
+			outputKeys = append(outputKeys, key)
+		}
+	}
+
+	kept := outputKeys
+	switch policy.Mode {
+	case layer1.ContextPruningKeepLast:
+		if len(outputKeys) > policy.KeepLast {
+			kept = outputKeys[len(outputKeys)-policy.KeepLast:]
+		}
+	case layer1.ContextPruningUnreferenced:
+		if referenced, all := engine.referencedContextKeys(transition.GetToStateID()); !all {
+			kept = nil
+			for _, key := range outputKeys {
+				if referenced[key] {
+					kept = append(kept, key)
+				}
+			}
+		}
+	}
+
+	isKept := make(map[string]bool, len(kept))
+	for _, key := range kept {
+		isKept[key] = true
+	}
+	for _, key := range outputKeys {
+		if !isKept[key] {
+			staged = staged.Delete(key)
+		}
+	}
+
+	return kept, staged
+}
+
+// transitionOutputKeys returns the context keys the work of a transition's actions
+// stores its outputs under
+func (engine *WorkflowRuntimeEngine) transitionOutputKeys(transition layer0.Transition) []string {
+	var keys []string
+	for _, actionID := range transition.GetActions() {
+		if _, _, shared := parseSharedAction(actionID); shared {
+			continue
+		}
+		keys = append(keys, actionOutputKey(engine.actionWorkTemplate(actionID)))
+	}
+	return keys
+}
+
+// referencedContextKeys returns the input keys of the work of every action of a
+// transition reachable from a state. It reports all when such work sees the full
+// context, since any key may then be read.
+func (engine *WorkflowRuntimeEngine) referencedContextKeys(stateID layer0.StateID) (map[string]bool, bool) {
+	referenced := make(map[string]bool)
+	visited := map[layer0.StateID]bool{stateID: true}
+	pending := []layer0.StateID{stateID}
+
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+
+		for _, transition := range engine.stateMachineCore.GetTransitionsFromState(current) {
+			for _, actionID := range transition.GetActions() {
+				if _, _, shared := parseSharedAction(actionID); shared {
+					continue
+				}
+
+				properties := engine.actionWorkTemplate(actionID).GetMetadata().Properties
+				if properties[layer1.WorkPropertyFullContext] == "true" {
+					return nil, true
+				}
+				for _, key := range strings.Split(properties[layer1.WorkPropertyInputKeys], ",") {
+					if key = strings.TrimSpace(key); key != "" {
+						referenced[key] = true
+					}
+				}
+			}
+
+			if next := transition.GetToStateID(); !visited[next] {
+				visited[next] = true
+				pending = append(pending, next)
+			}
+		}
+	}
+	return referenced, false
+}
@@ -0,0 +1,84 @@
+package layer2
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// ContextWatchHandler is called when a watched context key of an instance changes.
+// The old or new value is nil when the key was absent before or after the change.
+type ContextWatchHandler func(instanceID WorkflowInstanceID, key string, oldValue, newValue interface{})
+
+// contextWatch is a handler registered for one context key
+type contextWatch struct {
+	key     string
+	handler ContextWatchHandler
+}
+
+// WatchContextKey registers a handler invoked whenever the engine changes key in the
+// instance context. Handlers run asynchronously so they never block execution.
+// Watches end when the instance finishes or the returned unwatch function is called.
+func (engine *WorkflowRuntimeEngine) WatchContextKey(instanceID WorkflowInstanceID, key string, handler ContextWatchHandler) (unwatch func(), err error) {
+	if key == "" {
+		return nil, fmt.Errorf("watched key cannot be empty")
+	}
+
+	if handler == nil {
+		return nil, fmt.Errorf("watch handler cannot be nil")
+	}
+
+	instance, err := engine.GetWorkflowInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if instance.IsTerminal() {
+		return nil, fmt.Errorf("cannot watch workflow instance %s in status %s", instanceID, instance.Status)
+	}
+
+	watch := &contextWatch{key: key, handler: handler}
+
+	engine.mutex.Lock()
+	engine.contextWatches[instanceID] = append(engine.contextWatches[instanceID], watch)
+	engine.mutex.Unlock()
+
+	return func() {
+		engine.mutex.Lock()
+		defer engine.mutex.Unlock()
+
+		watches := engine.contextWatches[instanceID]
+		for index, registered := range watches {
+			if registered == watch {
+				engine.contextWatches[instanceID] = append(watches[:index:index], watches[index+1:]...)
+				break
+			}
+		}
+	}, nil
+}
+
+// notifyContextWatches dispatches the watched keys that differ between two versions of an instance context
+func (engine *WorkflowRuntimeEngine) notifyContextWatches(instanceID WorkflowInstanceID, before, after *layer0.Context) {
+	engine.mutex.RLock()
+	watches := engine.contextWatches[instanceID]
+	engine.mutex.RUnlock()
+
+	for _, watch := range watches {
+		oldValue, hadValue := contextValue(before, watch.key)
+		newValue, hasValue := contextValue(after, watch.key)
+		if hadValue == hasValue && reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+
+		go watch.handler(instanceID, watch.key, oldValue, newValue)
+	}
+}
+
+// contextValue reads a key from a possibly nil context
+func contextValue(context *layer0.Context, key string) (interface{}, bool) {
+	if context == nil {
+		return nil, false
+	}
+	return context.Get(key)
+}
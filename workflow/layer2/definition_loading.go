@@ -0,0 +1,55 @@
+package layer2
+
+import (
+	"fmt"
+
+	"github.com/ubom/workflow/layer1"
+)
+
+// SetDefinitionResolver sets how the engine finds the definition of an instance it
+// did not start, such as one left waiting on work or in a timed state by an engine
+// that restarted on the same store. Without a resolver such instances run against
+// the definition the engine last started.
+func (engine *WorkflowRuntimeEngine) SetDefinitionResolver(resolve DefinitionResolver) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.definitionResolver = resolve
+}
+
+// loadDefinition makes definition the one the engine executes and precomputes its
+// transition lookups
+func (engine *WorkflowRuntimeEngine) loadDefinition(definition layer1.WorkflowDefinition) {
+	stateMachine := definition.GetStateMachine()
+	stateMachine.Compile()
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.stateMachineCore = stateMachine
+	engine.initialStateID = definition.GetInitialStateID()
+	engine.invariants = definition.GetInvariants()
+	engine.loadedDefinitionID = definition.GetID()
+	engine.loadedDefinitionVersion = definition.GetVersion()
+}
+
+// loadInstanceDefinition loads the definition of an instance through the definition
+// resolver when the engine is executing another one
+func (engine *WorkflowRuntimeEngine) loadInstanceDefinition(instance WorkflowInstance) error {
+	engine.mutex.RLock()
+	resolve := engine.definitionResolver
+	loaded := engine.loadedDefinitionID == instance.DefinitionID && engine.loadedDefinitionVersion == instance.DefinitionVersion
+	engine.mutex.RUnlock()
+
+	if loaded || resolve == nil {
+		return nil
+	}
+
+	definition, err := resolve(instance.DefinitionID, instance.DefinitionVersion)
+	if err != nil {
+		return fmt.Errorf("failed to resolve definition %s version %s of workflow instance %s: %w", instance.DefinitionID, instance.DefinitionVersion, instance.ID, err)
+	}
+
+	engine.loadDefinition(definition)
+	return nil
+}
@@ -0,0 +1,79 @@
+package layer2
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ubom/workflow/layer1"
+)
+
+// DefinitionUsage reports the unfinished instances of one definition version
+type DefinitionUsage struct {
+	DefinitionID      layer1.WorkflowDefinitionID      `json:"definition_id"`
+	DefinitionVersion layer1.WorkflowDefinitionVersion `json:"definition_version"`
+	Instances         int                              `json:"instances"`
+	ByStatus          map[WorkflowInstanceStatus]int   `json:"by_status"`
+}
+
+// ListInUseDefinitions reports, per definition ID and version, how many instances
+// have not yet reached a terminal status, broken down by status. A definition
+// version absent from the report has no instances that could still run, so it is
+// safe to deprecate. Instances are read from the persistence store, with the
+// engine's in-memory view taking precedence; if the store cannot be listed the
+// error is reported and only in-memory instances are counted.
+func (engine *WorkflowRuntimeEngine) ListInUseDefinitions() []DefinitionUsage {
+	instances := make(map[WorkflowInstanceID]WorkflowInstance)
+
+	persisted, err := engine.persistenceStore.ListAllWorkflowInstances()
+	if err != nil {
+		engine.errorHandler.HandleError("", fmt.Errorf("failed to list workflow instances for definition usage: %w", err))
+	}
+	for _, instance := range persisted {
+		instances[instance.ID] = instance
+	}
+
+	engine.mutex.RLock()
+	for instanceID, instance := range engine.activeInstances {
+		instances[instanceID] = *instance
+	}
+	engine.mutex.RUnlock()
+
+	type definitionKey struct {
+		id      layer1.WorkflowDefinitionID
+		version layer1.WorkflowDefinitionVersion
+	}
+
+	usages := make(map[definitionKey]*DefinitionUsage)
+	for _, instance := range instances {
+		if instance.IsTerminal() {
+			continue
+		}
+
+		key := definitionKey{id: instance.DefinitionID, version: instance.DefinitionVersion}
+		usage, exists := usages[key]
+		if !exists {
+			usage = &DefinitionUsage{
+				DefinitionID:      instance.DefinitionID,
+				DefinitionVersion: instance.DefinitionVersion,
+				ByStatus:          make(map[WorkflowInstanceStatus]int),
+			}
+			usages[key] = usage
+		}
+
+		usage.Instances++
+		usage.ByStatus[instance.Status]++
+	}
+
+	report := make([]DefinitionUsage, 0, len(usages))
+	for _, usage := range usages {
+		report = append(report, *usage)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].DefinitionID != report[j].DefinitionID {
+			return report[i].DefinitionID < report[j].DefinitionID
+		}
+		return report[i].DefinitionVersion < report[j].DefinitionVersion
+	})
+
+	return report
+}
@@ -0,0 +1,26 @@
+package layer2
+
+import "time"
+
+// DeterministicEpoch is the time the clock of an engine in deterministic mode starts at
+var DeterministicEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// EnableDeterministicMode makes the engine reproducible for end-to-end tests. It sets
+// a manual clock starting at DeterministicEpoch, which also times work results,
+// sequential instance and correlation IDs, and the seed that breaks transition ties
+// and drives random selection. The actions of parallel transitions run one at a time
+// in order on the stepping goroutine, and concurrent condition evaluation is turned
+// off. The clock is returned so tests can advance time.
+func (engine *WorkflowRuntimeEngine) EnableDeterministicMode(seed int64) *ManualClock {
+	clock := NewManualClock(DeterministicEpoch)
+	engine.SetClock(clock)
+	engine.workExecutionCore.SetTimeSource(clock.Now)
+	engine.SetIDGenerator(NewSequentialIDGenerator())
+	engine.SetTransitionTiebreakSeed(seed)
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.deterministic = true
+	return clock
+}
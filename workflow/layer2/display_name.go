@@ -0,0 +1,63 @@
+package layer2
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// DisplayNameMetadataKey is the instance metadata key holding its rendered display name
+const DisplayNameMetadataKey = "display_name"
+
+// displayNamePlaceholder matches ${key} references in display name templates
+var displayNamePlaceholder = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// DisplayName returns the instance's display name, or its ID when it has none
+func (instance WorkflowInstance) DisplayName() string {
+	if name, ok := instance.Metadata[DisplayNameMetadataKey].(string); ok && name != "" {
+		return name
+	}
+	return string(instance.ID)
+}
+
+// setDisplayName renders the definition's display name template from the instance's
+// initial context into its metadata
+func setDisplayName(instance *WorkflowInstance, configuration layer1.WorkflowConfiguration) error {
+	if configuration.DisplayNameTemplate == "" {
+		return nil
+	}
+
+	name, err := renderDisplayName(configuration.DisplayNameTemplate, instance.Context, configuration.StrictDisplayName)
+	if err != nil {
+		return fmt.Errorf("failed to render display name of workflow instance %s: %w", instance.ID, err)
+	}
+	instance.Metadata[DisplayNameMetadataKey] = name
+	return nil
+}
+
+// renderDisplayName replaces each ${key} reference with the context value under key.
+// Missing and nil values render blank, or are reported when strict.
+func renderDisplayName(template string, context *layer0.Context, strict bool) (string, error) {
+	var missing []string
+	name := displayNamePlaceholder.ReplaceAllStringFunc(template, func(match string) string {
+		key := displayNamePlaceholder.FindStringSubmatch(match)[1]
+
+		var value interface{}
+		if context != nil {
+			value, _ = context.Get(key)
+		}
+		if value == nil {
+			missing = append(missing, key)
+			return ""
+		}
+		return fmt.Sprint(value)
+	})
+
+	if strict && len(missing) > 0 {
+		return "", fmt.Errorf("context has no value for %s", strings.Join(missing, ", "))
+	}
+	return strings.TrimSpace(name), nil
+}
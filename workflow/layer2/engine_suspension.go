@@ -0,0 +1,283 @@
+package layer2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// ErrEngineSuspended indicates a step was refused because the engine is suspended
+var ErrEngineSuspended = errors.New("workflow engine is suspended")
+
+// EngineSnapshotVersion is the format version written by Suspend
+const EngineSnapshotVersion = 1
+
+// EngineSnapshot is the serialized in-memory state of a suspended engine.
+// Context watches hold callbacks and are not captured; they must be registered again.
+type EngineSnapshot struct {
+	Version            int                                             `json:"version"`
+	SuspendedAt        time.Time                                       `json:"suspended_at"`
+	StateMachine       *layer1.StateMachineCore                        `json:"state_machine,omitempty"`
+	InitialStateID     layer0.StateID                                  `json:"initial_state_id,omitempty"`
+	Invariants         []layer1.Invariant                              `json:"invariants,omitempty"`
+	Instances          []WorkflowInstance                              `json:"instances"`
+	SuspendedInstances []WorkflowInstanceID                            `json:"suspended_instances,omitempty"`
+	DebugInstances     []WorkflowInstanceID                            `json:"debug_instances,omitempty"`
+	History            map[WorkflowInstanceID][]TransitionRecord       `json:"history,omitempty"`
+	SharedContexts     map[layer1.WorkflowDefinitionID]*layer0.Context `json:"shared_contexts,omitempty"`
+	PendingWrites      []WorkflowInstance                              `json:"pending_writes,omitempty"`
+	HumanTasks         []HumanTask                                     `json:"human_tasks,omitempty"`
+}
+
+// Suspend quiesces the engine and serializes its in-memory state. Running instances
+// are paused, steps already in progress are allowed to finish, and no further steps
+// run until the state is restored with Resume. Instances paused by Suspend are
+// running again after Resume; instances that were already paused stay paused.
+// If an instance cannot be paused in the store, the suspend is undone and the
+// engine keeps running.
+func (engine *WorkflowRuntimeEngine) Suspend() ([]byte, error) {
+	engine.mutex.Lock()
+	if engine.suspended {
+		engine.mutex.Unlock()
+		return nil, ErrEngineSuspended
+	}
+	engine.suspended = true
+
+	now := engine.clock.Now()
+	var paused []WorkflowInstance
+	for _, instance := range engine.activeInstances {
+		if instance.Status != WorkflowInstanceStatusRunning {
+			continue
+		}
+		instance.Status = WorkflowInstanceStatusPaused
+		instance.UpdatedAt = now
+		paused = append(paused, *instance)
+	}
+	engine.mutex.Unlock()
+
+	sort.Slice(paused, func(i, j int) bool {
+		return paused[i].ID < paused[j].ID
+	})
+
+	suspendedIDs := make([]WorkflowInstanceID, 0, len(paused))
+	for _, instance := range paused {
+		if err := engine.updateInstance(instance); err != nil {
+			engine.abortSuspend(paused, suspendedIDs)
+			return nil, fmt.Errorf("failed to update workflow instance: %w", err)
+		}
+		if err := engine.lifecycleManager.OnWorkflowPaused(instance.ID); err != nil {
+			engine.errorHandler.HandleError(instance.ID, fmt.Errorf("lifecycle manager error: %w", err))
+		}
+		suspendedIDs = append(suspendedIDs, instance.ID)
+	}
+
+	// Let steps in progress commit or roll back before capturing state
+	engine.steps.Wait()
+
+	snapshot := engine.snapshot(now, suspendedIDs)
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize engine state: %w", err)
+	}
+	return data, nil
+}
+
+// abortSuspend undoes a Suspend that failed to pause every instance: the paused
+// instances run again in memory, those already written paused are written running
+// again, and the engine accepts steps. Failures to write an instance back are
+// reported to the error handler.
+func (engine *WorkflowRuntimeEngine) abortSuspend(paused []WorkflowInstance, written []WorkflowInstanceID) {
+	engine.mutex.Lock()
+	now := engine.clock.Now()
+	restored := make(map[WorkflowInstanceID]WorkflowInstance, len(paused))
+	for _, instance := range paused {
+		active, exists := engine.activeInstances[instance.ID]
+		if !exists || active.Status != WorkflowInstanceStatusPaused {
+			continue
+		}
+		active.Status = WorkflowInstanceStatusRunning
+		active.UpdatedAt = now
+		restored[instance.ID] = *active
+	}
+	engine.suspended = false
+	engine.mutex.Unlock()
+
+	for _, instanceID := range written {
+		instance, exists := restored[instanceID]
+		if !exists {
+			continue
+		}
+		if err := engine.updateInstance(instance); err != nil {
+			engine.errorHandler.HandleError(instanceID, fmt.Errorf("failed to restore workflow instance after aborted suspend: %w", err))
+			continue
+		}
+		if err := engine.lifecycleManager.OnWorkflowResumed(instanceID); err != nil {
+			engine.errorHandler.HandleError(instanceID, fmt.Errorf("lifecycle manager error: %w", err))
+		}
+	}
+}
+
+// snapshot captures the engine's in-memory state
+func (engine *WorkflowRuntimeEngine) snapshot(suspendedAt time.Time, suspendedIDs []WorkflowInstanceID) EngineSnapshot {
+	engine.mutex.RLock()
+	snapshot := EngineSnapshot{
+		Version:            EngineSnapshotVersion,
+		SuspendedAt:        suspendedAt,
+		StateMachine:       engine.stateMachineCore,
+		InitialStateID:     engine.initialStateID,
+		Invariants:         engine.invariants,
+		Instances:          make([]WorkflowInstance, 0, len(engine.activeInstances)),
+		SuspendedInstances: suspendedIDs,
+		History:            make(map[WorkflowInstanceID][]TransitionRecord, len(engine.history)),
+	}
+
+	for _, instance := range engine.activeInstances {
+		snapshot.Instances = append(snapshot.Instances, *instance)
+	}
+	for instanceID, enabled := range engine.debugInstances {
+		if enabled {
+			snapshot.DebugInstances = append(snapshot.DebugInstances, instanceID)
+		}
+	}
+	for instanceID, records := range engine.history {
+		snapshot.History[instanceID] = append([]TransitionRecord(nil), records...)
+	}
+	for _, task := range engine.humanTasks {
+		snapshot.HumanTasks = append(snapshot.HumanTasks, task)
+	}
+	engine.mutex.RUnlock()
+
+	sortInstancesByID(snapshot.Instances)
+	sortInstanceIDs(snapshot.DebugInstances)
+	sort.Slice(snapshot.HumanTasks, func(i, j int) bool {
+		return snapshot.HumanTasks[i].Token < snapshot.HumanTasks[j].Token
+	})
+	snapshot.SharedContexts = engine.sharedContexts.snapshotAll()
+
+	engine.writeAhead.mutex.Lock()
+	snapshot.PendingWrites = append([]WorkflowInstance(nil), engine.writeAhead.pending...)
+	engine.writeAhead.mutex.Unlock()
+
+	return snapshot
+}
+
+// Resume restores engine state serialized by Suspend, saving the instances to the
+// persistence store and resuming the instances Suspend paused. It fails if the
+// engine already has active instances, unless the engine itself is suspended.
+func (engine *WorkflowRuntimeEngine) Resume(data []byte) error {
+	var snapshot EngineSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to decode engine state: %w", err)
+	}
+	if snapshot.Version != EngineSnapshotVersion {
+		return fmt.Errorf("unsupported engine snapshot version %d", snapshot.Version)
+	}
+
+	resumed := make(map[WorkflowInstanceID]bool, len(snapshot.SuspendedInstances))
+	for _, instanceID := range snapshot.SuspendedInstances {
+		resumed[instanceID] = true
+	}
+
+	now := engine.clock.Now()
+	instances := make([]WorkflowInstance, len(snapshot.Instances))
+	for index, instance := range snapshot.Instances {
+		if resumed[instance.ID] {
+			instance.Status = WorkflowInstanceStatusRunning
+			instance.UpdatedAt = now
+		}
+		instances[index] = instance
+	}
+
+	engine.mutex.Lock()
+	if len(engine.activeInstances) > 0 && !engine.suspended {
+		engine.mutex.Unlock()
+		return fmt.Errorf("cannot resume into an engine with active instances")
+	}
+
+	if snapshot.StateMachine != nil {
+		engine.stateMachineCore = snapshot.StateMachine
+		engine.stateMachineCore.Compile()
+		engine.initialStateID = snapshot.InitialStateID
+		engine.invariants = snapshot.Invariants
+		engine.loadedDefinitionID = ""
+		engine.loadedDefinitionVersion = ""
+	}
+
+	engine.activeInstances = make(map[WorkflowInstanceID]*WorkflowInstance, len(instances))
+	for index := range instances {
+		engine.applyContextDefaultsUnsafe(&instances[index])
+		engine.activeInstances[instances[index].ID] = &instances[index]
+	}
+
+	engine.debugInstances = make(map[WorkflowInstanceID]bool, len(snapshot.DebugInstances))
+	for _, instanceID := range snapshot.DebugInstances {
+		engine.debugInstances[instanceID] = true
+	}
+
+	engine.history = snapshot.History
+	if engine.history == nil {
+		engine.history = make(map[WorkflowInstanceID][]TransitionRecord)
+	}
+
+	engine.humanTasks = make(map[string]HumanTask, len(snapshot.HumanTasks))
+	engine.redeemingHumanTasks = make(map[string]bool)
+	for _, task := range snapshot.HumanTasks {
+		engine.humanTasks[task.Token] = task
+	}
+	engine.mutex.Unlock()
+
+	engine.sharedContexts.restore(snapshot.SharedContexts)
+
+	engine.writeAhead.mutex.Lock()
+	engine.writeAhead.pending = snapshot.PendingWrites
+	engine.writeAhead.mutex.Unlock()
+
+	for _, instance := range instances {
+		if err := engine.restoreInstance(instance); err != nil {
+			return err
+		}
+		if resumed[instance.ID] {
+			if err := engine.lifecycleManager.OnWorkflowResumed(instance.ID); err != nil {
+				engine.errorHandler.HandleError(instance.ID, fmt.Errorf("lifecycle manager error: %w", err))
+			}
+		}
+	}
+
+	engine.mutex.Lock()
+	engine.suspended = false
+	engine.mutex.Unlock()
+
+	return nil
+}
+
+// restoreInstance writes a restored instance to the persistence store, saving it
+// if the store does not have it yet
+func (engine *WorkflowRuntimeEngine) restoreInstance(instance WorkflowInstance) error {
+	if instance.Ephemeral {
+		return nil
+	}
+
+	if _, err := engine.persistenceStore.GetWorkflowInstance(instance.ID); err != nil {
+		if err := engine.persistenceStore.SaveWorkflowInstance(instance); err != nil {
+			return fmt.Errorf("failed to save restored workflow instance %s: %w", instance.ID, err)
+		}
+		return nil
+	}
+
+	if err := engine.updateInstance(instance); err != nil {
+		return fmt.Errorf("failed to update restored workflow instance %s: %w", instance.ID, err)
+	}
+	return nil
+}
+
+// sortInstanceIDs orders instance IDs
+func sortInstanceIDs(instanceIDs []WorkflowInstanceID) {
+	sort.Slice(instanceIDs, func(i, j int) bool {
+		return instanceIDs[i] < instanceIDs[j]
+	})
+}
@@ -0,0 +1,80 @@
+package layer2
+
+import (
+	"fmt"
+	"time"
+)
+
+// Instances of definitions with PersistenceEnabled unset are ephemeral: the engine
+// keeps them only in memory and never writes them, their work or their status to
+// the persistence store. They do not survive an engine restart and cannot wait for
+// asynchronous or human work. ProcessStateTimeouts, ProcessStalledInstances and
+// ProcessSLABreaches find them among the engine's active instances. Finished
+// ephemeral instances stay queryable through GetWorkflowInstance until
+// ReapExpiredInstances removes them once their TTL passes.
+
+// retireInstanceUnsafe removes a finished instance from the active instances,
+// keeping ephemeral instances in memory since the store has no copy of them.
+// The tokens of its human tasks can no longer be redeemed and are dropped.
+// This method assumes the caller already holds the mutex lock.
+func (engine *WorkflowRuntimeEngine) retireInstanceUnsafe(instance *WorkflowInstance) {
+	delete(engine.activeInstances, instance.ID)
+	delete(engine.debugInstances, instance.ID)
+	engine.dropHumanTasksUnsafe(instance.ID)
+
+	if instance.Ephemeral {
+		engine.finishedEphemeral[instance.ID] = *instance
+	}
+}
+
+// reapEphemeralInstances removes finished ephemeral instances whose expiry is at or
+// before now and returns the number removed
+func (engine *WorkflowRuntimeEngine) reapEphemeralInstances(now time.Time) int {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	reaped := 0
+	for instanceID, instance := range engine.finishedEphemeral {
+		if !instance.IsExpired(now) {
+			continue
+		}
+		delete(engine.finishedEphemeral, instanceID)
+		delete(engine.history, instanceID)
+		reaped++
+	}
+	return reaped
+}
+
+// listScannableInstances lists the instances the background processors scan: the
+// instances in the persistence store merged with the engine's active instances, whose
+// in-memory view takes precedence. Ephemeral instances are only found in memory.
+// Only instances the engine owns are returned.
+func (engine *WorkflowRuntimeEngine) listScannableInstances() ([]WorkflowInstance, error) {
+	instances, err := engine.persistenceStore.ListAllWorkflowInstances()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflow instances: %w", err)
+	}
+
+	engine.mutex.RLock()
+	listed := make(map[WorkflowInstanceID]bool, len(instances))
+	for i, instance := range instances {
+		listed[instance.ID] = true
+		if active, exists := engine.activeInstances[instance.ID]; exists {
+			instances[i] = *active
+		}
+	}
+
+	var unlisted []WorkflowInstanceID
+	for instanceID := range engine.activeInstances {
+		if !listed[instanceID] {
+			unlisted = append(unlisted, instanceID)
+		}
+	}
+	sortInstanceIDs(unlisted)
+	for _, instanceID := range unlisted {
+		instances = append(instances, *engine.activeInstances[instanceID])
+	}
+	engine.mutex.RUnlock()
+
+	return engine.ownedInstances(instances), nil
+}
@@ -0,0 +1,74 @@
+package layer2
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// ExecutabilityError reports a transition action whose work would be rejected
+type ExecutabilityError struct {
+	TransitionID layer0.TransitionID `json:"transition_id"`
+	ActionID     string              `json:"action_id"`
+	Err          error               `json:"-"`
+}
+
+// Error formats the error with the transition and action it was found in
+func (e *ExecutabilityError) Error() string {
+	return fmt.Sprintf("transition %s action %s: %v", e.TransitionID, e.ActionID, e.Err)
+}
+
+// Unwrap returns the underlying validation error
+func (e *ExecutabilityError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateExecutability checks, without running anything, that the work of every
+// transition action of a definition would be accepted. Each action's work is built
+// as it would be for an instance and checked by the executor that would run it and
+// by the schema validator, if any. Unlike Validate, which checks the structure of
+// the definition, this catches executor configuration errors before the definition
+// is activated. Every failure is returned as an *ExecutabilityError. Work dispatched
+// to a backend other than the local one is not checked against local executors.
+func (engine *WorkflowRuntimeEngine) ValidateExecutability(definition layer1.WorkflowDefinition, validator layer1.WorkValidator) []error {
+	if definition.StateMachine == nil {
+		return []error{fmt.Errorf("workflow definition %s has no state machine", definition.GetID())}
+	}
+
+	engine.mutex.RLock()
+	_, local := engine.executionBackend.(*LocalExecutionBackend)
+	engine.mutex.RUnlock()
+
+	var errs []error
+	for _, state := range definition.StateMachine.GetAllStates() {
+		transitions := definition.StateMachine.GetTransitionsFromState(state.GetID())
+		sort.Slice(transitions, func(i, j int) bool {
+			return transitions[i].GetID() < transitions[j].GetID()
+		})
+
+		for _, transition := range transitions {
+			for _, actionID := range transition.GetActions() {
+				work := engine.actionWorkTemplate(actionID)
+
+				check := work.Validate
+				if local {
+					check = func() error { return engine.workExecutionCore.ValidateWork(work) }
+				}
+				if err := check(); err != nil {
+					errs = append(errs, &ExecutabilityError{TransitionID: transition.GetID(), ActionID: actionID, Err: err})
+					continue
+				}
+
+				if validator == nil {
+					continue
+				}
+				if err := validator.ValidateWork(work); err != nil {
+					errs = append(errs, &ExecutabilityError{TransitionID: transition.GetID(), ActionID: actionID, Err: fmt.Errorf("schema validation failed: %w", err)})
+				}
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,43 @@
+package layer2
+
+import (
+	"context"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// ExecutionBackend dispatches work for execution and reports its result. The engine
+// resolves secrets, schedules and projects the context before dispatching, and folds
+// the result's output back into the instance context, so a backend only runs the
+// work, whether in process or on remote workers such as a job queue or cluster.
+type ExecutionBackend interface {
+	Execute(ctx context.Context, work layer0.Work, workContext *layer0.Context) (layer1.WorkExecutionResult, error)
+}
+
+// LocalExecutionBackend runs work in process on a work execution core
+type LocalExecutionBackend struct {
+	core *layer1.WorkExecutionCore
+}
+
+// NewLocalExecutionBackend creates a new backend running work on the given core
+func NewLocalExecutionBackend(core *layer1.WorkExecutionCore) *LocalExecutionBackend {
+	return &LocalExecutionBackend{core: core}
+}
+
+// Execute runs work on the core, passing ctx to executors that support cancellation
+func (backend *LocalExecutionBackend) Execute(ctx context.Context, work layer0.Work, workContext *layer0.Context) (layer1.WorkExecutionResult, error) {
+	return backend.core.ExecuteWorkWithContext(ctx, work, workContext)
+}
+
+// SetExecutionBackend sets the backend work is dispatched to. A nil backend restores
+// the default, which runs work in process on the engine's registered executors.
+func (engine *WorkflowRuntimeEngine) SetExecutionBackend(backend ExecutionBackend) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if backend == nil {
+		backend = NewLocalExecutionBackend(engine.workExecutionCore)
+	}
+	engine.executionBackend = backend
+}
@@ -0,0 +1,56 @@
+package layer2
+
+import (
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// TransitionRecord captures a single transition attempt made by the engine
+type TransitionRecord struct {
+	InstanceID          WorkflowInstanceID           `json:"instance_id"`
+	CorrelationID       string                       `json:"correlation_id,omitempty"`
+	TransitionID        layer0.TransitionID          `json:"transition_id"`
+	FromStateID         layer0.StateID               `json:"from_state_id"`
+	ToStateID           layer0.StateID               `json:"to_state_id"`
+	StartedAt           time.Time                    `json:"started_at"`
+	CompletedAt         time.Time                    `json:"completed_at"`
+	WorkResults         []layer1.WorkExecutionResult `json:"work_results"`
+	Error               string                       `json:"error,omitempty"`
+	Tags                []string                     `json:"tags,omitempty"`
+	Annotations         map[string]interface{}       `json:"annotations,omitempty"`
+	ContextPatch        []PatchOperation             `json:"context_patch,omitempty"`         // How the transition changed the context data
+	ContextPatchOmitted int                          `json:"context_patch_omitted,omitempty"` // Operations dropped past the engine's patch limit
+}
+
+// Duration returns how long the transition took
+func (record TransitionRecord) Duration() time.Duration {
+	return record.CompletedAt.Sub(record.StartedAt)
+}
+
+// Succeeded checks if the transition was committed
+func (record TransitionRecord) Succeeded() bool {
+	return record.Error == ""
+}
+
+// recordTransition appends a transition record to the instance's execution history
+func (engine *WorkflowRuntimeEngine) recordTransition(record TransitionRecord) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.history[record.InstanceID] = append(engine.history[record.InstanceID], record)
+}
+
+// GetExecutionHistory returns the transitions attempted for an instance in execution order
+func (engine *WorkflowRuntimeEngine) GetExecutionHistory(instanceID WorkflowInstanceID) []TransitionRecord {
+	engine.mutex.RLock()
+	defer engine.mutex.RUnlock()
+
+	records := engine.history[instanceID]
+
+	// Return a copy to prevent external modification
+	result := make([]TransitionRecord, len(records))
+	copy(result, records)
+	return result
+}
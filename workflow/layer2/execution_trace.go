@@ -0,0 +1,207 @@
+package layer2
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SpanData represents a single span of a reconstructed workflow execution trace
+type SpanData struct {
+	TraceID      string            `json:"trace_id"`
+	SpanID       string            `json:"span_id"`
+	ParentSpanID string            `json:"parent_span_id,omitempty"`
+	Name         string            `json:"name"`
+	StartTime    time.Time         `json:"start_time"`
+	EndTime      time.Time         `json:"end_time"`
+	Attributes   map[string]string `json:"attributes"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// Duration returns the span duration
+func (span SpanData) Duration() time.Duration {
+	return span.EndTime.Sub(span.StartTime)
+}
+
+// ExportTrace reconstructs an instance's execution history as a span tree.
+// The first span is the root span for the instance; each transition becomes a
+// child of the root and each work execution becomes a child of its transition.
+// Trace and span IDs are derived from the instance ID so exports are repeatable.
+func (engine *WorkflowRuntimeEngine) ExportTrace(instanceID WorkflowInstanceID) ([]SpanData, error) {
+	instance, err := engine.GetWorkflowInstance(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	records := engine.GetExecutionHistory(instanceID)
+	traceID := traceHexID(string(instanceID), 16)
+
+	// Determine root span boundaries
+	rootStart := instance.CreatedAt
+	if instance.StartedAt != nil {
+		rootStart = *instance.StartedAt
+	}
+
+	rootEnd := instance.UpdatedAt
+	if instance.CompletedAt != nil {
+		rootEnd = *instance.CompletedAt
+	} else if len(records) > 0 && records[len(records)-1].CompletedAt.After(rootEnd) {
+		rootEnd = records[len(records)-1].CompletedAt
+	}
+
+	root := SpanData{
+		TraceID:   traceID,
+		SpanID:    traceHexID(string(instanceID)+"/root", 8),
+		Name:      fmt.Sprintf("workflow %s", instance.DefinitionID),
+		StartTime: rootStart,
+		EndTime:   rootEnd,
+		Attributes: map[string]string{
+			"workflow.instance_id":        string(instance.ID),
+			"workflow.definition_id":      string(instance.DefinitionID),
+			"workflow.definition_version": string(instance.DefinitionVersion),
+			"workflow.status":             string(instance.Status),
+			"workflow.current_state_id":   string(instance.CurrentStateID),
+			"workflow.correlation_id":     instance.CorrelationID,
+		},
+		Error: instance.Error,
+	}
+
+	spans := []SpanData{root}
+	for i, record := range records {
+		transitionSpan := SpanData{
+			TraceID:      traceID,
+			SpanID:       traceHexID(fmt.Sprintf("%s/transition/%d", instanceID, i), 8),
+			ParentSpanID: root.SpanID,
+			Name:         fmt.Sprintf("transition %s", record.TransitionID),
+			StartTime:    record.StartedAt,
+			EndTime:      record.CompletedAt,
+			Attributes: map[string]string{
+				"workflow.transition_id": string(record.TransitionID),
+				"workflow.from_state_id": string(record.FromStateID),
+				"workflow.to_state_id":   string(record.ToStateID),
+			},
+			Error: record.Error,
+		}
+		if len(record.Tags) > 0 {
+			transitionSpan.Attributes["workflow.transition_tags"] = strings.Join(record.Tags, ",")
+		}
+		for key, value := range record.Annotations {
+			transitionSpan.Attributes["workflow.annotation."+key] = fmt.Sprint(value)
+		}
+		spans = append(spans, transitionSpan)
+
+		for j, result := range record.WorkResults {
+			workSpan := SpanData{
+				TraceID:      traceID,
+				SpanID:       traceHexID(fmt.Sprintf("%s/transition/%d/work/%d", instanceID, i, j), 8),
+				ParentSpanID: transitionSpan.SpanID,
+				Name:         fmt.Sprintf("work %s", result.WorkID),
+				StartTime:    result.StartedAt,
+				EndTime:      result.StartedAt.Add(result.Duration),
+				Attributes: map[string]string{
+					"workflow.work_id":     string(result.WorkID),
+					"workflow.work_status": string(result.Status),
+					"workflow.duration_ms": strconv.FormatInt(result.Duration.Milliseconds(), 10),
+				},
+				Error: result.Error,
+			}
+			spans = append(spans, workSpan)
+		}
+	}
+
+	return spans, nil
+}
+
+// otlpAttribute mirrors the OTLP JSON KeyValue message
+type otlpAttribute struct {
+	Key   string            `json:"key"`
+	Value map[string]string `json:"value"`
+}
+
+// otlpStatus mirrors the OTLP JSON Status message
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpSpan mirrors the OTLP JSON Span message
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            otlpStatus      `json:"status"`
+}
+
+// MarshalSpansOTLPJSON serializes spans to the OTLP/JSON trace export format
+func MarshalSpansOTLPJSON(spans []SpanData) ([]byte, error) {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		keys := make([]string, 0, len(span.Attributes))
+		for key := range span.Attributes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		attributes := make([]otlpAttribute, 0, len(keys))
+		for _, key := range keys {
+			attributes = append(attributes, otlpAttribute{
+				Key:   key,
+				Value: map[string]string{"stringValue": span.Attributes[key]},
+			})
+		}
+
+		// Status codes: 1 = OK, 2 = ERROR
+		status := otlpStatus{Code: 1}
+		if span.Error != "" {
+			status = otlpStatus{Code: 2, Message: span.Error}
+		}
+
+		otlpSpans = append(otlpSpans, otlpSpan{
+			TraceID:           span.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentSpanID,
+			Name:              span.Name,
+			Kind:              1, // SPAN_KIND_INTERNAL
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Attributes:        attributes,
+			Status:            status,
+		})
+	}
+
+	document := map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []otlpAttribute{
+						{Key: "service.name", Value: map[string]string{"stringValue": "ubom-workflow"}},
+					},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "github.com/ubom/workflow/layer2"},
+						"spans": otlpSpans,
+					},
+				},
+			},
+		},
+	}
+
+	return json.Marshal(document)
+}
+
+// traceHexID derives a deterministic hex identifier of the given byte length
+func traceHexID(seed string, length int) string {
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:length])
+}
@@ -0,0 +1,151 @@
+package layer2
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ubom/workflow/layer1"
+)
+
+// HealthStatus represents the health of the engine or one of its subsystems
+type HealthStatus string
+
+const (
+	// HealthStatusHealthy indicates everything is working
+	HealthStatusHealthy HealthStatus = "healthy"
+	// HealthStatusDegraded indicates the engine works but some subsystems are failing
+	HealthStatusDegraded HealthStatus = "degraded"
+	// HealthStatusUnhealthy indicates the engine cannot make progress
+	HealthStatusUnhealthy HealthStatus = "unhealthy"
+)
+
+// HealthCheck reports an error when the checked subsystem is not working
+type HealthCheck func() error
+
+// SubsystemHealth is the health of a single engine subsystem
+type SubsystemHealth struct {
+	Name    string       `json:"name"`
+	Status  HealthStatus `json:"status"`
+	Message string       `json:"message,omitempty"`
+}
+
+// HealthReport aggregates the health of the engine's subsystems
+type HealthReport struct {
+	Status          HealthStatus      `json:"status"`
+	Subsystems      []SubsystemHealth `json:"subsystems"`
+	ActiveInstances int               `json:"active_instances"`
+	CheckedAt       time.Time         `json:"checked_at"`
+}
+
+// IsReady reports whether the engine can accept work
+func (report HealthReport) IsReady() bool {
+	return report.Status != HealthStatusUnhealthy
+}
+
+// Subsystem returns the health of the named subsystem
+func (report HealthReport) Subsystem(name string) (SubsystemHealth, bool) {
+	for _, subsystem := range report.Subsystems {
+		if subsystem.Name == name {
+			return subsystem, true
+		}
+	}
+	return SubsystemHealth{}, false
+}
+
+// RegisterHealthCheck registers an additional named check included in health reports
+func (engine *WorkflowRuntimeEngine) RegisterHealthCheck(name string, check HealthCheck) error {
+	if name == "" {
+		return fmt.Errorf("health check name cannot be empty")
+	}
+
+	if check == nil {
+		return fmt.Errorf("health check cannot be nil")
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if _, exists := engine.healthChecks[name]; exists {
+		return fmt.Errorf("health check %s already registered", name)
+	}
+
+	engine.healthChecks[name] = check
+	return nil
+}
+
+// Health checks the engine's subsystems. An unreachable persistence store makes
+// the engine unhealthy; failing executors or registered checks degrade it.
+func (engine *WorkflowRuntimeEngine) Health() HealthReport {
+	report := HealthReport{
+		Status:    HealthStatusHealthy,
+		CheckedAt: engine.clock.Now(),
+	}
+
+	// The persistence store is required for any progress
+	store := SubsystemHealth{Name: "persistence_store", Status: HealthStatusHealthy}
+	if _, err := engine.persistenceStore.GetStats(); err != nil {
+		store.Status = HealthStatusUnhealthy
+		store.Message = err.Error()
+	}
+	report.add(store)
+
+	// Executors that can check their dependencies
+	workTypes := engine.workExecutionCore.GetSupportedWorkTypes()
+	sort.Slice(workTypes, func(i, j int) bool {
+		return workTypes[i] < workTypes[j]
+	})
+	for _, workType := range workTypes {
+		executor, err := engine.workExecutionCore.GetExecutor(workType)
+		if err != nil {
+			continue
+		}
+
+		checked, ok := executor.(layer1.HealthCheckedExecutor)
+		if !ok {
+			continue
+		}
+
+		report.add(checkSubsystem(fmt.Sprintf("executor:%s", workType), checked.HealthCheck))
+	}
+
+	// Registered checks
+	engine.mutex.RLock()
+	checks := make(map[string]HealthCheck, len(engine.healthChecks))
+	for name, check := range engine.healthChecks {
+		checks[name] = check
+	}
+	report.ActiveInstances = len(engine.activeInstances)
+	engine.mutex.RUnlock()
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		report.add(checkSubsystem(name, checks[name]))
+	}
+
+	return report
+}
+
+// add records a subsystem and lowers the overall status to match it
+func (report *HealthReport) add(subsystem SubsystemHealth) {
+	report.Subsystems = append(report.Subsystems, subsystem)
+
+	switch {
+	case subsystem.Status == HealthStatusUnhealthy:
+		report.Status = HealthStatusUnhealthy
+	case subsystem.Status == HealthStatusDegraded && report.Status == HealthStatusHealthy:
+		report.Status = HealthStatusDegraded
+	}
+}
+
+// checkSubsystem runs a non-critical check, reporting failures as degraded
+func checkSubsystem(name string, check HealthCheck) SubsystemHealth {
+	if err := check(); err != nil {
+		return SubsystemHealth{Name: name, Status: HealthStatusDegraded, Message: err.Error()}
+	}
+	return SubsystemHealth{Name: name, Status: HealthStatusHealthy}
+}
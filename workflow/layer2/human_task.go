@@ -0,0 +1,211 @@
+package layer2
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+var (
+	// ErrInvalidHumanTaskToken indicates a completion token that was never issued or was already used
+	ErrInvalidHumanTaskToken = errors.New("invalid human task token")
+	// ErrHumanTaskTokenExpired indicates a completion token presented after its expiry
+	ErrHumanTaskTokenExpired = errors.New("human task token expired")
+)
+
+// HumanTask is human task work waiting for an approver to complete it with its token
+type HumanTask struct {
+	Token      string             `json:"token"`
+	InstanceID WorkflowInstanceID `json:"instance_id"`
+	WorkID     layer0.WorkID      `json:"work_id"`
+	Outcomes   []string           `json:"outcomes,omitempty"`
+	IssuedAt   time.Time          `json:"issued_at"`
+	ExpiresAt  *time.Time         `json:"expires_at,omitempty"`
+}
+
+// issueHumanTaskToken registers a single-use completion token for blocked human task work.
+// Tokens are held in memory; they are carried across Suspend and Resume but not
+// across an engine restart.
+func (engine *WorkflowRuntimeEngine) issueHumanTaskToken(instanceID WorkflowInstanceID, work layer0.Work) error {
+	expiry, expires, err := layer1.HumanTaskExpiry(work)
+	if err != nil {
+		return err
+	}
+	outcomes, err := layer1.HumanTaskOutcomes(work)
+	if err != nil {
+		return err
+	}
+
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return fmt.Errorf("failed to generate token for human task %s: %w", work.GetID(), err)
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	task := HumanTask{
+		Token:      hex.EncodeToString(buffer),
+		InstanceID: instanceID,
+		WorkID:     work.GetID(),
+		Outcomes:   outcomes,
+		IssuedAt:   engine.clock.Now(),
+	}
+	if expires {
+		expiresAt := task.IssuedAt.Add(expiry)
+		task.ExpiresAt = &expiresAt
+	}
+
+	// A task suspended again replaces the token issued for its earlier attempt
+	for token, issued := range engine.humanTasks {
+		if issued.InstanceID == instanceID && issued.WorkID == task.WorkID {
+			delete(engine.humanTasks, token)
+		}
+	}
+	engine.humanTasks[task.Token] = task
+	return nil
+}
+
+// HumanTasks returns the human tasks of an instance that are waiting for completion, ordered by work ID
+func (engine *WorkflowRuntimeEngine) HumanTasks(instanceID WorkflowInstanceID) []HumanTask {
+	engine.mutex.RLock()
+	defer engine.mutex.RUnlock()
+
+	var tasks []HumanTask
+	for _, task := range engine.humanTasks {
+		if task.InstanceID == instanceID {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].WorkID < tasks[j].WorkID
+	})
+	return tasks
+}
+
+// CompleteHumanTask completes the human task a token was issued for. The token is
+// consumed on success and rejected once expired; if completion fails the token stays
+// valid so the approver can retry. The payload is merged into the instance context
+// and, when the task declares outcomes, each outcome is set in the context to whether
+// it was chosen, so transitions conditioned on an outcome route the instance along
+// that branch.
+func (engine *WorkflowRuntimeEngine) CompleteHumanTask(token string, outcome string, payload map[string]interface{}) error {
+	issuedAt := engine.clock.Now()
+	task, err := engine.redeemHumanTaskToken(token, outcome)
+	if err != nil {
+		return err
+	}
+
+	err = engine.completeHumanTask(task.InstanceID, task.WorkID, outcome, payload)
+	engine.finishHumanTaskRedemption(token, err == nil)
+	engine.recordCommand(Command{Type: CommandCompleteHuman, InstanceID: task.InstanceID, WorkID: task.WorkID, Result: &AsyncWorkResult{Output: outcome}, Payload: payload, IssuedAt: issuedAt}, err)
+	return err
+}
+
+// redeemHumanTaskToken validates a token and the outcome presented with it, marking
+// the token as being redeemed so it cannot be presented again until
+// finishHumanTaskRedemption settles it
+func (engine *WorkflowRuntimeEngine) redeemHumanTaskToken(token string, outcome string) (HumanTask, error) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	task, exists := engine.humanTasks[token]
+	if !exists {
+		return HumanTask{}, ErrInvalidHumanTaskToken
+	}
+
+	if engine.redeemingHumanTasks[token] {
+		return HumanTask{}, fmt.Errorf("%w: human task %s is already being completed", ErrInvalidHumanTaskToken, task.WorkID)
+	}
+
+	if task.ExpiresAt != nil && !engine.clock.Now().Before(*task.ExpiresAt) {
+		delete(engine.humanTasks, token)
+		return HumanTask{}, fmt.Errorf("%w: human task %s expired at %s", ErrHumanTaskTokenExpired, task.WorkID, task.ExpiresAt.Format(time.RFC3339))
+	}
+
+	if len(task.Outcomes) > 0 && !containsOutcome(task.Outcomes, outcome) {
+		return HumanTask{}, fmt.Errorf("human task %s does not accept outcome %q", task.WorkID, outcome)
+	}
+
+	engine.redeemingHumanTasks[token] = true
+	return task, nil
+}
+
+// finishHumanTaskRedemption consumes a token being redeemed once its task completed,
+// or releases it for another attempt when completion failed
+func (engine *WorkflowRuntimeEngine) finishHumanTaskRedemption(token string, completed bool) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	delete(engine.redeemingHumanTasks, token)
+	if completed {
+		delete(engine.humanTasks, token)
+	}
+}
+
+// completeHumanTask completes the blocked work with the payload and outcome without
+// recording the command
+func (engine *WorkflowRuntimeEngine) completeHumanTask(instanceID WorkflowInstanceID, workID layer0.WorkID, outcome string, payload map[string]interface{}) error {
+	if _, err := engine.loadWaitingInstance(instanceID); err != nil {
+		return err
+	}
+
+	pending, err := engine.persistenceStore.GetWork(instanceID, workID)
+	if err != nil {
+		return fmt.Errorf("human task %s not found for instance %s: %w", workID, instanceID, err)
+	}
+	outcomes, err := layer1.HumanTaskOutcomes(pending)
+	if err != nil {
+		return err
+	}
+
+	// The payload and chosen outcome are staged with the suspended transition
+	values := make(map[string]interface{}, len(payload)+len(outcomes))
+	for key, value := range payload {
+		values[key] = value
+	}
+	for _, declared := range outcomes {
+		values[declared] = declared == outcome
+	}
+
+	return engine.completeAsyncWork(instanceID, workID, AsyncWorkResult{Output: outcome}, &humanTaskCompletion{values: values})
+}
+
+// dropHumanTask removes the token of human task work that is no longer waiting for completion
+func (engine *WorkflowRuntimeEngine) dropHumanTask(instanceID WorkflowInstanceID, workID layer0.WorkID) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	for token, task := range engine.humanTasks {
+		if task.InstanceID == instanceID && task.WorkID == workID {
+			delete(engine.humanTasks, token)
+		}
+	}
+}
+
+// dropHumanTasksUnsafe removes the tokens of every human task of an instance that
+// left the state its tasks were waiting in.
+// This method assumes the caller already holds the mutex lock.
+func (engine *WorkflowRuntimeEngine) dropHumanTasksUnsafe(instanceID WorkflowInstanceID) {
+	for token, task := range engine.humanTasks {
+		if task.InstanceID == instanceID {
+			delete(engine.humanTasks, token)
+		}
+	}
+}
+
+// containsOutcome checks if outcome is one of the declared outcomes
+func containsOutcome(outcomes []string, outcome string) bool {
+	for _, declared := range outcomes {
+		if declared == outcome {
+			return true
+		}
+	}
+	return false
+}
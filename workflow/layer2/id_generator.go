@@ -0,0 +1,70 @@
+package layer2
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// IDGenerator generates the IDs the engine assigns to new instances and correlations
+// so they can be made predictable in tests
+type IDGenerator interface {
+	NewID(prefix string) string
+}
+
+// SequentialIDGenerator numbers IDs from 1 separately for each prefix
+type SequentialIDGenerator struct {
+	counters map[string]int
+	mutex    sync.Mutex
+}
+
+// NewSequentialIDGenerator creates a new sequential ID generator
+func NewSequentialIDGenerator() *SequentialIDGenerator {
+	return &SequentialIDGenerator{
+		counters: make(map[string]int),
+		mutex:    sync.Mutex{},
+	}
+}
+
+// NewID returns the prefix followed by the next number for that prefix
+func (generator *SequentialIDGenerator) NewID(prefix string) string {
+	generator.mutex.Lock()
+	defer generator.mutex.Unlock()
+
+	generator.counters[prefix]++
+	return fmt.Sprintf("%s-%d", prefix, generator.counters[prefix])
+}
+
+// SetIDGenerator sets the generator of instance IDs, prefixed by the definition ID,
+// and of correlation IDs not supplied at start. A nil generator restores the default
+// time-based instance IDs and random correlation IDs.
+func (engine *WorkflowRuntimeEngine) SetIDGenerator(generator IDGenerator) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.idGenerator = generator
+}
+
+// newInstanceID generates the ID of a new instance of a definition
+func (engine *WorkflowRuntimeEngine) newInstanceID(definitionID string) WorkflowInstanceID {
+	engine.mutex.RLock()
+	generator := engine.idGenerator
+	engine.mutex.RUnlock()
+
+	if generator == nil {
+		return WorkflowInstanceID(fmt.Sprintf("%s-%d", definitionID, time.Now().UnixNano()))
+	}
+	return WorkflowInstanceID(generator.NewID(definitionID))
+}
+
+// resolveCorrelationID returns the correlation ID supplied at start or generates one
+func (engine *WorkflowRuntimeEngine) resolveCorrelationID(options StartOptions) string {
+	engine.mutex.RLock()
+	generator := engine.idGenerator
+	engine.mutex.RUnlock()
+
+	if options.CorrelationID != "" || generator == nil {
+		return options.resolveCorrelationID()
+	}
+	return generator.NewID("corr")
+}
@@ -0,0 +1,68 @@
+package layer2
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// instanceContext returns the context work of the instance executes under,
+// creating it if the instance has none yet (e.g. after being restored)
+func (engine *WorkflowRuntimeEngine) instanceContext(instanceID WorkflowInstanceID) context.Context {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if ctx, exists := engine.instanceContexts[instanceID]; exists {
+		return ctx
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	engine.instanceContexts[instanceID] = ctx
+	engine.instanceCancels[instanceID] = cancel
+	return ctx
+}
+
+// cancelInstanceContextUnsafe cancels in-flight work of the instance and releases its context.
+// This method assumes the caller already holds the mutex lock.
+func (engine *WorkflowRuntimeEngine) cancelInstanceContextUnsafe(instanceID WorkflowInstanceID) {
+	if cancel, exists := engine.instanceCancels[instanceID]; exists {
+		cancel()
+	}
+	delete(engine.instanceCancels, instanceID)
+	delete(engine.instanceContexts, instanceID)
+}
+
+// GetChildInstances returns the IDs of active instances started with the given parent
+func (engine *WorkflowRuntimeEngine) GetChildInstances(parentID WorkflowInstanceID) []WorkflowInstanceID {
+	engine.mutex.RLock()
+	defer engine.mutex.RUnlock()
+
+	return engine.childInstancesUnsafe(parentID)
+}
+
+// childInstancesUnsafe returns the IDs of active children of an instance in sorted order.
+// This method assumes the caller already holds the mutex lock.
+func (engine *WorkflowRuntimeEngine) childInstancesUnsafe(parentID WorkflowInstanceID) []WorkflowInstanceID {
+	var children []WorkflowInstanceID
+	for instanceID, instance := range engine.activeInstances {
+		if instance.ParentInstanceID == parentID {
+			children = append(children, instanceID)
+		}
+	}
+
+	sort.Slice(children, func(i, j int) bool {
+		return children[i] < children[j]
+	})
+	return children
+}
+
+// cancelChildrenUnsafe cancels the active children of an instance, depth first.
+// This method assumes the caller already holds the mutex lock.
+func (engine *WorkflowRuntimeEngine) cancelChildrenUnsafe(parentID WorkflowInstanceID) error {
+	for _, childID := range engine.childInstancesUnsafe(parentID) {
+		if err := engine.cancelWorkflowUnsafe(childID); err != nil {
+			return fmt.Errorf("failed to cancel child instance %s: %w", childID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package layer2
+
+import (
+	"fmt"
+)
+
+// InstanceEnricher populates the metadata and labels of an instance being started,
+// for example from its initial context. Changes to other fields are discarded.
+type InstanceEnricher func(instance *WorkflowInstance) error
+
+// AddInstanceEnricher adds an enricher run on every instance as it starts.
+// Enrichers run in the order they were added, each seeing the changes of the
+// ones before it; an enricher error fails the start.
+func (engine *WorkflowRuntimeEngine) AddInstanceEnricher(enricher InstanceEnricher) error {
+	if enricher == nil {
+		return fmt.Errorf("instance enricher cannot be nil")
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.enrichers = append(engine.enrichers, enricher)
+	return nil
+}
+
+// enrichInstance runs the enrichers on a copy of the instance and keeps the
+// metadata and labels they set
+func (engine *WorkflowRuntimeEngine) enrichInstance(instance *WorkflowInstance) error {
+	engine.mutex.RLock()
+	enrichers := append([]InstanceEnricher(nil), engine.enrichers...)
+	engine.mutex.RUnlock()
+
+	if len(enrichers) == 0 {
+		return nil
+	}
+
+	enriched := *instance
+	if instance.Context != nil {
+		enriched.Context = instance.Context.Clone()
+	}
+	enriched.Labels = make(map[string]string, len(instance.Labels))
+	for key, value := range instance.Labels {
+		enriched.Labels[key] = value
+	}
+
+	for _, enricher := range enrichers {
+		if err := enricher(&enriched); err != nil {
+			return fmt.Errorf("failed to enrich workflow instance %s: %w", instance.ID, err)
+		}
+	}
+
+	instance.Metadata = enriched.Metadata
+	instance.Labels = enriched.Labels
+	if len(instance.Labels) == 0 {
+		instance.Labels = nil
+	}
+	return nil
+}
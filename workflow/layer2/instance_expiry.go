@@ -0,0 +1,108 @@
+package layer2
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// IsTerminal checks if the instance has finished and will not run again
+func (instance WorkflowInstance) IsTerminal() bool {
+	switch instance.Status {
+	case WorkflowInstanceStatusCompleted, WorkflowInstanceStatusFailed, WorkflowInstanceStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsExpired checks if a terminal instance is past its expiry at the given time
+func (instance WorkflowInstance) IsExpired(now time.Time) bool {
+	return instance.IsTerminal() && instance.ExpiresAt != nil && !now.Before(*instance.ExpiresAt)
+}
+
+// SetInstanceTTL sets the default retention period for terminal instances.
+// A definition's InstanceTTL takes precedence; zero keeps instances forever.
+func (engine *WorkflowRuntimeEngine) SetInstanceTTL(ttl time.Duration) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.instanceTTL = ttl
+}
+
+// markTerminal sets the completion and expiry times of an instance entering a terminal status
+// and cancels any of its work still in flight, its context watches and its work result streams
+func (engine *WorkflowRuntimeEngine) markTerminal(instance *WorkflowInstance, status WorkflowInstanceStatus) {
+	now := engine.clock.Now()
+	instance.Status = status
+	instance.CompletedAt = &now
+	instance.UpdatedAt = now
+
+	if instance.TTL > 0 {
+		expiresAt := now.Add(instance.TTL)
+		instance.ExpiresAt = &expiresAt
+	}
+
+	engine.cancelInstanceContextUnsafe(instance.ID)
+	delete(engine.contextWatches, instance.ID)
+	engine.closeWorkResultSubscriptionsUnsafe(instance.ID)
+	engine.recordWorkflowFinished(instance)
+}
+
+// ReapExpiredInstances deletes terminal instances whose expiry is at or before now
+// and returns the number of instances reaped
+func (engine *WorkflowRuntimeEngine) ReapExpiredInstances(now time.Time) (int, error) {
+	instances, err := engine.persistenceStore.ListAllWorkflowInstances()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list workflow instances: %w", err)
+	}
+
+	instances = engine.ownedInstances(instances)
+
+	reaped := engine.reapEphemeralInstances(now)
+	for _, instance := range instances {
+		if !instance.IsExpired(now) {
+			continue
+		}
+
+		if err := engine.persistenceStore.DeleteWorkflowInstance(instance.ID); err != nil {
+			return reaped, fmt.Errorf("failed to delete expired workflow instance %s: %w", instance.ID, err)
+		}
+
+		engine.mutex.Lock()
+		delete(engine.history, instance.ID)
+		engine.mutex.Unlock()
+
+		reaped++
+	}
+
+	return reaped, nil
+}
+
+// StartInstanceReaper reaps expired instances on the given interval until the
+// returned stop function is called. Reaping errors are reported to the error handler.
+func (engine *WorkflowRuntimeEngine) StartInstanceReaper(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := engine.ReapExpiredInstances(engine.clock.Now()); err != nil {
+					engine.errorHandler.HandleError("", fmt.Errorf("instance reaper error: %w", err))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
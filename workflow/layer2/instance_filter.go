@@ -0,0 +1,141 @@
+package layer2
+
+import (
+	"sort"
+
+	"github.com/ubom/workflow/layer1"
+)
+
+// InstanceFilter selects workflow instances by definition, status and labels.
+// Empty fields match every instance; all set fields must match.
+type InstanceFilter struct {
+	DefinitionID layer1.WorkflowDefinitionID `json:"definition_id,omitempty"`
+	Statuses     []WorkflowInstanceStatus    `json:"statuses,omitempty"`
+	Labels       map[string]string           `json:"labels,omitempty"`
+}
+
+// Matches checks if an instance satisfies the filter
+func (filter InstanceFilter) Matches(instance WorkflowInstance) bool {
+	if filter.DefinitionID != "" && instance.DefinitionID != filter.DefinitionID {
+		return false
+	}
+
+	if len(filter.Statuses) > 0 {
+		matched := false
+		for _, status := range filter.Statuses {
+			if instance.Status == status {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for key, value := range filter.Labels {
+		if instance.Labels[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// InstanceOperationResult reports the outcome of a bulk operation on one instance
+type InstanceOperationResult struct {
+	InstanceID WorkflowInstanceID `json:"instance_id"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// Succeeded checks if the operation was applied to the instance
+func (result InstanceOperationResult) Succeeded() bool {
+	return result.Error == ""
+}
+
+// PauseWorkflowsByFilter pauses every active instance matching the filter,
+// recording the reason and initiator in each instance's metadata
+func (engine *WorkflowRuntimeEngine) PauseWorkflowsByFilter(filter InstanceFilter, reason, initiator string) []InstanceOperationResult {
+	return engine.applyToMatchingInstances(filter, map[string]interface{}{
+		"pause_reason": reason,
+		"paused_by":    initiator,
+	}, engine.PauseWorkflow)
+}
+
+// CancelWorkflowsByFilter cancels every active instance matching the filter,
+// recording the reason and initiator in each instance's metadata
+func (engine *WorkflowRuntimeEngine) CancelWorkflowsByFilter(filter InstanceFilter, reason, initiator string) []InstanceOperationResult {
+	return engine.applyToMatchingInstances(filter, map[string]interface{}{
+		"cancel_reason": reason,
+		"cancelled_by":  initiator,
+	}, engine.CancelWorkflow)
+}
+
+// applyToMatchingInstances annotates and applies an operation to each matching
+// active instance in ID order, collecting a result per instance. The annotations
+// of an instance the operation fails on are rolled back.
+func (engine *WorkflowRuntimeEngine) applyToMatchingInstances(filter InstanceFilter, annotations map[string]interface{}, operation func(WorkflowInstanceID) error) []InstanceOperationResult {
+	engine.mutex.RLock()
+	var instanceIDs []WorkflowInstanceID
+	for instanceID, instance := range engine.activeInstances {
+		if filter.Matches(*instance) {
+			instanceIDs = append(instanceIDs, instanceID)
+		}
+	}
+	engine.mutex.RUnlock()
+
+	sort.Slice(instanceIDs, func(i, j int) bool {
+		return instanceIDs[i] < instanceIDs[j]
+	})
+
+	results := make([]InstanceOperationResult, 0, len(instanceIDs))
+	for _, instanceID := range instanceIDs {
+		// Annotate first so the operation persists the annotations with the new status
+		restore := engine.annotateInstance(instanceID, annotations)
+
+		result := InstanceOperationResult{InstanceID: instanceID}
+		if err := operation(instanceID); err != nil {
+			restore()
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// annotateInstance sets annotations in the metadata of an active instance, returning
+// a function that restores the values they replaced
+func (engine *WorkflowRuntimeEngine) annotateInstance(instanceID WorkflowInstanceID, annotations map[string]interface{}) func() {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	instance, exists := engine.activeInstances[instanceID]
+	if !exists {
+		return func() {}
+	}
+
+	if instance.Metadata == nil {
+		instance.Metadata = make(map[string]interface{})
+	}
+	previous := make(map[string]interface{}, len(annotations))
+	for key, value := range annotations {
+		if old, set := instance.Metadata[key]; set {
+			previous[key] = old
+		}
+		instance.Metadata[key] = value
+	}
+
+	return func() {
+		engine.mutex.Lock()
+		defer engine.mutex.Unlock()
+
+		for key := range annotations {
+			if old, set := previous[key]; set {
+				instance.Metadata[key] = old
+			} else {
+				delete(instance.Metadata, key)
+			}
+		}
+	}
+}
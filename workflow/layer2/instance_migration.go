@@ -0,0 +1,156 @@
+package layer2
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// CurrentInstanceSchemaVersion is the schema version written with every persisted instance.
+// Records saved before versioning was introduced have no version and are treated as version 1.
+const CurrentInstanceSchemaVersion = 2
+
+// InstanceRecord is the raw, decoded form of a persisted workflow instance
+type InstanceRecord map[string]interface{}
+
+// InstanceMigration upgrades an instance record from one schema version to the next
+type InstanceMigration struct {
+	FromVersion int
+	Description string
+	Migrate     func(record InstanceRecord) error
+}
+
+// InstanceMigrator upgrades persisted instance records to the current schema version
+type InstanceMigrator struct {
+	migrations map[int]InstanceMigration
+}
+
+// NewInstanceMigrator creates a new migrator with the built-in migrations registered
+func NewInstanceMigrator() *InstanceMigrator {
+	migrator := &InstanceMigrator{
+		migrations: make(map[int]InstanceMigration),
+	}
+
+	migrator.RegisterMigration(InstanceMigration{
+		FromVersion: 1,
+		Description: "default missing metadata",
+		Migrate: func(record InstanceRecord) error {
+			if record["metadata"] == nil {
+				record["metadata"] = map[string]interface{}{}
+			}
+			return nil
+		},
+	})
+
+	return migrator
+}
+
+// RegisterMigration registers a migration, replacing any migration from the same version
+func (migrator *InstanceMigrator) RegisterMigration(migration InstanceMigration) {
+	migrator.migrations[migration.FromVersion] = migration
+}
+
+// Upgrade applies every migration between the record's schema version and the current one
+func (migrator *InstanceMigrator) Upgrade(record InstanceRecord) error {
+	version := recordSchemaVersion(record)
+	if version > CurrentInstanceSchemaVersion {
+		return fmt.Errorf("instance schema version %d is newer than supported version %d", version, CurrentInstanceSchemaVersion)
+	}
+
+	for ; version < CurrentInstanceSchemaVersion; version++ {
+		migration, exists := migrator.migrations[version]
+		if !exists {
+			return fmt.Errorf("no migration registered from instance schema version %d", version)
+		}
+
+		if err := migration.Migrate(record); err != nil {
+			return fmt.Errorf("failed to migrate instance from schema version %d: %w", version, err)
+		}
+	}
+
+	record["schema_version"] = CurrentInstanceSchemaVersion
+	return nil
+}
+
+// DecodeWorkflowInstance decodes a persisted instance, upgrading it to the current schema first
+func (migrator *InstanceMigrator) DecodeWorkflowInstance(data []byte) (WorkflowInstance, error) {
+	var record InstanceRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return WorkflowInstance{}, fmt.Errorf("failed to decode workflow instance: %w", err)
+	}
+
+	if err := migrator.Upgrade(record); err != nil {
+		return WorkflowInstance{}, err
+	}
+
+	upgraded, err := json.Marshal(record)
+	if err != nil {
+		return WorkflowInstance{}, fmt.Errorf("failed to encode upgraded workflow instance: %w", err)
+	}
+
+	var instance WorkflowInstance
+	if err := json.Unmarshal(upgraded, &instance); err != nil {
+		return WorkflowInstance{}, fmt.Errorf("failed to decode upgraded workflow instance: %w", err)
+	}
+
+	return instance, nil
+}
+
+// EncodeWorkflowInstance encodes an instance for persistence, stamped with the current schema version
+func EncodeWorkflowInstance(instance WorkflowInstance) ([]byte, error) {
+	instance.SchemaVersion = CurrentInstanceSchemaVersion
+	return json.Marshal(instance)
+}
+
+// MigrateAll rewrites every instance in the store that predates the current schema version.
+// It returns the number of instances migrated.
+func (migrator *InstanceMigrator) MigrateAll(store StatePersistenceStore) (int, error) {
+	instances, err := store.ListAllWorkflowInstances()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list workflow instances: %w", err)
+	}
+
+	// Migrate in a stable order so partial failures are reproducible
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].ID < instances[j].ID
+	})
+
+	migrated := 0
+	for _, instance := range instances {
+		if instance.SchemaVersion >= CurrentInstanceSchemaVersion {
+			continue
+		}
+
+		data, err := json.Marshal(instance)
+		if err != nil {
+			return migrated, fmt.Errorf("failed to encode workflow instance %s: %w", instance.ID, err)
+		}
+
+		upgraded, err := migrator.DecodeWorkflowInstance(data)
+		if err != nil {
+			return migrated, fmt.Errorf("failed to migrate workflow instance %s: %w", instance.ID, err)
+		}
+
+		if err := store.UpdateWorkflowInstance(upgraded); err != nil {
+			return migrated, fmt.Errorf("failed to save migrated workflow instance %s: %w", instance.ID, err)
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
+
+// recordSchemaVersion returns a record's schema version, treating unversioned records as version 1
+func recordSchemaVersion(record InstanceRecord) int {
+	switch version := record["schema_version"].(type) {
+	case float64:
+		if version >= 1 {
+			return int(version)
+		}
+	case int:
+		if version >= 1 {
+			return version
+		}
+	}
+	return 1
+}
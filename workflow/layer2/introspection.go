@@ -0,0 +1,83 @@
+package layer2
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// ExecutorTopology describes the executor registered for a work type
+type ExecutorTopology struct {
+	WorkType       layer0.WorkType             `json:"work_type"`
+	Executor       string                      `json:"executor"`
+	SupportedTypes []layer0.WorkType           `json:"supported_types"`
+	Capabilities   layer1.ExecutorCapabilities `json:"capabilities"`
+}
+
+// EvaluatorTopology describes the evaluator registered for a condition type
+type EvaluatorTopology struct {
+	ConditionType  layer0.ConditionType   `json:"condition_type"`
+	Evaluator      string                 `json:"evaluator"`
+	SupportedTypes []layer0.ConditionType `json:"supported_types"`
+}
+
+// RuntimeTopology is a point-in-time picture of the engine's registered components,
+// active instances and persistence store. The engine has no plugin loader, so
+// plugins are not reported.
+type RuntimeTopology struct {
+	Executors       []ExecutorTopology        `json:"executors"`
+	Evaluators      []EvaluatorTopology       `json:"evaluators"`
+	ActiveInstances []WorkflowInstanceSummary `json:"active_instances"`
+	StoreStats      map[string]interface{}    `json:"store_stats,omitempty"`
+	StoreError      string                    `json:"store_error,omitempty"`
+	CapturedAt      time.Time                 `json:"captured_at"`
+}
+
+// Introspect returns the engine's current topology: the registered executors and
+// condition evaluators ordered by type, summaries of the active instances and the
+// persistence store stats. A failure to read the store stats is reported in
+// StoreError rather than failing the call.
+func (engine *WorkflowRuntimeEngine) Introspect() RuntimeTopology {
+	topology := RuntimeTopology{
+		Executors:       []ExecutorTopology{},
+		Evaluators:      []EvaluatorTopology{},
+		ActiveInstances: engine.ListActiveWorkflowsDetailed(),
+		CapturedAt:      engine.clock.Now(),
+	}
+
+	for _, workType := range engine.workExecutionCore.GetSupportedWorkTypes() {
+		executor, err := engine.workExecutionCore.GetExecutor(workType)
+		if err != nil {
+			continue
+		}
+		topology.Executors = append(topology.Executors, ExecutorTopology{
+			WorkType:       workType,
+			Executor:       fmt.Sprintf("%T", executor),
+			SupportedTypes: executor.GetSupportedTypes(),
+			Capabilities:   layer1.CapabilitiesOf(executor),
+		})
+	}
+
+	for _, conditionType := range engine.conditionEvaluationCore.GetSupportedConditionTypes() {
+		evaluator, err := engine.conditionEvaluationCore.GetEvaluator(conditionType)
+		if err != nil {
+			continue
+		}
+		topology.Evaluators = append(topology.Evaluators, EvaluatorTopology{
+			ConditionType:  conditionType,
+			Evaluator:      fmt.Sprintf("%T", evaluator),
+			SupportedTypes: evaluator.GetSupportedTypes(),
+		})
+	}
+
+	stats, err := engine.persistenceStore.GetStats()
+	if err != nil {
+		topology.StoreError = err.Error()
+	} else {
+		topology.StoreStats = stats
+	}
+
+	return topology
+}
@@ -0,0 +1,39 @@
+package layer2
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// ErrInvariantViolated indicates a transition would leave the instance violating a definition invariant
+var ErrInvariantViolated = errors.New("workflow invariant violated")
+
+// RegisterConditionEvaluator registers the evaluator for a condition type used to check invariants
+func (engine *WorkflowRuntimeEngine) RegisterConditionEvaluator(conditionType layer0.ConditionType, evaluator layer1.ConditionEvaluator) error {
+	return engine.conditionEvaluationCore.RegisterEvaluator(conditionType, evaluator)
+}
+
+// checkInvariants evaluates the definition's invariants against the context a
+// transition produced and reports the first that does not hold. An invariant
+// that cannot be evaluated is treated as violated.
+func (engine *WorkflowRuntimeEngine) checkInvariants(staged *layer0.Context) error {
+	for _, invariant := range engine.invariants {
+		result, err := engine.conditionEvaluationCore.EvaluateCondition(invariant.Condition, staged)
+		if err != nil {
+			return fmt.Errorf("%w: %s could not be evaluated: %v", ErrInvariantViolated, invariant.Name, err)
+		}
+
+		switch result.Status {
+		case layer0.ConditionStatusTrue:
+			continue
+		case layer0.ConditionStatusError:
+			return fmt.Errorf("%w: %s could not be evaluated: %s", ErrInvariantViolated, invariant.Name, result.Error)
+		default:
+			return fmt.Errorf("%w: %s", ErrInvariantViolated, invariant.Name)
+		}
+	}
+	return nil
+}
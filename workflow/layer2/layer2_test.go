@@ -1,8 +1,18 @@
 package layer2
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/ubom/workflow/layer0"
 	"github.com/ubom/workflow/layer1"
@@ -407,3 +417,5524 @@ func TestWorkflowRuntimeEngineShutdown(t *testing.T) {
 		t.Errorf("Expected 0 active workflows after shutdown, got %d", len(activeWorkflowsAfterShutdown))
 	}
 }
+
+func TestWorkflowRuntimeEngineDebugStep(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	engine.workExecutionCore.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, nil))
+
+	// Create a branching workflow: start -> (left | right) -> end
+	definition := layer1.NewWorkflowDefinition("debug-workflow", "1.0.0", "Debug Workflow")
+
+	startState := layer0.NewState("start", layer0.StateTypeInitial, "Start")
+	leftState := layer0.NewState("left", layer0.StateTypeIntermediate, "Left")
+	rightState := layer0.NewState("right", layer0.StateTypeIntermediate, "Right")
+	endState := layer0.NewState("end", layer0.StateTypeFinal, "End")
+
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(startState)
+	stateMachine.AddState(leftState)
+	stateMachine.AddState(rightState)
+	stateMachine.AddState(endState)
+
+	toLeft := layer0.NewTransition("start-to-left", layer0.TransitionTypeConditional, "start", "left", "Start to Left").
+		AddCondition("go_left").
+		AddAction("left-action")
+	toRight := layer0.NewTransition("start-to-right", layer0.TransitionTypeConditional, "start", "right", "Start to Right").
+		AddCondition("go_right")
+	leftToEnd := layer0.NewTransition("left-to-end", layer0.TransitionTypeAutomatic, "left", "end", "Left to End")
+	rightToEnd := layer0.NewTransition("right-to-end", layer0.TransitionTypeAutomatic, "right", "end", "Right to End")
+	stateMachine.AddTransition(toLeft)
+	stateMachine.AddTransition(toRight)
+	stateMachine.AddTransition(leftToEnd)
+	stateMachine.AddTransition(rightToEnd)
+
+	definition = definition.SetStateMachine(stateMachine).
+		SetInitialStateID(startState.GetID()).
+		AddFinalStateID(endState.GetID()).
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	initialContext := layer0.NewContext("debug-context", layer0.ContextScopeWorkflow, "Debug Context").
+		Set("go_left", true).
+		Set("go_right", false)
+
+	instanceID, err := engine.StartWorkflow(definition, initialContext)
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	// Step requires debug mode
+	if _, err := engine.Step(instanceID); err == nil {
+		t.Error("Step should return error when debug mode is not enabled")
+	}
+
+	if err := engine.EnableDebug(instanceID); err != nil {
+		t.Fatalf("EnableDebug should not return error: %v", err)
+	}
+
+	// Debug instances must not auto-advance
+	if err := engine.ExecuteWorkflow(instanceID); err == nil {
+		t.Error("ExecuteWorkflow should return error for an instance in debug mode")
+	}
+
+	// First step reports both choices and takes the satisfiable one
+	result, err := engine.Step(instanceID)
+	if err != nil {
+		t.Fatalf("Step should not return error: %v", err)
+	}
+
+	if len(result.Candidates) != 2 {
+		t.Fatalf("Expected 2 candidates, got %d", len(result.Candidates))
+	}
+
+	satisfiable := map[layer0.TransitionID]bool{}
+	for _, candidate := range result.Candidates {
+		satisfiable[candidate.Transition.GetID()] = candidate.Satisfiable
+	}
+
+	if !satisfiable["start-to-left"] {
+		t.Error("start-to-left should be reported as satisfiable")
+	}
+
+	if satisfiable["start-to-right"] {
+		t.Error("start-to-right should not be reported as satisfiable")
+	}
+
+	if result.ChosenTransition == nil || result.ChosenTransition.GetID() != "start-to-left" {
+		t.Errorf("Expected chosen transition start-to-left, got %v", result.ChosenTransition)
+	}
+
+	if result.FromStateID != "start" || result.ToStateID != "left" {
+		t.Errorf("Expected step from start to left, got %s to %s", result.FromStateID, result.ToStateID)
+	}
+
+	if len(result.WorkResults) != 1 || result.WorkResults[0].WorkID != "left-action" {
+		t.Errorf("Expected one work result for left-action, got %v", result.WorkResults)
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.CurrentStateID != "left" {
+		t.Errorf("Expected current state left, got %s", instance.CurrentStateID)
+	}
+
+	// Second step moves to the final state
+	result, err = engine.Step(instanceID)
+	if err != nil {
+		t.Fatalf("Step should not return error: %v", err)
+	}
+
+	if len(result.Candidates) != 1 || !result.Candidates[0].Satisfiable {
+		t.Errorf("Expected a single satisfiable candidate, got %v", result.Candidates)
+	}
+
+	if result.ToStateID != "end" {
+		t.Errorf("Expected step to end, got %s", result.ToStateID)
+	}
+
+	// Continue leaves debug mode and runs to completion
+	if err := engine.Continue(instanceID); err != nil {
+		t.Errorf("Continue should not return error: %v", err)
+	}
+
+	status, _ := engine.GetWorkflowStatus(instanceID)
+	if status != WorkflowInstanceStatusCompleted {
+		t.Errorf("Expected status %s, got %s", WorkflowInstanceStatusCompleted, status)
+	}
+
+	if engine.IsDebugEnabled(instanceID) {
+		t.Error("Debug mode should be cleared after completion")
+	}
+}
+
+func TestWorkflowRuntimeEngineExportTrace(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	engine.workExecutionCore.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		time.Sleep(5 * time.Millisecond)
+		return "done", nil
+	}))
+
+	// Create a linear workflow: start -> processing -> end
+	definition := layer1.NewWorkflowDefinition("trace-workflow", "1.0.0", "Trace Workflow")
+
+	startState := layer0.NewState("start", layer0.StateTypeInitial, "Start")
+	processingState := layer0.NewState("processing", layer0.StateTypeIntermediate, "Processing")
+	endState := layer0.NewState("end", layer0.StateTypeFinal, "End")
+
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(startState)
+	stateMachine.AddState(processingState)
+	stateMachine.AddState(endState)
+	stateMachine.AddTransition(layer0.NewTransition("start-to-processing", layer0.TransitionTypeAutomatic, "start", "processing", "Start to Processing").
+		AddAction("prepare").
+		AddAction("process"))
+	stateMachine.AddTransition(layer0.NewTransition("processing-to-end", layer0.TransitionTypeAutomatic, "processing", "end", "Processing to End"))
+
+	definition = definition.SetStateMachine(stateMachine).
+		SetInitialStateID(startState.GetID()).
+		AddFinalStateID(endState.GetID()).
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	initialContext := layer0.NewContext("trace-context", layer0.ContextScopeWorkflow, "Trace Context")
+	instanceID, err := engine.StartWorkflow(definition, initialContext)
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	spans, err := engine.ExportTrace(instanceID)
+	if err != nil {
+		t.Fatalf("ExportTrace should not return error: %v", err)
+	}
+
+	// 1 root + 2 transitions + 2 work executions
+	if len(spans) != 5 {
+		t.Fatalf("Expected 5 spans, got %d", len(spans))
+	}
+
+	root := spans[0]
+	if root.ParentSpanID != "" {
+		t.Error("Root span should not have a parent")
+	}
+
+	if root.Attributes["workflow.status"] != string(WorkflowInstanceStatusCompleted) {
+		t.Errorf("Expected root status attribute %s, got %s", WorkflowInstanceStatusCompleted, root.Attributes["workflow.status"])
+	}
+
+	spansByID := map[string]SpanData{}
+	children := map[string][]SpanData{}
+	for _, span := range spans {
+		if span.TraceID != root.TraceID {
+			t.Errorf("Span %s should share the root trace ID", span.Name)
+		}
+		spansByID[span.SpanID] = span
+		if span.ParentSpanID != "" {
+			children[span.ParentSpanID] = append(children[span.ParentSpanID], span)
+		}
+	}
+
+	transitionSpans := children[root.SpanID]
+	if len(transitionSpans) != 2 {
+		t.Fatalf("Expected 2 transition spans under root, got %d", len(transitionSpans))
+	}
+
+	if transitionSpans[0].Attributes["workflow.transition_id"] != "start-to-processing" {
+		t.Errorf("Expected first transition span for start-to-processing, got %s", transitionSpans[0].Attributes["workflow.transition_id"])
+	}
+
+	workSpans := children[transitionSpans[0].SpanID]
+	if len(workSpans) != 2 {
+		t.Fatalf("Expected 2 work spans under first transition, got %d", len(workSpans))
+	}
+
+	if len(children[transitionSpans[1].SpanID]) != 0 {
+		t.Error("Second transition should have no work spans")
+	}
+
+	// Child spans must lie within their parents
+	for _, span := range spans[1:] {
+		parent := spansByID[span.ParentSpanID]
+		if span.StartTime.Before(parent.StartTime) || span.EndTime.After(parent.EndTime) {
+			t.Errorf("Span %s should lie within parent %s", span.Name, parent.Name)
+		}
+	}
+
+	for _, workSpan := range workSpans {
+		if workSpan.Duration() < 5*time.Millisecond {
+			t.Errorf("Expected work span %s to last at least 5ms, got %s", workSpan.Name, workSpan.Duration())
+		}
+	}
+
+	// Export must be repeatable and serializable to OTLP JSON
+	again, _ := engine.ExportTrace(instanceID)
+	if again[2].SpanID != spans[2].SpanID {
+		t.Error("Span IDs should be deterministic across exports")
+	}
+
+	data, err := MarshalSpansOTLPJSON(spans)
+	if err != nil {
+		t.Fatalf("MarshalSpansOTLPJSON should not return error: %v", err)
+	}
+
+	var document struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []map[string]interface{} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatalf("OTLP JSON should be valid: %v", err)
+	}
+
+	if len(document.ResourceSpans) != 1 || len(document.ResourceSpans[0].ScopeSpans[0].Spans) != 5 {
+		t.Error("OTLP JSON should contain all 5 spans")
+	}
+}
+
+// newForkDefinition creates a workflow whose start state has two unconditional
+// transitions, so only the selection order decides which branch is taken
+func newForkDefinition(leftPriority, rightPriority int) layer1.WorkflowDefinition {
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	stateMachine.AddState(layer0.NewState("left", layer0.StateTypeIntermediate, "Left"))
+	stateMachine.AddState(layer0.NewState("right", layer0.StateTypeIntermediate, "Right"))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+
+	toLeft := layer0.NewTransition("start-to-left", layer0.TransitionTypeAutomatic, "start", "left", "Start to Left")
+	toLeft.Priority = leftPriority
+	toRight := layer0.NewTransition("start-to-right", layer0.TransitionTypeAutomatic, "start", "right", "Start to Right")
+	toRight.Priority = rightPriority
+	stateMachine.AddTransition(toLeft)
+	stateMachine.AddTransition(toRight)
+	stateMachine.AddTransition(layer0.NewTransition("left-to-end", layer0.TransitionTypeAutomatic, "left", "end", "Left to End"))
+	stateMachine.AddTransition(layer0.NewTransition("right-to-end", layer0.TransitionTypeAutomatic, "right", "end", "Right to End"))
+
+	return layer1.NewWorkflowDefinition("fork-workflow", "1.0.0", "Fork Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("end").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+}
+
+// runForkStep starts the fork workflow and returns the state reached after one step
+func runForkStep(t *testing.T, engine *WorkflowRuntimeEngine, definition layer1.WorkflowDefinition) layer0.StateID {
+	context := layer0.NewContext("fork-context", layer0.ContextScopeWorkflow, "Fork Context")
+	instanceID, err := engine.StartWorkflow(definition, context)
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	if err := engine.ExecuteStep(instanceID); err != nil {
+		t.Fatalf("ExecuteStep should not return error: %v", err)
+	}
+
+	instance, err := engine.GetWorkflowInstance(instanceID)
+	if err != nil {
+		t.Fatalf("GetWorkflowInstance should not return error: %v", err)
+	}
+	return instance.CurrentStateID
+}
+
+func TestWorkflowRuntimeEngineTransitionSelection(t *testing.T) {
+	// Without a seed, ties are broken by transition ID
+	if state := runForkStep(t, NewWorkflowRuntimeEngine(), newForkDefinition(0, 0)); state != "left" {
+		t.Errorf("Expected ID tiebreak to select left, got %s", state)
+	}
+
+	// Priority always wins over the tiebreak
+	if state := runForkStep(t, NewWorkflowRuntimeEngine(), newForkDefinition(0, 5)); state != "right" {
+		t.Errorf("Expected higher priority transition to select right, got %s", state)
+	}
+
+	// The same seed selects the same branch on separate engines
+	seen := map[layer0.StateID]bool{}
+	for seed := int64(0); seed < 32; seed++ {
+		first := NewWorkflowRuntimeEngine()
+		first.SetTransitionTiebreakSeed(seed)
+		second := NewWorkflowRuntimeEngine()
+		second.SetTransitionTiebreakSeed(seed)
+
+		firstState := runForkStep(t, first, newForkDefinition(0, 0))
+		secondState := runForkStep(t, second, newForkDefinition(0, 0))
+		if firstState != secondState {
+			t.Errorf("Seed %d selected %s and %s on separate engines", seed, firstState, secondState)
+		}
+		seen[firstState] = true
+
+		// A seeded tiebreak must still respect priority
+		priorityEngine := NewWorkflowRuntimeEngine()
+		priorityEngine.SetTransitionTiebreakSeed(seed)
+		if state := runForkStep(t, priorityEngine, newForkDefinition(5, 0)); state != "left" {
+			t.Errorf("Seed %d should not override priority, got %s", seed, state)
+		}
+	}
+
+	// Different seeds should not all produce the same order
+	if !seen["left"] || !seen["right"] {
+		t.Error("Expected seeded tiebreak to select both branches across seeds")
+	}
+
+	// Clearing the seed restores the ID tiebreak
+	engine := NewWorkflowRuntimeEngine()
+	engine.SetTransitionTiebreakSeed(1)
+	engine.ClearTransitionTiebreakSeed()
+	if state := runForkStep(t, engine, newForkDefinition(0, 0)); state != "left" {
+		t.Errorf("Expected cleared seed to select left, got %s", state)
+	}
+}
+
+func TestDefaultTransitionEvaluatorOperators(t *testing.T) {
+	evaluator := NewDefaultTransitionEvaluator()
+
+	// Only one of the two conditions holds
+	context := layer0.NewContext("operator-context", layer0.ContextScopeWorkflow, "Operator Context").
+		Set("approved", true).
+		Set("escalated", false)
+
+	transition := layer0.NewTransition("operator-transition", layer0.TransitionTypeConditional, "from", "to", "Operator Transition").
+		AddCondition("approved").
+		AddCondition("escalated")
+
+	// AND is the default and requires both conditions
+	canTransition, err := evaluator.CanTransition(transition, context)
+	if err != nil {
+		t.Errorf("CanTransition should not return error: %v", err)
+	}
+
+	if canTransition {
+		t.Error("AND transition should not fire when only one condition holds")
+	}
+
+	// OR fires when either condition holds
+	canTransition, err = evaluator.CanTransition(transition.SetOperator(layer0.ConditionOperatorOr), context)
+	if err != nil {
+		t.Errorf("CanTransition should not return error: %v", err)
+	}
+
+	if !canTransition {
+		t.Error("OR transition should fire when one condition holds")
+	}
+
+	// NOT inverts its single condition
+	notTransition := layer0.NewTransition("not-transition", layer0.TransitionTypeConditional, "from", "to", "Not Transition").
+		AddCondition("escalated").
+		SetOperator(layer0.ConditionOperatorNot)
+
+	canTransition, err = evaluator.CanTransition(notTransition, context)
+	if err != nil {
+		t.Errorf("CanTransition should not return error: %v", err)
+	}
+
+	if !canTransition {
+		t.Error("NOT transition should fire when its condition does not hold")
+	}
+}
+
+func TestWorkflowRuntimeEngineReapExpiredInstances(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	engine := NewWorkflowRuntimeEngine()
+	engine.SetClock(clock)
+	engine.SetInstanceTTL(time.Hour)
+
+	runToCompletion := func(definition layer1.WorkflowDefinition) WorkflowInstanceID {
+		context := layer0.NewContext("ttl-context", layer0.ContextScopeWorkflow, "TTL Context")
+		instanceID, err := engine.StartWorkflow(definition, context)
+		if err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+
+		if err := engine.ExecuteWorkflow(instanceID); err != nil {
+			t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+		}
+		return instanceID
+	}
+
+	// Complete one instance, then another half an hour later
+	first := runToCompletion(newForkDefinition(0, 0))
+	clock.Advance(30 * time.Minute)
+	second := runToCompletion(newForkDefinition(0, 0))
+
+	instance, _ := engine.GetWorkflowInstance(first)
+	if instance.ExpiresAt == nil || !instance.ExpiresAt.Equal(clock.Now().Add(30*time.Minute)) {
+		t.Errorf("Expected first instance to expire an hour after completion, got %v", instance.ExpiresAt)
+	}
+
+	// Nothing has expired yet
+	reaped, err := engine.ReapExpiredInstances(clock.Now())
+	if err != nil || reaped != 0 {
+		t.Errorf("Expected no instances reaped, got %d (%v)", reaped, err)
+	}
+
+	// Only the first instance is past its expiry
+	clock.Advance(45 * time.Minute)
+	reaped, err = engine.ReapExpiredInstances(clock.Now())
+	if err != nil {
+		t.Fatalf("ReapExpiredInstances should not return error: %v", err)
+	}
+
+	if reaped != 1 {
+		t.Errorf("Expected 1 instance reaped, got %d", reaped)
+	}
+
+	if _, err := engine.GetWorkflowInstance(first); err == nil {
+		t.Error("Expired instance should be deleted")
+	}
+
+	if _, err := engine.GetWorkflowInstance(second); err != nil {
+		t.Errorf("Non-expired instance should remain: %v", err)
+	}
+
+	// A definition TTL overrides the engine default
+	definition := newForkDefinition(0, 0)
+	config := definition.GetConfiguration()
+	config.InstanceTTL = time.Minute
+	third := runToCompletion(definition.UpdateConfiguration(config))
+
+	clock.Advance(2 * time.Minute)
+	if reaped, _ := engine.ReapExpiredInstances(clock.Now()); reaped != 1 {
+		t.Errorf("Expected the short-lived instance to be reaped, got %d", reaped)
+	}
+
+	if _, err := engine.GetWorkflowInstance(third); err == nil {
+		t.Error("Instance with a definition TTL should be deleted")
+	}
+}
+
+func TestWorkflowRuntimeEngineBulkOperationsByFilter(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+
+	forkDefinition := newForkDefinition(0, 0)
+	otherDefinition := newForkDefinition(0, 0)
+	otherDefinition.ID = "other-workflow"
+
+	start := func(definition layer1.WorkflowDefinition, labels map[string]string) WorkflowInstanceID {
+		context := layer0.NewContext("bulk-context", layer0.ContextScopeWorkflow, "Bulk Context")
+		instanceID, err := engine.StartWorkflowWithOptions(definition, context, StartOptions{Labels: labels})
+		if err != nil {
+			t.Fatalf("StartWorkflowWithOptions should not return error: %v", err)
+		}
+		return instanceID
+	}
+
+	forkEU := start(forkDefinition, map[string]string{"region": "eu"})
+	forkUS := start(forkDefinition, map[string]string{"region": "us"})
+	other := start(otherDefinition, map[string]string{"region": "eu"})
+
+	// Pause all running instances of one definition
+	filter := InstanceFilter{
+		DefinitionID: "fork-workflow",
+		Statuses:     []WorkflowInstanceStatus{WorkflowInstanceStatusRunning},
+	}
+	results := engine.PauseWorkflowsByFilter(filter, "incident", "operator")
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	for _, result := range results {
+		if !result.Succeeded() {
+			t.Errorf("Pause of %s should succeed: %s", result.InstanceID, result.Error)
+		}
+	}
+
+	for _, instanceID := range []WorkflowInstanceID{forkEU, forkUS} {
+		instance, _ := engine.GetWorkflowInstance(instanceID)
+		if instance.Status != WorkflowInstanceStatusPaused {
+			t.Errorf("Expected %s to be paused, got %s", instanceID, instance.Status)
+		}
+
+		if instance.Metadata["pause_reason"] != "incident" || instance.Metadata["paused_by"] != "operator" {
+			t.Errorf("Expected pause reason and initiator to be recorded on %s", instanceID)
+		}
+	}
+
+	if status, _ := engine.GetWorkflowStatus(other); status != WorkflowInstanceStatusRunning {
+		t.Errorf("Instance of another definition should keep running, got %s", status)
+	}
+
+	// Partial failures are reported per instance
+	results = engine.PauseWorkflowsByFilter(InstanceFilter{DefinitionID: "fork-workflow"}, "again", "operator")
+	for _, result := range results {
+		if result.Succeeded() {
+			t.Errorf("Pausing already paused instance %s should fail", result.InstanceID)
+		}
+	}
+
+	// A failed operation leaves the annotations of the earlier pause in place
+	if instance, _ := engine.GetWorkflowInstance(forkEU); instance.Metadata["pause_reason"] != "incident" {
+		t.Errorf("Expected a failed pause to restore the pause reason, got %v", instance.Metadata["pause_reason"])
+	}
+
+	// Cancel by label across definitions
+	results = engine.CancelWorkflowsByFilter(InstanceFilter{Labels: map[string]string{"region": "eu"}}, "region shutdown", "operator")
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	for _, instanceID := range []WorkflowInstanceID{forkEU, other} {
+		instance, _ := engine.GetWorkflowInstance(instanceID)
+		if instance.Status != WorkflowInstanceStatusCancelled {
+			t.Errorf("Expected %s to be cancelled, got %s", instanceID, instance.Status)
+		}
+
+		if instance.Metadata["cancel_reason"] != "region shutdown" {
+			t.Errorf("Expected cancel reason to be recorded on %s", instanceID)
+		}
+	}
+
+	if status, _ := engine.GetWorkflowStatus(forkUS); status != WorkflowInstanceStatusPaused {
+		t.Errorf("Instance with another label should stay paused, got %s", status)
+	}
+}
+
+type recordingAsyncWorkQueue struct {
+	jobs []layer1.AsyncJob
+}
+
+func (q *recordingAsyncWorkQueue) Enqueue(job layer1.AsyncJob) error {
+	q.jobs = append(q.jobs, job)
+	return nil
+}
+
+func TestWorkflowRuntimeEngineAsyncWork(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	queue := &recordingAsyncWorkQueue{}
+	if err := engine.RegisterExecutor(layer0.WorkTypeAsync, layer1.NewAsyncQueueExecutor(queue)); err != nil {
+		t.Fatalf("RegisterExecutor should not return error: %v", err)
+	}
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		return "recorded", nil
+	}))
+
+	if err := engine.RegisterActionWork("charge", layer0.NewWork("charge", layer0.WorkTypeAsync, "Charge")); err != nil {
+		t.Fatalf("RegisterActionWork should not return error: %v", err)
+	}
+
+	// start -[charge, record]-> charged -> end
+	definition := layer1.NewWorkflowDefinition("async-workflow", "1.0.0", "Async Workflow")
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	stateMachine.AddState(layer0.NewState("charged", layer0.StateTypeIntermediate, "Charged"))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	stateMachine.AddTransition(layer0.NewTransition("start-to-charged", layer0.TransitionTypeAutomatic, "start", "charged", "Start to Charged").
+		AddAction("charge").
+		AddAction("record"))
+	stateMachine.AddTransition(layer0.NewTransition("charged-to-end", layer0.TransitionTypeAutomatic, "charged", "end", "Charged to End"))
+
+	definition = definition.SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("end").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	instanceID, err := engine.StartWorkflow(definition, layer0.NewContext("async-context", layer0.ContextScopeWorkflow, "Async Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	// The instance waits on the enqueued work without leaving its state
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.Status != WorkflowInstanceStatusWaiting {
+		t.Fatalf("Expected waiting status, got %s", instance.Status)
+	}
+
+	if instance.CurrentStateID != "start" {
+		t.Errorf("Expected instance to remain in start, got %s", instance.CurrentStateID)
+	}
+
+	if len(queue.jobs) != 1 || queue.jobs[0].InstanceID != string(instanceID) || queue.jobs[0].WorkID != "charge" {
+		t.Fatalf("Expected charge job for %s to be enqueued, got %+v", instanceID, queue.jobs)
+	}
+
+	// The pending work is persisted
+	pending, err := engine.persistenceStore.GetWork(instanceID, "charge")
+	if err != nil {
+		t.Fatalf("Pending work should be persisted: %v", err)
+	}
+
+	if pending.GetStatus() != layer0.WorkStatusPending {
+		t.Errorf("Expected persisted work to be pending, got %s", pending.GetStatus())
+	}
+
+	if err := engine.CompleteAsyncWork(instanceID, "unknown", AsyncWorkResult{}); err == nil {
+		t.Error("Completing unknown work should return error")
+	}
+
+	// Completing the work resumes the transition and the workflow
+	if err := engine.CompleteAsyncWork(instanceID, "charge", AsyncWorkResult{Output: "receipt-1"}); err != nil {
+		t.Fatalf("CompleteAsyncWork should not return error: %v", err)
+	}
+
+	instance, _ = engine.GetWorkflowInstance(instanceID)
+	if instance.Status != WorkflowInstanceStatusCompleted {
+		t.Errorf("Expected completed status, got %s", instance.Status)
+	}
+
+	if output, _ := instance.Context.Get("work_charge_output"); output != "receipt-1" {
+		t.Errorf("Expected async output in context, got %v", output)
+	}
+
+	if output, _ := instance.Context.Get("work_record_output"); output != "recorded" {
+		t.Errorf("Expected remaining action to run after completion, got %v", output)
+	}
+
+	completed, _ := engine.persistenceStore.GetWork(instanceID, "charge")
+	if completed.GetStatus() != layer0.WorkStatusCompleted {
+		t.Errorf("Expected persisted work to be completed, got %s", completed.GetStatus())
+	}
+
+	if err := engine.CompleteAsyncWork(instanceID, "charge", AsyncWorkResult{}); err == nil {
+		t.Error("Completing work of a finished instance should return error")
+	}
+}
+
+type correlationRecordingExecutor struct {
+	correlationIDs []string
+}
+
+func (e *correlationRecordingExecutor) Execute(work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	return e.ExecuteWithContext(context.Background(), work, workContext)
+}
+
+func (e *correlationRecordingExecutor) ExecuteWithContext(ctx context.Context, work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	e.correlationIDs = append(e.correlationIDs, layer1.CorrelationIDFromContext(ctx))
+	return nil, nil
+}
+
+func (e *correlationRecordingExecutor) CanExecute(workType layer0.WorkType) bool {
+	return workType == layer0.WorkTypeTask
+}
+
+func (e *correlationRecordingExecutor) GetSupportedTypes() []layer0.WorkType {
+	return []layer0.WorkType{layer0.WorkTypeTask}
+}
+
+func TestWorkflowRuntimeEngineCorrelationID(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	executor := &correlationRecordingExecutor{}
+	engine.RegisterExecutor(layer0.WorkTypeTask, executor)
+	queue := &recordingAsyncWorkQueue{}
+	engine.RegisterExecutor(layer0.WorkTypeAsync, layer1.NewAsyncQueueExecutor(queue))
+	engine.RegisterActionWork("notify", layer0.NewWork("notify", layer0.WorkTypeAsync, "Notify"))
+
+	definition := layer1.NewWorkflowDefinition("correlated-workflow", "1.0.0", "Correlated Workflow")
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	stateMachine.AddTransition(layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("process").
+		AddAction("notify"))
+
+	definition = definition.SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("end").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	instanceID, err := engine.StartWorkflowWithOptions(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"), StartOptions{CorrelationID: "req-42"})
+	if err != nil {
+		t.Fatalf("StartWorkflowWithOptions should not return error: %v", err)
+	}
+
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.CorrelationID != "req-42" {
+		t.Errorf("Expected correlation ID req-42 on instance, got %s", instance.CorrelationID)
+	}
+
+	// The executor receives the correlation ID via context
+	if len(executor.correlationIDs) != 1 || executor.correlationIDs[0] != "req-42" {
+		t.Errorf("Expected executor to receive req-42, got %v", executor.correlationIDs)
+	}
+
+	// The outbound job carries the correlation ID
+	if len(queue.jobs) != 1 || queue.jobs[0].CorrelationID != "req-42" {
+		t.Errorf("Expected enqueued job to carry req-42, got %+v", queue.jobs)
+	}
+
+	if err := engine.CompleteAsyncWork(instanceID, "notify", AsyncWorkResult{}); err != nil {
+		t.Fatalf("CompleteAsyncWork should not return error: %v", err)
+	}
+
+	// Execution history records the correlation ID
+	history := engine.GetExecutionHistory(instanceID)
+	if len(history) != 1 {
+		t.Fatalf("Expected 1 history record, got %d", len(history))
+	}
+	for _, record := range history {
+		if record.CorrelationID != "req-42" {
+			t.Errorf("Expected history record to carry req-42, got %s", record.CorrelationID)
+		}
+	}
+
+	// A correlation ID is generated when none is supplied
+	generatedID, err := engine.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	generated, _ := engine.GetWorkflowInstance(generatedID)
+	if generated.CorrelationID == "" || generated.CorrelationID == "req-42" {
+		t.Errorf("Expected a new correlation ID to be generated, got %q", generated.CorrelationID)
+	}
+}
+
+func TestWorkflowRuntimeEngineTransitionAtomicity(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	var executed []layer0.WorkID
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		executed = append(executed, w.GetID())
+		if w.GetID() == "second" {
+			return nil, errors.New("second action failed")
+		}
+		return "output", nil
+	}))
+
+	definition := layer1.NewWorkflowDefinition("atomic-workflow", "1.0.0", "Atomic Workflow")
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	stateMachine.AddTransition(layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("first").
+		AddAction("second").
+		AddAction("third"))
+
+	definition = definition.SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("end").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	instanceID, err := engine.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	engine.ExecuteStep(instanceID)
+
+	if len(executed) != 2 || executed[0] != "first" || executed[1] != "second" {
+		t.Errorf("Expected execution to stop after the failing action, got %v", executed)
+	}
+
+	// Neither the active nor the persisted instance sees the first action's output
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.Context.Has("work_first_output") {
+		t.Error("Context should not contain output of the first action after a later action failed")
+	}
+
+	if instance.CurrentStateID != "start" {
+		t.Errorf("Expected instance to remain in start, got %s", instance.CurrentStateID)
+	}
+
+	persisted, _ := engine.persistenceStore.GetWorkflowInstance(instanceID)
+	if persisted.Context.Has("work_first_output") {
+		t.Error("Persisted context should not contain output of the first action")
+	}
+}
+
+func newParallelDefinition(transition layer0.Transition) layer1.WorkflowDefinition {
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	stateMachine.AddTransition(transition)
+
+	return layer1.NewWorkflowDefinition("parallel-workflow", "1.0.0", "Parallel Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("end").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+}
+
+func TestWorkflowRuntimeEngineParallelActions(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+
+	// Each action waits until all three are running, which only succeeds in parallel
+	var started sync.WaitGroup
+	started.Add(3)
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		started.Done()
+		started.Wait()
+		return string(w.GetID()) + "-done", nil
+	}))
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("fetch-a").
+		AddAction("fetch-b").
+		AddAction("fetch-c").
+		SetExecutionMode(layer0.ActionExecutionParallel)
+
+	instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- engine.ExecuteWorkflow(instanceID) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Parallel actions did not run concurrently")
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.Status != WorkflowInstanceStatusCompleted {
+		t.Errorf("Expected completed status, got %s", instance.Status)
+	}
+
+	for _, actionID := range []string{"fetch-a", "fetch-b", "fetch-c"} {
+		if output, _ := instance.Context.Get(fmt.Sprintf("work_%s_output", actionID)); output != actionID+"-done" {
+			t.Errorf("Expected output of %s in context, got %v", actionID, output)
+		}
+	}
+}
+
+func TestWorkflowRuntimeEngineParallelActionConflicts(t *testing.T) {
+	run := func(strategy layer0.MergeConflictStrategy) (*WorkflowInstance, []TransitionRecord) {
+		engine := NewWorkflowRuntimeEngine()
+		engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+			return string(w.GetID()), nil
+		}))
+
+		// Both actions write their output to the same context key
+		for _, actionID := range []string{"primary", "secondary"} {
+			work := layer0.NewWork(layer0.WorkID(actionID), layer0.WorkTypeTask, actionID)
+			work.Metadata.Properties[layer1.WorkPropertyOutputKey] = "region"
+			engine.RegisterActionWork(actionID, work)
+		}
+
+		transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+			AddAction("primary").
+			AddAction("secondary").
+			SetExecutionMode(layer0.ActionExecutionParallel).
+			SetConflictStrategy(strategy)
+
+		instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+		if err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+		engine.ExecuteStep(instanceID)
+
+		instance, _ := engine.GetWorkflowInstance(instanceID)
+		return instance, engine.GetExecutionHistory(instanceID)
+	}
+
+	// Overwrite lets the later action win
+	instance, _ := run(layer0.MergeConflictOverwrite)
+	if region, _ := instance.Context.Get("region"); region != "secondary" {
+		t.Errorf("Expected overwrite to keep secondary, got %v", region)
+	}
+
+	// Keep-existing keeps the earlier action's output
+	instance, _ = run(layer0.MergeConflictKeepExisting)
+	if region, _ := instance.Context.Get("region"); region != "primary" {
+		t.Errorf("Expected keep-existing to keep primary, got %v", region)
+	}
+
+	// Error fails the transition without touching the context
+	instance, history := run(layer0.MergeConflictError)
+	if instance.CurrentStateID != "start" || instance.Context.Has("region") {
+		t.Error("Conflicting outputs should fail the transition without committing any output")
+	}
+
+	if len(history) != 1 || !strings.Contains(history[0].Error, "merge conflict on key region") {
+		t.Errorf("Expected the conflict to be recorded in history, got %+v", history)
+	}
+}
+
+func TestWorkflowRuntimeEngineParallelActionFailures(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		if w.GetID() == "ok" {
+			return "fine", nil
+		}
+		return nil, fmt.Errorf("%s broke", w.GetID())
+	}))
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("bad-a").
+		AddAction("ok").
+		AddAction("bad-b").
+		SetExecutionMode(layer0.ActionExecutionParallel)
+
+	instanceID, _ := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	engine.ExecuteStep(instanceID)
+
+	history := engine.GetExecutionHistory(instanceID)
+	if len(history) != 1 {
+		t.Fatalf("Expected 1 history record, got %d", len(history))
+	}
+
+	for _, fragment := range []string{"2 of 3 parallel actions failed", "bad-a broke", "bad-b broke"} {
+		if !strings.Contains(history[0].Error, fragment) {
+			t.Errorf("Expected aggregated error to contain %q, got %s", fragment, history[0].Error)
+		}
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.Context.Has("work_ok_output") {
+		t.Error("Successful parallel output should be discarded when another action fails")
+	}
+}
+
+type unreachableStore struct {
+	*InMemoryStatePersistenceStore
+}
+
+func (store unreachableStore) GetStats() (map[string]interface{}, error) {
+	return nil, errors.New("connection refused")
+}
+
+type checkedExecutor struct {
+	*layer1.MockWorkExecutor
+	err error
+}
+
+func (executor checkedExecutor) HealthCheck() error {
+	return executor.err
+}
+
+func TestWorkflowRuntimeEngineHealth(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	engine.RegisterExecutor(layer0.WorkTypeTask, checkedExecutor{MockWorkExecutor: layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, nil)})
+
+	report := engine.Health()
+	if report.Status != HealthStatusHealthy || !report.IsReady() {
+		t.Errorf("Expected healthy report, got %+v", report)
+	}
+
+	if _, ok := report.Subsystem("executor:task"); !ok {
+		t.Error("Expected health-checked executor to be reported")
+	}
+
+	// A failing registered check degrades the engine
+	if err := engine.RegisterHealthCheck("queue", func() error { return errors.New("queue lagging") }); err != nil {
+		t.Fatalf("RegisterHealthCheck should not return error: %v", err)
+	}
+
+	report = engine.Health()
+	if report.Status != HealthStatusDegraded || !report.IsReady() {
+		t.Errorf("Expected degraded but ready report, got %s", report.Status)
+	}
+
+	// An unreachable store makes the engine unhealthy and is identified
+	engine.SetPersistenceStore(unreachableStore{NewInMemoryStatePersistenceStore()})
+	report = engine.Health()
+	if report.Status != HealthStatusUnhealthy || report.IsReady() {
+		t.Errorf("Expected unhealthy report, got %s", report.Status)
+	}
+
+	store, ok := report.Subsystem("persistence_store")
+	if !ok || store.Status != HealthStatusUnhealthy || store.Message != "connection refused" {
+		t.Errorf("Expected persistence store to be reported as failing, got %+v", store)
+	}
+
+	queue, _ := report.Subsystem("queue")
+	if queue.Status != HealthStatusDegraded {
+		t.Errorf("Expected queue check to be degraded, got %s", queue.Status)
+	}
+
+	if executor, _ := report.Subsystem("executor:task"); executor.Status != HealthStatusHealthy {
+		t.Errorf("Expected executor to stay healthy, got %s", executor.Status)
+	}
+}
+
+func TestWorkflowRuntimeEngineStateTimeout(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	clock := NewManualClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	engine.SetClock(clock)
+
+	// start -> review -(approved)-> end, with review timing out into an error state
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	stateMachine.AddState(layer0.NewState("review", layer0.StateTypeIntermediate, "Manual Review").SetTimeout(24 * time.Hour))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	stateMachine.AddState(layer0.NewState("review-expired", layer0.StateTypeError, "Review Expired"))
+	stateMachine.AddTransition(layer0.NewTransition("start-to-review", layer0.TransitionTypeAutomatic, "start", "review", "Start to Review"))
+	stateMachine.AddTransition(layer0.NewTransition("review-to-end", layer0.TransitionTypeConditional, "review", "end", "Review to End").
+		AddCondition("approved"))
+	stateMachine.AddTransition(layer0.NewTransition("review-timeout", layer0.TransitionTypeTimeout, "review", "review-expired", "Review Timeout"))
+
+	definition := layer1.NewWorkflowDefinition("review-workflow", "1.0.0", "Review Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("end").
+		AddErrorStateID("review-expired").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	initialContext := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").Set("approved", false)
+	instanceID, err := engine.StartWorkflow(definition, initialContext)
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	// The instance waits in review; the timeout transition is not taken normally
+	engine.ExecuteWorkflow(instanceID)
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.CurrentStateID != "review" {
+		t.Fatalf("Expected instance to wait in review, got %s", instance.CurrentStateID)
+	}
+
+	// The deadline is persisted for restart recovery
+	persisted, _ := engine.persistenceStore.GetWorkflowInstance(instanceID)
+	expectedDeadline := clock.Now().Add(24 * time.Hour)
+	if persisted.StateDeadline == nil || !persisted.StateDeadline.Equal(expectedDeadline) {
+		t.Fatalf("Expected persisted deadline %v, got %v", expectedDeadline, persisted.StateDeadline)
+	}
+
+	// Nothing times out before the deadline
+	clock.Advance(23 * time.Hour)
+	if timedOut, err := engine.ProcessStateTimeouts(clock.Now()); err != nil || timedOut != 0 {
+		t.Fatalf("Expected no timeouts before the deadline, got %d (%v)", timedOut, err)
+	}
+
+	clock.Advance(time.Hour)
+	timedOut, err := engine.ProcessStateTimeouts(clock.Now())
+	if err != nil {
+		t.Fatalf("ProcessStateTimeouts should not return error: %v", err)
+	}
+
+	if timedOut != 1 {
+		t.Errorf("Expected 1 timed out instance, got %d", timedOut)
+	}
+
+	instance, _ = engine.GetWorkflowInstance(instanceID)
+	if instance.CurrentStateID != "review-expired" {
+		t.Errorf("Expected instance to move to the error state, got %s", instance.CurrentStateID)
+	}
+
+	if instance.Status != WorkflowInstanceStatusFailed {
+		t.Errorf("Expected failed status, got %s", instance.Status)
+	}
+
+	if instance.Error != "state review timed out" {
+		t.Errorf("Expected timeout error, got %q", instance.Error)
+	}
+
+	// Terminal instances are not timed out again
+	if timedOut, _ := engine.ProcessStateTimeouts(clock.Now().Add(48 * time.Hour)); timedOut != 0 {
+		t.Errorf("Expected no further timeouts, got %d", timedOut)
+	}
+}
+
+func TestMetricsRegistryWriteMetrics(t *testing.T) {
+	registry := NewMetricsRegistry()
+	registry.RegisterCounter("jobs_total", "Jobs processed.")
+	registry.RegisterHistogram("job_seconds", "Job duration.", []float64{1, 0.1})
+
+	registry.IncCounter("jobs_total", map[string]string{"queue": "a\"b"})
+	registry.AddCounter("jobs_total", map[string]string{"queue": "a\"b"}, 2)
+	registry.ObserveHistogram("job_seconds", nil, 0.05)
+	registry.ObserveHistogram("job_seconds", nil, 0.5)
+	registry.ObserveHistogram("job_seconds", nil, 3)
+
+	if err := registry.IncCounter("unknown_total", nil); err == nil {
+		t.Error("Incrementing an unregistered metric should return error")
+	}
+
+	if err := registry.AddCounter("jobs_total", nil, -1); err == nil {
+		t.Error("Decreasing a counter should return error")
+	}
+
+	if err := registry.RegisterHistogram("jobs_total", "", DefaultHistogramBuckets); err == nil {
+		t.Error("Registering a name with another type should return error")
+	}
+
+	var buffer strings.Builder
+	if err := registry.WriteMetrics(&buffer); err != nil {
+		t.Fatalf("WriteMetrics should not return error: %v", err)
+	}
+
+	expected := `# HELP job_seconds Job duration.
+# TYPE job_seconds histogram
+job_seconds_bucket{le="0.1"} 1
+job_seconds_bucket{le="1"} 2
+job_seconds_bucket{le="+Inf"} 3
+job_seconds_sum 3.55
+job_seconds_count 3
+# HELP jobs_total Jobs processed.
+# TYPE jobs_total counter
+jobs_total{queue="a\"b"} 3
+`
+	if buffer.String() != expected {
+		t.Errorf("Unexpected metrics output:\n%s", buffer.String())
+	}
+}
+
+func TestWorkflowRuntimeEngineMetrics(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	registry := NewMetricsRegistry()
+	if err := engine.SetMetricsRegistry(registry); err != nil {
+		t.Fatalf("SetMetricsRegistry should not return error: %v", err)
+	}
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, nil))
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("prepare").
+		AddAction("process")
+
+	for i := 0; i < 2; i++ {
+		instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+		if err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+
+		if err := engine.ExecuteWorkflow(instanceID); err != nil {
+			t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+		}
+	}
+
+	var buffer strings.Builder
+	if err := engine.metrics.WriteMetrics(&buffer); err != nil {
+		t.Fatalf("WriteMetrics should not return error: %v", err)
+	}
+	output := buffer.String()
+
+	expected := []string{
+		`# TYPE workflow_instances_started_total counter`,
+		`workflow_instances_started_total{definition_id="parallel-workflow"} 2`,
+		`workflow_instances_finished_total{definition_id="parallel-workflow",status="completed"} 2`,
+		`workflow_transitions_total{definition_id="parallel-workflow",outcome="completed",transition_id="start-to-end"} 2`,
+		`workflow_work_executions_total{definition_id="parallel-workflow",status="completed",work_id="prepare"} 2`,
+		`workflow_work_executions_total{definition_id="parallel-workflow",status="completed",work_id="process"} 2`,
+		`workflow_transition_duration_seconds_count{definition_id="parallel-workflow"} 2`,
+		`workflow_work_duration_seconds_count{definition_id="parallel-workflow"} 4`,
+		`# TYPE workflow_work_duration_seconds histogram`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected metrics output to contain %q, got:\n%s", line, output)
+		}
+	}
+}
+
+func TestWorkflowRuntimeEngineRetryBudget(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+
+	// Every action fails on its first attempt and succeeds afterwards
+	attempts := map[layer0.WorkID]int{}
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		attempts[w.GetID()]++
+		if attempts[w.GetID()] == 1 {
+			return nil, errors.New("downstream unavailable")
+		}
+		return "ok", nil
+	}))
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End")
+	for _, actionID := range []string{"a", "b", "c", "d"} {
+		work := layer0.NewWork(layer0.WorkID(actionID), layer0.WorkTypeTask, actionID)
+		work.Configuration.RetryCount = 3
+		work.Configuration.RetryDelaySeconds = 0
+		engine.RegisterActionWork(actionID, work)
+		transition = transition.AddAction(actionID)
+	}
+
+	definition := newParallelDefinition(transition)
+	config := definition.GetConfiguration()
+	config.RetryPolicy.InstanceBudget = 2
+	definition = definition.UpdateConfiguration(config)
+
+	instanceID, err := engine.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	err = engine.ExecuteWorkflow(instanceID)
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Fatalf("Expected retry budget exhausted error, got %v", err)
+	}
+
+	// The first two failures were retried, the third was not, and the fourth action never ran
+	expected := map[layer0.WorkID]int{"a": 2, "b": 2, "c": 1, "d": 0}
+	for workID, count := range expected {
+		if attempts[workID] != count {
+			t.Errorf("Expected %d attempts of %s, got %d", count, workID, attempts[workID])
+		}
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.Status != WorkflowInstanceStatusFailed {
+		t.Errorf("Expected instance to fail fast, got %s", instance.Status)
+	}
+
+	if instance.RetriesUsed != 2 || instance.RetryBudget != 2 {
+		t.Errorf("Expected 2 of 2 retries used, got %d of %d", instance.RetriesUsed, instance.RetryBudget)
+	}
+}
+
+type blockingExecutor struct {
+	started   chan struct{}
+	cancelled chan struct{}
+}
+
+func (e *blockingExecutor) Execute(work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	return e.ExecuteWithContext(context.Background(), work, workContext)
+}
+
+func (e *blockingExecutor) ExecuteWithContext(ctx context.Context, work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	e.started <- struct{}{}
+	<-ctx.Done()
+	e.cancelled <- struct{}{}
+	return nil, ctx.Err()
+}
+
+func (e *blockingExecutor) CanExecute(workType layer0.WorkType) bool {
+	return workType == layer0.WorkTypeTask
+}
+
+func (e *blockingExecutor) GetSupportedTypes() []layer0.WorkType {
+	return []layer0.WorkType{layer0.WorkTypeTask}
+}
+
+func TestWorkflowRuntimeEngineCancelCascades(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	executor := &blockingExecutor{started: make(chan struct{}, 2), cancelled: make(chan struct{}, 2)}
+	engine.RegisterExecutor(layer0.WorkTypeTask, executor)
+
+	parentTransition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("branch-a").
+		AddAction("branch-b").
+		SetExecutionMode(layer0.ActionExecutionParallel)
+	parentID, err := engine.StartWorkflow(newParallelDefinition(parentTransition), layer0.NewContext("parent", layer0.ContextScopeWorkflow, "Parent"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	childDefinition := newForkDefinition(0, 0)
+	childID, err := engine.StartWorkflowWithOptions(childDefinition, layer0.NewContext("child", layer0.ContextScopeWorkflow, "Child"), StartOptions{ParentInstanceID: parentID})
+	if err != nil {
+		t.Fatalf("StartWorkflowWithOptions should not return error: %v", err)
+	}
+
+	grandchildID, err := engine.StartWorkflowWithOptions(childDefinition, layer0.NewContext("grandchild", layer0.ContextScopeWorkflow, "Grandchild"), StartOptions{ParentInstanceID: childID})
+	if err != nil {
+		t.Fatalf("StartWorkflowWithOptions should not return error: %v", err)
+	}
+
+	unrelatedID, _ := engine.StartWorkflow(childDefinition, layer0.NewContext("unrelated", layer0.ContextScopeWorkflow, "Unrelated"))
+
+	if children := engine.GetChildInstances(parentID); len(children) != 1 || children[0] != childID {
+		t.Errorf("Expected %s to be the only child, got %v", childID, children)
+	}
+
+	// Run the parent's parallel branches until both are in flight
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		engine.runTransition(parentID, parentTransition, 0, nil)
+	}()
+	<-executor.started
+	<-executor.started
+
+	if err := engine.CancelWorkflow(parentID); err != nil {
+		t.Fatalf("CancelWorkflow should not return error: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-executor.cancelled:
+		case <-time.After(2 * time.Second):
+			t.Fatal("In-flight branch work was not cancelled")
+		}
+	}
+	<-done
+
+	for _, instanceID := range []WorkflowInstanceID{parentID, childID, grandchildID} {
+		if status, _ := engine.GetWorkflowStatus(instanceID); status != WorkflowInstanceStatusCancelled {
+			t.Errorf("Expected %s to be cancelled, got %s", instanceID, status)
+		}
+	}
+
+	if status, _ := engine.GetWorkflowStatus(unrelatedID); status != WorkflowInstanceStatusRunning {
+		t.Errorf("Unrelated instance should keep running, got %s", status)
+	}
+}
+
+type slowTransitionEvaluator struct {
+	*DefaultTransitionEvaluator
+	slow layer0.TransitionID
+}
+
+func (evaluator slowTransitionEvaluator) CanTransition(transition layer0.Transition, context *layer0.Context) (bool, error) {
+	if transition.GetID() == evaluator.slow {
+		time.Sleep(5 * time.Second)
+	}
+	return evaluator.DefaultTransitionEvaluator.CanTransition(transition, context)
+}
+
+func (evaluator slowTransitionEvaluator) CanTransitionWithContext(ctx context.Context, transition layer0.Transition, context *layer0.Context) (bool, error) {
+	if transition.GetID() == evaluator.slow {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+	return evaluator.DefaultTransitionEvaluator.CanTransition(transition, context)
+}
+
+func TestWorkflowRuntimeEngineConditionTimeout(t *testing.T) {
+	run := func(action ConditionTimeoutAction) (*WorkflowInstance, time.Duration, error) {
+		engine := NewWorkflowRuntimeEngine()
+		engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, nil))
+
+		// The left branch is tried first but its conditions hang
+		engine.SetTransitionEvaluator(slowTransitionEvaluator{DefaultTransitionEvaluator: NewDefaultTransitionEvaluator(), slow: "start-to-left"})
+		if err := engine.SetConditionTimeout(50*time.Millisecond, action); err != nil {
+			t.Fatalf("SetConditionTimeout should not return error: %v", err)
+		}
+
+		instanceID, err := engine.StartWorkflow(newForkDefinition(0, 0), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+		if err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+
+		started := time.Now()
+		err = engine.ExecuteStep(instanceID)
+		elapsed := time.Since(started)
+
+		instance, _ := engine.GetWorkflowInstance(instanceID)
+		return instance, elapsed, err
+	}
+
+	// Skipping treats the hanging transition as unsatisfied and takes the other branch
+	instance, elapsed, err := run(ConditionTimeoutSkip)
+	if err != nil {
+		t.Fatalf("ExecuteStep should not return error: %v", err)
+	}
+
+	if elapsed > time.Second {
+		t.Errorf("Step should not wait for the hanging condition, took %v", elapsed)
+	}
+
+	if instance.CurrentStateID != "right" {
+		t.Errorf("Expected timed out transition to be skipped, got state %s", instance.CurrentStateID)
+	}
+
+	// Failing routes the instance to failure instead
+	instance, _, err = run(ConditionTimeoutFail)
+	if !errors.Is(err, ErrConditionTimeout) {
+		t.Errorf("Expected condition timeout error, got %v", err)
+	}
+
+	if instance.Status != WorkflowInstanceStatusFailed {
+		t.Errorf("Expected failed status, got %s", instance.Status)
+	}
+}
+
+func TestWorkflowRuntimeEngineWorkPersistence(t *testing.T) {
+	run := func(persist bool) (WorkflowInstanceID, StatePersistenceStore) {
+		engine := NewWorkflowRuntimeEngine()
+		engine.SetWorkPersistence(persist)
+		engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+			return "output of " + string(w.GetID()), nil
+		}))
+
+		transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+			AddAction("reserve").
+			AddAction("charge")
+
+		instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+		if err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+
+		if err := engine.ExecuteWorkflow(instanceID); err != nil {
+			t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+		}
+
+		return instanceID, engine.persistenceStore
+	}
+
+	instanceID, store := run(true)
+
+	// The store holds a record per executed action
+	workItems, err := store.ListWork(instanceID)
+	if err != nil {
+		t.Fatalf("ListWork should not return error: %v", err)
+	}
+
+	if len(workItems) != 2 {
+		t.Fatalf("Expected 2 work records, got %d", len(workItems))
+	}
+
+	for _, work := range workItems {
+		if work.GetStatus() != layer0.WorkStatusCompleted {
+			t.Errorf("Expected work %s to be completed, got %s", work.GetID(), work.GetStatus())
+		}
+
+		if work.GetOutput() != "output of "+string(work.GetID()) {
+			t.Errorf("Unexpected output for work %s: %v", work.GetID(), work.GetOutput())
+		}
+
+		if work.GetMetadata().StartedAt == nil || work.GetMetadata().CompletedAt == nil {
+			t.Errorf("Expected work %s to record its execution times", work.GetID())
+		}
+
+		if work.GetMetadata().Properties[workPropertyDuration] == "" {
+			t.Errorf("Expected work %s to record its duration", work.GetID())
+		}
+	}
+
+	stats, _ := store.GetStats()
+	if stats["total_work"] != 2 {
+		t.Errorf("Expected stats to count 2 work records, got %v", stats["total_work"])
+	}
+
+	// Persistence can be switched off
+	instanceID, store = run(false)
+	if workItems, _ := store.ListWork(instanceID); len(workItems) != 0 {
+		t.Errorf("Expected no work records with persistence disabled, got %d", len(workItems))
+	}
+}
+
+func TestWorkflowRuntimeEngineBlockedWork(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+
+	// Approval blocks until a reviewer signals a decision
+	engine.RegisterExecutor(layer0.WorkTypeHuman, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeHuman}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		return nil, layer1.ErrWorkBlocked
+	}))
+	engine.RegisterActionWork("approve", layer0.NewWork("approve", layer0.WorkTypeHuman, "Approve"))
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("approve")
+
+	instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error for blocked work: %v", err)
+	}
+
+	// The instance suspends instead of failing
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.Status != WorkflowInstanceStatusWaiting || instance.CurrentStateID != "start" {
+		t.Fatalf("Expected instance waiting in start, got %s in %s", instance.Status, instance.CurrentStateID)
+	}
+
+	blocked, err := engine.persistenceStore.GetWork(instanceID, "approve")
+	if err != nil {
+		t.Fatalf("Blocked work should be persisted: %v", err)
+	}
+
+	if blocked.GetStatus() != layer0.WorkStatusBlocked {
+		t.Errorf("Expected persisted work to be blocked, got %s", blocked.GetStatus())
+	}
+
+	// The completion signal resumes the workflow
+	if err := engine.CompleteAsyncWork(instanceID, "approve", AsyncWorkResult{Output: "approved"}); err != nil {
+		t.Fatalf("CompleteAsyncWork should not return error: %v", err)
+	}
+
+	instance, _ = engine.GetWorkflowInstance(instanceID)
+	if instance.Status != WorkflowInstanceStatusCompleted {
+		t.Errorf("Expected completed status, got %s", instance.Status)
+	}
+
+	if output, _ := instance.Context.Get("work_approve_output"); output != "approved" {
+		t.Errorf("Expected approval output in context, got %v", output)
+	}
+}
+
+func TestWorkflowRuntimeEngineWatchContextKey(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		return 50, nil
+	}))
+
+	work := layer0.NewWork("report", layer0.WorkTypeTask, "Report Progress")
+	work.Metadata.Properties[layer1.WorkPropertyOutputKey] = "progress"
+	engine.RegisterActionWork("report", work)
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("report")
+
+	instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").Set("progress", 0))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	if _, err := engine.WatchContextKey(instanceID, "progress", nil); err == nil {
+		t.Error("WatchContextKey should return error for nil handler")
+	}
+
+	type change struct {
+		oldValue, newValue interface{}
+	}
+	changes := make(chan change, 1)
+	if _, err := engine.WatchContextKey(instanceID, "progress", func(id WorkflowInstanceID, key string, oldValue, newValue interface{}) {
+		changes <- change{oldValue: oldValue, newValue: newValue}
+	}); err != nil {
+		t.Fatalf("WatchContextKey should not return error: %v", err)
+	}
+
+	unrelated := make(chan struct{}, 1)
+	unwatch, _ := engine.WatchContextKey(instanceID, "other", func(WorkflowInstanceID, string, interface{}, interface{}) {
+		unrelated <- struct{}{}
+	})
+	defer unwatch()
+
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	select {
+	case got := <-changes:
+		if got.oldValue != 0 || got.newValue != 50 {
+			t.Errorf("Expected progress change from 0 to 50, got %v to %v", got.oldValue, got.newValue)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected watch handler to fire for the written key")
+	}
+
+	select {
+	case <-unrelated:
+		t.Error("Handler for an unchanged key should not fire")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Finished instances cannot be watched
+	if _, err := engine.WatchContextKey(instanceID, "progress", func(WorkflowInstanceID, string, interface{}, interface{}) {}); err == nil {
+		t.Error("WatchContextKey should return error for a finished instance")
+	}
+}
+
+func TestWorkflowRuntimeEngineCommandLogRebuild(t *testing.T) {
+	newEngine := func() *WorkflowRuntimeEngine {
+		engine := NewWorkflowRuntimeEngine()
+		engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+			return "done", nil
+		}))
+		return engine
+	}
+
+	simple := newParallelDefinition(layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("record"))
+	definitions := map[layer1.WorkflowDefinitionID]layer1.WorkflowDefinition{simple.GetID(): simple}
+	resolve := func(id layer1.WorkflowDefinitionID, version layer1.WorkflowDefinitionVersion) (layer1.WorkflowDefinition, error) {
+		definition, exists := definitions[id]
+		if !exists {
+			return layer1.WorkflowDefinition{}, fmt.Errorf("definition %s not found", id)
+		}
+		return definition, nil
+	}
+
+	// Record a session touching several instances
+	log := NewCommandLog(NewInMemoryCommandSink())
+	engine := newEngine()
+	engine.SetCommandLog(log)
+
+	completed, _ := engine.StartWorkflow(simple, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").Set("order", "A-1"))
+	engine.PauseWorkflow(completed)
+	engine.ResumeWorkflow(completed)
+	if err := engine.ExecuteWorkflow(completed); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	paused, _ := engine.StartWorkflowWithOptions(simple, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"), StartOptions{Labels: map[string]string{"tenant": "acme"}})
+	engine.PauseWorkflow(paused)
+	if err := engine.PauseWorkflow(paused); err == nil {
+		t.Fatal("Pausing a paused instance should return error")
+	}
+
+	cancelled, _ := engine.StartWorkflow(simple, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	engine.CancelWorkflow(cancelled)
+
+	commands, _ := log.Commands()
+	var types []CommandType
+	for _, command := range commands {
+		types = append(types, command.Type)
+	}
+	expected := []CommandType{CommandStart, CommandPause, CommandResume, CommandExecute, CommandStart, CommandPause, CommandPause, CommandStart, CommandCancel}
+	if !reflect.DeepEqual(types, expected) {
+		t.Fatalf("Expected commands %v, got %v", expected, types)
+	}
+
+	if commands[6].Error == "" {
+		t.Error("Expected the failed pause to record its error")
+	}
+
+	// Replaying the log against a fresh engine reproduces the instances
+	rebuilt := newEngine()
+	if err := rebuilt.Rebuild(log, resolve); err != nil {
+		t.Fatalf("Rebuild should not return error: %v", err)
+	}
+
+	for _, instanceID := range []WorkflowInstanceID{completed, paused, cancelled} {
+		original, _ := engine.GetWorkflowInstance(instanceID)
+		replayed, err := rebuilt.GetWorkflowInstance(instanceID)
+		if err != nil {
+			t.Fatalf("Rebuilt engine should have instance %s: %v", instanceID, err)
+		}
+
+		if replayed.Status != original.Status || replayed.CurrentStateID != original.CurrentStateID {
+			t.Errorf("Instance %s: expected %s in %s, got %s in %s", instanceID, original.Status, original.CurrentStateID, replayed.Status, replayed.CurrentStateID)
+		}
+
+		if replayed.CorrelationID != original.CorrelationID || !reflect.DeepEqual(replayed.Labels, original.Labels) {
+			t.Errorf("Instance %s: expected start options to be replayed", instanceID)
+		}
+
+		if !reflect.DeepEqual(replayed.Context.Data, original.Context.Data) {
+			t.Errorf("Instance %s: expected context %v, got %v", instanceID, original.Context.Data, replayed.Context.Data)
+		}
+	}
+
+	if len(rebuilt.ListActiveWorkflows()) != len(engine.ListActiveWorkflows()) {
+		t.Errorf("Expected %d active instances after rebuild, got %d", len(engine.ListActiveWorkflows()), len(rebuilt.ListActiveWorkflows()))
+	}
+
+	// A replay that diverges from the recorded outcome is reported
+	if err := newEngine().Rebuild(log, func(layer1.WorkflowDefinitionID, layer1.WorkflowDefinitionVersion) (layer1.WorkflowDefinition, error) {
+		return layer1.WorkflowDefinition{}, errors.New("definition unavailable")
+	}); err == nil {
+		t.Error("Rebuild should return error when a start cannot be replayed")
+	}
+}
+
+func TestWorkflowRuntimeEngineSharedContext(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("shared:increment:runs").
+		AddAction("shared:get:last_region")
+	definition := newParallelDefinition(transition).
+		UpdateGlobalContext(layer0.NewContext("global", layer0.ContextScopeGlobal, "Global").Set("runs", 10))
+
+	run := func(initial *layer0.Context) *WorkflowInstance {
+		instanceID, err := engine.StartWorkflow(definition, initial)
+		if err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+
+		if err := engine.ExecuteWorkflow(instanceID); err != nil {
+			t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+		}
+
+		instance, _ := engine.GetWorkflowInstance(instanceID)
+		return instance
+	}
+
+	// The first instance increments the counter seeded from the global context
+	first := run(layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if runs, _ := first.Context.Get("runs"); runs != 11 {
+		t.Errorf("Expected first instance to see 11 runs, got %v", runs)
+	}
+
+	// A later instance sees the first instance's increment and values written through the store
+	engine.SharedContexts().Update(definition.GetID(), "last_region", func(interface{}, bool) (interface{}, error) {
+		return "eu", nil
+	})
+	second := run(layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if runs, _ := second.Context.Get("runs"); runs != 12 {
+		t.Errorf("Expected second instance to see 12 runs, got %v", runs)
+	}
+
+	if region, _ := second.Context.Get("last_region"); region != "eu" {
+		t.Errorf("Expected shared value to be copied into the instance context, got %v", region)
+	}
+
+	if runs, _ := engine.SharedContexts().Get(definition.GetID(), "runs"); runs != 12 {
+		t.Errorf("Expected shared counter of 12, got %v", runs)
+	}
+
+	// The definition's global context is left untouched
+	if runs, _ := definition.GetGlobalContext().Get("runs"); runs != 10 {
+		t.Errorf("Expected global context to keep its seed value, got %v", runs)
+	}
+
+	// Concurrent updates are serialized
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			engine.SharedContexts().Update("concurrent", "count", func(current interface{}, exists bool) (interface{}, error) {
+				if !exists {
+					return 1, nil
+				}
+				return current.(int) + 1, nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if count, _ := engine.SharedContexts().Get("concurrent", "count"); count != 50 {
+		t.Errorf("Expected 50 concurrent increments, got %v", count)
+	}
+}
+
+func TestWorkflowRuntimeEngineProgress(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+
+	// Create a linear workflow: s1 -> s2 -> s3 -> s4 -> s5
+	stateIDs := []layer0.StateID{"s1", "s2", "s3", "s4", "s5"}
+	stateMachine := layer1.NewStateMachineCore()
+	for index, stateID := range stateIDs {
+		stateType := layer0.StateTypeIntermediate
+		switch index {
+		case 0:
+			stateType = layer0.StateTypeInitial
+		case len(stateIDs) - 1:
+			stateType = layer0.StateTypeFinal
+		}
+		stateMachine.AddState(layer0.NewState(stateID, stateType, string(stateID)))
+		if index > 0 {
+			transitionID := layer0.TransitionID(fmt.Sprintf("%s-to-%s", stateIDs[index-1], stateID))
+			stateMachine.AddTransition(layer0.NewTransition(transitionID, layer0.TransitionTypeAutomatic, stateIDs[index-1], stateID, string(transitionID)))
+		}
+	}
+
+	definition := layer1.NewWorkflowDefinition("progress-workflow", "1.0.0", "Progress Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("s1").
+		AddFinalStateID("s5").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	instanceID, err := engine.StartWorkflow(definition, layer0.NewContext("progress-context", layer0.ContextScopeWorkflow, "Progress Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.EnableDebug(instanceID); err != nil {
+		t.Fatalf("EnableDebug should not return error: %v", err)
+	}
+
+	progress, stateID, err := engine.Progress(instanceID)
+	if err != nil {
+		t.Fatalf("Progress should not return error: %v", err)
+	}
+	if progress != 0 || stateID != "s1" {
+		t.Errorf("Expected progress 0 at s1, got %v at %s", progress, stateID)
+	}
+
+	// Each step advances progress by a quarter
+	for index := 1; index < len(stateIDs); index++ {
+		if _, err := engine.Step(instanceID); err != nil {
+			t.Fatalf("Step should not return error: %v", err)
+		}
+
+		next, stateID, err := engine.Progress(instanceID)
+		if err != nil {
+			t.Fatalf("Progress should not return error: %v", err)
+		}
+		if stateID != stateIDs[index] {
+			t.Errorf("Expected current state %s, got %s", stateIDs[index], stateID)
+		}
+		if next <= progress {
+			t.Errorf("Expected progress to increase past %v at %s, got %v", progress, stateID, next)
+		}
+		if expected := float64(index) / 4; next != expected {
+			t.Errorf("Expected progress %v at %s, got %v", expected, stateID, next)
+		}
+		progress = next
+	}
+}
+
+func TestWorkflowRuntimeEngineSecretResolution(t *testing.T) {
+	const token = "s3cr3t-token"
+
+	engine := NewWorkflowRuntimeEngine()
+	provider := NewMockSecretProvider(map[string]string{"api_token": token})
+	engine.SetSecretProvider(provider)
+
+	var seen []interface{}
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		seen = append(seen, w.GetConfiguration().Parameters["headers"])
+		if w.GetID() == "reject" {
+			return nil, fmt.Errorf("401 for %s", w.GetConfiguration().Parameters["headers"].(map[string]interface{})["Authorization"])
+		}
+		return "called", nil
+	}))
+
+	call := layer0.NewWork("call", layer0.WorkTypeTask, "Call")
+	call.Configuration.Parameters = map[string]interface{}{
+		"headers": map[string]interface{}{"Authorization": "Bearer ${secret:api_token}"},
+	}
+	if err := engine.RegisterActionWork("call", call); err != nil {
+		t.Fatalf("RegisterActionWork should not return error: %v", err)
+	}
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("call")
+	instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	// The executor saw the resolved secret
+	if len(seen) != 1 || seen[0].(map[string]interface{})["Authorization"] != "Bearer "+token {
+		t.Errorf("Expected the executor to see the resolved secret, got %v", seen)
+	}
+	if provider.Lookups() != 1 {
+		t.Errorf("Expected 1 secret lookup, got %d", provider.Lookups())
+	}
+
+	// Neither the context nor the stored work holds the resolved value
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	for _, key := range instance.Context.Keys() {
+		if value, _ := instance.Context.Get(key); strings.Contains(fmt.Sprint(value), token) {
+			t.Errorf("Context key %s should not hold the secret", key)
+		}
+	}
+
+	stored, err := engine.persistenceStore.GetWork(instanceID, "call")
+	if err != nil {
+		t.Fatalf("GetWork should not return error: %v", err)
+	}
+	if header := stored.GetConfiguration().Parameters["headers"].(map[string]interface{})["Authorization"]; header != "Bearer ${secret:api_token}" {
+		t.Errorf("Expected the stored work to keep the placeholder, got %v", header)
+	}
+	if registered := engine.actionWork["call"].GetConfiguration().Parameters["headers"].(map[string]interface{})["Authorization"]; registered != "Bearer ${secret:api_token}" {
+		t.Errorf("Expected the registered work to keep the placeholder, got %v", registered)
+	}
+
+	// Errors mentioning the secret are redacted
+	reject := call.Clone()
+	reject.ID = "reject"
+	engine.RegisterActionWork("reject", reject)
+	transition = layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("reject")
+	instanceID, err = engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	engine.ExecuteWorkflow(instanceID)
+
+	stored, err = engine.persistenceStore.GetWork(instanceID, "reject")
+	if err != nil {
+		t.Fatalf("GetWork should not return error: %v", err)
+	}
+	if strings.Contains(stored.GetError(), token) || !strings.Contains(stored.GetError(), redactedSecret) {
+		t.Errorf("Expected the stored error to be redacted, got %q", stored.GetError())
+	}
+	instance, _ = engine.GetWorkflowInstance(instanceID)
+	if strings.Contains(instance.Error, token) {
+		t.Errorf("Expected the instance error to be redacted, got %q", instance.Error)
+	}
+
+	// Unresolvable references fail the work without calling the executor
+	engine.SetSecretProvider(nil)
+	seen = nil
+	instanceID, _ = engine.StartWorkflow(newParallelDefinition(layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").AddAction("call")), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err := engine.ExecuteWorkflow(instanceID); err == nil {
+		t.Error("ExecuteWorkflow should return error when a secret cannot be resolved")
+	}
+	if len(seen) != 0 {
+		t.Error("The executor should not run when a secret cannot be resolved")
+	}
+}
+
+func TestWorkflowRuntimeEngineContextProjection(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	engine.SetContextProjection(true, "tenant")
+
+	observed := map[layer0.WorkID][]string{}
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		keys := c.Keys()
+		sort.Strings(keys)
+		observed[w.GetID()] = keys
+		return nil, nil
+	}))
+
+	charge := layer0.NewWork("charge", layer0.WorkTypeTask, "Charge")
+	charge.Metadata.Properties[layer1.WorkPropertyInputKeys] = "amount, currency"
+	engine.RegisterActionWork("charge", charge)
+
+	audit := layer0.NewWork("audit", layer0.WorkTypeTask, "Audit")
+	audit.Metadata.Properties[layer1.WorkPropertyFullContext] = "true"
+	engine.RegisterActionWork("audit", audit)
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("charge").
+		AddAction("audit")
+
+	initialContext := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").
+		Set("amount", 42).
+		Set("currency", "EUR").
+		Set("tenant", "acme").
+		Set("card_number", "4111111111111111")
+
+	instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), initialContext)
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	// The charge work sees its mapped keys and the shared tenant, nothing else
+	if expected := []string{"amount", "currency", "tenant"}; !reflect.DeepEqual(observed["charge"], expected) {
+		t.Errorf("Expected charge to observe %v, got %v", expected, observed["charge"])
+	}
+
+	// The audit work opted out of projection
+	if expected := []string{"amount", "card_number", "currency", "tenant"}; !reflect.DeepEqual(observed["audit"], expected) {
+		t.Errorf("Expected audit to observe %v, got %v", expected, observed["audit"])
+	}
+
+	// Projection does not remove data from the instance context
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if !instance.Context.Has("card_number") {
+		t.Error("The instance context should keep keys hidden from executors")
+	}
+}
+
+func TestWorkflowRuntimeEngineListInUseDefinitions(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+
+	newDefinition := func(id layer1.WorkflowDefinitionID, version layer1.WorkflowDefinitionVersion) layer1.WorkflowDefinition {
+		stateMachine := layer1.NewStateMachineCore()
+		stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+		stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+		stateMachine.AddTransition(layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End"))
+
+		return layer1.NewWorkflowDefinition(id, version, string(id)).
+			SetStateMachine(stateMachine).
+			SetInitialStateID("start").
+			AddFinalStateID("end").
+			SetStatus(layer1.WorkflowDefinitionStatusActive)
+	}
+
+	start := func(definition layer1.WorkflowDefinition, instanceID WorkflowInstanceID) {
+		_, err := engine.StartWorkflowWithOptions(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"), StartOptions{InstanceID: instanceID})
+		if err != nil {
+			t.Fatalf("StartWorkflowWithOptions should not return error: %v", err)
+		}
+	}
+
+	orders := newDefinition("orders", "1.0.0")
+	start(orders, "orders-1")
+	start(orders, "orders-2")
+	start(orders, "orders-3")
+	if err := engine.PauseWorkflow("orders-3"); err != nil {
+		t.Fatalf("PauseWorkflow should not return error: %v", err)
+	}
+
+	refunds := newDefinition("refunds", "2.0.0")
+	start(refunds, "refunds-1")
+	start(refunds, "refunds-2")
+	if err := engine.CancelWorkflow("refunds-2"); err != nil {
+		t.Fatalf("CancelWorkflow should not return error: %v", err)
+	}
+
+	// Instances only known to the store are counted too
+	engine.persistenceStore.SaveWorkflowInstance(WorkflowInstance{
+		ID:                "refunds-3",
+		DefinitionID:      "refunds",
+		DefinitionVersion: "2.0.0",
+		Status:            WorkflowInstanceStatusWaiting,
+		Context:           layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"),
+		Metadata:          map[string]interface{}{},
+	})
+
+	expected := []DefinitionUsage{
+		{
+			DefinitionID:      "orders",
+			DefinitionVersion: "1.0.0",
+			Instances:         3,
+			ByStatus:          map[WorkflowInstanceStatus]int{WorkflowInstanceStatusRunning: 2, WorkflowInstanceStatusPaused: 1},
+		},
+		{
+			DefinitionID:      "refunds",
+			DefinitionVersion: "2.0.0",
+			Instances:         2,
+			ByStatus:          map[WorkflowInstanceStatus]int{WorkflowInstanceStatusRunning: 1, WorkflowInstanceStatusWaiting: 1},
+		},
+	}
+
+	if usage := engine.ListInUseDefinitions(); !reflect.DeepEqual(usage, expected) {
+		t.Errorf("Expected usage %+v, got %+v", expected, usage)
+	}
+
+	// Finished instances no longer count
+	for _, instanceID := range []WorkflowInstanceID{"orders-1", "orders-2"} {
+		if err := engine.ExecuteWorkflow(instanceID); err != nil {
+			t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+		}
+	}
+	if usage := engine.ListInUseDefinitions(); len(usage) != 2 || usage[0].Instances != 1 || usage[0].ByStatus[WorkflowInstanceStatusRunning] != 0 {
+		t.Errorf("Expected only the paused orders instance to remain in use, got %+v", usage)
+	}
+}
+
+// flakyStore rejects instance updates while down and records the statuses it accepts
+type flakyStore struct {
+	*InMemoryStatePersistenceStore
+	down     bool
+	accepted []WorkflowInstanceStatus
+}
+
+func (store *flakyStore) UpdateWorkflowInstance(instance WorkflowInstance) error {
+	if store.down {
+		return errors.New("connection refused")
+	}
+	store.accepted = append(store.accepted, instance.Status)
+	return store.InMemoryStatePersistenceStore.UpdateWorkflowInstance(instance)
+}
+
+func TestWorkflowRuntimeEnginePersistenceFailurePolicy(t *testing.T) {
+	store := &flakyStore{InMemoryStatePersistenceStore: NewInMemoryStatePersistenceStore()}
+	engine := NewWorkflowRuntimeEngine()
+	engine.SetPersistenceStore(store)
+
+	definition := newParallelDefinition(layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End"))
+	instanceID, err := engine.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	// By default a store outage fails the operation
+	store.down = true
+	if err := engine.PauseWorkflow(instanceID); err == nil {
+		t.Error("PauseWorkflow should return error while the store is down")
+	}
+
+	// Under the buffer policy the engine keeps going in memory
+	engine.SetPersistenceFailurePolicy(PersistenceFailurePolicyBuffer)
+	store.accepted = nil
+
+	if err := engine.ResumeWorkflow(instanceID); err != nil {
+		t.Fatalf("ResumeWorkflow should not return error while buffering: %v", err)
+	}
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error while buffering: %v", err)
+	}
+
+	status, _ := engine.GetWorkflowStatus(instanceID)
+	if status != WorkflowInstanceStatusCompleted {
+		t.Errorf("Expected the instance to complete in memory, got %s", status)
+	}
+	buffered := engine.PendingWrites()
+	if buffered == 0 {
+		t.Fatal("Expected updates to be buffered while the store is down")
+	}
+	if persisted, _ := store.GetWorkflowInstance(instanceID); persisted.Status == WorkflowInstanceStatusCompleted {
+		t.Error("The store should not see the completion before it recovers")
+	}
+
+	// Still down: flushing keeps everything
+	if remaining, err := engine.FlushPendingWrites(); err == nil || remaining != buffered {
+		t.Errorf("Expected flush to fail with %d pending, got %d (err %v)", buffered, remaining, err)
+	}
+
+	// Once the store recovers every update lands, in order
+	store.down = false
+	if remaining, err := engine.FlushPendingWrites(); err != nil || remaining != 0 {
+		t.Fatalf("Expected flush to succeed, got %d pending (err %v)", remaining, err)
+	}
+	if len(store.accepted) != buffered {
+		t.Errorf("Expected %d buffered updates to be written, got %d", buffered, len(store.accepted))
+	}
+	if store.accepted[len(store.accepted)-1] != WorkflowInstanceStatusCompleted {
+		t.Errorf("Expected the completion to be written last, got %v", store.accepted)
+	}
+
+	persisted, _ := store.GetWorkflowInstance(instanceID)
+	if persisted.Status != WorkflowInstanceStatusCompleted || persisted.CurrentStateID != "end" {
+		t.Errorf("Expected the store to hold the completed instance, got %s in %s", persisted.Status, persisted.CurrentStateID)
+	}
+}
+
+func TestWorkflowRuntimeEnginePriorityQueue(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	if err := engine.SetExecutionMode(ExecutionModePriorityQueue, 1); err != nil {
+		t.Fatalf("SetExecutionMode should not return error: %v", err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	var order []string
+	priorities := map[string]layer0.WorkPriority{}
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		instanceID := w.GetMetadata().Properties[layer1.WorkPropertyInstanceID]
+		if instanceID == "blocker" {
+			close(started)
+			<-release
+			return nil, nil
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		order = append(order, instanceID)
+		priorities[instanceID] = w.GetPriority()
+		return nil, nil
+	}))
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("job")
+	definition := newParallelDefinition(transition)
+
+	instances := []StartOptions{
+		{InstanceID: "blocker"},
+		{InstanceID: "low", Priority: layer0.WorkPriorityLow},
+		{InstanceID: "high", Priority: layer0.WorkPriorityHigh},
+	}
+	for _, options := range instances {
+		if _, err := engine.StartWorkflowWithOptions(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"), options); err != nil {
+			t.Fatalf("StartWorkflowWithOptions should not return error: %v", err)
+		}
+	}
+
+	if instance, _ := engine.GetWorkflowInstance("high"); instance.Priority != layer0.WorkPriorityHigh {
+		t.Errorf("Expected the instance priority to be %d, got %d", layer0.WorkPriorityHigh, instance.Priority)
+	}
+
+	waitForQueued := func(expected int) {
+		deadline := time.Now().Add(2 * time.Second)
+		for engine.QueuedWork() != expected {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %d queued work, got %d", expected, engine.QueuedWork())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	var wg sync.WaitGroup
+	execute := func(instanceID WorkflowInstanceID) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := engine.ExecuteWorkflow(instanceID); err != nil {
+				t.Errorf("ExecuteWorkflow(%s) should not return error: %v", instanceID, err)
+			}
+		}()
+	}
+
+	// The blocker holds the only worker while the low then the high priority work queue
+	execute("blocker")
+	<-started
+	execute("low")
+	waitForQueued(1)
+	execute("high")
+	waitForQueued(2)
+
+	close(release)
+	wg.Wait()
+
+	if expected := []string{"high", "low"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected queued work to run in order %v, got %v", expected, order)
+	}
+	if priorities["low"] != layer0.WorkPriorityLow || priorities["high"] != layer0.WorkPriorityHigh {
+		t.Errorf("Expected work to inherit the instance priority, got %v", priorities)
+	}
+
+	if err := engine.SetExecutionMode("unknown", 1); err == nil {
+		t.Error("SetExecutionMode should reject an unknown mode")
+	}
+	if err := engine.SetExecutionMode(ExecutionModePriorityQueue, 0); err == nil {
+		t.Error("SetExecutionMode should reject a non-positive worker count")
+	}
+}
+
+func TestWorkflowRuntimeEngineTransitionAnnotations(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddTag("approval").
+		SetAnnotation("ui_label", "Approve")
+
+	instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	history := engine.GetExecutionHistory(instanceID)
+	if len(history) != 1 {
+		t.Fatalf("Expected 1 transition record, got %d", len(history))
+	}
+	if history[0].Annotations["ui_label"] != "Approve" || !reflect.DeepEqual(history[0].Tags, []string{"approval"}) {
+		t.Errorf("Expected the audit record to carry the transition metadata, got tags %v and annotations %v", history[0].Tags, history[0].Annotations)
+	}
+
+	spans, err := engine.ExportTrace(instanceID)
+	if err != nil {
+		t.Fatalf("ExportTrace should not return error: %v", err)
+	}
+	if spans[1].Attributes["workflow.annotation.ui_label"] != "Approve" || spans[1].Attributes["workflow.transition_tags"] != "approval" {
+		t.Errorf("Expected the transition span to carry the metadata, got %v", spans[1].Attributes)
+	}
+}
+
+func TestWorkflowRuntimeEngineMaxConcurrentWork(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+
+	started := make(chan layer0.WorkID, 5)
+	release := make(chan struct{})
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		started <- w.GetID()
+		<-release
+		return nil, nil
+	}))
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		SetExecutionMode(layer0.ActionExecutionParallel)
+	for i := 0; i < 5; i++ {
+		transition = transition.AddAction(fmt.Sprintf("branch-%d", i))
+	}
+
+	definition := newParallelDefinition(transition)
+	config := definition.GetConfiguration()
+	config.MaxConcurrentWork = 2
+	definition = definition.UpdateConfiguration(config)
+
+	instanceID, err := engine.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- engine.ExecuteWorkflow(instanceID) }()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Branches within the cap should start")
+		}
+	}
+
+	// The excess branches wait for a running one to finish
+	select {
+	case workID := <-started:
+		t.Fatalf("Branch %s started beyond the cap of 2", workID)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Queued branches did not run once slots freed up")
+	}
+
+	if len(started) != 3 {
+		t.Errorf("Expected the 3 queued branches to run after the first 2, got %d", len(started))
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.Status != WorkflowInstanceStatusCompleted {
+		t.Errorf("Expected completed status, got %s", instance.Status)
+	}
+}
+
+func TestWorkflowRuntimeEngineStallDetection(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	engine.SetClock(clock)
+	engine.SetStallDetection(time.Hour, true)
+
+	// The instance waits on a manual transition that is never triggered
+	signal := layer0.NewTransition("await-signal", layer0.TransitionTypeManual, "start", "end", "Await Signal")
+	instanceID, err := engine.StartWorkflow(newParallelDefinition(signal), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	clock.Advance(30 * time.Minute)
+	if stalled, err := engine.ProcessStalledInstances(clock.Now()); err != nil || stalled != 0 {
+		t.Fatalf("Expected no stalled instances before the threshold, got %d (%v)", stalled, err)
+	}
+
+	clock.Advance(time.Hour)
+	if stalled, err := engine.ProcessStalledInstances(clock.Now()); err != nil || stalled != 1 {
+		t.Fatalf("Expected 1 stalled instance past the threshold, got %d (%v)", stalled, err)
+	}
+
+	// A stall is only reported once
+	clock.Advance(time.Hour)
+	if stalled, _ := engine.ProcessStalledInstances(clock.Now()); stalled != 0 {
+		t.Errorf("Expected the stall to be reported once, got %d more", stalled)
+	}
+
+	var stalledEvents []WorkflowLifecycleEvent
+	for _, event := range engine.lifecycleManager.GetEvents(instanceID) {
+		if event.EventType == "workflow_stalled" {
+			stalledEvents = append(stalledEvents, event)
+		}
+	}
+	if len(stalledEvents) != 1 || stalledEvents[0].Data["stalled_for"] != (90*time.Minute).String() {
+		t.Errorf("Expected one workflow_stalled event after 1h30m, got %v", stalledEvents)
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.StalledAt == nil || instance.Metadata[MetadataNeedsAttention] != true {
+		t.Errorf("Expected the instance to be marked stalled for attention, got %v and %v", instance.StalledAt, instance.Metadata)
+	}
+
+	persisted, _ := engine.persistenceStore.GetWorkflowInstance(instanceID)
+	if persisted.StalledAt == nil {
+		t.Error("The stall should be persisted")
+	}
+}
+
+func TestWorkflowRuntimeEngineResultTransformer(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		return map[string]interface{}{"customer_name": "Ada", "internal_id": 7, "tier": "gold"}, nil
+	}))
+
+	err := engine.SetResultTransformer("lookup", layer1.ResultTransformer{
+		{Operation: layer1.TransformRename, From: "customer_name", To: "name"},
+		{Operation: layer1.TransformPick, Keys: []string{"name", "tier"}},
+	})
+	if err != nil {
+		t.Fatalf("SetResultTransformer should not return error: %v", err)
+	}
+	if err := engine.SetResultTransformer("lookup", layer1.ResultTransformer{{Operation: "flatten"}}); err == nil {
+		t.Error("SetResultTransformer should reject invalid rules")
+	}
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("lookup")
+	instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	output, _ := instance.Context.Get("work_lookup_output")
+	if expected := map[string]interface{}{"name": "Ada", "tier": "gold"}; !reflect.DeepEqual(output, expected) {
+		t.Errorf("Expected transformed output %v in context, got %v", expected, output)
+	}
+
+	// The execution history keeps the raw output
+	history := engine.GetExecutionHistory(instanceID)
+	if raw, _ := history[0].WorkResults[0].Output.(map[string]interface{}); raw["customer_name"] != "Ada" {
+		t.Errorf("Expected the work result to keep the raw output, got %v", history[0].WorkResults[0].Output)
+	}
+}
+
+func TestWorkflowRuntimeEngineInvariants(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+
+	// The invariant holds while the total is not negative
+	engine.RegisterConditionEvaluator(layer0.ConditionTypeExpression, layer1.NewMockConditionEvaluator([]layer0.ConditionType{layer0.ConditionTypeExpression}, func(c layer0.Condition, ctx *layer0.Context) (interface{}, error) {
+		total, _ := ctx.Get("total")
+		return total.(int) >= 0, nil
+	}))
+
+	// Each step spends 10 from the total
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		total, _ := c.Get("total")
+		return total.(int) - 10, nil
+	}))
+	spend := layer0.NewWork("spend", layer0.WorkTypeTask, "Spend")
+	spend.Metadata.Properties[layer1.WorkPropertyOutputKey] = "total"
+	engine.RegisterActionWork("spend", spend)
+
+	stateMachine := layer1.NewStateMachineCore()
+	stateIDs := []layer0.StateID{"s0", "s1", "s2", "s3", "s4"}
+	for i, stateID := range stateIDs {
+		stateType := layer0.StateTypeIntermediate
+		switch i {
+		case 0:
+			stateType = layer0.StateTypeInitial
+		case len(stateIDs) - 1:
+			stateType = layer0.StateTypeFinal
+		}
+		stateMachine.AddState(layer0.NewState(stateID, stateType, string(stateID)))
+		if i > 0 {
+			transitionID := layer0.TransitionID(fmt.Sprintf("%s-to-%s", stateIDs[i-1], stateID))
+			stateMachine.AddTransition(layer0.NewTransition(transitionID, layer0.TransitionTypeAutomatic, stateIDs[i-1], stateID, string(transitionID)).AddAction("spend"))
+		}
+	}
+
+	nonNegative := layer0.NewCondition("non-negative-total", layer0.ConditionTypeExpression, "Non-negative total")
+	nonNegative.Expression.Expression = "total >= 0"
+
+	definition := layer1.NewWorkflowDefinition("budget-workflow", "1.0.0", "Budget Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("s0").
+		AddFinalStateID("s4").
+		SetStatus(layer1.WorkflowDefinitionStatusActive).
+		AddInvariant("total-never-negative", nonNegative)
+
+	instanceID, err := engine.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").Set("total", 25))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	// The total goes 15, 5, then -5 on the third transition
+	err = engine.ExecuteWorkflow(instanceID)
+	if !errors.Is(err, ErrInvariantViolated) {
+		t.Fatalf("Expected an invariant violation, got %v", err)
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.Status != WorkflowInstanceStatusFailed {
+		t.Errorf("Expected failed status, got %s", instance.Status)
+	}
+	if !strings.Contains(instance.Error, "total-never-negative") {
+		t.Errorf("Expected the error to name the invariant, got %q", instance.Error)
+	}
+
+	// The violating transition is not committed
+	if instance.CurrentStateID != "s2" {
+		t.Errorf("Expected the instance to stay in s2, got %s", instance.CurrentStateID)
+	}
+	if total, _ := instance.Context.Get("total"); total != 5 {
+		t.Errorf("Expected the last valid total of 5, got %v", total)
+	}
+}
+
+func TestWorkflowRuntimeEngineSuspendResume(t *testing.T) {
+	newLinearDefinition := func() layer1.WorkflowDefinition {
+		stateMachine := layer1.NewStateMachineCore()
+		stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+		stateMachine.AddState(layer0.NewState("middle", layer0.StateTypeIntermediate, "Middle"))
+		stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+		stateMachine.AddTransition(layer0.NewTransition("start-to-middle", layer0.TransitionTypeAutomatic, "start", "middle", "Start to Middle"))
+		stateMachine.AddTransition(layer0.NewTransition("middle-to-end", layer0.TransitionTypeAutomatic, "middle", "end", "Middle to End"))
+
+		return layer1.NewWorkflowDefinition("linear-workflow", "1.0.0", "Linear Workflow").
+			SetStateMachine(stateMachine).
+			SetInitialStateID("start").
+			AddFinalStateID("end").
+			SetStatus(layer1.WorkflowDefinitionStatusActive)
+	}
+
+	source := NewWorkflowRuntimeEngine()
+	definition := newLinearDefinition()
+	for _, instanceID := range []WorkflowInstanceID{"a", "b", "c"} {
+		initialContext := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").Set("owner", string(instanceID))
+		if _, err := source.StartWorkflowWithOptions(definition, initialContext, StartOptions{InstanceID: instanceID}); err != nil {
+			t.Fatalf("StartWorkflowWithOptions should not return error: %v", err)
+		}
+	}
+
+	// a has moved on a step and c was paused before the engine was suspended
+	if err := source.ExecuteStep("a"); err != nil {
+		t.Fatalf("ExecuteStep should not return error: %v", err)
+	}
+	if err := source.PauseWorkflow("c"); err != nil {
+		t.Fatalf("PauseWorkflow should not return error: %v", err)
+	}
+
+	data, err := source.Suspend()
+	if err != nil {
+		t.Fatalf("Suspend should not return error: %v", err)
+	}
+
+	// The suspended engine runs no further steps
+	if status, _ := source.GetWorkflowStatus("b"); status != WorkflowInstanceStatusPaused {
+		t.Errorf("Expected running instances to be paused by Suspend, got %s", status)
+	}
+	if err := source.ExecuteStep("b"); !errors.Is(err, ErrEngineSuspended) {
+		t.Errorf("Expected steps to be refused while suspended, got %v", err)
+	}
+	if _, err := source.Suspend(); !errors.Is(err, ErrEngineSuspended) {
+		t.Errorf("Expected a second Suspend to be refused, got %v", err)
+	}
+
+	target := NewWorkflowRuntimeEngine()
+	if err := target.Resume(data); err != nil {
+		t.Fatalf("Resume should not return error: %v", err)
+	}
+
+	if active := target.ListActiveWorkflows(); !reflect.DeepEqual(active, []WorkflowInstanceID{"a", "b", "c"}) {
+		t.Errorf("Expected instances a, b and c to be restored, got %v", active)
+	}
+
+	expected := map[WorkflowInstanceID]struct {
+		status WorkflowInstanceStatus
+		state  layer0.StateID
+	}{
+		"a": {WorkflowInstanceStatusRunning, "middle"},
+		"b": {WorkflowInstanceStatusRunning, "start"},
+		"c": {WorkflowInstanceStatusPaused, "start"},
+	}
+	for instanceID, want := range expected {
+		original, _ := source.GetWorkflowInstance(instanceID)
+		restored, err := target.GetWorkflowInstance(instanceID)
+		if err != nil {
+			t.Fatalf("GetWorkflowInstance(%s) should not return error: %v", instanceID, err)
+		}
+
+		if restored.Status != want.status || restored.CurrentStateID != want.state {
+			t.Errorf("Expected %s to be %s in %s, got %s in %s", instanceID, want.status, want.state, restored.Status, restored.CurrentStateID)
+		}
+		if owner, _ := restored.Context.Get("owner"); owner != string(instanceID) {
+			t.Errorf("Expected the context of %s to be restored, got owner %v", instanceID, owner)
+		}
+		if restored.CorrelationID != original.CorrelationID || !restored.CreatedAt.Equal(original.CreatedAt) {
+			t.Errorf("Expected %s to keep its identity, got %+v", instanceID, restored)
+		}
+		if len(target.GetExecutionHistory(instanceID)) != len(source.GetExecutionHistory(instanceID)) {
+			t.Errorf("Expected the history of %s to be restored", instanceID)
+		}
+	}
+
+	// Restored instances continue on the new engine and its store
+	if err := target.ExecuteWorkflow("b"); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+	if status, _ := target.GetWorkflowStatus("b"); status != WorkflowInstanceStatusCompleted {
+		t.Errorf("Expected b to complete after resuming, got %s", status)
+	}
+
+	if err := target.Resume(data); err == nil {
+		t.Error("Resuming into an engine with active instances should return error")
+	}
+}
+
+// newThreeWayDefinition creates a definition whose start state has three unconditional
+// transitions, pick-a, pick-b and pick-c, leading to the given states
+func newThreeWayDefinition(targets [3]layer0.StateID, priorities [3]int, strategy layer1.TransitionSelectionStrategy) layer1.WorkflowDefinition {
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	added := map[layer0.StateID]bool{}
+	for i, name := range []string{"a", "b", "c"} {
+		if !added[targets[i]] {
+			stateMachine.AddState(layer0.NewState(targets[i], layer0.StateTypeFinal, string(targets[i])))
+			added[targets[i]] = true
+		}
+
+		transition := layer0.NewTransition(layer0.TransitionID("pick-"+name), layer0.TransitionTypeAutomatic, "start", targets[i], "Pick "+name).
+			AddAction("action-" + name)
+		transition.Priority = priorities[i]
+		stateMachine.AddTransition(transition)
+	}
+
+	definition := layer1.NewWorkflowDefinition("three-way-workflow", "1.0.0", "Three Way Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+	for target := range added {
+		definition = definition.AddFinalStateID(target)
+	}
+
+	config := definition.GetConfiguration()
+	config.TransitionSelection = strategy
+	return definition.UpdateConfiguration(config)
+}
+
+// newThreeWayEngine creates an engine whose task actions succeed without output
+func newThreeWayEngine() *WorkflowRuntimeEngine {
+	engine := NewWorkflowRuntimeEngine()
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		return nil, nil
+	}))
+	return engine
+}
+
+// runThreeWayStep takes one step of a three way definition and returns the state
+// reached, the IDs of the work that ran and the step error
+func runThreeWayStep(t *testing.T, engine *WorkflowRuntimeEngine, definition layer1.WorkflowDefinition) (layer0.StateID, []string, error) {
+	instanceID, err := engine.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	stepErr := engine.ExecuteStep(instanceID)
+	instance, err := engine.GetWorkflowInstance(instanceID)
+	if err != nil {
+		t.Fatalf("GetWorkflowInstance should not return error: %v", err)
+	}
+
+	var ran []string
+	for _, record := range engine.GetExecutionHistory(instanceID) {
+		for _, result := range record.WorkResults {
+			ran = append(ran, string(result.WorkID))
+		}
+	}
+	sort.Strings(ran)
+	return instance.CurrentStateID, ran, stepErr
+}
+
+func TestWorkflowRuntimeEngineTransitionSelectionStrategy(t *testing.T) {
+	distinct := [3]layer0.StateID{"state-a", "state-b", "state-c"}
+
+	t.Run("first match", func(t *testing.T) {
+		// The default takes the first satisfiable transition in priority order
+		state, ran, err := runThreeWayStep(t, newThreeWayEngine(), newThreeWayDefinition(distinct, [3]int{0, 0, 0}, ""))
+		if err != nil {
+			t.Fatalf("ExecuteStep should not return error: %v", err)
+		}
+		if state != "state-a" || !reflect.DeepEqual(ran, []string{"action-a"}) {
+			t.Errorf("Expected first match to take pick-a, got %s after %v", state, ran)
+		}
+
+		state, _, _ = runThreeWayStep(t, newThreeWayEngine(), newThreeWayDefinition(distinct, [3]int{0, 2, 1}, layer1.TransitionSelectionFirstMatch))
+		if state != "state-b" {
+			t.Errorf("Expected first match to respect priority, got %s", state)
+		}
+	})
+
+	t.Run("highest priority", func(t *testing.T) {
+		state, ran, err := runThreeWayStep(t, newThreeWayEngine(), newThreeWayDefinition(distinct, [3]int{1, 3, 2}, layer1.TransitionSelectionHighestPriority))
+		if err != nil {
+			t.Fatalf("ExecuteStep should not return error: %v", err)
+		}
+		if state != "state-b" || !reflect.DeepEqual(ran, []string{"action-b"}) {
+			t.Errorf("Expected highest priority to take pick-b, got %s after %v", state, ran)
+		}
+
+		// A tie at the highest priority is ambiguous and nothing runs
+		state, ran, err = runThreeWayStep(t, newThreeWayEngine(), newThreeWayDefinition(distinct, [3]int{3, 3, 2}, layer1.TransitionSelectionHighestPriority))
+		if err == nil || !strings.Contains(err.Error(), "highest priority") {
+			t.Errorf("Expected an ambiguous priority error, got %v", err)
+		}
+		if state != "start" || len(ran) != 0 {
+			t.Errorf("Expected no transition to run, got %s after %v", state, ran)
+		}
+	})
+
+	t.Run("all", func(t *testing.T) {
+		joined := [3]layer0.StateID{"joined", "joined", "joined"}
+		engine := newThreeWayEngine()
+		engine.SetParallelActionLimit(1)
+		if err := engine.SetTransitionSelectionStrategy(layer1.TransitionSelectionAll); err != nil {
+			t.Fatalf("SetTransitionSelectionStrategy should not return error: %v", err)
+		}
+
+		definition := newThreeWayDefinition(joined, [3]int{0, 0, 0}, "")
+		state, ran, err := runThreeWayStep(t, engine, definition)
+		if err != nil {
+			t.Fatalf("ExecuteStep should not return error: %v", err)
+		}
+		if state != "joined" || !reflect.DeepEqual(ran, []string{"action-a", "action-b", "action-c"}) {
+			t.Errorf("Expected every satisfiable transition to run, got %s after %v", state, ran)
+		}
+
+		// Satisfiable transitions to different states cannot all be taken
+		_, ran, err = runThreeWayStep(t, engine, newThreeWayDefinition(distinct, [3]int{0, 0, 0}, ""))
+		if err == nil || len(ran) != 0 {
+			t.Errorf("Expected an error and no work for diverging transitions, got %v after %v", err, ran)
+		}
+	})
+
+	t.Run("random", func(t *testing.T) {
+		// The same seed makes the same sequence of choices on separate engines
+		sequence := func(seed int64) []layer0.StateID {
+			engine := newThreeWayEngine()
+			engine.SetTransitionTiebreakSeed(seed)
+			engine.SetTransitionSelectionStrategy(layer1.TransitionSelectionRandom)
+
+			var states []layer0.StateID
+			for i := 0; i < 30; i++ {
+				state, ran, err := runThreeWayStep(t, engine, newThreeWayDefinition(distinct, [3]int{5, 0, 0}, ""))
+				if err != nil {
+					t.Fatalf("ExecuteStep should not return error: %v", err)
+				}
+				if len(ran) != 1 {
+					t.Fatalf("Expected one transition to run, got %v", ran)
+				}
+				states = append(states, state)
+			}
+			return states
+		}
+
+		first, second := sequence(7), sequence(7)
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("Expected seed 7 to repeat its choices, got %v and %v", first, second)
+		}
+
+		// Random selection ignores priority and reaches every state
+		seen := map[layer0.StateID]bool{}
+		for _, state := range first {
+			seen[state] = true
+		}
+		if len(seen) != 3 {
+			t.Errorf("Expected random selection to reach all three states, got %v", seen)
+		}
+	})
+
+	if err := NewWorkflowRuntimeEngine().SetTransitionSelectionStrategy("bogus"); err == nil {
+		t.Error("SetTransitionSelectionStrategy should reject unknown strategies")
+	}
+}
+
+// newChainDefinition creates a definition that moves through one, two and three to end
+func newChainDefinition() layer1.WorkflowDefinition {
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("one", layer0.StateTypeInitial, "One"))
+	stateMachine.AddState(layer0.NewState("two", layer0.StateTypeIntermediate, "Two"))
+	stateMachine.AddState(layer0.NewState("three", layer0.StateTypeIntermediate, "Three"))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	stateMachine.AddTransition(layer0.NewTransition("one-to-two", layer0.TransitionTypeAutomatic, "one", "two", "One to Two"))
+	stateMachine.AddTransition(layer0.NewTransition("two-to-three", layer0.TransitionTypeAutomatic, "two", "three", "Two to Three"))
+	stateMachine.AddTransition(layer0.NewTransition("three-to-end", layer0.TransitionTypeAutomatic, "three", "end", "Three to End"))
+
+	return layer1.NewWorkflowDefinition("chain-workflow", "1.0.0", "Chain Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("one").
+		AddFinalStateID("end").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+}
+
+// awaitTimer waits for the engine to start waiting on the manual clock
+func awaitTimer(t *testing.T, clock *ManualClock) {
+	deadline := time.Now().Add(2 * time.Second)
+	for clock.PendingTimers() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the engine to wait on the manual clock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWorkflowRuntimeEngineTransitionRateLimit(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	engine.SetClock(clock)
+
+	definition := newChainDefinition()
+	config := definition.GetConfiguration()
+	config.MinTransitionInterval = time.Second
+	definition = definition.UpdateConfiguration(config)
+
+	instanceID, err := engine.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- engine.ExecuteWorkflow(instanceID) }()
+
+	// The first transition runs at once; each later one waits for the clock
+	for i := 0; i < 2; i++ {
+		awaitTimer(t, clock)
+		clock.Advance(400 * time.Millisecond)
+		select {
+		case err := <-done:
+			t.Fatalf("Workflow finished before its transitions were spaced out: %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+		clock.Advance(600 * time.Millisecond)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Workflow did not finish once the clock allowed it")
+	}
+
+	history := engine.GetExecutionHistory(instanceID)
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 transitions, got %d", len(history))
+	}
+	for i := 1; i < len(history); i++ {
+		if gap := history[i].StartedAt.Sub(history[i-1].CompletedAt); gap < time.Second {
+			t.Errorf("Expected transition %s to start at least 1s after %s, got %s", history[i].TransitionID, history[i-1].TransitionID, gap)
+		}
+	}
+}
+
+func TestWorkflowRuntimeEngineTransitionRateLimitCancel(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	engine.SetClock(clock)
+	if err := engine.SetTransitionRateLimit(time.Minute); err != nil {
+		t.Fatalf("SetTransitionRateLimit should not return error: %v", err)
+	}
+	if err := engine.SetTransitionRateLimit(-time.Second); err == nil {
+		t.Error("SetTransitionRateLimit should reject a negative interval")
+	}
+
+	instanceID, err := engine.StartWorkflow(newChainDefinition(), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.ExecuteStep(instanceID); err != nil {
+		t.Fatalf("ExecuteStep should not return error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- engine.ExecuteStep(instanceID) }()
+	awaitTimer(t, clock)
+
+	if err := engine.CancelWorkflow(instanceID); err != nil {
+		t.Fatalf("CancelWorkflow should not return error: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the delayed step to be cancelled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Cancelling the instance should release the delayed step")
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.CurrentStateID != "two" {
+		t.Errorf("Expected the cancelled instance to stay in two, got %s", instance.CurrentStateID)
+	}
+}
+
+func TestConsistentHashSharder(t *testing.T) {
+	sharder, err := NewConsistentHashSharder([]string{"node-a", "node-b", "node-c"}, 0)
+	if err != nil {
+		t.Fatalf("NewConsistentHashSharder should not return error: %v", err)
+	}
+
+	owners := map[string]int{}
+	assigned := map[WorkflowInstanceID]string{}
+	for i := 0; i < 300; i++ {
+		instanceID := WorkflowInstanceID(fmt.Sprintf("order-%d", i))
+		node := sharder.NodeFor(instanceID)
+		if sharder.NodeFor(instanceID) != node {
+			t.Fatalf("Expected %s to map to the same node every time", instanceID)
+		}
+		owners[node]++
+		assigned[instanceID] = node
+	}
+	if len(owners) != 3 {
+		t.Errorf("Expected instances spread over all three nodes, got %v", owners)
+	}
+
+	// Removing a node only moves the instances it owned
+	reduced, _ := NewConsistentHashSharder([]string{"node-a", "node-b"}, 0)
+	for instanceID, node := range assigned {
+		if node != "node-c" && reduced.NodeFor(instanceID) != node {
+			t.Errorf("Expected %s to stay on %s, moved to %s", instanceID, node, reduced.NodeFor(instanceID))
+		}
+	}
+
+	if _, err := NewConsistentHashSharder(nil, 0); err == nil {
+		t.Error("NewConsistentHashSharder should reject an empty node list")
+	}
+	if _, err := NewConsistentHashSharder([]string{"node-a", "node-a"}, 0); err == nil {
+		t.Error("NewConsistentHashSharder should reject duplicate nodes")
+	}
+}
+
+func TestWorkflowRuntimeEngineSharding(t *testing.T) {
+	store := NewInMemoryStatePersistenceStore()
+	clock := NewManualClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	sharder, err := NewConsistentHashSharder([]string{"node-a", "node-b"}, 0)
+	if err != nil {
+		t.Fatalf("NewConsistentHashSharder should not return error: %v", err)
+	}
+
+	var mutex sync.Mutex
+	escalations := map[string]int{}
+	engines := map[string]*WorkflowRuntimeEngine{}
+	for _, node := range []string{"node-a", "node-b"} {
+		engine := NewWorkflowRuntimeEngine()
+		engine.SetPersistenceStore(store)
+		engine.SetClock(clock)
+		engine.SetSharder(sharder, node)
+		engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			escalations[w.GetMetadata().Properties[layer1.WorkPropertyInstanceID]]++
+			return nil, nil
+		}))
+		engines[node] = engine
+	}
+
+	// start -> review, which escalates once its day-long deadline passes
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	stateMachine.AddState(layer0.NewState("review", layer0.StateTypeIntermediate, "Review").SetTimeout(24 * time.Hour))
+	stateMachine.AddState(layer0.NewState("escalated", layer0.StateTypeFinal, "Escalated"))
+	stateMachine.AddTransition(layer0.NewTransition("start-to-review", layer0.TransitionTypeAutomatic, "start", "review", "Start to Review"))
+	stateMachine.AddTransition(layer0.NewTransition("review-to-escalated", layer0.TransitionTypeConditional, "review", "escalated", "Review to Escalated").
+		AddCondition("approved"))
+	stateMachine.AddTransition(layer0.NewTransition("review-timeout", layer0.TransitionTypeTimeout, "review", "escalated", "Review Timeout").
+		AddAction("escalate"))
+	definition := layer1.NewWorkflowDefinition("sharded-workflow", "1.0.0", "Sharded Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("escalated").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	// Instances are started on either node regardless of who owns them
+	owned := map[string]int{}
+	for i := 0; i < 20; i++ {
+		instanceID := WorkflowInstanceID(fmt.Sprintf("review-%d", i))
+		owned[sharder.NodeFor(instanceID)]++
+
+		engine := engines[[]string{"node-a", "node-b"}[i%2]]
+		context := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").Set("approved", false)
+		if _, err := engine.StartWorkflowWithOptions(definition, context, StartOptions{InstanceID: instanceID}); err != nil {
+			t.Fatalf("StartWorkflowWithOptions should not return error: %v", err)
+		}
+		engine.ExecuteWorkflow(instanceID)
+	}
+	if owned["node-a"] == 0 || owned["node-b"] == 0 {
+		t.Fatalf("Expected both nodes to own instances, got %v", owned)
+	}
+
+	clock.Advance(25 * time.Hour)
+	for _, node := range []string{"node-a", "node-b"} {
+		engine := engines[node]
+		timedOut, err := engine.ProcessStateTimeouts(clock.Now())
+		if err != nil {
+			t.Fatalf("ProcessStateTimeouts on %s should not return error: %v", node, err)
+		}
+		if timedOut != owned[node] {
+			t.Errorf("Expected %s to time out its %d instances, got %d", node, owned[node], timedOut)
+		}
+	}
+
+	if len(escalations) != 20 {
+		t.Errorf("Expected all 20 instances to escalate, got %d", len(escalations))
+	}
+	for instanceID, count := range escalations {
+		if count != 1 {
+			t.Errorf("Expected %s to escalate once, got %d", instanceID, count)
+		}
+	}
+}
+
+func TestWorkflowRuntimeEngineContextPatch(t *testing.T) {
+	run := func(engine *WorkflowRuntimeEngine) TransitionRecord {
+		engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+			if w.GetID() == "classify" {
+				return "priority", nil
+			}
+			return map[string]interface{}{"tier": "gold"}, nil
+		}))
+		for _, actionID := range []string{"enrich", "classify"} {
+			work := layer0.NewWork(layer0.WorkID(actionID), layer0.WorkTypeTask, actionID)
+			work.Metadata.Properties[layer1.WorkPropertyOutputKey] = map[string]string{"enrich": "customer/profile", "classify": "status"}[actionID]
+			engine.RegisterActionWork(actionID, work)
+		}
+
+		transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+			AddAction("enrich").
+			AddAction("classify")
+		initialContext := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").
+			Set("status", "new").
+			Set("order", "A-1")
+		instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), initialContext)
+		if err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+		if err := engine.ExecuteStep(instanceID); err != nil {
+			t.Fatalf("ExecuteStep should not return error: %v", err)
+		}
+
+		history := engine.GetExecutionHistory(instanceID)
+		if len(history) != 1 {
+			t.Fatalf("Expected 1 transition record, got %d", len(history))
+		}
+		return history[0]
+	}
+
+	// The patch adds the enriched profile and replaces the status; order is untouched
+	record := run(NewWorkflowRuntimeEngine())
+	expected := []PatchOperation{
+		{Op: "add", Path: "/customer~1profile", Value: map[string]interface{}{"tier": "gold"}},
+		{Op: "replace", Path: "/status", Value: "priority"},
+	}
+	if !reflect.DeepEqual(record.ContextPatch, expected) || record.ContextPatchOmitted != 0 {
+		t.Errorf("Expected patch %v, got %v (%d omitted)", expected, record.ContextPatch, record.ContextPatchOmitted)
+	}
+
+	// Patches past the limit are dropped and counted
+	engine := NewWorkflowRuntimeEngine()
+	if err := engine.SetContextPatchLimit(1); err != nil {
+		t.Fatalf("SetContextPatchLimit should not return error: %v", err)
+	}
+	record = run(engine)
+	if len(record.ContextPatch) != 1 || record.ContextPatchOmitted != 1 {
+		t.Errorf("Expected 1 kept and 1 omitted operation, got %v (%d omitted)", record.ContextPatch, record.ContextPatchOmitted)
+	}
+
+	// Nested objects are diffed key by key
+	before := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").
+		Set("customer", map[string]interface{}{"name": "Ada", "tier": "silver", "region": "eu"})
+	after := before.Set("customer", map[string]interface{}{"name": "Ada", "tier": "gold"})
+	patch, err := diffContexts(before, after)
+	if err != nil {
+		t.Fatalf("diffContexts should not return error: %v", err)
+	}
+	expected = []PatchOperation{
+		{Op: "remove", Path: "/customer/region"},
+		{Op: "replace", Path: "/customer/tier", Value: "gold"},
+	}
+	if !reflect.DeepEqual(patch, expected) {
+		t.Errorf("Expected nested patch %v, got %v", expected, patch)
+	}
+}
+
+func TestDefaultWorkflowLifecycleManagerDeduplication(t *testing.T) {
+	manager := NewDefaultWorkflowLifecycleManager()
+	instanceID := WorkflowInstanceID("dedup-instance")
+
+	// Without deduplication every notification is recorded
+	manager.OnStateChanged(instanceID, "start", "review")
+	manager.OnStateChanged(instanceID, "start", "review")
+	if events := manager.GetEvents(instanceID); len(events) != 2 {
+		t.Fatalf("Expected 2 events without deduplication, got %d", len(events))
+	}
+	manager.ClearEvents(instanceID)
+
+	manager.SetDeduplication(time.Minute)
+	manager.OnStateChanged(instanceID, "start", "review")
+	manager.OnStateChanged(instanceID, "start", "review")
+	if events := manager.GetEvents(instanceID); len(events) != 1 {
+		t.Fatalf("Expected the repeated state change to be recorded once, got %d", len(events))
+	}
+
+	// Different transitions and event types are not duplicates
+	manager.OnStateChanged(instanceID, "review", "end")
+	manager.OnWorkflowCompleted(instanceID)
+	manager.OnWorkflowCompleted(instanceID)
+	manager.OnStateChanged("other-instance", "start", "review")
+
+	var types []string
+	for _, event := range manager.GetEvents(instanceID) {
+		types = append(types, event.EventType)
+	}
+	if expected := []string{"state_changed", "state_changed", "workflow_completed"}; !reflect.DeepEqual(types, expected) {
+		t.Errorf("Expected events %v, got %v", expected, types)
+	}
+	if len(manager.GetEvents("other-instance")) != 1 {
+		t.Error("Events of another instance should not be treated as duplicates")
+	}
+}
+
+func TestWorkflowRuntimeEngineDefinitionVersionPinning(t *testing.T) {
+	registry := layer1.NewDefinitionRegistry()
+	if err := registry.Register(newChainDefinition()); err != nil {
+		t.Fatalf("Register should not return error: %v", err)
+	}
+
+	original, _ := registry.GetDefault("chain-workflow")
+	engine := NewWorkflowRuntimeEngine()
+	instanceID, err := engine.StartWorkflow(original, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	// The update skips state three and is stored as a new version
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("one", layer0.StateTypeInitial, "One"))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	stateMachine.AddTransition(layer0.NewTransition("one-to-end", layer0.TransitionTypeAutomatic, "one", "end", "One to End"))
+	updated, err := registry.UpdateDefinition(original.SetStateMachine(stateMachine))
+	if err != nil {
+		t.Fatalf("UpdateDefinition should not return error: %v", err)
+	}
+	if updated.GetVersion() != "1.0.1" {
+		t.Fatalf("Expected version 1.0.1, got %s", updated.GetVersion())
+	}
+
+	previous, err := registry.Get("chain-workflow", "1.0.0")
+	if err != nil {
+		t.Fatalf("Expected version 1.0.0 to remain registered: %v", err)
+	}
+	if states := previous.GetStateMachine().GetAllStates(); len(states) != 4 {
+		t.Errorf("Expected version 1.0.0 to keep its 4 states, got %d", len(states))
+	}
+
+	// The running instance finishes on the version it started with
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.DefinitionVersion != "1.0.0" {
+		t.Errorf("Expected the instance to stay on version 1.0.0, got %s", instance.DefinitionVersion)
+	}
+	if history := engine.GetExecutionHistory(instanceID); len(history) != 3 {
+		t.Errorf("Expected the 3 transitions of version 1.0.0, got %d", len(history))
+	}
+
+	// New starts use the promoted version
+	if err := registry.SetDefaultVersion("chain-workflow", updated.GetVersion()); err != nil {
+		t.Fatalf("SetDefaultVersion should not return error: %v", err)
+	}
+	promoted, _ := registry.GetDefault("chain-workflow")
+	nextEngine := NewWorkflowRuntimeEngine()
+	nextID, err := nextEngine.StartWorkflow(promoted, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if next, _ := nextEngine.GetWorkflowInstance(nextID); next.DefinitionVersion != "1.0.1" {
+		t.Errorf("Expected a new instance of version 1.0.1, got %s", next.DefinitionVersion)
+	}
+}
+
+func TestWorkflowRuntimeEngineExplainStep(t *testing.T) {
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("review", layer0.StateTypeInitial, "Review"))
+	stateMachine.AddState(layer0.NewState("shipped", layer0.StateTypeFinal, "Shipped"))
+	stateMachine.AddState(layer0.NewState("cancelled", layer0.StateTypeFinal, "Cancelled"))
+	stateMachine.AddTransition(layer0.NewTransition("ship", layer0.TransitionTypeConditional, "review", "shipped", "Ship").
+		AddCondition("approved").
+		AddCondition("paid"))
+	stateMachine.AddTransition(layer0.NewTransition("cancel", layer0.TransitionTypeConditional, "review", "cancelled", "Cancel").
+		AddCondition("rejected"))
+
+	definition := layer1.NewWorkflowDefinition("explain-workflow", "1.0.0", "Explain Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("review").
+		AddFinalStateID("shipped").
+		AddFinalStateID("cancelled").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	engine := NewWorkflowRuntimeEngine()
+	initialContext := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").
+		Set("approved", true).
+		Set("paid", false).
+		Set("rejected", false)
+	instanceID, err := engine.StartWorkflow(definition, initialContext)
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	explanation, err := engine.ExplainStep(instanceID)
+	if err != nil {
+		t.Fatalf("ExplainStep should not return error: %v", err)
+	}
+	if explanation.CurrentStateID != "review" || len(explanation.Transitions) != 2 {
+		t.Fatalf("Expected 2 transitions explained from review, got %d from %s", len(explanation.Transitions), explanation.CurrentStateID)
+	}
+
+	expected := map[layer0.TransitionID]map[layer0.ConditionID]layer0.ConditionStatus{
+		"ship":   {"approved": layer0.ConditionStatusTrue, "paid": layer0.ConditionStatusFalse},
+		"cancel": {"rejected": layer0.ConditionStatusFalse},
+	}
+	for _, transition := range explanation.Transitions {
+		conditions := expected[transition.Transition.GetID()]
+		if transition.Satisfiable {
+			t.Errorf("Expected transition %s to be blocked", transition.Transition.GetID())
+		}
+		if len(transition.Conditions) != len(conditions) {
+			t.Fatalf("Expected %d conditions on %s, got %d", len(conditions), transition.Transition.GetID(), len(transition.Conditions))
+		}
+		for _, condition := range transition.Conditions {
+			if condition.Status != conditions[condition.ConditionID] {
+				t.Errorf("Expected condition %s of %s to be %s, got %s", condition.ConditionID, transition.Transition.GetID(), conditions[condition.ConditionID], condition.Status)
+			}
+		}
+	}
+
+	// Explaining neither advances the instance nor records history
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.CurrentStateID != "review" || len(engine.GetExecutionHistory(instanceID)) != 0 {
+		t.Errorf("Expected the instance to stay in review without history, got %s", instance.CurrentStateID)
+	}
+
+	if _, err := engine.ExplainStep("missing"); err == nil {
+		t.Error("ExplainStep should return error for an unknown instance")
+	}
+}
+
+func TestWorkflowRuntimeEngineWeightedTransitions(t *testing.T) {
+	newWeightedDefinition := func(weights map[layer0.TransitionID]float64) layer1.WorkflowDefinition {
+		stateMachine := layer1.NewStateMachineCore()
+		stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+		for _, transitionID := range []layer0.TransitionID{"variant-a", "variant-b", "variant-c", "control"} {
+			stateMachine.AddState(layer0.NewState(layer0.StateID(transitionID), layer0.StateTypeFinal, string(transitionID)))
+			transition := layer0.NewTransition(transitionID, layer0.TransitionTypeAutomatic, "start", layer0.StateID(transitionID), string(transitionID))
+			if weight, exists := weights[transitionID]; exists {
+				transition = transition.SetWeight(weight)
+			}
+			stateMachine.AddTransition(transition)
+		}
+
+		return layer1.NewWorkflowDefinition("ab-workflow", "1.0.0", "A/B Workflow").
+			SetStateMachine(stateMachine).
+			SetInitialStateID("start").
+			AddFinalStateID("variant-a").
+			AddFinalStateID("variant-b").
+			AddFinalStateID("variant-c").
+			AddFinalStateID("control").
+			SetStatus(layer1.WorkflowDefinitionStatusActive)
+	}
+
+	runInstances := func(engine *WorkflowRuntimeEngine, definition layer1.WorkflowDefinition, count int) map[layer0.StateID]int {
+		counts := make(map[layer0.StateID]int)
+		for i := 0; i < count; i++ {
+			instanceID, err := engine.StartWorkflowWithOptions(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"),
+				StartOptions{InstanceID: WorkflowInstanceID(fmt.Sprintf("ab-%d", i))})
+			if err != nil {
+				t.Fatalf("StartWorkflow should not return error: %v", err)
+			}
+			if err := engine.ExecuteStep(instanceID); err != nil {
+				t.Fatalf("ExecuteStep should not return error: %v", err)
+			}
+			instance, _ := engine.GetWorkflowInstance(instanceID)
+			counts[instance.CurrentStateID]++
+		}
+		return counts
+	}
+
+	// Weighted transitions split 3:1, the zero weight is never chosen and the
+	// unweighted control is not considered while a weighted transition is satisfiable
+	engine := NewWorkflowRuntimeEngine()
+	engine.SetTransitionTiebreakSeed(42)
+	definition := newWeightedDefinition(map[layer0.TransitionID]float64{"variant-a": 3, "variant-b": 1, "variant-c": 0})
+	counts := runInstances(engine, definition, 2000)
+
+	if counts["variant-c"] != 0 || counts["control"] != 0 {
+		t.Errorf("Expected only the positively weighted variants to be chosen, got %v", counts)
+	}
+	if share := float64(counts["variant-a"]) / 2000; share < 0.72 || share > 0.78 {
+		t.Errorf("Expected variant-a to take about 75%% of instances, got %.3f (%v)", share, counts)
+	}
+
+	// The seed makes the split reproducible
+	replay := NewWorkflowRuntimeEngine()
+	replay.SetTransitionTiebreakSeed(42)
+	if replayed := runInstances(replay, definition, 2000); !reflect.DeepEqual(replayed, counts) {
+		t.Errorf("Expected the same seed to reproduce the split %v, got %v", counts, replayed)
+	}
+
+	// With only zero weights the normal strategy picks among unweighted transitions
+	fallback := NewWorkflowRuntimeEngine()
+	zeroWeights := newWeightedDefinition(map[layer0.TransitionID]float64{"variant-a": 0, "variant-b": 0, "variant-c": 0})
+	if counts := runInstances(fallback, zeroWeights, 10); counts["control"] != 10 {
+		t.Errorf("Expected every instance to take the unweighted control, got %v", counts)
+	}
+}
+
+func TestWorkflowRuntimeEngineStateElapsedCondition(t *testing.T) {
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("waiting", layer0.StateTypeInitial, "Waiting"))
+	stateMachine.AddState(layer0.NewState("escalated", layer0.StateTypeFinal, "Escalated"))
+	stateMachine.AddTransition(layer0.NewTransition("escalate", layer0.TransitionTypeConditional, "waiting", "escalated", "Escalate").
+		AddCondition("sla-breached"))
+
+	definition := layer1.NewWorkflowDefinition("sla-workflow", "1.0.0", "SLA Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("waiting").
+		AddFinalStateID("escalated").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	engine := NewWorkflowRuntimeEngine()
+	clock := NewManualClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	engine.SetClock(clock)
+
+	condition := layer0.NewCondition("sla-breached", layer0.ConditionTypeTime, "SLA Breached")
+	condition.Expression.Variables[layer1.StateElapsedThresholdVariable] = "10m"
+	evaluator := NewDefaultTransitionEvaluator()
+	if err := evaluator.DefineCondition(condition, layer1.NewStateElapsedEvaluator(clock.Now)); err != nil {
+		t.Fatalf("DefineCondition should not return error: %v", err)
+	}
+	engine.SetTransitionEvaluator(evaluator)
+
+	instanceID, err := engine.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.StateEnteredAt == nil || !instance.StateEnteredAt.Equal(clock.Now()) {
+		t.Fatalf("Expected the state entry time to be recorded at start, got %v", instance.StateEnteredAt)
+	}
+
+	// Shortly after entry the condition does not hold
+	clock.Advance(time.Minute)
+	if err := engine.ExecuteStep(instanceID); err == nil {
+		t.Fatal("Expected no transition before the threshold")
+	}
+
+	clock.Advance(10 * time.Minute)
+	if err := engine.ExecuteStep(instanceID); err != nil {
+		t.Fatalf("ExecuteStep should not return error once the threshold passed: %v", err)
+	}
+	instance, _ = engine.GetWorkflowInstance(instanceID)
+	if instance.CurrentStateID != "escalated" || !instance.StateEnteredAt.Equal(clock.Now()) {
+		t.Errorf("Expected to enter escalated now, got %s at %v", instance.CurrentStateID, instance.StateEnteredAt)
+	}
+	if _, exists := instance.Context.Get(layer1.StateEnteredAtKey); exists {
+		t.Error("Expected the entry time to stay out of the instance context")
+	}
+}
+
+// concurrencyTrackingEvaluator records the most transition evaluations running at once
+type concurrencyTrackingEvaluator struct {
+	TransitionEvaluator
+	running    int
+	maxRunning int
+	mutex      sync.Mutex
+}
+
+func (evaluator *concurrencyTrackingEvaluator) CanTransition(transition layer0.Transition, workContext *layer0.Context) (bool, error) {
+	evaluator.mutex.Lock()
+	evaluator.running++
+	if evaluator.running > evaluator.maxRunning {
+		evaluator.maxRunning = evaluator.running
+	}
+	evaluator.mutex.Unlock()
+
+	time.Sleep(2 * time.Millisecond)
+
+	evaluator.mutex.Lock()
+	evaluator.running--
+	evaluator.mutex.Unlock()
+	return evaluator.TransitionEvaluator.CanTransition(transition, workContext)
+}
+
+func TestWorkflowRuntimeEngineStepConcurrency(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	metrics := NewMetricsRegistry()
+	engine.SetMetricsRegistry(metrics)
+	if err := engine.SetStepConcurrency(2); err != nil {
+		t.Fatalf("SetStepConcurrency should not return error: %v", err)
+	}
+
+	// Each step evaluates a transition that holds its slot briefly and tracks how many run at once
+	evaluator := &concurrencyTrackingEvaluator{TransitionEvaluator: NewDefaultTransitionEvaluator()}
+	engine.SetTransitionEvaluator(evaluator)
+
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("one", layer0.StateTypeInitial, "One"))
+	stateMachine.AddState(layer0.NewState("two", layer0.StateTypeIntermediate, "Two"))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	stateMachine.AddTransition(layer0.NewTransition("one-to-two", layer0.TransitionTypeAutomatic, "one", "two", "One to Two"))
+	stateMachine.AddTransition(layer0.NewTransition("two-to-end", layer0.TransitionTypeAutomatic, "two", "end", "Two to End"))
+	definition := layer1.NewWorkflowDefinition("fair-workflow", "1.0.0", "Fair Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("one").
+		AddFinalStateID("end").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	// More runnable instances than slots all run to completion
+	const instances = 8
+	instanceIDs := make([]WorkflowInstanceID, instances)
+	for i := range instanceIDs {
+		instanceID, err := engine.StartWorkflowWithOptions(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"),
+			StartOptions{InstanceID: WorkflowInstanceID(fmt.Sprintf("fair-%d", i))})
+		if err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+		instanceIDs[i] = instanceID
+	}
+
+	var group sync.WaitGroup
+	errs := make(chan error, instances)
+	for _, instanceID := range instanceIDs {
+		group.Add(1)
+		go func(instanceID WorkflowInstanceID) {
+			defer group.Done()
+			errs <- engine.ExecuteWorkflow(instanceID)
+		}(instanceID)
+	}
+	group.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+		}
+	}
+
+	for _, instanceID := range instanceIDs {
+		if history := engine.GetExecutionHistory(instanceID); len(history) != 2 {
+			t.Errorf("Expected instance %s to advance through 2 transitions, got %d", instanceID, len(history))
+		}
+	}
+	if evaluator.maxRunning > 2 {
+		t.Errorf("Expected at most 2 steps at once, got %d", evaluator.maxRunning)
+	}
+	if queued := engine.QueuedSteps(); queued != 0 {
+		t.Errorf("Expected no queued steps once all finished, got %d", queued)
+	}
+
+	// Every step, including the final-state check, records its wait
+	var buffer strings.Builder
+	metrics.WriteMetrics(&buffer)
+	if expected := `workflow_step_wait_seconds_count{definition_id="fair-workflow"} 24`; !strings.Contains(buffer.String(), expected+"\n") {
+		t.Errorf("Expected metrics output to contain %q, got:\n%s", expected, buffer.String())
+	}
+
+	if err := engine.SetStepConcurrency(-1); err == nil {
+		t.Error("SetStepConcurrency should reject a negative limit")
+	}
+}
+
+func newApprovalDefinition() layer1.WorkflowDefinition {
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	stateMachine.AddState(layer0.NewState("review", layer0.StateTypeIntermediate, "Review"))
+	stateMachine.AddState(layer0.NewState("approved", layer0.StateTypeFinal, "Approved"))
+	stateMachine.AddState(layer0.NewState("rejected", layer0.StateTypeFinal, "Rejected"))
+	stateMachine.AddTransition(layer0.NewTransition("start-to-review", layer0.TransitionTypeAutomatic, "start", "review", "Request Approval").
+		AddAction("approve"))
+	stateMachine.AddTransition(layer0.NewTransition("review-to-approved", layer0.TransitionTypeConditional, "review", "approved", "Approved").
+		AddCondition("approve_outcome_approved"))
+	stateMachine.AddTransition(layer0.NewTransition("review-to-rejected", layer0.TransitionTypeConditional, "review", "rejected", "Rejected").
+		AddCondition("approve_outcome_rejected"))
+
+	return layer1.NewWorkflowDefinition("approval-workflow", "1.0.0", "Approval Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("approved").
+		AddFinalStateID("rejected").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+}
+
+func TestWorkflowRuntimeEngineHumanTask(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	clock := NewManualClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	engine.SetClock(clock)
+
+	work := layer0.NewWork("approve", layer0.WorkTypeHumanTask, "Approve")
+	work.Configuration.Parameters[layer1.HumanTaskExpiryParameter] = "1h"
+	work.Configuration.Parameters[layer1.HumanTaskOutcomesParameter] = []string{"approve_outcome_approved", "approve_outcome_rejected"}
+	engine.RegisterActionWork("approve", work)
+
+	startApproval := func() (WorkflowInstanceID, HumanTask) {
+		instanceID, err := engine.StartWorkflow(newApprovalDefinition(), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+		if err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+		if err := engine.ExecuteWorkflow(instanceID); err != nil {
+			t.Fatalf("ExecuteWorkflow should not return error for a human task: %v", err)
+		}
+
+		instance, _ := engine.GetWorkflowInstance(instanceID)
+		if instance.Status != WorkflowInstanceStatusWaiting {
+			t.Fatalf("Expected the instance to wait for the human task, got %s", instance.Status)
+		}
+		tasks := engine.HumanTasks(instanceID)
+		if len(tasks) != 1 || tasks[0].WorkID != "approve" || tasks[0].Token == "" {
+			t.Fatalf("Expected one human task with a token, got %+v", tasks)
+		}
+		return instanceID, tasks[0]
+	}
+
+	// Completion merges the payload and follows the chosen outcome
+	instanceID, task := startApproval()
+	if err := engine.CompleteHumanTask(task.Token, "approve_outcome_maybe", nil); err == nil {
+		t.Error("CompleteHumanTask should reject an undeclared outcome")
+	}
+	if err := engine.CompleteHumanTask(task.Token, "approve_outcome_rejected", map[string]interface{}{"reviewer": "kim"}); err != nil {
+		t.Fatalf("CompleteHumanTask should not return error: %v", err)
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.Status != WorkflowInstanceStatusCompleted || instance.CurrentStateID != "rejected" {
+		t.Errorf("Expected completion in rejected, got %s in %s", instance.Status, instance.CurrentStateID)
+	}
+	if reviewer, _ := instance.Context.Get("reviewer"); reviewer != "kim" {
+		t.Errorf("Expected the payload in context, got %v", reviewer)
+	}
+
+	// Tokens are single use
+	if err := engine.CompleteHumanTask(task.Token, "approve_outcome_approved", nil); !errors.Is(err, ErrInvalidHumanTaskToken) {
+		t.Errorf("Expected a reused token to be rejected, got %v", err)
+	}
+	if err := engine.CompleteHumanTask("forged", "approve_outcome_approved", nil); !errors.Is(err, ErrInvalidHumanTaskToken) {
+		t.Errorf("Expected an unknown token to be rejected, got %v", err)
+	}
+
+	// Tokens expire
+	instanceID, task = startApproval()
+	clock.Advance(time.Hour)
+	if err := engine.CompleteHumanTask(task.Token, "approve_outcome_approved", nil); !errors.Is(err, ErrHumanTaskTokenExpired) {
+		t.Errorf("Expected an expired token to be rejected, got %v", err)
+	}
+	if instance, _ := engine.GetWorkflowInstance(instanceID); instance.Status != WorkflowInstanceStatusWaiting {
+		t.Errorf("Expected the instance to keep waiting after an expired token, got %s", instance.Status)
+	}
+	if len(engine.HumanTasks(instanceID)) != 0 {
+		t.Error("An expired token should be discarded")
+	}
+}
+
+func TestWorkflowRuntimeEngineTransitionRecoveryPolicy(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+
+	var charges, refunds int
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		if w.GetID() == "refund" {
+			refunds++
+			return "refunded", nil
+		}
+		charges++
+		return nil, fmt.Errorf("card declined")
+	}))
+
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	stateMachine.AddState(layer0.NewState("payment-failed", layer0.StateTypeError, "Payment Failed"))
+	if err := stateMachine.AddTransition(layer0.NewTransition("charge", layer0.TransitionTypeAutomatic, "start", "end", "Charge").
+		AddAction("charge").
+		SetRecoveryPolicy(layer0.TransitionRecoveryPolicy{Retry: 2, Compensation: []string{"refund"}, OnExhausted: "payment-failed"})); err != nil {
+		t.Fatalf("AddTransition should not return error: %v", err)
+	}
+
+	definition := layer1.NewWorkflowDefinition("payment-workflow", "1.0.0", "Payment Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("end").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	instanceID, err := engine.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	// The transition is tried three times, compensated once, then routed to the error state
+	if charges != 3 || refunds != 1 {
+		t.Errorf("Expected 3 charges and 1 refund, got %d and %d", charges, refunds)
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.Status != WorkflowInstanceStatusFailed || instance.CurrentStateID != "payment-failed" {
+		t.Errorf("Expected failure in payment-failed, got %s in %s", instance.Status, instance.CurrentStateID)
+	}
+	if output, _ := instance.Context.Get("work_refund_output"); output != "refunded" {
+		t.Errorf("Expected the compensation output in context, got %v", output)
+	}
+}
+
+func TestWorkflowRuntimeEnginePauseWorkflowUntil(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	engine := NewWorkflowRuntimeEngine()
+	engine.SetClock(clock)
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End")
+	start := func() WorkflowInstanceID {
+		instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+		if err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+		return instanceID
+	}
+
+	resumed := start()
+	if err := engine.PauseWorkflowUntil(resumed, clock.Now().Add(time.Minute), "later"); err == nil {
+		t.Error("PauseWorkflowUntil should reject an unknown expiry action")
+	}
+	if err := engine.PauseWorkflowUntil(resumed, clock.Now().Add(time.Minute), PauseExpiryResume); err != nil {
+		t.Fatalf("PauseWorkflowUntil should not return error: %v", err)
+	}
+	failed := start()
+	engine.PauseWorkflowUntil(failed, clock.Now().Add(time.Minute), PauseExpiryFail)
+
+	// The deadline is persisted for restart recovery
+	if persisted, _ := engine.persistenceStore.GetWorkflowInstance(resumed); persisted.PauseDeadline == nil || persisted.Status != WorkflowInstanceStatusPaused {
+		t.Fatalf("Expected a persisted pause deadline, got %v (%s)", persisted.PauseDeadline, persisted.Status)
+	}
+
+	if expired, _ := engine.ProcessStateTimeouts(clock.Now()); expired != 0 {
+		t.Errorf("Expected no expired pauses before the deadline, got %d", expired)
+	}
+
+	clock.Advance(time.Minute)
+	if expired, err := engine.ProcessStateTimeouts(clock.Now()); err != nil || expired != 2 {
+		t.Fatalf("Expected 2 expired pauses, got %d (%v)", expired, err)
+	}
+
+	// The resumed instance continues executing to completion
+	instance, _ := engine.GetWorkflowInstance(resumed)
+	if instance.Status != WorkflowInstanceStatusCompleted || instance.PauseDeadline != nil {
+		t.Errorf("Expected the instance to auto-resume and complete, got %s (deadline %v)", instance.Status, instance.PauseDeadline)
+	}
+
+	if instance, _ := engine.GetWorkflowInstance(failed); instance.Status != WorkflowInstanceStatusFailed {
+		t.Errorf("Expected the instance to fail at its deadline, got %s", instance.Status)
+	}
+
+	// Resuming early cancels the deadline
+	early := start()
+	engine.PauseWorkflowUntil(early, clock.Now().Add(time.Minute), PauseExpiryFail)
+	if err := engine.ResumeWorkflow(early); err != nil {
+		t.Fatalf("ResumeWorkflow should not return error: %v", err)
+	}
+	clock.Advance(time.Minute)
+	if expired, _ := engine.ProcessStateTimeouts(clock.Now()); expired != 0 {
+		t.Errorf("Expected a resumed instance's deadline to be cancelled, got %d expired", expired)
+	}
+}
+
+func TestWorkflowRuntimeEngineListActiveWorkflowsDetailed(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End")
+
+	for index := 0; index < 3; index++ {
+		if _, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context")); err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+	}
+	instanceIDs := engine.ListActiveWorkflows()
+	engine.PauseWorkflow(instanceIDs[1])
+
+	summaries := engine.ListActiveWorkflowsDetailed()
+	if len(summaries) != len(instanceIDs) {
+		t.Fatalf("Expected %d summaries, got %d", len(instanceIDs), len(summaries))
+	}
+
+	for index, summary := range summaries {
+		instance, _ := engine.GetWorkflowInstance(instanceIDs[index])
+		if summary.ID != instance.ID || summary.DefinitionID != instance.DefinitionID || summary.DefinitionVersion != instance.DefinitionVersion {
+			t.Errorf("Expected summary of %s %s %s, got %+v", instance.ID, instance.DefinitionID, instance.DefinitionVersion, summary)
+		}
+		if summary.Status != instance.Status || summary.CurrentStateID != instance.CurrentStateID {
+			t.Errorf("Expected %s in %s, got %s in %s", instance.Status, instance.CurrentStateID, summary.Status, summary.CurrentStateID)
+		}
+		if summary.StartedAt == nil || !summary.StartedAt.Equal(*instance.StartedAt) {
+			t.Errorf("Expected started at %v, got %v", instance.StartedAt, summary.StartedAt)
+		}
+	}
+
+	if summaries[1].Status != WorkflowInstanceStatusPaused {
+		t.Errorf("Expected the paused instance to be listed as paused, got %s", summaries[1].Status)
+	}
+}
+
+func TestWorkflowRuntimeEngineCancelDuringStep(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		close(started)
+		<-release
+		return "charged", nil
+	}))
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("charge")
+	instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	// The instance is cancelled while the step's action runs
+	stepErr := make(chan error, 1)
+	go func() {
+		stepErr <- engine.ExecuteStep(instanceID)
+	}()
+	<-started
+	if err := engine.CancelWorkflow(instanceID); err != nil {
+		t.Fatalf("CancelWorkflow should not return error: %v", err)
+	}
+	close(release)
+
+	if err := <-stepErr; !errors.Is(err, ErrInstanceNotRunning) {
+		t.Errorf("Expected the step to abort with ErrInstanceNotRunning, got %v", err)
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.Status != WorkflowInstanceStatusCancelled || instance.CurrentStateID != "start" {
+		t.Errorf("Expected the cancelled instance to stay in start, got %s in %s", instance.Status, instance.CurrentStateID)
+	}
+	if len(engine.ListActiveWorkflows()) != 0 {
+		t.Error("The aborted step should not restore the cancelled instance")
+	}
+}
+
+func TestWorkflowRuntimeEngineCancelRacesStep(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		return "done", nil
+	}))
+
+	for index := 0; index < 20; index++ {
+		action := fmt.Sprintf("action-%d", index)
+		transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+			AddAction(action)
+		instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+		if err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			engine.ExecuteStep(instanceID)
+		}()
+		go func() {
+			defer wg.Done()
+			engine.CancelWorkflow(instanceID)
+		}()
+		wg.Wait()
+
+		// Whichever finished first, a commit never overwrites the cancellation
+		if instance, _ := engine.GetWorkflowInstance(instanceID); instance.Status != WorkflowInstanceStatusCancelled {
+			t.Fatalf("Expected instance %d to stay cancelled, got %s in %s", index, instance.Status, instance.CurrentStateID)
+		}
+	}
+}
+
+func TestWorkflowRuntimeEngineInstanceEnrichers(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	if err := engine.AddInstanceEnricher(nil); err == nil {
+		t.Error("AddInstanceEnricher should reject a nil enricher")
+	}
+
+	// The tenant label is derived from the initial context
+	engine.AddInstanceEnricher(func(instance *WorkflowInstance) error {
+		if tenant, exists := instance.Context.Get("tenant"); exists {
+			instance.Labels["tenant"] = fmt.Sprint(tenant)
+		}
+		instance.CurrentStateID = "end"
+		return nil
+	})
+	// Later enrichers see the changes of earlier ones
+	engine.AddInstanceEnricher(func(instance *WorkflowInstance) error {
+		instance.Metadata["source"] = "tenant:" + instance.Labels["tenant"]
+		return nil
+	})
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End")
+	instanceID, err := engine.StartWorkflowWithOptions(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").Set("tenant", "acme"),
+		StartOptions{Labels: map[string]string{"team": "billing"}})
+	if err != nil {
+		t.Fatalf("StartWorkflowWithOptions should not return error: %v", err)
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.Labels["tenant"] != "acme" || instance.Labels["team"] != "billing" {
+		t.Errorf("Expected tenant and team labels, got %v", instance.Labels)
+	}
+	if instance.Metadata["source"] != "tenant:acme" {
+		t.Errorf("Expected enrichers to run in order, got source %v", instance.Metadata["source"])
+	}
+	if instance.CurrentStateID != "start" {
+		t.Errorf("Expected changes outside metadata and labels to be discarded, got state %s", instance.CurrentStateID)
+	}
+
+	engine.AddInstanceEnricher(func(instance *WorkflowInstance) error {
+		return fmt.Errorf("tenant lookup unavailable")
+	})
+	if _, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context")); err == nil {
+		t.Error("StartWorkflow should fail when an enricher fails")
+	}
+}
+
+func TestWorkflowRuntimeEngineIntrospect(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	executor := layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask, layer0.WorkTypeService}, nil)
+	engine.RegisterExecutor(layer0.WorkTypeTask, executor)
+	engine.RegisterExecutor(layer0.WorkTypeService, executor)
+	engine.RegisterConditionEvaluator(layer0.ConditionTypeScript, layer1.NewMockConditionEvaluator([]layer0.ConditionType{layer0.ConditionTypeScript}, nil))
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End")
+	instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	topology := engine.Introspect()
+
+	executors := make(map[layer0.WorkType]ExecutorTopology)
+	for _, registered := range topology.Executors {
+		executors[registered.WorkType] = registered
+	}
+	for _, workType := range []layer0.WorkType{layer0.WorkTypeTask, layer0.WorkTypeService, layer0.WorkTypeHumanTask} {
+		if _, ok := executors[workType]; !ok {
+			t.Errorf("Expected an executor for %s in %+v", workType, topology.Executors)
+		}
+	}
+	if task := executors[layer0.WorkTypeTask]; task.Executor != "*layer1.MockWorkExecutor" || len(task.SupportedTypes) != 2 {
+		t.Errorf("Expected the mock executor with 2 supported types, got %+v", task)
+	}
+
+	found := false
+	for _, registered := range topology.Evaluators {
+		found = found || registered.ConditionType == layer0.ConditionTypeScript
+	}
+	if !found {
+		t.Errorf("Expected the script evaluator in %+v", topology.Evaluators)
+	}
+
+	if len(topology.ActiveInstances) != 1 || topology.ActiveInstances[0].ID != instanceID {
+		t.Errorf("Expected instance %s to be active, got %+v", instanceID, topology.ActiveInstances)
+	}
+	if topology.StoreStats == nil || topology.StoreError != "" {
+		t.Errorf("Expected store stats, got %v (%s)", topology.StoreStats, topology.StoreError)
+	}
+
+	// An unreachable store is reported without failing the call
+	engine.SetPersistenceStore(unreachableStore{NewInMemoryStatePersistenceStore()})
+	if topology := engine.Introspect(); topology.StoreError == "" || len(topology.Executors) == 0 {
+		t.Errorf("Expected the store error alongside the registered components, got %+v", topology)
+	}
+}
+
+// cancellableConditionEvaluator blocks until its evaluation is cancelled
+type cancellableConditionEvaluator struct {
+	*layer1.MockConditionEvaluator
+	started   chan struct{}
+	cancelled chan struct{}
+}
+
+func (evaluator cancellableConditionEvaluator) EvaluateWithContext(ctx context.Context, condition layer0.Condition, context *layer0.Context) (interface{}, error) {
+	close(evaluator.started)
+	<-ctx.Done()
+	close(evaluator.cancelled)
+	return nil, ctx.Err()
+}
+
+func TestWorkflowRuntimeEngineConcurrentConditionEvaluation(t *testing.T) {
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	stateMachine.AddState(layer0.NewState("fast", layer0.StateTypeFinal, "Fast"))
+	stateMachine.AddState(layer0.NewState("slow", layer0.StateTypeFinal, "Slow"))
+	toFast := layer0.NewTransition("start-to-fast", layer0.TransitionTypeAutomatic, "start", "fast", "Start to Fast").AddCondition("fast-check")
+	toFast.Priority = 2
+	stateMachine.AddTransition(toFast)
+	stateMachine.AddTransition(layer0.NewTransition("start-to-slow", layer0.TransitionTypeAutomatic, "start", "slow", "Start to Slow").AddCondition("slow-check"))
+	definition := layer1.NewWorkflowDefinition("race-workflow", "1.0.0", "Race Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("fast").
+		AddFinalStateID("slow").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	slow := cancellableConditionEvaluator{
+		MockConditionEvaluator: layer1.NewMockConditionEvaluator([]layer0.ConditionType{layer0.ConditionTypeService}, nil),
+		started:                make(chan struct{}),
+		cancelled:              make(chan struct{}),
+	}
+	// The fast condition holds only once the slow one is running, so both are in flight
+	fast := layer1.NewMockConditionEvaluator([]layer0.ConditionType{layer0.ConditionTypeScript}, func(c layer0.Condition, ctx *layer0.Context) (interface{}, error) {
+		<-slow.started
+		return true, nil
+	})
+
+	evaluator := NewDefaultTransitionEvaluator()
+	evaluator.DefineCondition(layer0.NewCondition("fast-check", layer0.ConditionTypeScript, "Fast Check"), fast)
+	evaluator.DefineCondition(layer0.NewCondition("slow-check", layer0.ConditionTypeService, "Slow Check"), slow)
+
+	engine := NewWorkflowRuntimeEngine()
+	engine.SetTransitionEvaluator(evaluator)
+	engine.SetConcurrentConditionEvaluation(true)
+
+	instanceID, err := engine.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.ExecuteStep(instanceID); err != nil {
+		t.Fatalf("ExecuteStep should not return error: %v", err)
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.CurrentStateID != "fast" {
+		t.Errorf("Expected the fast transition to be taken, got %s", instance.CurrentStateID)
+	}
+
+	select {
+	case <-slow.cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the slow evaluation to be cancelled once the fast transition was chosen")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for evaluator.conditionEvaluationCore.IsConditionEvaluating("slow-check") {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the cancelled evaluation to be released")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWorkflowRuntimeEngineFallbackExecutor(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	engine.SetFallbackExecutor(layer1.NewMockWorkExecutor(nil, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		return "queued for manual handling", nil
+	}))
+	engine.RegisterActionWork("ship", layer0.NewWork("ship", "shipment", "Ship"))
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").AddAction("ship")
+	instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	if err := engine.ExecuteStep(instanceID); err != nil {
+		t.Fatalf("ExecuteStep should not return error: %v", err)
+	}
+
+	history := engine.GetExecutionHistory(instanceID)
+	if len(history) != 1 || history[0].ToStateID != "end" || len(history[0].WorkResults) != 1 {
+		t.Fatalf("Expected the transition to complete with one work result, got %+v", history)
+	}
+	if work := history[0].WorkResults[0]; !work.HandledByFallback || work.Output != "queued for manual handling" {
+		t.Errorf("Expected the unregistered work type to be handled by the fallback, got %+v", work)
+	}
+}
+
+func TestWorkflowRuntimeEngineDisplayName(t *testing.T) {
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End")
+	definition := newParallelDefinition(transition)
+	configuration := definition.GetConfiguration()
+	configuration.DisplayNameTemplate = "Order ${order_id} for ${customer}"
+	definition = definition.UpdateConfiguration(configuration)
+
+	engine := NewWorkflowRuntimeEngine()
+	context := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").
+		Set("order_id", 1042).
+		Set("customer", "Acme")
+	instanceID, err := engine.StartWorkflow(definition, context)
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if name := instance.Metadata[DisplayNameMetadataKey]; name != "Order 1042 for Acme" {
+		t.Errorf("Expected display name 'Order 1042 for Acme', got %v", name)
+	}
+	if summaries := engine.ListActiveWorkflowsDetailed(); len(summaries) != 1 || summaries[0].DisplayName != "Order 1042 for Acme" {
+		t.Errorf("Expected the display name in the listing, got %+v", summaries)
+	}
+
+	// Unresolved references render blank unless the template is strict
+	partial := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").Set("order_id", 7)
+	instanceID, err = engine.StartWorkflow(definition, partial)
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	instance, _ = engine.GetWorkflowInstance(instanceID)
+	if name := instance.Metadata[DisplayNameMetadataKey]; name != "Order 7 for" {
+		t.Errorf("Expected display name 'Order 7 for', got %v", name)
+	}
+
+	configuration.StrictDisplayName = true
+	if _, err := engine.StartWorkflow(definition.UpdateConfiguration(configuration), partial); err == nil || !strings.Contains(err.Error(), "customer") {
+		t.Errorf("Expected the unresolved customer reference to fail the start, got %v", err)
+	}
+
+	// Instances without a template are named by their ID
+	instanceID, _ = engine.StartWorkflow(newParallelDefinition(transition), partial)
+	if instance, _ := engine.GetWorkflowInstance(instanceID); instance.DisplayName() != string(instanceID) {
+		t.Errorf("Expected the ID as display name, got %s", instance.DisplayName())
+	}
+}
+
+func TestWorkflowRuntimeEngineSubscribeWorkResults(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		return string(w.GetID()) + "-done", nil
+	}))
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("reserve").
+		AddAction("charge")
+	instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	events, cancel := engine.SubscribeWorkResults(instanceID)
+	other, cancelOther := engine.SubscribeWorkResults(instanceID)
+	cancelOther()
+	if _, open := <-other; open {
+		t.Error("Expected a cancelled subscription to be closed")
+	}
+
+	if err := engine.ExecuteStep(instanceID); err != nil {
+		t.Fatalf("ExecuteStep should not return error: %v", err)
+	}
+	// Finishing the instance ends the stream
+	if err := engine.ExecuteStep(instanceID); err != nil {
+		t.Fatalf("ExecuteStep should not return error: %v", err)
+	}
+
+	var received []WorkResultEvent
+	for event := range events {
+		received = append(received, event)
+	}
+	if len(received) != 2 {
+		t.Fatalf("Expected an event per executed action, got %+v", received)
+	}
+	for index, workID := range []layer0.WorkID{"reserve", "charge"} {
+		event := received[index]
+		if event.InstanceID != instanceID || event.WorkID != workID || event.WorkType != layer0.WorkTypeTask {
+			t.Errorf("Expected an event for %s, got %+v", workID, event)
+		}
+		if !event.Succeeded || event.Output != string(workID)+"-done" {
+			t.Errorf("Expected %s to succeed with its output, got %+v", workID, event)
+		}
+	}
+	cancel()
+
+	// Subscribing to an instance that is no longer active yields a closed stream
+	finished, _ := engine.SubscribeWorkResults(instanceID)
+	if _, open := <-finished; open {
+		t.Error("Expected the stream of a finished instance to be closed")
+	}
+}
+
+func TestWorkflowRuntimeEngineDeterministicMode(t *testing.T) {
+	definition := newThreeWayDefinition([3]layer0.StateID{"x", "y", "z"}, [3]int{0, 0, 0}, layer1.TransitionSelectionRandom)
+	initialContext := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context")
+
+	// run executes several branching instances and returns their audit trail
+	run := func() ([]byte, []WorkflowInstanceID) {
+		engine := newThreeWayEngine()
+		clock := engine.EnableDeterministicMode(7)
+		log := NewCommandLog(NewInMemoryCommandSink())
+		engine.SetCommandLog(log)
+
+		var instanceIDs []WorkflowInstanceID
+		var histories [][]TransitionRecord
+		for index := 0; index < 5; index++ {
+			instanceID, err := engine.StartWorkflow(definition, initialContext.Clone())
+			if err != nil {
+				t.Fatalf("StartWorkflow should not return error: %v", err)
+			}
+			clock.Advance(time.Second)
+			if err := engine.ExecuteStep(instanceID); err != nil {
+				t.Fatalf("ExecuteStep should not return error: %v", err)
+			}
+			instanceIDs = append(instanceIDs, instanceID)
+			histories = append(histories, engine.GetExecutionHistory(instanceID))
+		}
+
+		commands, _ := log.Commands()
+		trail, err := json.Marshal(map[string]interface{}{"commands": commands, "histories": histories})
+		if err != nil {
+			t.Fatalf("Marshal should not return error: %v", err)
+		}
+		return trail, instanceIDs
+	}
+
+	first, instanceIDs := run()
+	second, _ := run()
+	if !bytes.Equal(first, second) {
+		t.Errorf("Expected identical audit trails, got\n%s\n%s", first, second)
+	}
+	if instanceIDs[0] != "three-way-workflow-1" || instanceIDs[4] != "three-way-workflow-5" {
+		t.Errorf("Expected sequential instance IDs, got %v", instanceIDs)
+	}
+}
+
+// nonIdempotentExecutor is a mock executor declaring that its work must not be repeated
+type nonIdempotentExecutor struct {
+	*layer1.MockWorkExecutor
+}
+
+func (ne *nonIdempotentExecutor) Capabilities() layer1.ExecutorCapabilities {
+	return layer1.ExecutorCapabilities{RequiresNetwork: true}
+}
+
+func TestWorkflowRuntimeEngineExecutorCapabilities(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+
+	attempts := map[layer0.WorkID]int{}
+	failing := func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		attempts[w.GetID()]++
+		if attempts[w.GetID()] == 1 {
+			return nil, errors.New("downstream unavailable")
+		}
+		return "ok", nil
+	}
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, failing))
+	engine.RegisterExecutor(layer0.WorkTypeService, &nonIdempotentExecutor{
+		MockWorkExecutor: layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeService}, failing),
+	})
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End")
+	for _, workType := range []layer0.WorkType{layer0.WorkTypeTask, layer0.WorkTypeService} {
+		work := layer0.NewWork(layer0.WorkID(workType), workType, string(workType))
+		work.Configuration.RetryCount = 3
+		work.Configuration.RetryDelaySeconds = 0
+		engine.RegisterActionWork(string(workType), work)
+		transition = transition.AddAction(string(workType))
+	}
+
+	definition := newParallelDefinition(transition)
+	config := definition.GetConfiguration()
+	config.RetryPolicy.InstanceBudget = 5
+	definition = definition.UpdateConfiguration(config)
+
+	instanceID, err := engine.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.ExecuteWorkflow(instanceID); err == nil {
+		t.Fatal("Expected the non-idempotent work to fail the transition")
+	}
+
+	// The idempotent task was retried; the service work declaring otherwise ran once
+	if attempts["task"] != 2 || attempts["service"] != 1 {
+		t.Errorf("Expected 2 task attempts and 1 service attempt, got %v", attempts)
+	}
+
+	expected := map[layer0.WorkType]layer1.ExecutorCapabilities{
+		layer0.WorkTypeTask:    {Idempotent: true},
+		layer0.WorkTypeService: {RequiresNetwork: true},
+	}
+	for _, executor := range engine.Introspect().Executors {
+		if capabilities, exists := expected[executor.WorkType]; exists && executor.Capabilities != capabilities {
+			t.Errorf("Expected %s capabilities %+v, got %+v", executor.WorkType, capabilities, executor.Capabilities)
+		}
+	}
+}
+
+func TestWorkflowRuntimeEngineContextDefaults(t *testing.T) {
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeConditional, "start", "end", "Start to End").
+		AddCondition("express")
+	original := newParallelDefinition(transition)
+
+	engine := NewWorkflowRuntimeEngine()
+	instanceID, err := engine.StartWorkflow(original, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").Set("customer", "Acme"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.PauseWorkflow(instanceID); err != nil {
+		t.Fatalf("PauseWorkflow should not return error: %v", err)
+	}
+
+	// A later version introduces the express key with a default
+	evolved := newParallelDefinition(transition)
+	evolved.Version = "1.1.0"
+	configuration := evolved.GetConfiguration()
+	configuration.ContextDefaults = map[string]interface{}{"express": true, "customer": "unknown"}
+	evolved = evolved.UpdateConfiguration(configuration)
+	if _, err := engine.StartWorkflow(evolved, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").Set("express", false)); err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	if err := engine.ResumeWorkflow(instanceID); err != nil {
+		t.Fatalf("ResumeWorkflow should not return error: %v", err)
+	}
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if express, _ := instance.Context.Get("express"); express != true {
+		t.Errorf("Expected the resumed instance to get the express default, got %v", express)
+	}
+	if customer, _ := instance.Context.Get("customer"); customer != "Acme" {
+		t.Errorf("Expected defaults to leave existing keys unchanged, got %v", customer)
+	}
+
+	// The default satisfies the condition the original instance could not
+	if err := engine.ExecuteStep(instanceID); err != nil {
+		t.Fatalf("ExecuteStep should not return error: %v", err)
+	}
+	if instance, _ := engine.GetWorkflowInstance(instanceID); instance.CurrentStateID != "end" {
+		t.Errorf("Expected the instance to transition to end, got %s", instance.CurrentStateID)
+	}
+}
+
+func TestWorkflowRuntimeEngineStatusGuard(t *testing.T) {
+	store := NewInMemoryStatePersistenceStore()
+	engine := NewWorkflowRuntimeEngine()
+	engine.SetPersistenceStore(store)
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeConditional, "start", "end", "Start to End").
+		AddCondition("ready")
+	instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	if err := engine.PauseWorkflow(instanceID); err != nil {
+		t.Fatalf("PauseWorkflow should not return error: %v", err)
+	}
+	if persisted, _ := store.GetWorkflowInstance(instanceID); persisted.Status != WorkflowInstanceStatusPaused {
+		t.Errorf("Expected the store to hold paused, got %s", persisted.Status)
+	}
+
+	// Another replica sharing the store cancels the instance
+	if err := store.CompareAndSetStatus(instanceID, WorkflowInstanceStatusPaused, WorkflowInstanceStatusCancelled); err != nil {
+		t.Fatalf("CompareAndSetStatus should not return error: %v", err)
+	}
+
+	if err := engine.ResumeWorkflow(instanceID); !errors.Is(err, ErrStatusConflict) {
+		t.Errorf("Expected resuming a cancelled instance to conflict, got %v", err)
+	}
+	if instance, _ := engine.GetWorkflowInstance(instanceID); instance.Status != WorkflowInstanceStatusPaused {
+		t.Errorf("A conflicting resume should leave the instance paused, got %s", instance.Status)
+	}
+	if persisted, _ := store.GetWorkflowInstance(instanceID); persisted.Status != WorkflowInstanceStatusCancelled {
+		t.Errorf("A conflicting resume should not overwrite the store, got %s", persisted.Status)
+	}
+}
+
+// remoteBackend is a fake execution backend standing in for remote workers
+type remoteBackend struct {
+	mutex      sync.Mutex
+	dispatched []layer0.WorkID
+}
+
+func (backend *remoteBackend) Execute(ctx context.Context, work layer0.Work, workContext *layer0.Context) (layer1.WorkExecutionResult, error) {
+	backend.mutex.Lock()
+	backend.dispatched = append(backend.dispatched, work.GetID())
+	backend.mutex.Unlock()
+
+	return layer1.WorkExecutionResult{
+		WorkID: work.GetID(),
+		Status: layer0.WorkStatusCompleted,
+		Output: fmt.Sprintf("ran %s remotely", work.GetID()),
+	}, nil
+}
+
+func TestWorkflowRuntimeEngineExecutionBackend(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	backend := &remoteBackend{}
+	engine.SetExecutionBackend(backend)
+
+	// The build work type has no local executor; only the backend can run it
+	engine.RegisterActionWork("build", layer0.NewWork("build", "build", "Build"))
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").AddAction("build")
+	instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	if len(backend.dispatched) != 1 || backend.dispatched[0] != "build" {
+		t.Errorf("Expected the build work to be dispatched to the backend, got %v", backend.dispatched)
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if output, _ := instance.Context.Get("work_build_output"); output != "ran build remotely" {
+		t.Errorf("Expected the remote output in the context, got %v", output)
+	}
+
+	// Restoring the default runs work on the local executors again
+	engine.SetExecutionBackend(nil)
+	instanceID, _ = engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err := engine.ExecuteWorkflow(instanceID); err == nil {
+		t.Error("Expected the local backend to fail work without a registered executor")
+	}
+	if len(backend.dispatched) != 1 {
+		t.Errorf("Expected no further dispatches to the remote backend, got %v", backend.dispatched)
+	}
+}
+
+func TestWorkflowRuntimeEngineSLAStatus(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	engine.SetClock(clock)
+
+	withSLA := func(transition layer0.Transition) layer1.WorkflowDefinition {
+		definition := newParallelDefinition(transition)
+		configuration := definition.GetConfiguration()
+		configuration.SLA = time.Hour
+		return definition.UpdateConfiguration(configuration)
+	}
+
+	// The instance waits on a manual transition that is never triggered
+	signal := layer0.NewTransition("await-signal", layer0.TransitionTypeManual, "start", "end", "Await Signal")
+	instanceID, err := engine.StartWorkflow(withSLA(signal), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	clock.Advance(20 * time.Minute)
+	status, err := engine.GetSLAStatus(instanceID)
+	if err != nil {
+		t.Fatalf("GetSLAStatus should not return error: %v", err)
+	}
+	if status.Breached || status.Elapsed != 20*time.Minute || status.Remaining != 40*time.Minute {
+		t.Errorf("Expected 40m remaining within the SLA, got %+v", status)
+	}
+	if breached, _ := engine.ProcessSLABreaches(clock.Now()); breached != 0 {
+		t.Errorf("Expected no breaches within the SLA, got %d", breached)
+	}
+
+	clock.Advance(time.Hour)
+	if status, _ := engine.GetSLAStatus(instanceID); !status.Breached || status.Remaining != 0 || status.Elapsed != 80*time.Minute {
+		t.Errorf("Expected the instance to breach its SLA after 1h20m, got %+v", status)
+	}
+	if breached, err := engine.ProcessSLABreaches(clock.Now()); err != nil || breached != 1 {
+		t.Fatalf("Expected 1 breached instance, got %d (%v)", breached, err)
+	}
+
+	// A breach is only reported once
+	clock.Advance(time.Hour)
+	if breached, _ := engine.ProcessSLABreaches(clock.Now()); breached != 0 {
+		t.Errorf("Expected the breach to be reported once, got %d more", breached)
+	}
+
+	var breachEvents []WorkflowLifecycleEvent
+	for _, event := range engine.lifecycleManager.GetEvents(instanceID) {
+		if event.EventType == "workflow_sla_breached" {
+			breachEvents = append(breachEvents, event)
+		}
+	}
+	if len(breachEvents) != 1 || breachEvents[0].Data["elapsed"] != (80*time.Minute).String() {
+		t.Errorf("Expected one workflow_sla_breached event after 1h20m, got %v", breachEvents)
+	}
+
+	// A finished instance's status is fixed at its completion
+	automatic := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End")
+	instanceID, err = engine.StartWorkflow(withSLA(automatic), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	clock.Advance(45 * time.Minute)
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	clock.Advance(time.Hour)
+	status, _ = engine.GetSLAStatus(instanceID)
+	if !status.Final || status.Breached || status.Elapsed != 45*time.Minute || status.Remaining != 15*time.Minute {
+		t.Errorf("Expected a final status 45m into the SLA, got %+v", status)
+	}
+}
+
+// writeRecordingStore records the instances every store write was made for
+type writeRecordingStore struct {
+	*InMemoryStatePersistenceStore
+	writes []WorkflowInstanceID
+}
+
+func (store *writeRecordingStore) SaveWorkflowInstance(instance WorkflowInstance) error {
+	store.writes = append(store.writes, instance.ID)
+	return store.InMemoryStatePersistenceStore.SaveWorkflowInstance(instance)
+}
+
+func (store *writeRecordingStore) UpdateWorkflowInstance(instance WorkflowInstance) error {
+	store.writes = append(store.writes, instance.ID)
+	return store.InMemoryStatePersistenceStore.UpdateWorkflowInstance(instance)
+}
+
+func (store *writeRecordingStore) CompareAndSetStatus(instanceID WorkflowInstanceID, expected, status WorkflowInstanceStatus) error {
+	store.writes = append(store.writes, instanceID)
+	return store.InMemoryStatePersistenceStore.CompareAndSetStatus(instanceID, expected, status)
+}
+
+func (store *writeRecordingStore) SaveWork(instanceID WorkflowInstanceID, work layer0.Work) error {
+	store.writes = append(store.writes, instanceID)
+	return store.InMemoryStatePersistenceStore.SaveWork(instanceID, work)
+}
+
+func TestWorkflowRuntimeEnginePersistenceDisabled(t *testing.T) {
+	store := &writeRecordingStore{InMemoryStatePersistenceStore: NewInMemoryStatePersistenceStore()}
+	engine := NewWorkflowRuntimeEngine()
+	engine.SetPersistenceStore(store)
+	engine.RegisterActionWork("notify", layer0.NewWork("notify", layer0.WorkTypeNoop, "Notify"))
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").AddAction("notify")
+	definition := newParallelDefinition(transition)
+	configuration := definition.GetConfiguration()
+	configuration.PersistenceEnabled = false
+	ephemeral := definition.UpdateConfiguration(configuration)
+
+	instanceID, err := engine.StartWorkflow(ephemeral, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.PauseWorkflow(instanceID); err != nil {
+		t.Fatalf("PauseWorkflow should not return error: %v", err)
+	}
+	if err := engine.ResumeWorkflow(instanceID); err != nil {
+		t.Fatalf("ResumeWorkflow should not return error: %v", err)
+	}
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	if len(store.writes) != 0 {
+		t.Errorf("Expected the ephemeral instance never to be written, got writes for %v", store.writes)
+	}
+
+	// The finished instance is still served from memory
+	instance, err := engine.GetWorkflowInstance(instanceID)
+	if err != nil {
+		t.Fatalf("GetWorkflowInstance should not return error: %v", err)
+	}
+	if instance.Status != WorkflowInstanceStatusCompleted || instance.CurrentStateID != "end" {
+		t.Errorf("Expected the ephemeral instance to complete, got %s in %s", instance.Status, instance.CurrentStateID)
+	}
+
+	// Definitions that keep persistence enabled are written as before
+	persistedID, err := engine.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if len(store.writes) == 0 || store.writes[0] != persistedID {
+		t.Errorf("Expected the persisted instance to be written, got writes for %v", store.writes)
+	}
+}
+
+type levelCapturingLogger struct {
+	mutex    sync.Mutex
+	messages []string
+}
+
+func (logger *levelCapturingLogger) record(level, format string, args ...interface{}) {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	logger.messages = append(logger.messages, level+" "+fmt.Sprintf(format, args...))
+}
+
+func (logger *levelCapturingLogger) Debugf(format string, args ...interface{}) {
+	logger.record("DEBUG", format, args...)
+}
+
+func (logger *levelCapturingLogger) Infof(format string, args ...interface{}) {
+	logger.record("INFO", format, args...)
+}
+
+func (logger *levelCapturingLogger) Warnf(format string, args ...interface{}) {
+	logger.record("WARN", format, args...)
+}
+
+func (logger *levelCapturingLogger) Errorf(format string, args ...interface{}) {
+	logger.record("ERROR", format, args...)
+}
+
+func (logger *levelCapturingLogger) take() []string {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	messages := logger.messages
+	logger.messages = nil
+	return messages
+}
+
+type loggingExecutor struct{}
+
+func (e *loggingExecutor) Execute(work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	return e.ExecuteWithContext(context.Background(), work, workContext)
+}
+
+func (e *loggingExecutor) ExecuteWithContext(ctx context.Context, work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	logger := layer1.LoggerFromContext(ctx)
+	logger.Debugf("inputs of %s", work.GetID())
+	logger.Infof("ran %s", work.GetID())
+	return nil, nil
+}
+
+func (e *loggingExecutor) CanExecute(workType layer0.WorkType) bool {
+	return workType == layer0.WorkTypeTask
+}
+
+func (e *loggingExecutor) GetSupportedTypes() []layer0.WorkType {
+	return []layer0.WorkType{layer0.WorkTypeTask}
+}
+
+func TestWorkflowRuntimeEngineExecutorLoggingLevel(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	logger := &levelCapturingLogger{}
+	engine.SetLogger(logger)
+	engine.RegisterExecutor(layer0.WorkTypeTask, &loggingExecutor{})
+	engine.RegisterActionWork("process", layer0.NewWork("process", layer0.WorkTypeTask, "Process"))
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").AddAction("process")
+
+	run := func(level string) []string {
+		definition := newParallelDefinition(transition)
+		configuration := definition.GetConfiguration()
+		configuration.LoggingLevel = level
+		instanceID, err := engine.StartWorkflow(definition.UpdateConfiguration(configuration), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+		if err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+		if err := engine.ExecuteWorkflow(instanceID); err != nil {
+			t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+		}
+		return logger.take()
+	}
+
+	// Debug messages are suppressed at the default INFO level
+	if messages := run("INFO"); !reflect.DeepEqual(messages, []string{"INFO ran process"}) {
+		t.Errorf("Expected only the info message at INFO, got %v", messages)
+	}
+
+	if messages := run("DEBUG"); !reflect.DeepEqual(messages, []string{"DEBUG inputs of process", "INFO ran process"}) {
+		t.Errorf("Expected debug and info messages at DEBUG, got %v", messages)
+	}
+
+	// Without a logger, executors log nowhere
+	engine.SetLogger(nil)
+	if messages := run("DEBUG"); len(messages) != 0 {
+		t.Errorf("Expected no messages without a logger, got %v", messages)
+	}
+}
+
+type environmentRecordingExecutor struct {
+	environments []map[string]string
+}
+
+func (e *environmentRecordingExecutor) Execute(work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	return e.ExecuteWithContext(context.Background(), work, workContext)
+}
+
+func (e *environmentRecordingExecutor) ExecuteWithContext(ctx context.Context, work layer0.Work, workContext *layer0.Context) (interface{}, error) {
+	e.environments = append(e.environments, layer1.EnvironmentFromContext(ctx))
+	return nil, nil
+}
+
+func (e *environmentRecordingExecutor) CanExecute(workType layer0.WorkType) bool {
+	return workType == layer0.WorkTypeTask
+}
+
+func (e *environmentRecordingExecutor) GetSupportedTypes() []layer0.WorkType {
+	return []layer0.WorkType{layer0.WorkTypeTask}
+}
+
+func TestWorkflowRuntimeEngineEnvironment(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	executor := &environmentRecordingExecutor{}
+	engine.RegisterExecutor(layer0.WorkTypeTask, executor)
+	engine.RegisterActionWork("deploy", layer0.NewWork("deploy", layer0.WorkTypeTask, "Deploy"))
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").AddAction("deploy")
+
+	definition := newParallelDefinition(transition)
+	configuration := definition.GetConfiguration()
+	configuration.Environment = map[string]string{"REGION": "eu-west-1", "STAGE": "staging"}
+	definition = definition.UpdateConfiguration(configuration)
+
+	instanceID, err := engine.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	expected := map[string]string{"REGION": "eu-west-1", "STAGE": "staging"}
+	if len(executor.environments) != 1 || !reflect.DeepEqual(executor.environments[0], expected) {
+		t.Errorf("Expected the executor to observe the definition environment, got %v", executor.environments)
+	}
+
+	// Instance overrides take precedence over the definition environment
+	instanceID, err = engine.StartWorkflowWithOptions(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"), StartOptions{
+		Environment: map[string]string{"STAGE": "production"},
+	})
+	if err != nil {
+		t.Fatalf("StartWorkflowWithOptions should not return error: %v", err)
+	}
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	expected = map[string]string{"REGION": "eu-west-1", "STAGE": "production"}
+	if len(executor.environments) != 2 || !reflect.DeepEqual(executor.environments[1], expected) {
+		t.Errorf("Expected the instance override to take precedence, got %v", executor.environments)
+	}
+}
+
+// grpcConfigExecutor stands in for a gRPC executor that validates its target configuration
+type grpcConfigExecutor struct {
+	*layer1.MockWorkExecutor
+}
+
+func (e *grpcConfigExecutor) Validate(work layer0.Work) error {
+	config, ok := work.GetConfiguration().Parameters["executor_config"].(map[string]interface{})
+	if !ok {
+		return errors.New("executor_config is required")
+	}
+	if target, _ := config["target"].(string); !strings.Contains(target, ":") {
+		return fmt.Errorf("target %q must be host:port", config["target"])
+	}
+	return nil
+}
+
+func TestWorkflowRuntimeEngineValidateExecutability(t *testing.T) {
+	const workTypeGRPC layer0.WorkType = "grpc"
+
+	engine := NewWorkflowRuntimeEngine()
+	executed := 0
+	engine.RegisterExecutor(workTypeGRPC, &grpcConfigExecutor{
+		MockWorkExecutor: layer1.NewMockWorkExecutor([]layer0.WorkType{workTypeGRPC}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+			executed++
+			return nil, nil
+		}),
+	})
+
+	lookup := layer0.NewWork("lookup", workTypeGRPC, "Lookup")
+	lookup.Configuration.Parameters["executor_config"] = map[string]interface{}{"target": "orders:443", "method": "orders.Orders/Get"}
+	engine.RegisterActionWork("lookup", lookup)
+
+	charge := layer0.NewWork("charge", workTypeGRPC, "Charge")
+	charge.Configuration.Parameters["executor_config"] = map[string]interface{}{"target": "payments"}
+	engine.RegisterActionWork("charge", charge)
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("lookup").
+		AddAction("charge")
+	definition := newParallelDefinition(transition)
+
+	errs := engine.ValidateExecutability(definition, nil)
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 executability error, got %v", errs)
+	}
+
+	var executabilityErr *ExecutabilityError
+	if !errors.As(errs[0], &executabilityErr) {
+		t.Fatalf("Expected an ExecutabilityError, got %T", errs[0])
+	}
+	if executabilityErr.TransitionID != "start-to-end" || executabilityErr.ActionID != "charge" {
+		t.Errorf("Expected the error to identify start-to-end action charge, got %s action %s", executabilityErr.TransitionID, executabilityErr.ActionID)
+	}
+	if !strings.Contains(errs[0].Error(), `target "payments" must be host:port`) {
+		t.Errorf("Expected the executor's reason in the error, got %v", errs[0])
+	}
+	if executed != 0 {
+		t.Errorf("Expected no work to run during validation, got %d executions", executed)
+	}
+
+	// Failures of the schema validator are collected alongside executor failures
+	requireMethod := layer1.WorkValidatorFunc(func(work layer0.Work) error {
+		config, _ := work.GetConfiguration().Parameters["executor_config"].(map[string]interface{})
+		if _, ok := config["method"]; !ok {
+			return errors.New("method is required")
+		}
+		return nil
+	})
+	charge.Configuration.Parameters["executor_config"] = map[string]interface{}{"target": "payments:443"}
+	engine.RegisterActionWork("charge", charge)
+
+	errs = engine.ValidateExecutability(definition, requireMethod)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "transition start-to-end action charge: schema validation failed: method is required") {
+		t.Errorf("Expected the schema validator to reject the charge action, got %v", errs)
+	}
+
+	// Actions without an executor are reported; unregistered actions run as task work
+	missing := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").AddAction("notify")
+	if errs := engine.ValidateExecutability(newParallelDefinition(missing), nil); len(errs) != 1 {
+		t.Errorf("Expected the action without an executor to be reported, got %v", errs)
+	}
+}
+
+// newStepChainDefinition creates a definition moving through the given number of steps,
+// each transition running an action named after its step
+func newStepChainDefinition(steps int, pruning layer1.ContextPruningPolicy) layer1.WorkflowDefinition {
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("step-0", layer0.StateTypeInitial, "Step 0"))
+	for step := 1; step < steps; step++ {
+		stateMachine.AddState(layer0.NewState(layer0.StateID(fmt.Sprintf("step-%d", step)), layer0.StateTypeIntermediate, fmt.Sprintf("Step %d", step)))
+	}
+	stateMachine.AddState(layer0.NewState("done", layer0.StateTypeFinal, "Done"))
+
+	for step := 0; step < steps; step++ {
+		to := layer0.StateID(fmt.Sprintf("step-%d", step+1))
+		if step == steps-1 {
+			to = "done"
+		}
+		stateMachine.AddTransition(layer0.NewTransition(layer0.TransitionID(fmt.Sprintf("run-%d", step)), layer0.TransitionTypeAutomatic, layer0.StateID(fmt.Sprintf("step-%d", step)), to, fmt.Sprintf("Run %d", step)).
+			AddAction(fmt.Sprintf("action-%d", step)))
+	}
+
+	definition := layer1.NewWorkflowDefinition("chain-workflow", "1.0.0", "Chain Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("step-0").
+		AddFinalStateID("done").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+	configuration := definition.GetConfiguration()
+	configuration.ContextPruning = pruning
+	return definition.UpdateConfiguration(configuration)
+}
+
+func TestWorkflowRuntimeEngineContextPruning(t *testing.T) {
+	const steps = 8
+
+	newEngine := func() *WorkflowRuntimeEngine {
+		engine := NewWorkflowRuntimeEngine()
+		engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+			return string(w.GetID()) + "-done", nil
+		}))
+		return engine
+	}
+
+	run := func(engine *WorkflowRuntimeEngine, pruning layer1.ContextPruningPolicy) *WorkflowInstance {
+		initial := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").Set("order_id", "order-1")
+		instanceID, err := engine.StartWorkflow(newStepChainDefinition(steps, pruning), initial)
+		if err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+		if err := engine.ExecuteWorkflow(instanceID); err != nil {
+			t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+		}
+		instance, _ := engine.GetWorkflowInstance(instanceID)
+		if instance.Status != WorkflowInstanceStatusCompleted {
+			t.Fatalf("Expected the instance to complete, got %s", instance.Status)
+		}
+		return instance
+	}
+
+	outputKeys := func(instance *WorkflowInstance) []string {
+		var keys []string
+		for _, key := range instance.Context.Keys() {
+			if strings.HasSuffix(key, "_output") {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	// Without a policy every output is kept
+	if keys := outputKeys(run(newEngine(), layer1.ContextPruningPolicy{})); len(keys) != steps {
+		t.Errorf("Expected all %d outputs without pruning, got %v", steps, keys)
+	}
+
+	// keep_last keeps the newest outputs plus the retained ones
+	instance := run(newEngine(), layer1.ContextPruningPolicy{
+		Mode:         layer1.ContextPruningKeepLast,
+		KeepLast:     2,
+		RetainedKeys: []string{"work_action-0_output"},
+	})
+	expected := []string{"work_action-0_output", "work_action-6_output", "work_action-7_output"}
+	if keys := outputKeys(instance); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected outputs %v with keep_last, got %v", expected, keys)
+	}
+	if !reflect.DeepEqual(instance.OutputKeys, []string{"work_action-6_output", "work_action-7_output"}) {
+		t.Errorf("Expected the tracked outputs to be the newest two, got %v", instance.OutputKeys)
+	}
+	if orderID, _ := instance.Context.Get("order_id"); orderID != "order-1" {
+		t.Errorf("Expected keys not written by work to be kept, got %v", orderID)
+	}
+
+	// unreferenced keeps outputs until the last action that reads them has run
+	engine := newEngine()
+	var seen []interface{}
+	engine.RegisterExecutor(layer0.WorkTypeService, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeService}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		value, _ := c.Get("work_action-1_output")
+		seen = append(seen, value)
+		return "reported", nil
+	}))
+	report := layer0.NewWork("action-5", layer0.WorkTypeService, "Report")
+	report.Metadata.Properties[layer1.WorkPropertyInputKeys] = "work_action-1_output"
+	engine.RegisterActionWork("action-5", report)
+
+	pruning := layer1.ContextPruningPolicy{Mode: layer1.ContextPruningUnreferenced, RetainedKeys: []string{"work_action-7_output"}}
+	instance = run(engine, pruning)
+
+	if len(seen) != 1 || seen[0] != "action-1-done" {
+		t.Errorf("Expected the referenced output to survive until read, got %v", seen)
+	}
+	if keys := outputKeys(instance); !reflect.DeepEqual(keys, []string{"work_action-7_output"}) {
+		t.Errorf("Expected only the retained output once nothing reads the others, got %v", keys)
+	}
+}
+
+func TestWorkflowRuntimeEngineEphemeralStateTimeout(t *testing.T) {
+	store := &writeRecordingStore{InMemoryStatePersistenceStore: NewInMemoryStatePersistenceStore()}
+	engine := NewWorkflowRuntimeEngine()
+	engine.SetPersistenceStore(store)
+	clock := NewManualClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	engine.SetClock(clock)
+
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	stateMachine.AddState(layer0.NewState("review", layer0.StateTypeIntermediate, "Manual Review").SetTimeout(time.Hour))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	stateMachine.AddState(layer0.NewState("review-expired", layer0.StateTypeError, "Review Expired"))
+	stateMachine.AddTransition(layer0.NewTransition("start-to-review", layer0.TransitionTypeAutomatic, "start", "review", "Start to Review"))
+	stateMachine.AddTransition(layer0.NewTransition("review-to-end", layer0.TransitionTypeConditional, "review", "end", "Review to End").
+		AddCondition("approved"))
+	stateMachine.AddTransition(layer0.NewTransition("review-timeout", layer0.TransitionTypeTimeout, "review", "review-expired", "Review Timeout"))
+
+	definition := layer1.NewWorkflowDefinition("review-workflow", "1.0.0", "Review Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("end").
+		AddErrorStateID("review-expired").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+	configuration := definition.GetConfiguration()
+	configuration.PersistenceEnabled = false
+	definition = definition.UpdateConfiguration(configuration)
+
+	initialContext := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").Set("approved", false)
+	instanceID, err := engine.StartWorkflow(definition, initialContext)
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	engine.ExecuteWorkflow(instanceID)
+
+	clock.Advance(time.Hour)
+	timedOut, err := engine.ProcessStateTimeouts(clock.Now())
+	if err != nil {
+		t.Fatalf("ProcessStateTimeouts should not return error: %v", err)
+	}
+	if timedOut != 1 {
+		t.Fatalf("Expected the ephemeral instance to time out, got %d timeouts", timedOut)
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.Status != WorkflowInstanceStatusFailed || instance.CurrentStateID != "review-expired" {
+		t.Errorf("Expected the ephemeral instance to fail in review-expired, got %s in %s", instance.Status, instance.CurrentStateID)
+	}
+
+	if len(store.writes) != 0 {
+		t.Errorf("Expected the ephemeral instance never to be written, got writes for %v", store.writes)
+	}
+}
+
+func TestWorkflowRuntimeEngineWorkAttemptsPerExecution(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+
+	failures := map[WorkflowInstanceID]bool{"a": true}
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		if failures[WorkflowInstanceID(c.GetID())] {
+			return nil, errors.New("card declined")
+		}
+		return "charged", nil
+	}))
+
+	work := layer0.NewWork("charge", layer0.WorkTypeTask, "Charge")
+	work.Configuration.RetryCount = 1
+	work.Configuration.RetryDelaySeconds = 0
+
+	execute := func(instance *WorkflowInstance) layer1.WorkExecutionResult {
+		workContext := layer0.NewContext(layer0.ContextID(instance.ID), layer0.ContextScopeWorkflow, "Context")
+		result, err := engine.executeWorkWithRetries(context.Background(), instance, work, workContext)
+		if err != nil {
+			t.Fatalf("executeWorkWithRetries should not return error: %v", err)
+		}
+		return result
+	}
+
+	// Instance a fails its attempt and its one retry
+	instanceA := &WorkflowInstance{ID: "a", RetryBudget: 5, Ephemeral: true}
+	if result := execute(instanceA); result.Status != layer0.WorkStatusFailed || len(result.Attempts) != 2 || result.Attempts[1].Attempt != 2 {
+		t.Fatalf("Expected two failed attempts for instance a, got %v", result.Attempts)
+	}
+
+	// Instance b's execution of the same work does not continue instance a's attempts
+	instanceB := &WorkflowInstance{ID: "b", RetryBudget: 5, Ephemeral: true}
+	if result := execute(instanceB); result.Status != layer0.WorkStatusCompleted || len(result.Attempts) != 1 || result.Attempts[0].Attempt != 1 {
+		t.Errorf("Expected a single attempt for instance b, got %v", result.Attempts)
+	}
+	if attempts := engine.workExecutionCore.GetAttempts("charge"); len(attempts) != 1 {
+		t.Errorf("Expected the latest result to carry only instance b's attempt, got %v", attempts)
+	}
+
+	// Executing the work again after its final failure starts a new list
+	execute(instanceA)
+	if result := execute(instanceA); len(result.Attempts) != 2 || result.Attempts[0].Attempt != 1 {
+		t.Errorf("Expected a re-execution to record its own two attempts, got %v", result.Attempts)
+	}
+}
+
+type workOutageStore struct {
+	*InMemoryStatePersistenceStore
+	down bool
+}
+
+func (store *workOutageStore) GetWork(instanceID WorkflowInstanceID, workID layer0.WorkID) (layer0.Work, error) {
+	if store.down {
+		return layer0.Work{}, errors.New("connection refused")
+	}
+	return store.InMemoryStatePersistenceStore.GetWork(instanceID, workID)
+}
+
+func TestWorkflowRuntimeEngineHumanTaskRetryAfterFailedCompletion(t *testing.T) {
+	store := &workOutageStore{InMemoryStatePersistenceStore: NewInMemoryStatePersistenceStore()}
+	engine := NewWorkflowRuntimeEngine()
+	engine.SetPersistenceStore(store)
+	engine.RegisterActionWork("approve", layer0.NewWork("approve", layer0.WorkTypeHumanTask, "Approve"))
+
+	instanceID, err := engine.StartWorkflow(newApprovalDefinition(), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error for a human task: %v", err)
+	}
+	tasks := engine.HumanTasks(instanceID)
+	if len(tasks) != 1 {
+		t.Fatalf("Expected one human task, got %+v", tasks)
+	}
+
+	// A completion that fails leaves the token valid
+	store.down = true
+	if err := engine.CompleteHumanTask(tasks[0].Token, "", map[string]interface{}{"approve_outcome_approved": true}); err == nil {
+		t.Fatal("CompleteHumanTask should return error while the store is down")
+	}
+	if remaining := engine.HumanTasks(instanceID); len(remaining) != 1 || remaining[0].Token != tasks[0].Token {
+		t.Fatalf("Expected the token to survive a failed completion, got %+v", remaining)
+	}
+
+	// The same token completes the task once the store is back, and only once
+	store.down = false
+	if err := engine.CompleteHumanTask(tasks[0].Token, "", map[string]interface{}{"approve_outcome_approved": true}); err != nil {
+		t.Fatalf("CompleteHumanTask should not return error on retry: %v", err)
+	}
+	if instance, _ := engine.GetWorkflowInstance(instanceID); instance.Status != WorkflowInstanceStatusCompleted {
+		t.Errorf("Expected the instance to complete, got %s", instance.Status)
+	}
+	if err := engine.CompleteHumanTask(tasks[0].Token, "", nil); !errors.Is(err, ErrInvalidHumanTaskToken) {
+		t.Errorf("Expected the redeemed token to be rejected, got %v", err)
+	}
+}
+
+func TestWorkflowRuntimeEngineAsyncWorkStagedContext(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	queue := &recordingAsyncWorkQueue{}
+	engine.RegisterExecutor(layer0.WorkTypeAsync, layer1.NewAsyncQueueExecutor(queue))
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		return "reserved", nil
+	}))
+	engine.RegisterActionWork("charge", layer0.NewWork("charge", layer0.WorkTypeAsync, "Charge"))
+
+	// start -[reserve, charge]-> end, where charge completes asynchronously
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("reserve").
+		AddAction("charge")
+
+	start := func() WorkflowInstanceID {
+		instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+		if err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+		if err := engine.ExecuteWorkflow(instanceID); err != nil {
+			t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+		}
+
+		// The output of reserve stays staged while the instance waits
+		instance, _ := engine.GetWorkflowInstance(instanceID)
+		if instance.Status != WorkflowInstanceStatusWaiting {
+			t.Fatalf("Expected waiting status, got %s", instance.Status)
+		}
+		if _, exists := instance.Context.Get("work_reserve_output"); exists {
+			t.Error("Expected the staged output not to reach the context while waiting")
+		}
+		if _, err := engine.persistenceStore.GetContext(instanceID, "staged_charge"); err != nil {
+			t.Errorf("Expected the staged context to be persisted: %v", err)
+		}
+		return instanceID
+	}
+
+	// A failed charge discards the staged output of reserve
+	instanceID := start()
+	engine.CompleteAsyncWork(instanceID, "charge", AsyncWorkResult{Error: "card declined"})
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.Status != WorkflowInstanceStatusRunning || instance.CurrentStateID != "start" {
+		t.Errorf("Expected the instance to resume in start, got %s in %s", instance.Status, instance.CurrentStateID)
+	}
+	if output, exists := instance.Context.Get("work_reserve_output"); exists {
+		t.Errorf("Expected no output from reserve after the charge failed, got %v", output)
+	}
+	if _, err := engine.persistenceStore.GetContext(instanceID, "staged_charge"); err == nil {
+		t.Error("Expected the staged context to be deleted after the charge failed")
+	}
+
+	// A successful charge commits both outputs with the transition
+	instanceID = start()
+	if err := engine.CompleteAsyncWork(instanceID, "charge", AsyncWorkResult{Output: "charged"}); err != nil {
+		t.Fatalf("CompleteAsyncWork should not return error: %v", err)
+	}
+	instance, _ = engine.GetWorkflowInstance(instanceID)
+	if instance.Status != WorkflowInstanceStatusCompleted {
+		t.Errorf("Expected the instance to complete, got %s", instance.Status)
+	}
+	reserved, _ := instance.Context.Get("work_reserve_output")
+	charged, _ := instance.Context.Get("work_charge_output")
+	if reserved != "reserved" || charged != "charged" {
+		t.Errorf("Expected both outputs in context, got %v and %v", reserved, charged)
+	}
+	if _, err := engine.persistenceStore.GetContext(instanceID, "staged_charge"); err == nil {
+		t.Error("Expected the staged context to be deleted after the charge completed")
+	}
+}
+
+type instanceOutageStore struct {
+	*InMemoryStatePersistenceStore
+	unreachable WorkflowInstanceID
+}
+
+func (store *instanceOutageStore) UpdateWorkflowInstance(instance WorkflowInstance) error {
+	if instance.ID == store.unreachable {
+		return errors.New("connection refused")
+	}
+	return store.InMemoryStatePersistenceStore.UpdateWorkflowInstance(instance)
+}
+
+func (store *instanceOutageStore) CompareAndSetStatus(instanceID WorkflowInstanceID, expected, status WorkflowInstanceStatus) error {
+	if instanceID == store.unreachable {
+		return errors.New("connection refused")
+	}
+	return store.InMemoryStatePersistenceStore.CompareAndSetStatus(instanceID, expected, status)
+}
+
+func TestWorkflowRuntimeEngineSuspendStoreFailure(t *testing.T) {
+	store := &instanceOutageStore{InMemoryStatePersistenceStore: NewInMemoryStatePersistenceStore()}
+	engine := NewWorkflowRuntimeEngine()
+	engine.SetPersistenceStore(store)
+
+	definition := newParallelDefinition(layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End"))
+	for _, instanceID := range []WorkflowInstanceID{"a", "b", "c"} {
+		if _, err := engine.StartWorkflowWithOptions(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"), StartOptions{InstanceID: instanceID}); err != nil {
+			t.Fatalf("StartWorkflowWithOptions should not return error: %v", err)
+		}
+	}
+
+	// a is paused in the store before the write for b fails
+	store.unreachable = "b"
+	if _, err := engine.Suspend(); err == nil {
+		t.Fatal("Suspend should return error when an instance cannot be paused")
+	}
+	store.unreachable = ""
+
+	// The suspend is undone in memory and in the store
+	for _, instanceID := range []WorkflowInstanceID{"a", "b", "c"} {
+		instance, _ := engine.GetWorkflowInstance(instanceID)
+		persisted, _ := store.GetWorkflowInstance(instanceID)
+		if instance.Status != WorkflowInstanceStatusRunning || persisted.Status != WorkflowInstanceStatusRunning {
+			t.Errorf("Expected %s to be running, got %s in memory and %s in the store", instanceID, instance.Status, persisted.Status)
+		}
+	}
+
+	// The engine keeps running steps and can be suspended again
+	if err := engine.ExecuteStep("a"); err != nil {
+		t.Errorf("ExecuteStep should not return error after an aborted suspend: %v", err)
+	}
+	if _, err := engine.Suspend(); err != nil {
+		t.Errorf("Suspend should not return error once the store recovers: %v", err)
+	}
+}
+
+func TestWorkflowRuntimeEngineCancelChildFailure(t *testing.T) {
+	store := &instanceOutageStore{InMemoryStatePersistenceStore: NewInMemoryStatePersistenceStore()}
+	engine := NewWorkflowRuntimeEngine()
+	engine.SetPersistenceStore(store)
+
+	definition := newParallelDefinition(layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End"))
+	if _, err := engine.StartWorkflowWithOptions(definition, layer0.NewContext("parent", layer0.ContextScopeWorkflow, "Parent"), StartOptions{InstanceID: "parent"}); err != nil {
+		t.Fatalf("StartWorkflowWithOptions should not return error: %v", err)
+	}
+	if _, err := engine.StartWorkflowWithOptions(definition, layer0.NewContext("child", layer0.ContextScopeWorkflow, "Child"), StartOptions{InstanceID: "child", ParentInstanceID: "parent"}); err != nil {
+		t.Fatalf("StartWorkflowWithOptions should not return error: %v", err)
+	}
+
+	// A child that cannot be cancelled leaves the parent running in memory and in the store
+	store.unreachable = "child"
+	if err := engine.CancelWorkflow("parent"); err == nil {
+		t.Fatal("CancelWorkflow should return error when a child cannot be cancelled")
+	}
+	instance, _ := engine.GetWorkflowInstance("parent")
+	persisted, _ := store.GetWorkflowInstance("parent")
+	if instance.Status != WorkflowInstanceStatusRunning || persisted.Status != WorkflowInstanceStatusRunning {
+		t.Errorf("Expected the parent to stay running, got %s in memory and %s in the store", instance.Status, persisted.Status)
+	}
+
+	// The cancel succeeds when retried
+	store.unreachable = ""
+	if err := engine.CancelWorkflow("parent"); err != nil {
+		t.Fatalf("CancelWorkflow should not return error on retry: %v", err)
+	}
+	for _, instanceID := range []WorkflowInstanceID{"parent", "child"} {
+		if persisted, _ := store.GetWorkflowInstance(instanceID); persisted.Status != WorkflowInstanceStatusCancelled {
+			t.Errorf("Expected %s to be cancelled, got %s", instanceID, persisted.Status)
+		}
+	}
+}
+
+func TestWorkflowRuntimeEngineRetryDelayUsesClock(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	engine.SetClock(clock)
+
+	var calls int32
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return nil, errors.New("downstream unavailable")
+		}
+		return "ok", nil
+	}))
+
+	work := layer0.NewWork("charge", layer0.WorkTypeTask, "Charge")
+	work.Configuration.RetryCount = 1
+	work.Configuration.RetryDelaySeconds = 30
+
+	instance := &WorkflowInstance{ID: "a", RetryBudget: 1, Ephemeral: true}
+	done := make(chan layer1.WorkExecutionResult)
+	go func() {
+		result, _ := engine.executeWorkWithRetries(context.Background(), instance, work, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+		done <- result
+	}()
+
+	// The retry waits on the manual clock rather than in real time
+	awaitTimer(t, clock)
+	clock.Advance(29 * time.Second)
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("Expected the retry to wait for its delay, got %d calls", atomic.LoadInt32(&calls))
+	}
+	clock.Advance(time.Second)
+
+	select {
+	case result := <-done:
+		if result.Status != layer0.WorkStatusCompleted || atomic.LoadInt32(&calls) != 2 {
+			t.Errorf("Expected the retry to complete the work, got %s after %d calls", result.Status, atomic.LoadInt32(&calls))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the retry to run once the clock passed its delay")
+	}
+}
+
+func TestWorkflowRuntimeEngineAsyncWorkAfterRestart(t *testing.T) {
+	store := NewInMemoryStatePersistenceStore()
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("charge").
+		AddAction("record")
+	definition := newParallelDefinition(transition)
+
+	newEngine := func() *WorkflowRuntimeEngine {
+		engine := NewWorkflowRuntimeEngine()
+		engine.SetPersistenceStore(store)
+		engine.RegisterExecutor(layer0.WorkTypeAsync, layer1.NewAsyncQueueExecutor(&recordingAsyncWorkQueue{}))
+		engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+			return "recorded", nil
+		}))
+		engine.RegisterActionWork("charge", layer0.NewWork("charge", layer0.WorkTypeAsync, "Charge"))
+		return engine
+	}
+
+	// The first engine suspends the instance on the async charge
+	first := newEngine()
+	instanceID, err := first.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := first.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	// A restarted engine on the same store resolves the instance's definition to complete it
+	second := newEngine()
+	second.SetDefinitionResolver(func(id layer1.WorkflowDefinitionID, version layer1.WorkflowDefinitionVersion) (layer1.WorkflowDefinition, error) {
+		if id != definition.GetID() || version != definition.GetVersion() {
+			return layer1.WorkflowDefinition{}, fmt.Errorf("unknown definition %s %s", id, version)
+		}
+		return definition, nil
+	})
+	if err := second.CompleteAsyncWork(instanceID, "charge", AsyncWorkResult{Output: "charged"}); err != nil {
+		t.Fatalf("CompleteAsyncWork should not return error after a restart: %v", err)
+	}
+
+	persisted, _ := store.GetWorkflowInstance(instanceID)
+	if persisted.Status != WorkflowInstanceStatusCompleted || persisted.CurrentStateID != "end" {
+		t.Errorf("Expected the instance to complete in end, got %s in %s", persisted.Status, persisted.CurrentStateID)
+	}
+	if recorded, _ := persisted.Context.Get("work_record_output"); recorded != "recorded" {
+		t.Errorf("Expected the remaining action to run, got %v", recorded)
+	}
+}
+
+type slowWorkStore struct {
+	*InMemoryStatePersistenceStore
+}
+
+func (store slowWorkStore) GetWork(instanceID WorkflowInstanceID, workID layer0.WorkID) (layer0.Work, error) {
+	work, err := store.InMemoryStatePersistenceStore.GetWork(instanceID, workID)
+	time.Sleep(10 * time.Millisecond)
+	return work, err
+}
+
+func TestWorkflowRuntimeEngineConcurrentAsyncCompletion(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	engine.SetPersistenceStore(slowWorkStore{InMemoryStatePersistenceStore: NewInMemoryStatePersistenceStore()})
+	engine.RegisterExecutor(layer0.WorkTypeAsync, layer1.NewAsyncQueueExecutor(&recordingAsyncWorkQueue{}))
+
+	var records int32
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		atomic.AddInt32(&records, 1)
+		return "recorded", nil
+	}))
+	engine.RegisterActionWork("charge", layer0.NewWork("charge", layer0.WorkTypeAsync, "Charge"))
+
+	transition := layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Start to End").
+		AddAction("charge").
+		AddAction("record")
+	instanceID, err := engine.StartWorkflow(newParallelDefinition(transition), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	// Two workers report the same completion at once
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errs <- engine.CompleteAsyncWork(instanceID, "charge", AsyncWorkResult{Output: "charged"})
+		}()
+	}
+
+	succeeded := 0
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("Expected exactly one completion to succeed, got %d", succeeded)
+	}
+	if calls := atomic.LoadInt32(&records); calls != 1 {
+		t.Errorf("Expected the remaining action to run once, got %d runs", calls)
+	}
+	if instance, _ := engine.GetWorkflowInstance(instanceID); instance.Status != WorkflowInstanceStatusCompleted {
+		t.Errorf("Expected the instance to complete, got %s", instance.Status)
+	}
+}
+
+func TestWorkflowRuntimeEngineStateTimeoutSupersedesStep(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	clock := NewManualClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	engine.SetClock(clock)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	engine.RegisterExecutor(layer0.WorkTypeTask, layer1.NewMockWorkExecutor([]layer0.WorkType{layer0.WorkTypeTask}, func(w layer0.Work, c *layer0.Context) (interface{}, error) {
+		close(started)
+		<-release
+		return nil, nil
+	}))
+
+	// start -> review -(submit)-> end, with review timing out into escalated
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	stateMachine.AddState(layer0.NewState("review", layer0.StateTypeIntermediate, "Review").SetTimeout(time.Hour))
+	stateMachine.AddState(layer0.NewState("escalated", layer0.StateTypeIntermediate, "Escalated"))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	stateMachine.AddTransition(layer0.NewTransition("start-to-review", layer0.TransitionTypeAutomatic, "start", "review", "Start to Review"))
+	stateMachine.AddTransition(layer0.NewTransition("review-to-end", layer0.TransitionTypeAutomatic, "review", "end", "Review to End").
+		AddAction("submit"))
+	stateMachine.AddTransition(layer0.NewTransition("review-timeout", layer0.TransitionTypeTimeout, "review", "escalated", "Review Timeout"))
+	stateMachine.AddTransition(layer0.NewTransition("escalated-to-end", layer0.TransitionTypeConditional, "escalated", "end", "Escalated to End").
+		AddCondition("resolved"))
+
+	definition := layer1.NewWorkflowDefinition("escalation-workflow", "1.0.0", "Escalation Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("end").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	initialContext := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").Set("resolved", false)
+	instanceID, err := engine.StartWorkflow(definition, initialContext)
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- engine.ExecuteWorkflow(instanceID)
+	}()
+	<-started
+
+	// The review deadline passes while the submit action is still running
+	clock.Advance(time.Hour)
+	if timedOut, err := engine.ProcessStateTimeouts(clock.Now()); err != nil || timedOut != 1 {
+		t.Fatalf("Expected 1 timed out instance, got %d (%v)", timedOut, err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error: %v", err)
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.CurrentStateID != "escalated" || instance.Status != WorkflowInstanceStatusRunning {
+		t.Errorf("Expected the instance to stay escalated, got %s in %s", instance.Status, instance.CurrentStateID)
+	}
+	for _, record := range engine.GetExecutionHistory(instanceID) {
+		if record.TransitionID == "review-to-end" && record.Error == "" {
+			t.Errorf("Expected the superseded step not to commit, got %+v", record)
+		}
+	}
+
+	// The timed out state does not time out again
+	if timedOut, _ := engine.ProcessStateTimeouts(clock.Now().Add(time.Hour)); timedOut != 0 {
+		t.Errorf("Expected no further timeouts, got %d", timedOut)
+	}
+}
+
+func TestWorkflowRuntimeEngineStateTimeoutAfterRestart(t *testing.T) {
+	store := NewInMemoryStatePersistenceStore()
+	clock := NewManualClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+
+	// start -> review -(approved)-> end, with review timing out into escalated
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	stateMachine.AddState(layer0.NewState("review", layer0.StateTypeIntermediate, "Review").SetTimeout(time.Hour))
+	stateMachine.AddState(layer0.NewState("escalated", layer0.StateTypeIntermediate, "Escalated"))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	stateMachine.AddTransition(layer0.NewTransition("start-to-review", layer0.TransitionTypeAutomatic, "start", "review", "Start to Review"))
+	stateMachine.AddTransition(layer0.NewTransition("review-to-end", layer0.TransitionTypeConditional, "review", "end", "Review to End").
+		AddCondition("approved"))
+	stateMachine.AddTransition(layer0.NewTransition("review-timeout", layer0.TransitionTypeTimeout, "review", "escalated", "Review Timeout"))
+	stateMachine.AddTransition(layer0.NewTransition("escalated-to-end", layer0.TransitionTypeAutomatic, "escalated", "end", "Escalated to End"))
+
+	definition := layer1.NewWorkflowDefinition("escalation-workflow", "1.0.0", "Escalation Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("end").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	first := NewWorkflowRuntimeEngine()
+	first.SetPersistenceStore(store)
+	first.SetClock(clock)
+	initialContext := layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context").Set("approved", false)
+	instanceID, err := first.StartWorkflow(definition, initialContext)
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	first.ExecuteWorkflow(instanceID)
+
+	// A restarted engine on the same store takes the timeout transition of the instance's definition
+	second := NewWorkflowRuntimeEngine()
+	second.SetPersistenceStore(store)
+	second.SetClock(clock)
+	second.SetDefinitionResolver(func(id layer1.WorkflowDefinitionID, version layer1.WorkflowDefinitionVersion) (layer1.WorkflowDefinition, error) {
+		if id != definition.GetID() || version != definition.GetVersion() {
+			return layer1.WorkflowDefinition{}, fmt.Errorf("unknown definition %s %s", id, version)
+		}
+		return definition, nil
+	})
+
+	clock.Advance(time.Hour)
+	if timedOut, err := second.ProcessStateTimeouts(clock.Now()); err != nil || timedOut != 1 {
+		t.Fatalf("Expected 1 timed out instance, got %d (%v)", timedOut, err)
+	}
+
+	persisted, _ := store.GetWorkflowInstance(instanceID)
+	if persisted.Status != WorkflowInstanceStatusCompleted || persisted.CurrentStateID != "end" {
+		t.Errorf("Expected the instance to escalate and complete in end, got %s in %s", persisted.Status, persisted.CurrentStateID)
+	}
+	if persisted.Metadata["timed_out_state"] != "review" {
+		t.Errorf("Expected the timed out state to be recorded, got %q", persisted.Metadata["timed_out_state"])
+	}
+}
+
+func TestWorkflowRuntimeEngineHumanTaskRequiresToken(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	work := layer0.NewWork("approve", layer0.WorkTypeHumanTask, "Approve")
+	work.Configuration.Parameters[layer1.HumanTaskOutcomesParameter] = []string{"approve_outcome_approved", "approve_outcome_rejected"}
+	engine.RegisterActionWork("approve", work)
+
+	instanceID, err := engine.StartWorkflow(newApprovalDefinition(), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("ExecuteWorkflow should not return error for a human task: %v", err)
+	}
+
+	// Completing the human task as plain async work bypasses its token and outcomes
+	if err := engine.CompleteAsyncWork(instanceID, "approve", AsyncWorkResult{Output: "approve_outcome_approved"}); !errors.Is(err, ErrInvalidHumanTaskToken) {
+		t.Fatalf("Expected CompleteAsyncWork to reject a human task, got %v", err)
+	}
+	if instance, _ := engine.GetWorkflowInstance(instanceID); instance.Status != WorkflowInstanceStatusWaiting {
+		t.Fatalf("Expected the instance to keep waiting, got %s", instance.Status)
+	}
+
+	tasks := engine.HumanTasks(instanceID)
+	if len(tasks) != 1 {
+		t.Fatalf("Expected the human task to keep its token, got %+v", tasks)
+	}
+	if err := engine.CompleteHumanTask(tasks[0].Token, "approve_outcome_approved", nil); err != nil {
+		t.Fatalf("CompleteHumanTask should not return error: %v", err)
+	}
+
+	instance, _ := engine.GetWorkflowInstance(instanceID)
+	if instance.Status != WorkflowInstanceStatusCompleted || instance.CurrentStateID != "approved" {
+		t.Errorf("Expected completion in approved, got %s in %s", instance.Status, instance.CurrentStateID)
+	}
+	if len(engine.HumanTasks(instanceID)) != 0 {
+		t.Error("Expected the token of the completed task to be removed")
+	}
+}
+
+func TestWorkflowRuntimeEngineHumanTaskDroppedWithInstance(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	clock := NewManualClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	engine.SetClock(clock)
+	engine.RegisterActionWork("approve", layer0.NewWork("approve", layer0.WorkTypeHumanTask, "Approve"))
+
+	// start -(approve)-> end, with start timing out into an error state
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start").SetTimeout(time.Hour))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+	stateMachine.AddState(layer0.NewState("expired", layer0.StateTypeError, "Expired"))
+	stateMachine.AddTransition(layer0.NewTransition("start-to-end", layer0.TransitionTypeAutomatic, "start", "end", "Approve").
+		AddAction("approve"))
+	stateMachine.AddTransition(layer0.NewTransition("start-timeout", layer0.TransitionTypeTimeout, "start", "expired", "Approval Timeout"))
+
+	definition := layer1.NewWorkflowDefinition("approval-workflow", "1.0.0", "Approval Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("end").
+		AddErrorStateID("expired").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+
+	startApproval := func() (WorkflowInstanceID, HumanTask) {
+		instanceID, err := engine.StartWorkflow(definition, layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+		if err != nil {
+			t.Fatalf("StartWorkflow should not return error: %v", err)
+		}
+		if err := engine.ExecuteWorkflow(instanceID); err != nil {
+			t.Fatalf("ExecuteWorkflow should not return error for a human task: %v", err)
+		}
+		tasks := engine.HumanTasks(instanceID)
+		if len(tasks) != 1 {
+			t.Fatalf("Expected one human task, got %+v", tasks)
+		}
+		return instanceID, tasks[0]
+	}
+
+	// A cancelled instance drops its tokens
+	instanceID, task := startApproval()
+	if err := engine.CancelWorkflow(instanceID); err != nil {
+		t.Fatalf("CancelWorkflow should not return error: %v", err)
+	}
+	if tasks := engine.HumanTasks(instanceID); len(tasks) != 0 {
+		t.Errorf("Expected the tokens of a cancelled instance to be dropped, got %+v", tasks)
+	}
+	if err := engine.CompleteHumanTask(task.Token, "", nil); !errors.Is(err, ErrInvalidHumanTaskToken) {
+		t.Errorf("Expected the token of a cancelled instance to be rejected, got %v", err)
+	}
+
+	// So does an instance that timed out of the state its task was waiting in
+	instanceID, task = startApproval()
+	clock.Advance(time.Hour)
+	if timedOut, err := engine.ProcessStateTimeouts(clock.Now()); err != nil || timedOut != 1 {
+		t.Fatalf("Expected 1 timed out instance, got %d (%v)", timedOut, err)
+	}
+	if tasks := engine.HumanTasks(instanceID); len(tasks) != 0 {
+		t.Errorf("Expected the tokens of a timed out instance to be dropped, got %+v", tasks)
+	}
+	if err := engine.CompleteHumanTask(task.Token, "", nil); !errors.Is(err, ErrInvalidHumanTaskToken) {
+		t.Errorf("Expected the token of a timed out instance to be rejected, got %v", err)
+	}
+}
+
+// signallingTransitionEvaluator signals when the slow transition starts evaluating
+type signallingTransitionEvaluator struct {
+	slowTransitionEvaluator
+	started chan struct{}
+}
+
+func (evaluator signallingTransitionEvaluator) CanTransitionWithContext(ctx context.Context, transition layer0.Transition, context *layer0.Context) (bool, error) {
+	if transition.GetID() == evaluator.slow {
+		close(evaluator.started)
+	}
+	return evaluator.slowTransitionEvaluator.CanTransitionWithContext(ctx, transition, context)
+}
+
+func TestWorkflowRuntimeEngineConditionEvaluationCancelled(t *testing.T) {
+	engine := NewWorkflowRuntimeEngine()
+	evaluator := signallingTransitionEvaluator{
+		slowTransitionEvaluator: slowTransitionEvaluator{DefaultTransitionEvaluator: NewDefaultTransitionEvaluator(), slow: "start-to-left"},
+		started:                 make(chan struct{}),
+	}
+	engine.SetTransitionEvaluator(evaluator)
+	if err := engine.SetConditionTimeout(10*time.Second, ConditionTimeoutFail); err != nil {
+		t.Fatalf("SetConditionTimeout should not return error: %v", err)
+	}
+
+	instanceID, err := engine.StartWorkflow(newForkDefinition(0, 0), layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Context"))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- engine.ExecuteStep(instanceID)
+	}()
+	<-evaluator.started
+
+	// Cancelling the instance stops the evaluation without it counting as a timeout
+	if err := engine.CancelWorkflow(instanceID); err != nil {
+		t.Fatalf("CancelWorkflow should not return error: %v", err)
+	}
+	if err := <-done; !errors.Is(err, ErrInstanceNotRunning) || errors.Is(err, ErrConditionTimeout) {
+		t.Errorf("Expected the step to stop as not running, got %v", err)
+	}
+
+	if status, _ := engine.GetWorkflowStatus(instanceID); status != WorkflowInstanceStatusCancelled {
+		t.Errorf("Expected cancelled status, got %s", status)
+	}
+	if reported := engine.errorHandler.GetErrors(instanceID); len(reported) != 0 {
+		t.Errorf("Expected no evaluation errors to be reported, got %v", reported)
+	}
+}
@@ -0,0 +1,385 @@
+package layer2
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricType identifies the kind of a metric family
+type MetricType string
+
+const (
+	// MetricTypeCounter is a monotonically increasing value
+	MetricTypeCounter MetricType = "counter"
+	// MetricTypeHistogram counts observations into cumulative buckets
+	MetricTypeHistogram MetricType = "histogram"
+)
+
+// DefaultHistogramBuckets are the upper bounds, in seconds, used for duration histograms
+var DefaultHistogramBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metric names recorded by the engine
+const (
+	MetricWorkflowsStarted   = "workflow_instances_started_total"
+	MetricWorkflowsFinished  = "workflow_instances_finished_total"
+	MetricTransitions        = "workflow_transitions_total"
+	MetricTransitionDuration = "workflow_transition_duration_seconds"
+	MetricWorkExecutions     = "workflow_work_executions_total"
+	MetricWorkDuration       = "workflow_work_duration_seconds"
+	MetricStepWait           = "workflow_step_wait_seconds"
+)
+
+// metricSeries holds the value of one label combination of a metric family
+type metricSeries struct {
+	labels  map[string]string
+	value   float64
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// metricFamily holds all series of a named metric
+type metricFamily struct {
+	name    string
+	help    string
+	kind    MetricType
+	buckets []float64
+	series  map[string]*metricSeries
+}
+
+// MetricsRegistry accumulates counters and histograms and renders them in the
+// Prometheus text exposition format without depending on a client library
+type MetricsRegistry struct {
+	families map[string]*metricFamily
+	mutex    sync.RWMutex
+}
+
+// NewMetricsRegistry creates a new empty metrics registry
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{
+		families: make(map[string]*metricFamily),
+	}
+}
+
+// RegisterCounter registers a counter metric. Registering the same counter again is a no-op.
+func (registry *MetricsRegistry) RegisterCounter(name, help string) error {
+	return registry.register(name, help, MetricTypeCounter, nil)
+}
+
+// RegisterHistogram registers a histogram metric with the given bucket upper bounds.
+// Registering the same histogram again is a no-op.
+func (registry *MetricsRegistry) RegisterHistogram(name, help string, buckets []float64) error {
+	if len(buckets) == 0 {
+		return fmt.Errorf("histogram %s must have at least one bucket", name)
+	}
+
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+
+	return registry.register(name, help, MetricTypeHistogram, sorted)
+}
+
+// register adds a metric family, rejecting a name already used by another type
+func (registry *MetricsRegistry) register(name, help string, kind MetricType, buckets []float64) error {
+	if name == "" {
+		return fmt.Errorf("metric name cannot be empty")
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if existing, exists := registry.families[name]; exists {
+		if existing.kind != kind {
+			return fmt.Errorf("metric %s already registered as %s", name, existing.kind)
+		}
+		return nil
+	}
+
+	registry.families[name] = &metricFamily{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		buckets: buckets,
+		series:  make(map[string]*metricSeries),
+	}
+	return nil
+}
+
+// AddCounter adds a non-negative delta to a counter series
+func (registry *MetricsRegistry) AddCounter(name string, labels map[string]string, delta float64) error {
+	if delta < 0 {
+		return fmt.Errorf("counter %s cannot decrease", name)
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	series, err := registry.seriesUnsafe(name, MetricTypeCounter, labels)
+	if err != nil {
+		return err
+	}
+
+	series.value += delta
+	return nil
+}
+
+// IncCounter increments a counter series by one
+func (registry *MetricsRegistry) IncCounter(name string, labels map[string]string) error {
+	return registry.AddCounter(name, labels, 1)
+}
+
+// ObserveHistogram records an observation in a histogram series
+func (registry *MetricsRegistry) ObserveHistogram(name string, labels map[string]string, value float64) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	series, err := registry.seriesUnsafe(name, MetricTypeHistogram, labels)
+	if err != nil {
+		return err
+	}
+
+	for i, bound := range registry.families[name].buckets {
+		if value <= bound {
+			series.buckets[i]++
+		}
+	}
+	series.sum += value
+	series.count++
+	return nil
+}
+
+// seriesUnsafe returns the series for the labels, creating it if needed.
+// This method assumes the caller already holds the mutex lock.
+func (registry *MetricsRegistry) seriesUnsafe(name string, kind MetricType, labels map[string]string) (*metricSeries, error) {
+	family, exists := registry.families[name]
+	if !exists {
+		return nil, fmt.Errorf("metric %s is not registered", name)
+	}
+
+	if family.kind != kind {
+		return nil, fmt.Errorf("metric %s is a %s, not a %s", name, family.kind, kind)
+	}
+
+	key := formatLabels(labels)
+	series, exists := family.series[key]
+	if !exists {
+		copied := make(map[string]string, len(labels))
+		for k, v := range labels {
+			copied[k] = v
+		}
+		series = &metricSeries{labels: copied, buckets: make([]uint64, len(family.buckets))}
+		family.series[key] = series
+	}
+	return series, nil
+}
+
+// WriteMetrics renders all metrics in the Prometheus text exposition format
+func (registry *MetricsRegistry) WriteMetrics(w io.Writer) error {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	names := make([]string, 0, len(registry.families))
+	for name := range registry.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := bufio.NewWriter(w)
+	for _, name := range names {
+		family := registry.families[name]
+		if family.help != "" {
+			fmt.Fprintf(out, "# HELP %s %s\n", name, escapeHelp(family.help))
+		}
+		fmt.Fprintf(out, "# TYPE %s %s\n", name, family.kind)
+
+		keys := make([]string, 0, len(family.series))
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			series := family.series[key]
+			if family.kind == MetricTypeCounter {
+				fmt.Fprintf(out, "%s%s %s\n", name, key, formatMetricValue(series.value))
+				continue
+			}
+
+			for i, bound := range family.buckets {
+				fmt.Fprintf(out, "%s_bucket%s %d\n", name, formatLabels(withLabel(series.labels, "le", formatMetricValue(bound))), series.buckets[i])
+			}
+			fmt.Fprintf(out, "%s_bucket%s %d\n", name, formatLabels(withLabel(series.labels, "le", "+Inf")), series.count)
+			fmt.Fprintf(out, "%s_sum%s %s\n", name, key, formatMetricValue(series.sum))
+			fmt.Fprintf(out, "%s_count%s %d\n", name, key, series.count)
+		}
+	}
+
+	return out.Flush()
+}
+
+// withLabel returns a copy of labels with an additional label
+func withLabel(labels map[string]string, name, value string) map[string]string {
+	copied := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		copied[k] = v
+	}
+	copied[name] = value
+	return copied
+}
+
+// formatLabels renders labels sorted by name, e.g. {a="1",b="2"}
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, escapeLabelValue(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// escapeLabelValue escapes backslashes, quotes and newlines in a label value
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// escapeHelp escapes backslashes and newlines in help text
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+// formatMetricValue renders a sample value the way Prometheus expects
+func formatMetricValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+// registerEngineMetrics registers the metrics the engine records
+func registerEngineMetrics(registry *MetricsRegistry) error {
+	counters := map[string]string{
+		MetricWorkflowsStarted:  "Workflow instances started.",
+		MetricWorkflowsFinished: "Workflow instances that reached a terminal status.",
+		MetricTransitions:       "Transitions attempted, by outcome.",
+		MetricWorkExecutions:    "Work executions, by work type and status.",
+	}
+	for name, help := range counters {
+		if err := registry.RegisterCounter(name, help); err != nil {
+			return err
+		}
+	}
+
+	histograms := map[string]string{
+		MetricTransitionDuration: "Duration of transition attempts in seconds.",
+		MetricWorkDuration:       "Duration of work executions in seconds.",
+		MetricStepWait:           "Time instances waited for a step slot in seconds.",
+	}
+	for name, help := range histograms {
+		if err := registry.RegisterHistogram(name, help, DefaultHistogramBuckets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetMetricsRegistry enables recording engine metrics into the registry
+func (engine *WorkflowRuntimeEngine) SetMetricsRegistry(registry *MetricsRegistry) error {
+	if registry != nil {
+		if err := registerEngineMetrics(registry); err != nil {
+			return fmt.Errorf("failed to register engine metrics: %w", err)
+		}
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.metrics = registry
+	return nil
+}
+
+// recordWorkflowStarted counts a started instance.
+// This method assumes the caller already holds the mutex lock.
+func (engine *WorkflowRuntimeEngine) recordWorkflowStarted(instance *WorkflowInstance) {
+	if engine.metrics == nil {
+		return
+	}
+
+	engine.metrics.IncCounter(MetricWorkflowsStarted, map[string]string{"definition_id": string(instance.DefinitionID)})
+}
+
+// recordWorkflowFinished counts an instance entering a terminal status.
+// This method assumes the caller already holds the mutex lock.
+func (engine *WorkflowRuntimeEngine) recordWorkflowFinished(instance *WorkflowInstance) {
+	if engine.metrics == nil {
+		return
+	}
+
+	engine.metrics.IncCounter(MetricWorkflowsFinished, map[string]string{
+		"definition_id": string(instance.DefinitionID),
+		"status":        string(instance.Status),
+	})
+}
+
+// recordTransitionAttempt counts a transition attempt and the work it executed
+func (engine *WorkflowRuntimeEngine) recordTransitionAttempt(instance *WorkflowInstance, record TransitionRecord, outcome string) {
+	engine.mutex.RLock()
+	metrics := engine.metrics
+	engine.mutex.RUnlock()
+
+	if metrics == nil {
+		return
+	}
+
+	definitionID := string(instance.DefinitionID)
+	metrics.IncCounter(MetricTransitions, map[string]string{
+		"definition_id": definitionID,
+		"transition_id": string(record.TransitionID),
+		"outcome":       outcome,
+	})
+	metrics.ObserveHistogram(MetricTransitionDuration, map[string]string{"definition_id": definitionID}, record.Duration().Seconds())
+
+	for _, result := range record.WorkResults {
+		metrics.IncCounter(MetricWorkExecutions, map[string]string{
+			"definition_id": definitionID,
+			"work_id":       string(result.WorkID),
+			"status":        string(result.Status),
+		})
+		metrics.ObserveHistogram(MetricWorkDuration, map[string]string{"definition_id": definitionID}, result.Duration.Seconds())
+	}
+}
+
+// recordStepWait observes how long an instance waited for a step slot
+func (engine *WorkflowRuntimeEngine) recordStepWait(instance *WorkflowInstance, wait time.Duration) {
+	engine.mutex.RLock()
+	metrics := engine.metrics
+	engine.mutex.RUnlock()
+
+	if metrics == nil {
+		return
+	}
+
+	metrics.ObserveHistogram(MetricStepWait, map[string]string{"definition_id": string(instance.DefinitionID)}, wait.Seconds())
+}
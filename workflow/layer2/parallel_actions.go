@@ -0,0 +1,140 @@
+package layer2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// defaultParallelActionLimit bounds how many actions of a parallel transition run at once
+const defaultParallelActionLimit = 4
+
+// SetParallelActionLimit sets how many actions of a parallel transition may run at once
+func (engine *WorkflowRuntimeEngine) SetParallelActionLimit(limit int) error {
+	if limit <= 0 {
+		return fmt.Errorf("parallel action limit must be positive")
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.parallelActionLimit = limit
+	return nil
+}
+
+// actionOutputKey returns the context key an action's output is stored under
+func actionOutputKey(work layer0.Work) string {
+	if key := work.GetMetadata().Properties[layer1.WorkPropertyOutputKey]; key != "" {
+		return key
+	}
+	return fmt.Sprintf("work_%s_output", work.GetID())
+}
+
+// runParallelActions executes a transition's actions concurrently on a bounded pool
+// sized by the instance's work cap, if lower than the engine limit; excess actions
+// wait for a running one to finish. Every action sees the same staged context;
+// their outputs are merged in action order using the transition's conflict
+// strategy, and all failures are reported together.
+func (engine *WorkflowRuntimeEngine) runParallelActions(ctx context.Context, instance *WorkflowInstance, transition layer0.Transition, staged *layer0.Context) ([]layer1.WorkExecutionResult, *layer0.Context, error) {
+	actions := transition.GetActions()
+	for _, actionID := range actions {
+		if _, _, shared := parseSharedAction(actionID); shared {
+			return nil, staged, fmt.Errorf("shared action %s cannot run in a parallel transition", actionID)
+		}
+	}
+
+	engine.mutex.RLock()
+	limit := engine.parallelActionLimit
+	if instance.MaxConcurrentWork > 0 && instance.MaxConcurrentWork < limit {
+		limit = instance.MaxConcurrentWork
+	}
+	deterministic := engine.deterministic
+	engine.mutex.RUnlock()
+
+	works := make([]layer0.Work, len(actions))
+	results := make([]layer1.WorkExecutionResult, len(actions))
+	outputs := make([]interface{}, len(actions))
+	failures := make([]error, len(actions))
+
+	slots := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for index, actionID := range actions {
+		works[index] = engine.buildActionWork(instance.ID, actionID)
+
+		run := func(index int, actionID string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			result, err := engine.executeWorkWithRetries(ctx, instance, works[index], staged)
+			switch {
+			case err != nil:
+				failures[index] = fmt.Errorf("failed to execute work %s: %w", actionID, err)
+				return
+			case result.Status == layer0.WorkStatusFailed:
+				failures[index] = fmt.Errorf("work %s failed: %s", actionID, result.Error)
+			case isAwaitingCompletion(result.Status):
+				failures[index] = fmt.Errorf("work %s cannot complete asynchronously in a parallel transition", actionID)
+			default:
+				outputs[index], failures[index] = engine.transformOutput(works[index], result.Output)
+			}
+			results[index] = result
+		}
+
+		// Deterministic engines run the actions in order on this goroutine
+		wg.Add(1)
+		if deterministic {
+			run(index, actionID)
+		} else {
+			go run(index, actionID)
+		}
+	}
+	wg.Wait()
+
+	// Aggregate failures in action order
+	workResults := make([]layer1.WorkExecutionResult, 0, len(actions))
+	var failed []string
+	budgetExhausted := false
+	for index := range actions {
+		if results[index].WorkID != "" {
+			workResults = append(workResults, results[index])
+		}
+		if failures[index] != nil {
+			failed = append(failed, failures[index].Error())
+			budgetExhausted = budgetExhausted || errors.Is(failures[index], ErrRetryBudgetExhausted)
+		}
+	}
+
+	if len(failed) > 0 {
+		err := fmt.Errorf("%d of %d parallel actions failed: %s", len(failed), len(actions), strings.Join(failed, "; "))
+		if budgetExhausted {
+			err = fmt.Errorf("%w: %v", ErrRetryBudgetExhausted, err)
+		}
+		return workResults, staged, err
+	}
+
+	// Merge outputs among themselves first so the strategy only applies to
+	// conflicts between parallel actions, not to values from earlier steps
+	merged := layer0.NewContext(layer0.ContextID(fmt.Sprintf("%s-outputs", transition.GetID())), layer0.ContextScopeWork, "Parallel Outputs")
+	for index := range results {
+		if outputs[index] == nil {
+			continue
+		}
+
+		output := layer0.NewContext(layer0.ContextID(actions[index]), layer0.ContextScopeWork, "Action Output").
+			Set(actionOutputKey(works[index]), outputs[index])
+
+		next, err := merged.MergeWithStrategy(output, transition.GetConflictStrategy())
+		if err != nil {
+			return workResults, staged, fmt.Errorf("failed to merge output of parallel action %s: %w", actions[index], err)
+		}
+		merged = next
+	}
+
+	return workResults, staged.Merge(merged), nil
+}
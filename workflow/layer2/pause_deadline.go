@@ -0,0 +1,109 @@
+package layer2
+
+import (
+	"fmt"
+	"time"
+)
+
+// PauseExpiryAction defines what happens to an instance paused with a deadline once it passes
+type PauseExpiryAction string
+
+const (
+	// PauseExpiryResume resumes the instance and continues executing it
+	PauseExpiryResume PauseExpiryAction = "resume"
+	// PauseExpiryFail fails the instance
+	PauseExpiryFail PauseExpiryAction = "fail"
+)
+
+// PauseWorkflowUntil pauses a running instance until deadline, after which it is
+// resumed or failed according to onExpiry. Resuming the instance earlier cancels
+// the deadline. The deadline is persisted with the instance and handled by
+// ProcessStateTimeouts, so it survives an engine restart.
+func (engine *WorkflowRuntimeEngine) PauseWorkflowUntil(instanceID WorkflowInstanceID, deadline time.Time, onExpiry PauseExpiryAction) error {
+	issuedAt := engine.clock.Now()
+	err := engine.pauseWorkflowUntil(instanceID, deadline, onExpiry)
+	engine.recordCommand(Command{Type: CommandPauseUntil, InstanceID: instanceID, Now: &deadline, PauseExpiry: onExpiry, IssuedAt: issuedAt}, err)
+	return err
+}
+
+// pauseWorkflowUntil pauses an instance with a deadline without recording the command
+func (engine *WorkflowRuntimeEngine) pauseWorkflowUntil(instanceID WorkflowInstanceID, deadline time.Time, onExpiry PauseExpiryAction) error {
+	switch onExpiry {
+	case PauseExpiryResume, PauseExpiryFail:
+	default:
+		return fmt.Errorf("unknown pause expiry action: %s", onExpiry)
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	instance, exists := engine.activeInstances[instanceID]
+	if !exists {
+		return fmt.Errorf("workflow instance %s not found", instanceID)
+	}
+
+	if instance.Status != WorkflowInstanceStatusRunning {
+		return fmt.Errorf("workflow instance %s is not running", instanceID)
+	}
+
+	if err := engine.guardStatus(instance, WorkflowInstanceStatusPaused); err != nil {
+		return err
+	}
+
+	instance.Status = WorkflowInstanceStatusPaused
+	instance.UpdatedAt = engine.clock.Now()
+	instance.PauseDeadline = &deadline
+	instance.PauseExpiry = onExpiry
+
+	if err := engine.updateInstance(*instance); err != nil {
+		return fmt.Errorf("failed to update workflow instance: %w", err)
+	}
+
+	if err := engine.lifecycleManager.OnWorkflowPaused(instanceID); err != nil {
+		engine.errorHandler.HandleError(instanceID, fmt.Errorf("lifecycle manager error: %w", err))
+	}
+
+	return nil
+}
+
+// expirePause resumes or fails an instance whose pause deadline passed
+func (engine *WorkflowRuntimeEngine) expirePause(persisted WorkflowInstance) error {
+	instanceID := persisted.ID
+
+	// Restore the instance if the engine restarted since it was paused
+	engine.mutex.Lock()
+	instance, exists := engine.activeInstances[instanceID]
+	if !exists {
+		instance = &persisted
+		engine.applyContextDefaultsUnsafe(instance)
+		engine.activeInstances[instanceID] = instance
+	}
+	deadline := instance.PauseDeadline
+	onExpiry := instance.PauseExpiry
+	engine.mutex.Unlock()
+
+	if deadline == nil {
+		return nil
+	}
+
+	if onExpiry == PauseExpiryFail {
+		return engine.failWorkflow(instanceID, fmt.Errorf("pause deadline %s passed", deadline.Format(time.RFC3339)))
+	}
+
+	if err := engine.loadInstanceDefinition(persisted); err != nil {
+		return err
+	}
+
+	if err := engine.resumeWorkflow(instanceID); err != nil {
+		return err
+	}
+
+	if engine.IsDebugEnabled(instanceID) {
+		return nil
+	}
+
+	if err := engine.executeWorkflow(instanceID); err != nil {
+		engine.errorHandler.HandleError(instanceID, fmt.Errorf("execution after pause deadline: %w", err))
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package layer2
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PersistenceFailurePolicy defines how the engine reacts when persisting an instance update fails
+type PersistenceFailurePolicy string
+
+const (
+	// PersistenceFailurePolicyFail returns the store error from the operation that made the update
+	PersistenceFailurePolicyFail PersistenceFailurePolicy = "fail"
+	// PersistenceFailurePolicyBuffer keeps running in memory and queues the update
+	// in a write-ahead buffer that is flushed once the store recovers
+	PersistenceFailurePolicyBuffer PersistenceFailurePolicy = "buffer"
+)
+
+// writeAheadBuffer queues instance updates the store rejected, in the order they were made
+type writeAheadBuffer struct {
+	policy  PersistenceFailurePolicy
+	pending []WorkflowInstance
+	mutex   sync.Mutex
+}
+
+// SetPersistenceFailurePolicy sets how failed instance updates are handled.
+// Under the buffer policy the engine's in-memory state runs ahead of the store
+// until the buffered updates are flushed, and updates to an instance are always
+// written in the order they were made. Saving a new instance still fails on error.
+func (engine *WorkflowRuntimeEngine) SetPersistenceFailurePolicy(policy PersistenceFailurePolicy) {
+	engine.writeAhead.mutex.Lock()
+	defer engine.writeAhead.mutex.Unlock()
+
+	engine.writeAhead.policy = policy
+}
+
+// PendingWrites returns the number of instance updates waiting in the write-ahead buffer
+func (engine *WorkflowRuntimeEngine) PendingWrites() int {
+	engine.writeAhead.mutex.Lock()
+	defer engine.writeAhead.mutex.Unlock()
+
+	return len(engine.writeAhead.pending)
+}
+
+// FlushPendingWrites writes buffered instance updates to the store in order,
+// stopping at the first failure, and returns the number still pending
+func (engine *WorkflowRuntimeEngine) FlushPendingWrites() (int, error) {
+	engine.writeAhead.mutex.Lock()
+	defer engine.writeAhead.mutex.Unlock()
+
+	err := engine.flushPendingWritesUnsafe()
+	return len(engine.writeAhead.pending), err
+}
+
+// flushPendingWritesUnsafe writes buffered updates until one fails.
+// This method assumes the caller already holds the write-ahead mutex lock.
+func (engine *WorkflowRuntimeEngine) flushPendingWritesUnsafe() error {
+	for len(engine.writeAhead.pending) > 0 {
+		instance := engine.writeAhead.pending[0]
+		if err := engine.persistenceStore.UpdateWorkflowInstance(instance); err != nil {
+			return fmt.Errorf("failed to flush update of workflow instance %s: %w", instance.ID, err)
+		}
+		engine.writeAhead.pending = engine.writeAhead.pending[1:]
+	}
+
+	engine.writeAhead.pending = nil
+	return nil
+}
+
+// updateInstance persists an instance update according to the persistence failure policy.
+// Earlier buffered updates are flushed first; while any remain, new updates queue
+// behind them so the store never sees updates out of order.
+func (engine *WorkflowRuntimeEngine) updateInstance(instance WorkflowInstance) error {
+	if instance.Ephemeral {
+		return nil
+	}
+
+	engine.writeAhead.mutex.Lock()
+	defer engine.writeAhead.mutex.Unlock()
+
+	err := engine.flushPendingWritesUnsafe()
+	if err == nil {
+		err = engine.persistenceStore.UpdateWorkflowInstance(instance)
+		if err == nil {
+			return nil
+		}
+	}
+
+	if engine.writeAhead.policy != PersistenceFailurePolicyBuffer && len(engine.writeAhead.pending) == 0 {
+		return err
+	}
+
+	engine.writeAhead.pending = append(engine.writeAhead.pending, instance)
+	engine.errorHandler.HandleError(instance.ID, fmt.Errorf("buffered workflow instance update: %w", err))
+	return nil
+}
+
+// guardStatus moves the stored status of an instance from its current status to
+// status in one atomic store operation, so engines sharing a store cannot apply
+// conflicting status changes, and returns ErrStatusConflict if another engine changed
+// it first. Store failures follow the persistence failure policy: when the update
+// would be buffered, the guard is skipped and the change is buffered by updateInstance.
+// Ephemeral instances are not in the store and are never guarded.
+func (engine *WorkflowRuntimeEngine) guardStatus(instance *WorkflowInstance, status WorkflowInstanceStatus) error {
+	if instance.Ephemeral {
+		return nil
+	}
+
+	engine.writeAhead.mutex.Lock()
+	defer engine.writeAhead.mutex.Unlock()
+
+	err := engine.flushPendingWritesUnsafe()
+	if err == nil {
+		err = engine.persistenceStore.CompareAndSetStatus(instance.ID, instance.Status, status)
+		if err == nil || errors.Is(err, ErrStatusConflict) {
+			return err
+		}
+	}
+
+	if engine.writeAhead.policy != PersistenceFailurePolicyBuffer && len(engine.writeAhead.pending) == 0 {
+		return err
+	}
+	return nil
+}
+
+// bufferedInstance returns the latest buffered update of an instance
+func (engine *WorkflowRuntimeEngine) bufferedInstance(instanceID WorkflowInstanceID) (WorkflowInstance, bool) {
+	engine.writeAhead.mutex.Lock()
+	defer engine.writeAhead.mutex.Unlock()
+
+	for index := len(engine.writeAhead.pending) - 1; index >= 0; index-- {
+		if engine.writeAhead.pending[index].ID == instanceID {
+			return engine.writeAhead.pending[index], true
+		}
+	}
+	return WorkflowInstance{}, false
+}
+
+// StartWriteAheadFlusher flushes buffered instance updates on the given interval until
+// the returned stop function is called. Flush errors are reported to the error handler.
+func (engine *WorkflowRuntimeEngine) StartWriteAheadFlusher(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := engine.FlushPendingWrites(); err != nil {
+					engine.errorHandler.HandleError("", fmt.Errorf("write-ahead flusher error: %w", err))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
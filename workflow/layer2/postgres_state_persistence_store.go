@@ -0,0 +1,713 @@
+package layer2
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// postgresSchema creates the tables of PostgresStatePersistenceStore. Instances keep
+// their context and metadata in JSONB columns beside the rest of the instance record;
+// states, transitions, work and contexts are stored as JSONB keyed by instance and ID.
+var postgresSchema = []string{
+	`CREATE TABLE IF NOT EXISTS workflow_instances (
+	id TEXT PRIMARY KEY,
+	definition_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	data JSONB NOT NULL,
+	context JSONB,
+	metadata JSONB,
+	updated_at TIMESTAMPTZ NOT NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS workflow_instances_definition_id_idx ON workflow_instances (definition_id)`,
+	`CREATE INDEX IF NOT EXISTS workflow_instances_status_idx ON workflow_instances (status)`,
+	postgresItemTableSchema("workflow_states"),
+	postgresItemTableSchema("workflow_transitions"),
+	postgresItemTableSchema("workflow_work"),
+	postgresItemTableSchema("workflow_contexts"),
+}
+
+// postgresItemTableSchema creates a table of per-instance items
+func postgresItemTableSchema(table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	instance_id TEXT NOT NULL REFERENCES workflow_instances (id),
+	id TEXT NOT NULL,
+	data JSONB NOT NULL,
+	PRIMARY KEY (instance_id, id)
+)`, table)
+}
+
+// postgresItemTables maps each kind of per-instance item to its table
+var postgresItemTables = map[string]string{
+	"states":      "workflow_states",
+	"transitions": "workflow_transitions",
+	"work":        "workflow_work",
+	"contexts":    "workflow_contexts",
+}
+
+const (
+	postgresInsertInstance = `INSERT INTO workflow_instances (id, definition_id, status, data, context, metadata, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (id) DO NOTHING`
+	postgresUpdateInstance = `UPDATE workflow_instances SET definition_id = $2, status = $3, data = $4, context = $5, metadata = $6, updated_at = $7 WHERE id = $1`
+	postgresSelectInstance = `SELECT data, context, metadata FROM workflow_instances WHERE id = $1`
+	postgresLockInstance   = postgresSelectInstance + ` FOR UPDATE`
+	postgresListInstances  = `SELECT data, context, metadata FROM workflow_instances`
+	postgresListDefinition = postgresListInstances + ` WHERE definition_id = $1`
+	postgresInstanceExists = `SELECT EXISTS (SELECT 1 FROM workflow_instances WHERE id = $1)`
+	postgresDeleteInstance = `DELETE FROM workflow_instances WHERE id = $1`
+	postgresCountByStatus  = `SELECT status, COUNT(*) FROM workflow_instances GROUP BY status`
+	postgresCountItems     = `SELECT (SELECT COUNT(*) FROM workflow_states), (SELECT COUNT(*) FROM workflow_transitions), (SELECT COUNT(*) FROM workflow_work), (SELECT COUNT(*) FROM workflow_contexts)`
+	postgresTruncate       = `TRUNCATE workflow_states, workflow_transitions, workflow_work, workflow_contexts, workflow_instances`
+
+	postgresInsertItem  = `INSERT INTO %s (instance_id, id, data) VALUES ($1, $2, $3) ON CONFLICT (instance_id, id) DO NOTHING`
+	postgresSelectItem  = `SELECT data FROM %s WHERE instance_id = $1 AND id = $2`
+	postgresUpdateItem  = `UPDATE %s SET data = $3 WHERE instance_id = $1 AND id = $2`
+	postgresListItems   = `SELECT data FROM %s WHERE instance_id = $1`
+	postgresDeleteItem  = `DELETE FROM %s WHERE instance_id = $1 AND id = $2`
+	postgresDeleteItems = `DELETE FROM %s WHERE instance_id = $1`
+)
+
+// PostgresStatePersistenceStore provides a StatePersistenceStore backed by PostgreSQL,
+// so instances survive process restarts. Callers open the *sql.DB with the driver of
+// their choice and create the tables with Migrate before using the store.
+type PostgresStatePersistenceStore struct {
+	db       *sql.DB
+	migrator *InstanceMigrator
+	codecs   *ContextValueCodecs
+}
+
+// NewPostgresStatePersistenceStore creates a new Postgres store on an open database
+func NewPostgresStatePersistenceStore(db *sql.DB) *PostgresStatePersistenceStore {
+	return &PostgresStatePersistenceStore{
+		db:       db,
+		migrator: NewInstanceMigrator(),
+	}
+}
+
+// OpenPostgresStatePersistenceStore opens a database with a registered driver, such as
+// "postgres" or "pgx", and creates a store on it with its tables migrated
+func OpenPostgresStatePersistenceStore(driverName, dataSourceName string) (*PostgresStatePersistenceStore, error) {
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	store := NewPostgresStatePersistenceStore(db)
+	if err := store.Migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Migrate creates the store's tables and indexes if they do not exist
+func (store *PostgresStatePersistenceStore) Migrate() error {
+	for _, statement := range postgresSchema {
+		if _, err := store.db.Exec(statement); err != nil {
+			return fmt.Errorf("failed to create schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetValueCodecs sets the codecs used to persist custom context value types.
+// Without codecs context values are persisted as plain JSON.
+func (store *PostgresStatePersistenceStore) SetValueCodecs(codecs *ContextValueCodecs) {
+	store.codecs = codecs
+}
+
+// postgresInstanceRow is an instance encoded into the columns of its row
+type postgresInstanceRow struct {
+	data     string
+	context  string
+	metadata string
+}
+
+// encodeInstance encodes an instance into its row, applying the value codecs to its
+// context. The context and metadata get their own columns and are left out of data.
+func (store *PostgresStatePersistenceStore) encodeInstance(instance WorkflowInstance) (postgresInstanceRow, error) {
+	encoded, err := store.codecs.EncodeContext(instance.Context)
+	if err != nil {
+		return postgresInstanceRow{}, err
+	}
+
+	contextJSON, err := json.Marshal(encoded)
+	if err != nil {
+		return postgresInstanceRow{}, err
+	}
+
+	metadataJSON, err := json.Marshal(instance.Metadata)
+	if err != nil {
+		return postgresInstanceRow{}, err
+	}
+
+	instance.Context = nil
+	instance.Metadata = nil
+	data, err := EncodeWorkflowInstance(instance)
+	if err != nil {
+		return postgresInstanceRow{}, err
+	}
+
+	return postgresInstanceRow{data: string(data), context: string(contextJSON), metadata: string(metadataJSON)}, nil
+}
+
+// decodeInstance reassembles an instance from its row, upgrading it to the current schema first
+func (store *PostgresStatePersistenceStore) decodeInstance(data, contextJSON, metadataJSON []byte) (WorkflowInstance, error) {
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(data, &record); err != nil {
+		return WorkflowInstance{}, fmt.Errorf("failed to decode workflow instance: %w", err)
+	}
+
+	if len(contextJSON) > 0 {
+		record["context"] = contextJSON
+	}
+	if len(metadataJSON) > 0 {
+		record["metadata"] = metadataJSON
+	}
+
+	assembled, err := json.Marshal(record)
+	if err != nil {
+		return WorkflowInstance{}, fmt.Errorf("failed to encode workflow instance: %w", err)
+	}
+
+	instance, err := store.migrator.DecodeWorkflowInstance(assembled)
+	if err != nil {
+		return WorkflowInstance{}, err
+	}
+
+	if instance.Context, err = store.codecs.DecodeContext(instance.Context); err != nil {
+		return WorkflowInstance{}, fmt.Errorf("failed to decode context of workflow instance %s: %w", instance.ID, err)
+	}
+
+	return instance, nil
+}
+
+// postgresRowScanner is implemented by *sql.Row and *sql.Rows
+type postgresRowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanInstance reads and decodes an instance row
+func (store *PostgresStatePersistenceStore) scanInstance(row postgresRowScanner) (WorkflowInstance, error) {
+	var data, contextJSON, metadataJSON []byte
+	if err := row.Scan(&data, &contextJSON, &metadataJSON); err != nil {
+		return WorkflowInstance{}, err
+	}
+	return store.decodeInstance(data, contextJSON, metadataJSON)
+}
+
+// SaveWorkflowInstance saves a workflow instance
+func (store *PostgresStatePersistenceStore) SaveWorkflowInstance(instance WorkflowInstance) error {
+	row, err := store.encodeInstance(instance)
+	if err != nil {
+		return fmt.Errorf("failed to encode workflow instance %s: %w", instance.ID, err)
+	}
+
+	result, err := store.db.Exec(postgresInsertInstance, string(instance.ID), string(instance.DefinitionID), string(instance.Status), row.data, row.context, row.metadata, instance.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save workflow instance %s: %w", instance.ID, err)
+	}
+
+	if created, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to save workflow instance %s: %w", instance.ID, err)
+	} else if created == 0 {
+		return fmt.Errorf("workflow instance %s already exists", instance.ID)
+	}
+
+	return nil
+}
+
+// GetWorkflowInstance retrieves a workflow instance
+func (store *PostgresStatePersistenceStore) GetWorkflowInstance(instanceID WorkflowInstanceID) (WorkflowInstance, error) {
+	instance, err := store.scanInstance(store.db.QueryRow(postgresSelectInstance, string(instanceID)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return WorkflowInstance{}, fmt.Errorf("workflow instance %s not found", instanceID)
+	}
+	if err != nil {
+		return WorkflowInstance{}, fmt.Errorf("failed to get workflow instance %s: %w", instanceID, err)
+	}
+	return instance, nil
+}
+
+// UpdateWorkflowInstance updates a workflow instance
+func (store *PostgresStatePersistenceStore) UpdateWorkflowInstance(instance WorkflowInstance) error {
+	instance.UpdatedAt = time.Now()
+	return store.updateInstance(store.db, instance)
+}
+
+// postgresExecer is implemented by *sql.DB and *sql.Tx
+type postgresExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// updateInstance writes an instance over its existing row
+func (store *PostgresStatePersistenceStore) updateInstance(execer postgresExecer, instance WorkflowInstance) error {
+	row, err := store.encodeInstance(instance)
+	if err != nil {
+		return fmt.Errorf("failed to encode workflow instance %s: %w", instance.ID, err)
+	}
+
+	result, err := execer.Exec(postgresUpdateInstance, string(instance.ID), string(instance.DefinitionID), string(instance.Status), row.data, row.context, row.metadata, instance.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update workflow instance %s: %w", instance.ID, err)
+	}
+
+	if updated, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update workflow instance %s: %w", instance.ID, err)
+	} else if updated == 0 {
+		return fmt.Errorf("workflow instance %s not found", instance.ID)
+	}
+
+	return nil
+}
+
+// CompareAndSetStatus atomically sets the status of an instance if it currently
+// equals expected, returning ErrStatusConflict otherwise. The instance's row is
+// locked from the check until the new status is written.
+func (store *PostgresStatePersistenceStore) CompareAndSetStatus(instanceID WorkflowInstanceID, expected, status WorkflowInstanceStatus) error {
+	tx, err := store.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	instance, err := store.scanInstance(tx.QueryRow(postgresLockInstance, string(instanceID)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("workflow instance %s not found", instanceID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get workflow instance %s: %w", instanceID, err)
+	}
+
+	if instance.Status != expected {
+		return fmt.Errorf("%w: workflow instance %s is %s, expected %s", ErrStatusConflict, instanceID, instance.Status, expected)
+	}
+
+	instance.Status = status
+	instance.UpdatedAt = time.Now()
+	if err := store.updateInstance(tx, instance); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update workflow instance %s: %w", instanceID, err)
+	}
+	return nil
+}
+
+// DeleteWorkflowInstance deletes a workflow instance and all its associated data in
+// a single transaction
+func (store *PostgresStatePersistenceStore) DeleteWorkflowInstance(instanceID WorkflowInstanceID) error {
+	tx, err := store.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, kind := range itemKinds {
+		if _, err := tx.Exec(fmt.Sprintf(postgresDeleteItems, postgresItemTables[kind]), string(instanceID)); err != nil {
+			return fmt.Errorf("failed to delete %s of workflow instance %s: %w", kind, instanceID, err)
+		}
+	}
+
+	result, err := tx.Exec(postgresDeleteInstance, string(instanceID))
+	if err != nil {
+		return fmt.Errorf("failed to delete workflow instance %s: %w", instanceID, err)
+	}
+
+	if deleted, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to delete workflow instance %s: %w", instanceID, err)
+	} else if deleted == 0 {
+		return fmt.Errorf("workflow instance %s not found", instanceID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete workflow instance %s: %w", instanceID, err)
+	}
+	return nil
+}
+
+// ListWorkflowInstances lists all workflow instances for a specific definition
+func (store *PostgresStatePersistenceStore) ListWorkflowInstances(definitionID layer1.WorkflowDefinitionID) ([]WorkflowInstance, error) {
+	return store.listInstances(postgresListDefinition, string(definitionID))
+}
+
+// ListAllWorkflowInstances lists all workflow instances
+func (store *PostgresStatePersistenceStore) ListAllWorkflowInstances() ([]WorkflowInstance, error) {
+	return store.listInstances(postgresListInstances)
+}
+
+// listInstances loads every instance a query selects
+func (store *PostgresStatePersistenceStore) listInstances(query string, args ...interface{}) ([]WorkflowInstance, error) {
+	rows, err := store.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflow instances: %w", err)
+	}
+	defer rows.Close()
+
+	var instances []WorkflowInstance
+	for rows.Next() {
+		instance, err := store.scanInstance(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list workflow instances: %w", err)
+		}
+		instances = append(instances, instance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list workflow instances: %w", err)
+	}
+	sortInstancesByID(instances)
+
+	return instances, nil
+}
+
+// requireInstance returns an error if the instance does not exist
+func (store *PostgresStatePersistenceStore) requireInstance(instanceID WorkflowInstanceID) error {
+	var exists bool
+	if err := store.db.QueryRow(postgresInstanceExists, string(instanceID)).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check workflow instance %s: %w", instanceID, err)
+	}
+
+	if !exists {
+		return fmt.Errorf("workflow instance %s not found", instanceID)
+	}
+
+	return nil
+}
+
+// saveItem stores a new per-instance item, failing if the ID is already present
+func (store *PostgresStatePersistenceStore) saveItem(kind, noun string, instanceID WorkflowInstanceID, itemID string, item interface{}) error {
+	if err := store.requireInstance(instanceID); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s %s: %w", noun, itemID, err)
+	}
+
+	result, err := store.db.Exec(fmt.Sprintf(postgresInsertItem, postgresItemTables[kind]), string(instanceID), itemID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save %s %s: %w", noun, itemID, err)
+	}
+
+	if created, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to save %s %s: %w", noun, itemID, err)
+	} else if created == 0 {
+		return fmt.Errorf("%s %s already exists for instance %s", noun, itemID, instanceID)
+	}
+
+	return nil
+}
+
+// getItem loads and decodes a per-instance item
+func (store *PostgresStatePersistenceStore) getItem(kind, noun string, instanceID WorkflowInstanceID, itemID string, item interface{}) error {
+	if err := store.requireInstance(instanceID); err != nil {
+		return err
+	}
+
+	var data []byte
+	err := store.db.QueryRow(fmt.Sprintf(postgresSelectItem, postgresItemTables[kind]), string(instanceID), itemID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s %s not found for instance %s", noun, itemID, instanceID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get %s %s: %w", noun, itemID, err)
+	}
+
+	if err := json.Unmarshal(data, item); err != nil {
+		return fmt.Errorf("failed to decode %s %s: %w", noun, itemID, err)
+	}
+
+	return nil
+}
+
+// updateItem replaces an existing per-instance item
+func (store *PostgresStatePersistenceStore) updateItem(kind, noun string, instanceID WorkflowInstanceID, itemID string, item interface{}) error {
+	if err := store.requireInstance(instanceID); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s %s: %w", noun, itemID, err)
+	}
+
+	result, err := store.db.Exec(fmt.Sprintf(postgresUpdateItem, postgresItemTables[kind]), string(instanceID), itemID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to update %s %s: %w", noun, itemID, err)
+	}
+
+	if updated, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to update %s %s: %w", noun, itemID, err)
+	} else if updated == 0 {
+		return fmt.Errorf("%s %s not found for instance %s", noun, itemID, instanceID)
+	}
+
+	return nil
+}
+
+// deleteItem removes an existing per-instance item
+func (store *PostgresStatePersistenceStore) deleteItem(kind, noun string, instanceID WorkflowInstanceID, itemID string) error {
+	if err := store.requireInstance(instanceID); err != nil {
+		return err
+	}
+
+	result, err := store.db.Exec(fmt.Sprintf(postgresDeleteItem, postgresItemTables[kind]), string(instanceID), itemID)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s %s: %w", noun, itemID, err)
+	}
+
+	if deleted, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to delete %s %s: %w", noun, itemID, err)
+	} else if deleted == 0 {
+		return fmt.Errorf("%s %s not found for instance %s", noun, itemID, instanceID)
+	}
+
+	return nil
+}
+
+// listItems returns the raw JSON of every per-instance item of a kind
+func (store *PostgresStatePersistenceStore) listItems(kind string, instanceID WorkflowInstanceID) ([][]byte, error) {
+	if err := store.requireInstance(instanceID); err != nil {
+		return nil, err
+	}
+
+	rows, err := store.db.Query(fmt.Sprintf(postgresListItems, postgresItemTables[kind]), string(instanceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s for instance %s: %w", kind, instanceID, err)
+	}
+	defer rows.Close()
+
+	var values [][]byte
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to list %s for instance %s: %w", kind, instanceID, err)
+		}
+		values = append(values, data)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list %s for instance %s: %w", kind, instanceID, err)
+	}
+
+	return values, nil
+}
+
+// SaveState saves a state for a workflow instance
+func (store *PostgresStatePersistenceStore) SaveState(instanceID WorkflowInstanceID, state layer0.State) error {
+	return store.saveItem("states", "state", instanceID, string(state.GetID()), state)
+}
+
+// GetState retrieves a state for a workflow instance
+func (store *PostgresStatePersistenceStore) GetState(instanceID WorkflowInstanceID, stateID layer0.StateID) (layer0.State, error) {
+	var state layer0.State
+	if err := store.getItem("states", "state", instanceID, string(stateID), &state); err != nil {
+		return layer0.State{}, err
+	}
+	return state, nil
+}
+
+// UpdateState updates a state for a workflow instance
+func (store *PostgresStatePersistenceStore) UpdateState(instanceID WorkflowInstanceID, state layer0.State) error {
+	return store.updateItem("states", "state", instanceID, string(state.GetID()), state)
+}
+
+// ListStates lists all states for a workflow instance
+func (store *PostgresStatePersistenceStore) ListStates(instanceID WorkflowInstanceID) ([]layer0.State, error) {
+	values, err := store.listItems("states", instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]layer0.State, 0, len(values))
+	for _, value := range values {
+		var state layer0.State
+		if err := json.Unmarshal(value, &state); err != nil {
+			return nil, fmt.Errorf("failed to decode state: %w", err)
+		}
+		states = append(states, state)
+	}
+	sortStatesByID(states)
+
+	return states, nil
+}
+
+// SaveTransition saves a transition for a workflow instance
+func (store *PostgresStatePersistenceStore) SaveTransition(instanceID WorkflowInstanceID, transition layer0.Transition) error {
+	return store.saveItem("transitions", "transition", instanceID, string(transition.GetID()), transition)
+}
+
+// GetTransition retrieves a transition for a workflow instance
+func (store *PostgresStatePersistenceStore) GetTransition(instanceID WorkflowInstanceID, transitionID layer0.TransitionID) (layer0.Transition, error) {
+	var transition layer0.Transition
+	if err := store.getItem("transitions", "transition", instanceID, string(transitionID), &transition); err != nil {
+		return layer0.Transition{}, err
+	}
+	return transition, nil
+}
+
+// UpdateTransition updates a transition for a workflow instance
+func (store *PostgresStatePersistenceStore) UpdateTransition(instanceID WorkflowInstanceID, transition layer0.Transition) error {
+	return store.updateItem("transitions", "transition", instanceID, string(transition.GetID()), transition)
+}
+
+// ListTransitions lists all transitions for a workflow instance
+func (store *PostgresStatePersistenceStore) ListTransitions(instanceID WorkflowInstanceID) ([]layer0.Transition, error) {
+	values, err := store.listItems("transitions", instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	transitions := make([]layer0.Transition, 0, len(values))
+	for _, value := range values {
+		var transition layer0.Transition
+		if err := json.Unmarshal(value, &transition); err != nil {
+			return nil, fmt.Errorf("failed to decode transition: %w", err)
+		}
+		transitions = append(transitions, transition)
+	}
+	sortTransitionsByID(transitions)
+
+	return transitions, nil
+}
+
+// SaveWork saves work for a workflow instance
+func (store *PostgresStatePersistenceStore) SaveWork(instanceID WorkflowInstanceID, work layer0.Work) error {
+	return store.saveItem("work", "work", instanceID, string(work.GetID()), work)
+}
+
+// GetWork retrieves work for a workflow instance
+func (store *PostgresStatePersistenceStore) GetWork(instanceID WorkflowInstanceID, workID layer0.WorkID) (layer0.Work, error) {
+	var work layer0.Work
+	if err := store.getItem("work", "work", instanceID, string(workID), &work); err != nil {
+		return layer0.Work{}, err
+	}
+	return work, nil
+}
+
+// UpdateWork updates work for a workflow instance
+func (store *PostgresStatePersistenceStore) UpdateWork(instanceID WorkflowInstanceID, work layer0.Work) error {
+	return store.updateItem("work", "work", instanceID, string(work.GetID()), work)
+}
+
+// ListWork lists all work for a workflow instance
+func (store *PostgresStatePersistenceStore) ListWork(instanceID WorkflowInstanceID) ([]layer0.Work, error) {
+	values, err := store.listItems("work", instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	workItems := make([]layer0.Work, 0, len(values))
+	for _, value := range values {
+		var work layer0.Work
+		if err := json.Unmarshal(value, &work); err != nil {
+			return nil, fmt.Errorf("failed to decode work: %w", err)
+		}
+		workItems = append(workItems, work)
+	}
+	sortWorkByID(workItems)
+
+	return workItems, nil
+}
+
+// SaveContext saves a context for a workflow instance
+func (store *PostgresStatePersistenceStore) SaveContext(instanceID WorkflowInstanceID, context *layer0.Context) error {
+	encoded, err := store.codecs.EncodeContext(context)
+	if err != nil {
+		return err
+	}
+	return store.saveItem("contexts", "context", instanceID, string(context.GetID()), encoded)
+}
+
+// GetContext retrieves a context for a workflow instance
+func (store *PostgresStatePersistenceStore) GetContext(instanceID WorkflowInstanceID, contextID layer0.ContextID) (*layer0.Context, error) {
+	context := &layer0.Context{}
+	if err := store.getItem("contexts", "context", instanceID, string(contextID), context); err != nil {
+		return nil, err
+	}
+	return store.codecs.DecodeContext(context)
+}
+
+// UpdateContext updates a context for a workflow instance
+func (store *PostgresStatePersistenceStore) UpdateContext(instanceID WorkflowInstanceID, context *layer0.Context) error {
+	encoded, err := store.codecs.EncodeContext(context)
+	if err != nil {
+		return err
+	}
+	return store.updateItem("contexts", "context", instanceID, string(context.GetID()), encoded)
+}
+
+// DeleteContext deletes a context of a workflow instance
+func (store *PostgresStatePersistenceStore) DeleteContext(instanceID WorkflowInstanceID, contextID layer0.ContextID) error {
+	return store.deleteItem("contexts", "context", instanceID, string(contextID))
+}
+
+// ListContexts lists all contexts for a workflow instance
+func (store *PostgresStatePersistenceStore) ListContexts(instanceID WorkflowInstanceID) ([]*layer0.Context, error) {
+	values, err := store.listItems("contexts", instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	contexts := make([]*layer0.Context, 0, len(values))
+	for _, value := range values {
+		context := &layer0.Context{}
+		if err := json.Unmarshal(value, context); err != nil {
+			return nil, fmt.Errorf("failed to decode context: %w", err)
+		}
+
+		decoded, err := store.codecs.DecodeContext(context)
+		if err != nil {
+			return nil, err
+		}
+		contexts = append(contexts, decoded)
+	}
+	sortContextsByID(contexts)
+
+	return contexts, nil
+}
+
+// Cleanup deletes every row in the store's tables
+func (store *PostgresStatePersistenceStore) Cleanup() error {
+	if _, err := store.db.Exec(postgresTruncate); err != nil {
+		return fmt.Errorf("failed to clean up store: %w", err)
+	}
+	return nil
+}
+
+// GetStats returns statistics about the store, counted by the database
+func (store *PostgresStatePersistenceStore) GetStats() (map[string]interface{}, error) {
+	rows, err := store.db.Query(postgresCountByStatus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count workflow instances: %w", err)
+	}
+	defer rows.Close()
+
+	instances := 0
+	instancesByStatus := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to count workflow instances: %w", err)
+		}
+		instancesByStatus[status] = count
+		instances += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count workflow instances: %w", err)
+	}
+
+	var states, transitions, work, contexts int
+	if err := store.db.QueryRow(postgresCountItems).Scan(&states, &transitions, &work, &contexts); err != nil {
+		return nil, fmt.Errorf("failed to count workflow instance items: %w", err)
+	}
+
+	return buildStoreStats(instances, states, transitions, work, contexts, instancesByStatus), nil
+}
@@ -0,0 +1,598 @@
+package layer2
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// fakePostgres is an in-memory database/sql driver understanding exactly the statements
+// PostgresStatePersistenceStore issues. Every statement, and the start and end of each
+// transaction, is recorded so tests can check what ran against the database.
+type fakePostgres struct {
+	mutex      sync.Mutex
+	instances  map[string][]driver.Value // id, definition_id, status, data, context, metadata, updated_at
+	items      map[string]map[[2]string]driver.Value
+	statements []string
+}
+
+func newFakePostgres() *fakePostgres {
+	items := make(map[string]map[[2]string]driver.Value)
+	for _, table := range postgresItemTables {
+		items[table] = make(map[[2]string]driver.Value)
+	}
+	return &fakePostgres{instances: make(map[string][]driver.Value), items: items}
+}
+
+func (db *fakePostgres) Open(name string) (driver.Conn, error) {
+	return &fakePostgresConn{db: db}, nil
+}
+
+func (db *fakePostgres) record(statement string) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.statements = append(db.statements, statement)
+}
+
+// takeStatements returns and clears the recorded statements
+func (db *fakePostgres) takeStatements() []string {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	statements := db.statements
+	db.statements = nil
+	return statements
+}
+
+// itemStatement reports which item table a templated statement targets
+func itemStatement(query, template string) (string, bool) {
+	for _, table := range postgresItemTables {
+		if query == fmt.Sprintf(template, table) {
+			return table, true
+		}
+	}
+	return "", false
+}
+
+type fakePostgresConn struct {
+	db *fakePostgres
+}
+
+func (c *fakePostgresConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepare not supported")
+}
+
+func (c *fakePostgresConn) Close() error {
+	return nil
+}
+
+func (c *fakePostgresConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakePostgresConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.record("BEGIN")
+	return &fakePostgresTx{db: c.db}, nil
+}
+
+type fakePostgresTx struct {
+	db *fakePostgres
+}
+
+func (tx *fakePostgresTx) Commit() error {
+	tx.db.record("COMMIT")
+	return nil
+}
+
+func (tx *fakePostgresTx) Rollback() error {
+	tx.db.record("ROLLBACK")
+	return nil
+}
+
+func (c *fakePostgresConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query)
+
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+
+	if strings.HasPrefix(query, "CREATE ") {
+		return driver.RowsAffected(0), nil
+	}
+
+	switch query {
+	case postgresInsertInstance:
+		id := args[0].Value.(string)
+		if _, exists := c.db.instances[id]; exists {
+			return driver.RowsAffected(0), nil
+		}
+		c.db.instances[id] = namedValues(args)
+		return driver.RowsAffected(1), nil
+	case postgresUpdateInstance:
+		id := args[0].Value.(string)
+		if _, exists := c.db.instances[id]; !exists {
+			return driver.RowsAffected(0), nil
+		}
+		c.db.instances[id] = namedValues(args)
+		return driver.RowsAffected(1), nil
+	case postgresDeleteInstance:
+		id := args[0].Value.(string)
+		for table, items := range c.db.items {
+			for key := range items {
+				if key[0] == id {
+					return nil, fmt.Errorf("%s rows still reference instance %s", table, id)
+				}
+			}
+		}
+		if _, exists := c.db.instances[id]; !exists {
+			return driver.RowsAffected(0), nil
+		}
+		delete(c.db.instances, id)
+		return driver.RowsAffected(1), nil
+	case postgresTruncate:
+		c.db.instances = make(map[string][]driver.Value)
+		for table := range c.db.items {
+			c.db.items[table] = make(map[[2]string]driver.Value)
+		}
+		return driver.RowsAffected(0), nil
+	}
+
+	if table, ok := itemStatement(query, postgresInsertItem); ok {
+		key := [2]string{args[0].Value.(string), args[1].Value.(string)}
+		if _, exists := c.db.items[table][key]; exists {
+			return driver.RowsAffected(0), nil
+		}
+		c.db.items[table][key] = args[2].Value
+		return driver.RowsAffected(1), nil
+	}
+	if table, ok := itemStatement(query, postgresUpdateItem); ok {
+		key := [2]string{args[0].Value.(string), args[1].Value.(string)}
+		if _, exists := c.db.items[table][key]; !exists {
+			return driver.RowsAffected(0), nil
+		}
+		c.db.items[table][key] = args[2].Value
+		return driver.RowsAffected(1), nil
+	}
+	if table, ok := itemStatement(query, postgresDeleteItem); ok {
+		key := [2]string{args[0].Value.(string), args[1].Value.(string)}
+		if _, exists := c.db.items[table][key]; !exists {
+			return driver.RowsAffected(0), nil
+		}
+		delete(c.db.items[table], key)
+		return driver.RowsAffected(1), nil
+	}
+	if table, ok := itemStatement(query, postgresDeleteItems); ok {
+		deleted := int64(0)
+		for key := range c.db.items[table] {
+			if key[0] == args[0].Value.(string) {
+				delete(c.db.items[table], key)
+				deleted++
+			}
+		}
+		return driver.RowsAffected(deleted), nil
+	}
+
+	return nil, fmt.Errorf("unsupported statement %q", query)
+}
+
+func (c *fakePostgresConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.record(query)
+
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+
+	rows := &fakePostgresRows{}
+	instanceRow := func(row []driver.Value) {
+		rows.rows = append(rows.rows, []driver.Value{row[3], row[4], row[5]})
+	}
+
+	switch query {
+	case postgresSelectInstance, postgresLockInstance:
+		rows.columns = []string{"data", "context", "metadata"}
+		if row, exists := c.db.instances[args[0].Value.(string)]; exists {
+			instanceRow(row)
+		}
+		return rows, nil
+	case postgresListInstances, postgresListDefinition:
+		rows.columns = []string{"data", "context", "metadata"}
+		for _, row := range c.db.instances {
+			if query == postgresListInstances || row[1] == args[0].Value {
+				instanceRow(row)
+			}
+		}
+		return rows, nil
+	case postgresInstanceExists:
+		_, exists := c.db.instances[args[0].Value.(string)]
+		rows.columns = []string{"exists"}
+		rows.rows = [][]driver.Value{{exists}}
+		return rows, nil
+	case postgresCountByStatus:
+		counts := make(map[string]int64)
+		for _, row := range c.db.instances {
+			counts[row[2].(string)]++
+		}
+		rows.columns = []string{"status", "count"}
+		for status, count := range counts {
+			rows.rows = append(rows.rows, []driver.Value{status, count})
+		}
+		return rows, nil
+	case postgresCountItems:
+		rows.columns = []string{"states", "transitions", "work", "contexts"}
+		rows.rows = [][]driver.Value{{
+			int64(len(c.db.items["workflow_states"])),
+			int64(len(c.db.items["workflow_transitions"])),
+			int64(len(c.db.items["workflow_work"])),
+			int64(len(c.db.items["workflow_contexts"])),
+		}}
+		return rows, nil
+	}
+
+	if table, ok := itemStatement(query, postgresSelectItem); ok {
+		rows.columns = []string{"data"}
+		if data, exists := c.db.items[table][[2]string{args[0].Value.(string), args[1].Value.(string)}]; exists {
+			rows.rows = [][]driver.Value{{data}}
+		}
+		return rows, nil
+	}
+	if table, ok := itemStatement(query, postgresListItems); ok {
+		rows.columns = []string{"data"}
+		for key, data := range c.db.items[table] {
+			if key[0] == args[0].Value.(string) {
+				rows.rows = append(rows.rows, []driver.Value{data})
+			}
+		}
+		return rows, nil
+	}
+
+	return nil, fmt.Errorf("unsupported query %q", query)
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for index, arg := range args {
+		values[index] = arg.Value
+	}
+	return values
+}
+
+type fakePostgresRows struct {
+	columns []string
+	rows    [][]driver.Value
+	index   int
+}
+
+func (r *fakePostgresRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakePostgresRows) Close() error {
+	return nil
+}
+
+func (r *fakePostgresRows) Next(dest []driver.Value) error {
+	if r.index >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.index])
+	r.index++
+	return nil
+}
+
+var fakePostgresCount int
+var fakePostgresMutex sync.Mutex
+
+// newPostgresTestStore creates a migrated store on a fresh fake database
+func newPostgresTestStore(t *testing.T) (*PostgresStatePersistenceStore, *fakePostgres) {
+	fakePostgresMutex.Lock()
+	fakePostgresCount++
+	driverName := fmt.Sprintf("fake-postgres-%d", fakePostgresCount)
+	fakePostgresMutex.Unlock()
+
+	fake := newFakePostgres()
+	sql.Register(driverName, fake)
+
+	store, err := OpenPostgresStatePersistenceStore(driverName, "")
+	if err != nil {
+		t.Fatalf("OpenPostgresStatePersistenceStore should not return error: %v", err)
+	}
+	t.Cleanup(func() { store.db.Close() })
+
+	if statements := fake.takeStatements(); len(statements) != len(postgresSchema) {
+		t.Errorf("Expected the schema to be created, got %d statements", len(statements))
+	}
+	return store, fake
+}
+
+func TestPostgresStatePersistenceStoreWorkflowInstances(t *testing.T) {
+	store, fake := newPostgresTestStore(t)
+
+	instance := newRedisTestInstance("test-instance", "test-definition", WorkflowInstanceStatusCreated)
+	instance.Context = instance.Context.Set("order_id", "order-1")
+	instance.Metadata["source"] = "api"
+
+	// Test SaveWorkflowInstance
+	if err := store.SaveWorkflowInstance(instance); err != nil {
+		t.Errorf("SaveWorkflowInstance should not return error: %v", err)
+	}
+
+	// The context and metadata are stored in their own JSONB columns
+	row := fake.instances["test-instance"]
+	if !strings.Contains(row[4].(string), "order-1") || !strings.Contains(row[5].(string), "api") {
+		t.Errorf("Expected the context and metadata columns to hold their JSON, got %v and %v", row[4], row[5])
+	}
+	if strings.Contains(row[3].(string), "order-1") {
+		t.Error("The instance data should not repeat the context")
+	}
+
+	if err := store.SaveWorkflowInstance(instance); err == nil {
+		t.Error("SaveWorkflowInstance should return error when saving duplicate instance")
+	}
+
+	// Test GetWorkflowInstance
+	retrieved, err := store.GetWorkflowInstance(instance.ID)
+	if err != nil {
+		t.Fatalf("GetWorkflowInstance should not return error: %v", err)
+	}
+	if retrieved.ID != instance.ID || retrieved.CurrentStateID != instance.CurrentStateID {
+		t.Error("Retrieved instance should match saved instance")
+	}
+	if orderID, _ := retrieved.Context.Get("order_id"); orderID != "order-1" || retrieved.Metadata["source"] != "api" {
+		t.Errorf("Expected the context and metadata to be restored, got %v and %v", orderID, retrieved.Metadata)
+	}
+	if retrieved.SchemaVersion != CurrentInstanceSchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", CurrentInstanceSchemaVersion, retrieved.SchemaVersion)
+	}
+
+	if _, err := store.GetWorkflowInstance("missing"); err == nil {
+		t.Error("GetWorkflowInstance should return error for non-existent instance")
+	}
+
+	// Test UpdateWorkflowInstance
+	instance.Status = WorkflowInstanceStatusRunning
+	if err := store.UpdateWorkflowInstance(instance); err != nil {
+		t.Errorf("UpdateWorkflowInstance should not return error: %v", err)
+	}
+	if retrieved, _ := store.GetWorkflowInstance(instance.ID); retrieved.Status != WorkflowInstanceStatusRunning {
+		t.Errorf("Expected status running, got %s", retrieved.Status)
+	}
+	if err := store.UpdateWorkflowInstance(newRedisTestInstance("missing", "test-definition", WorkflowInstanceStatusCreated)); err == nil {
+		t.Error("UpdateWorkflowInstance should return error for non-existent instance")
+	}
+
+	// Test ListWorkflowInstances
+	store.SaveWorkflowInstance(newRedisTestInstance("other-instance", "other-definition", WorkflowInstanceStatusCreated))
+
+	instances, err := store.ListWorkflowInstances("test-definition")
+	if err != nil {
+		t.Errorf("ListWorkflowInstances should not return error: %v", err)
+	}
+	if len(instances) != 1 || instances[0].ID != instance.ID {
+		t.Errorf("Expected only the test-definition instance, got %d instances", len(instances))
+	}
+
+	all, _ := store.ListAllWorkflowInstances()
+	if len(all) != 2 || all[0].ID != "other-instance" || all[1].ID != "test-instance" {
+		t.Errorf("Expected both instances ordered by ID, got %v", all)
+	}
+}
+
+func TestPostgresStatePersistenceStoreItems(t *testing.T) {
+	store, _ := newPostgresTestStore(t)
+	instance := newRedisTestInstance("test-instance", "test-definition", WorkflowInstanceStatusRunning)
+	store.SaveWorkflowInstance(instance)
+
+	// States
+	state := layer0.NewState("test-state", layer0.StateTypeInitial, "Test State")
+	if err := store.SaveState(instance.ID, state); err != nil {
+		t.Errorf("SaveState should not return error: %v", err)
+	}
+	if err := store.SaveState(instance.ID, state); err == nil {
+		t.Error("SaveState should return error when saving duplicate state")
+	}
+	if retrieved, err := store.GetState(instance.ID, state.GetID()); err != nil || retrieved.GetID() != state.GetID() {
+		t.Errorf("GetState should return the saved state: %v", err)
+	}
+	if err := store.UpdateState(instance.ID, state.SetStatus(layer0.StateStatusActive)); err != nil {
+		t.Errorf("UpdateState should not return error: %v", err)
+	}
+	if retrieved, _ := store.GetState(instance.ID, state.GetID()); retrieved.GetStatus() != layer0.StateStatusActive {
+		t.Error("UpdateState should replace the stored state")
+	}
+	if err := store.UpdateState(instance.ID, layer0.NewState("missing", layer0.StateTypeFinal, "Missing")); err == nil {
+		t.Error("UpdateState should return error for non-existent state")
+	}
+	if _, err := store.GetState(instance.ID, "missing"); err == nil {
+		t.Error("GetState should return error for non-existent state")
+	}
+	if states, _ := store.ListStates(instance.ID); len(states) != 1 {
+		t.Errorf("Expected 1 state, got %d", len(states))
+	}
+
+	// Transitions
+	transition := layer0.NewTransition("test-transition", layer0.TransitionTypeAutomatic, "from", "to", "Test Transition")
+	if err := store.SaveTransition(instance.ID, transition); err != nil {
+		t.Errorf("SaveTransition should not return error: %v", err)
+	}
+	if retrieved, err := store.GetTransition(instance.ID, transition.GetID()); err != nil || retrieved.GetToStateID() != "to" {
+		t.Errorf("GetTransition should return the saved transition: %v", err)
+	}
+	if err := store.UpdateTransition(instance.ID, transition.SetStatus(layer0.TransitionStatusCompleted)); err != nil {
+		t.Errorf("UpdateTransition should not return error: %v", err)
+	}
+	if transitions, _ := store.ListTransitions(instance.ID); len(transitions) != 1 || !transitions[0].IsCompleted() {
+		t.Error("ListTransitions should return the updated transition")
+	}
+
+	// Work
+	for _, id := range []layer0.WorkID{"work-b", "work-a"} {
+		if err := store.SaveWork(instance.ID, layer0.NewWork(id, layer0.WorkTypeTask, "Test Work")); err != nil {
+			t.Errorf("SaveWork should not return error: %v", err)
+		}
+	}
+	if retrieved, err := store.GetWork(instance.ID, "work-a"); err != nil || retrieved.GetID() != "work-a" {
+		t.Errorf("GetWork should return the saved work: %v", err)
+	}
+	if workItems, _ := store.ListWork(instance.ID); len(workItems) != 2 || workItems[0].GetID() != "work-a" {
+		t.Errorf("Expected 2 work items ordered by ID, got %v", workItems)
+	}
+
+	// Contexts
+	context := layer0.NewContext("test-context", layer0.ContextScopeState, "Test Context").Set("key", "value")
+	if err := store.SaveContext(instance.ID, context); err != nil {
+		t.Errorf("SaveContext should not return error: %v", err)
+	}
+	if err := store.UpdateContext(instance.ID, context.Set("new-key", "new-value")); err != nil {
+		t.Errorf("UpdateContext should not return error: %v", err)
+	}
+	retrievedContext, err := store.GetContext(instance.ID, context.GetID())
+	if err != nil {
+		t.Errorf("GetContext should not return error: %v", err)
+	} else if value, _ := retrievedContext.Get("new-key"); value != "new-value" {
+		t.Error("GetContext should return the updated context data")
+	}
+	if contexts, _ := store.ListContexts(instance.ID); len(contexts) != 1 {
+		t.Errorf("Expected 1 context, got %d", len(contexts))
+	}
+	if err := store.DeleteContext(instance.ID, context.GetID()); err != nil {
+		t.Errorf("DeleteContext should not return error: %v", err)
+	}
+	if contexts, _ := store.ListContexts(instance.ID); len(contexts) != 0 {
+		t.Errorf("Expected no contexts after DeleteContext, got %d", len(contexts))
+	}
+	if err := store.DeleteContext(instance.ID, context.GetID()); err == nil {
+		t.Error("DeleteContext should return error for a missing context")
+	}
+
+	// Operations on a non-existent instance fail
+	if err := store.SaveState("non-existent", state); err == nil {
+		t.Error("SaveState should return error for non-existent instance")
+	}
+	if _, err := store.ListWork("non-existent"); err == nil {
+		t.Error("ListWork should return error for non-existent instance")
+	}
+}
+
+func TestPostgresStatePersistenceStoreDeleteCascades(t *testing.T) {
+	store, fake := newPostgresTestStore(t)
+	instance := newRedisTestInstance("doomed", "test-definition", WorkflowInstanceStatusCompleted)
+	store.SaveWorkflowInstance(instance)
+	store.SaveState(instance.ID, layer0.NewState("a", layer0.StateTypeInitial, "A"))
+	store.SaveTransition(instance.ID, layer0.NewTransition("t", layer0.TransitionTypeAutomatic, "a", "b", "T"))
+	store.SaveWork(instance.ID, layer0.NewWork("work", layer0.WorkTypeTask, "Work"))
+	store.SaveContext(instance.ID, layer0.NewContext("ctx", layer0.ContextScopeState, "Context"))
+
+	survivor := newRedisTestInstance("survivor", "test-definition", WorkflowInstanceStatusRunning)
+	store.SaveWorkflowInstance(survivor)
+	store.SaveState(survivor.ID, layer0.NewState("a", layer0.StateTypeInitial, "A"))
+	fake.takeStatements()
+
+	if err := store.DeleteWorkflowInstance(instance.ID); err != nil {
+		t.Fatalf("DeleteWorkflowInstance should not return error: %v", err)
+	}
+
+	// Every child table and the instance are deleted inside one transaction
+	statements := fake.takeStatements()
+	if len(statements) < 2 || statements[0] != "BEGIN" || statements[len(statements)-2] != postgresDeleteInstance || statements[len(statements)-1] != "COMMIT" {
+		t.Fatalf("Expected the deletes to run in one transaction, got %v", statements)
+	}
+	var deletedTables []string
+	for _, statement := range statements[1 : len(statements)-2] {
+		for _, table := range postgresItemTables {
+			if statement == fmt.Sprintf(postgresDeleteItems, table) {
+				deletedTables = append(deletedTables, table)
+			}
+		}
+	}
+	sort.Strings(deletedTables)
+	if strings.Join(deletedTables, ",") != "workflow_contexts,workflow_states,workflow_transitions,workflow_work" {
+		t.Errorf("Expected every child table to be deleted from, got %v", deletedTables)
+	}
+
+	if _, err := store.GetWorkflowInstance(instance.ID); err == nil {
+		t.Error("GetWorkflowInstance should return error for deleted instance")
+	}
+	stats, _ := store.GetStats()
+	if stats["workflow_instances"] != 1 || stats["total_states"] != 1 || stats["total_work"] != 0 || stats["total_contexts"] != 0 {
+		t.Errorf("Expected only the survivor's data to remain, got %v", stats)
+	}
+
+	// A missing instance rolls the transaction back
+	fake.takeStatements()
+	if err := store.DeleteWorkflowInstance(instance.ID); err == nil {
+		t.Error("DeleteWorkflowInstance should return error for non-existent instance")
+	}
+	if statements := fake.takeStatements(); statements[len(statements)-1] != "ROLLBACK" {
+		t.Errorf("Expected the transaction to be rolled back, got %v", statements)
+	}
+}
+
+func TestPostgresStatePersistenceStoreCleanupAndStats(t *testing.T) {
+	store, fake := newPostgresTestStore(t)
+
+	first := newRedisTestInstance("first", "test-definition", WorkflowInstanceStatusRunning)
+	second := newRedisTestInstance("second", "test-definition", WorkflowInstanceStatusCompleted)
+	store.SaveWorkflowInstance(first)
+	store.SaveWorkflowInstance(second)
+	store.SaveState(first.ID, layer0.NewState("a", layer0.StateTypeInitial, "A"))
+	store.SaveState(first.ID, layer0.NewState("b", layer0.StateTypeFinal, "B"))
+	store.SaveWork(second.ID, layer0.NewWork("work", layer0.WorkTypeTask, "Work"))
+	fake.takeStatements()
+
+	stats, err := store.GetStats()
+	if err != nil {
+		t.Fatalf("GetStats should not return error: %v", err)
+	}
+	if stats["workflow_instances"] != 2 || stats["total_states"] != 2 || stats["total_work"] != 1 {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+	byStatus := stats["instances_by_status"].(map[string]int)
+	if byStatus["running"] != 1 || byStatus["completed"] != 1 {
+		t.Errorf("Unexpected per-status stats: %v", byStatus)
+	}
+
+	// Stats are counted by the database rather than by loading rows
+	if statements := fake.takeStatements(); len(statements) != 2 || statements[0] != postgresCountByStatus || statements[1] != postgresCountItems {
+		t.Errorf("Expected only the aggregate count queries, got %v", statements)
+	}
+
+	if err := store.Cleanup(); err != nil {
+		t.Errorf("Cleanup should not return error: %v", err)
+	}
+	stats, _ = store.GetStats()
+	if stats["workflow_instances"] != 0 || stats["total_states"] != 0 {
+		t.Errorf("Expected an empty store after cleanup, got %v", stats)
+	}
+}
+
+func TestPostgresStatePersistenceStoreCompareAndSetStatus(t *testing.T) {
+	store, fake := newPostgresTestStore(t)
+	store.SaveWorkflowInstance(newRedisTestInstance("guarded", "test-definition", WorkflowInstanceStatusRunning))
+	fake.takeStatements()
+
+	if err := store.CompareAndSetStatus("guarded", WorkflowInstanceStatusRunning, WorkflowInstanceStatusPaused); err != nil {
+		t.Fatalf("CompareAndSetStatus should not return error: %v", err)
+	}
+	if instance, _ := store.GetWorkflowInstance("guarded"); instance.Status != WorkflowInstanceStatusPaused {
+		t.Errorf("Expected status paused, got %s", instance.Status)
+	}
+
+	// The row is locked while the status is checked and written
+	statements := fake.takeStatements()
+	if len(statements) < 4 || statements[0] != "BEGIN" || statements[1] != postgresLockInstance || statements[2] != postgresUpdateInstance || statements[3] != "COMMIT" {
+		t.Errorf("Expected a locked read and write in one transaction, got %v", statements)
+	}
+
+	if err := store.CompareAndSetStatus("guarded", WorkflowInstanceStatusRunning, WorkflowInstanceStatusCancelled); !errors.Is(err, ErrStatusConflict) {
+		t.Errorf("Expected a status conflict, got %v", err)
+	}
+	if err := store.CompareAndSetStatus("missing", WorkflowInstanceStatusRunning, WorkflowInstanceStatusCancelled); err == nil || errors.Is(err, ErrStatusConflict) {
+		t.Errorf("Expected a not found error for a missing instance, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package layer2
+
+import (
+	"fmt"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// Progress estimates how far an instance has come as a fraction from 0 to 1, along
+// with its current state. It compares the current state's distance to the nearest
+// final state with the initial state's, using breadth-first distances precomputed
+// when the state machine is compiled. The estimate is exact for linear workflows;
+// with branches it assumes the shortest remaining path is taken, and a loop back
+// towards the start lowers it again. Completed instances report 1.
+func (engine *WorkflowRuntimeEngine) Progress(instanceID WorkflowInstanceID) (float64, layer0.StateID, error) {
+	instance, err := engine.GetWorkflowInstance(instanceID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	engine.mutex.RLock()
+	stateID := instance.CurrentStateID
+	status := instance.Status
+	engine.mutex.RUnlock()
+
+	if status == WorkflowInstanceStatusCompleted {
+		return 1, stateID, nil
+	}
+
+	remaining, reachable := engine.stateMachineCore.DistanceToFinal(stateID)
+	if !reachable {
+		return 0, stateID, fmt.Errorf("no final state is reachable from state %s", stateID)
+	}
+
+	total, reachable := engine.stateMachineCore.DistanceToFinal(engine.initialStateID)
+	if !reachable || total == 0 || remaining >= total {
+		if remaining == 0 {
+			return 1, stateID, nil
+		}
+		return 0, stateID, nil
+	}
+
+	return float64(total-remaining) / float64(total), stateID, nil
+}
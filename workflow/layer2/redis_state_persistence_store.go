@@ -0,0 +1,688 @@
+package layer2
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// RedisClient defines the subset of Redis commands used by RedisStatePersistenceStore.
+// Adapters for concrete clients implement it; a missing key or field is reported
+// through the found result rather than as an error.
+type RedisClient interface {
+	Get(key string) (value string, found bool, err error)
+	Set(key, value string) error
+	SetNX(key, value string) (bool, error)
+	Exists(key string) (bool, error)
+	Del(keys ...string) error
+	HGet(key, field string) (value string, found bool, err error)
+	HSet(key, field, value string) error
+	HSetNX(key, field, value string) (bool, error)
+	HExists(key, field string) (bool, error)
+	HDel(key string, fields ...string) (removed int, err error)
+	HVals(key string) ([]string, error)
+	HLen(key string) (int, error)
+	SAdd(key string, members ...string) error
+	SRem(key string, members ...string) error
+	SMembers(key string) ([]string, error)
+	SCard(key string) (int, error)
+}
+
+// RedisCompareAndSwapClient is implemented by Redis clients that can replace a key's
+// value only while it still holds an expected value, for example with WATCH and
+// MULTI or a script. RedisStatePersistenceStore uses it to make CompareAndSetStatus
+// atomic across replicas.
+type RedisCompareAndSwapClient interface {
+	RedisClient
+	CompareAndSwap(key, expected, value string) (swapped bool, err error)
+}
+
+// RedisStatePersistenceStore provides a StatePersistenceStore backed by Redis.
+// Instances are stored as JSON strings and their states, transitions, work and
+// contexts as hashes keyed by ID; index sets track instances by definition and
+// status so listing and stats never scan the keyspace.
+type RedisStatePersistenceStore struct {
+	client    RedisClient
+	namespace string
+	migrator  *InstanceMigrator
+	codecs    *ContextValueCodecs
+}
+
+// NewRedisStatePersistenceStore creates a new Redis store; an empty namespace defaults to "wf"
+func NewRedisStatePersistenceStore(client RedisClient, namespace string) *RedisStatePersistenceStore {
+	if namespace == "" {
+		namespace = "wf"
+	}
+
+	return &RedisStatePersistenceStore{
+		client:    client,
+		namespace: namespace,
+		migrator:  NewInstanceMigrator(),
+	}
+}
+
+// SetValueCodecs sets the codecs used to persist custom context value types.
+// Without codecs context values are persisted as plain JSON.
+func (store *RedisStatePersistenceStore) SetValueCodecs(codecs *ContextValueCodecs) {
+	store.codecs = codecs
+}
+
+// encodeInstance encodes an instance, applying the value codecs to its context
+func (store *RedisStatePersistenceStore) encodeInstance(instance WorkflowInstance) ([]byte, error) {
+	encoded, err := store.codecs.EncodeContext(instance.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	instance.Context = encoded
+	return EncodeWorkflowInstance(instance)
+}
+
+// instanceKey returns the key holding an instance's JSON
+func (store *RedisStatePersistenceStore) instanceKey(instanceID WorkflowInstanceID) string {
+	return fmt.Sprintf("%s:instance:%s", store.namespace, instanceID)
+}
+
+// itemsKey returns the hash key holding one kind of per-instance item
+func (store *RedisStatePersistenceStore) itemsKey(kind string, instanceID WorkflowInstanceID) string {
+	return fmt.Sprintf("%s:%s:%s", store.namespace, kind, instanceID)
+}
+
+// instancesKey returns the set of all instance IDs
+func (store *RedisStatePersistenceStore) instancesKey() string {
+	return store.namespace + ":instances"
+}
+
+// definitionsKey returns the set of definition IDs with indexed instances
+func (store *RedisStatePersistenceStore) definitionsKey() string {
+	return store.namespace + ":definitions"
+}
+
+// definitionIndexKey returns the set of instance IDs for a definition
+func (store *RedisStatePersistenceStore) definitionIndexKey(definitionID layer1.WorkflowDefinitionID) string {
+	return fmt.Sprintf("%s:definition:%s", store.namespace, definitionID)
+}
+
+// statusIndexKey returns the set of instance IDs with a status
+func (store *RedisStatePersistenceStore) statusIndexKey(status WorkflowInstanceStatus) string {
+	return fmt.Sprintf("%s:status:%s", store.namespace, status)
+}
+
+// itemKinds lists the per-instance hashes
+var itemKinds = []string{"states", "transitions", "work", "contexts"}
+
+// SaveWorkflowInstance saves a workflow instance
+func (store *RedisStatePersistenceStore) SaveWorkflowInstance(instance WorkflowInstance) error {
+	data, err := store.encodeInstance(instance)
+	if err != nil {
+		return fmt.Errorf("failed to encode workflow instance %s: %w", instance.ID, err)
+	}
+
+	created, err := store.client.SetNX(store.instanceKey(instance.ID), string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save workflow instance %s: %w", instance.ID, err)
+	}
+
+	if !created {
+		return fmt.Errorf("workflow instance %s already exists", instance.ID)
+	}
+
+	return store.addToIndexes(instance)
+}
+
+// GetWorkflowInstance retrieves a workflow instance
+func (store *RedisStatePersistenceStore) GetWorkflowInstance(instanceID WorkflowInstanceID) (WorkflowInstance, error) {
+	instance, _, err := store.getInstance(instanceID)
+	return instance, err
+}
+
+// getInstance retrieves a workflow instance along with the JSON stored for it
+func (store *RedisStatePersistenceStore) getInstance(instanceID WorkflowInstanceID) (WorkflowInstance, string, error) {
+	data, found, err := store.client.Get(store.instanceKey(instanceID))
+	if err != nil {
+		return WorkflowInstance{}, "", fmt.Errorf("failed to get workflow instance %s: %w", instanceID, err)
+	}
+
+	if !found {
+		return WorkflowInstance{}, "", fmt.Errorf("workflow instance %s not found", instanceID)
+	}
+
+	instance, err := store.migrator.DecodeWorkflowInstance([]byte(data))
+	if err != nil {
+		return WorkflowInstance{}, "", err
+	}
+
+	if instance.Context, err = store.codecs.DecodeContext(instance.Context); err != nil {
+		return WorkflowInstance{}, "", fmt.Errorf("failed to decode context of workflow instance %s: %w", instanceID, err)
+	}
+
+	return instance, data, nil
+}
+
+// UpdateWorkflowInstance updates a workflow instance
+func (store *RedisStatePersistenceStore) UpdateWorkflowInstance(instance WorkflowInstance) error {
+	existing, err := store.GetWorkflowInstance(instance.ID)
+	if err != nil {
+		return err
+	}
+
+	instance.UpdatedAt = time.Now()
+	data, err := store.encodeInstance(instance)
+	if err != nil {
+		return fmt.Errorf("failed to encode workflow instance %s: %w", instance.ID, err)
+	}
+
+	if err := store.client.Set(store.instanceKey(instance.ID), string(data)); err != nil {
+		return fmt.Errorf("failed to update workflow instance %s: %w", instance.ID, err)
+	}
+
+	// Move the instance between index sets if its definition or status changed
+	if existing.DefinitionID != instance.DefinitionID || existing.Status != instance.Status {
+		if err := store.removeFromIndexes(existing); err != nil {
+			return err
+		}
+		return store.addToIndexes(instance)
+	}
+
+	return nil
+}
+
+// CompareAndSetStatus sets the status of an instance if it currently equals expected,
+// returning ErrStatusConflict otherwise. The write only succeeds if the instance is
+// unchanged since it was read when the client implements RedisCompareAndSwapClient;
+// other clients check and write the status in separate commands.
+func (store *RedisStatePersistenceStore) CompareAndSetStatus(instanceID WorkflowInstanceID, expected, status WorkflowInstanceStatus) error {
+	instance, data, err := store.getInstance(instanceID)
+	if err != nil {
+		return err
+	}
+
+	if instance.Status != expected {
+		return fmt.Errorf("%w: workflow instance %s is %s, expected %s", ErrStatusConflict, instanceID, instance.Status, expected)
+	}
+
+	instance.Status = status
+	instance.UpdatedAt = time.Now()
+	updated, err := store.encodeInstance(instance)
+	if err != nil {
+		return fmt.Errorf("failed to encode workflow instance %s: %w", instanceID, err)
+	}
+
+	key := store.instanceKey(instanceID)
+	if swapper, ok := store.client.(RedisCompareAndSwapClient); ok {
+		swapped, err := swapper.CompareAndSwap(key, data, string(updated))
+		if err != nil {
+			return fmt.Errorf("failed to update workflow instance %s: %w", instanceID, err)
+		}
+		if !swapped {
+			return fmt.Errorf("%w: workflow instance %s changed while its status was set", ErrStatusConflict, instanceID)
+		}
+	} else if err := store.client.Set(key, string(updated)); err != nil {
+		return fmt.Errorf("failed to update workflow instance %s: %w", instanceID, err)
+	}
+
+	if err := store.client.SRem(store.statusIndexKey(expected), string(instanceID)); err != nil {
+		return fmt.Errorf("failed to unindex workflow instance %s: %w", instanceID, err)
+	}
+	if err := store.client.SAdd(store.statusIndexKey(status), string(instanceID)); err != nil {
+		return fmt.Errorf("failed to index workflow instance %s: %w", instanceID, err)
+	}
+
+	return nil
+}
+
+// DeleteWorkflowInstance deletes a workflow instance and all its associated data
+func (store *RedisStatePersistenceStore) DeleteWorkflowInstance(instanceID WorkflowInstanceID) error {
+	instance, err := store.GetWorkflowInstance(instanceID)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{store.instanceKey(instanceID)}
+	for _, kind := range itemKinds {
+		keys = append(keys, store.itemsKey(kind, instanceID))
+	}
+
+	if err := store.client.Del(keys...); err != nil {
+		return fmt.Errorf("failed to delete workflow instance %s: %w", instanceID, err)
+	}
+
+	return store.removeFromIndexes(instance)
+}
+
+// ListWorkflowInstances lists all workflow instances for a specific definition
+func (store *RedisStatePersistenceStore) ListWorkflowInstances(definitionID layer1.WorkflowDefinitionID) ([]WorkflowInstance, error) {
+	return store.listInstances(store.definitionIndexKey(definitionID))
+}
+
+// ListAllWorkflowInstances lists all workflow instances
+func (store *RedisStatePersistenceStore) ListAllWorkflowInstances() ([]WorkflowInstance, error) {
+	return store.listInstances(store.instancesKey())
+}
+
+// listInstances loads every instance whose ID is in the given index set
+func (store *RedisStatePersistenceStore) listInstances(indexKey string) ([]WorkflowInstance, error) {
+	instanceIDs, err := store.client.SMembers(indexKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflow instances: %w", err)
+	}
+
+	instances := make([]WorkflowInstance, 0, len(instanceIDs))
+	for _, instanceID := range instanceIDs {
+		instance, err := store.GetWorkflowInstance(WorkflowInstanceID(instanceID))
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+	sortInstancesByID(instances)
+
+	return instances, nil
+}
+
+// addToIndexes adds an instance to the index sets
+func (store *RedisStatePersistenceStore) addToIndexes(instance WorkflowInstance) error {
+	id := string(instance.ID)
+	if err := store.client.SAdd(store.instancesKey(), id); err != nil {
+		return fmt.Errorf("failed to index workflow instance %s: %w", instance.ID, err)
+	}
+
+	if err := store.client.SAdd(store.definitionsKey(), string(instance.DefinitionID)); err != nil {
+		return fmt.Errorf("failed to index workflow instance %s: %w", instance.ID, err)
+	}
+
+	if err := store.client.SAdd(store.definitionIndexKey(instance.DefinitionID), id); err != nil {
+		return fmt.Errorf("failed to index workflow instance %s: %w", instance.ID, err)
+	}
+
+	if err := store.client.SAdd(store.statusIndexKey(instance.Status), id); err != nil {
+		return fmt.Errorf("failed to index workflow instance %s: %w", instance.ID, err)
+	}
+
+	return nil
+}
+
+// removeFromIndexes removes an instance from the index sets
+func (store *RedisStatePersistenceStore) removeFromIndexes(instance WorkflowInstance) error {
+	id := string(instance.ID)
+	if err := store.client.SRem(store.instancesKey(), id); err != nil {
+		return fmt.Errorf("failed to unindex workflow instance %s: %w", instance.ID, err)
+	}
+
+	if err := store.client.SRem(store.definitionIndexKey(instance.DefinitionID), id); err != nil {
+		return fmt.Errorf("failed to unindex workflow instance %s: %w", instance.ID, err)
+	}
+
+	if err := store.client.SRem(store.statusIndexKey(instance.Status), id); err != nil {
+		return fmt.Errorf("failed to unindex workflow instance %s: %w", instance.ID, err)
+	}
+
+	return nil
+}
+
+// requireInstance returns an error if the instance does not exist
+func (store *RedisStatePersistenceStore) requireInstance(instanceID WorkflowInstanceID) error {
+	exists, err := store.client.Exists(store.instanceKey(instanceID))
+	if err != nil {
+		return fmt.Errorf("failed to check workflow instance %s: %w", instanceID, err)
+	}
+
+	if !exists {
+		return fmt.Errorf("workflow instance %s not found", instanceID)
+	}
+
+	return nil
+}
+
+// saveItem stores a new per-instance item, failing if the ID is already present
+func (store *RedisStatePersistenceStore) saveItem(kind, noun string, instanceID WorkflowInstanceID, itemID string, item interface{}) error {
+	if err := store.requireInstance(instanceID); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s %s: %w", noun, itemID, err)
+	}
+
+	created, err := store.client.HSetNX(store.itemsKey(kind, instanceID), itemID, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save %s %s: %w", noun, itemID, err)
+	}
+
+	if !created {
+		return fmt.Errorf("%s %s already exists for instance %s", noun, itemID, instanceID)
+	}
+
+	return nil
+}
+
+// getItem loads and decodes a per-instance item
+func (store *RedisStatePersistenceStore) getItem(kind, noun string, instanceID WorkflowInstanceID, itemID string, item interface{}) error {
+	if err := store.requireInstance(instanceID); err != nil {
+		return err
+	}
+
+	data, found, err := store.client.HGet(store.itemsKey(kind, instanceID), itemID)
+	if err != nil {
+		return fmt.Errorf("failed to get %s %s: %w", noun, itemID, err)
+	}
+
+	if !found {
+		return fmt.Errorf("%s %s not found for instance %s", noun, itemID, instanceID)
+	}
+
+	if err := json.Unmarshal([]byte(data), item); err != nil {
+		return fmt.Errorf("failed to decode %s %s: %w", noun, itemID, err)
+	}
+
+	return nil
+}
+
+// updateItem replaces an existing per-instance item
+func (store *RedisStatePersistenceStore) updateItem(kind, noun string, instanceID WorkflowInstanceID, itemID string, item interface{}) error {
+	if err := store.requireInstance(instanceID); err != nil {
+		return err
+	}
+
+	exists, err := store.client.HExists(store.itemsKey(kind, instanceID), itemID)
+	if err != nil {
+		return fmt.Errorf("failed to check %s %s: %w", noun, itemID, err)
+	}
+
+	if !exists {
+		return fmt.Errorf("%s %s not found for instance %s", noun, itemID, instanceID)
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s %s: %w", noun, itemID, err)
+	}
+
+	if err := store.client.HSet(store.itemsKey(kind, instanceID), itemID, string(data)); err != nil {
+		return fmt.Errorf("failed to update %s %s: %w", noun, itemID, err)
+	}
+
+	return nil
+}
+
+// deleteItem removes an existing per-instance item
+func (store *RedisStatePersistenceStore) deleteItem(kind, noun string, instanceID WorkflowInstanceID, itemID string) error {
+	if err := store.requireInstance(instanceID); err != nil {
+		return err
+	}
+
+	removed, err := store.client.HDel(store.itemsKey(kind, instanceID), itemID)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s %s: %w", noun, itemID, err)
+	}
+
+	if removed == 0 {
+		return fmt.Errorf("%s %s not found for instance %s", noun, itemID, instanceID)
+	}
+
+	return nil
+}
+
+// listItems returns the raw JSON of every per-instance item of a kind
+func (store *RedisStatePersistenceStore) listItems(kind string, instanceID WorkflowInstanceID) ([]string, error) {
+	if err := store.requireInstance(instanceID); err != nil {
+		return nil, err
+	}
+
+	values, err := store.client.HVals(store.itemsKey(kind, instanceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s for instance %s: %w", kind, instanceID, err)
+	}
+
+	return values, nil
+}
+
+// SaveState saves a state for a workflow instance
+func (store *RedisStatePersistenceStore) SaveState(instanceID WorkflowInstanceID, state layer0.State) error {
+	return store.saveItem("states", "state", instanceID, string(state.GetID()), state)
+}
+
+// GetState retrieves a state for a workflow instance
+func (store *RedisStatePersistenceStore) GetState(instanceID WorkflowInstanceID, stateID layer0.StateID) (layer0.State, error) {
+	var state layer0.State
+	if err := store.getItem("states", "state", instanceID, string(stateID), &state); err != nil {
+		return layer0.State{}, err
+	}
+	return state, nil
+}
+
+// UpdateState updates a state for a workflow instance
+func (store *RedisStatePersistenceStore) UpdateState(instanceID WorkflowInstanceID, state layer0.State) error {
+	return store.updateItem("states", "state", instanceID, string(state.GetID()), state)
+}
+
+// ListStates lists all states for a workflow instance
+func (store *RedisStatePersistenceStore) ListStates(instanceID WorkflowInstanceID) ([]layer0.State, error) {
+	values, err := store.listItems("states", instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]layer0.State, 0, len(values))
+	for _, value := range values {
+		var state layer0.State
+		if err := json.Unmarshal([]byte(value), &state); err != nil {
+			return nil, fmt.Errorf("failed to decode state: %w", err)
+		}
+		states = append(states, state)
+	}
+	sortStatesByID(states)
+
+	return states, nil
+}
+
+// SaveTransition saves a transition for a workflow instance
+func (store *RedisStatePersistenceStore) SaveTransition(instanceID WorkflowInstanceID, transition layer0.Transition) error {
+	return store.saveItem("transitions", "transition", instanceID, string(transition.GetID()), transition)
+}
+
+// GetTransition retrieves a transition for a workflow instance
+func (store *RedisStatePersistenceStore) GetTransition(instanceID WorkflowInstanceID, transitionID layer0.TransitionID) (layer0.Transition, error) {
+	var transition layer0.Transition
+	if err := store.getItem("transitions", "transition", instanceID, string(transitionID), &transition); err != nil {
+		return layer0.Transition{}, err
+	}
+	return transition, nil
+}
+
+// UpdateTransition updates a transition for a workflow instance
+func (store *RedisStatePersistenceStore) UpdateTransition(instanceID WorkflowInstanceID, transition layer0.Transition) error {
+	return store.updateItem("transitions", "transition", instanceID, string(transition.GetID()), transition)
+}
+
+// ListTransitions lists all transitions for a workflow instance
+func (store *RedisStatePersistenceStore) ListTransitions(instanceID WorkflowInstanceID) ([]layer0.Transition, error) {
+	values, err := store.listItems("transitions", instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	transitions := make([]layer0.Transition, 0, len(values))
+	for _, value := range values {
+		var transition layer0.Transition
+		if err := json.Unmarshal([]byte(value), &transition); err != nil {
+			return nil, fmt.Errorf("failed to decode transition: %w", err)
+		}
+		transitions = append(transitions, transition)
+	}
+	sortTransitionsByID(transitions)
+
+	return transitions, nil
+}
+
+// SaveWork saves work for a workflow instance
+func (store *RedisStatePersistenceStore) SaveWork(instanceID WorkflowInstanceID, work layer0.Work) error {
+	return store.saveItem("work", "work", instanceID, string(work.GetID()), work)
+}
+
+// GetWork retrieves work for a workflow instance
+func (store *RedisStatePersistenceStore) GetWork(instanceID WorkflowInstanceID, workID layer0.WorkID) (layer0.Work, error) {
+	var work layer0.Work
+	if err := store.getItem("work", "work", instanceID, string(workID), &work); err != nil {
+		return layer0.Work{}, err
+	}
+	return work, nil
+}
+
+// UpdateWork updates work for a workflow instance
+func (store *RedisStatePersistenceStore) UpdateWork(instanceID WorkflowInstanceID, work layer0.Work) error {
+	return store.updateItem("work", "work", instanceID, string(work.GetID()), work)
+}
+
+// ListWork lists all work for a workflow instance
+func (store *RedisStatePersistenceStore) ListWork(instanceID WorkflowInstanceID) ([]layer0.Work, error) {
+	values, err := store.listItems("work", instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	workItems := make([]layer0.Work, 0, len(values))
+	for _, value := range values {
+		var work layer0.Work
+		if err := json.Unmarshal([]byte(value), &work); err != nil {
+			return nil, fmt.Errorf("failed to decode work: %w", err)
+		}
+		workItems = append(workItems, work)
+	}
+	sortWorkByID(workItems)
+
+	return workItems, nil
+}
+
+// SaveContext saves a context for a workflow instance
+func (store *RedisStatePersistenceStore) SaveContext(instanceID WorkflowInstanceID, context *layer0.Context) error {
+	encoded, err := store.codecs.EncodeContext(context)
+	if err != nil {
+		return err
+	}
+	return store.saveItem("contexts", "context", instanceID, string(context.GetID()), encoded)
+}
+
+// GetContext retrieves a context for a workflow instance
+func (store *RedisStatePersistenceStore) GetContext(instanceID WorkflowInstanceID, contextID layer0.ContextID) (*layer0.Context, error) {
+	context := &layer0.Context{}
+	if err := store.getItem("contexts", "context", instanceID, string(contextID), context); err != nil {
+		return nil, err
+	}
+	return store.codecs.DecodeContext(context)
+}
+
+// UpdateContext updates a context for a workflow instance
+func (store *RedisStatePersistenceStore) UpdateContext(instanceID WorkflowInstanceID, context *layer0.Context) error {
+	encoded, err := store.codecs.EncodeContext(context)
+	if err != nil {
+		return err
+	}
+	return store.updateItem("contexts", "context", instanceID, string(context.GetID()), encoded)
+}
+
+// DeleteContext deletes a context of a workflow instance
+func (store *RedisStatePersistenceStore) DeleteContext(instanceID WorkflowInstanceID, contextID layer0.ContextID) error {
+	return store.deleteItem("contexts", "context", instanceID, string(contextID))
+}
+
+// ListContexts lists all contexts for a workflow instance
+func (store *RedisStatePersistenceStore) ListContexts(instanceID WorkflowInstanceID) ([]*layer0.Context, error) {
+	values, err := store.listItems("contexts", instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	contexts := make([]*layer0.Context, 0, len(values))
+	for _, value := range values {
+		context := &layer0.Context{}
+		if err := json.Unmarshal([]byte(value), context); err != nil {
+			return nil, fmt.Errorf("failed to decode context: %w", err)
+		}
+
+		decoded, err := store.codecs.DecodeContext(context)
+		if err != nil {
+			return nil, err
+		}
+		contexts = append(contexts, decoded)
+	}
+	sortContextsByID(contexts)
+
+	return contexts, nil
+}
+
+// Cleanup deletes every key in the store's namespace
+func (store *RedisStatePersistenceStore) Cleanup() error {
+	instanceIDs, err := store.client.SMembers(store.instancesKey())
+	if err != nil {
+		return fmt.Errorf("failed to list workflow instances: %w", err)
+	}
+
+	definitionIDs, err := store.client.SMembers(store.definitionsKey())
+	if err != nil {
+		return fmt.Errorf("failed to list workflow definitions: %w", err)
+	}
+
+	keys := []string{store.instancesKey(), store.definitionsKey()}
+	for _, instanceID := range instanceIDs {
+		keys = append(keys, store.instanceKey(WorkflowInstanceID(instanceID)))
+		for _, kind := range itemKinds {
+			keys = append(keys, store.itemsKey(kind, WorkflowInstanceID(instanceID)))
+		}
+	}
+
+	for _, definitionID := range definitionIDs {
+		keys = append(keys, store.definitionIndexKey(layer1.WorkflowDefinitionID(definitionID)))
+	}
+
+	for _, status := range allInstanceStatuses {
+		keys = append(keys, store.statusIndexKey(status))
+	}
+
+	if err := store.client.Del(keys...); err != nil {
+		return fmt.Errorf("failed to clean up store: %w", err)
+	}
+
+	return nil
+}
+
+// GetStats returns statistics about the store
+func (store *RedisStatePersistenceStore) GetStats() (map[string]interface{}, error) {
+	instances, err := store.client.SCard(store.instancesKey())
+	if err != nil {
+		return nil, fmt.Errorf("failed to count workflow instances: %w", err)
+	}
+
+	instancesByStatus := make(map[string]int)
+	for _, status := range allInstanceStatuses {
+		count, err := store.client.SCard(store.statusIndexKey(status))
+		if err != nil {
+			return nil, fmt.Errorf("failed to count %s workflow instances: %w", status, err)
+		}
+		if count > 0 {
+			instancesByStatus[string(status)] = count
+		}
+	}
+
+	instanceIDs, err := store.client.SMembers(store.instancesKey())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflow instances: %w", err)
+	}
+
+	totals := make(map[string]int, len(itemKinds))
+	for _, instanceID := range instanceIDs {
+		for _, kind := range itemKinds {
+			count, err := store.client.HLen(store.itemsKey(kind, WorkflowInstanceID(instanceID)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to count %s for instance %s: %w", kind, instanceID, err)
+			}
+			totals[kind] += count
+		}
+	}
+
+	return buildStoreStats(instances, totals["states"], totals["transitions"], totals["work"], totals["contexts"], instancesByStatus), nil
+}
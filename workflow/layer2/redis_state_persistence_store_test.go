@@ -0,0 +1,580 @@
+package layer2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// fakeRedisClient provides an in-memory RedisClient for tests
+type fakeRedisClient struct {
+	strings map[string]string
+	hashes  map[string]map[string]string
+	sets    map[string]map[string]bool
+	mutex   sync.Mutex
+}
+
+func newFakeRedisClient() *fakeRedisClient {
+	return &fakeRedisClient{
+		strings: make(map[string]string),
+		hashes:  make(map[string]map[string]string),
+		sets:    make(map[string]map[string]bool),
+	}
+}
+
+func (client *fakeRedisClient) Get(key string) (string, bool, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	value, found := client.strings[key]
+	return value, found, nil
+}
+
+func (client *fakeRedisClient) Set(key, value string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.strings[key] = value
+	return nil
+}
+
+func (client *fakeRedisClient) SetNX(key, value string) (bool, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if _, exists := client.strings[key]; exists {
+		return false, nil
+	}
+	client.strings[key] = value
+	return true, nil
+}
+
+func (client *fakeRedisClient) Exists(key string) (bool, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	_, isString := client.strings[key]
+	_, isHash := client.hashes[key]
+	_, isSet := client.sets[key]
+	return isString || isHash || isSet, nil
+}
+
+func (client *fakeRedisClient) Del(keys ...string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	for _, key := range keys {
+		delete(client.strings, key)
+		delete(client.hashes, key)
+		delete(client.sets, key)
+	}
+	return nil
+}
+
+func (client *fakeRedisClient) HGet(key, field string) (string, bool, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	value, found := client.hashes[key][field]
+	return value, found, nil
+}
+
+func (client *fakeRedisClient) HSet(key, field, value string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.hashes[key] == nil {
+		client.hashes[key] = make(map[string]string)
+	}
+	client.hashes[key][field] = value
+	return nil
+}
+
+func (client *fakeRedisClient) HSetNX(key, field, value string) (bool, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if _, exists := client.hashes[key][field]; exists {
+		return false, nil
+	}
+	if client.hashes[key] == nil {
+		client.hashes[key] = make(map[string]string)
+	}
+	client.hashes[key][field] = value
+	return true, nil
+}
+
+func (client *fakeRedisClient) HExists(key, field string) (bool, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	_, exists := client.hashes[key][field]
+	return exists, nil
+}
+
+func (client *fakeRedisClient) HDel(key string, fields ...string) (int, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	removed := 0
+	for _, field := range fields {
+		if _, exists := client.hashes[key][field]; exists {
+			delete(client.hashes[key], field)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (client *fakeRedisClient) HVals(key string) ([]string, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	values := make([]string, 0, len(client.hashes[key]))
+	for _, value := range client.hashes[key] {
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func (client *fakeRedisClient) HLen(key string) (int, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return len(client.hashes[key]), nil
+}
+
+func (client *fakeRedisClient) SAdd(key string, members ...string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.sets[key] == nil {
+		client.sets[key] = make(map[string]bool)
+	}
+	for _, member := range members {
+		client.sets[key][member] = true
+	}
+	return nil
+}
+
+func (client *fakeRedisClient) SRem(key string, members ...string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	for _, member := range members {
+		delete(client.sets[key], member)
+	}
+	if len(client.sets[key]) == 0 {
+		delete(client.sets, key)
+	}
+	return nil
+}
+
+func (client *fakeRedisClient) SMembers(key string) ([]string, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	members := make([]string, 0, len(client.sets[key]))
+	for member := range client.sets[key] {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (client *fakeRedisClient) SCard(key string) (int, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return len(client.sets[key]), nil
+}
+
+// keyCount returns the number of keys held by the fake
+func (client *fakeRedisClient) keyCount() int {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return len(client.strings) + len(client.hashes) + len(client.sets)
+}
+
+func newRedisTestInstance(id WorkflowInstanceID, definitionID layer1.WorkflowDefinitionID, status WorkflowInstanceStatus) WorkflowInstance {
+	return WorkflowInstance{
+		ID:                id,
+		DefinitionID:      definitionID,
+		DefinitionVersion: "1.0.0",
+		Status:            status,
+		CurrentStateID:    "initial-state",
+		Context:           layer0.NewContext("instance-context", layer0.ContextScopeWorkflow, "Instance Context"),
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+		Metadata:          map[string]interface{}{},
+	}
+}
+
+func TestRedisStatePersistenceStoreWorkflowInstances(t *testing.T) {
+	client := newFakeRedisClient()
+	store := NewRedisStatePersistenceStore(client, "")
+
+	instance := newRedisTestInstance("test-instance", "test-definition", WorkflowInstanceStatusCreated)
+
+	// Test SaveWorkflowInstance
+	if err := store.SaveWorkflowInstance(instance); err != nil {
+		t.Errorf("SaveWorkflowInstance should not return error: %v", err)
+	}
+
+	if _, found, _ := client.Get("wf:instance:test-instance"); !found {
+		t.Error("Instance should be stored under the namespaced key")
+	}
+
+	// Duplicate saves fail like the in-memory store
+	if err := store.SaveWorkflowInstance(instance); err == nil {
+		t.Error("SaveWorkflowInstance should return error when saving duplicate instance")
+	}
+
+	// Test GetWorkflowInstance
+	retrieved, err := store.GetWorkflowInstance(instance.ID)
+	if err != nil {
+		t.Errorf("GetWorkflowInstance should not return error: %v", err)
+	}
+
+	if retrieved.ID != instance.ID || retrieved.CurrentStateID != instance.CurrentStateID {
+		t.Error("Retrieved instance should match saved instance")
+	}
+
+	if retrieved.SchemaVersion != CurrentInstanceSchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", CurrentInstanceSchemaVersion, retrieved.SchemaVersion)
+	}
+
+	// Test UpdateWorkflowInstance moves the instance between status indexes
+	instance.Status = WorkflowInstanceStatusRunning
+	if err := store.UpdateWorkflowInstance(instance); err != nil {
+		t.Errorf("UpdateWorkflowInstance should not return error: %v", err)
+	}
+
+	stats, _ := store.GetStats()
+	byStatus := stats["instances_by_status"].(map[string]int)
+	if byStatus["running"] != 1 || byStatus["created"] != 0 {
+		t.Errorf("Expected the instance to be indexed as running, got %v", byStatus)
+	}
+
+	if err := store.UpdateWorkflowInstance(newRedisTestInstance("missing", "test-definition", WorkflowInstanceStatusCreated)); err == nil {
+		t.Error("UpdateWorkflowInstance should return error for non-existent instance")
+	}
+
+	// Test ListWorkflowInstances uses the definition index
+	other := newRedisTestInstance("other-instance", "other-definition", WorkflowInstanceStatusCreated)
+	store.SaveWorkflowInstance(other)
+
+	instances, err := store.ListWorkflowInstances("test-definition")
+	if err != nil {
+		t.Errorf("ListWorkflowInstances should not return error: %v", err)
+	}
+
+	if len(instances) != 1 || instances[0].ID != instance.ID {
+		t.Errorf("Expected only the test-definition instance, got %d instances", len(instances))
+	}
+
+	all, _ := store.ListAllWorkflowInstances()
+	if len(all) != 2 {
+		t.Errorf("Expected 2 instances, got %d", len(all))
+	}
+
+	// Test DeleteWorkflowInstance
+	if err := store.DeleteWorkflowInstance(instance.ID); err != nil {
+		t.Errorf("DeleteWorkflowInstance should not return error: %v", err)
+	}
+
+	if _, err := store.GetWorkflowInstance(instance.ID); err == nil {
+		t.Error("GetWorkflowInstance should return error for deleted instance")
+	}
+
+	if instances, _ := store.ListWorkflowInstances("test-definition"); len(instances) != 0 {
+		t.Error("Deleted instance should be removed from the definition index")
+	}
+
+	if err := store.DeleteWorkflowInstance(instance.ID); err == nil {
+		t.Error("DeleteWorkflowInstance should return error for non-existent instance")
+	}
+}
+
+func TestRedisStatePersistenceStoreItems(t *testing.T) {
+	store := NewRedisStatePersistenceStore(newFakeRedisClient(), "test")
+	instance := newRedisTestInstance("test-instance", "test-definition", WorkflowInstanceStatusRunning)
+	store.SaveWorkflowInstance(instance)
+
+	// States
+	state := layer0.NewState("test-state", layer0.StateTypeInitial, "Test State")
+	if err := store.SaveState(instance.ID, state); err != nil {
+		t.Errorf("SaveState should not return error: %v", err)
+	}
+	if err := store.SaveState(instance.ID, state); err == nil {
+		t.Error("SaveState should return error when saving duplicate state")
+	}
+	if retrieved, err := store.GetState(instance.ID, state.GetID()); err != nil || retrieved.GetID() != state.GetID() {
+		t.Errorf("GetState should return the saved state: %v", err)
+	}
+	if err := store.UpdateState(instance.ID, state.SetStatus(layer0.StateStatusActive)); err != nil {
+		t.Errorf("UpdateState should not return error: %v", err)
+	}
+	if retrieved, _ := store.GetState(instance.ID, state.GetID()); retrieved.GetStatus() != layer0.StateStatusActive {
+		t.Error("UpdateState should replace the stored state")
+	}
+	if err := store.UpdateState(instance.ID, layer0.NewState("missing", layer0.StateTypeFinal, "Missing")); err == nil {
+		t.Error("UpdateState should return error for non-existent state")
+	}
+	if states, _ := store.ListStates(instance.ID); len(states) != 1 {
+		t.Errorf("Expected 1 state, got %d", len(states))
+	}
+
+	// Transitions
+	transition := layer0.NewTransition("test-transition", layer0.TransitionTypeAutomatic, "from", "to", "Test Transition")
+	if err := store.SaveTransition(instance.ID, transition); err != nil {
+		t.Errorf("SaveTransition should not return error: %v", err)
+	}
+	if err := store.SaveTransition(instance.ID, transition); err == nil {
+		t.Error("SaveTransition should return error when saving duplicate transition")
+	}
+	if retrieved, err := store.GetTransition(instance.ID, transition.GetID()); err != nil || retrieved.GetToStateID() != "to" {
+		t.Errorf("GetTransition should return the saved transition: %v", err)
+	}
+	if err := store.UpdateTransition(instance.ID, transition.SetStatus(layer0.TransitionStatusCompleted)); err != nil {
+		t.Errorf("UpdateTransition should not return error: %v", err)
+	}
+	if transitions, _ := store.ListTransitions(instance.ID); len(transitions) != 1 || !transitions[0].IsCompleted() {
+		t.Error("ListTransitions should return the updated transition")
+	}
+
+	// Work
+	work := layer0.NewWork("test-work", layer0.WorkTypeTask, "Test Work")
+	if err := store.SaveWork(instance.ID, work); err != nil {
+		t.Errorf("SaveWork should not return error: %v", err)
+	}
+	if err := store.SaveWork(instance.ID, work); err == nil {
+		t.Error("SaveWork should return error when saving duplicate work")
+	}
+	if retrieved, err := store.GetWork(instance.ID, work.GetID()); err != nil || retrieved.GetID() != work.GetID() {
+		t.Errorf("GetWork should return the saved work: %v", err)
+	}
+	if err := store.UpdateWork(instance.ID, work); err != nil {
+		t.Errorf("UpdateWork should not return error: %v", err)
+	}
+	if workItems, _ := store.ListWork(instance.ID); len(workItems) != 1 {
+		t.Errorf("Expected 1 work item, got %d", len(workItems))
+	}
+
+	// Contexts
+	context := layer0.NewContext("test-context", layer0.ContextScopeState, "Test Context").Set("key", "value")
+	if err := store.SaveContext(instance.ID, context); err != nil {
+		t.Errorf("SaveContext should not return error: %v", err)
+	}
+	if err := store.SaveContext(instance.ID, context); err == nil {
+		t.Error("SaveContext should return error when saving duplicate context")
+	}
+	if err := store.UpdateContext(instance.ID, context.Set("new-key", "new-value")); err != nil {
+		t.Errorf("UpdateContext should not return error: %v", err)
+	}
+	retrievedContext, err := store.GetContext(instance.ID, context.GetID())
+	if err != nil {
+		t.Errorf("GetContext should not return error: %v", err)
+	} else if value, _ := retrievedContext.Get("new-key"); value != "new-value" {
+		t.Error("GetContext should return the updated context data")
+	}
+	if contexts, _ := store.ListContexts(instance.ID); len(contexts) != 1 {
+		t.Errorf("Expected 1 context, got %d", len(contexts))
+	}
+	if err := store.DeleteContext(instance.ID, context.GetID()); err != nil {
+		t.Errorf("DeleteContext should not return error: %v", err)
+	}
+	if contexts, _ := store.ListContexts(instance.ID); len(contexts) != 0 {
+		t.Errorf("Expected no contexts after DeleteContext, got %d", len(contexts))
+	}
+	if err := store.DeleteContext(instance.ID, context.GetID()); err == nil {
+		t.Error("DeleteContext should return error for a missing context")
+	}
+
+	// Operations on a non-existent instance fail
+	if err := store.SaveState("non-existent", state); err == nil {
+		t.Error("SaveState should return error for non-existent instance")
+	}
+	if _, err := store.ListWork("non-existent"); err == nil {
+		t.Error("ListWork should return error for non-existent instance")
+	}
+}
+
+func TestRedisStatePersistenceStoreCleanupAndStats(t *testing.T) {
+	client := newFakeRedisClient()
+	store := NewRedisStatePersistenceStore(client, "wf")
+
+	// Data outside the namespace must survive cleanup
+	client.Set("other:key", "value")
+
+	first := newRedisTestInstance("first", "test-definition", WorkflowInstanceStatusRunning)
+	second := newRedisTestInstance("second", "test-definition", WorkflowInstanceStatusCompleted)
+	store.SaveWorkflowInstance(first)
+	store.SaveWorkflowInstance(second)
+	store.SaveState(first.ID, layer0.NewState("a", layer0.StateTypeInitial, "A"))
+	store.SaveState(first.ID, layer0.NewState("b", layer0.StateTypeFinal, "B"))
+	store.SaveWork(second.ID, layer0.NewWork("work", layer0.WorkTypeTask, "Work"))
+
+	stats, err := store.GetStats()
+	if err != nil {
+		t.Fatalf("GetStats should not return error: %v", err)
+	}
+
+	if stats["workflow_instances"] != 2 || stats["total_states"] != 2 || stats["total_work"] != 1 {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+
+	byStatus := stats["instances_by_status"].(map[string]int)
+	if byStatus["running"] != 1 || byStatus["completed"] != 1 {
+		t.Errorf("Unexpected per-status stats: %v", byStatus)
+	}
+
+	// Cleanup flushes the namespace only
+	if err := store.Cleanup(); err != nil {
+		t.Errorf("Cleanup should not return error: %v", err)
+	}
+
+	if client.keyCount() != 1 {
+		t.Errorf("Expected only the foreign key to remain, got %d keys", client.keyCount())
+	}
+
+	stats, _ = store.GetStats()
+	if stats["workflow_instances"] != 0 {
+		t.Errorf("Expected 0 workflow instances after cleanup, got %v", stats["workflow_instances"])
+	}
+}
+
+// money is a custom context value type persisted through moneyCodec
+type money struct {
+	Cents    int64
+	Currency string
+}
+
+// moneyCodec persists money as a compact "CUR:cents" string
+type moneyCodec struct{}
+
+func (moneyCodec) MarshalContextValue(value interface{}) ([]byte, error) {
+	amount := value.(money)
+	return json.Marshal(fmt.Sprintf("%s:%d", amount.Currency, amount.Cents))
+}
+
+func (moneyCodec) UnmarshalContextValue(data []byte) (interface{}, error) {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, err
+	}
+
+	var amount money
+	if _, err := fmt.Sscanf(strings.Replace(encoded, ":", " ", 1), "%s %d", &amount.Currency, &amount.Cents); err != nil {
+		return nil, err
+	}
+	return amount, nil
+}
+
+func TestRedisStatePersistenceStoreValueCodecs(t *testing.T) {
+	codecs := NewContextValueCodecs()
+	if err := codecs.Register("money", money{}, moneyCodec{}); err != nil {
+		t.Fatalf("Register should not return error: %v", err)
+	}
+
+	if err := codecs.Register("money-v2", money{}, moneyCodec{}); err == nil {
+		t.Error("Register should return error for a type that already has a codec")
+	}
+
+	client := newFakeRedisClient()
+	store := NewRedisStatePersistenceStore(client, "test")
+	store.SetValueCodecs(codecs)
+
+	price := money{Cents: 1999, Currency: "USD"}
+	instance := newRedisTestInstance("instance-1", "test-definition", WorkflowInstanceStatusRunning)
+	instance.Context = instance.Context.Set("price", price).Set("sku", "widget")
+
+	if err := store.SaveWorkflowInstance(instance); err != nil {
+		t.Fatalf("SaveWorkflowInstance should not return error: %v", err)
+	}
+
+	// The codec controls the persisted form
+	raw, _, _ := client.Get(store.instanceKey(instance.ID))
+	if !strings.Contains(raw, `"$codec":"money"`) || !strings.Contains(raw, `USD:1999`) {
+		t.Errorf("Expected price to be persisted by its codec, got %s", raw)
+	}
+
+	loaded, err := store.GetWorkflowInstance(instance.ID)
+	if err != nil {
+		t.Fatalf("GetWorkflowInstance should not return error: %v", err)
+	}
+
+	if value, _ := loaded.Context.Get("price"); value != price {
+		t.Errorf("Expected price %v to round trip, got %#v", price, value)
+	}
+
+	if value, _ := loaded.Context.GetString("sku"); value != "widget" {
+		t.Errorf("Expected values without a codec to persist as JSON, got %v", value)
+	}
+
+	// Standalone contexts use the same codecs
+	context := layer0.NewContext("order", layer0.ContextScopeWorkflow, "Order").Set("total", price)
+	if err := store.SaveContext(instance.ID, context); err != nil {
+		t.Fatalf("SaveContext should not return error: %v", err)
+	}
+
+	retrieved, err := store.GetContext(instance.ID, "order")
+	if err != nil {
+		t.Fatalf("GetContext should not return error: %v", err)
+	}
+
+	if value, _ := retrieved.Get("total"); value != price {
+		t.Errorf("Expected total %v to round trip, got %#v", price, value)
+	}
+
+	// Reading a value whose codec is not registered fails rather than losing its type
+	unconfigured := NewRedisStatePersistenceStore(client, "test")
+	unconfigured.SetValueCodecs(NewContextValueCodecs())
+	if _, err := unconfigured.GetWorkflowInstance(instance.ID); err == nil {
+		t.Error("GetWorkflowInstance should return error for a value with an unknown codec")
+	}
+}
+
+// swappingRedisClient adds compare-and-swap to the fake client; beforeSwap runs
+// between the read and the swap to simulate a concurrent writer
+type swappingRedisClient struct {
+	*fakeRedisClient
+	beforeSwap func()
+}
+
+func (client *swappingRedisClient) CompareAndSwap(key, expected, value string) (bool, error) {
+	if client.beforeSwap != nil {
+		client.beforeSwap()
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.strings[key] != expected {
+		return false, nil
+	}
+	client.strings[key] = value
+	return true, nil
+}
+
+func TestRedisStatePersistenceStoreCompareAndSetStatus(t *testing.T) {
+	client := &swappingRedisClient{fakeRedisClient: newFakeRedisClient()}
+	store := NewRedisStatePersistenceStore(client, "")
+	store.SaveWorkflowInstance(newRedisTestInstance("guarded", "test-definition", WorkflowInstanceStatusRunning))
+
+	if err := store.CompareAndSetStatus("guarded", WorkflowInstanceStatusRunning, WorkflowInstanceStatusPaused); err != nil {
+		t.Fatalf("CompareAndSetStatus should not return error: %v", err)
+	}
+	if instance, _ := store.GetWorkflowInstance("guarded"); instance.Status != WorkflowInstanceStatusPaused {
+		t.Errorf("Expected status paused, got %s", instance.Status)
+	}
+	stats, _ := store.GetStats()
+	if byStatus := stats["instances_by_status"].(map[string]int); byStatus["paused"] != 1 || byStatus["running"] != 0 {
+		t.Errorf("Expected the instance to be indexed as paused, got %v", byStatus)
+	}
+
+	if err := store.CompareAndSetStatus("guarded", WorkflowInstanceStatusRunning, WorkflowInstanceStatusCancelled); !errors.Is(err, ErrStatusConflict) {
+		t.Errorf("Expected a status conflict, got %v", err)
+	}
+
+	// A write landing between the read and the swap makes the swap fail
+	client.beforeSwap = func() {
+		cancelled := newRedisTestInstance("guarded", "test-definition", WorkflowInstanceStatusCancelled)
+		store.UpdateWorkflowInstance(cancelled)
+	}
+	if err := store.CompareAndSetStatus("guarded", WorkflowInstanceStatusPaused, WorkflowInstanceStatusRunning); !errors.Is(err, ErrStatusConflict) {
+		t.Errorf("Expected a concurrent write to conflict, got %v", err)
+	}
+	if instance, _ := store.GetWorkflowInstance("guarded"); instance.Status != WorkflowInstanceStatusCancelled {
+		t.Errorf("Expected the concurrent write to win, got %s", instance.Status)
+	}
+}
@@ -0,0 +1,51 @@
+package layer2
+
+import (
+	"fmt"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// SetResultTransformer sets the rules that reshape an action's output before it is
+// stored in the instance context. The execution result keeps the raw output.
+// An empty transformer removes the action's rules.
+func (engine *WorkflowRuntimeEngine) SetResultTransformer(actionID string, transformer layer1.ResultTransformer) error {
+	if actionID == "" {
+		return fmt.Errorf("action ID cannot be empty")
+	}
+
+	if err := transformer.Validate(); err != nil {
+		return fmt.Errorf("invalid result transformer for action %s: %w", actionID, err)
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if len(transformer) == 0 {
+		delete(engine.resultTransformers, actionID)
+		return nil
+	}
+
+	rules := make(layer1.ResultTransformer, len(transformer))
+	copy(rules, transformer)
+	engine.resultTransformers[actionID] = rules
+	return nil
+}
+
+// transformOutput applies the transformer of the work's action to its output
+func (engine *WorkflowRuntimeEngine) transformOutput(work layer0.Work, output interface{}) (interface{}, error) {
+	engine.mutex.RLock()
+	transformer := engine.resultTransformers[string(work.GetID())]
+	engine.mutex.RUnlock()
+
+	if output == nil || len(transformer) == 0 {
+		return output, nil
+	}
+
+	transformed, err := transformer.Apply(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transform output of work %s: %w", work.GetID(), err)
+	}
+	return transformed, nil
+}
@@ -0,0 +1,119 @@
+package layer2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// ErrRetryBudgetExhausted indicates work failed after the instance used up its retry budget
+var ErrRetryBudgetExhausted = errors.New("instance retry budget exhausted")
+
+// executeWorkWithRetries executes work, retrying failures up to the work's retry
+// count while the instance's retry budget lasts. Instances without a budget do
+// not retry, nor does work whose executor declares it is not idempotent. A failure
+// once the budget is spent returns ErrRetryBudgetExhausted.
+func (engine *WorkflowRuntimeEngine) executeWorkWithRetries(ctx context.Context, instance *WorkflowInstance, work layer0.Work, workContext *layer0.Context) (layer1.WorkExecutionResult, error) {
+	ctx = layer1.WithEnvironment(engine.withInstanceLogger(ctx, instance), instance.Environment)
+	result, err := engine.retryWork(ctx, instance, work, workContext)
+	if err == nil || result.WorkID != "" {
+		// The final attempt is recorded unless the instance is ephemeral; a store
+		// failure does not fail the transition
+		if !instance.Ephemeral {
+			if persistErr := engine.persistWorkResult(instance.ID, work, result); persistErr != nil {
+				engine.errorHandler.HandleError(instance.ID, persistErr)
+			}
+		}
+		engine.publishWorkResult(instance.ID, work, result)
+	}
+	return result, err
+}
+
+// retryWork runs the attempts of executeWorkWithRetries. Each retry carries the
+// attempts before it, so the final result records every attempt of this execution.
+func (engine *WorkflowRuntimeEngine) retryWork(ctx context.Context, instance *WorkflowInstance, work layer0.Work, workContext *layer0.Context) (layer1.WorkExecutionResult, error) {
+	var attempts []layer1.WorkAttempt
+	for attempt := 0; ; attempt++ {
+		result, err := engine.executeWorkAttempt(layer1.WithPreviousAttempts(ctx, attempts), work, workContext)
+		attempts = result.Attempts
+		if err != nil || result.Status != layer0.WorkStatusFailed {
+			return result, err
+		}
+
+		if instance.RetryBudget == 0 || attempt >= work.GetConfiguration().RetryCount || !engine.isIdempotent(work) {
+			return result, nil
+		}
+
+		if !engine.consumeRetry(instance) {
+			return result, fmt.Errorf("work %s failed: %s: %w", work.GetID(), result.Error, ErrRetryBudgetExhausted)
+		}
+
+		// Back off on the engine clock, so a manual clock controls retries too
+		if delay := time.Duration(work.GetConfiguration().RetryDelaySeconds) * time.Second; delay > 0 {
+			if err := engine.sleep(ctx, delay); err != nil {
+				return result, err
+			}
+		}
+	}
+}
+
+// executeWorkAttempt executes work once with its secret references resolved,
+// against the part of the context the work may see
+func (engine *WorkflowRuntimeEngine) executeWorkAttempt(ctx context.Context, work layer0.Work, workContext *layer0.Context) (layer1.WorkExecutionResult, error) {
+	resolved, secrets, err := engine.resolveSecrets(ctx, work)
+	if err != nil {
+		return layer1.WorkExecutionResult{}, err
+	}
+
+	release, err := engine.scheduleWork(ctx, work)
+	if err != nil {
+		return layer1.WorkExecutionResult{}, err
+	}
+	defer release()
+
+	engine.mutex.RLock()
+	backend := engine.executionBackend
+	engine.mutex.RUnlock()
+
+	result, err := backend.Execute(ctx, resolved, engine.projectContext(work, workContext))
+	result.Error = redactSecrets(result.Error, secrets)
+	return result, redactSecretsError(err, secrets)
+}
+
+// withInstanceLogger returns a copy of ctx carrying the engine logger filtered by the
+// logging level of the instance's definition
+func (engine *WorkflowRuntimeEngine) withInstanceLogger(ctx context.Context, instance *WorkflowInstance) context.Context {
+	engine.mutex.RLock()
+	logger := engine.logger
+	engine.mutex.RUnlock()
+
+	if logger == nil {
+		return ctx
+	}
+	return layer1.WithLogger(ctx, layer1.NewLevelLogger(logger, layer1.ParseLogLevel(instance.LoggingLevel)))
+}
+
+// consumeRetry takes one retry from the instance's budget, reporting false when none are left
+func (engine *WorkflowRuntimeEngine) consumeRetry(instance *WorkflowInstance) bool {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if instance.RetriesUsed >= instance.RetryBudget {
+		return false
+	}
+
+	instance.RetriesUsed++
+	return true
+}
+
+// isIdempotent checks if the executor that runs work declares it safe to repeat.
+// Work without a local executor, such as work run by a remote execution backend,
+// is treated like an executor that declares nothing.
+func (engine *WorkflowRuntimeEngine) isIdempotent(work layer0.Work) bool {
+	capabilities, err := engine.workExecutionCore.GetWorkCapabilities(work)
+	return err != nil || capabilities.Idempotent
+}
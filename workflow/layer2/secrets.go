@@ -0,0 +1,226 @@
+package layer2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// secretPlaceholder matches ${secret:name} references in work configuration
+var secretPlaceholder = regexp.MustCompile(`\$\{secret:([^}]+)\}`)
+
+// redactedSecret replaces resolved secret values in errors
+const redactedSecret = "[REDACTED]"
+
+// SecretProvider defines the interface for looking up secrets by name
+type SecretProvider interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+// EnvSecretProvider resolves secrets from environment variables named by prefix and secret name
+type EnvSecretProvider struct {
+	prefix string
+}
+
+// NewEnvSecretProvider creates a new environment secret provider
+func NewEnvSecretProvider(prefix string) *EnvSecretProvider {
+	return &EnvSecretProvider{prefix: prefix}
+}
+
+// GetSecret reads the secret's environment variable
+func (provider *EnvSecretProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	value, exists := os.LookupEnv(provider.prefix + name)
+	if !exists {
+		return "", fmt.Errorf("secret %s is not set", name)
+	}
+	return value, nil
+}
+
+// MockSecretProvider resolves secrets from a fixed map and counts lookups
+type MockSecretProvider struct {
+	secrets map[string]string
+	lookups int
+	mutex   sync.Mutex
+}
+
+// NewMockSecretProvider creates a new mock secret provider over the given secrets
+func NewMockSecretProvider(secrets map[string]string) *MockSecretProvider {
+	copied := make(map[string]string, len(secrets))
+	for name, value := range secrets {
+		copied[name] = value
+	}
+
+	return &MockSecretProvider{
+		secrets: copied,
+		mutex:   sync.Mutex{},
+	}
+}
+
+// GetSecret returns the named secret
+func (provider *MockSecretProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	provider.lookups++
+	value, exists := provider.secrets[name]
+	if !exists {
+		return "", fmt.Errorf("secret %s not found", name)
+	}
+	return value, nil
+}
+
+// Lookups returns the number of secrets looked up so far
+func (provider *MockSecretProvider) Lookups() int {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	return provider.lookups
+}
+
+// SetSecretProvider sets the provider ${secret:name} references in work parameters and
+// environment are resolved from. References are resolved on a copy of the work just
+// before each execution; resolved values are never stored in the instance, its
+// context or persisted work, and are redacted from work errors.
+func (engine *WorkflowRuntimeEngine) SetSecretProvider(provider SecretProvider) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.secretProvider = provider
+}
+
+// secretResolver resolves secret references, remembering the values it resolved
+type secretResolver struct {
+	ctx      context.Context
+	provider SecretProvider
+	resolved []string
+}
+
+// resolveSecrets returns a copy of the work with its secret references resolved,
+// along with the resolved values for redaction
+func (engine *WorkflowRuntimeEngine) resolveSecrets(ctx context.Context, work layer0.Work) (layer0.Work, []string, error) {
+	engine.mutex.RLock()
+	provider := engine.secretProvider
+	engine.mutex.RUnlock()
+
+	resolver := &secretResolver{ctx: ctx, provider: provider}
+	resolved := work.Clone()
+
+	for key, value := range resolved.Configuration.Parameters {
+		replaced, err := resolver.resolveValue(value)
+		if err != nil {
+			return work, nil, fmt.Errorf("work %s parameter %s: %w", work.GetID(), key, err)
+		}
+		resolved.Configuration.Parameters[key] = replaced
+	}
+
+	for key, value := range resolved.Configuration.Environment {
+		replaced, err := resolver.resolveString(value)
+		if err != nil {
+			return work, nil, fmt.Errorf("work %s environment %s: %w", work.GetID(), key, err)
+		}
+		resolved.Configuration.Environment[key] = replaced
+	}
+
+	return resolved, resolver.resolved, nil
+}
+
+// resolveValue resolves references in strings, copying any maps and slices it descends into
+func (resolver *secretResolver) resolveValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return resolver.resolveString(v)
+	case []string:
+		copied := make([]string, len(v))
+		for index, item := range v {
+			replaced, err := resolver.resolveString(item)
+			if err != nil {
+				return nil, err
+			}
+			copied[index] = replaced
+		}
+		return copied, nil
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for index, item := range v {
+			replaced, err := resolver.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			copied[index] = replaced
+		}
+		return copied, nil
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			replaced, err := resolver.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			copied[key] = replaced
+		}
+		return copied, nil
+	default:
+		return value, nil
+	}
+}
+
+// resolveString replaces every secret reference in the string
+func (resolver *secretResolver) resolveString(value string) (string, error) {
+	if !strings.Contains(value, "${secret:") {
+		return value, nil
+	}
+
+	var resolveErr error
+	replaced := secretPlaceholder.ReplaceAllStringFunc(value, func(match string) string {
+		if resolveErr != nil {
+			return match
+		}
+
+		name := secretPlaceholder.FindStringSubmatch(match)[1]
+		if resolver.provider == nil {
+			resolveErr = fmt.Errorf("secret %s is referenced but no secret provider is configured", name)
+			return match
+		}
+
+		secret, err := resolver.provider.GetSecret(resolver.ctx, name)
+		if err != nil {
+			resolveErr = fmt.Errorf("failed to resolve secret %s: %w", name, err)
+			return match
+		}
+
+		if secret != "" {
+			resolver.resolved = append(resolver.resolved, secret)
+		}
+		return secret
+	})
+
+	return replaced, resolveErr
+}
+
+// redactSecrets replaces resolved secret values in text
+func redactSecrets(text string, secrets []string) string {
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, redactedSecret)
+	}
+	return text
+}
+
+// redactSecretsError replaces resolved secret values in an error's message.
+// Errors that mention no secrets are returned unchanged so they can still be unwrapped.
+func redactSecretsError(err error, secrets []string) error {
+	if err == nil {
+		return nil
+	}
+
+	message := err.Error()
+	if redacted := redactSecrets(message, secrets); redacted != message {
+		return errors.New(redacted)
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+package layer2
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+// Sharder maps each workflow instance to the node that owns it. Engines sharing a
+// persistence store use the same sharder so every instance has exactly one owner.
+type Sharder interface {
+	NodeFor(instanceID WorkflowInstanceID) string
+}
+
+// defaultVirtualNodes is the number of ring positions given to each node
+const defaultVirtualNodes = 64
+
+// ringPoint is a position on the consistent hash ring
+type ringPoint struct {
+	hash uint32
+	node string
+}
+
+// ConsistentHashSharder assigns instances to nodes on a consistent hash ring, so
+// adding or removing a node only moves the instances of the ring segments it owned
+type ConsistentHashSharder struct {
+	ring []ringPoint
+}
+
+// NewConsistentHashSharder creates a sharder over the given nodes, placing each node
+// at virtualNodes points on the ring; zero uses the default of 64
+func NewConsistentHashSharder(nodes []string, virtualNodes int) (*ConsistentHashSharder, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("consistent hash sharder requires at least one node")
+	}
+	if virtualNodes < 0 {
+		return nil, fmt.Errorf("virtual nodes cannot be negative")
+	}
+	if virtualNodes == 0 {
+		virtualNodes = defaultVirtualNodes
+	}
+
+	seen := make(map[string]bool, len(nodes))
+	ring := make([]ringPoint, 0, len(nodes)*virtualNodes)
+	for _, node := range nodes {
+		if node == "" {
+			return nil, fmt.Errorf("node name cannot be empty")
+		}
+		if seen[node] {
+			return nil, fmt.Errorf("node %s is listed more than once", node)
+		}
+		seen[node] = true
+
+		for replica := 0; replica < virtualNodes; replica++ {
+			ring = append(ring, ringPoint{hash: ringHash(fmt.Sprintf("%s#%d", node, replica)), node: node})
+		}
+	}
+
+	sort.Slice(ring, func(i, j int) bool {
+		if ring[i].hash != ring[j].hash {
+			return ring[i].hash < ring[j].hash
+		}
+		return ring[i].node < ring[j].node
+	})
+
+	return &ConsistentHashSharder{ring: ring}, nil
+}
+
+// NodeFor returns the node owning the first ring point at or after the instance's hash
+func (sharder *ConsistentHashSharder) NodeFor(instanceID WorkflowInstanceID) string {
+	hash := ringHash(string(instanceID))
+	index := sort.Search(len(sharder.ring), func(i int) bool {
+		return sharder.ring[i].hash >= hash
+	})
+	if index == len(sharder.ring) {
+		index = 0
+	}
+	return sharder.ring[index].node
+}
+
+// ringHash places a key on the ring
+func ringHash(key string) uint32 {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return hash.Sum32()
+}
+
+// SetSharder makes the engine schedule only the instances the sharder assigns to
+// nodeID. Background processing of the persistence store, such as state timeouts,
+// stall detection and reaping, skips instances owned by other nodes. A nil sharder
+// makes the engine own every instance.
+func (engine *WorkflowRuntimeEngine) SetSharder(sharder Sharder, nodeID string) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.sharder = sharder
+	engine.nodeID = nodeID
+}
+
+// OwnsInstance reports whether the engine's node owns an instance
+func (engine *WorkflowRuntimeEngine) OwnsInstance(instanceID WorkflowInstanceID) bool {
+	engine.mutex.RLock()
+	sharder, nodeID := engine.sharder, engine.nodeID
+	engine.mutex.RUnlock()
+
+	return sharder == nil || sharder.NodeFor(instanceID) == nodeID
+}
+
+// ownedInstances filters instances read from the persistence store to those the engine owns
+func (engine *WorkflowRuntimeEngine) ownedInstances(instances []WorkflowInstance) []WorkflowInstance {
+	owned := instances[:0]
+	for _, instance := range instances {
+		if engine.OwnsInstance(instance.ID) {
+			owned = append(owned, instance)
+		}
+	}
+	return owned
+}
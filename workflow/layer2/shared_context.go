@@ -0,0 +1,200 @@
+package layer2
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// sharedActionPrefix marks transition actions that operate on the shared context
+// of the instance's definition rather than executing work. Shared actions take the
+// form "shared:<operation>:<key>".
+const sharedActionPrefix = "shared:"
+
+// SharedContextOperation is an operation a shared action performs on a shared key
+type SharedContextOperation string
+
+const (
+	// SharedContextGet copies the shared value into the instance context under the same key
+	SharedContextGet SharedContextOperation = "get"
+	// SharedContextSet writes the instance context value of the key to the shared context
+	SharedContextSet SharedContextOperation = "set"
+	// SharedContextIncrement atomically adds one to the shared value and copies the
+	// result into the instance context
+	SharedContextIncrement SharedContextOperation = "increment"
+)
+
+// SharedContextStore holds state shared by every instance of a definition.
+// Updates are serialized so concurrent instances never lose each other's writes.
+type SharedContextStore struct {
+	contexts map[layer1.WorkflowDefinitionID]*layer0.Context
+	mutex    sync.Mutex
+}
+
+// NewSharedContextStore creates a new, empty shared context store
+func NewSharedContextStore() *SharedContextStore {
+	return &SharedContextStore{
+		contexts: make(map[layer1.WorkflowDefinitionID]*layer0.Context),
+		mutex:    sync.Mutex{},
+	}
+}
+
+// Seed initializes a definition's shared context from its global context unless it already exists
+func (store *SharedContextStore) Seed(definitionID layer1.WorkflowDefinitionID, global *layer0.Context) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, exists := store.contexts[definitionID]; exists {
+		return
+	}
+
+	if global == nil {
+		store.contexts[definitionID] = newSharedContext(definitionID)
+		return
+	}
+	store.contexts[definitionID] = global.Clone()
+}
+
+// Get returns a shared value of a definition
+func (store *SharedContextStore) Get(definitionID layer1.WorkflowDefinitionID, key string) (interface{}, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if context, exists := store.contexts[definitionID]; exists {
+		return context.Get(key)
+	}
+	return nil, false
+}
+
+// Snapshot returns a copy of a definition's shared context, or nil if it has none
+func (store *SharedContextStore) Snapshot(definitionID layer1.WorkflowDefinitionID) *layer0.Context {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if context, exists := store.contexts[definitionID]; exists {
+		return context.Clone()
+	}
+	return nil
+}
+
+// Update atomically replaces a shared value with the result of update and returns it.
+// No other update of the definition's shared context runs while update is called.
+func (store *SharedContextStore) Update(definitionID layer1.WorkflowDefinitionID, key string, update func(current interface{}, exists bool) (interface{}, error)) (interface{}, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	context, exists := store.contexts[definitionID]
+	if !exists {
+		context = newSharedContext(definitionID)
+	}
+
+	current, found := context.Get(key)
+	value, err := update(current, found)
+	if err != nil {
+		return nil, err
+	}
+
+	store.contexts[definitionID] = context.Set(key, value)
+	return value, nil
+}
+
+// snapshotAll returns a copy of every definition's shared context
+func (store *SharedContextStore) snapshotAll() map[layer1.WorkflowDefinitionID]*layer0.Context {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	contexts := make(map[layer1.WorkflowDefinitionID]*layer0.Context, len(store.contexts))
+	for definitionID, context := range store.contexts {
+		contexts[definitionID] = context.Clone()
+	}
+	return contexts
+}
+
+// restore replaces every shared context with the given ones
+func (store *SharedContextStore) restore(contexts map[layer1.WorkflowDefinitionID]*layer0.Context) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.contexts = make(map[layer1.WorkflowDefinitionID]*layer0.Context, len(contexts))
+	for definitionID, context := range contexts {
+		store.contexts[definitionID] = context.Clone()
+	}
+}
+
+// newSharedContext creates an empty shared context for a definition
+func newSharedContext(definitionID layer1.WorkflowDefinitionID) *layer0.Context {
+	return layer0.NewContext(layer0.ContextID(fmt.Sprintf("%s-shared", definitionID)), layer0.ContextScopeGlobal, "Shared Context")
+}
+
+// SharedContexts returns the engine's shared context store
+func (engine *WorkflowRuntimeEngine) SharedContexts() *SharedContextStore {
+	return engine.sharedContexts
+}
+
+// parseSharedAction splits a shared action into its operation and key
+func parseSharedAction(actionID string) (SharedContextOperation, string, bool) {
+	if !strings.HasPrefix(actionID, sharedActionPrefix) {
+		return "", "", false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(actionID, sharedActionPrefix), ":", 2)
+	if len(parts) != 2 {
+		return SharedContextOperation(parts[0]), "", true
+	}
+	return SharedContextOperation(parts[0]), parts[1], true
+}
+
+// applySharedAction performs a shared action for an instance and returns the updated staged context.
+// Shared writes take effect immediately and are not rolled back if the transition later fails.
+func (engine *WorkflowRuntimeEngine) applySharedAction(definitionID layer1.WorkflowDefinitionID, actionID string, staged *layer0.Context) (*layer0.Context, error) {
+	operation, key, _ := parseSharedAction(actionID)
+	if key == "" {
+		return nil, fmt.Errorf("shared action %s must name a key", actionID)
+	}
+
+	switch operation {
+	case SharedContextGet:
+		if value, exists := engine.sharedContexts.Get(definitionID, key); exists {
+			return staged.Set(key, value), nil
+		}
+		return staged.Delete(key), nil
+	case SharedContextSet:
+		value, exists := staged.Get(key)
+		if !exists {
+			return nil, fmt.Errorf("shared action %s: key %s is not set in the instance context", actionID, key)
+		}
+
+		if _, err := engine.sharedContexts.Update(definitionID, key, func(interface{}, bool) (interface{}, error) {
+			return value, nil
+		}); err != nil {
+			return nil, err
+		}
+		return staged, nil
+	case SharedContextIncrement:
+		value, err := engine.sharedContexts.Update(definitionID, key, func(current interface{}, exists bool) (interface{}, error) {
+			if !exists {
+				return 1, nil
+			}
+
+			switch number := current.(type) {
+			case int:
+				return number + 1, nil
+			case int64:
+				return number + 1, nil
+			case float64:
+				return number + 1, nil
+			default:
+				return nil, fmt.Errorf("shared value %s is not a number: %v", key, current)
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("shared action %s: %w", actionID, err)
+		}
+		return staged.Set(key, value), nil
+	default:
+		return nil, fmt.Errorf("unknown shared context operation %s in action %s", operation, actionID)
+	}
+}
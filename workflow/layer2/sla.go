@@ -0,0 +1,109 @@
+package layer2
+
+import (
+	"fmt"
+	"time"
+)
+
+// SLAStatus reports how an instance is doing against the SLA of its definition
+type SLAStatus struct {
+	SLA       time.Duration `json:"sla"`
+	Elapsed   time.Duration `json:"elapsed"`
+	Remaining time.Duration `json:"remaining"`
+	Breached  bool          `json:"breached"`
+	// Final is set once the instance finished, after which its status no longer changes
+	Final bool `json:"final"`
+}
+
+// GetSLAStatus returns the time an instance has taken since it started, measured on
+// the engine clock until it finishes, and the time left before it exceeds the SLA
+// of its definition. Instances of definitions without an SLA are never breached.
+func (engine *WorkflowRuntimeEngine) GetSLAStatus(instanceID WorkflowInstanceID) (SLAStatus, error) {
+	instance, err := engine.GetWorkflowInstance(instanceID)
+	if err != nil {
+		return SLAStatus{}, err
+	}
+
+	engine.mutex.RLock()
+	defer engine.mutex.RUnlock()
+
+	return slaStatus(instance, engine.clock.Now()), nil
+}
+
+// slaStatus computes the SLA status of an instance as of now
+func slaStatus(instance *WorkflowInstance, now time.Time) SLAStatus {
+	status := SLAStatus{SLA: instance.SLA, Final: instance.IsTerminal()}
+	if instance.StartedAt == nil {
+		status.Remaining = instance.SLA
+		return status
+	}
+
+	end := now
+	if instance.CompletedAt != nil {
+		end = *instance.CompletedAt
+	}
+	status.Elapsed = end.Sub(*instance.StartedAt)
+
+	if instance.SLA > 0 {
+		status.Breached = status.Elapsed > instance.SLA
+		if !status.Breached {
+			status.Remaining = instance.SLA - status.Elapsed
+		}
+	}
+	return status
+}
+
+// ProcessSLABreaches reports running and waiting instances that exceeded the SLA of
+// their definition as of now. Each breach is reported once and recorded on the
+// instance. It returns the number of newly breached instances.
+func (engine *WorkflowRuntimeEngine) ProcessSLABreaches(now time.Time) (int, error) {
+	instances, err := engine.listScannableInstances()
+	if err != nil {
+		return 0, err
+	}
+
+	breached := 0
+	for _, persisted := range instances {
+		if persisted.SLA <= 0 || (persisted.Status != WorkflowInstanceStatusRunning && persisted.Status != WorkflowInstanceStatusWaiting) {
+			continue
+		}
+
+		elapsed, isBreached := engine.markSLABreached(persisted, now)
+		if !isBreached {
+			continue
+		}
+
+		if err := engine.lifecycleManager.OnWorkflowSLABreached(persisted.ID, persisted.SLA, elapsed); err != nil {
+			engine.errorHandler.HandleError(persisted.ID, fmt.Errorf("lifecycle manager error: %w", err))
+		}
+		breached++
+	}
+
+	return breached, nil
+}
+
+// markSLABreached records the SLA breach of an instance that has exceeded its SLA
+// and reports how long it has been running
+func (engine *WorkflowRuntimeEngine) markSLABreached(persisted WorkflowInstance, now time.Time) (time.Duration, bool) {
+	engine.mutex.Lock()
+	instance, exists := engine.activeInstances[persisted.ID]
+	if !exists {
+		instance = &persisted
+	}
+
+	status := slaStatus(instance, now)
+	if instance.SLABreachedAt != nil || !status.Breached || status.Final {
+		engine.mutex.Unlock()
+		return 0, false
+	}
+
+	instance.SLABreachedAt = &now
+	updated := *instance
+	engine.mutex.Unlock()
+
+	if err := engine.updateInstance(updated); err != nil {
+		engine.errorHandler.HandleError(persisted.ID, fmt.Errorf("failed to update breached workflow instance: %w", err))
+	}
+
+	return status.Elapsed, true
+}
@@ -0,0 +1,123 @@
+package layer2
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MetadataNeedsAttention is the instance metadata key set on stalled instances
+// when the engine marks them for operator attention
+const MetadataNeedsAttention = "needs_attention"
+
+// SetStallDetection sets how long a running or waiting instance may go without a
+// transition before it is reported stalled; zero disables stall detection. When
+// markForAttention is set, stalled instances are also flagged in their metadata.
+func (engine *WorkflowRuntimeEngine) SetStallDetection(threshold time.Duration, markForAttention bool) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.stallThreshold = threshold
+	engine.markStalledInstances = markForAttention
+}
+
+// ProcessStalledInstances reports instances that have not transitioned within the
+// stall threshold as of now. Each stall is reported once, until the instance
+// transitions again. It returns the number of newly stalled instances.
+func (engine *WorkflowRuntimeEngine) ProcessStalledInstances(now time.Time) (int, error) {
+	engine.mutex.RLock()
+	threshold := engine.stallThreshold
+	engine.mutex.RUnlock()
+
+	if threshold <= 0 {
+		return 0, nil
+	}
+
+	instances, err := engine.listScannableInstances()
+	if err != nil {
+		return 0, err
+	}
+
+	stalled := 0
+	for _, persisted := range instances {
+		if persisted.Status != WorkflowInstanceStatusRunning && persisted.Status != WorkflowInstanceStatusWaiting {
+			continue
+		}
+
+		stalledFor, isStalled := engine.markStalled(persisted, now, threshold)
+		if !isStalled {
+			continue
+		}
+
+		if err := engine.lifecycleManager.OnWorkflowStalled(persisted.ID, stalledFor); err != nil {
+			engine.errorHandler.HandleError(persisted.ID, fmt.Errorf("lifecycle manager error: %w", err))
+		}
+		stalled++
+	}
+
+	return stalled, nil
+}
+
+// markStalled records the stall of an instance that has gone without a transition
+// for at least threshold and reports how long it has been stalled
+func (engine *WorkflowRuntimeEngine) markStalled(persisted WorkflowInstance, now time.Time, threshold time.Duration) (time.Duration, bool) {
+	engine.mutex.Lock()
+	instance, exists := engine.activeInstances[persisted.ID]
+	if !exists {
+		instance = &persisted
+	}
+
+	lastTransitionAt := instance.LastTransitionAt
+	if lastTransitionAt == nil {
+		lastTransitionAt = instance.StartedAt
+	}
+
+	if instance.StalledAt != nil || lastTransitionAt == nil || now.Sub(*lastTransitionAt) < threshold {
+		engine.mutex.Unlock()
+		return 0, false
+	}
+
+	instance.StalledAt = &now
+	if engine.markStalledInstances {
+		if instance.Metadata == nil {
+			instance.Metadata = make(map[string]interface{})
+		}
+		instance.Metadata[MetadataNeedsAttention] = true
+	}
+	updated := *instance
+	engine.mutex.Unlock()
+
+	if err := engine.updateInstance(updated); err != nil {
+		engine.errorHandler.HandleError(persisted.ID, fmt.Errorf("failed to update stalled workflow instance: %w", err))
+	}
+
+	return now.Sub(*lastTransitionAt), true
+}
+
+// StartStallDetector reports stalled instances on the given interval until the
+// returned stop function is called. Errors are reported to the error handler.
+func (engine *WorkflowRuntimeEngine) StartStallDetector(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := engine.ProcessStalledInstances(engine.clock.Now()); err != nil {
+					engine.errorHandler.HandleError("", fmt.Errorf("stall detector error: %w", err))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package layer2
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// StartOptions contains optional settings applied when starting a workflow instance
+type StartOptions struct {
+	// InstanceID requests a specific instance ID, such as when replaying a command log;
+	// one is generated if empty
+	InstanceID WorkflowInstanceID `json:"instance_id,omitempty"`
+	Labels     map[string]string  `json:"labels,omitempty"`
+	// CorrelationID ties the instance to an external request trace; one is generated if empty
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// ParentInstanceID links a sub-workflow to the instance that started it so
+	// cancelling the parent also cancels the child
+	ParentInstanceID WorkflowInstanceID `json:"parent_instance_id,omitempty"`
+	// Priority is given to the instance's work so it is scheduled ahead of lower
+	// priority work in the priority queue execution mode; zero keeps each work's own priority
+	Priority layer0.WorkPriority `json:"priority,omitempty"`
+	// Environment sets environment variables for the instance's executors, taking
+	// precedence over variables of the same name in the definition's environment
+	Environment map[string]string `json:"environment,omitempty"`
+}
+
+// copyLabels returns a copy of the labels so callers cannot modify the instance's labels
+func (options StartOptions) copyLabels() map[string]string {
+	if len(options.Labels) == 0 {
+		return nil
+	}
+
+	labels := make(map[string]string, len(options.Labels))
+	for key, value := range options.Labels {
+		labels[key] = value
+	}
+	return labels
+}
+
+// mergeEnvironment returns the definition's environment overlaid with the instance's
+// own environment variables, or nil when neither sets any
+func (options StartOptions) mergeEnvironment(definitionEnvironment map[string]string) map[string]string {
+	if len(definitionEnvironment) == 0 && len(options.Environment) == 0 {
+		return nil
+	}
+
+	environment := make(map[string]string, len(definitionEnvironment)+len(options.Environment))
+	for key, value := range definitionEnvironment {
+		environment[key] = value
+	}
+	for key, value := range options.Environment {
+		environment[key] = value
+	}
+	return environment
+}
+
+// resolveCorrelationID returns the supplied correlation ID or generates a new one
+func (options StartOptions) resolveCorrelationID() string {
+	if options.CorrelationID != "" {
+		return options.CorrelationID
+	}
+
+	buffer := make([]byte, 16)
+	if _, err := rand.Read(buffer); err != nil {
+		return fmt.Sprintf("corr-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buffer)
+}
@@ -1,7 +1,9 @@
 package layer2
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -19,25 +21,66 @@ const (
 	WorkflowInstanceStatusCreated   WorkflowInstanceStatus = "created"
 	WorkflowInstanceStatusRunning   WorkflowInstanceStatus = "running"
 	WorkflowInstanceStatusPaused    WorkflowInstanceStatus = "paused"
+	WorkflowInstanceStatusWaiting   WorkflowInstanceStatus = "waiting"
 	WorkflowInstanceStatusCompleted WorkflowInstanceStatus = "completed"
 	WorkflowInstanceStatusFailed    WorkflowInstanceStatus = "failed"
 	WorkflowInstanceStatusCancelled WorkflowInstanceStatus = "cancelled"
 )
 
+// ErrStatusConflict indicates a guarded status change found the instance in another status
+var ErrStatusConflict = errors.New("workflow instance status conflict")
+
+// allInstanceStatuses lists every workflow instance status
+var allInstanceStatuses = []WorkflowInstanceStatus{
+	WorkflowInstanceStatusCreated,
+	WorkflowInstanceStatusRunning,
+	WorkflowInstanceStatusPaused,
+	WorkflowInstanceStatusWaiting,
+	WorkflowInstanceStatusCompleted,
+	WorkflowInstanceStatusFailed,
+	WorkflowInstanceStatusCancelled,
+}
+
 // WorkflowInstance represents a running instance of a workflow
 type WorkflowInstance struct {
-	ID                WorkflowInstanceID               `json:"id"`
-	DefinitionID      layer1.WorkflowDefinitionID      `json:"definition_id"`
-	DefinitionVersion layer1.WorkflowDefinitionVersion `json:"definition_version"`
-	Status            WorkflowInstanceStatus           `json:"status"`
-	CurrentStateID    layer0.StateID                   `json:"current_state_id"`
-	Context           *layer0.Context                  `json:"context"`
-	CreatedAt         time.Time                        `json:"created_at"`
-	UpdatedAt         time.Time                        `json:"updated_at"`
-	StartedAt         *time.Time                       `json:"started_at,omitempty"`
-	CompletedAt       *time.Time                       `json:"completed_at,omitempty"`
-	Error             string                           `json:"error,omitempty"`
-	Metadata          map[string]interface{}           `json:"metadata"`
+	ID                    WorkflowInstanceID                 `json:"id"`
+	DefinitionID          layer1.WorkflowDefinitionID        `json:"definition_id"`
+	DefinitionVersion     layer1.WorkflowDefinitionVersion   `json:"definition_version"`
+	Status                WorkflowInstanceStatus             `json:"status"`
+	CurrentStateID        layer0.StateID                     `json:"current_state_id"`
+	Context               *layer0.Context                    `json:"context"`
+	CreatedAt             time.Time                          `json:"created_at"`
+	UpdatedAt             time.Time                          `json:"updated_at"`
+	StartedAt             *time.Time                         `json:"started_at,omitempty"`
+	CompletedAt           *time.Time                         `json:"completed_at,omitempty"`
+	Error                 string                             `json:"error,omitempty"`
+	Metadata              map[string]interface{}             `json:"metadata"`
+	SchemaVersion         int                                `json:"schema_version"`
+	TTL                   time.Duration                      `json:"ttl,omitempty"`
+	ExpiresAt             *time.Time                         `json:"expires_at,omitempty"`
+	Labels                map[string]string                  `json:"labels,omitempty"`
+	CorrelationID         string                             `json:"correlation_id,omitempty"`
+	StateDeadline         *time.Time                         `json:"state_deadline,omitempty"`
+	RetryBudget           int                                `json:"retry_budget,omitempty"`
+	RetriesUsed           int                                `json:"retries_used,omitempty"`
+	ParentInstanceID      WorkflowInstanceID                 `json:"parent_instance_id,omitempty"`
+	Priority              layer0.WorkPriority                `json:"priority,omitempty"`
+	MaxConcurrentWork     int                                `json:"max_concurrent_work,omitempty"`
+	TransitionSelection   layer1.TransitionSelectionStrategy `json:"transition_selection,omitempty"`
+	LastTransitionAt      *time.Time                         `json:"last_transition_at,omitempty"`
+	StateEnteredAt        *time.Time                         `json:"state_entered_at,omitempty"`
+	MinTransitionInterval time.Duration                      `json:"min_transition_interval,omitempty"`
+	NextTransitionAt      *time.Time                         `json:"next_transition_at,omitempty"`
+	StalledAt             *time.Time                         `json:"stalled_at,omitempty"`
+	PauseDeadline         *time.Time                         `json:"pause_deadline,omitempty"`
+	PauseExpiry           PauseExpiryAction                  `json:"pause_expiry,omitempty"`
+	SLA                   time.Duration                      `json:"sla,omitempty"`
+	SLABreachedAt         *time.Time                         `json:"sla_breached_at,omitempty"`
+	Ephemeral             bool                               `json:"ephemeral,omitempty"` // Kept only in memory, never written to the store
+	LoggingLevel          string                             `json:"logging_level,omitempty"`
+	Environment           map[string]string                  `json:"environment,omitempty"`
+	ContextPruning        layer1.ContextPruningPolicy        `json:"context_pruning,omitempty"`
+	OutputKeys            []string                           `json:"output_keys,omitempty"` // Work outputs in the context, oldest first; tracked only while pruning
 }
 
 // StatePersistenceStore defines the interface for persisting workflow state
@@ -46,6 +89,7 @@ type StatePersistenceStore interface {
 	SaveWorkflowInstance(instance WorkflowInstance) error
 	GetWorkflowInstance(instanceID WorkflowInstanceID) (WorkflowInstance, error)
 	UpdateWorkflowInstance(instance WorkflowInstance) error
+	CompareAndSetStatus(instanceID WorkflowInstanceID, expected, status WorkflowInstanceStatus) error
 	DeleteWorkflowInstance(instanceID WorkflowInstanceID) error
 	ListWorkflowInstances(definitionID layer1.WorkflowDefinitionID) ([]WorkflowInstance, error)
 	ListAllWorkflowInstances() ([]WorkflowInstance, error)
@@ -72,6 +116,7 @@ type StatePersistenceStore interface {
 	SaveContext(instanceID WorkflowInstanceID, context *layer0.Context) error
 	GetContext(instanceID WorkflowInstanceID, contextID layer0.ContextID) (*layer0.Context, error)
 	UpdateContext(instanceID WorkflowInstanceID, context *layer0.Context) error
+	DeleteContext(instanceID WorkflowInstanceID, contextID layer0.ContextID) error
 	ListContexts(instanceID WorkflowInstanceID) ([]*layer0.Context, error)
 
 	// Cleanup operations
@@ -148,6 +193,27 @@ func (store *InMemoryStatePersistenceStore) UpdateWorkflowInstance(instance Work
 	return nil
 }
 
+// CompareAndSetStatus atomically sets the status of an instance if it currently
+// equals expected, returning ErrStatusConflict otherwise
+func (store *InMemoryStatePersistenceStore) CompareAndSetStatus(instanceID WorkflowInstanceID, expected, status WorkflowInstanceStatus) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	instance, exists := store.workflowInstances[instanceID]
+	if !exists {
+		return fmt.Errorf("workflow instance %s not found", instanceID)
+	}
+
+	if instance.Status != expected {
+		return fmt.Errorf("%w: workflow instance %s is %s, expected %s", ErrStatusConflict, instanceID, instance.Status, expected)
+	}
+
+	instance.Status = status
+	instance.UpdatedAt = time.Now()
+	store.workflowInstances[instanceID] = instance
+	return nil
+}
+
 // DeleteWorkflowInstance deletes a workflow instance and all its associated data
 func (store *InMemoryStatePersistenceStore) DeleteWorkflowInstance(instanceID WorkflowInstanceID) error {
 	store.mutex.Lock()
@@ -177,6 +243,7 @@ func (store *InMemoryStatePersistenceStore) ListWorkflowInstances(definitionID l
 			instances = append(instances, instance)
 		}
 	}
+	sortInstancesByID(instances)
 
 	return instances, nil
 }
@@ -190,6 +257,7 @@ func (store *InMemoryStatePersistenceStore) ListAllWorkflowInstances() ([]Workfl
 	for _, instance := range store.workflowInstances {
 		instances = append(instances, instance)
 	}
+	sortInstancesByID(instances)
 
 	return instances, nil
 }
@@ -258,6 +326,7 @@ func (store *InMemoryStatePersistenceStore) ListStates(instanceID WorkflowInstan
 	for _, state := range store.states[instanceID] {
 		states = append(states, state)
 	}
+	sortStatesByID(states)
 
 	return states, nil
 }
@@ -326,6 +395,7 @@ func (store *InMemoryStatePersistenceStore) ListTransitions(instanceID WorkflowI
 	for _, transition := range store.transitions[instanceID] {
 		transitions = append(transitions, transition)
 	}
+	sortTransitionsByID(transitions)
 
 	return transitions, nil
 }
@@ -394,6 +464,7 @@ func (store *InMemoryStatePersistenceStore) ListWork(instanceID WorkflowInstance
 	for _, work := range store.work[instanceID] {
 		workItems = append(workItems, work)
 	}
+	sortWorkByID(workItems)
 
 	return workItems, nil
 }
@@ -449,6 +520,23 @@ func (store *InMemoryStatePersistenceStore) UpdateContext(instanceID WorkflowIns
 	return nil
 }
 
+// DeleteContext deletes a context of a workflow instance
+func (store *InMemoryStatePersistenceStore) DeleteContext(instanceID WorkflowInstanceID, contextID layer0.ContextID) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, exists := store.workflowInstances[instanceID]; !exists {
+		return fmt.Errorf("workflow instance %s not found", instanceID)
+	}
+
+	if _, exists := store.contexts[instanceID][contextID]; !exists {
+		return fmt.Errorf("context %s not found for instance %s", contextID, instanceID)
+	}
+
+	delete(store.contexts[instanceID], contextID)
+	return nil
+}
+
 // ListContexts lists all contexts for a workflow instance
 func (store *InMemoryStatePersistenceStore) ListContexts(instanceID WorkflowInstanceID) ([]*layer0.Context, error) {
 	store.mutex.RLock()
@@ -462,6 +550,7 @@ func (store *InMemoryStatePersistenceStore) ListContexts(instanceID WorkflowInst
 	for _, context := range store.contexts[instanceID] {
 		contexts = append(contexts, context)
 	}
+	sortContextsByID(contexts)
 
 	return contexts, nil
 }
@@ -485,20 +574,69 @@ func (store *InMemoryStatePersistenceStore) GetStats() (map[string]interface{},
 	store.mutex.RLock()
 	defer store.mutex.RUnlock()
 
-	stats := map[string]interface{}{
-		"workflow_instances": len(store.workflowInstances),
-		"total_states":       0,
-		"total_transitions":  0,
-		"total_work":         0,
-		"total_contexts":     0,
+	var totalStates, totalTransitions, totalWork, totalContexts int
+	instancesByStatus := make(map[string]int)
+
+	for instanceID, instance := range store.workflowInstances {
+		instancesByStatus[string(instance.Status)]++
+		totalStates += len(store.states[instanceID])
+		totalTransitions += len(store.transitions[instanceID])
+		totalWork += len(store.work[instanceID])
+		totalContexts += len(store.contexts[instanceID])
 	}
 
-	for instanceID := range store.workflowInstances {
-		stats["total_states"] = stats["total_states"].(int) + len(store.states[instanceID])
-		stats["total_transitions"] = stats["total_transitions"].(int) + len(store.transitions[instanceID])
-		stats["total_work"] = stats["total_work"].(int) + len(store.work[instanceID])
-		stats["total_contexts"] = stats["total_contexts"].(int) + len(store.contexts[instanceID])
+	return buildStoreStats(len(store.workflowInstances), totalStates, totalTransitions, totalWork, totalContexts, instancesByStatus), nil
+}
+
+// buildStoreStats assembles the stats map shared by the persistence stores
+func buildStoreStats(instances, states, transitions, work, contexts int, instancesByStatus map[string]int) map[string]interface{} {
+	averageStates := 0.0
+	if instances > 0 {
+		averageStates = float64(states) / float64(instances)
 	}
 
-	return stats, nil
+	return map[string]interface{}{
+		"workflow_instances":          instances,
+		"total_states":                states,
+		"total_transitions":           transitions,
+		"total_work":                  work,
+		"total_contexts":              contexts,
+		"instances_by_status":         instancesByStatus,
+		"average_states_per_instance": averageStates,
+	}
+}
+
+// sortInstancesByID orders instances by ID so listings are deterministic
+func sortInstancesByID(instances []WorkflowInstance) {
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].ID < instances[j].ID
+	})
+}
+
+// sortStatesByID orders states by ID so listings are deterministic
+func sortStatesByID(states []layer0.State) {
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].GetID() < states[j].GetID()
+	})
+}
+
+// sortTransitionsByID orders transitions by ID so listings are deterministic
+func sortTransitionsByID(transitions []layer0.Transition) {
+	sort.Slice(transitions, func(i, j int) bool {
+		return transitions[i].GetID() < transitions[j].GetID()
+	})
+}
+
+// sortWorkByID orders work by ID so listings are deterministic
+func sortWorkByID(workItems []layer0.Work) {
+	sort.Slice(workItems, func(i, j int) bool {
+		return workItems[i].GetID() < workItems[j].GetID()
+	})
+}
+
+// sortContextsByID orders contexts by ID so listings are deterministic
+func sortContextsByID(contexts []*layer0.Context) {
+	sort.Slice(contexts, func(i, j int) bool {
+		return contexts[i].GetID() < contexts[j].GetID()
+	})
 }
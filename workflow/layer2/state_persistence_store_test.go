@@ -1,6 +1,8 @@
 package layer2
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -338,6 +340,20 @@ func TestContextOperations(t *testing.T) {
 	if len(contexts) != 1 {
 		t.Errorf("Expected 1 context, got %d", len(contexts))
 	}
+
+	// Test DeleteContext
+	err = store.DeleteContext(instance.ID, context.GetID())
+	if err != nil {
+		t.Errorf("DeleteContext should not return error: %v", err)
+	}
+
+	if _, err := store.GetContext(instance.ID, context.GetID()); err == nil {
+		t.Error("GetContext should return error for a deleted context")
+	}
+
+	if err := store.DeleteContext(instance.ID, context.GetID()); err == nil {
+		t.Error("DeleteContext should return error for a missing context")
+	}
 }
 
 func TestCleanupAndStats(t *testing.T) {
@@ -413,6 +429,62 @@ func TestCleanupAndStats(t *testing.T) {
 	}
 }
 
+func TestInMemoryStatePersistenceStoreStatsByStatus(t *testing.T) {
+	store := NewInMemoryStatePersistenceStore()
+
+	// Save instances in several statuses
+	statuses := []WorkflowInstanceStatus{
+		WorkflowInstanceStatusRunning,
+		WorkflowInstanceStatusRunning,
+		WorkflowInstanceStatusPaused,
+		WorkflowInstanceStatusCompleted,
+	}
+	for i, status := range statuses {
+		store.SaveWorkflowInstance(WorkflowInstance{
+			ID:     WorkflowInstanceID(fmt.Sprintf("instance-%d", i)),
+			Status: status,
+		})
+	}
+
+	// Give the first instance three states and the second one
+	for i := 0; i < 3; i++ {
+		store.SaveState("instance-0", layer0.NewState(layer0.StateID(fmt.Sprintf("state-%d", i)), layer0.StateTypeIntermediate, "State"))
+	}
+	store.SaveState("instance-1", layer0.NewState("state-0", layer0.StateTypeIntermediate, "State"))
+
+	stats, err := store.GetStats()
+	if err != nil {
+		t.Fatalf("GetStats should not return error: %v", err)
+	}
+
+	byStatus, ok := stats["instances_by_status"].(map[string]int)
+	if !ok {
+		t.Fatalf("Expected instances_by_status to be map[string]int, got %T", stats["instances_by_status"])
+	}
+
+	expected := map[string]int{"running": 2, "paused": 1, "completed": 1}
+	for status, count := range expected {
+		if byStatus[status] != count {
+			t.Errorf("Expected %d %s instances, got %d", count, status, byStatus[status])
+		}
+	}
+
+	if byStatus["failed"] != 0 {
+		t.Errorf("Expected 0 failed instances, got %d", byStatus["failed"])
+	}
+
+	if stats["average_states_per_instance"] != 1.0 {
+		t.Errorf("Expected 1.0 average states per instance, got %v", stats["average_states_per_instance"])
+	}
+
+	// An empty store reports no instances without dividing by zero
+	store.Cleanup()
+	stats, _ = store.GetStats()
+	if stats["average_states_per_instance"] != 0.0 {
+		t.Errorf("Expected 0 average states for an empty store, got %v", stats["average_states_per_instance"])
+	}
+}
+
 func TestErrorCases(t *testing.T) {
 	store := NewInMemoryStatePersistenceStore()
 
@@ -460,3 +532,226 @@ func TestErrorCases(t *testing.T) {
 		t.Error("SaveContext should return error for non-existent instance")
 	}
 }
+
+func TestInstanceMigratorDecodeLegacyRecord(t *testing.T) {
+	migrator := NewInstanceMigrator()
+
+	// A record written before schema versioning, without metadata
+	legacy := []byte(`{
+		"id": "legacy-instance",
+		"definition_id": "legacy-workflow",
+		"definition_version": "1.0.0",
+		"status": "completed",
+		"current_state_id": "end",
+		"created_at": "2024-01-01T00:00:00Z",
+		"updated_at": "2024-01-01T00:00:00Z"
+	}`)
+
+	instance, err := migrator.DecodeWorkflowInstance(legacy)
+	if err != nil {
+		t.Fatalf("DecodeWorkflowInstance should not return error: %v", err)
+	}
+
+	if instance.ID != "legacy-instance" || instance.Status != WorkflowInstanceStatusCompleted {
+		t.Error("Existing fields should be preserved")
+	}
+
+	if instance.Metadata == nil {
+		t.Error("Missing metadata should be defaulted")
+	}
+
+	if instance.SchemaVersion != CurrentInstanceSchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", CurrentInstanceSchemaVersion, instance.SchemaVersion)
+	}
+
+	// Records from a newer engine are rejected
+	if _, err := migrator.DecodeWorkflowInstance([]byte(`{"id": "future", "schema_version": 99}`)); err == nil {
+		t.Error("DecodeWorkflowInstance should return error for a newer schema version")
+	}
+
+	// Encoded instances round-trip at the current version
+	data, err := EncodeWorkflowInstance(instance)
+	if err != nil {
+		t.Fatalf("EncodeWorkflowInstance should not return error: %v", err)
+	}
+
+	roundTripped, err := migrator.DecodeWorkflowInstance(data)
+	if err != nil {
+		t.Fatalf("DecodeWorkflowInstance should not return error: %v", err)
+	}
+
+	if roundTripped.ID != instance.ID || roundTripped.SchemaVersion != CurrentInstanceSchemaVersion {
+		t.Error("Encoded instance should round-trip unchanged")
+	}
+}
+
+func TestInstanceMigratorMigrateAll(t *testing.T) {
+	store := NewInMemoryStatePersistenceStore()
+	migrator := NewInstanceMigrator()
+
+	// Save one legacy instance and one current instance
+	store.SaveWorkflowInstance(WorkflowInstance{
+		ID:           "legacy",
+		DefinitionID: "test-workflow",
+		Status:       WorkflowInstanceStatusCompleted,
+	})
+	store.SaveWorkflowInstance(WorkflowInstance{
+		ID:            "current",
+		DefinitionID:  "test-workflow",
+		Status:        WorkflowInstanceStatusRunning,
+		Metadata:      map[string]interface{}{},
+		SchemaVersion: CurrentInstanceSchemaVersion,
+	})
+
+	migrated, err := migrator.MigrateAll(store)
+	if err != nil {
+		t.Fatalf("MigrateAll should not return error: %v", err)
+	}
+
+	if migrated != 1 {
+		t.Errorf("Expected 1 migrated instance, got %d", migrated)
+	}
+
+	instance, _ := store.GetWorkflowInstance("legacy")
+	if instance.SchemaVersion != CurrentInstanceSchemaVersion || instance.Metadata == nil {
+		t.Error("Legacy instance should be rewritten at the current schema version")
+	}
+
+	// Running again is a no-op
+	migrated, err = migrator.MigrateAll(store)
+	if err != nil || migrated != 0 {
+		t.Errorf("Second MigrateAll should migrate nothing, got %d (%v)", migrated, err)
+	}
+}
+
+// countingStatePersistenceStore counts instance updates reaching the wrapped store
+type countingStatePersistenceStore struct {
+	StatePersistenceStore
+	updates int
+}
+
+func (store *countingStatePersistenceStore) UpdateWorkflowInstance(instance WorkflowInstance) error {
+	store.updates++
+	return store.StatePersistenceStore.UpdateWorkflowInstance(instance)
+}
+
+func TestBufferedStatePersistenceStore(t *testing.T) {
+	inner := &countingStatePersistenceStore{StatePersistenceStore: NewInMemoryStatePersistenceStore()}
+	store := NewBufferedStatePersistenceStore(inner, FlushPolicy{BatchSize: 3})
+
+	instance := WorkflowInstance{
+		ID:             "buffered-instance",
+		DefinitionID:   "test-definition",
+		Status:         WorkflowInstanceStatusRunning,
+		CurrentStateID: "initial-state",
+		Context:        layer0.NewContext("instance-context", layer0.ContextScopeWorkflow, "Instance Context"),
+		Metadata:       map[string]interface{}{},
+	}
+	if err := store.SaveWorkflowInstance(instance); err != nil {
+		t.Fatalf("SaveWorkflowInstance should not return error: %v", err)
+	}
+
+	// Updates below the batch size are coalesced in memory
+	for _, stateID := range []layer0.StateID{"state-1", "state-2"} {
+		instance.CurrentStateID = stateID
+		if err := store.UpdateWorkflowInstance(instance); err != nil {
+			t.Fatalf("UpdateWorkflowInstance should not return error: %v", err)
+		}
+	}
+	if inner.updates != 0 {
+		t.Errorf("Expected no writes below the batch size, got %d", inner.updates)
+	}
+	if store.PendingUpdates() != 1 {
+		t.Errorf("Expected updates to coalesce into 1 pending instance, got %d", store.PendingUpdates())
+	}
+
+	retrieved, err := store.GetWorkflowInstance(instance.ID)
+	if err != nil || retrieved.CurrentStateID != "state-2" {
+		t.Errorf("Expected reads to see the buffered update, got %s (err %v)", retrieved.CurrentStateID, err)
+	}
+
+	// Reaching the batch size writes the latest version once
+	instance.CurrentStateID = "state-3"
+	if err := store.UpdateWorkflowInstance(instance); err != nil {
+		t.Fatalf("UpdateWorkflowInstance should not return error: %v", err)
+	}
+	if inner.updates != 1 {
+		t.Errorf("Expected 1 write on reaching the batch size, got %d", inner.updates)
+	}
+	persisted, _ := inner.GetWorkflowInstance(instance.ID)
+	if persisted.CurrentStateID != "state-3" {
+		t.Errorf("Expected the latest update to be written, got %s", persisted.CurrentStateID)
+	}
+
+	// A terminal status flushes immediately
+	instance.Status = WorkflowInstanceStatusCompleted
+	if err := store.UpdateWorkflowInstance(instance); err != nil {
+		t.Fatalf("UpdateWorkflowInstance should not return error: %v", err)
+	}
+	if inner.updates != 2 || store.PendingUpdates() != 0 {
+		t.Errorf("Expected a terminal update to flush immediately, got %d writes and %d pending", inner.updates, store.PendingUpdates())
+	}
+	persisted, _ = inner.GetWorkflowInstance(instance.ID)
+	if persisted.Status != WorkflowInstanceStatusCompleted {
+		t.Errorf("Expected the terminal status to be persisted, got %s", persisted.Status)
+	}
+}
+
+func TestInMemoryStatePersistenceStoreListOrdering(t *testing.T) {
+	store := NewInMemoryStatePersistenceStore()
+
+	instanceIDs := []WorkflowInstanceID{"instance-c", "instance-a", "instance-d", "instance-b"}
+	for _, instanceID := range instanceIDs {
+		store.SaveWorkflowInstance(WorkflowInstance{
+			ID:           instanceID,
+			DefinitionID: "test-definition",
+			Status:       WorkflowInstanceStatusRunning,
+			Context:      layer0.NewContext("ctx", layer0.ContextScopeWorkflow, "Ctx"),
+			Metadata:     map[string]interface{}{},
+		})
+	}
+	for _, workID := range []layer0.WorkID{"work-3", "work-1", "work-2"} {
+		store.SaveWork("instance-a", layer0.NewWork(workID, layer0.WorkTypeTask, string(workID)))
+	}
+
+	for call := 0; call < 10; call++ {
+		instances, _ := store.ListAllWorkflowInstances()
+		for index, expected := range []WorkflowInstanceID{"instance-a", "instance-b", "instance-c", "instance-d"} {
+			if instances[index].ID != expected {
+				t.Fatalf("Call %d: expected instance %s at %d, got %s", call, expected, index, instances[index].ID)
+			}
+		}
+
+		workItems, _ := store.ListWork("instance-a")
+		for index, expected := range []layer0.WorkID{"work-1", "work-2", "work-3"} {
+			if workItems[index].GetID() != expected {
+				t.Fatalf("Call %d: expected work %s at %d, got %s", call, expected, index, workItems[index].GetID())
+			}
+		}
+	}
+}
+
+func TestInMemoryStatePersistenceStoreCompareAndSetStatus(t *testing.T) {
+	store := NewInMemoryStatePersistenceStore()
+	store.SaveWorkflowInstance(WorkflowInstance{ID: "guarded", DefinitionID: "test-definition", Status: WorkflowInstanceStatusRunning})
+
+	if err := store.CompareAndSetStatus("guarded", WorkflowInstanceStatusRunning, WorkflowInstanceStatusPaused); err != nil {
+		t.Fatalf("CompareAndSetStatus should not return error: %v", err)
+	}
+	if instance, _ := store.GetWorkflowInstance("guarded"); instance.Status != WorkflowInstanceStatusPaused {
+		t.Errorf("Expected status paused, got %s", instance.Status)
+	}
+
+	// The instance is no longer running, so a second change from running conflicts
+	err := store.CompareAndSetStatus("guarded", WorkflowInstanceStatusRunning, WorkflowInstanceStatusCancelled)
+	if !errors.Is(err, ErrStatusConflict) {
+		t.Errorf("Expected a status conflict, got %v", err)
+	}
+	if instance, _ := store.GetWorkflowInstance("guarded"); instance.Status != WorkflowInstanceStatusPaused {
+		t.Errorf("A conflicting change should leave the status unchanged, got %s", instance.Status)
+	}
+
+	if err := store.CompareAndSetStatus("missing", WorkflowInstanceStatusRunning, WorkflowInstanceStatusPaused); err == nil || errors.Is(err, ErrStatusConflict) {
+		t.Errorf("Expected a not found error for a missing instance, got %v", err)
+	}
+}
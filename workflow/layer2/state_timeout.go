@@ -0,0 +1,234 @@
+package layer2
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// scheduleStateTimeout sets the instance's deadline for leaving its current state,
+// clearing it when the state has no timeout
+func (engine *WorkflowRuntimeEngine) scheduleStateTimeout(instance *WorkflowInstance) {
+	instance.StateDeadline = nil
+
+	state, err := engine.stateMachineCore.GetState(instance.CurrentStateID)
+	if err != nil || state.GetTimeout() <= 0 {
+		return
+	}
+
+	deadline := engine.clock.Now().Add(state.GetTimeout())
+	instance.StateDeadline = &deadline
+}
+
+// selectableTransitions filters out timeout transitions, which are only taken
+// when their source state times out
+func selectableTransitions(transitions []layer0.Transition) []layer0.Transition {
+	selectable := transitions[:0]
+	for _, transition := range transitions {
+		if transition.GetType() != layer0.TransitionTypeTimeout {
+			selectable = append(selectable, transition)
+		}
+	}
+	return selectable
+}
+
+// ProcessStateTimeouts handles instances whose state deadline is at or before now.
+// A timed out instance takes its state's timeout transition, or fails if the state
+// has none; entering an error state fails the instance. Instances paused with
+// PauseWorkflowUntil whose deadline passed are resumed or failed. Deadlines are read
+// from the persistence store so timeouts survive an engine restart. It returns the
+// number of instances that timed out.
+func (engine *WorkflowRuntimeEngine) ProcessStateTimeouts(now time.Time) (int, error) {
+	issuedAt := engine.clock.Now()
+	timedOut, err := engine.processStateTimeouts(now)
+	if timedOut > 0 || err != nil {
+		engine.recordCommand(Command{Type: CommandProcessTimeouts, Now: &now, IssuedAt: issuedAt}, err)
+	}
+	return timedOut, err
+}
+
+// processStateTimeouts handles passed state deadlines without recording the command
+func (engine *WorkflowRuntimeEngine) processStateTimeouts(now time.Time) (int, error) {
+	instances, err := engine.listScannableInstances()
+	if err != nil {
+		return 0, err
+	}
+
+	timedOut := 0
+	for _, persisted := range instances {
+		if persisted.Status == WorkflowInstanceStatusPaused && persisted.PauseDeadline != nil && !now.Before(*persisted.PauseDeadline) {
+			if err := engine.expirePause(persisted); err != nil {
+				return timedOut, err
+			}
+			timedOut++
+			continue
+		}
+
+		if persisted.StateDeadline == nil || now.Before(*persisted.StateDeadline) {
+			continue
+		}
+
+		if persisted.Status != WorkflowInstanceStatusRunning && persisted.Status != WorkflowInstanceStatusWaiting {
+			continue
+		}
+
+		expired, err := engine.timeoutState(persisted, now)
+		if err != nil {
+			return timedOut, err
+		}
+		if expired {
+			timedOut++
+		}
+	}
+
+	return timedOut, nil
+}
+
+// timeoutState moves an instance out of the state whose deadline passed and reports
+// whether it timed out. The listing the instance came from may be stale, so the
+// instance is only timed out if it is still running or waiting past its deadline.
+// A step of the instance in progress does not commit once the timeout moved it on.
+func (engine *WorkflowRuntimeEngine) timeoutState(persisted WorkflowInstance, now time.Time) (bool, error) {
+	instanceID := persisted.ID
+
+	// An instance the engine cannot find the definition of is left for a later scan
+	if err := engine.loadInstanceDefinition(persisted); err != nil {
+		engine.errorHandler.HandleError(instanceID, fmt.Errorf("timeout of state %s: %w", persisted.CurrentStateID, err))
+		return false, nil
+	}
+
+	// Restore the instance if the engine restarted since it entered the state
+	engine.mutex.Lock()
+	instance, exists := engine.activeInstances[instanceID]
+	if !exists {
+		instance = &persisted
+		engine.applyContextDefaultsUnsafe(instance)
+		engine.activeInstances[instanceID] = instance
+	}
+
+	if instance.Status != WorkflowInstanceStatusRunning && instance.Status != WorkflowInstanceStatusWaiting ||
+		instance.StateDeadline == nil || now.Before(*instance.StateDeadline) {
+		engine.mutex.Unlock()
+		return false, nil
+	}
+
+	// Work a waiting instance was suspended on is abandoned
+	if instance.Status == WorkflowInstanceStatusWaiting {
+		if err := engine.guardStatus(instance, WorkflowInstanceStatusRunning); err != nil {
+			engine.mutex.Unlock()
+			return false, err
+		}
+		instance.Status = WorkflowInstanceStatusRunning
+		engine.dropHumanTasksUnsafe(instanceID)
+	}
+
+	stateID := instance.CurrentStateID
+	instance.Metadata["timed_out_state"] = string(stateID)
+	instance.StateDeadline = nil
+	engine.mutex.Unlock()
+
+	timeoutErr := fmt.Errorf("state %s timed out", stateID)
+
+	transitions := selectTimeoutTransitions(engine.stateMachineCore.GetTransitionsFromState(stateID))
+	if len(transitions) == 0 {
+		return true, engine.failWorkflow(instanceID, timeoutErr)
+	}
+	engine.orderTransitions(transitions)
+
+	toStateID := transitions[0].GetToStateID()
+	if _, err := engine.executeTransition(instanceID, transitions[0]); err != nil {
+		// A step that committed first, or a pause or cancel, wins over the timeout
+		if errors.Is(err, ErrTransitionSuperseded) || errors.Is(err, ErrInstanceNotRunning) {
+			return false, nil
+		}
+		var exhausted *exhaustedTransitionError
+		if !errors.As(err, &exhausted) {
+			return true, engine.failWorkflow(instanceID, fmt.Errorf("%v: timeout transition %s failed: %w", timeoutErr, transitions[0].GetID(), err))
+		}
+		toStateID = exhausted.stateID
+	}
+
+	// Entering an error state ends the instance
+	if state, err := engine.stateMachineCore.GetState(toStateID); err == nil && state.IsError() {
+		return true, engine.failWorkflow(instanceID, timeoutErr)
+	}
+
+	if engine.IsDebugEnabled(instanceID) {
+		return true, nil
+	}
+
+	if err := engine.executeWorkflow(instanceID); err != nil {
+		engine.errorHandler.HandleError(instanceID, fmt.Errorf("execution after timeout of state %s: %w", stateID, err))
+	}
+	return true, nil
+}
+
+// selectTimeoutTransitions returns the timeout transitions among transitions
+func selectTimeoutTransitions(transitions []layer0.Transition) []layer0.Transition {
+	var timeouts []layer0.Transition
+	for _, transition := range transitions {
+		if transition.GetType() == layer0.TransitionTypeTimeout {
+			timeouts = append(timeouts, transition)
+		}
+	}
+	return timeouts
+}
+
+// failWorkflow marks an active instance failed with the given error
+func (engine *WorkflowRuntimeEngine) failWorkflow(instanceID WorkflowInstanceID, cause error) error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	instance, exists := engine.activeInstances[instanceID]
+	if !exists {
+		return fmt.Errorf("workflow instance %s not found", instanceID)
+	}
+
+	engine.markTerminal(instance, WorkflowInstanceStatusFailed)
+	instance.Error = cause.Error()
+	instance.StateDeadline = nil
+	instance.PauseDeadline = nil
+
+	if err := engine.updateInstance(*instance); err != nil {
+		return fmt.Errorf("failed to update workflow instance: %w", err)
+	}
+
+	engine.retireInstanceUnsafe(instance)
+
+	if err := engine.lifecycleManager.OnWorkflowFailed(instanceID, cause); err != nil {
+		engine.errorHandler.HandleError(instanceID, fmt.Errorf("lifecycle manager error: %w", err))
+	}
+
+	return nil
+}
+
+// StartStateTimeoutWatcher processes state timeouts on the given interval until the
+// returned stop function is called. Errors are reported to the error handler.
+func (engine *WorkflowRuntimeEngine) StartStateTimeoutWatcher(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := engine.ProcessStateTimeouts(engine.clock.Now()); err != nil {
+					engine.errorHandler.HandleError("", fmt.Errorf("state timeout watcher error: %w", err))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
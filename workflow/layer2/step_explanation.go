@@ -0,0 +1,81 @@
+package layer2
+
+import (
+	"fmt"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// DetailedTransitionEvaluator is implemented by evaluators that can report the
+// result of each condition rather than only their combined outcome
+type DetailedTransitionEvaluator interface {
+	TransitionEvaluator
+	EvaluateConditionDetails(conditionIDs []string, context *layer0.Context) ([]layer1.ConditionEvaluationResult, error)
+}
+
+// TransitionExplanation describes whether an outgoing transition can fire and why
+type TransitionExplanation struct {
+	Transition  layer0.Transition                  `json:"transition"`
+	Satisfiable bool                               `json:"satisfiable"`
+	Conditions  []layer1.ConditionEvaluationResult `json:"conditions,omitempty"`
+	Error       string                             `json:"error,omitempty"`
+}
+
+// StepExplanation describes the outgoing transitions of an instance's current state
+type StepExplanation struct {
+	InstanceID     WorkflowInstanceID      `json:"instance_id"`
+	CurrentStateID layer0.StateID          `json:"current_state_id"`
+	Status         WorkflowInstanceStatus  `json:"status"`
+	Transitions    []TransitionExplanation `json:"transitions"`
+}
+
+// ExplainStep evaluates every outgoing transition of an instance's current state, in
+// the order a step would consider them, and reports the result of each condition.
+// The instance is not advanced and nothing is persisted; per-condition results are
+// reported only when the transition evaluator is a DetailedTransitionEvaluator.
+func (engine *WorkflowRuntimeEngine) ExplainStep(instanceID WorkflowInstanceID) (StepExplanation, error) {
+	engine.mutex.RLock()
+	instance, exists := engine.activeInstances[instanceID]
+	if !exists {
+		engine.mutex.RUnlock()
+		return StepExplanation{}, fmt.Errorf("workflow instance %s not found", instanceID)
+	}
+	explanation := StepExplanation{
+		InstanceID:     instanceID,
+		CurrentStateID: instance.CurrentStateID,
+		Status:         instance.Status,
+	}
+	evaluator := engine.transitionEvaluator
+	engine.mutex.RUnlock()
+
+	workContext := engine.conditionContext(instance)
+
+	transitions := selectableTransitions(engine.stateMachineCore.GetTransitionsFromState(explanation.CurrentStateID))
+	engine.orderTransitions(transitions)
+
+	detailed, hasDetails := evaluator.(DetailedTransitionEvaluator)
+	explanation.Transitions = make([]TransitionExplanation, 0, len(transitions))
+	for _, transition := range transitions {
+		transitionExplanation := TransitionExplanation{Transition: transition}
+
+		satisfiable, err := evaluator.CanTransition(transition, workContext)
+		if err != nil {
+			transitionExplanation.Error = err.Error()
+		} else {
+			transitionExplanation.Satisfiable = satisfiable
+		}
+
+		if hasDetails && len(transition.GetConditions()) > 0 {
+			conditions, err := detailed.EvaluateConditionDetails(transition.GetConditions(), workContext)
+			if err != nil && transitionExplanation.Error == "" {
+				transitionExplanation.Error = err.Error()
+			}
+			transitionExplanation.Conditions = conditions
+		}
+
+		explanation.Transitions = append(explanation.Transitions, transitionExplanation)
+	}
+
+	return explanation, nil
+}
@@ -0,0 +1,58 @@
+package layer2
+
+import (
+	"fmt"
+)
+
+// SetStepConcurrency limits how many workflow steps run at once across all instances.
+// Steps waiting for a slot run in arrival order, so an instance that keeps stepping
+// queues behind instances that were already waiting and none is starved. A limit of
+// zero removes the limit.
+func (engine *WorkflowRuntimeEngine) SetStepConcurrency(limit int) error {
+	if limit < 0 {
+		return fmt.Errorf("step concurrency cannot be negative")
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if limit == 0 {
+		engine.stepScheduler = nil
+		return nil
+	}
+	engine.stepScheduler = newWorkScheduler(limit)
+	return nil
+}
+
+// QueuedSteps returns the number of steps waiting for a slot under the step concurrency limit
+func (engine *WorkflowRuntimeEngine) QueuedSteps() int {
+	engine.mutex.RLock()
+	scheduler := engine.stepScheduler
+	engine.mutex.RUnlock()
+
+	if scheduler == nil {
+		return 0
+	}
+	return scheduler.queued()
+}
+
+// scheduleStep waits for a step slot when step concurrency is limited, records how
+// long the instance waited and returns the function that frees the slot
+func (engine *WorkflowRuntimeEngine) scheduleStep(instanceID WorkflowInstanceID) (func(), error) {
+	engine.mutex.RLock()
+	scheduler := engine.stepScheduler
+	instance, exists := engine.activeInstances[instanceID]
+	engine.mutex.RUnlock()
+
+	// Unknown instances are reported by the step itself
+	if scheduler == nil || !exists {
+		return func() {}, nil
+	}
+
+	queuedAt := engine.clock.Now()
+	if err := scheduler.acquire(engine.instanceContext(instanceID), 0); err != nil {
+		return nil, fmt.Errorf("step of workflow instance %s was not scheduled: %w", instanceID, err)
+	}
+	engine.recordStepWait(instance, engine.clock.Now().Sub(queuedAt))
+	return scheduler.release, nil
+}
@@ -1,6 +1,10 @@
 package layer2
 
 import (
+	"context"
+	"fmt"
+	"sync"
+
 	"github.com/ubom/workflow/layer0"
 	"github.com/ubom/workflow/layer1"
 )
@@ -11,20 +15,51 @@ type TransitionEvaluator interface {
 	EvaluateConditions(conditionIDs []string, context *layer0.Context) (bool, error)
 }
 
-// DefaultTransitionEvaluator provides a default implementation of TransitionEvaluator
+// DefaultTransitionEvaluator provides a default implementation of TransitionEvaluator.
+// Condition IDs defined with DefineCondition are evaluated by the evaluator for their
+// type; any other condition ID holds unless the context sets that key to false or nil.
 type DefaultTransitionEvaluator struct {
 	conditionEvaluationCore *layer1.ConditionEvaluationCore
+	conditions              map[string]layer0.Condition
+	mutex                   sync.RWMutex
 }
 
 // NewDefaultTransitionEvaluator creates a new default transition evaluator
 func NewDefaultTransitionEvaluator() *DefaultTransitionEvaluator {
 	return &DefaultTransitionEvaluator{
 		conditionEvaluationCore: layer1.NewConditionEvaluationCore(),
+		conditions:              make(map[string]layer0.Condition),
 	}
 }
 
+// DefineCondition makes transitions that reference the condition's ID evaluate it with
+// evaluator, which is registered for the condition's type unless one already is
+func (evaluator *DefaultTransitionEvaluator) DefineCondition(condition layer0.Condition, conditionEvaluator layer1.ConditionEvaluator) error {
+	if err := condition.Validate(); err != nil {
+		return fmt.Errorf("invalid condition: %w", err)
+	}
+
+	if _, err := evaluator.conditionEvaluationCore.GetEvaluator(condition.GetType()); err != nil {
+		if err := evaluator.conditionEvaluationCore.RegisterEvaluator(condition.GetType(), conditionEvaluator); err != nil {
+			return err
+		}
+	}
+
+	evaluator.mutex.Lock()
+	defer evaluator.mutex.Unlock()
+
+	evaluator.conditions[string(condition.GetID())] = condition
+	return nil
+}
+
 // CanTransition evaluates whether a transition can be taken
-func (evaluator *DefaultTransitionEvaluator) CanTransition(transition layer0.Transition, context *layer0.Context) (bool, error) {
+func (evaluator *DefaultTransitionEvaluator) CanTransition(transition layer0.Transition, workContext *layer0.Context) (bool, error) {
+	return evaluator.CanTransitionWithContext(context.Background(), transition, workContext)
+}
+
+// CanTransitionWithContext evaluates whether a transition can be taken, passing ctx
+// to condition evaluators that support cancellation
+func (evaluator *DefaultTransitionEvaluator) CanTransitionWithContext(ctx context.Context, transition layer0.Transition, context *layer0.Context) (bool, error) {
 	// Check transition status
 	if !transition.IsReady() && transition.GetStatus() != layer0.TransitionStatusPending {
 		return false, nil
@@ -36,50 +71,73 @@ func (evaluator *DefaultTransitionEvaluator) CanTransition(transition layer0.Tra
 		return true, nil
 	}
 
-	// Evaluate all conditions
-	return evaluator.EvaluateConditions(conditions, context)
+	// Evaluate conditions with the transition's operator
+	return evaluator.conditionEvaluationCore.EvaluateConditionsWithContext(ctx, evaluator.buildConditions(conditions), context, transition.GetOperator())
 }
 
-// EvaluateConditions evaluates a list of condition IDs
+// EvaluateConditions evaluates a list of condition IDs, all of which must hold
 func (evaluator *DefaultTransitionEvaluator) EvaluateConditions(conditionIDs []string, context *layer0.Context) (bool, error) {
+	return evaluator.EvaluateConditionsWithOperator(conditionIDs, context, layer0.ConditionOperatorAnd)
+}
+
+// EvaluateConditionsWithOperator evaluates a list of condition IDs combined with the given operator
+func (evaluator *DefaultTransitionEvaluator) EvaluateConditionsWithOperator(conditionIDs []string, context *layer0.Context, operator layer0.ConditionOperator) (bool, error) {
 	if len(conditionIDs) == 0 {
 		return true, nil
 	}
 
-	// For simplicity, we'll assume all conditions must be true (AND logic)
-	// In a real implementation, this would be more sophisticated
-	for _, conditionID := range conditionIDs {
-		// Create a simple condition for evaluation
-		condition := layer0.NewCondition(layer0.ConditionID(conditionID), layer0.ConditionTypeExpression, conditionID)
-		condition.Expression.Expression = "true" // Default to true for now
-
-		// Register a simple evaluator if not already registered
-		if len(evaluator.conditionEvaluationCore.GetSupportedConditionTypes()) == 0 {
-			simpleEvaluator := layer1.NewMockConditionEvaluator(
-				[]layer0.ConditionType{layer0.ConditionTypeExpression},
-				func(c layer0.Condition, ctx *layer0.Context) (interface{}, error) {
-					// Simple evaluation: check if context has a key matching the condition ID
-					if value, exists := ctx.Get(string(c.GetID())); exists {
-						if boolValue, ok := value.(bool); ok {
-							return boolValue, nil
-						}
-						return value != nil, nil
-					}
-					return true, nil // Default to true if no specific condition
-				},
-			)
-			evaluator.conditionEvaluationCore.RegisterEvaluator(layer0.ConditionTypeExpression, simpleEvaluator)
-		}
+	return evaluator.conditionEvaluationCore.EvaluateConditions(evaluator.buildConditions(conditionIDs), context, operator)
+}
 
+// EvaluateConditionDetails evaluates each condition ID and returns every result,
+// including conditions that did not hold or failed to evaluate
+func (evaluator *DefaultTransitionEvaluator) EvaluateConditionDetails(conditionIDs []string, context *layer0.Context) ([]layer1.ConditionEvaluationResult, error) {
+	results := make([]layer1.ConditionEvaluationResult, 0, len(conditionIDs))
+	for _, condition := range evaluator.buildConditions(conditionIDs) {
 		result, err := evaluator.conditionEvaluationCore.EvaluateCondition(condition, context)
 		if err != nil {
-			return false, err
+			return results, fmt.Errorf("failed to evaluate condition %s: %w", condition.GetID(), err)
 		}
+		results = append(results, result)
+	}
+	return results, nil
+}
 
-		if result.Status != layer0.ConditionStatusTrue {
-			return false, nil
-		}
+// buildConditions creates the conditions evaluated for a list of condition IDs,
+// registering the simple evaluator on first use
+func (evaluator *DefaultTransitionEvaluator) buildConditions(conditionIDs []string) []layer0.Condition {
+	// Register a simple evaluator if not already registered
+	if _, err := evaluator.conditionEvaluationCore.GetEvaluator(layer0.ConditionTypeExpression); err != nil {
+		simpleEvaluator := layer1.NewMockConditionEvaluator(
+			[]layer0.ConditionType{layer0.ConditionTypeExpression},
+			func(c layer0.Condition, ctx *layer0.Context) (interface{}, error) {
+				// Simple evaluation: check if context has a key matching the condition ID
+				if value, exists := ctx.Get(string(c.GetID())); exists {
+					if boolValue, ok := value.(bool); ok {
+						return boolValue, nil
+					}
+					return value != nil, nil
+				}
+				return true, nil // Default to true if no specific condition
+			},
+		)
+		evaluator.conditionEvaluationCore.RegisterEvaluator(layer0.ConditionTypeExpression, simpleEvaluator)
 	}
 
-	return true, nil
+	evaluator.mutex.RLock()
+	defer evaluator.mutex.RUnlock()
+
+	conditions := make([]layer0.Condition, 0, len(conditionIDs))
+	for _, conditionID := range conditionIDs {
+		if defined, exists := evaluator.conditions[conditionID]; exists {
+			conditions = append(conditions, defined)
+			continue
+		}
+
+		// Create a simple condition for evaluation
+		condition := layer0.NewCondition(layer0.ConditionID(conditionID), layer0.ConditionTypeExpression, conditionID)
+		condition.Expression.Expression = "true" // Default to true for now
+		conditions = append(conditions, condition)
+	}
+	return conditions
 }
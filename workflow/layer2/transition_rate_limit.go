@@ -0,0 +1,87 @@
+package layer2
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SetTransitionRateLimit sets the least time between two transitions of an instance
+// whose definition does not set one. A step taken sooner waits until the interval
+// has passed on the engine clock, or until the instance is cancelled. Zero disables
+// the limit.
+func (engine *WorkflowRuntimeEngine) SetTransitionRateLimit(interval time.Duration) error {
+	if interval < 0 {
+		return fmt.Errorf("transition rate limit interval cannot be negative")
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.transitionInterval = interval
+	return nil
+}
+
+// nextTransitionAt returns when an instance that transitioned at transitionedAt may
+// transition again, or nil when it is not rate limited
+func (engine *WorkflowRuntimeEngine) nextTransitionAt(instance *WorkflowInstance, transitionedAt time.Time) *time.Time {
+	engine.mutex.RLock()
+	interval := engine.transitionInterval
+	if instance.MinTransitionInterval > 0 {
+		interval = instance.MinTransitionInterval
+	}
+	engine.mutex.RUnlock()
+
+	if interval <= 0 {
+		return nil
+	}
+	next := transitionedAt.Add(interval)
+	return &next
+}
+
+// awaitTransitionSlot blocks until the instance may transition again or its
+// context is cancelled
+func (engine *WorkflowRuntimeEngine) awaitTransitionSlot(instance *WorkflowInstance) error {
+	engine.mutex.RLock()
+	nextTransitionAt := instance.NextTransitionAt
+	engine.mutex.RUnlock()
+
+	if nextTransitionAt == nil {
+		return nil
+	}
+
+	delay := nextTransitionAt.Sub(engine.clock.Now())
+	if delay <= 0 {
+		return nil
+	}
+
+	return engine.sleep(engine.instanceContext(instance.ID), delay)
+}
+
+// sleep blocks until duration has passed on the engine clock or ctx is done. During
+// a rebuild the replay clock is moved forward instead, since the recorded command
+// only completed once the time had passed.
+func (engine *WorkflowRuntimeEngine) sleep(ctx context.Context, duration time.Duration) error {
+	engine.mutex.RLock()
+	replayClock := engine.replayClock
+	engine.mutex.RUnlock()
+
+	if replayClock != nil {
+		replayClock.Advance(duration)
+		return nil
+	}
+
+	var ready <-chan time.Time
+	if timerClock, ok := engine.clock.(TimerClock); ok {
+		ready = timerClock.After(duration)
+	} else {
+		ready = time.After(duration)
+	}
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
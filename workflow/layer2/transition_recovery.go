@@ -0,0 +1,120 @@
+package layer2
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// exhaustedTransitionError reports that a transition failed on every attempt its
+// recovery policy allowed and the instance was compensated and moved to the
+// policy's recovery state
+type exhaustedTransitionError struct {
+	transitionID layer0.TransitionID
+	stateID      layer0.StateID
+	err          error
+}
+
+// Error describes the exhausted transition and its last failure
+func (e *exhaustedTransitionError) Error() string {
+	return fmt.Sprintf("transition %s exhausted its retries and moved to state %s: %v", e.transitionID, e.stateID, e.err)
+}
+
+// Unwrap returns the last failure of the transition
+func (e *exhaustedTransitionError) Unwrap() error {
+	return e.err
+}
+
+// runTransitionWithRecovery runs a transition under its recovery policy. A failed
+// transition is retried up to policy.Retry times; once the retries are exhausted
+// the compensation actions run and, when the policy names a recovery state, the
+// instance is moved there and an exhaustedTransitionError is returned. Suspensions,
+// invariant violations, an exhausted retry budget and superseded transitions are
+// not retried.
+func (engine *WorkflowRuntimeEngine) runTransitionWithRecovery(instanceID WorkflowInstanceID, transition layer0.Transition, policy layer0.TransitionRecoveryPolicy) ([]layer1.WorkExecutionResult, error) {
+	var workResults []layer1.WorkExecutionResult
+	var err error
+	for attempt := 0; attempt <= policy.Retry; attempt++ {
+		var results []layer1.WorkExecutionResult
+		results, err = engine.runTransition(instanceID, transition, 0, nil)
+		workResults = append(workResults, results...)
+		if err == nil || !isRecoverableTransitionError(err) {
+			return workResults, err
+		}
+	}
+
+	engine.mutex.RLock()
+	instance := engine.activeInstances[instanceID]
+	engine.mutex.RUnlock()
+
+	staged, compensationResults, compensationErr := engine.runCompensation(instance, policy.Compensation)
+	workResults = append(workResults, compensationResults...)
+	if compensationErr != nil {
+		return workResults, fmt.Errorf("%v; compensation of transition %s failed: %w", err, transition.GetID(), compensationErr)
+	}
+
+	if policy.OnExhausted == "" {
+		return workResults, err
+	}
+
+	recovery := transition.Clone()
+	recovery.ToStateID = policy.OnExhausted
+	if commitErr := engine.commitTransition(instance, recovery, staged); commitErr != nil {
+		return workResults, commitErr
+	}
+	return workResults, &exhaustedTransitionError{transitionID: transition.GetID(), stateID: policy.OnExhausted, err: err}
+}
+
+// isRecoverableTransitionError checks if a transition failure may be retried and compensated
+func isRecoverableTransitionError(err error) bool {
+	return !errors.Is(err, errWorkSuspended) && !errors.Is(err, ErrInvariantViolated) && !errors.Is(err, ErrRetryBudgetExhausted) &&
+		!errors.Is(err, ErrTransitionSuperseded)
+}
+
+// runCompensation runs compensation actions in order against the instance context,
+// returning the context with their outputs. Compensation work may not suspend.
+func (engine *WorkflowRuntimeEngine) runCompensation(instance *WorkflowInstance, actions []string) (*layer0.Context, []layer1.WorkExecutionResult, error) {
+	engine.mutex.RLock()
+	staged := instance.Context.Clone()
+	engine.mutex.RUnlock()
+
+	ctx := layer1.WithCorrelationID(engine.instanceContext(instance.ID), instance.CorrelationID)
+	workResults := make([]layer1.WorkExecutionResult, 0, len(actions))
+	for _, actionID := range actions {
+		work := engine.buildActionWork(instance.ID, actionID)
+
+		result, err := engine.executeWorkWithRetries(ctx, instance, work, staged)
+		if err != nil {
+			return staged, workResults, fmt.Errorf("failed to execute compensation work %s: %w", actionID, err)
+		}
+		workResults = append(workResults, result)
+
+		switch {
+		case result.Status == layer0.WorkStatusFailed:
+			return staged, workResults, fmt.Errorf("compensation work %s failed: %s", actionID, result.Error)
+		case isAwaitingCompletion(result.Status):
+			return staged, workResults, fmt.Errorf("compensation work %s cannot wait for completion", actionID)
+		}
+
+		output, err := engine.transformOutput(work, result.Output)
+		if err != nil {
+			return staged, workResults, err
+		}
+		if output != nil {
+			staged = staged.Set(actionOutputKey(work), output)
+		}
+	}
+
+	return staged, workResults, nil
+}
+
+// failOnErrorState fails the instance with err when it was moved into an error state
+func (engine *WorkflowRuntimeEngine) failOnErrorState(instanceID WorkflowInstanceID, stateID layer0.StateID, err error) error {
+	state, stateErr := engine.stateMachineCore.GetState(stateID)
+	if stateErr != nil || !state.IsError() {
+		return nil
+	}
+	return engine.failWorkflow(instanceID, err)
+}
@@ -0,0 +1,236 @@
+package layer2
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// SetTransitionTiebreakSeed makes the engine break ties between transitions of
+// equal priority with a seeded, deterministic ordering instead of by ID.
+// The ordering depends only on the seed and the transition IDs, so the same
+// state and context always select the same transition.
+func (engine *WorkflowRuntimeEngine) SetTransitionTiebreakSeed(seed int64) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.tiebreakSeed = seed
+	engine.seededTiebreak = true
+	engine.selectionRand = nil
+}
+
+// ClearTransitionTiebreakSeed restores the default tiebreak by transition ID
+func (engine *WorkflowRuntimeEngine) ClearTransitionTiebreakSeed() {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.tiebreakSeed = 0
+	engine.seededTiebreak = false
+	engine.selectionRand = nil
+}
+
+// orderTransitions sorts transitions in the order the engine considers them:
+// highest priority first, with ties broken by the seeded rank or by ID
+func (engine *WorkflowRuntimeEngine) orderTransitions(transitions []layer0.Transition) {
+	engine.mutex.RLock()
+	seed, seeded := engine.tiebreakSeed, engine.seededTiebreak
+	engine.mutex.RUnlock()
+
+	sort.SliceStable(transitions, func(i, j int) bool {
+		a, b := transitions[i], transitions[j]
+		if a.GetPriority() != b.GetPriority() {
+			return a.GetPriority() > b.GetPriority()
+		}
+
+		if seeded {
+			rankA, rankB := tiebreakRank(seed, a.GetID()), tiebreakRank(seed, b.GetID())
+			if rankA != rankB {
+				return rankA < rankB
+			}
+		}
+
+		return a.GetID() < b.GetID()
+	})
+}
+
+// tiebreakRank derives a stable pseudo-random rank for a transition from the seed
+func tiebreakRank(seed int64, transitionID layer0.TransitionID) uint64 {
+	hash := fnv.New64a()
+	var seedBytes [8]byte
+	binary.LittleEndian.PutUint64(seedBytes[:], uint64(seed))
+	hash.Write(seedBytes[:])
+	hash.Write([]byte(transitionID))
+	return hash.Sum64()
+}
+
+// SetTransitionSelectionStrategy sets how a step chooses among several satisfiable
+// transitions for instances whose definition does not set a strategy. The default
+// is first match. The random strategy draws from an RNG seeded by the tiebreak seed,
+// so a seeded engine makes the same choices for the same sequence of steps.
+func (engine *WorkflowRuntimeEngine) SetTransitionSelectionStrategy(strategy layer1.TransitionSelectionStrategy) error {
+	if err := strategy.Validate(); err != nil {
+		return err
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.selectionStrategy = strategy
+	return nil
+}
+
+// transitionSelectionStrategy returns the strategy for an instance, preferring its definition's
+func (engine *WorkflowRuntimeEngine) transitionSelectionStrategy(instance *WorkflowInstance) layer1.TransitionSelectionStrategy {
+	if instance.TransitionSelection != "" {
+		return instance.TransitionSelection
+	}
+
+	engine.mutex.RLock()
+	defer engine.mutex.RUnlock()
+
+	if engine.selectionStrategy == "" {
+		return layer1.TransitionSelectionFirstMatch
+	}
+	return engine.selectionStrategy
+}
+
+// selectTransitions chooses what a step executes from the satisfiable transitions,
+// which are in the engine's order. Weighted transitions are chosen among by weight
+// before the strategy applies; zero weights are never chosen, and only unweighted
+// transitions remain for the strategy. First match is otherwise handled while
+// evaluating and is not selected here.
+func (engine *WorkflowRuntimeEngine) selectTransitions(strategy layer1.TransitionSelectionStrategy, satisfiable []layer0.Transition) ([]layer0.Transition, error) {
+	weighted, unweighted, total := splitWeightedTransitions(satisfiable)
+	if total > 0 {
+		engine.mutex.Lock()
+		pick := engine.selectionRandUnsafe().Float64() * total
+		engine.mutex.Unlock()
+
+		for _, transition := range weighted {
+			weight, _ := transition.GetWeight()
+			if pick < weight {
+				return []layer0.Transition{transition}, nil
+			}
+			pick -= weight
+		}
+		return weighted[len(weighted)-1:], nil
+	}
+
+	satisfiable = unweighted
+	if len(satisfiable) == 0 {
+		return nil, nil
+	}
+
+	switch strategy {
+	case layer1.TransitionSelectionHighestPriority:
+		if len(satisfiable) > 1 && satisfiable[1].GetPriority() == satisfiable[0].GetPriority() {
+			return nil, fmt.Errorf("transitions %s and %s are both satisfiable at the highest priority %d",
+				satisfiable[0].GetID(), satisfiable[1].GetID(), satisfiable[0].GetPriority())
+		}
+		return satisfiable[:1], nil
+
+	case layer1.TransitionSelectionRandom:
+		engine.mutex.Lock()
+		index := engine.selectionRandUnsafe().Intn(len(satisfiable))
+		engine.mutex.Unlock()
+		return satisfiable[index : index+1], nil
+
+	case layer1.TransitionSelectionAll:
+		fork, err := forkTransition(satisfiable)
+		if err != nil {
+			return nil, err
+		}
+		return []layer0.Transition{fork}, nil
+
+	default:
+		return satisfiable, nil
+	}
+}
+
+// selectionRandUnsafe returns the random source for transition selection, seeded
+// by the tiebreak seed when one is set.
+// This method assumes the caller already holds the mutex lock.
+func (engine *WorkflowRuntimeEngine) selectionRandUnsafe() *rand.Rand {
+	if engine.selectionRand == nil {
+		seed := engine.clock.Now().UnixNano()
+		if engine.seededTiebreak {
+			seed = engine.tiebreakSeed
+		}
+		engine.selectionRand = rand.New(rand.NewSource(seed))
+	}
+	return engine.selectionRand
+}
+
+// splitWeightedTransitions separates transitions with a positive weight from those
+// without a weight, dropping zero weights, and totals the positive weights
+func splitWeightedTransitions(transitions []layer0.Transition) (weighted, unweighted []layer0.Transition, total float64) {
+	for _, transition := range transitions {
+		weight, hasWeight := transition.GetWeight()
+		switch {
+		case !hasWeight:
+			unweighted = append(unweighted, transition)
+		case weight > 0:
+			weighted = append(weighted, transition)
+			total += weight
+		}
+	}
+	return weighted, unweighted, total
+}
+
+// hasWeightedTransition reports whether any transition has a weight
+func hasWeightedTransition(transitions []layer0.Transition) bool {
+	for _, transition := range transitions {
+		if _, hasWeight := transition.GetWeight(); hasWeight {
+			return true
+		}
+	}
+	return false
+}
+
+// forkTransition combines transitions into one that runs all of their actions in
+// parallel, each action once. The combined transition is identified by the joined
+// IDs and takes the tags and annotations of all of them.
+func forkTransition(transitions []layer0.Transition) (layer0.Transition, error) {
+	if len(transitions) == 1 {
+		return transitions[0], nil
+	}
+
+	first := transitions[0]
+	ids := make([]string, 0, len(transitions))
+	for _, transition := range transitions {
+		if transition.GetToStateID() != first.GetToStateID() {
+			return layer0.Transition{}, fmt.Errorf("transitions %s and %s lead to different states and cannot run together",
+				first.GetID(), transition.GetID())
+		}
+		ids = append(ids, string(transition.GetID()))
+	}
+
+	fork := layer0.NewTransition(layer0.TransitionID(strings.Join(ids, "+")), first.GetType(), first.GetFromStateID(), first.GetToStateID(), first.GetMetadata().Name).
+		SetExecutionMode(layer0.ActionExecutionParallel).
+		SetConflictStrategy(first.GetConflictStrategy())
+	fork.Priority = first.GetPriority()
+
+	seen := make(map[string]bool)
+	for _, transition := range transitions {
+		for _, actionID := range transition.GetActions() {
+			if !seen[actionID] {
+				seen[actionID] = true
+				fork = fork.AddAction(actionID)
+			}
+		}
+		for _, tag := range transition.GetMetadata().Tags {
+			fork = fork.AddTag(tag)
+		}
+		for key, value := range transition.GetAnnotations() {
+			fork = fork.SetAnnotation(key, value)
+		}
+	}
+
+	return fork, nil
+}
@@ -0,0 +1,58 @@
+package layer2
+
+import (
+	"fmt"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// workPropertyDuration is the work metadata property holding how long the work ran
+const workPropertyDuration = "duration"
+
+// SetWorkPersistence sets whether executed work is recorded in the persistence store.
+// Recording is enabled by default so ListWork reflects an instance's execution history.
+func (engine *WorkflowRuntimeEngine) SetWorkPersistence(enabled bool) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.persistWork = enabled
+}
+
+// persistWorkResult records the outcome of executed work in the persistence store.
+// Pending and blocked work is persisted when the instance suspends instead.
+func (engine *WorkflowRuntimeEngine) persistWorkResult(instanceID WorkflowInstanceID, work layer0.Work, result layer1.WorkExecutionResult) error {
+	engine.mutex.RLock()
+	enabled := engine.persistWork
+	engine.mutex.RUnlock()
+
+	if !enabled || isAwaitingCompletion(result.Status) {
+		return nil
+	}
+
+	executed := work.Clone()
+	executed.Status = result.Status
+	executed.Output = result.Output
+	executed.Error = result.Error
+	startedAt := result.StartedAt
+	executed.Metadata.StartedAt = &startedAt
+	executed.Metadata.CompletedAt = result.CompletedAt
+	executed.Metadata.Properties[workPropertyDuration] = result.Duration.String()
+
+	return engine.saveOrUpdateWork(instanceID, executed)
+}
+
+// saveOrUpdateWork persists work, replacing the record of an earlier run of the same work
+func (engine *WorkflowRuntimeEngine) saveOrUpdateWork(instanceID WorkflowInstanceID, work layer0.Work) error {
+	if _, err := engine.persistenceStore.GetWork(instanceID, work.GetID()); err == nil {
+		if err := engine.persistenceStore.UpdateWork(instanceID, work); err != nil {
+			return fmt.Errorf("failed to update work %s: %w", work.GetID(), err)
+		}
+		return nil
+	}
+
+	if err := engine.persistenceStore.SaveWork(instanceID, work); err != nil {
+		return fmt.Errorf("failed to save work %s: %w", work.GetID(), err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package layer2
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+const (
+	// workResultStreamBuffer is how many events a subscriber may fall behind before
+	// further events are dropped for it
+	workResultStreamBuffer = 64
+	// workResultOutputLimit bounds the output summary carried by an event
+	workResultOutputLimit = 256
+)
+
+// WorkResultEvent describes one executed work of an instance
+type WorkResultEvent struct {
+	InstanceID WorkflowInstanceID `json:"instance_id"`
+	WorkID     layer0.WorkID      `json:"work_id"`
+	WorkType   layer0.WorkType    `json:"work_type"`
+	Status     layer0.WorkStatus  `json:"status"`
+	Succeeded  bool               `json:"succeeded"`
+	Duration   time.Duration      `json:"duration"`
+	Output     string             `json:"output,omitempty"` // The output formatted and truncated for display
+	Error      string             `json:"error,omitempty"`
+}
+
+// workResultSubscription is one subscriber's stream of work results
+type workResultSubscription struct {
+	events chan WorkResultEvent
+}
+
+// SubscribeWorkResults streams an event for each work the instance executes, after
+// retries, as its transitions run. Every subscriber has its own buffered channel;
+// events for a subscriber that falls behind are dropped so the engine never blocks.
+// The channel is closed when the instance finishes or cancel is called. Subscribing
+// to an instance that is not active returns a closed channel.
+func (engine *WorkflowRuntimeEngine) SubscribeWorkResults(instanceID WorkflowInstanceID) (<-chan WorkResultEvent, func()) {
+	subscription := &workResultSubscription{events: make(chan WorkResultEvent, workResultStreamBuffer)}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	instance, exists := engine.activeInstances[instanceID]
+	if !exists || instance.IsTerminal() {
+		close(subscription.events)
+		return subscription.events, func() {}
+	}
+	engine.workResultSubscriptions[instanceID] = append(engine.workResultSubscriptions[instanceID], subscription)
+
+	return subscription.events, func() {
+		engine.mutex.Lock()
+		defer engine.mutex.Unlock()
+
+		subscriptions := engine.workResultSubscriptions[instanceID]
+		for index, registered := range subscriptions {
+			if registered == subscription {
+				engine.workResultSubscriptions[instanceID] = append(subscriptions[:index:index], subscriptions[index+1:]...)
+				close(subscription.events)
+				break
+			}
+		}
+	}
+}
+
+// publishWorkResult sends the result of executed work to the instance's subscribers
+func (engine *WorkflowRuntimeEngine) publishWorkResult(instanceID WorkflowInstanceID, work layer0.Work, result layer1.WorkExecutionResult) {
+	engine.mutex.RLock()
+	defer engine.mutex.RUnlock()
+
+	subscriptions := engine.workResultSubscriptions[instanceID]
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	event := WorkResultEvent{
+		InstanceID: instanceID,
+		WorkID:     work.GetID(),
+		WorkType:   work.GetType(),
+		Status:     result.Status,
+		Succeeded:  result.Status == layer0.WorkStatusCompleted,
+		Duration:   result.Duration,
+		Output:     summarizeWorkOutput(result.Output),
+		Error:      result.Error,
+	}
+	for _, subscription := range subscriptions {
+		select {
+		case subscription.events <- event:
+		default:
+		}
+	}
+}
+
+// closeWorkResultSubscriptionsUnsafe ends the work result streams of an instance.
+// This method assumes the caller already holds the mutex lock.
+func (engine *WorkflowRuntimeEngine) closeWorkResultSubscriptionsUnsafe(instanceID WorkflowInstanceID) {
+	for _, subscription := range engine.workResultSubscriptions[instanceID] {
+		close(subscription.events)
+	}
+	delete(engine.workResultSubscriptions, instanceID)
+}
+
+// summarizeWorkOutput formats work output for an event, truncating long output
+func summarizeWorkOutput(output interface{}) string {
+	if output == nil {
+		return ""
+	}
+
+	summary := fmt.Sprint(output)
+	if len(summary) > workResultOutputLimit {
+		summary = summary[:workResultOutputLimit] + "..."
+	}
+	return summary
+}
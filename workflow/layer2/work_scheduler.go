@@ -0,0 +1,181 @@
+package layer2
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ubom/workflow/layer0"
+)
+
+// ExecutionMode defines how the engine schedules work across instances
+type ExecutionMode string
+
+const (
+	// ExecutionModeDirect runs work as soon as an instance reaches it
+	ExecutionModeDirect ExecutionMode = "direct"
+	// ExecutionModePriorityQueue runs work on a fixed number of workers; work waiting
+	// for a worker is started highest priority first, then in arrival order
+	ExecutionModePriorityQueue ExecutionMode = "priority_queue"
+)
+
+// workWaiter is work queued for a worker
+type workWaiter struct {
+	priority layer0.WorkPriority
+	sequence uint64
+	ready    chan struct{}
+}
+
+// workScheduler hands a fixed number of worker slots to queued work by priority
+type workScheduler struct {
+	workers  int
+	running  int
+	sequence uint64
+	waiting  []*workWaiter
+	mutex    sync.Mutex
+}
+
+// newWorkScheduler creates a scheduler with the given number of workers
+func newWorkScheduler(workers int) *workScheduler {
+	return &workScheduler{workers: workers}
+}
+
+// acquire blocks until the work may run or ctx is done
+func (scheduler *workScheduler) acquire(ctx context.Context, priority layer0.WorkPriority) error {
+	scheduler.mutex.Lock()
+	if scheduler.running < scheduler.workers && len(scheduler.waiting) == 0 {
+		scheduler.running++
+		scheduler.mutex.Unlock()
+		return nil
+	}
+
+	scheduler.sequence++
+	waiter := &workWaiter{priority: priority, sequence: scheduler.sequence, ready: make(chan struct{})}
+	scheduler.waiting = append(scheduler.waiting, waiter)
+	scheduler.mutex.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-ctx.Done():
+		scheduler.mutex.Lock()
+		defer scheduler.mutex.Unlock()
+
+		// The slot may have been handed over while the context was being cancelled
+		select {
+		case <-waiter.ready:
+			scheduler.releaseUnsafe()
+		default:
+			scheduler.removeWaiterUnsafe(waiter)
+		}
+		return ctx.Err()
+	}
+}
+
+// release frees the caller's slot for the next queued work
+func (scheduler *workScheduler) release() {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	scheduler.releaseUnsafe()
+}
+
+// releaseUnsafe hands the freed slot to the highest priority waiter, if any.
+// This method assumes the caller already holds the mutex lock.
+func (scheduler *workScheduler) releaseUnsafe() {
+	if len(scheduler.waiting) == 0 {
+		scheduler.running--
+		return
+	}
+
+	next := 0
+	for index, waiter := range scheduler.waiting {
+		best := scheduler.waiting[next]
+		if waiter.priority > best.priority || (waiter.priority == best.priority && waiter.sequence < best.sequence) {
+			next = index
+		}
+	}
+
+	waiter := scheduler.waiting[next]
+	scheduler.waiting = append(scheduler.waiting[:next], scheduler.waiting[next+1:]...)
+	close(waiter.ready)
+}
+
+// removeWaiterUnsafe drops a waiter that gave up before getting a slot.
+// This method assumes the caller already holds the mutex lock.
+func (scheduler *workScheduler) removeWaiterUnsafe(target *workWaiter) {
+	for index, waiter := range scheduler.waiting {
+		if waiter == target {
+			scheduler.waiting = append(scheduler.waiting[:index], scheduler.waiting[index+1:]...)
+			return
+		}
+	}
+}
+
+// queued returns the number of work items waiting for a worker
+func (scheduler *workScheduler) queued() int {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	return len(scheduler.waiting)
+}
+
+// SetExecutionMode sets how work is scheduled. The priority queue mode runs at most
+// workers items of work at once across all instances; workers is ignored in direct mode.
+func (engine *WorkflowRuntimeEngine) SetExecutionMode(mode ExecutionMode, workers int) error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	switch mode {
+	case ExecutionModeDirect:
+		engine.scheduler = nil
+	case ExecutionModePriorityQueue:
+		if workers <= 0 {
+			return fmt.Errorf("priority queue workers must be positive")
+		}
+		engine.scheduler = newWorkScheduler(workers)
+	default:
+		return fmt.Errorf("unknown execution mode: %s", mode)
+	}
+	return nil
+}
+
+// QueuedWork returns the number of work items waiting for a worker in the priority queue mode
+func (engine *WorkflowRuntimeEngine) QueuedWork() int {
+	engine.mutex.RLock()
+	scheduler := engine.scheduler
+	engine.mutex.RUnlock()
+
+	if scheduler == nil {
+		return 0
+	}
+	return scheduler.queued()
+}
+
+// scheduleWork waits for a worker when the priority queue mode is enabled and
+// returns the function that frees it
+func (engine *WorkflowRuntimeEngine) scheduleWork(ctx context.Context, work layer0.Work) (func(), error) {
+	engine.mutex.RLock()
+	scheduler := engine.scheduler
+	engine.mutex.RUnlock()
+
+	if scheduler == nil {
+		return func() {}, nil
+	}
+
+	if err := scheduler.acquire(ctx, work.GetPriority()); err != nil {
+		return nil, fmt.Errorf("work %s was not scheduled: %w", work.GetID(), err)
+	}
+	return scheduler.release, nil
+}
+
+// instancePriority returns the priority of an active instance, or zero when it has none
+func (engine *WorkflowRuntimeEngine) instancePriority(instanceID WorkflowInstanceID) layer0.WorkPriority {
+	engine.mutex.RLock()
+	defer engine.mutex.RUnlock()
+
+	if instance, exists := engine.activeInstances[instanceID]; exists {
+		return instance.Priority
+	}
+	return 0
+}
@@ -0,0 +1,96 @@
+package layer2
+
+import (
+	"fmt"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+)
+
+// TransitionCandidate describes an outgoing transition evaluated during a step
+type TransitionCandidate struct {
+	Transition  layer0.Transition `json:"transition"`
+	Satisfiable bool              `json:"satisfiable"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// StepResult describes what happened during a single workflow step
+type StepResult struct {
+	InstanceID       WorkflowInstanceID           `json:"instance_id"`
+	FromStateID      layer0.StateID               `json:"from_state_id"`
+	ToStateID        layer0.StateID               `json:"to_state_id"`
+	Candidates       []TransitionCandidate        `json:"candidates"`
+	ChosenTransition *layer0.Transition           `json:"chosen_transition,omitempty"`
+	WorkResults      []layer1.WorkExecutionResult `json:"work_results"`
+	Completed        bool                         `json:"completed"`
+	Suspended        bool                         `json:"suspended"`
+}
+
+// EnableDebug puts a workflow instance in manual-step mode so it no longer auto-advances
+func (engine *WorkflowRuntimeEngine) EnableDebug(instanceID WorkflowInstanceID) error {
+	issuedAt := engine.clock.Now()
+	err := engine.enableDebug(instanceID)
+	engine.recordCommand(Command{Type: CommandEnableDebug, InstanceID: instanceID, IssuedAt: issuedAt}, err)
+	return err
+}
+
+// enableDebug puts an instance in manual-step mode without recording the command
+func (engine *WorkflowRuntimeEngine) enableDebug(instanceID WorkflowInstanceID) error {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	if _, exists := engine.activeInstances[instanceID]; !exists {
+		return fmt.Errorf("workflow instance %s not found", instanceID)
+	}
+
+	engine.debugInstances[instanceID] = true
+	return nil
+}
+
+// IsDebugEnabled checks if a workflow instance is in manual-step mode
+func (engine *WorkflowRuntimeEngine) IsDebugEnabled(instanceID WorkflowInstanceID) bool {
+	engine.mutex.RLock()
+	defer engine.mutex.RUnlock()
+
+	return engine.debugInstances[instanceID]
+}
+
+// Step executes exactly one transition of an instance in debug mode.
+// Every outgoing transition is evaluated first so the result reports all the
+// choices that were available, not only the one that was taken.
+func (engine *WorkflowRuntimeEngine) Step(instanceID WorkflowInstanceID) (StepResult, error) {
+	issuedAt := engine.clock.Now()
+	result, err := engine.debugStep(instanceID)
+	engine.recordCommand(Command{Type: CommandDebugStep, InstanceID: instanceID, IssuedAt: issuedAt}, err)
+	return result, err
+}
+
+// debugStep executes one transition of an instance in debug mode without recording the command
+func (engine *WorkflowRuntimeEngine) debugStep(instanceID WorkflowInstanceID) (StepResult, error) {
+	if !engine.IsDebugEnabled(instanceID) {
+		return StepResult{}, fmt.Errorf("workflow instance %s is not in debug mode", instanceID)
+	}
+
+	return engine.executeStep(instanceID, true)
+}
+
+// Continue leaves debug mode and resumes normal execution of the instance
+func (engine *WorkflowRuntimeEngine) Continue(instanceID WorkflowInstanceID) error {
+	issuedAt := engine.clock.Now()
+	err := engine.continueWorkflow(instanceID)
+	engine.recordCommand(Command{Type: CommandContinue, InstanceID: instanceID, IssuedAt: issuedAt}, err)
+	return err
+}
+
+// continueWorkflow leaves debug mode and resumes execution without recording the command
+func (engine *WorkflowRuntimeEngine) continueWorkflow(instanceID WorkflowInstanceID) error {
+	engine.mutex.Lock()
+	if _, exists := engine.activeInstances[instanceID]; !exists {
+		engine.mutex.Unlock()
+		return fmt.Errorf("workflow instance %s not found", instanceID)
+	}
+	delete(engine.debugInstances, instanceID)
+	engine.mutex.Unlock()
+
+	return engine.executeWorkflow(instanceID)
+}
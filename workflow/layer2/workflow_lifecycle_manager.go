@@ -23,6 +23,8 @@ type WorkflowLifecycleManager interface {
 	OnWorkflowResumed(instanceID WorkflowInstanceID) error
 	OnWorkflowCancelled(instanceID WorkflowInstanceID) error
 	OnStateChanged(instanceID WorkflowInstanceID, fromState, toState string) error
+	OnWorkflowStalled(instanceID WorkflowInstanceID, stalledFor time.Duration) error
+	OnWorkflowSLABreached(instanceID WorkflowInstanceID, sla time.Duration, elapsed time.Duration) error
 	GetEvents(instanceID WorkflowInstanceID) []WorkflowLifecycleEvent
 	GetAllEvents() []WorkflowLifecycleEvent
 	ClearEvents(instanceID WorkflowInstanceID) error
@@ -30,8 +32,9 @@ type WorkflowLifecycleManager interface {
 
 // DefaultWorkflowLifecycleManager provides a default implementation of WorkflowLifecycleManager
 type DefaultWorkflowLifecycleManager struct {
-	events map[WorkflowInstanceID][]WorkflowLifecycleEvent
-	mutex  sync.RWMutex
+	events      map[WorkflowInstanceID][]WorkflowLifecycleEvent
+	dedupWindow time.Duration
+	mutex       sync.RWMutex
 }
 
 // NewDefaultWorkflowLifecycleManager creates a new default workflow lifecycle manager
@@ -42,6 +45,17 @@ func NewDefaultWorkflowLifecycleManager() *DefaultWorkflowLifecycleManager {
 	}
 }
 
+// SetDeduplication drops an event when the same instance recorded an event of the
+// same type, and for state changes the same from and to states, within window.
+// This keeps the event stream clean when the engine retries an operation. Zero
+// records every event.
+func (manager *DefaultWorkflowLifecycleManager) SetDeduplication(window time.Duration) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.dedupWindow = window
+}
+
 // OnWorkflowStarted handles workflow started event
 func (manager *DefaultWorkflowLifecycleManager) OnWorkflowStarted(instanceID WorkflowInstanceID) error {
 	event := WorkflowLifecycleEvent{
@@ -51,7 +65,9 @@ func (manager *DefaultWorkflowLifecycleManager) OnWorkflowStarted(instanceID Wor
 		Data:       map[string]interface{}{},
 	}
 
-	manager.addEvent(instanceID, event)
+	if !manager.addEvent(instanceID, event) {
+		return nil
+	}
 	log.Printf("Workflow %s started", instanceID)
 	return nil
 }
@@ -65,7 +81,9 @@ func (manager *DefaultWorkflowLifecycleManager) OnWorkflowCompleted(instanceID W
 		Data:       map[string]interface{}{},
 	}
 
-	manager.addEvent(instanceID, event)
+	if !manager.addEvent(instanceID, event) {
+		return nil
+	}
 	log.Printf("Workflow %s completed", instanceID)
 	return nil
 }
@@ -81,7 +99,9 @@ func (manager *DefaultWorkflowLifecycleManager) OnWorkflowFailed(instanceID Work
 		},
 	}
 
-	manager.addEvent(instanceID, event)
+	if !manager.addEvent(instanceID, event) {
+		return nil
+	}
 	log.Printf("Workflow %s failed: %v", instanceID, err)
 	return nil
 }
@@ -95,7 +115,9 @@ func (manager *DefaultWorkflowLifecycleManager) OnWorkflowPaused(instanceID Work
 		Data:       map[string]interface{}{},
 	}
 
-	manager.addEvent(instanceID, event)
+	if !manager.addEvent(instanceID, event) {
+		return nil
+	}
 	log.Printf("Workflow %s paused", instanceID)
 	return nil
 }
@@ -109,7 +131,9 @@ func (manager *DefaultWorkflowLifecycleManager) OnWorkflowResumed(instanceID Wor
 		Data:       map[string]interface{}{},
 	}
 
-	manager.addEvent(instanceID, event)
+	if !manager.addEvent(instanceID, event) {
+		return nil
+	}
 	log.Printf("Workflow %s resumed", instanceID)
 	return nil
 }
@@ -123,7 +147,9 @@ func (manager *DefaultWorkflowLifecycleManager) OnWorkflowCancelled(instanceID W
 		Data:       map[string]interface{}{},
 	}
 
-	manager.addEvent(instanceID, event)
+	if !manager.addEvent(instanceID, event) {
+		return nil
+	}
 	log.Printf("Workflow %s cancelled", instanceID)
 	return nil
 }
@@ -140,11 +166,50 @@ func (manager *DefaultWorkflowLifecycleManager) OnStateChanged(instanceID Workfl
 		},
 	}
 
-	manager.addEvent(instanceID, event)
+	if !manager.addEvent(instanceID, event) {
+		return nil
+	}
 	log.Printf("Workflow %s state changed from %s to %s", instanceID, fromState, toState)
 	return nil
 }
 
+// OnWorkflowStalled handles workflow stalled event
+func (manager *DefaultWorkflowLifecycleManager) OnWorkflowStalled(instanceID WorkflowInstanceID, stalledFor time.Duration) error {
+	event := WorkflowLifecycleEvent{
+		InstanceID: instanceID,
+		EventType:  "workflow_stalled",
+		Timestamp:  time.Now(),
+		Data: map[string]interface{}{
+			"stalled_for": stalledFor.String(),
+		},
+	}
+
+	if !manager.addEvent(instanceID, event) {
+		return nil
+	}
+	log.Printf("Workflow %s stalled for %s", instanceID, stalledFor)
+	return nil
+}
+
+// OnWorkflowSLABreached handles workflow SLA breached event
+func (manager *DefaultWorkflowLifecycleManager) OnWorkflowSLABreached(instanceID WorkflowInstanceID, sla time.Duration, elapsed time.Duration) error {
+	event := WorkflowLifecycleEvent{
+		InstanceID: instanceID,
+		EventType:  "workflow_sla_breached",
+		Timestamp:  time.Now(),
+		Data: map[string]interface{}{
+			"sla":     sla.String(),
+			"elapsed": elapsed.String(),
+		},
+	}
+
+	if !manager.addEvent(instanceID, event) {
+		return nil
+	}
+	log.Printf("Workflow %s breached its SLA of %s after %s", instanceID, sla, elapsed)
+	return nil
+}
+
 // GetEvents retrieves all events for a specific workflow instance
 func (manager *DefaultWorkflowLifecycleManager) GetEvents(instanceID WorkflowInstanceID) []WorkflowLifecycleEvent {
 	manager.mutex.RLock()
@@ -183,14 +248,37 @@ func (manager *DefaultWorkflowLifecycleManager) ClearEvents(instanceID WorkflowI
 	return nil
 }
 
-// addEvent adds an event to the manager
-func (manager *DefaultWorkflowLifecycleManager) addEvent(instanceID WorkflowInstanceID, event WorkflowLifecycleEvent) {
+// addEvent adds an event to the manager, reporting false when it was dropped as a duplicate
+func (manager *DefaultWorkflowLifecycleManager) addEvent(instanceID WorkflowInstanceID, event WorkflowLifecycleEvent) bool {
 	manager.mutex.Lock()
 	defer manager.mutex.Unlock()
 
-	if _, exists := manager.events[instanceID]; !exists {
-		manager.events[instanceID] = []WorkflowLifecycleEvent{}
+	if manager.isDuplicateUnsafe(instanceID, event) {
+		return false
 	}
 
 	manager.events[instanceID] = append(manager.events[instanceID], event)
+	return true
+}
+
+// isDuplicateUnsafe checks whether an equivalent event was recorded within the dedup window.
+// This method assumes the caller already holds the mutex lock.
+func (manager *DefaultWorkflowLifecycleManager) isDuplicateUnsafe(instanceID WorkflowInstanceID, event WorkflowLifecycleEvent) bool {
+	if manager.dedupWindow <= 0 {
+		return false
+	}
+
+	events := manager.events[instanceID]
+	for index := len(events) - 1; index >= 0; index-- {
+		recorded := events[index]
+		if event.Timestamp.Sub(recorded.Timestamp) > manager.dedupWindow {
+			return false
+		}
+		if recorded.EventType == event.EventType &&
+			recorded.Data["from_state"] == event.Data["from_state"] &&
+			recorded.Data["to_state"] == event.Data["to_state"] {
+			return true
+		}
+	}
+	return false
 }
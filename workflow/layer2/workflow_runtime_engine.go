@@ -1,7 +1,11 @@
 package layer2
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
@@ -12,13 +16,66 @@ import (
 // WorkflowRuntimeEngine provides the main runtime engine for executing workflows
 type WorkflowRuntimeEngine struct {
 	stateMachineCore        *layer1.StateMachineCore
+	initialStateID          layer0.StateID
 	workExecutionCore       *layer1.WorkExecutionCore
+	executionBackend        ExecutionBackend
 	conditionEvaluationCore *layer1.ConditionEvaluationCore
 	persistenceStore        StatePersistenceStore
 	transitionEvaluator     TransitionEvaluator
 	errorHandler            ErrorHandler
 	lifecycleManager        WorkflowLifecycleManager
+	logger                  layer1.Logger
 	activeInstances         map[WorkflowInstanceID]*WorkflowInstance
+	finishedEphemeral       map[WorkflowInstanceID]WorkflowInstance
+	debugInstances          map[WorkflowInstanceID]bool
+	actionWork              map[string]layer0.Work
+	resultTransformers      map[string]layer1.ResultTransformer
+	parallelActionLimit     int
+	persistWork             bool
+	healthChecks            map[string]HealthCheck
+	metrics                 *MetricsRegistry
+	instanceContexts        map[WorkflowInstanceID]context.Context
+	instanceCancels         map[WorkflowInstanceID]context.CancelFunc
+	contextWatches          map[WorkflowInstanceID][]*contextWatch
+	workResultSubscriptions map[WorkflowInstanceID][]*workResultSubscription
+	contextDefaults         map[layer1.WorkflowDefinitionID]map[string]interface{}
+	commandLog              *CommandLog
+	sharedContexts          *SharedContextStore
+	secretProvider          SecretProvider
+	projectContexts         bool
+	writeAhead              writeAheadBuffer
+	scheduler               *workScheduler
+	stepScheduler           *workScheduler
+	invariants              []layer1.Invariant
+	stallThreshold          time.Duration
+	markStalledInstances    bool
+	suspended               bool
+	steps                   sync.WaitGroup
+	sharedContextKeys       []string
+	conditionTimeout        time.Duration
+	conditionTimeoutAction  ConditionTimeoutAction
+	concurrentConditions    bool
+	idGenerator             IDGenerator
+	deterministic           bool
+	history                 map[WorkflowInstanceID][]TransitionRecord
+	tiebreakSeed            int64
+	seededTiebreak          bool
+	selectionStrategy       layer1.TransitionSelectionStrategy
+	selectionRand           *rand.Rand
+	transitionInterval      time.Duration
+	replayClock             *ManualClock
+	sharder                 Sharder
+	contextPatchLimit       int
+	humanTasks              map[string]HumanTask
+	definitionResolver      DefinitionResolver
+	loadedDefinitionID      layer1.WorkflowDefinitionID
+	loadedDefinitionVersion layer1.WorkflowDefinitionVersion
+	redeemingHumanTasks     map[string]bool
+	completingWork          map[pendingWorkKey]bool
+	enrichers               []InstanceEnricher
+	nodeID                  string
+	clock                   Clock
+	instanceTTL             time.Duration
 	mutex                   sync.RWMutex
 }
 
@@ -26,25 +83,99 @@ type WorkflowRuntimeEngine struct {
 type WorkflowRuntimeEngineInterface interface {
 	// Lifecycle operations
 	StartWorkflow(definition layer1.WorkflowDefinition, initialContext layer0.Context) (WorkflowInstanceID, error)
+	StartWorkflowWithOptions(definition layer1.WorkflowDefinition, initialContext *layer0.Context, options StartOptions) (WorkflowInstanceID, error)
 	StopWorkflow(instanceID WorkflowInstanceID) error
 	PauseWorkflow(instanceID WorkflowInstanceID) error
+	PauseWorkflowUntil(instanceID WorkflowInstanceID, deadline time.Time, onExpiry PauseExpiryAction) error
 	ResumeWorkflow(instanceID WorkflowInstanceID) error
 	CancelWorkflow(instanceID WorkflowInstanceID) error
+	CompleteAsyncWork(instanceID WorkflowInstanceID, workID layer0.WorkID, result AsyncWorkResult) error
+	CompleteHumanTask(token string, outcome string, payload map[string]interface{}) error
+	HumanTasks(instanceID WorkflowInstanceID) []HumanTask
+	ProcessStateTimeouts(now time.Time) (int, error)
+	ProcessStalledInstances(now time.Time) (int, error)
+	ProcessSLABreaches(now time.Time) (int, error)
+	PauseWorkflowsByFilter(filter InstanceFilter, reason, initiator string) []InstanceOperationResult
+	CancelWorkflowsByFilter(filter InstanceFilter, reason, initiator string) []InstanceOperationResult
 
 	// Execution operations
 	ExecuteStep(instanceID WorkflowInstanceID) error
 	ExecuteWorkflow(instanceID WorkflowInstanceID) error
 
+	// Debug operations
+	EnableDebug(instanceID WorkflowInstanceID) error
+	Step(instanceID WorkflowInstanceID) (StepResult, error)
+	Continue(instanceID WorkflowInstanceID) error
+	ExplainStep(instanceID WorkflowInstanceID) (StepExplanation, error)
+
 	// Query operations
 	GetWorkflowInstance(instanceID WorkflowInstanceID) (*WorkflowInstance, error)
 	GetWorkflowStatus(instanceID WorkflowInstanceID) (WorkflowInstanceStatus, error)
+	Progress(instanceID WorkflowInstanceID) (float64, layer0.StateID, error)
+	GetSLAStatus(instanceID WorkflowInstanceID) (SLAStatus, error)
 	ListActiveWorkflows() []WorkflowInstanceID
+	ListActiveWorkflowsDetailed() []WorkflowInstanceSummary
+	Introspect() RuntimeTopology
+	GetExecutionHistory(instanceID WorkflowInstanceID) []TransitionRecord
+	ExportTrace(instanceID WorkflowInstanceID) ([]SpanData, error)
+	WatchContextKey(instanceID WorkflowInstanceID, key string, handler ContextWatchHandler) (unwatch func(), err error)
+	SubscribeWorkResults(instanceID WorkflowInstanceID) (<-chan WorkResultEvent, func())
+	SharedContexts() *SharedContextStore
+	ListInUseDefinitions() []DefinitionUsage
+
+	// Retention
+	ReapExpiredInstances(now time.Time) (int, error)
+
+	// Health
+	Health() HealthReport
+	RegisterHealthCheck(name string, check HealthCheck) error
+	AddInstanceEnricher(enricher InstanceEnricher) error
 
 	// Configuration
+	RegisterExecutor(workType layer0.WorkType, executor layer1.WorkExecutor) error
+	RegisterConditionEvaluator(conditionType layer0.ConditionType, evaluator layer1.ConditionEvaluator) error
+	RegisterActionWork(actionID string, work layer0.Work) error
+	SetResultTransformer(actionID string, transformer layer1.ResultTransformer) error
+	SetWorkRouter(router layer1.WorkRouter)
+	SetFallbackExecutor(executor layer1.WorkExecutor)
+	SetExecutionBackend(backend ExecutionBackend)
+	ValidateExecutability(definition layer1.WorkflowDefinition, validator layer1.WorkValidator) []error
+	SetContextDefaults(definitionID layer1.WorkflowDefinitionID, defaults map[string]interface{})
+	SetCheckpointStore(store layer1.CheckpointStore)
+	SetExecutorTimeout(workType layer0.WorkType, timeout time.Duration) error
 	SetPersistenceStore(store StatePersistenceStore)
 	SetTransitionEvaluator(evaluator TransitionEvaluator)
 	SetErrorHandler(handler ErrorHandler)
 	SetLifecycleManager(manager WorkflowLifecycleManager)
+	SetLogger(logger layer1.Logger)
+	SetTransitionTiebreakSeed(seed int64)
+	SetTransitionSelectionStrategy(strategy layer1.TransitionSelectionStrategy) error
+	SetTransitionRateLimit(interval time.Duration) error
+	SetClock(clock Clock)
+	SetIDGenerator(generator IDGenerator)
+	EnableDeterministicMode(seed int64) *ManualClock
+	SetInstanceTTL(ttl time.Duration)
+	SetWorkPersistence(enabled bool)
+	SetCommandLog(log *CommandLog)
+	SetSecretProvider(provider SecretProvider)
+	SetContextProjection(enabled bool, sharedKeys ...string)
+	SetPersistenceFailurePolicy(policy PersistenceFailurePolicy)
+	SetExecutionMode(mode ExecutionMode, workers int) error
+	SetStepConcurrency(limit int) error
+	SetStallDetection(threshold time.Duration, markForAttention bool)
+	SetContextPatchLimit(limit int) error
+	SetSharder(sharder Sharder, nodeID string)
+	SetDefinitionResolver(resolve DefinitionResolver)
+
+	// Sharding
+	OwnsInstance(instanceID WorkflowInstanceID) bool
+
+	// Replay
+	Rebuild(log *CommandLog, resolve DefinitionResolver) error
+
+	// Suspension
+	Suspend() ([]byte, error)
+	Resume(data []byte) error
 
 	// Cleanup
 	Shutdown() error
@@ -52,45 +183,141 @@ type WorkflowRuntimeEngineInterface interface {
 
 // NewWorkflowRuntimeEngine creates a new workflow runtime engine
 func NewWorkflowRuntimeEngine() *WorkflowRuntimeEngine {
+	// Register the built-in executors
+	workExecutionCore := layer1.NewWorkExecutionCore()
+	workExecutionCore.RegisterExecutor(layer0.WorkTypeWait, layer1.NewWaitExecutor())
+	workExecutionCore.RegisterExecutor(layer0.WorkTypeNoop, layer1.NewNoopExecutor())
+	workExecutionCore.RegisterExecutor(layer0.WorkTypeDecision, layer1.NewDecisionExecutor())
+	workExecutionCore.RegisterExecutor(layer0.WorkTypeForEach, layer1.NewForEachExecutor(workExecutionCore))
+	workExecutionCore.RegisterExecutor(layer0.WorkTypeHumanTask, layer1.NewHumanTaskExecutor())
+
 	return &WorkflowRuntimeEngine{
 		stateMachineCore:        layer1.NewStateMachineCore(),
-		workExecutionCore:       layer1.NewWorkExecutionCore(),
+		workExecutionCore:       workExecutionCore,
+		executionBackend:        NewLocalExecutionBackend(workExecutionCore),
 		conditionEvaluationCore: layer1.NewConditionEvaluationCore(),
 		persistenceStore:        NewInMemoryStatePersistenceStore(),
 		transitionEvaluator:     NewDefaultTransitionEvaluator(),
 		errorHandler:            NewDefaultErrorHandler(),
 		lifecycleManager:        NewDefaultWorkflowLifecycleManager(),
+		logger:                  layer1.NewStdLogger(),
 		activeInstances:         make(map[WorkflowInstanceID]*WorkflowInstance),
+		finishedEphemeral:       make(map[WorkflowInstanceID]WorkflowInstance),
+		debugInstances:          make(map[WorkflowInstanceID]bool),
+		actionWork:              make(map[string]layer0.Work),
+		resultTransformers:      make(map[string]layer1.ResultTransformer),
+		parallelActionLimit:     defaultParallelActionLimit,
+		contextPatchLimit:       defaultContextPatchLimit,
+		persistWork:             true,
+		healthChecks:            make(map[string]HealthCheck),
+		instanceContexts:        make(map[WorkflowInstanceID]context.Context),
+		instanceCancels:         make(map[WorkflowInstanceID]context.CancelFunc),
+		contextWatches:          make(map[WorkflowInstanceID][]*contextWatch),
+		workResultSubscriptions: make(map[WorkflowInstanceID][]*workResultSubscription),
+		contextDefaults:         make(map[layer1.WorkflowDefinitionID]map[string]interface{}),
+		sharedContexts:          NewSharedContextStore(),
+		writeAhead:              writeAheadBuffer{policy: PersistenceFailurePolicyFail},
+		conditionTimeoutAction:  ConditionTimeoutSkip,
+		history:                 make(map[WorkflowInstanceID][]TransitionRecord),
+		humanTasks:              make(map[string]HumanTask),
+		redeemingHumanTasks:     make(map[string]bool),
+		completingWork:          make(map[pendingWorkKey]bool),
+		clock:                   NewSystemClock(),
 		mutex:                   sync.RWMutex{},
 	}
 }
 
 // StartWorkflow starts a new workflow instance
 func (engine *WorkflowRuntimeEngine) StartWorkflow(definition layer1.WorkflowDefinition, initialContext *layer0.Context) (WorkflowInstanceID, error) {
+	return engine.StartWorkflowWithOptions(definition, initialContext, StartOptions{})
+}
+
+// StartWorkflowWithOptions starts a new workflow instance with additional start options
+func (engine *WorkflowRuntimeEngine) StartWorkflowWithOptions(definition layer1.WorkflowDefinition, initialContext *layer0.Context, options StartOptions) (WorkflowInstanceID, error) {
+	issuedAt := engine.clock.Now()
+	instanceID, err := engine.startWorkflow(definition, initialContext, options)
+	if instanceID != "" {
+		engine.recordStartCommand(instanceID, definition, initialContext, options, issuedAt, err)
+	}
+	return instanceID, err
+}
+
+// startWorkflow starts a new workflow instance without recording the command
+func (engine *WorkflowRuntimeEngine) startWorkflow(definition layer1.WorkflowDefinition, initialContext *layer0.Context, options StartOptions) (WorkflowInstanceID, error) {
 	if !definition.CanExecute() {
 		return "", fmt.Errorf("workflow definition cannot be executed")
 	}
 
-	// Generate instance ID
-	instanceID := WorkflowInstanceID(fmt.Sprintf("%s-%d", definition.GetID(), time.Now().UnixNano()))
+	// Initialize state machine with definition and precompute transition lookups
+	engine.loadDefinition(definition)
+
+	// The first instance of a definition seeds its shared context from the global context
+	engine.sharedContexts.Seed(definition.GetID(), definition.GetGlobalContext())
+
+	// Older instances of the definition pick up its context defaults when loaded or resumed
+	if defaults := definition.GetConfiguration().ContextDefaults; defaults != nil {
+		engine.SetContextDefaults(definition.GetID(), defaults)
+	}
+
+	// Generate instance ID unless one was requested
+	instanceID := options.InstanceID
+	if instanceID == "" {
+		instanceID = engine.newInstanceID(string(definition.GetID()))
+	}
+
+	// Resolve the retention period for the instance once it finishes
+	engine.mutex.RLock()
+	ttl := engine.instanceTTL
+	engine.mutex.RUnlock()
+	if definition.GetConfiguration().InstanceTTL > 0 {
+		ttl = definition.GetConfiguration().InstanceTTL
+	}
 
 	// Create workflow instance
-	now := time.Now()
+	now := engine.clock.Now()
 	instance := WorkflowInstance{
-		ID:                instanceID,
-		DefinitionID:      definition.GetID(),
-		DefinitionVersion: definition.GetVersion(),
-		Status:            WorkflowInstanceStatusCreated,
-		CurrentStateID:    definition.GetInitialStateID(),
-		Context:           initialContext,
-		CreatedAt:         now,
-		UpdatedAt:         now,
-		Metadata:          make(map[string]interface{}),
+		ID:                    instanceID,
+		DefinitionID:          definition.GetID(),
+		DefinitionVersion:     definition.GetVersion(),
+		Status:                WorkflowInstanceStatusCreated,
+		CurrentStateID:        definition.GetInitialStateID(),
+		Context:               initialContext,
+		CreatedAt:             now,
+		UpdatedAt:             now,
+		Metadata:              make(map[string]interface{}),
+		SchemaVersion:         CurrentInstanceSchemaVersion,
+		TTL:                   ttl,
+		Labels:                options.copyLabels(),
+		CorrelationID:         engine.resolveCorrelationID(options),
+		RetryBudget:           definition.GetConfiguration().RetryPolicy.InstanceBudget,
+		ParentInstanceID:      options.ParentInstanceID,
+		Priority:              options.Priority,
+		MaxConcurrentWork:     definition.GetConfiguration().MaxConcurrentWork,
+		TransitionSelection:   definition.GetConfiguration().TransitionSelection,
+		MinTransitionInterval: definition.GetConfiguration().MinTransitionInterval,
+		SLA:                   definition.GetConfiguration().SLA,
+		Ephemeral:             !definition.GetConfiguration().PersistenceEnabled,
+		LoggingLevel:          definition.GetConfiguration().LoggingLevel,
+		Environment:           options.mergeEnvironment(definition.GetConfiguration().Environment),
+		ContextPruning:        definition.GetConfiguration().ContextPruning.Clone(),
+	}
+	engine.scheduleStateTimeout(&instance)
+
+	// Name the instance for operators from its initial context
+	if err := setDisplayName(&instance, definition.GetConfiguration()); err != nil {
+		return "", err
+	}
+
+	// Attach the metadata and labels every instance should carry
+	if err := engine.enrichInstance(&instance); err != nil {
+		return "", err
 	}
 
-	// Save to persistence store
-	if err := engine.persistenceStore.SaveWorkflowInstance(instance); err != nil {
-		return "", fmt.Errorf("failed to save workflow instance: %w", err)
+	// Save to persistence store unless the instance is ephemeral
+	if !instance.Ephemeral {
+		if err := engine.persistenceStore.SaveWorkflowInstance(instance); err != nil {
+			return "", fmt.Errorf("failed to save workflow instance: %w", err)
+		}
 	}
 
 	// Add to active instances
@@ -98,9 +325,6 @@ func (engine *WorkflowRuntimeEngine) StartWorkflow(definition layer1.WorkflowDef
 	engine.activeInstances[instanceID] = &instance
 	engine.mutex.Unlock()
 
-	// Initialize state machine with definition
-	engine.stateMachineCore = definition.GetStateMachine()
-
 	// Notify lifecycle manager
 	if err := engine.lifecycleManager.OnWorkflowStarted(instanceID); err != nil {
 		engine.errorHandler.HandleError(instanceID, fmt.Errorf("lifecycle manager error: %w", err))
@@ -108,18 +332,21 @@ func (engine *WorkflowRuntimeEngine) StartWorkflow(definition layer1.WorkflowDef
 
 	// Start execution
 	instance.Status = WorkflowInstanceStatusRunning
-	startedAt := time.Now()
+	startedAt := engine.clock.Now()
 	instance.StartedAt = &startedAt
 	instance.UpdatedAt = startedAt
+	instance.LastTransitionAt = &startedAt
+	instance.StateEnteredAt = &startedAt
 
 	// Update persistence
-	if err := engine.persistenceStore.UpdateWorkflowInstance(instance); err != nil {
+	if err := engine.updateInstance(instance); err != nil {
 		return instanceID, fmt.Errorf("failed to update workflow instance: %w", err)
 	}
 
 	// Update active instance
 	engine.mutex.Lock()
 	engine.activeInstances[instanceID] = &instance
+	engine.recordWorkflowStarted(&instance)
 	engine.mutex.Unlock()
 
 	return instanceID, nil
@@ -127,6 +354,14 @@ func (engine *WorkflowRuntimeEngine) StartWorkflow(definition layer1.WorkflowDef
 
 // StopWorkflow stops a running workflow instance
 func (engine *WorkflowRuntimeEngine) StopWorkflow(instanceID WorkflowInstanceID) error {
+	issuedAt := engine.clock.Now()
+	err := engine.stopWorkflow(instanceID)
+	engine.recordCommand(Command{Type: CommandStop, InstanceID: instanceID, IssuedAt: issuedAt}, err)
+	return err
+}
+
+// stopWorkflow stops a running workflow instance without recording the command
+func (engine *WorkflowRuntimeEngine) stopWorkflow(instanceID WorkflowInstanceID) error {
 	engine.mutex.Lock()
 	defer engine.mutex.Unlock()
 
@@ -140,18 +375,15 @@ func (engine *WorkflowRuntimeEngine) StopWorkflow(instanceID WorkflowInstanceID)
 	}
 
 	// Update status
-	instance.Status = WorkflowInstanceStatusCompleted
-	now := time.Now()
-	instance.CompletedAt = &now
-	instance.UpdatedAt = now
+	engine.markTerminal(instance, WorkflowInstanceStatusCompleted)
 
 	// Update persistence
-	if err := engine.persistenceStore.UpdateWorkflowInstance(*instance); err != nil {
+	if err := engine.updateInstance(*instance); err != nil {
 		return fmt.Errorf("failed to update workflow instance: %w", err)
 	}
 
 	// Remove from active instances
-	delete(engine.activeInstances, instanceID)
+	engine.retireInstanceUnsafe(instance)
 
 	// Notify lifecycle manager
 	if err := engine.lifecycleManager.OnWorkflowCompleted(instanceID); err != nil {
@@ -163,6 +395,14 @@ func (engine *WorkflowRuntimeEngine) StopWorkflow(instanceID WorkflowInstanceID)
 
 // PauseWorkflow pauses a running workflow instance
 func (engine *WorkflowRuntimeEngine) PauseWorkflow(instanceID WorkflowInstanceID) error {
+	issuedAt := engine.clock.Now()
+	err := engine.pauseWorkflow(instanceID)
+	engine.recordCommand(Command{Type: CommandPause, InstanceID: instanceID, IssuedAt: issuedAt}, err)
+	return err
+}
+
+// pauseWorkflow pauses a running workflow instance without recording the command
+func (engine *WorkflowRuntimeEngine) pauseWorkflow(instanceID WorkflowInstanceID) error {
 	engine.mutex.Lock()
 	defer engine.mutex.Unlock()
 
@@ -175,12 +415,17 @@ func (engine *WorkflowRuntimeEngine) PauseWorkflow(instanceID WorkflowInstanceID
 		return fmt.Errorf("workflow instance %s is not running", instanceID)
 	}
 
+	// Claim the status change in the store so concurrent changes cannot both apply
+	if err := engine.guardStatus(instance, WorkflowInstanceStatusPaused); err != nil {
+		return err
+	}
+
 	// Update status
 	instance.Status = WorkflowInstanceStatusPaused
-	instance.UpdatedAt = time.Now()
+	instance.UpdatedAt = engine.clock.Now()
 
 	// Update persistence
-	if err := engine.persistenceStore.UpdateWorkflowInstance(*instance); err != nil {
+	if err := engine.updateInstance(*instance); err != nil {
 		return fmt.Errorf("failed to update workflow instance: %w", err)
 	}
 
@@ -194,6 +439,14 @@ func (engine *WorkflowRuntimeEngine) PauseWorkflow(instanceID WorkflowInstanceID
 
 // ResumeWorkflow resumes a paused workflow instance
 func (engine *WorkflowRuntimeEngine) ResumeWorkflow(instanceID WorkflowInstanceID) error {
+	issuedAt := engine.clock.Now()
+	err := engine.resumeWorkflow(instanceID)
+	engine.recordCommand(Command{Type: CommandResume, InstanceID: instanceID, IssuedAt: issuedAt}, err)
+	return err
+}
+
+// resumeWorkflow resumes a paused workflow instance without recording the command
+func (engine *WorkflowRuntimeEngine) resumeWorkflow(instanceID WorkflowInstanceID) error {
 	engine.mutex.Lock()
 	defer engine.mutex.Unlock()
 
@@ -206,12 +459,20 @@ func (engine *WorkflowRuntimeEngine) ResumeWorkflow(instanceID WorkflowInstanceI
 		return fmt.Errorf("workflow instance %s is not paused", instanceID)
 	}
 
-	// Update status
+	// Claim the status change in the store so concurrent changes cannot both apply
+	if err := engine.guardStatus(instance, WorkflowInstanceStatusRunning); err != nil {
+		return err
+	}
+
+	// Update status and cancel any pause deadline
+	engine.applyContextDefaultsUnsafe(instance)
 	instance.Status = WorkflowInstanceStatusRunning
-	instance.UpdatedAt = time.Now()
+	instance.UpdatedAt = engine.clock.Now()
+	instance.PauseDeadline = nil
+	instance.PauseExpiry = ""
 
 	// Update persistence
-	if err := engine.persistenceStore.UpdateWorkflowInstance(*instance); err != nil {
+	if err := engine.updateInstance(*instance); err != nil {
 		return fmt.Errorf("failed to update workflow instance: %w", err)
 	}
 
@@ -225,13 +486,18 @@ func (engine *WorkflowRuntimeEngine) ResumeWorkflow(instanceID WorkflowInstanceI
 
 // CancelWorkflow cancels a workflow instance
 func (engine *WorkflowRuntimeEngine) CancelWorkflow(instanceID WorkflowInstanceID) error {
+	issuedAt := engine.clock.Now()
 	engine.mutex.Lock()
-	defer engine.mutex.Unlock()
+	err := engine.cancelWorkflowUnsafe(instanceID)
+	engine.mutex.Unlock()
 
-	return engine.cancelWorkflowUnsafe(instanceID)
+	engine.recordCommand(Command{Type: CommandCancel, InstanceID: instanceID, IssuedAt: issuedAt}, err)
+	return err
 }
 
-// cancelWorkflowUnsafe cancels a workflow instance without acquiring the mutex
+// cancelWorkflowUnsafe cancels a workflow instance without acquiring the mutex.
+// Child instances are cancelled first and in-flight work of the instance is
+// cancelled through its context before the instance is marked cancelled.
 // This method assumes the caller already holds the mutex lock
 func (engine *WorkflowRuntimeEngine) cancelWorkflowUnsafe(instanceID WorkflowInstanceID) error {
 	instance, exists := engine.activeInstances[instanceID]
@@ -239,19 +505,27 @@ func (engine *WorkflowRuntimeEngine) cancelWorkflowUnsafe(instanceID WorkflowIns
 		return fmt.Errorf("workflow instance %s not found", instanceID)
 	}
 
+	// Children are cancelled before the status change is claimed, so a child that
+	// cannot be cancelled leaves the instance as it was and the cancel can be retried
+	if err := engine.cancelChildrenUnsafe(instanceID); err != nil {
+		return err
+	}
+
+	// Claim the status change in the store so concurrent changes cannot both apply
+	if err := engine.guardStatus(instance, WorkflowInstanceStatusCancelled); err != nil {
+		return err
+	}
+
 	// Update status
-	instance.Status = WorkflowInstanceStatusCancelled
-	now := time.Now()
-	instance.CompletedAt = &now
-	instance.UpdatedAt = now
+	engine.markTerminal(instance, WorkflowInstanceStatusCancelled)
 
 	// Update persistence
-	if err := engine.persistenceStore.UpdateWorkflowInstance(*instance); err != nil {
+	if err := engine.updateInstance(*instance); err != nil {
 		return fmt.Errorf("failed to update workflow instance: %w", err)
 	}
 
 	// Remove from active instances
-	delete(engine.activeInstances, instanceID)
+	engine.retireInstanceUnsafe(instance)
 
 	// Notify lifecycle manager
 	if err := engine.lifecycleManager.OnWorkflowCancelled(instanceID); err != nil {
@@ -263,111 +537,470 @@ func (engine *WorkflowRuntimeEngine) cancelWorkflowUnsafe(instanceID WorkflowIns
 
 // ExecuteStep executes a single step of the workflow
 func (engine *WorkflowRuntimeEngine) ExecuteStep(instanceID WorkflowInstanceID) error {
+	issuedAt := engine.clock.Now()
+	_, err := engine.executeStep(instanceID, false)
+	engine.recordCommand(Command{Type: CommandStep, InstanceID: instanceID, IssuedAt: issuedAt}, err)
+	return err
+}
+
+// executeStep executes a single step of the workflow and reports what happened.
+// When evaluateAll is set every outgoing transition is evaluated before one is
+// chosen, so the result lists all candidates; otherwise evaluation stops at the
+// first satisfiable transition unless the selection strategy needs them all.
+func (engine *WorkflowRuntimeEngine) executeStep(instanceID WorkflowInstanceID, evaluateAll bool) (StepResult, error) {
+	// Wait for a step slot when step concurrency is limited
+	release, err := engine.scheduleStep(instanceID)
+	if err != nil {
+		return StepResult{}, err
+	}
+	defer release()
+
 	engine.mutex.RLock()
 	instance, exists := engine.activeInstances[instanceID]
+	suspended := engine.suspended
+	if !suspended {
+		engine.steps.Add(1)
+	}
 	engine.mutex.RUnlock()
 
+	if suspended {
+		return StepResult{}, ErrEngineSuspended
+	}
+	defer engine.steps.Done()
+
 	if !exists {
-		return fmt.Errorf("workflow instance %s not found", instanceID)
+		return StepResult{}, fmt.Errorf("workflow instance %s not found", instanceID)
 	}
 
 	if instance.Status != WorkflowInstanceStatusRunning {
-		return fmt.Errorf("workflow instance %s is not running", instanceID)
+		return StepResult{}, fmt.Errorf("workflow instance %s is not running", instanceID)
+	}
+
+	result := StepResult{
+		InstanceID:  instanceID,
+		FromStateID: instance.CurrentStateID,
+		ToStateID:   instance.CurrentStateID,
 	}
 
 	// Get current state
 	currentState, err := engine.stateMachineCore.GetState(instance.CurrentStateID)
 	if err != nil {
-		return fmt.Errorf("failed to get current state: %w", err)
+		return result, fmt.Errorf("failed to get current state: %w", err)
 	}
 
 	// Check if current state is final
 	if currentState.IsFinal() {
-		return engine.StopWorkflow(instanceID)
+		result.Completed = true
+		return result, engine.stopWorkflow(instanceID)
+	}
+
+	// Hold the step until the instance's transition rate limit allows it
+	if err := engine.awaitTransitionSlot(instance); err != nil {
+		return result, fmt.Errorf("workflow instance %s transition delayed: %w", instanceID, err)
 	}
 
-	// Get available transitions
-	transitions := engine.stateMachineCore.GetTransitionsFromState(instance.CurrentStateID)
+	// Get available transitions in selection order
+	transitions := selectableTransitions(engine.stateMachineCore.GetTransitionsFromState(instance.CurrentStateID))
 	if len(transitions) == 0 {
-		return fmt.Errorf("no transitions available from state %s", instance.CurrentStateID)
+		return result, fmt.Errorf("no transitions available from state %s", instance.CurrentStateID)
 	}
+	engine.orderTransitions(transitions)
 
-	// Evaluate transitions
-	for _, transition := range transitions {
-		canTransition, err := engine.transitionEvaluator.CanTransition(transition, instance.Context)
+	conditionContext := engine.conditionContext(instance)
+	if evaluateAll {
+		result.Candidates = engine.evaluateCandidates(instanceID, transitions, conditionContext)
+	}
+
+	// Weighted transitions and strategies other than first match choose among all
+	// satisfiable transitions
+	strategy := engine.transitionSelectionStrategy(instance)
+	selected := strategy != layer1.TransitionSelectionFirstMatch || hasWeightedTransition(transitions)
+	if selected {
+		var satisfiable []layer0.Transition
+		if evaluateAll {
+			for _, candidate := range result.Candidates {
+				if candidate.Satisfiable {
+					satisfiable = append(satisfiable, candidate.Transition)
+				}
+			}
+		} else {
+			satisfiable, err = engine.satisfiableTransitions(instanceID, transitions, conditionContext)
+			if errors.Is(err, ErrInstanceNotRunning) {
+				return result, err
+			}
+			if errors.Is(err, ErrConditionTimeout) {
+				if failErr := engine.failWorkflow(instanceID, err); failErr != nil {
+					return result, failErr
+				}
+				return result, fmt.Errorf("workflow instance %s failed: %w", instanceID, err)
+			}
+		}
+
+		transitions, err = engine.selectTransitions(strategy, satisfiable)
 		if err != nil {
-			engine.errorHandler.HandleError(instanceID, fmt.Errorf("transition evaluation error: %w", err))
-			continue
+			return result, fmt.Errorf("failed to select transition from state %s: %w", instance.CurrentStateID, err)
+		}
+	}
+
+	// Concurrent first-match evaluation settles the first satisfiable transition up
+	// front; transitions after it are evaluated in turn only if it fails to execute
+	raced := !selected && !evaluateAll && engine.concurrentConditionEvaluation()
+	if raced {
+		first, err := engine.firstSatisfiableTransition(instanceID, transitions, conditionContext)
+		if errors.Is(err, ErrInstanceNotRunning) {
+			return result, err
+		}
+		if err != nil {
+			if failErr := engine.failWorkflow(instanceID, err); failErr != nil {
+				return result, failErr
+			}
+			return result, fmt.Errorf("workflow instance %s failed: %w", instanceID, err)
+		}
+		if first < 0 {
+			transitions = nil
+		} else {
+			transitions = transitions[first:]
+		}
+	}
+
+	// Evaluate transitions
+	for i, transition := range transitions {
+		var canTransition bool
+		if selected || (raced && i == 0) {
+			canTransition = true
+		} else if evaluateAll {
+			canTransition = result.Candidates[i].Satisfiable
+		} else {
+			canTransition, err = engine.canTransition(instanceID, transition, conditionContext)
+			if errors.Is(err, ErrInstanceNotRunning) {
+				return result, err
+			}
+			if errors.Is(err, ErrConditionTimeout) {
+				if failErr := engine.failWorkflow(instanceID, err); failErr != nil {
+					return result, failErr
+				}
+				return result, fmt.Errorf("workflow instance %s failed: %w", instanceID, err)
+			}
+			if err != nil {
+				engine.errorHandler.HandleError(instanceID, fmt.Errorf("transition evaluation error: %w", err))
+				continue
+			}
 		}
 
 		if canTransition {
 			// Execute transition
-			if err := engine.executeTransition(instanceID, transition); err != nil {
-				engine.errorHandler.HandleError(instanceID, fmt.Errorf("transition execution error: %w", err))
+			workResults, err := engine.executeTransition(instanceID, transition)
+			if errors.Is(err, errWorkSuspended) {
+				chosen := transition
+				result.ChosenTransition = &chosen
+				result.WorkResults = workResults
+				result.Suspended = true
+				return result, nil
+			}
+			var exhausted *exhaustedTransitionError
+			if errors.As(err, &exhausted) {
+				chosen := transition
+				result.ChosenTransition = &chosen
+				result.ToStateID = exhausted.stateID
+				result.WorkResults = workResults
+				return result, engine.failOnErrorState(instanceID, exhausted.stateID, err)
+			}
+			if errors.Is(err, ErrRetryBudgetExhausted) {
+				if failErr := engine.failWorkflow(instanceID, err); failErr != nil {
+					return result, failErr
+				}
+				return result, fmt.Errorf("workflow instance %s failed: %w", instanceID, err)
+			}
+			if errors.Is(err, ErrInstanceNotRunning) || errors.Is(err, ErrTransitionSuperseded) {
+				return result, err
+			}
+			if errors.Is(err, ErrInvariantViolated) {
+				return result, fmt.Errorf("workflow instance %s failed: %w", instanceID, err)
+			}
+			if err != nil {
+				engine.errorHandler.HandleError(instanceID, fmt.Errorf("transition execution error (correlation %s): %w", instance.CorrelationID, err))
 				continue
 			}
-			return nil
+
+			chosen := transition
+			result.ChosenTransition = &chosen
+			result.ToStateID = transition.GetToStateID()
+			result.WorkResults = workResults
+			return result, nil
 		}
 	}
 
-	return fmt.Errorf("no valid transitions found from state %s", instance.CurrentStateID)
+	return result, fmt.Errorf("no valid transitions found from state %s", instance.CurrentStateID)
 }
 
-// executeTransition executes a specific transition
-func (engine *WorkflowRuntimeEngine) executeTransition(instanceID WorkflowInstanceID, transition layer0.Transition) error {
+// satisfiableTransitions evaluates every transition and returns those that are
+// satisfiable, in order. Evaluation errors are reported and the transition skipped,
+// except a condition timeout or the instance no longer running, which are returned.
+func (engine *WorkflowRuntimeEngine) satisfiableTransitions(instanceID WorkflowInstanceID, transitions []layer0.Transition, context *layer0.Context) ([]layer0.Transition, error) {
+	var satisfiable []layer0.Transition
+	for _, transition := range transitions {
+		canTransition, err := engine.canTransition(instanceID, transition, context)
+		if errors.Is(err, ErrConditionTimeout) || errors.Is(err, ErrInstanceNotRunning) {
+			return nil, err
+		}
+		if err != nil {
+			engine.errorHandler.HandleError(instanceID, fmt.Errorf("transition evaluation error: %w", err))
+			continue
+		}
+		if canTransition {
+			satisfiable = append(satisfiable, transition)
+		}
+	}
+	return satisfiable, nil
+}
+
+// evaluateCandidates evaluates every transition without executing any of them
+func (engine *WorkflowRuntimeEngine) evaluateCandidates(instanceID WorkflowInstanceID, transitions []layer0.Transition, context *layer0.Context) []TransitionCandidate {
+	candidates := make([]TransitionCandidate, 0, len(transitions))
+	for _, transition := range transitions {
+		candidate := TransitionCandidate{Transition: transition}
+
+		canTransition, err := engine.canTransition(instanceID, transition, context)
+		if err != nil {
+			engine.errorHandler.HandleError(instanceID, fmt.Errorf("transition evaluation error: %w", err))
+			candidate.Error = err.Error()
+		} else {
+			candidate.Satisfiable = canTransition
+		}
+
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates
+}
+
+// ErrInstanceNotRunning indicates a transition was aborted because its instance was
+// paused, cancelled or otherwise stopped while the step was in progress
+var ErrInstanceNotRunning = errors.New("workflow instance is no longer running")
+
+// ErrTransitionSuperseded indicates a transition was not committed because its instance
+// left the transition's source state while it ran, such as when the state timed out
+var ErrTransitionSuperseded = errors.New("workflow instance left the transition's source state")
+
+// ensureRunningUnsafe returns ErrInstanceNotRunning unless the instance is running.
+// This method assumes the caller already holds the mutex lock.
+func (engine *WorkflowRuntimeEngine) ensureRunningUnsafe(instance *WorkflowInstance) error {
+	if instance == nil {
+		return ErrInstanceNotRunning
+	}
+	if instance.Status != WorkflowInstanceStatusRunning {
+		return fmt.Errorf("%w: workflow instance %s is %s", ErrInstanceNotRunning, instance.ID, instance.Status)
+	}
+	return nil
+}
+
+// executeTransition executes a specific transition, honoring its recovery policy
+func (engine *WorkflowRuntimeEngine) executeTransition(instanceID WorkflowInstanceID, transition layer0.Transition) ([]layer1.WorkExecutionResult, error) {
+	// The instance may have been paused or cancelled since the step checked its status
+	engine.mutex.Lock()
+	err := engine.ensureRunningUnsafe(engine.activeInstances[instanceID])
+	engine.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if policy, exists := transition.GetRecoveryPolicy(); exists {
+		return engine.runTransitionWithRecovery(instanceID, transition, policy)
+	}
+	return engine.runTransition(instanceID, transition, 0, nil)
+}
+
+// runTransition executes a transition's actions starting at firstAction and
+// commits the state change once they all complete. Action outputs are staged on a
+// copy of the instance context, or on staged when resuming a suspended transition.
+// If an action is pending asynchronous completion the staged context is saved with
+// the pending work, the instance is suspended and errWorkSuspended is returned.
+func (engine *WorkflowRuntimeEngine) runTransition(instanceID WorkflowInstanceID, transition layer0.Transition, firstAction int, staged *layer0.Context) (workResults []layer1.WorkExecutionResult, err error) {
 	engine.mutex.Lock()
 	instance := engine.activeInstances[instanceID]
+	before := instance.Context
 	engine.mutex.Unlock()
 
+	// Record the attempt in the metrics, and in the execution history unless it was suspended
+	startedAt := engine.clock.Now()
+	defer func() {
+		record := TransitionRecord{
+			InstanceID:    instanceID,
+			CorrelationID: instance.CorrelationID,
+			TransitionID:  transition.GetID(),
+			FromStateID:   transition.GetFromStateID(),
+			ToStateID:     transition.GetToStateID(),
+			StartedAt:     startedAt,
+			CompletedAt:   engine.clock.Now(),
+			WorkResults:   workResults,
+			Tags:          append([]string(nil), transition.GetMetadata().Tags...),
+			Annotations:   transition.GetAnnotations(),
+		}
+
+		switch {
+		case errors.Is(err, errWorkSuspended):
+			engine.recordTransitionAttempt(instance, record, "suspended")
+			return
+		case err != nil:
+			record.Error = err.Error()
+			engine.recordTransitionAttempt(instance, record, "failed")
+		default:
+			engine.mutex.RLock()
+			after := instance.Context
+			engine.mutex.RUnlock()
+			engine.recordContextPatch(&record, before, after)
+			engine.recordTransitionAttempt(instance, record, "completed")
+		}
+		engine.recordTransition(record)
+	}()
+
+	actions := transition.GetActions()
+	workResults = make([]layer1.WorkExecutionResult, 0, len(actions))
+	ctx := layer1.WithCorrelationID(engine.instanceContext(instanceID), instance.CorrelationID)
+
+	// Stage context changes so a failing action leaves the instance context untouched
+	if staged == nil {
+		staged = instance.Context.Clone()
+	}
+
+	// Execute parallel actions together; a resumed transition finishes sequentially
+	if transition.GetExecutionMode() == layer0.ActionExecutionParallel && firstAction == 0 {
+		workResults, staged, err = engine.runParallelActions(ctx, instance, transition, staged)
+		if err != nil {
+			return workResults, err
+		}
+		return workResults, engine.commitTransition(instance, transition, staged)
+	}
+
 	// Execute transition actions (work items)
-	for _, actionID := range transition.GetActions() {
-		// Create work item
-		work := layer0.NewWork(layer0.WorkID(actionID), layer0.WorkTypeTask, fmt.Sprintf("Action %s", actionID))
+	for index := firstAction; index < len(actions); index++ {
+		actionID := actions[index]
+
+		// Shared actions read or write the definition's shared context instead of running work
+		if _, _, shared := parseSharedAction(actionID); shared {
+			if staged, err = engine.applySharedAction(instance.DefinitionID, actionID, staged); err != nil {
+				return workResults, err
+			}
+			continue
+		}
+
+		work := engine.buildActionWork(instanceID, actionID)
 
 		// Execute work
-		result, err := engine.workExecutionCore.ExecuteWork(work, instance.Context)
+		result, err := engine.executeWorkWithRetries(ctx, instance, work, staged)
 		if err != nil {
-			return fmt.Errorf("failed to execute work %s: %w", actionID, err)
+			return workResults, fmt.Errorf("failed to execute work %s: %w", actionID, err)
 		}
+		workResults = append(workResults, result)
 
 		if result.Status == layer0.WorkStatusFailed {
-			return fmt.Errorf("work %s failed: %s", actionID, result.Error)
+			return workResults, fmt.Errorf("work %s failed: %s", actionID, result.Error)
+		}
+
+		// Suspend until pending or blocked work is completed. Outputs of the actions
+		// already run stay staged, since the transition resumes after them.
+		if isAwaitingCompletion(result.Status) {
+			if err := engine.suspendForAsyncWork(instance, transition, index, work, result.Status, staged); err != nil {
+				return workResults, err
+			}
+			return workResults, errWorkSuspended
 		}
 
-		// Update context with work output if available
-		if result.Output != nil {
-			instance.Context = instance.Context.Set(fmt.Sprintf("work_%s_output", actionID), result.Output)
+		// Update staged context with work output if available
+		output, err := engine.transformOutput(work, result.Output)
+		if err != nil {
+			return workResults, err
+		}
+		if output != nil {
+			staged = staged.Set(actionOutputKey(work), output)
 		}
 	}
 
-	// Update current state
-	instance.CurrentStateID = transition.GetToStateID()
-	instance.UpdatedAt = time.Now()
+	return workResults, engine.commitTransition(instance, transition, staged)
+}
 
-	// Update persistence
-	if err := engine.persistenceStore.UpdateWorkflowInstance(*instance); err != nil {
+// commitTransition prunes and persists the staged context and target state, then applies them to the instance.
+// If the staged context violates an invariant nothing is committed and the instance fails.
+func (engine *WorkflowRuntimeEngine) commitTransition(instance *WorkflowInstance, transition layer0.Transition, staged *layer0.Context) error {
+	outputKeys, staged := engine.pruneContext(instance, transition, staged)
+	if err := engine.checkInvariants(staged); err != nil {
+		if failErr := engine.failWorkflow(instance.ID, err); failErr != nil {
+			return failErr
+		}
+		return err
+	}
+
+	transitionedAt := engine.clock.Now()
+	nextTransitionAt := engine.nextTransitionAt(instance, transitionedAt)
+
+	// Persist and apply the commit under the lock, so an instance paused, cancelled or
+	// moved to another state while its actions ran is not moved on
+	engine.mutex.Lock()
+	if err := engine.ensureRunningUnsafe(instance); err != nil {
+		engine.mutex.Unlock()
+		return err
+	}
+	if instance.CurrentStateID != transition.GetFromStateID() {
+		engine.mutex.Unlock()
+		return fmt.Errorf("%w: workflow instance %s is in %s, not %s", ErrTransitionSuperseded, instance.ID, instance.CurrentStateID, transition.GetFromStateID())
+	}
+
+	// Persist the staged context and new state before committing them to the instance
+	committed := *instance
+	committed.Context = staged
+	committed.OutputKeys = outputKeys
+	committed.CurrentStateID = transition.GetToStateID()
+	committed.UpdatedAt = transitionedAt
+	committed.LastTransitionAt = &transitionedAt
+	if committed.CurrentStateID != instance.CurrentStateID || committed.StateEnteredAt == nil {
+		committed.StateEnteredAt = &transitionedAt
+	}
+	committed.StalledAt = nil
+	committed.NextTransitionAt = nextTransitionAt
+	engine.scheduleStateTimeout(&committed)
+
+	if err := engine.updateInstance(committed); err != nil {
+		engine.mutex.Unlock()
 		return fmt.Errorf("failed to update workflow instance: %w", err)
 	}
 
 	// Update active instance
-	engine.mutex.Lock()
-	engine.activeInstances[instanceID] = instance
+	previous := instance.Context
+	*instance = committed
+	engine.activeInstances[instance.ID] = instance
 	engine.mutex.Unlock()
 
+	engine.notifyContextWatches(instance.ID, previous, staged)
+
 	return nil
 }
 
 // ExecuteWorkflow executes a workflow until completion or error
 func (engine *WorkflowRuntimeEngine) ExecuteWorkflow(instanceID WorkflowInstanceID) error {
+	issuedAt := engine.clock.Now()
+	err := engine.executeWorkflow(instanceID)
+	engine.recordCommand(Command{Type: CommandExecute, InstanceID: instanceID, IssuedAt: issuedAt}, err)
+	return err
+}
+
+// executeWorkflow executes a workflow until completion or error without recording the command
+func (engine *WorkflowRuntimeEngine) executeWorkflow(instanceID WorkflowInstanceID) error {
+	if engine.IsDebugEnabled(instanceID) {
+		return fmt.Errorf("workflow instance %s is in debug mode; use Step or Continue", instanceID)
+	}
+
 	maxSteps := 1000 // Prevent infinite loops
 
 	for i := 0; i < maxSteps; i++ {
-		err := engine.ExecuteStep(instanceID)
+		_, err := engine.executeStep(instanceID, false)
 		if err != nil {
 			// Check if workflow completed normally
 			if err.Error() == fmt.Sprintf("workflow instance %s is not running", instanceID) {
 				return nil // Workflow completed
 			}
+			if errors.Is(err, ErrInstanceNotRunning) || errors.Is(err, ErrTransitionSuperseded) {
+				return nil // Workflow paused, cancelled or moved on during the step
+			}
 			return err
 		}
 
@@ -394,6 +1027,19 @@ func (engine *WorkflowRuntimeEngine) GetWorkflowInstance(instanceID WorkflowInst
 		return instance, nil
 	}
 
+	// Finished ephemeral instances are only held in memory
+	engine.mutex.RLock()
+	finished, exists := engine.finishedEphemeral[instanceID]
+	engine.mutex.RUnlock()
+	if exists {
+		return &finished, nil
+	}
+
+	// Updates the store has not accepted yet are newer than its copy
+	if buffered, exists := engine.bufferedInstance(instanceID); exists {
+		return &buffered, nil
+	}
+
 	// Try to get from persistence store
 	persistedInstance, err := engine.persistenceStore.GetWorkflowInstance(instanceID)
 	if err != nil {
@@ -413,7 +1059,7 @@ func (engine *WorkflowRuntimeEngine) GetWorkflowStatus(instanceID WorkflowInstan
 	return instance.Status, nil
 }
 
-// ListActiveWorkflows returns a list of active workflow instance IDs
+// ListActiveWorkflows returns the IDs of active workflow instances in sorted order
 func (engine *WorkflowRuntimeEngine) ListActiveWorkflows() []WorkflowInstanceID {
 	engine.mutex.RLock()
 	defer engine.mutex.RUnlock()
@@ -422,10 +1068,53 @@ func (engine *WorkflowRuntimeEngine) ListActiveWorkflows() []WorkflowInstanceID
 	for instanceID := range engine.activeInstances {
 		instanceIDs = append(instanceIDs, instanceID)
 	}
+	sort.Slice(instanceIDs, func(i, j int) bool {
+		return instanceIDs[i] < instanceIDs[j]
+	})
 
 	return instanceIDs
 }
 
+// WorkflowInstanceSummary describes an active workflow instance for listings
+type WorkflowInstanceSummary struct {
+	ID                WorkflowInstanceID               `json:"id"`
+	DefinitionID      layer1.WorkflowDefinitionID      `json:"definition_id"`
+	DefinitionVersion layer1.WorkflowDefinitionVersion `json:"definition_version"`
+	Status            WorkflowInstanceStatus           `json:"status"`
+	CurrentStateID    layer0.StateID                   `json:"current_state_id"`
+	DisplayName       string                           `json:"display_name,omitempty"`
+	StartedAt         *time.Time                       `json:"started_at,omitempty"`
+}
+
+// ListActiveWorkflowsDetailed returns summaries of the active workflow instances
+// ordered by ID, read from memory without querying the persistence store
+func (engine *WorkflowRuntimeEngine) ListActiveWorkflowsDetailed() []WorkflowInstanceSummary {
+	engine.mutex.RLock()
+	defer engine.mutex.RUnlock()
+
+	summaries := make([]WorkflowInstanceSummary, 0, len(engine.activeInstances))
+	for _, instance := range engine.activeInstances {
+		summary := WorkflowInstanceSummary{
+			ID:                instance.ID,
+			DefinitionID:      instance.DefinitionID,
+			DefinitionVersion: instance.DefinitionVersion,
+			Status:            instance.Status,
+			CurrentStateID:    instance.CurrentStateID,
+			DisplayName:       instance.DisplayName(),
+		}
+		if instance.StartedAt != nil {
+			startedAt := *instance.StartedAt
+			summary.StartedAt = &startedAt
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].ID < summaries[j].ID
+	})
+
+	return summaries
+}
+
 // SetPersistenceStore sets the persistence store
 func (engine *WorkflowRuntimeEngine) SetPersistenceStore(store StatePersistenceStore) {
 	engine.persistenceStore = store
@@ -441,11 +1130,25 @@ func (engine *WorkflowRuntimeEngine) SetErrorHandler(handler ErrorHandler) {
 	engine.errorHandler = handler
 }
 
+// SetClock sets the clock used for instance timestamps and expiry
+func (engine *WorkflowRuntimeEngine) SetClock(clock Clock) {
+	engine.clock = clock
+}
+
 // SetLifecycleManager sets the lifecycle manager
 func (engine *WorkflowRuntimeEngine) SetLifecycleManager(manager WorkflowLifecycleManager) {
 	engine.lifecycleManager = manager
 }
 
+// SetLogger sets the logger executors log through. Each instance filters it by the
+// logging level of its definition; a nil logger discards every message.
+func (engine *WorkflowRuntimeEngine) SetLogger(logger layer1.Logger) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.logger = logger
+}
+
 // Shutdown shuts down the workflow runtime engine
 func (engine *WorkflowRuntimeEngine) Shutdown() error {
 	engine.mutex.Lock()
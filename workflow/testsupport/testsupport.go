@@ -0,0 +1,186 @@
+// Package testsupport provides fixtures for testing workflow definitions and the
+// runtime engine: definition builders, mock executors and evaluators, and
+// assertions on instance outcomes.
+package testsupport
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer1"
+	"github.com/ubom/workflow/layer2"
+)
+
+// LinearStateID returns the ID of the state at the given 1-based position in a SimpleLinearWorkflow
+func LinearStateID(position int) layer0.StateID {
+	return layer0.StateID(fmt.Sprintf("state-%d", position))
+}
+
+// SimpleLinearWorkflow builds an active definition of n states chained by automatic
+// transitions, from LinearStateID(1) to the final LinearStateID(n). n is raised to 2
+// if smaller, since a workflow needs distinct initial and final states.
+func SimpleLinearWorkflow(n int) layer1.WorkflowDefinition {
+	if n < 2 {
+		n = 2
+	}
+
+	stateMachine := layer1.NewStateMachineCore()
+	for position := 1; position <= n; position++ {
+		stateType := layer0.StateTypeIntermediate
+		switch position {
+		case 1:
+			stateType = layer0.StateTypeInitial
+		case n:
+			stateType = layer0.StateTypeFinal
+		}
+
+		stateID := LinearStateID(position)
+		stateMachine.AddState(layer0.NewState(stateID, stateType, string(stateID)))
+
+		if position > 1 {
+			fromStateID := LinearStateID(position - 1)
+			transitionID := layer0.TransitionID(fmt.Sprintf("%s-to-%s", fromStateID, stateID))
+			stateMachine.AddTransition(layer0.NewTransition(transitionID, layer0.TransitionTypeAutomatic, fromStateID, stateID, string(transitionID)))
+		}
+	}
+
+	return layer1.NewWorkflowDefinition(layer1.WorkflowDefinitionID(fmt.Sprintf("linear-%d", n)), "1.0.0", fmt.Sprintf("Linear Workflow (%d states)", n)).
+		SetStateMachine(stateMachine).
+		SetInitialStateID(LinearStateID(1)).
+		AddFinalStateID(LinearStateID(n)).
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+}
+
+// BranchingWorkflow builds an active definition that goes from "start" to "left" or
+// "right" and on to the final "end" state. The branches are conditional on the
+// "go_left" and "go_right" context keys under the default transition evaluator.
+func BranchingWorkflow() layer1.WorkflowDefinition {
+	stateMachine := layer1.NewStateMachineCore()
+	stateMachine.AddState(layer0.NewState("start", layer0.StateTypeInitial, "Start"))
+	stateMachine.AddState(layer0.NewState("left", layer0.StateTypeIntermediate, "Left"))
+	stateMachine.AddState(layer0.NewState("right", layer0.StateTypeIntermediate, "Right"))
+	stateMachine.AddState(layer0.NewState("end", layer0.StateTypeFinal, "End"))
+
+	stateMachine.AddTransition(layer0.NewTransition("start-to-left", layer0.TransitionTypeConditional, "start", "left", "Start to Left").
+		AddCondition("go_left"))
+	stateMachine.AddTransition(layer0.NewTransition("start-to-right", layer0.TransitionTypeConditional, "start", "right", "Start to Right").
+		AddCondition("go_right"))
+	stateMachine.AddTransition(layer0.NewTransition("left-to-end", layer0.TransitionTypeAutomatic, "left", "end", "Left to End"))
+	stateMachine.AddTransition(layer0.NewTransition("right-to-end", layer0.TransitionTypeAutomatic, "right", "end", "Right to End"))
+
+	return layer1.NewWorkflowDefinition("branching", "1.0.0", "Branching Workflow").
+		SetStateMachine(stateMachine).
+		SetInitialStateID("start").
+		AddFinalStateID("end").
+		SetStatus(layer1.WorkflowDefinitionStatusActive)
+}
+
+// NewContext creates a workflow context holding the given values
+func NewContext(values map[string]interface{}) *layer0.Context {
+	context := layer0.NewContext("test-context", layer0.ContextScopeWorkflow, "Test Context")
+	for key, value := range values {
+		context = context.Set(key, value)
+	}
+	return context
+}
+
+// RegisterMockExecutor registers a mock executor for the work type. A nil execute
+// function succeeds without output.
+func RegisterMockExecutor(t testing.TB, engine *layer2.WorkflowRuntimeEngine, workType layer0.WorkType, execute func(layer0.Work, *layer0.Context) (interface{}, error)) *layer1.MockWorkExecutor {
+	t.Helper()
+
+	executor := layer1.NewMockWorkExecutor([]layer0.WorkType{workType}, execute)
+	if err := engine.RegisterExecutor(workType, executor); err != nil {
+		t.Fatalf("failed to register mock executor for %s: %v", workType, err)
+	}
+	return executor
+}
+
+// FixedTransitionEvaluator allows or blocks transitions by ID, allowing any transition it does not list
+type FixedTransitionEvaluator struct {
+	allowed map[layer0.TransitionID]bool
+}
+
+// NewFixedTransitionEvaluator creates a new fixed transition evaluator
+func NewFixedTransitionEvaluator(allowed map[layer0.TransitionID]bool) *FixedTransitionEvaluator {
+	copied := make(map[layer0.TransitionID]bool, len(allowed))
+	for transitionID, allow := range allowed {
+		copied[transitionID] = allow
+	}
+	return &FixedTransitionEvaluator{allowed: copied}
+}
+
+// CanTransition reports whether the transition is allowed
+func (evaluator *FixedTransitionEvaluator) CanTransition(transition layer0.Transition, context *layer0.Context) (bool, error) {
+	if allow, listed := evaluator.allowed[transition.GetID()]; listed {
+		return allow, nil
+	}
+	return true, nil
+}
+
+// EvaluateConditions treats every condition as holding
+func (evaluator *FixedTransitionEvaluator) EvaluateConditions(conditionIDs []string, context *layer0.Context) (bool, error) {
+	return true, nil
+}
+
+// RunToCompletion starts and executes an instance of the definition, failing the
+// test unless it completes
+func RunToCompletion(t testing.TB, engine *layer2.WorkflowRuntimeEngine, definition layer1.WorkflowDefinition, context *layer0.Context) layer2.WorkflowInstanceID {
+	t.Helper()
+
+	if context == nil {
+		context = NewContext(nil)
+	}
+
+	instanceID, err := engine.StartWorkflow(definition, context)
+	if err != nil {
+		t.Fatalf("failed to start %s: %v", definition.GetID(), err)
+	}
+
+	if err := engine.ExecuteWorkflow(instanceID); err != nil {
+		t.Fatalf("failed to execute %s: %v", instanceID, err)
+	}
+
+	AssertStatus(t, engine, instanceID, layer2.WorkflowInstanceStatusCompleted)
+	return instanceID
+}
+
+// AssertStatus fails the test unless the instance has the expected status
+func AssertStatus(t testing.TB, engine *layer2.WorkflowRuntimeEngine, instanceID layer2.WorkflowInstanceID, expected layer2.WorkflowInstanceStatus) {
+	t.Helper()
+
+	instance, err := engine.GetWorkflowInstance(instanceID)
+	if err != nil {
+		t.Fatalf("failed to get instance %s: %v", instanceID, err)
+	}
+	if instance.Status != expected {
+		t.Errorf("expected instance %s to be %s, got %s (error: %s)", instanceID, expected, instance.Status, instance.Error)
+	}
+}
+
+// AssertState fails the test unless the instance is in the expected state
+func AssertState(t testing.TB, engine *layer2.WorkflowRuntimeEngine, instanceID layer2.WorkflowInstanceID, expected layer0.StateID) {
+	t.Helper()
+
+	instance, err := engine.GetWorkflowInstance(instanceID)
+	if err != nil {
+		t.Fatalf("failed to get instance %s: %v", instanceID, err)
+	}
+	if instance.CurrentStateID != expected {
+		t.Errorf("expected instance %s in state %s, got %s", instanceID, expected, instance.CurrentStateID)
+	}
+}
+
+// AssertContextValue fails the test unless the instance context holds the expected value
+func AssertContextValue(t testing.TB, engine *layer2.WorkflowRuntimeEngine, instanceID layer2.WorkflowInstanceID, key string, expected interface{}) {
+	t.Helper()
+
+	instance, err := engine.GetWorkflowInstance(instanceID)
+	if err != nil {
+		t.Fatalf("failed to get instance %s: %v", instanceID, err)
+	}
+	if value, exists := instance.Context.Get(key); !exists || value != expected {
+		t.Errorf("expected context key %s of instance %s to be %v, got %v", key, instanceID, expected, value)
+	}
+}
@@ -0,0 +1,59 @@
+package testsupport
+
+import (
+	"testing"
+
+	"github.com/ubom/workflow/layer0"
+	"github.com/ubom/workflow/layer2"
+)
+
+func TestSimpleLinearWorkflowRunsToCompletion(t *testing.T) {
+	definition := SimpleLinearWorkflow(5)
+	if err := definition.Validate(); err != nil {
+		t.Fatalf("SimpleLinearWorkflow should produce a valid definition: %v", err)
+	}
+
+	if states := definition.GetStateMachine().GetAllStates(); len(states) != 5 {
+		t.Errorf("Expected 5 states, got %d", len(states))
+	}
+
+	engine := layer2.NewWorkflowRuntimeEngine()
+	instanceID := RunToCompletion(t, engine, definition, nil)
+	AssertState(t, engine, instanceID, LinearStateID(5))
+}
+
+func TestBranchingWorkflowFollowsContext(t *testing.T) {
+	definition := BranchingWorkflow()
+	if err := definition.Validate(); err != nil {
+		t.Fatalf("BranchingWorkflow should produce a valid definition: %v", err)
+	}
+
+	engine := layer2.NewWorkflowRuntimeEngine()
+	instanceID, err := engine.StartWorkflow(definition, NewContext(map[string]interface{}{"go_left": false, "go_right": true}))
+	if err != nil {
+		t.Fatalf("StartWorkflow should not return error: %v", err)
+	}
+	if err := engine.ExecuteStep(instanceID); err != nil {
+		t.Fatalf("ExecuteStep should not return error: %v", err)
+	}
+	AssertState(t, engine, instanceID, "right")
+	AssertContextValue(t, engine, instanceID, "go_right", true)
+}
+
+func TestFixedTransitionEvaluatorAndMockExecutor(t *testing.T) {
+	engine := layer2.NewWorkflowRuntimeEngine()
+	engine.SetTransitionEvaluator(NewFixedTransitionEvaluator(map[layer0.TransitionID]bool{"start-to-left": false}))
+
+	executor := RegisterMockExecutor(t, engine, layer0.WorkTypeTask, nil)
+	if !executor.CanExecute(layer0.WorkTypeTask) {
+		t.Error("RegisterMockExecutor should return an executor for the work type")
+	}
+
+	instanceID := RunToCompletion(t, engine, BranchingWorkflow(), nil)
+	AssertStatus(t, engine, instanceID, layer2.WorkflowInstanceStatusCompleted)
+
+	history := engine.GetExecutionHistory(instanceID)
+	if len(history) == 0 || history[0].TransitionID != "start-to-right" {
+		t.Errorf("Expected the blocked branch to be skipped, got %v", history)
+	}
+}